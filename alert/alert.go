@@ -0,0 +1,293 @@
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"vrmix/events"
+	"vrmix/logging"
+)
+
+// Severity represents how much a condition affects the playback.
+type Severity string
+
+const (
+	// OK indicates that the condition does not affect the playback.
+	OK Severity = "ok"
+
+	// Warning indicates that the condition will affect the playback if it gets worse.
+	Warning Severity = "warning"
+
+	// Critical indicates that the condition affects or is about to affect the playback.
+	Critical Severity = "critical"
+)
+
+// Condition represents a kind of condition affecting the playback of a channel.
+type Condition string
+
+const (
+	// WindowUnderrun indicates that the live window of the channel holds too little media ahead of the players.
+	WindowUnderrun Condition = "window_underrun"
+
+	// OriginFailures indicates that too many fetches from the origins of the channel failed recently.
+	OriginFailures Condition = "origin_failures"
+
+	// ConversionBacklog indicates that the conversion of the channel runs slower than real-time.
+	ConversionBacklog Condition = "conversion_backlog"
+)
+
+// Thresholds represents the limits raising the alerts, each zero field using its default.
+type Thresholds struct {
+	UnderrunWarning  float64       // Target durations buffered below which a warning is raised, defaults to 2
+	UnderrunCritical float64       // Target durations buffered below which a critical alert is raised, defaults to 1
+	FailureWindow    time.Duration // Window the origin fetches are counted in, defaults to one minute
+	MinFetches       int           // Fetches needed in the window before the failure ratio is considered, defaults to 5
+	FailureWarning   float64       // Fraction of failed fetches in the window raising a warning, defaults to 0.1
+	FailureCritical  float64       // Fraction of failed fetches in the window raising a critical alert, defaults to 0.5
+	BacklogWarning   time.Duration // Conversion lag raising a warning, defaults to 10 seconds
+	BacklogCritical  time.Duration // Conversion lag raising a critical alert, defaults to 30 seconds
+}
+
+// withDefaults returns the thresholds with the zero fields set to their default.
+func (t Thresholds) withDefaults() Thresholds {
+	if t.UnderrunWarning <= 0 {
+		t.UnderrunWarning = 2
+	}
+
+	if t.UnderrunCritical <= 0 {
+		t.UnderrunCritical = 1
+	}
+
+	if t.FailureWindow <= 0 {
+		t.FailureWindow = time.Minute
+	}
+
+	if t.MinFetches <= 0 {
+		t.MinFetches = 5
+	}
+
+	if t.FailureWarning <= 0 {
+		t.FailureWarning = 0.1
+	}
+
+	if t.FailureCritical <= 0 {
+		t.FailureCritical = 0.5
+	}
+
+	if t.BacklogWarning <= 0 {
+		t.BacklogWarning = 10 * time.Second
+	}
+
+	if t.BacklogCritical <= 0 {
+		t.BacklogCritical = 30 * time.Second
+	}
+
+	return t
+}
+
+// Alert represents a condition raised on a channel.
+type Alert struct {
+	Channel   string    `json:"channel"`   // Channel affected
+	Condition Condition `json:"condition"` // Condition detected
+	Severity  Severity  `json:"severity"`  // Current severity of the condition
+	Message   string    `json:"message"`   // Human readable description of the measurement
+	Since     time.Time `json:"since"`     // Time the alert was raised or last changed severity
+}
+
+// alertKey identifies the alert of a condition on a channel.
+type alertKey struct {
+	channel   string
+	condition Condition
+}
+
+// fetch is the outcome of an origin fetch.
+type fetch struct {
+	time   time.Time
+	failed bool
+}
+
+// Detector evaluates the measurements reported by the subsystems, publishing AlertRaised when a condition gets worse and AlertResolved when it is back to normal.
+type Detector struct {
+	Thresholds Thresholds   // Limits raising the alerts
+	Bus        *events.Bus  // Bus receiving the alerts, forwarded to the webhooks, nil to only log them
+	Logger     *slog.Logger // Logger receiving the alerts, defaults to slog.Default
+
+	mutex   sync.Mutex
+	alerts  map[alertKey]Alert
+	fetches map[string][]fetch
+	now     func() time.Time
+}
+
+// time returns the current time.
+func (d *Detector) time() time.Time {
+	if d.now == nil {
+		return time.Now()
+	}
+
+	return d.now()
+}
+
+// update records the severity of the condition, publishing the change if any.
+func (d *Detector) update(channel string, condition Condition, severity Severity, message string) {
+	d.mutex.Lock()
+	if d.alerts == nil {
+		d.alerts = make(map[alertKey]Alert)
+	}
+
+	key := alertKey{channel: channel, condition: condition}
+	previous, raised := d.alerts[key]
+	if (!raised && severity == OK) || (raised && previous.Severity == severity) {
+		d.mutex.Unlock()
+		return
+	}
+
+	alert := Alert{Channel: channel, Condition: condition, Severity: severity, Message: message, Since: d.time()}
+	if severity == OK {
+		delete(d.alerts, key)
+	} else {
+		d.alerts[key] = alert
+	}
+	d.mutex.Unlock()
+
+	eventType, level := events.AlertRaised, slog.LevelWarn
+	if severity == OK {
+		eventType, level = events.AlertResolved, slog.LevelInfo
+	} else if severity == Critical {
+		level = slog.LevelError
+	}
+
+	logging.Or(d.Logger).Log(context.Background(), level, "playback alert", slog.String(logging.ChannelKey, channel), slog.String("condition", string(condition)), slog.String("severity", string(severity)), slog.String("message", message))
+
+	if d.Bus != nil {
+		d.Bus.Publish(events.Event{Type: eventType, Time: alert.Since, Channel: channel, Data: map[string]any{
+			"condition": string(condition),
+			"severity":  string(severity),
+			"message":   message,
+		}})
+	}
+}
+
+// ReportWindow reports the media buffered in the live window of the channel ahead of the players, raising WindowUnderrun when it holds too few target durations.
+func (d *Detector) ReportWindow(channel string, buffered time.Duration, targetDuration time.Duration) {
+	thresholds := d.Thresholds.withDefaults()
+	if targetDuration <= 0 {
+		return
+	}
+
+	durations := buffered.Seconds() / targetDuration.Seconds()
+	severity := OK
+	if durations < thresholds.UnderrunCritical {
+		severity = Critical
+	} else if durations < thresholds.UnderrunWarning {
+		severity = Warning
+	}
+
+	d.update(channel, WindowUnderrun, severity, "window holds "+strconv.FormatFloat(durations, 'f', 1, 64)+" target durations")
+}
+
+// ReportFetch reports the outcome of an origin fetch of the channel, raising OriginFailures when too many fetches failed within the window.
+func (d *Detector) ReportFetch(channel string, err error) {
+	thresholds := d.Thresholds.withDefaults()
+	now := d.time()
+
+	d.mutex.Lock()
+	if d.fetches == nil {
+		d.fetches = make(map[string][]fetch)
+	}
+
+	fetches := append(d.fetches[channel], fetch{time: now, failed: err != nil})
+	expired := 0
+	for expired < len(fetches) && now.Sub(fetches[expired].time) > thresholds.FailureWindow {
+		expired++
+	}
+	fetches = fetches[expired:]
+	d.fetches[channel] = fetches
+
+	failed := 0
+	for _, f := range fetches {
+		if f.failed {
+			failed++
+		}
+	}
+	d.mutex.Unlock()
+
+	if len(fetches) < thresholds.MinFetches {
+		return
+	}
+
+	ratio := float64(failed) / float64(len(fetches))
+	severity := OK
+	if ratio >= thresholds.FailureCritical {
+		severity = Critical
+	} else if ratio >= thresholds.FailureWarning {
+		severity = Warning
+	}
+
+	d.update(channel, OriginFailures, severity, strconv.Itoa(failed)+" of "+strconv.Itoa(len(fetches))+" origin fetches failed in "+thresholds.FailureWindow.String())
+}
+
+// ReportBacklog reports how far the conversion of the channel lags behind the playback, raising ConversionBacklog when it runs slower than real-time for too long.
+func (d *Detector) ReportBacklog(channel string, lag time.Duration) {
+	thresholds := d.Thresholds.withDefaults()
+
+	severity := OK
+	if lag >= thresholds.BacklogCritical {
+		severity = Critical
+	} else if lag >= thresholds.BacklogWarning {
+		severity = Warning
+	}
+
+	d.update(channel, ConversionBacklog, severity, "conversion lags "+lag.Round(time.Second).String()+" behind the playback")
+}
+
+// Forget resolves the alerts of the channel and forgets its measurements, like when it is deleted.
+func (d *Detector) Forget(channel string) {
+	d.mutex.Lock()
+	delete(d.fetches, channel)
+	var conditions []Condition
+	for key := range d.alerts {
+		if key.channel == channel {
+			conditions = append(conditions, key.condition)
+		}
+	}
+	d.mutex.Unlock()
+
+	for _, condition := range conditions {
+		d.update(channel, condition, OK, "channel removed")
+	}
+}
+
+// Active returns the alerts raised, sorted by channel and condition.
+func (d *Detector) Active() []Alert {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	alerts := make([]Alert, 0, len(d.alerts))
+	for _, alert := range d.alerts {
+		alerts = append(alerts, alert)
+	}
+
+	slices.SortFunc(alerts, func(a, b Alert) int {
+		if c := strings.Compare(a.Channel, b.Channel); c != 0 {
+			return c
+		}
+
+		return strings.Compare(string(a.Condition), string(b.Condition))
+	})
+
+	return alerts
+}
+
+// ServeHTTP writes the alerts raised as JSON.
+func (d *Detector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(d.Active())
+}
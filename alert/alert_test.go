@@ -0,0 +1,108 @@
+package alert
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"vrmix/events"
+)
+
+// newDetector returns a detector publishing to a subscribed bus, with a controllable clock
+func newDetector(t *testing.T) (*Detector, *events.Subscription, *time.Time) {
+	t.Helper()
+
+	now := time.Unix(0, 0)
+	bus := &events.Bus{}
+	subscription := bus.Subscribe(events.Options{Types: []events.Type{events.AlertRaised, events.AlertResolved}})
+	t.Cleanup(subscription.Close)
+
+	return &Detector{Bus: bus, now: func() time.Time { return now }}, subscription, &now
+}
+
+// nextEvent returns the next event of the subscription, failing when none was published
+func nextEvent(t *testing.T, subscription *events.Subscription) events.Event {
+	t.Helper()
+
+	select {
+	case event := <-subscription.Events():
+		return event
+	case <-time.After(time.Second):
+		t.Fatal("expected an event")
+		return events.Event{}
+	}
+}
+
+func TestWindowUnderrun(t *testing.T) {
+	d, subscription, _ := newDetector(t)
+
+	d.ReportWindow("main", 12*time.Second, 4*time.Second)
+	d.ReportWindow("main", 6*time.Second, 4*time.Second)
+
+	event := nextEvent(t, subscription)
+	if event.Type != events.AlertRaised || event.Channel != "main" || event.Data["severity"] != string(Warning) {
+		t.Errorf("expected a warning on main, got %v", event)
+	}
+
+	d.ReportWindow("main", 5*time.Second, 4*time.Second)
+	d.ReportWindow("main", 2*time.Second, 4*time.Second)
+	if event := nextEvent(t, subscription); event.Data["severity"] != string(Critical) {
+		t.Errorf("expected the alert to escalate, got %v", event)
+	}
+
+	if active := d.Active(); len(active) != 1 || active[0].Condition != WindowUnderrun || active[0].Severity != Critical {
+		t.Errorf("expected a critical underrun, got %v", active)
+	}
+
+	d.ReportWindow("main", 12*time.Second, 4*time.Second)
+	if event := nextEvent(t, subscription); event.Type != events.AlertResolved {
+		t.Errorf("expected the alert to be resolved, got %v", event)
+	}
+
+	if active := d.Active(); len(active) != 0 {
+		t.Errorf("expected no alert, got %v", active)
+	}
+}
+
+func TestOriginFailures(t *testing.T) {
+	d, subscription, now := newDetector(t)
+	failure := errors.New("origin down")
+
+	for range 3 {
+		d.ReportFetch("main", failure)
+	}
+
+	if active := d.Active(); len(active) != 0 {
+		t.Errorf("expected no alert before the minimum fetches, got %v", active)
+	}
+
+	d.ReportFetch("main", nil)
+	d.ReportFetch("main", nil)
+	if event := nextEvent(t, subscription); event.Data["condition"] != string(OriginFailures) || event.Data["severity"] != string(Critical) {
+		t.Errorf("expected a critical origin failures alert, got %v", event)
+	}
+
+	*now = now.Add(2 * time.Minute)
+	for range 5 {
+		d.ReportFetch("main", nil)
+	}
+
+	if event := nextEvent(t, subscription); event.Type != events.AlertResolved {
+		t.Errorf("expected the failures to expire, got %v", event)
+	}
+}
+
+func TestConversionBacklog(t *testing.T) {
+	d, subscription, _ := newDetector(t)
+
+	d.ReportBacklog("main", 15*time.Second)
+	if event := nextEvent(t, subscription); event.Data["condition"] != string(ConversionBacklog) || event.Data["severity"] != string(Warning) {
+		t.Errorf("expected a backlog warning, got %v", event)
+	}
+
+	d.Forget("main")
+	if event := nextEvent(t, subscription); event.Type != events.AlertResolved {
+		t.Errorf("expected the alert to be resolved, got %v", event)
+	}
+
+}
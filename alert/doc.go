@@ -0,0 +1,2 @@
+// Package alert detects the conditions affecting the playback of the channels, raising warning and critical alerts before the viewers notice.
+package alert
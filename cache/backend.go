@@ -0,0 +1,49 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// Backend stores the segments by key, like a Cache on disk or an object storage shared between instances.
+type Backend interface {
+	// Get reads the segment, returning ErrNotFound if it is not cached or expired.
+	Get(ctx context.Context, key string) ([]byte, error)
+
+	// Put stores the segment, replacing the one with the same key, expiring it after the TTL or the default one of the backend if zero.
+	Put(ctx context.Context, key string, data []byte, ttl time.Duration) error
+
+	// Remove removes the segment, succeeding when it is not cached.
+	Remove(ctx context.Context, key string) error
+}
+
+// Locator is a Backend the players can download the segments from directly, so the cache hits do not go through VRMix.
+type Locator interface {
+	Backend
+
+	// URL returns a URL the segment can be downloaded from until the duration elapses, returning ErrNotFound if it is not cached or expired.
+	URL(ctx context.Context, key string, expires time.Duration) (string, error)
+}
+
+// disk is the Backend of a Cache.
+type disk struct {
+	cache *Cache
+}
+
+// Backend returns the cache as a Backend, the contexts being ignored as the disk is not interrupted.
+func (c *Cache) Backend() Backend {
+	return disk{c}
+}
+
+func (d disk) Get(ctx context.Context, key string) ([]byte, error) {
+	return d.cache.Get(key)
+}
+
+func (d disk) Put(ctx context.Context, key string, data []byte, ttl time.Duration) error {
+	return d.cache.Put(key, data, ttl)
+}
+
+func (d disk) Remove(ctx context.Context, key string) error {
+	d.cache.Remove(key)
+	return nil
+}
@@ -0,0 +1,33 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestDiskBackend(t *testing.T) {
+	c, _ := open(t, Config{})
+	backend := c.Backend()
+	ctx := context.Background()
+
+	if err := backend.Put(ctx, "channel/1.ts", []byte("segment"), 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if data, err := backend.Get(ctx, "channel/1.ts"); err != nil || string(data) != "segment" {
+		t.Errorf("expected the segment, got %q and %v", data, err)
+	}
+
+	if _, ok := backend.(Locator); ok {
+		t.Errorf("expected the disk not to be a locator")
+	}
+
+	if err := backend.Remove(ctx, "channel/1.ts"); err != nil || backend.Remove(ctx, "channel/1.ts") != nil {
+		t.Errorf("expected the segment to be removed, got %v", err)
+	}
+
+	if _, err := backend.Get(ctx, "channel/1.ts"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected the segment to be missing, got %v", err)
+	}
+}
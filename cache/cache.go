@@ -0,0 +1,349 @@
+package cache
+
+import (
+	"container/list"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"vrmix/logging"
+)
+
+var (
+	// ErrNotFound indicates that the segment is not cached or expired.
+	ErrNotFound = errors.New("segment not cached")
+
+	// ErrInvalidKey indicates that the key cannot name a cached segment.
+	ErrInvalidKey = errors.New("invalid cache key")
+)
+
+// extension suffixes the files of the segments, the other files of the directory, like the ones being written, being removed when the cache is opened.
+const extension = ".seg"
+
+// Reason represents why a segment left the cache.
+type Reason string
+
+const (
+	// ReasonSize is a segment evicted as the least recently used one while the cache is above its size.
+	ReasonSize Reason = "size"
+
+	// ReasonExpired is a segment evicted once its TTL elapsed.
+	ReasonExpired Reason = "expired"
+
+	// ReasonRemoved is a segment removed explicitly.
+	ReasonRemoved Reason = "removed"
+)
+
+// Eviction represents a segment which left the cache.
+type Eviction struct {
+	Key    string // Key of the segment
+	Size   int64  // Size of the segment in bytes
+	Reason Reason // Why the segment left the cache
+}
+
+// entry is the index entry of a cached segment.
+type entry struct {
+	key      string
+	size     int64
+	expires  time.Time // zero for a segment kept until evicted
+	accessed time.Time // time of the last use
+}
+
+// Config represents the configuration of a Cache.
+type Config struct {
+	Dir      string        // Directory storing the segments, created if missing
+	MaxBytes int64         // Size above which the least recently used segments are evicted, zero for no limit
+	TTL      time.Duration // Time a segment is kept when put without its own TTL, zero to keep it until evicted
+
+	// Logger receives the files which could not be removed, defaults to slog.Default.
+	Logger *slog.Logger
+}
+
+// Cache stores segments by key as files of a directory, indexing them in memory to evict them by recency and age.
+type Cache struct {
+	config      Config
+	mutex       sync.Mutex
+	entries     map[string]*list.Element
+	recency     *list.List // entries from the most to the least recently used
+	size        int64
+	subscribers map[int]func(Eviction)
+	nextID      int
+	journal     *os.File // journal the changes of the index are appended to, nil once closed
+	records     int      // records in the journal, compacted once mostly outdated
+	now         func() time.Time
+}
+
+// Open creates the directory of the cache and indexes the segments already stored in it from the journal of the index, so their TTLs and recency survive a restart, the ones missing from the journal being indexed as if they were put and last used when last modified.
+func Open(config Config) (*Cache, error) {
+	if err := os.MkdirAll(config.Dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	records, err := readJournal(config.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := os.ReadDir(config.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Cache{config: config, entries: map[string]*list.Element{}, recency: list.New(), subscribers: map[int]func(Eviction){}, now: time.Now}
+
+	var segments []*entry
+	for _, file := range files {
+		if file.IsDir() || file.Name() == journalName {
+			continue
+		}
+
+		name, ok := strings.CutSuffix(file.Name(), extension)
+		key, err := url.PathUnescape(name)
+		if !ok || err != nil || key == "" {
+			c.removeFile(filepath.Join(config.Dir, file.Name()))
+			continue
+		}
+
+		info, err := file.Info()
+		if err != nil {
+			continue
+		}
+
+		// A record of another size is outdated, like when a crash happened between replacing the file and journaling it.
+		e := &entry{key: key, size: info.Size(), accessed: info.ModTime()}
+		if r, ok := records[key]; ok && r.Size == e.size {
+			e.expires, e.accessed = fromUnixNano(r.Expires), fromUnixNano(r.Accessed)
+		} else if config.TTL > 0 {
+			e.expires = info.ModTime().Add(config.TTL)
+		}
+
+		segments = append(segments, e)
+	}
+
+	slices.SortFunc(segments, func(a *entry, b *entry) int {
+		return a.accessed.Compare(b.accessed)
+	})
+
+	for _, e := range segments {
+		c.entries[e.key] = c.recency.PushFront(e)
+		c.size += e.size
+	}
+
+	// Nobody subscribed yet, so the segments evicted while opening are not notified.
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.evict()
+	if err := c.rewrite(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// path returns the path of the file of the segment, escaping the key so it is a single path element.
+func (c *Cache) path(key string) string {
+	return filepath.Join(c.config.Dir, url.PathEscape(key)+extension)
+}
+
+// Get reads the segment and marks it as the most recently used one, returning ErrNotFound if it is not cached or expired.
+func (c *Cache) Get(key string) ([]byte, error) {
+	c.mutex.Lock()
+	element := c.entries[key]
+	if element == nil {
+		c.mutex.Unlock()
+		return nil, ErrNotFound
+	}
+
+	if e := element.Value.(*entry); c.expired(e) {
+		evicted := []Eviction{c.remove(element, ReasonExpired)}
+		c.mutex.Unlock()
+		c.notify(evicted)
+		return nil, ErrNotFound
+	}
+
+	e := element.Value.(*entry)
+	e.accessed = c.now()
+	c.recency.MoveToFront(element)
+	c.write(record{Op: opGet, Key: key, Accessed: unixNano(e.accessed)})
+	c.mutex.Unlock()
+
+	// The segment may be evicted between the lookup and the read.
+	data, err := os.ReadFile(c.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+
+	return data, err
+}
+
+// Put stores the segment, replacing the one with the same key, expiring it after the TTL or Config.TTL if zero, then evicts the segments above MaxBytes.
+func (c *Cache) Put(key string, data []byte, ttl time.Duration) error {
+	if key == "" {
+		return ErrInvalidKey
+	}
+
+	// The segment is written aside then renamed, so a Get never reads it partially written.
+	file, err := os.CreateTemp(c.config.Dir, ".put-*")
+	if err != nil {
+		return err
+	}
+
+	_, err = file.Write(data)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		c.removeFile(file.Name())
+		return err
+	}
+
+	if ttl <= 0 {
+		ttl = c.config.TTL
+	}
+
+	e := &entry{key: key, size: int64(len(data)), accessed: c.now()}
+	if ttl > 0 {
+		e.expires = e.accessed.Add(ttl)
+	}
+
+	c.mutex.Lock()
+	if err := os.Rename(file.Name(), c.path(key)); err != nil {
+		c.mutex.Unlock()
+		c.removeFile(file.Name())
+		return err
+	}
+
+	if element := c.entries[key]; element != nil {
+		c.size -= element.Value.(*entry).size
+		c.recency.Remove(element)
+	}
+
+	c.entries[key] = c.recency.PushFront(e)
+	c.size += e.size
+	c.write(record{Op: opPut, Key: key, Size: e.size, Expires: unixNano(e.expires), Accessed: unixNano(e.accessed)})
+	evicted := c.evict()
+	c.mutex.Unlock()
+
+	c.notify(evicted)
+	return nil
+}
+
+// Remove removes the segment, returning false if it was not cached.
+func (c *Cache) Remove(key string) bool {
+	c.mutex.Lock()
+	element := c.entries[key]
+	if element == nil {
+		c.mutex.Unlock()
+		return false
+	}
+
+	evicted := []Eviction{c.remove(element, ReasonRemoved)}
+	c.mutex.Unlock()
+
+	c.notify(evicted)
+	return true
+}
+
+// Purge removes the expired segments, meant to be called periodically so the segments no longer requested do not wait for an eviction by size.
+func (c *Cache) Purge() {
+	c.mutex.Lock()
+	var evicted []Eviction
+	for element := c.recency.Back(); element != nil; {
+		previous := element.Prev()
+		if c.expired(element.Value.(*entry)) {
+			evicted = append(evicted, c.remove(element, ReasonExpired))
+		}
+
+		element = previous
+	}
+	c.mutex.Unlock()
+
+	c.notify(evicted)
+}
+
+// Stats returns the number of cached segments and their size in bytes.
+func (c *Cache) Stats() (int, int64) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return len(c.entries), c.size
+}
+
+// Subscribe calls the function with each segment leaving the cache, so the manifests referencing it can be invalidated, returning the function unsubscribing it.
+func (c *Cache) Subscribe(subscriber func(Eviction)) func() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	id := c.nextID
+	c.nextID++
+	c.subscribers[id] = subscriber
+
+	return func() {
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+
+		delete(c.subscribers, id)
+	}
+}
+
+// expired reports whether the TTL of the entry elapsed, called with the mutex held.
+func (c *Cache) expired(e *entry) bool {
+	return !e.expires.IsZero() && !c.now().Before(e.expires)
+}
+
+// evict removes the least recently used segments while the cache is above MaxBytes, called with the mutex held.
+func (c *Cache) evict() []Eviction {
+	var evicted []Eviction
+	for c.config.MaxBytes > 0 && c.size > c.config.MaxBytes {
+		evicted = append(evicted, c.remove(c.recency.Back(), ReasonSize))
+	}
+
+	return evicted
+}
+
+// remove drops the segment from the index and its file, called with the mutex held.
+func (c *Cache) remove(element *list.Element, reason Reason) Eviction {
+	e := c.recency.Remove(element).(*entry)
+	delete(c.entries, e.key)
+	c.size -= e.size
+	c.removeFile(c.path(e.key))
+	c.write(record{Op: opRemove, Key: e.key})
+
+	return Eviction{Key: e.key, Size: e.size, Reason: reason}
+}
+
+// removeFile removes the file, logging the failure as the file only wastes space.
+func (c *Cache) removeFile(path string) {
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		logging.Or(c.config.Logger).Warn("failed to remove cached segment", slog.String("path", path), logging.Err(err))
+	}
+}
+
+// notify calls the subscribers with the evicted segments, called without the mutex held so they can use the cache.
+func (c *Cache) notify(evicted []Eviction) {
+	if len(evicted) == 0 {
+		return
+	}
+
+	c.mutex.Lock()
+	subscribers := make([]func(Eviction), 0, len(c.subscribers))
+	for _, subscriber := range c.subscribers {
+		subscribers = append(subscribers, subscriber)
+	}
+	c.mutex.Unlock()
+
+	for _, eviction := range evicted {
+		for _, subscriber := range subscribers {
+			subscriber(eviction)
+		}
+	}
+}
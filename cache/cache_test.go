@@ -0,0 +1,174 @@
+package cache
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// open opens a cache in a temporary directory with a controlled clock
+func open(t *testing.T, config Config) (*Cache, *time.Time) {
+	t.Helper()
+
+	if config.Dir == "" {
+		config.Dir = t.TempDir()
+	}
+
+	c, err := Open(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { c.Close() })
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	c.now = func() time.Time { return now }
+	return c, &now
+}
+
+// subscribe records the evictions of the cache
+func subscribe(c *Cache) *[]Eviction {
+	var evicted []Eviction
+	c.Subscribe(func(eviction Eviction) {
+		evicted = append(evicted, eviction)
+	})
+
+	return &evicted
+}
+
+func TestCacheGetPut(t *testing.T) {
+	c, _ := open(t, Config{})
+
+	if _, err := c.Get("channel/1.ts"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected a missing segment, got %v", err)
+	}
+
+	if err := c.Put("channel/1.ts", []byte("segment"), 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if data, err := c.Get("channel/1.ts"); err != nil || string(data) != "segment" {
+		t.Errorf("expected the segment, got %q and %v", data, err)
+	}
+
+	c.Put("channel/1.ts", []byte("replaced"), 0)
+	if data, _ := c.Get("channel/1.ts"); string(data) != "replaced" {
+		t.Errorf("expected the replaced segment, got %q", data)
+	}
+
+	if count, size := c.Stats(); count != 1 || size != 8 {
+		t.Errorf("expected 1 segment of 8 bytes, got %d and %d", count, size)
+	}
+
+	if err := c.Put("", nil, 0); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("expected an invalid key, got %v", err)
+	}
+
+	if !c.Remove("channel/1.ts") || c.Remove("channel/1.ts") {
+		t.Errorf("expected the segment to be removed once")
+	}
+
+	if files, _ := os.ReadDir(c.config.Dir); len(files) != 1 || files[0].Name() != journalName {
+		t.Errorf("expected the files to be removed but the journal, got %d", len(files))
+	}
+}
+
+func TestCacheEviction(t *testing.T) {
+	c, _ := open(t, Config{MaxBytes: 10})
+	evicted := subscribe(c)
+
+	c.Put("a", []byte("aaaa"), 0)
+	c.Put("b", []byte("bbbb"), 0)
+	c.Get("a")
+	c.Put("c", []byte("cccc"), 0)
+
+	if _, err := c.Get("b"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected the least recently used segment to be evicted, got %v", err)
+	}
+
+	if _, err := c.Get("a"); err != nil {
+		t.Errorf("expected the recently used segment to be kept, got %v", err)
+	}
+
+	if !slices.Equal(*evicted, []Eviction{{Key: "b", Size: 4, Reason: ReasonSize}}) {
+		t.Errorf("expected the eviction of b, got %v", *evicted)
+	}
+
+	if _, size := c.Stats(); size != 8 {
+		t.Errorf("expected 8 bytes, got %d", size)
+	}
+}
+
+func TestCacheTTL(t *testing.T) {
+	c, now := open(t, Config{TTL: time.Minute})
+	evicted := subscribe(c)
+
+	c.Put("default", []byte("a"), 0)
+	c.Put("short", []byte("b"), time.Second)
+	c.Put("long", []byte("c"), time.Hour)
+
+	*now = now.Add(2 * time.Second)
+	if _, err := c.Get("short"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected the segment to expire after its TTL, got %v", err)
+	}
+
+	*now = now.Add(time.Minute)
+	c.Purge()
+
+	if _, err := c.Get("long"); err != nil {
+		t.Errorf("expected the segment with a longer TTL to be kept, got %v", err)
+	}
+
+	expected := []Eviction{{Key: "short", Size: 1, Reason: ReasonExpired}, {Key: "default", Size: 1, Reason: ReasonExpired}}
+	if !slices.Equal(*evicted, expected) {
+		t.Errorf("expected %v, got %v", expected, *evicted)
+	}
+}
+
+func TestCacheSubscribe(t *testing.T) {
+	c, _ := open(t, Config{})
+
+	var evicted []Eviction
+	unsubscribe := c.Subscribe(func(eviction Eviction) {
+		evicted = append(evicted, eviction)
+
+		// The subscribers run without the lock, so they can use the cache.
+		c.Stats()
+	})
+
+	c.Put("a", []byte("a"), 0)
+	c.Remove("a")
+	unsubscribe()
+	c.Put("b", []byte("b"), 0)
+	c.Remove("b")
+
+	if !slices.Equal(evicted, []Eviction{{Key: "a", Size: 1, Reason: ReasonRemoved}}) {
+		t.Errorf("expected only the eviction before unsubscribing, got %v", evicted)
+	}
+}
+
+func TestCacheOpen(t *testing.T) {
+	dir := t.TempDir()
+	c, now := open(t, Config{Dir: dir})
+
+	c.Put("channel/1.ts", []byte("first"), 0)
+	*now = now.Add(time.Minute)
+	c.Put("channel/2.ts", []byte("second"), 0)
+	os.WriteFile(filepath.Join(dir, ".put-123"), []byte("partial"), 0o644)
+
+	c, _ = open(t, Config{Dir: dir, MaxBytes: 8})
+	if data, err := c.Get("channel/2.ts"); err != nil || string(data) != "second" {
+		t.Errorf("expected the stored segment to be indexed, got %q and %v", data, err)
+	}
+
+	if _, err := c.Get("channel/1.ts"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected the oldest segment to be evicted above the size, got %v", err)
+	}
+
+	if files, _ := os.ReadDir(dir); len(files) != 2 {
+		t.Errorf("expected the partial and evicted files to be removed, got %d files", len(files))
+	}
+}
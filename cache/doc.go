@@ -0,0 +1,2 @@
+// Package cache stores the downloaded and converted segments on disk, evicting the least recently used ones above a size and the expired ones.
+package cache
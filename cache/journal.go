@@ -0,0 +1,181 @@
+package cache
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"vrmix/logging"
+)
+
+// journalName is the name of the journal of the index, kept in the directory of the cache.
+const journalName = "journal"
+
+// Operations of the records of the journal.
+const (
+	opPut    = "put"
+	opGet    = "get"
+	opRemove = "remove"
+)
+
+// record is a line of the journal, a JSON object describing a change of the index.
+type record struct {
+	Op       string `json:"op"`
+	Key      string `json:"key"`
+	Size     int64  `json:"size,omitempty"`
+	Expires  int64  `json:"expires,omitempty"`  // Unix nanoseconds, zero for a segment kept until evicted
+	Accessed int64  `json:"accessed,omitempty"` // Unix nanoseconds of the last use
+}
+
+// unixNano returns the time in Unix nanoseconds, zero for the zero time.
+func unixNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+
+	return t.UnixNano()
+}
+
+// fromUnixNano returns the time of Unix nanoseconds, the zero time for zero.
+func fromUnixNano(nanoseconds int64) time.Time {
+	if nanoseconds == 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(0, nanoseconds)
+}
+
+// readJournal replays the journal of the directory into the last record of each segment still cached, stopping at the first malformed line, like the one a crash left partially written.
+func readJournal(dir string) (map[string]record, error) {
+	file, err := os.Open(filepath.Join(dir, journalName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return map[string]record{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	records := map[string]record{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var r record
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			return records, nil
+		}
+
+		switch r.Op {
+		case opPut:
+			records[r.Key] = r
+		case opGet:
+			if existing, ok := records[r.Key]; ok {
+				existing.Accessed = r.Accessed
+				records[r.Key] = existing
+			}
+		case opRemove:
+			delete(records, r.Key)
+		}
+	}
+
+	// A line too long for the scanner is malformed too, the segments after it being indexed from their files.
+	return records, nil
+}
+
+// write appends the record to the journal, compacting it once mostly made of outdated records, called with the mutex held.
+func (c *Cache) write(r record) {
+	if c.journal == nil {
+		return
+	}
+
+	data, err := json.Marshal(r)
+	if err == nil {
+		_, err = c.journal.Write(append(data, '\n'))
+	}
+
+	if err != nil {
+		logging.Or(c.config.Logger).Warn("failed to write cache journal", logging.Err(err))
+		return
+	}
+
+	c.records++
+	if c.records > 4*len(c.entries)+1024 {
+		c.compact()
+	}
+}
+
+// compact rewrites the journal with a record for each cached segment, from the least to the most recently used, called with the mutex held.
+func (c *Cache) compact() {
+	if err := c.rewrite(); err != nil {
+		logging.Or(c.config.Logger).Warn("failed to compact cache journal", slog.String("dir", c.config.Dir), logging.Err(err))
+	}
+}
+
+// rewrite writes the records of the cached segments aside then renames them over the journal, so a crash leaves either journal whole.
+func (c *Cache) rewrite() error {
+	file, err := os.CreateTemp(c.config.Dir, ".journal-*")
+	if err != nil {
+		return err
+	}
+
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	for element := c.recency.Back(); element != nil && err == nil; element = element.Prev() {
+		e := element.Value.(*entry)
+		err = encoder.Encode(record{Op: opPut, Key: e.key, Size: e.size, Expires: unixNano(e.expires), Accessed: unixNano(e.accessed)})
+	}
+
+	if err == nil {
+		err = writer.Flush()
+	}
+
+	if err == nil {
+		err = file.Sync()
+	}
+
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = os.Rename(file.Name(), filepath.Join(c.config.Dir, journalName))
+	}
+
+	if err != nil {
+		c.removeFile(file.Name())
+		return err
+	}
+
+	journal, err := os.OpenFile(filepath.Join(c.config.Dir, journalName), os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+
+	if c.journal != nil {
+		c.journal.Close()
+	}
+
+	c.journal, c.records = journal, len(c.entries)
+	return nil
+}
+
+// Close compacts and closes the journal of the index, the cache no longer persisting the changes of its index.
+func (c *Cache) Close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.journal == nil {
+		return nil
+	}
+
+	err := c.rewrite()
+	if closeErr := c.journal.Close(); err == nil {
+		err = closeErr
+	}
+
+	c.journal = nil
+	return err
+}
@@ -0,0 +1,124 @@
+package cache
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// journalLines returns the number of records in the journal of the directory
+func journalLines(t *testing.T, dir string) int {
+	t.Helper()
+
+	file, err := os.Open(filepath.Join(dir, journalName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	lines := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lines++
+	}
+
+	return lines
+}
+
+func TestJournalRestart(t *testing.T) {
+	dir := t.TempDir()
+	c, now := open(t, Config{Dir: dir})
+
+	c.Put("channel/1.ts", []byte("first"), time.Hour)
+	*now = now.Add(time.Minute)
+	c.Put("channel/2.ts", []byte("second"), 0)
+	*now = now.Add(time.Minute)
+	c.Get("channel/1.ts")
+
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	c, _ = open(t, Config{Dir: dir, TTL: time.Minute, MaxBytes: 8})
+	expires := time.Date(2024, 1, 1, 1, 0, 0, 0, time.UTC)
+	if element, ok := c.entries["channel/1.ts"]; !ok || !element.Value.(*entry).expires.Equal(expires) {
+		t.Errorf("expected the TTL of the segment to survive the restart")
+	}
+
+	if element, ok := c.entries["channel/2.ts"]; ok && !element.Value.(*entry).expires.IsZero() {
+		t.Errorf("expected the segment kept until evicted not to expire")
+	}
+
+	// The first segment was used last, so the second one is evicted above the size.
+	if _, err := c.Get("channel/2.ts"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected the least recently used segment to be evicted, got %v", err)
+	}
+}
+
+func TestJournalRecovery(t *testing.T) {
+	dir := t.TempDir()
+	c, now := open(t, Config{Dir: dir})
+
+	c.Put("channel/1.ts", []byte("first"), time.Hour)
+	c.Put("channel/2.ts", []byte("second"), time.Hour)
+	c.Put("channel/3.ts", []byte("third"), time.Hour)
+	*now = now.Add(time.Minute)
+	c.Put("channel/2.ts", []byte("replaced"), time.Hour)
+
+	// A crash leaves the last record partially written and a segment deleted by hand.
+	c.journal.WriteString(`{"op":"remove","key":"chan`)
+	os.Remove(c.path("channel/3.ts"))
+
+	old := time.Now().Add(-time.Hour)
+	os.WriteFile(c.path("channel/2.ts"), []byte("rewritten"), 0o644)
+	os.Chtimes(c.path("channel/2.ts"), old, old)
+
+	c, _ = open(t, Config{Dir: dir, TTL: time.Minute})
+	if count, size := c.Stats(); count != 2 || size != 14 {
+		t.Errorf("expected 2 segments of 14 bytes, got %d and %d", count, size)
+	}
+
+	// The size of the second segment no longer matches its record, so it is indexed from its file.
+	if expires := c.entries["channel/2.ts"].Value.(*entry).expires; !expires.Equal(old.Add(time.Minute)) {
+		t.Errorf("expected the segment of another size to expire from its modification, got %v", expires)
+	}
+
+	if expires := c.entries["channel/1.ts"].Value.(*entry).expires; !expires.Equal(time.Date(2024, 1, 1, 1, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected the journaled TTL before the partial record, got %v", expires)
+	}
+
+	if lines := journalLines(t, dir); lines != 2 {
+		t.Errorf("expected the journal to be rewritten with 2 records, got %d", lines)
+	}
+}
+
+func TestJournalCompaction(t *testing.T) {
+	dir := t.TempDir()
+	c, _ := open(t, Config{Dir: dir})
+
+	c.Put("channel/1.ts", []byte("first"), 0)
+	for range 1100 {
+		c.Get("channel/1.ts")
+	}
+
+	if lines := journalLines(t, dir); lines > 1100 {
+		t.Errorf("expected the journal to be compacted, got %d records", lines)
+	}
+
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if lines := journalLines(t, dir); lines != 1 {
+		t.Errorf("expected the closed journal to hold a record per segment, got %d", lines)
+	}
+
+	// The changes after closing are no longer journaled.
+	c.Put("channel/2.ts", []byte("second"), 0)
+	if lines := journalLines(t, dir); lines != 1 || c.Close() != nil {
+		t.Errorf("expected the changes after closing not to be journaled, got %d records", lines)
+	}
+}
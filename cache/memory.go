@@ -0,0 +1,108 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryConfig represents the configuration of a Memory backend.
+type MemoryConfig struct {
+	MaxBytes int64         // Size above which the least recently used segments are evicted, zero for no limit
+	TTL      time.Duration // Time a segment is kept when put without its own TTL, zero to keep it until evicted
+}
+
+// memoryEntry is a segment held by a Memory backend.
+type memoryEntry struct {
+	key     string
+	data    []byte
+	expires time.Time // zero for a segment kept until evicted
+}
+
+// Memory is a Backend holding the segments in memory, evicting the least recently used ones, for single instances without a disk to spare.
+type Memory struct {
+	config  MemoryConfig
+	mutex   sync.Mutex
+	entries map[string]*list.Element
+	recency *list.List // entries from the most to the least recently used
+	size    int64
+	now     func() time.Time
+}
+
+// NewMemory creates an empty Memory backend.
+func NewMemory(config MemoryConfig) *Memory {
+	return &Memory{config: config, entries: map[string]*list.Element{}, recency: list.New(), now: time.Now}
+}
+
+// Get returns the segment and marks it as the most recently used one, returning ErrNotFound if it is not cached or expired.
+func (m *Memory) Get(ctx context.Context, key string) ([]byte, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	element := m.entries[key]
+	if element == nil {
+		return nil, ErrNotFound
+	}
+
+	e := element.Value.(*memoryEntry)
+	if !e.expires.IsZero() && !m.now().Before(e.expires) {
+		m.remove(element)
+		return nil, ErrNotFound
+	}
+
+	m.recency.MoveToFront(element)
+	return e.data, nil
+}
+
+// Put stores the segment, replacing the one with the same key, expiring it after the TTL or MemoryConfig.TTL if zero, then evicts the segments above MaxBytes.
+func (m *Memory) Put(ctx context.Context, key string, data []byte, ttl time.Duration) error {
+	if key == "" {
+		return ErrInvalidKey
+	}
+
+	if ttl <= 0 {
+		ttl = m.config.TTL
+	}
+
+	e := &memoryEntry{key: key, data: data}
+	if ttl > 0 {
+		e.expires = m.now().Add(ttl)
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if element := m.entries[key]; element != nil {
+		m.remove(element)
+	}
+
+	m.entries[key] = m.recency.PushFront(e)
+	m.size += int64(len(data))
+
+	// The segment just put is kept even when larger than MaxBytes, as it is about to be served.
+	for m.config.MaxBytes > 0 && m.size > m.config.MaxBytes && m.recency.Len() > 1 {
+		m.remove(m.recency.Back())
+	}
+
+	return nil
+}
+
+// Remove removes the segment, succeeding when it is not cached.
+func (m *Memory) Remove(ctx context.Context, key string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if element := m.entries[key]; element != nil {
+		m.remove(element)
+	}
+
+	return nil
+}
+
+// remove removes the entry of the element, called with the mutex held.
+func (m *Memory) remove(element *list.Element) {
+	e := m.recency.Remove(element).(*memoryEntry)
+	delete(m.entries, e.key)
+	m.size -= int64(len(e.data))
+}
@@ -0,0 +1,40 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMemory(t *testing.T) {
+	m := NewMemory(MemoryConfig{MaxBytes: 10, TTL: time.Minute})
+	now := time.Unix(1704067200, 0)
+	m.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	m.Put(ctx, "a", []byte("aaaa"), 0)
+	m.Put(ctx, "b", []byte("bbbb"), time.Hour)
+	if data, err := m.Get(ctx, "a"); err != nil || string(data) != "aaaa" {
+		t.Errorf("expected the segment, got %q and %v", data, err)
+	}
+
+	// The least recently used segment is evicted above MaxBytes.
+	m.Put(ctx, "c", []byte("cccc"), 0)
+	if _, err := m.Get(ctx, "b"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected the least recently used segment to be evicted, got %v", err)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, err := m.Get(ctx, "a"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected the segment to expire after the default TTL, got %v", err)
+	}
+
+	if err := m.Remove(ctx, "c"); err != nil || m.Remove(ctx, "c") != nil || m.size != 0 {
+		t.Errorf("expected every segment to be removed, got %v and %d bytes", err, m.size)
+	}
+
+	if err := m.Put(ctx, "", nil, 0); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("expected an invalid key, got %v", err)
+	}
+}
@@ -0,0 +1,119 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"vrmix/logging"
+	"vrmix/s3"
+)
+
+// expiresMetadata is the metadata of the objects holding the Unix time their segment expires at.
+const expiresMetadata = "Expires"
+
+// S3Config represents the configuration of an S3 backend.
+type S3Config struct {
+	Client *s3.Client    // Client of the bucket shared by the instances
+	Prefix string        // Prefix of the keys of the objects, like "segments/"
+	TTL    time.Duration // Time a segment is kept when put without its own TTL, zero to keep it until removed, like by the lifecycle rules of the bucket
+
+	// ContentType returns the content type stored with the segment, like server.ContentType so the players following the presigned URLs receive it, nil for the default one of the storage.
+	ContentType func(key string) string
+
+	// Logger receives the expired objects which could not be deleted, defaults to slog.Default.
+	Logger *slog.Logger
+}
+
+// S3 is a Locator storing the segments in an S3-compatible object storage, sharing them between the instances and surviving the loss of their disks.
+type S3 struct {
+	config S3Config
+	now    func() time.Time
+}
+
+// NewS3 creates an S3 backend.
+func NewS3(config S3Config) *S3 {
+	return &S3{config: config, now: time.Now}
+}
+
+// Get reads the segment after checking it did not expire, returning ErrNotFound if it is not cached or expired.
+func (s *S3) Get(ctx context.Context, key string) ([]byte, error) {
+	if err := s.check(ctx, key); err != nil {
+		return nil, err
+	}
+
+	body, err := s.config.Client.GetObject(ctx, s.config.Prefix+key, "")
+	if errors.Is(err, s3.ErrNotFound) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	return io.ReadAll(body)
+}
+
+// Put stores the segment, replacing the one with the same key, expiring it after the TTL or S3Config.TTL if zero.
+func (s *S3) Put(ctx context.Context, key string, data []byte, ttl time.Duration) error {
+	if key == "" {
+		return ErrInvalidKey
+	}
+
+	if ttl <= 0 {
+		ttl = s.config.TTL
+	}
+
+	var metadata map[string]string
+	if ttl > 0 {
+		metadata = map[string]string{expiresMetadata: strconv.FormatInt(s.now().Add(ttl).Unix(), 10)}
+	}
+
+	contentType := ""
+	if s.config.ContentType != nil {
+		contentType = s.config.ContentType(key)
+	}
+
+	return s.config.Client.PutObject(ctx, s.config.Prefix+key, data, contentType, metadata)
+}
+
+// Remove removes the segment, succeeding when it is not cached.
+func (s *S3) Remove(ctx context.Context, key string) error {
+	return s.config.Client.DeleteObject(ctx, s.config.Prefix+key)
+}
+
+// URL returns a presigned URL of the segment after checking it did not expire, returning ErrNotFound if it is not cached or expired.
+func (s *S3) URL(ctx context.Context, key string, expires time.Duration) (string, error) {
+	if err := s.check(ctx, key); err != nil {
+		return "", err
+	}
+
+	return s.config.Client.PresignGet(s.config.Prefix+key, expires)
+}
+
+// check returns ErrNotFound if the object of the segment does not exist or expired, deleting the expired one.
+func (s *S3) check(ctx context.Context, key string) error {
+	if key == "" {
+		return ErrNotFound
+	}
+
+	object, err := s.config.Client.HeadObject(ctx, s.config.Prefix+key)
+	if errors.Is(err, s3.ErrNotFound) {
+		return ErrNotFound
+	} else if err != nil {
+		return err
+	}
+
+	expires, err := strconv.ParseInt(object.Metadata[expiresMetadata], 10, 64)
+	if err != nil || s.now().Before(time.Unix(expires, 0)) {
+		return nil
+	}
+
+	if err := s.Remove(ctx, key); err != nil {
+		logging.Or(s.config.Logger).Warn("failed to delete expired segment", slog.String("key", s.config.Prefix+key), logging.Err(err))
+	}
+
+	return ErrNotFound
+}
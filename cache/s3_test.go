@@ -0,0 +1,129 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"vrmix/s3"
+)
+
+// fakeStorage is an object storage keeping the objects and their headers in memory
+type fakeStorage struct {
+	mutex   sync.Mutex
+	bodies  map[string][]byte
+	headers map[string]http.Header
+}
+
+func (f *fakeStorage) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	body, ok := f.bodies[r.URL.Path]
+	switch r.Method {
+	case http.MethodPut:
+		f.bodies[r.URL.Path], _ = io.ReadAll(r.Body)
+		f.headers[r.URL.Path] = r.Header.Clone()
+	case http.MethodDelete:
+		delete(f.bodies, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodHead, http.MethodGet:
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.Header().Set("Content-Type", f.headers[r.URL.Path].Get("Content-Type"))
+		if expires := f.headers[r.URL.Path].Get("X-Amz-Meta-Expires"); expires != "" {
+			w.Header().Set("X-Amz-Meta-Expires", expires)
+		}
+
+		w.Write(body)
+	}
+}
+
+// openS3 creates an S3 backend of a fake storage with a fixed time, returned to be advanced
+func openS3(t *testing.T, config S3Config) (*S3, *fakeStorage, *time.Time) {
+	t.Helper()
+
+	storage := &fakeStorage{bodies: map[string][]byte{}, headers: map[string]http.Header{}}
+	server := httptest.NewServer(storage)
+	t.Cleanup(server.Close)
+
+	config.Client = &s3.Client{Endpoint: server.URL, Region: "us-east-1", Bucket: "media", AccessKey: "key", SecretKey: "secret", PathStyle: true, HTTP: server.Client()}
+	s := NewS3(config)
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+	return s, storage, &now
+}
+
+func TestS3(t *testing.T) {
+	s, storage, now := openS3(t, S3Config{Prefix: "segments/", TTL: time.Minute, ContentType: func(key string) string { return "video/mp2t" }})
+	ctx := context.Background()
+
+	if err := s.Put(ctx, "channel/1.ts", []byte("segment"), 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if header := storage.headers["/media/segments/channel/1.ts"]; header.Get("Content-Type") != "video/mp2t" || header.Get("X-Amz-Meta-Expires") != "1704067260" {
+		t.Errorf("expected the content type and expiration of the object, got %v", header)
+	}
+
+	if data, err := s.Get(ctx, "channel/1.ts"); err != nil || string(data) != "segment" {
+		t.Errorf("expected the segment, got %q and %v", data, err)
+	}
+
+	location, err := s.URL(ctx, "channel/1.ts", time.Hour)
+	if err != nil || !strings.Contains(location, "/media/segments/channel/1.ts?") || !strings.Contains(location, "X-Amz-Expires=3600") {
+		t.Errorf("expected a presigned URL of the object, got %s and %v", location, err)
+	}
+
+	if _, err := s.URL(ctx, "channel/2.ts", time.Hour); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected a missing segment, got %v", err)
+	}
+
+	if err := s.Put(ctx, "", nil, 0); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("expected an invalid key, got %v", err)
+	}
+
+	*now = now.Add(time.Minute)
+	if _, err := s.Get(ctx, "channel/1.ts"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected the segment to expire, got %v", err)
+	}
+
+	if _, ok := storage.bodies["/media/segments/channel/1.ts"]; ok {
+		t.Errorf("expected the expired object to be deleted")
+	}
+}
+
+func TestS3KeepUntilRemoved(t *testing.T) {
+	s, storage, now := openS3(t, S3Config{})
+	ctx := context.Background()
+
+	s.Put(ctx, "channel/1.ts", []byte("segment"), 0)
+	if header := storage.headers["/media/channel/1.ts"]; header.Get("X-Amz-Meta-Expires") != "" {
+		t.Errorf("expected no expiration without a TTL, got %v", header)
+	}
+
+	*now = now.Add(24 * time.Hour)
+	if _, err := s.Get(ctx, "channel/1.ts"); err != nil {
+		t.Errorf("expected the segment to be kept, got %v", err)
+	}
+
+	if err := s.Remove(ctx, "channel/1.ts"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Get(ctx, "channel/1.ts"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected the segment to be removed, got %v", err)
+	}
+}
@@ -0,0 +1,351 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	"vrmix/cache"
+	"vrmix/config"
+	"vrmix/control"
+	"vrmix/hls"
+	"vrmix/logging"
+	"vrmix/profile"
+	"vrmix/s3"
+	"vrmix/scheduler"
+	"vrmix/server"
+	"vrmix/signer"
+	"vrmix/source"
+	"vrmix/stream"
+)
+
+// loopAhead is the time left to play in a looping channel below which its queue is enqueued again.
+const loopAhead = 30 * time.Second
+
+// channels is the streams of the configured channels, with the sources and scheduler producing their segments.
+type channels struct {
+	registry   *source.Registry
+	sources    map[string]source.Source
+	closers    []io.Closer
+	cache      cache.Backend
+	scheduler  *scheduler.Scheduler
+	controller *stream.Controller
+	service    *stream.Service
+	loaders    map[string]*stream.Loader // loaders of the configured channels by ID, the channels created through the API loading from the registry
+}
+
+// newSource returns the source configured by the section.
+func newSource(cfg config.Source) (source.Source, error) {
+	switch cfg.Type {
+	case "http":
+		return &source.HTTPSource{}, nil
+	case "file":
+		files := &source.FileSource{Root: cfg.Root}
+		return files, files.Refresh()
+	case "s3":
+		endpoint, pathStyle := cfg.Endpoint, cfg.Endpoint != ""
+		if endpoint == "" {
+			endpoint = "https://s3." + cfg.Region + ".amazonaws.com"
+		}
+
+		client := &s3.Client{Endpoint: endpoint, Region: cfg.Region, Bucket: cfg.Bucket, AccessKey: cfg.AccessKey, SecretKey: cfg.SecretKey, PathStyle: pathStyle}
+		return &source.S3Source{Client: client, Prefix: cfg.Prefix}, nil
+	case "sftp":
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+
+		hostKeys, err := knownhosts.New(filepath.Join(home, ".ssh", "known_hosts"))
+		if err != nil {
+			return nil, err
+		}
+
+		return &source.SFTPSource{Addr: cfg.Addr, User: cfg.User, Password: cfg.Password, HostKeyCallback: hostKeys}, nil
+	case "ftp":
+		return &source.FTPSource{Addr: cfg.Addr, User: cfg.User, Password: cfg.Password}, nil
+	case "webdav":
+		return &source.WebDAVSource{BaseURL: cfg.URL, User: cfg.User, Password: cfg.Password, Token: cfg.Token}, nil
+	case "jellyfin":
+		return &source.JellyfinSource{BaseURL: cfg.URL, User: cfg.User, Password: cfg.Password, Token: cfg.Token}, nil
+	case "plex":
+		return &source.PlexSource{BaseURL: cfg.URL, Token: cfg.Token}, nil
+	case "crawler":
+		return &source.CrawlerSource{BaseURL: cfg.URL}, nil
+	default:
+		return nil, fmt.Errorf("%w: source type %q", config.ErrInvalidValue, cfg.Type)
+	}
+}
+
+// newChannels builds the sources, cache, scheduler and stream controller of the configuration, without starting the channels.
+func newChannels(ctx context.Context, cfg config.Config) (*channels, error) {
+	c := &channels{registry: source.NewRegistry(), sources: map[string]source.Source{}, loaders: map[string]*stream.Loader{}}
+	for _, sourceConfig := range cfg.Sources {
+		src, err := newSource(sourceConfig)
+		if err != nil {
+			c.close()
+			return nil, fmt.Errorf("source %s: %w", sourceConfig.Name, err)
+		}
+
+		if closer, ok := src.(io.Closer); ok {
+			c.closers = append(c.closers, closer)
+		}
+
+		// The catalog of a crawler is listed in the background, as a large site takes a while to crawl.
+		if crawler, ok := src.(*source.CrawlerSource); ok {
+			go func() {
+				if err := crawler.Refresh(ctx); err != nil && ctx.Err() == nil {
+					logging.Or(nil).Warn("failed to crawl source", slog.String("source", sourceConfig.Name), logging.Err(err))
+				}
+			}()
+		}
+
+		c.sources[sourceConfig.Name] = src
+		if err := c.registry.Register(sourceConfig.Name, src); err != nil {
+			c.close()
+			return nil, fmt.Errorf("source %s: %w", sourceConfig.Name, err)
+		}
+	}
+
+	switch cfg.Cache.Backend {
+	case "disk":
+		disk, err := cache.Open(cache.Config{Dir: cfg.Cache.Dir, MaxBytes: cfg.Cache.MaxBytes, TTL: cfg.Cache.TTL})
+		if err != nil {
+			c.close()
+			return nil, err
+		}
+
+		c.cache = disk.Backend()
+	case "s3":
+		s3Source, ok := c.sources[cfg.Cache.Source].(*source.S3Source)
+		if !ok {
+			c.close()
+			return nil, fmt.Errorf("%w: s3 source %q", config.ErrUndefined, cfg.Cache.Source)
+		}
+
+		c.cache = cache.NewS3(cache.S3Config{Client: s3Source.Client, Prefix: "segments/", TTL: cfg.Cache.TTL, ContentType: server.ContentType})
+	default:
+		c.cache = cache.NewMemory(cache.MemoryConfig{MaxBytes: cfg.Cache.MaxBytes, TTL: cfg.Cache.TTL})
+	}
+
+	c.scheduler = scheduler.New(scheduler.Config{})
+	loader := &stream.Loader{Source: c.registry}
+	c.controller = stream.New(stream.Config{Scheduler: c.scheduler, Cache: c.cache, TTL: cfg.Cache.TTL, Policy: scheduler.Policy{}, Produce: loader.Produce})
+	c.service = stream.NewService(stream.ServiceConfig{Controller: c.controller, Load: func(ctx context.Context, channel string, ref string) (stream.Media, error) {
+		if channelLoader := c.loaders[channel]; channelLoader != nil {
+			return channelLoader.Load(ctx, ref)
+		}
+
+		return loader.Load(ctx, ref)
+	}})
+	return c, nil
+}
+
+// selectRendition returns the Select of a stream.Loader playing the rendition with the highest bandwidth fitting the top rung of the profile, or the one selected from the layout of each item without a profile.
+func selectRendition(cfg config.Config, name string) (func(item source.Item, renditions []source.Rendition) int, error) {
+	var fixed *profile.Profile
+	if name != "" {
+		p, err := cfg.Profile(name)
+		if err != nil {
+			return nil, err
+		}
+
+		fixed = &p
+	}
+
+	return func(item source.Item, renditions []source.Rendition) int {
+		p := profile.ForLayout(item.Layout)
+		if fixed != nil {
+			p = *fixed
+		}
+
+		var maxWidth, maxHeight int
+		if rungs := p.Ladder(item.Layout); len(rungs) > 0 {
+			maxWidth, maxHeight = rungs[0].Resolution(item.Layout.Stereo)
+		}
+
+		best, lowest := -1, 0
+		for i, rendition := range renditions {
+			if rendition.Bandwidth < renditions[lowest].Bandwidth {
+				lowest = i
+			}
+
+			// The renditions of unknown resolution are assumed to fit.
+			if width, height, err := hls.ParseResolution(rendition.Resolution); err == nil && maxWidth > 0 && (width > maxWidth || height > maxHeight) {
+				continue
+			}
+
+			if best < 0 || rendition.Bandwidth > renditions[best].Bandwidth {
+				best = i
+			}
+		}
+
+		if best < 0 {
+			return lowest
+		}
+
+		return best
+	}, nil
+}
+
+// start creates the streams of the channels and enqueues their queues in the background, enqueuing them again before they end for the looping channels, until the context is done.
+func (c *channels) start(ctx context.Context, cfg config.Config) error {
+	for _, channel := range cfg.Channels {
+		selectRendition, err := selectRendition(cfg, channel.Profile)
+		if err != nil {
+			return fmt.Errorf("channel %s: %w", channel.ID, err)
+		}
+
+		// The queue is resolved by the sources of the channel in order, or by logical IDs naming the source without them.
+		loader := &stream.Loader{Source: c.registry, Select: selectRendition}
+		if len(channel.Sources) > 0 {
+			sources := make(source.Multi, 0, len(channel.Sources))
+			for _, name := range channel.Sources {
+				sources = append(sources, c.sources[name])
+			}
+
+			loader.Source = sources
+		}
+
+		c.loaders[channel.ID] = loader
+		if _, err := c.service.CreateChannel(ctx, channel.ID, channel.Name); err != nil {
+			return fmt.Errorf("channel %s: %w", channel.ID, err)
+		}
+
+		go c.play(ctx, channel)
+	}
+
+	return nil
+}
+
+// play enqueues the queue of the channel, then again each time less than loopAhead is left to play when it loops.
+func (c *channels) play(ctx context.Context, channel config.Channel) {
+	logger := logging.Or(nil).With(slog.String(logging.ChannelKey, channel.ID))
+	enqueue := func() int {
+		enqueued := 0
+		for _, ref := range channel.Queue {
+			if _, err := c.service.Enqueue(ctx, channel.ID, control.QueueItem{Source: ref}); err != nil {
+				if ctx.Err() == nil {
+					logger.Warn("failed to enqueue media", slog.String("media", ref), logging.Err(err))
+				}
+
+				continue
+			}
+
+			enqueued++
+		}
+
+		return enqueued
+	}
+
+	// A queue of which nothing plays is not looped, as it would be loaded again every second.
+	if enqueue() == 0 || !channel.Loop {
+		return
+	}
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			remaining, err := c.controller.Remaining(channel.ID)
+			if err != nil {
+				return
+			}
+
+			if remaining < loopAhead && enqueue() == 0 {
+				return
+			}
+		}
+	}
+}
+
+// close closes the sources holding connections.
+func (c *channels) close() {
+	for _, closer := range c.closers {
+		closer.Close()
+	}
+}
+
+// signedStreams signs the segment URIs of the playlists with the restrictions of the signed playlist URL, so the players need no other credentials.
+type signedStreams struct {
+	server.Streams
+	signer *signer.Signer
+	expiry time.Duration
+}
+
+// signParamsKey is the context key of the restrictions of the signed playlist URL.
+type signParamsKey struct{}
+
+// Playlist returns the playlist of the stream with its segment URIs signed.
+func (s *signedStreams) Playlist(ctx context.Context, id string) ([]byte, error) {
+	data, err := s.Streams.Playlist(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	params, _ := ctx.Value(signParamsKey{}).(signer.Params)
+	params.Expires = time.Now().Add(s.expiry)
+
+	var b strings.Builder
+	for _, line := range strings.SplitAfter(string(data), "\n") {
+		name := strings.TrimRight(line, "\n")
+		if name == "" || strings.HasPrefix(name, "#") {
+			b.WriteString(line)
+			continue
+		}
+
+		signed := s.signer.Sign(&url.URL{Path: "/streams/" + id + "/" + name}, params)
+		b.WriteString(name + "?" + signed.RawQuery + strings.TrimPrefix(line, name))
+	}
+
+	return []byte(b.String()), nil
+}
+
+// verifyStreams returns a middleware rejecting the stream requests without a valid signature for their stream, passing the restrictions of the URL to signedStreams.
+func verifyStreams(s *signer.Signer) server.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id, _, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/streams/"), "/")
+			ip := server.ClientIP(r)
+			if err := s.Verify(r.URL, id, ip, time.Now()); err != nil {
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+
+			params := signer.Params{Channel: r.URL.Query().Get(signer.ChannelParam)}
+			if r.URL.Query().Get(signer.IPBoundParam) == "1" {
+				params.IP = ip
+			}
+
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), signParamsKey{}, params)))
+		})
+	}
+}
+
+// streamURLHandler returns the handler of GET /admin/streams/{id}/url, issuing the signed playlist URL of a stream bound to the client IP given by the ip query parameter, if any, to the API clients authorized by the function.
+func streamURLHandler(s *signer.Signer, expiry time.Duration, authorized func(r *http.Request) bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(r) {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+
+		id := r.PathValue("id")
+		signed := s.Sign(&url.URL{Path: "/streams/" + id + "/playlist.m3u8"}, signer.Params{Expires: time.Now().Add(expiry), Channel: id, IP: r.URL.Query().Get("ip")})
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprintln(w, signed.String())
+	})
+}
@@ -0,0 +1,151 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"vrmix/source"
+)
+
+// ErrUsage indicates that the command line is invalid, the usage being printed.
+var ErrUsage = errors.New("invalid usage")
+
+// command is a subcommand of the command line.
+type command struct {
+	name    string // Name of the subcommand, like "inspect"
+	usage   string // Arguments of the subcommand, like "[flags] <file|url>"
+	summary string // One line description of the subcommand
+
+	// run runs the subcommand with its arguments, returning ErrUsage when they are invalid.
+	run func(ctx context.Context, flags *flag.FlagSet, args []string, stdout io.Writer) error
+}
+
+// commands lists the subcommands, in the order printed by the usage.
+var commands = []command{serveCommand, configCommand, inspectCommand, downloadCommand, loadtestCommand, mergeCommand, trimCommand, spliceCommand, rewriteCommand, windowCommand}
+
+// Run runs the command line with the arguments, without the program name, returning the exit code: 0 on success, 1 on failure and 2 on invalid usage.
+func Run(ctx context.Context, args []string, stdout io.Writer, stderr io.Writer) int {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		usage(stderr)
+		if len(args) == 0 {
+			return 2
+		}
+
+		return 0
+	}
+
+	for _, c := range commands {
+		if c.name != args[0] {
+			continue
+		}
+
+		flags := flag.NewFlagSet("vrmix "+c.name, flag.ContinueOnError)
+		flags.SetOutput(stderr)
+		flags.Usage = func() {
+			fmt.Fprintf(stderr, "usage: vrmix %s %s\n\n%s\n", c.name, c.usage, c.summary)
+			if hasFlags(flags) {
+				fmt.Fprintln(stderr, "\nflags:")
+				flags.PrintDefaults()
+			}
+		}
+
+		err := c.run(ctx, flags, args[1:], stdout)
+		switch {
+		case err == nil:
+			return 0
+		case errors.Is(err, flag.ErrHelp):
+			return 0
+		case errors.Is(err, ErrUsage):
+			flags.Usage()
+			return 2
+		default:
+			fmt.Fprintln(stderr, "vrmix "+c.name+": "+err.Error())
+			return 1
+		}
+	}
+
+	fmt.Fprintf(stderr, "vrmix: unknown command %q\n\n", args[0])
+	usage(stderr)
+	return 2
+}
+
+// usage prints the subcommands.
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: vrmix <command> [arguments]\n\ncommands:")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-10s %s\n", c.name, c.summary)
+	}
+
+	fmt.Fprintln(w, "\nRun \"vrmix <command> -h\" for the arguments of a command.")
+}
+
+// hasFlags returns true if the flag set defines any flag.
+func hasFlags(flags *flag.FlagSet) bool {
+	found := false
+	flags.VisitAll(func(*flag.Flag) { found = true })
+	return found
+}
+
+// readRef reads a local file, a HTTP URL or the standard input when the reference is "-".
+func readRef(ctx context.Context, ref string) ([]byte, error) {
+	if ref == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+
+	body, err := openRef(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	return io.ReadAll(body)
+}
+
+// openRef opens a local file or a HTTP URL.
+func openRef(ctx context.Context, ref string) (io.ReadCloser, error) {
+	if !isURL(ref) {
+		return os.Open(ref)
+	}
+
+	return (&source.HTTPSource{Client: http.DefaultClient}).OpenSegment(ctx, ref)
+}
+
+// resolveRef resolves the URI referenced by the playlist at the base reference, which is a local file or a HTTP URL.
+func resolveRef(base string, uri string) (string, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", err
+	}
+
+	if u.IsAbs() {
+		return uri, nil
+	}
+
+	if !isURL(base) {
+		if filepath.IsAbs(uri) {
+			return uri, nil
+		}
+
+		return filepath.Join(filepath.Dir(base), filepath.FromSlash(uri)), nil
+	}
+
+	baseURL, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+
+	return baseURL.ResolveReference(u).String(), nil
+}
+
+// isURL returns true if the reference is a HTTP URL.
+func isURL(ref string) bool {
+	return strings.HasPrefix(ref, "http://") || strings.HasPrefix(ref, "https://")
+}
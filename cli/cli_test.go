@@ -0,0 +1,35 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+// run runs the command line, returning its exit code and outputs
+func run(t *testing.T, args ...string) (int, string, string) {
+	t.Helper()
+
+	var stdout, stderr bytes.Buffer
+	code := Run(context.Background(), args, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestRunUsage(t *testing.T) {
+	if code, _, stderr := run(t); code != 2 || !strings.Contains(stderr, "inspect") {
+		t.Errorf("expected the usage listing the commands, got %d and %s", code, stderr)
+	}
+
+	if code, _, stderr := run(t, "unknown"); code != 2 || !strings.Contains(stderr, `unknown command "unknown"`) {
+		t.Errorf("expected the unknown command to be reported, got %d and %s", code, stderr)
+	}
+
+	if code, _, stderr := run(t, "inspect"); code != 2 || !strings.Contains(stderr, "usage: vrmix inspect") {
+		t.Errorf("expected the usage of the command, got %d and %s", code, stderr)
+	}
+
+	if code, _, _ := run(t, "inspect", "-h"); code != 0 {
+		t.Errorf("expected the help to succeed, got %d", code)
+	}
+}
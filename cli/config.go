@@ -0,0 +1,87 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"io"
+	"os"
+	"time"
+
+	"vrmix/config"
+)
+
+// configCommand prints the configuration resolved from the defaults, the file, the environment and the flags.
+var configCommand = command{
+	name:    "config",
+	usage:   "[flags]",
+	summary: "Print the configuration resolved from the defaults, the file, the environment and the flags, with the secrets redacted.",
+	run:     runConfig,
+}
+
+// configFlags registers the flags layered over the configuration file and the environment, returning the function resolving the layers once the flags are parsed.
+func configFlags(flags *flag.FlagSet) func() config.Layers {
+	file := flags.String("config", "", "YAML or TOML configuration file, overridden by the "+config.EnvPrefix+"* environment variables and the flags set")
+	addr := flags.String("addr", ":8080", "address to listen on")
+	certFile := flags.String("tls-cert", "", "PEM certificate chain served over HTTPS, reloaded when it changes")
+	keyFile := flags.String("tls-key", "", "PEM private key of the certificate")
+	shutdownTimeout := flags.Duration("shutdown-timeout", 30*time.Second, "maximum time of the graceful shutdown")
+	drainPeriod := flags.Duration("drain", 0, "maximum time the existing sessions keep being served after the shutdown starts")
+
+	return func() config.Layers {
+		set := map[string]bool{}
+		flags.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+		return config.Layers{
+			File:      *file,
+			EnvPrefix: config.EnvPrefix,
+			Environ:   os.Environ(),
+			Flags: func(c *config.Config) {
+				if set["addr"] {
+					c.Server.Addr = *addr
+				}
+
+				if set["tls-cert"] {
+					c.Server.TLSCert = *certFile
+				}
+
+				if set["tls-key"] {
+					c.Server.TLSKey = *keyFile
+				}
+
+				if set["shutdown-timeout"] {
+					c.Server.ShutdownTimeout = *shutdownTimeout
+				}
+
+				if set["drain"] {
+					c.Server.DrainPeriod = *drainPeriod
+				}
+			},
+		}
+	}
+}
+
+// runConfig runs the config command.
+func runConfig(ctx context.Context, flags *flag.FlagSet, args []string, stdout io.Writer) error {
+	layers := configFlags(flags)
+	format := flags.String("format", "yaml", `format of the output, "yaml" or "toml"`)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 0 || (*format != string(config.FormatYAML) && *format != string(config.FormatTOML)) {
+		return ErrUsage
+	}
+
+	resolved, err := config.Resolve(layers())
+	if err != nil {
+		return err
+	}
+
+	data, err := resolved.Redact().Marshal(config.Format(*format))
+	if err != nil {
+		return err
+	}
+
+	_, err = stdout.Write(data)
+	return err
+}
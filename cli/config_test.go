@@ -0,0 +1,33 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vrmix.yaml")
+	os.WriteFile(path, []byte("server:\n  addr: \":9000\"\nauth:\n  api_tokens: [token]\nlimits:\n  segment_rate: 20\n"), 0o600)
+	t.Setenv("VRMIX_LIMITS_SEGMENT_RATE", "42")
+
+	code, stdout, stderr := run(t, "config", "-config", path, "-addr", ":9100")
+	if code != 0 {
+		t.Fatalf("expected the configuration to be resolved, got %d and %s", code, stderr)
+	}
+
+	for _, expected := range []string{`addr: :9100`, "segment_rate: 42", "- REDACTED", "shutdown_timeout: 30s"} {
+		if !strings.Contains(stdout, expected) {
+			t.Errorf("expected %q in %s", expected, stdout)
+		}
+	}
+
+	if code, stdout, _ := run(t, "config", "-format", "toml"); code != 0 || !strings.Contains(stdout, `addr = ":8080"`) {
+		t.Errorf("expected the defaults as TOML, got %d and %s", code, stdout)
+	}
+
+	if code, _, _ := run(t, "config", "-format", "json"); code != 2 {
+		t.Errorf("expected an unknown format to be rejected, got %d", code)
+	}
+}
@@ -0,0 +1,2 @@
+// Package cli implements the vrmix command line, dispatching its subcommands.
+package cli
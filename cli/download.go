@@ -0,0 +1,302 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"vrmix/hls"
+)
+
+var (
+	// ErrLivePlaylist indicates that the downloaded playlist has no end, so it is not a VOD.
+	ErrLivePlaylist = errors.New("playlist is live, not a VOD")
+
+	// ErrUnknownVariant indicates that no variant of the master playlist matches the requested one.
+	ErrUnknownVariant = errors.New("unknown variant")
+)
+
+// downloadCommand downloads a VOD as a local HLS copy or a single MP4.
+var downloadCommand = command{
+	name:    "download",
+	usage:   "[flags] <file|url> <directory|file.mp4>",
+	summary: "Download a HLS VOD into a directory, or remux it into an MP4 file with ffmpeg.",
+	run:     runDownload,
+}
+
+// download is a file fetched by the download command.
+type download struct {
+	ref  string // Reference of the remote file
+	name string // Name of the local file in the output directory
+}
+
+// runDownload runs the download command.
+func runDownload(ctx context.Context, flags *flag.FlagSet, args []string, stdout io.Writer) error {
+	variant := flags.String("variant", "highest", `variant of a master playlist: "highest", "lowest", a resolution like "1920x1080" or its URI`)
+	concurrency := flags.Int("concurrency", 4, "segments downloaded at once")
+	ffmpeg := flags.String("ffmpeg", "ffmpeg", "path to the ffmpeg binary remuxing the MP4")
+	quiet := flags.Bool("quiet", false, "do not print the progress")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 2 || *concurrency < 1 {
+		return ErrUsage
+	}
+
+	ref, output := flags.Arg(0), flags.Arg(1)
+	manifest, ref, err := readVOD(ctx, ref, *variant)
+	if err != nil {
+		return err
+	}
+
+	mp4 := strings.EqualFold(filepath.Ext(output), ".mp4")
+	dir := output
+	if mp4 {
+		if dir, err = os.MkdirTemp("", "vrmix-download-"); err != nil {
+			return err
+		}
+		defer os.RemoveAll(dir)
+	} else if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	downloads, err := localize(&manifest, ref)
+	if err != nil {
+		return err
+	}
+
+	progress := func(done int, bytes int64) {}
+	if !*quiet {
+		progress = func(done int, bytes int64) {
+			fmt.Fprintf(stdout, "\rdownloaded %d/%d files, %.1f MB", done, len(downloads), float64(bytes)/1e6)
+		}
+	}
+
+	err = fetchAll(ctx, dir, downloads, *concurrency, progress)
+	if !*quiet {
+		fmt.Fprintln(stdout)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	playlist := filepath.Join(dir, "playlist.m3u8")
+	if err := os.WriteFile(playlist, []byte(manifest.String()), 0o644); err != nil {
+		return err
+	}
+
+	if mp4 {
+		cmd := exec.CommandContext(ctx, *ffmpeg, remuxArgs(playlist, output)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("ffmpeg: %w: %s", err, strings.TrimSpace(string(out)))
+		}
+	}
+
+	fmt.Fprintln(stdout, "wrote "+output)
+	return nil
+}
+
+// readVOD reads the media playlist at the reference, picking the variant when it is a master playlist, returning it with its own reference.
+func readVOD(ctx context.Context, ref string, variant string) (hls.Manifest, string, error) {
+	data, err := readRef(ctx, ref)
+	if err != nil {
+		return hls.Manifest{}, "", err
+	}
+
+	text := strings.TrimRight(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+	if strings.Contains(text, hls.StreamInfField) {
+		master, err := hls.ParseMasterManifest(text)
+		if err != nil {
+			return hls.Manifest{}, "", err
+		}
+
+		selected, err := selectVariant(master.Variants, variant)
+		if err != nil {
+			return hls.Manifest{}, "", err
+		}
+
+		if ref, err = resolveRef(ref, selected.URI); err != nil {
+			return hls.Manifest{}, "", err
+		}
+
+		return readVOD(ctx, ref, variant)
+	}
+
+	manifest, err := hls.ParseHlsManifest(text)
+	if err != nil {
+		return hls.Manifest{}, "", err
+	}
+
+	if !manifest.HasEndList {
+		return hls.Manifest{}, "", ErrLivePlaylist
+	}
+
+	return manifest, ref, nil
+}
+
+// selectVariant returns the variant with the highest or lowest bandwidth, or the one with the resolution or URI.
+func selectVariant(variants []hls.Variant, name string) (hls.Variant, error) {
+	if len(variants) == 0 {
+		return hls.Variant{}, fmt.Errorf("%w: the master playlist has no variants", ErrUnknownVariant)
+	}
+
+	byBandwidth := func(a, b hls.Variant) int { return a.Bandwidth - b.Bandwidth }
+	switch name {
+	case "highest":
+		return slices.MaxFunc(variants, byBandwidth), nil
+	case "lowest":
+		return slices.MinFunc(variants, byBandwidth), nil
+	}
+
+	var matches []hls.Variant
+	for _, variant := range variants {
+		if variant.URI == name || variant.Resolution == name {
+			matches = append(matches, variant)
+		}
+	}
+
+	if len(matches) == 0 {
+		return hls.Variant{}, fmt.Errorf("%w: %s", ErrUnknownVariant, name)
+	}
+
+	return slices.MaxFunc(matches, byBandwidth), nil
+}
+
+// localize renames the segments and the keys of the manifest after the local files they are downloaded to, returning the downloads.
+func localize(manifest *hls.Manifest, ref string) ([]download, error) {
+	var downloads []download
+	keys := map[string]string{}
+
+	index := 0
+	for i := range manifest.SegmentGroups {
+		segments := manifest.SegmentGroups[i].Segments
+		for j := range segments {
+			segmentRef, err := resolveRef(ref, segments[j].Path)
+			if err != nil {
+				return nil, err
+			}
+
+			name := fmt.Sprintf("segment%05d%s", index, extension(segments[j].Path, ".ts"))
+			downloads = append(downloads, download{ref: segmentRef, name: name})
+			segments[j].Path = name
+			index++
+
+			segments[j].Keys = slices.Clone(segments[j].Keys)
+			for k := range segments[j].Keys {
+				key := &segments[j].Keys[k]
+				if key.DRM() || key.URI == "" {
+					continue
+				}
+
+				keyRef, err := resolveRef(ref, key.URI)
+				if err != nil {
+					return nil, err
+				}
+
+				name, found := keys[keyRef]
+				if !found {
+					name = "key" + strconv.Itoa(len(keys)) + ".key"
+					keys[keyRef] = name
+					downloads = append(downloads, download{ref: keyRef, name: name})
+				}
+
+				key.URI = name
+			}
+		}
+	}
+
+	return downloads, nil
+}
+
+// extension returns the extension of the path of the URI, or the fallback when it has none.
+func extension(uri string, fallback string) string {
+	u, err := url.Parse(uri)
+	if err != nil || path.Ext(u.Path) == "" {
+		return fallback
+	}
+
+	return path.Ext(u.Path)
+}
+
+// fetchAll downloads the files into the directory with the number of concurrent downloads, stopping at the first failure and reporting the progress after each file.
+func fetchAll(ctx context.Context, dir string, downloads []download, concurrency int, progress func(done int, bytes int64)) error {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	jobs := make(chan download)
+	var mutex sync.Mutex
+	var done int
+	var total int64
+
+	var wg sync.WaitGroup
+	for range min(concurrency, len(downloads)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				written, err := fetch(ctx, filepath.Join(dir, job.name), job.ref)
+				if err != nil {
+					cancel(fmt.Errorf("%s: %w", job.ref, err))
+					continue
+				}
+
+				mutex.Lock()
+				done, total = done+1, total+written
+				progress(done, total)
+				mutex.Unlock()
+			}
+		}()
+	}
+
+send:
+	for _, job := range downloads {
+		select {
+		case jobs <- job:
+		case <-ctx.Done():
+			break send
+		}
+	}
+
+	close(jobs)
+	wg.Wait()
+	return context.Cause(ctx)
+}
+
+// fetch downloads the reference into the file, returning the number of bytes written.
+func fetch(ctx context.Context, name string, ref string) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	body, err := openRef(ctx, ref)
+	if err != nil {
+		return 0, err
+	}
+	defer body.Close()
+
+	file, err := os.Create(name)
+	if err != nil {
+		return 0, err
+	}
+
+	written, err := io.Copy(file, body)
+	return written, errors.Join(err, file.Close())
+}
+
+// remuxArgs returns the arguments of ffmpeg remuxing the local playlist into an MP4 file without transcoding.
+func remuxArgs(playlist string, output string) []string {
+	return []string{"-y", "-loglevel", "error", "-allowed_extensions", "ALL", "-i", playlist, "-c", "copy", "-movflags", "+faststart", output}
+}
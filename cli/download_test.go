@@ -0,0 +1,123 @@
+package cli
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"vrmix/hls"
+)
+
+// newVODServer serves a master playlist with a low and a high variant, the high one being encrypted
+func newVODServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	files := map[string]string{
+		"/master.m3u8":     "#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=1000000,RESOLUTION=1280x720\nlow/index.m3u8\n#EXT-X-STREAM-INF:BANDWIDTH=5000000,RESOLUTION=1920x1080\nhigh/index.m3u8\n",
+		"/low/index.m3u8":  "#EXTM3U\n#EXT-X-TARGETDURATION:4\n#EXTINF:4,\n0.ts\n#EXT-X-ENDLIST\n",
+		"/low/0.ts":        "low0",
+		"/high/index.m3u8": "#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-TARGETDURATION:4\n#EXT-X-KEY:METHOD=AES-128,URI=\"/keys/1\"\n#EXTINF:4,\n0.ts?token=a\n#EXTINF:4,\nhttp://HOST/high/1.ts\n#EXT-X-ENDLIST\n",
+		"/high/0.ts":       "high0",
+		"/high/1.ts":       "high1",
+		"/keys/1":          "0123456789abcdef",
+		"/live.m3u8":       "#EXTM3U\n#EXT-X-TARGETDURATION:4\n#EXTINF:4,\n0.ts\n",
+	}
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, found := files[r.URL.Path]
+		if !found {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Write([]byte(strings.ReplaceAll(body, "http://HOST", server.URL)))
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestDownload(t *testing.T) {
+	server := newVODServer(t)
+	dir := filepath.Join(t.TempDir(), "vod")
+
+	code, stdout, stderr := run(t, "download", "-concurrency", "2", server.URL+"/master.m3u8", dir)
+	if code != 0 {
+		t.Fatalf("expected the download to succeed, got %d and %s", code, stderr)
+	}
+
+	if !strings.Contains(stdout, "downloaded 3/3 files") || !strings.Contains(stdout, "wrote "+dir) {
+		t.Errorf("expected the progress, got %s", stdout)
+	}
+
+	for name, expected := range map[string]string{"segment00000.ts": "high0", "segment00001.ts": "high1", "key0.key": "0123456789abcdef"} {
+		if data, err := os.ReadFile(filepath.Join(dir, name)); err != nil || string(data) != expected {
+			t.Errorf("expected %s to hold %q, got %q and %v", name, expected, data, err)
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "playlist.m3u8"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	manifest, err := hls.ParseHlsManifest(strings.TrimRight(string(data), "\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if paths(manifest) != "segment00000.ts segment00001.ts" || manifest.SegmentGroups[0].Segments[0].Keys[0].URI != "key0.key" {
+		t.Errorf("expected the playlist to reference the local files, got %s", data)
+	}
+}
+
+func TestDownloadVariant(t *testing.T) {
+	server := newVODServer(t)
+	dir := t.TempDir()
+
+	if code, _, stderr := run(t, "download", "-quiet", "-variant", "1280x720", server.URL+"/master.m3u8", dir); code != 0 {
+		t.Fatalf("expected the download to succeed, got %d and %s", code, stderr)
+	}
+
+	if data, err := os.ReadFile(filepath.Join(dir, "segment00000.ts")); err != nil || string(data) != "low0" {
+		t.Errorf("expected the low variant, got %q and %v", data, err)
+	}
+
+	if code, _, stderr := run(t, "download", "-variant", "640x360", server.URL+"/master.m3u8", dir); code != 1 || !strings.Contains(stderr, ErrUnknownVariant.Error()) {
+		t.Errorf("expected an unknown variant, got %d and %s", code, stderr)
+	}
+
+	if code, _, stderr := run(t, "download", server.URL+"/live.m3u8", dir); code != 1 || !strings.Contains(stderr, ErrLivePlaylist.Error()) {
+		t.Errorf("expected the live playlist to be rejected, got %d and %s", code, stderr)
+	}
+}
+
+func TestDownloadMissingSegment(t *testing.T) {
+	server := newVODServer(t)
+	path := filepath.Join(t.TempDir(), "missing.m3u8")
+	os.WriteFile(path, []byte("#EXTM3U\n#EXT-X-TARGETDURATION:4\n#EXTINF:4,\n"+server.URL+"/low/0.ts\n#EXTINF:4,\n"+server.URL+"/low/9.ts\n#EXT-X-ENDLIST\n"), 0o644)
+
+	code, _, stderr := run(t, "download", "-quiet", path, t.TempDir())
+	if code != 1 || !strings.Contains(stderr, "/low/9.ts") {
+		t.Errorf("expected the missing segment to be reported, got %d and %s", code, stderr)
+	}
+}
+
+func TestSelectVariant(t *testing.T) {
+	variants := []hls.Variant{{URI: "a.m3u8", Bandwidth: 2}, {URI: "b.m3u8", Bandwidth: 9}, {URI: "c.m3u8", Bandwidth: 1}}
+
+	for name, expected := range map[string]string{"highest": "b.m3u8", "lowest": "c.m3u8", "a.m3u8": "a.m3u8"} {
+		if variant, err := selectVariant(variants, name); err != nil || variant.URI != expected {
+			t.Errorf("expected %s to select %s, got %s and %v", name, expected, variant.URI, err)
+		}
+	}
+
+	if _, err := selectVariant(nil, "highest"); !errors.Is(err, ErrUnknownVariant) {
+		t.Errorf("expected ErrUnknownVariant, got %v", err)
+	}
+}
@@ -0,0 +1,287 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+
+	"vrmix/hls"
+)
+
+// mergeCommand concatenates playlists.
+var mergeCommand = command{
+	name:    "merge",
+	usage:   "[flags] <playlist> <playlist>...",
+	summary: "Concatenate media playlists, each one after a discontinuity.",
+	run:     runMerge,
+}
+
+// trimCommand removes segments from the start and the end of a playlist.
+var trimCommand = command{
+	name:    "trim",
+	usage:   "[flags] <playlist>",
+	summary: "Remove segments from the start and the end of a media playlist.",
+	run:     runTrim,
+}
+
+// spliceCommand inserts a playlist inside another.
+var spliceCommand = command{
+	name:    "splice",
+	usage:   "[flags] <playlist> <inserted playlist>",
+	summary: "Insert a media playlist inside another, between discontinuities.",
+	run:     runSplice,
+}
+
+// rewriteCommand rewrites the segment paths of a playlist.
+var rewriteCommand = command{
+	name:    "rewrite",
+	usage:   "[flags] <playlist>",
+	summary: "Rewrite the segment and key paths of a media playlist.",
+	run:     runRewrite,
+}
+
+// windowCommand keeps the last segments of a playlist.
+var windowCommand = command{
+	name:    "window",
+	usage:   "[flags] <playlist>",
+	summary: "Keep the last segments of a media playlist, like the window of a live stream.",
+	run:     runWindow,
+}
+
+// readManifest reads and parses the media playlist of the reference.
+func readManifest(ctx context.Context, ref string) (hls.Manifest, error) {
+	data, err := readRef(ctx, ref)
+	if err != nil {
+		return hls.Manifest{}, err
+	}
+
+	manifest, err := hls.ParseHlsManifest(strings.TrimRight(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n"))
+	if err != nil {
+		return hls.Manifest{}, fmt.Errorf("%s: %w", ref, err)
+	}
+
+	return manifest, nil
+}
+
+// outputFlag adds the flag of the file the playlist is written to.
+func outputFlag(flags *flag.FlagSet) *string {
+	return flags.String("o", "", "write the playlist to the file instead of the standard output")
+}
+
+// writeManifest writes the playlist to the file, or the standard output when the path is empty.
+func writeManifest(stdout io.Writer, path string, manifest *hls.Manifest) error {
+	if path == "" {
+		_, err := manifest.WriteTo(stdout)
+		return err
+	}
+
+	return os.WriteFile(path, []byte(manifest.String()), 0o644)
+}
+
+// runMerge runs the merge command.
+func runMerge(ctx context.Context, flags *flag.FlagSet, args []string, stdout io.Writer) error {
+	output := outputFlag(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() < 2 {
+		return ErrUsage
+	}
+
+	manifest, err := readManifest(ctx, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	for _, ref := range flags.Args()[1:] {
+		next, err := readManifest(ctx, ref)
+		if err != nil {
+			return err
+		}
+
+		if manifest.Merge(next) {
+			fmt.Fprintln(flags.Output(), "warning: "+ref+" raised the version or the target duration of the playlist")
+		}
+	}
+
+	return writeManifest(stdout, *output, &manifest)
+}
+
+// runTrim runs the trim command.
+func runTrim(ctx context.Context, flags *flag.FlagSet, args []string, stdout io.Writer) error {
+	output := outputFlag(flags)
+	start := flags.Int("start", 0, "segments removed from the start")
+	end := flags.Int("end", 0, "segments removed from the end")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 || *start < 0 || *end < 0 {
+		return ErrUsage
+	}
+
+	manifest, err := readManifest(ctx, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	manifest.RemoveFromStart(*start)
+
+	// The segments removed from the end do not move the remaining ones, so their sequence numbers are kept.
+	mediaSequence, discontinuitySequence := manifest.MediaSequence, manifest.DiscontinuitySequence
+	manifest.RemoveFromEnd(*end)
+	manifest.MediaSequence, manifest.DiscontinuitySequence = mediaSequence, discontinuitySequence
+
+	return writeManifest(stdout, *output, &manifest)
+}
+
+// runSplice runs the splice command.
+func runSplice(ctx context.Context, flags *flag.FlagSet, args []string, stdout io.Writer) error {
+	output := outputFlag(flags)
+	at := flags.Int("at", 0, "index of the segment the playlist is inserted before, counted across the discontinuities")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 2 || *at < 0 {
+		return ErrUsage
+	}
+
+	manifest, err := readManifest(ctx, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	inserted, err := readManifest(ctx, flags.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	if *at > manifest.SegmentCount() {
+		return fmt.Errorf("cannot insert at segment %d of a playlist of %d segments", *at, manifest.SegmentCount())
+	}
+
+	// The segments after the insertion change media sequence numbers, so the IVs derived from them are pinned first.
+	manifest.PinIVs()
+	inserted.PinIVs()
+
+	var groups []hls.SegmentGroup
+	index := *at
+	for i, group := range manifest.SegmentGroups {
+		if index < 0 || index > len(group.Segments) || (index == len(group.Segments) && i+1 < len(manifest.SegmentGroups)) {
+			groups = append(groups, group)
+			index -= len(group.Segments)
+			continue
+		}
+
+		if index > 0 {
+			groups = append(groups, hls.SegmentGroup{Segments: slices.Clone(group.Segments[:index]), Map: group.Map, Continuous: group.Continuous})
+		}
+
+		groups = append(groups, inserted.SegmentGroups...)
+		if index < len(group.Segments) {
+			groups = append(groups, hls.SegmentGroup{Segments: slices.Clone(group.Segments[index:]), Map: group.Map})
+		}
+
+		index = -1
+	}
+
+	if len(manifest.SegmentGroups) == 0 {
+		groups = inserted.SegmentGroups
+	}
+
+	manifest.SegmentGroups = groups
+	manifest.Version = max(manifest.Version, inserted.Version)
+	manifest.TargetDuration = max(manifest.TargetDuration, inserted.TargetDuration)
+	return writeManifest(stdout, *output, &manifest)
+}
+
+// runRewrite runs the rewrite command.
+func runRewrite(ctx context.Context, flags *flag.FlagSet, args []string, stdout io.Writer) error {
+	output := outputFlag(flags)
+	strip := flags.String("strip", "", "prefix removed from the paths")
+	prefix := flags.String("prefix", "", "prefix added to the relative paths, after the strip")
+	base := flags.String("base", "", "URL the relative paths are resolved against, after the prefix")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		return ErrUsage
+	}
+
+	var baseURL *url.URL
+	if *base != "" {
+		var err error
+		if baseURL, err = url.Parse(*base); err != nil {
+			return err
+		}
+	}
+
+	manifest, err := readManifest(ctx, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	rewrite := func(path string) string {
+		path = strings.TrimPrefix(path, *strip)
+		if u, err := url.Parse(path); err != nil || u.IsAbs() {
+			return path
+		}
+
+		path = *prefix + path
+		if baseURL != nil {
+			if u, err := baseURL.Parse(path); err == nil {
+				return u.String()
+			}
+		}
+
+		return path
+	}
+
+	for i := range manifest.SegmentGroups {
+		segments := manifest.SegmentGroups[i].Segments
+		for j := range segments {
+			segments[j].Path = rewrite(segments[j].Path)
+		}
+	}
+
+	manifest.RewriteKeyURIs(rewrite)
+
+	return writeManifest(stdout, *output, &manifest)
+}
+
+// runWindow runs the window command.
+func runWindow(ctx context.Context, flags *flag.FlagSet, args []string, stdout io.Writer) error {
+	output := outputFlag(flags)
+	size := flags.Int("size", 6, "segments kept")
+	live := flags.Bool("live", false, "remove the end of the playlist, so it is played as a live stream")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 || *size <= 0 {
+		return ErrUsage
+	}
+
+	manifest, err := readManifest(ctx, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	if count := manifest.SegmentCount(); count > *size {
+		manifest.RemoveFromStart(count - *size)
+	}
+
+	if *live {
+		manifest.HasEndList = false
+	}
+
+	return writeManifest(stdout, *output, &manifest)
+}
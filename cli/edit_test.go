@@ -0,0 +1,131 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"vrmix/hls"
+)
+
+// parseOutput parses the playlist written by a command
+func parseOutput(t *testing.T, output string) hls.Manifest {
+	t.Helper()
+
+	manifest, err := hls.ParseHlsManifest(strings.TrimRight(output, "\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return manifest
+}
+
+// paths returns the segment paths of the manifest, with a "|" for each discontinuity
+func paths(manifest hls.Manifest) string {
+	var groups []string
+	for _, group := range manifest.SegmentGroups {
+		var segments []string
+		for _, segment := range group.Segments {
+			segments = append(segments, segment.Path)
+		}
+
+		groups = append(groups, strings.Join(segments, " "))
+	}
+
+	return strings.Join(groups, " | ")
+}
+
+func TestMerge(t *testing.T) {
+	code, stdout, stderr := run(t, "merge", "../testdata/stream0.m3u8", "../testdata/stream1.m3u8")
+	if code != 0 {
+		t.Fatalf("expected the merge to succeed, got %d and %s", code, stderr)
+	}
+
+	manifest := parseOutput(t, stdout)
+	if paths(manifest) != "0.ts 1.ts | 0.ts 1.ts 2.ts" || manifest.TargetDuration != 8 {
+		t.Errorf("expected the playlists after a discontinuity, got %s", paths(manifest))
+	}
+
+	if !strings.Contains(stderr, "raised the version or the target duration") {
+		t.Errorf("expected the raised target duration to be reported, got %s", stderr)
+	}
+}
+
+func TestTrimWindow(t *testing.T) {
+	code, stdout, _ := run(t, "trim", "-start", "2", "-end", "3", "../testdata/stream2.m3u8")
+	if code != 0 {
+		t.Fatalf("expected the trim to succeed, got %d", code)
+	}
+
+	manifest := parseOutput(t, stdout)
+	if !strings.HasPrefix(paths(manifest), "2.ts 3.ts") || manifest.MediaSequence != 2 {
+		t.Errorf("expected the playlist to start at segment 2, got %s at %d", paths(manifest), manifest.MediaSequence)
+	}
+
+	output := filepath.Join(t.TempDir(), "window.m3u8")
+	if code, _, _ := run(t, "window", "-size", "2", "-live", "-o", output, "../testdata/stream1.m3u8"); code != 0 {
+		t.Fatalf("expected the window to succeed, got %d", code)
+	}
+
+	data, _ := os.ReadFile(output)
+	manifest = parseOutput(t, string(data))
+	if paths(manifest) != "1.ts 2.ts" || manifest.MediaSequence != 1 || manifest.HasEndList {
+		t.Errorf("expected the last 2 segments of a live playlist, got %s", data)
+	}
+}
+
+func TestSplice(t *testing.T) {
+	for at, expected := range map[string]string{
+		"0": "0.ts 1.ts 2.ts | 0.ts 1.ts",
+		"1": "0.ts | 0.ts 1.ts 2.ts | 1.ts",
+		"2": "0.ts 1.ts | 0.ts 1.ts 2.ts",
+	} {
+		code, stdout, _ := run(t, "splice", "-at", at, "../testdata/stream0.m3u8", "../testdata/stream1.m3u8")
+		if code != 0 {
+			t.Fatalf("expected the splice at %s to succeed, got %d", at, code)
+		}
+
+		if got := paths(parseOutput(t, stdout)); got != expected {
+			t.Errorf("expected %s at %s, got %s", expected, at, got)
+		}
+	}
+
+	if code, _, _ := run(t, "splice", "-at", "3", "../testdata/stream0.m3u8", "../testdata/stream1.m3u8"); code != 1 {
+		t.Errorf("expected the splice after the end to fail, got %d", code)
+	}
+}
+
+func TestSpliceContinuous(t *testing.T) {
+	dir := t.TempDir()
+	stream := filepath.Join(dir, "stream.m3u8")
+	os.WriteFile(stream, []byte("#EXTM3U\n#EXT-X-VERSION:6\n#EXT-X-TARGETDURATION:4\n#EXT-X-MAP:URI=\"a.mp4\"\n#EXTINF:4,\na.m4s\n#EXT-X-MAP:URI=\"b.mp4\"\n#EXTINF:4,\nb0.m4s\n#EXTINF:4,\nb1.m4s\n#EXT-X-ENDLIST\n"), 0o644)
+	ad := filepath.Join(dir, "ad.m3u8")
+	os.WriteFile(ad, []byte("#EXTM3U\n#EXT-X-VERSION:6\n#EXT-X-TARGETDURATION:4\n#EXT-X-MAP:URI=\"ad.mp4\"\n#EXTINF:4,\nad.m4s\n#EXT-X-ENDLIST\n"), 0o644)
+
+	code, stdout, stderr := run(t, "splice", "-at", "2", stream, ad)
+	if code != 0 {
+		t.Fatalf("expected the splice to succeed, got %d and %s", code, stderr)
+	}
+
+	manifest := parseOutput(t, stdout)
+	if paths(manifest) != "a.m4s | b0.m4s | ad.m4s | b1.m4s" || !manifest.SegmentGroups[1].Continuous || manifest.SegmentGroups[2].Continuous {
+		t.Errorf("expected the first half of the split group to stay continuous, got %s", stdout)
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.m3u8")
+	os.WriteFile(path, []byte("#EXTM3U\n#EXT-X-TARGETDURATION:4\n#EXT-X-KEY:METHOD=AES-128,URI=\"old/key.bin\"\n#EXTINF:4,\nold/0.ts\n#EXTINF:4,\nhttps://cdn.example/1.ts\n"), 0o644)
+
+	code, stdout, _ := run(t, "rewrite", "-strip", "old/", "-prefix", "new/", "-base", "https://origin.example/live/", path)
+	if code != 0 {
+		t.Fatalf("expected the rewrite to succeed, got %d", code)
+	}
+
+	manifest := parseOutput(t, stdout)
+	segments := manifest.SegmentGroups[0].Segments
+	if segments[0].Path != "https://origin.example/live/new/0.ts" || segments[1].Path != "https://cdn.example/1.ts" || segments[0].Keys[0].URI != "https://origin.example/live/new/key.bin" {
+		t.Errorf("expected the relative paths to be rewritten, got %s", stdout)
+	}
+}
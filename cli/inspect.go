@@ -0,0 +1,197 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"vrmix/hls"
+)
+
+// ErrInvalidPlaylist indicates that the inspected playlist has validation errors, or warnings in strict mode.
+var ErrInvalidPlaylist = errors.New("invalid playlist")
+
+// inspectCommand prints the summary and the validation issues of a playlist.
+var inspectCommand = command{
+	name:    "inspect",
+	usage:   "[flags] <file|url|->",
+	summary: "Print a summary of a playlist and validate it, to debug an origin before queueing it.",
+	run:     runInspect,
+}
+
+// runInspect runs the inspect command.
+func runInspect(ctx context.Context, flags *flag.FlagSet, args []string, stdout io.Writer) error {
+	strict := flags.Bool("strict", false, "fail on warnings as well as errors")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		return ErrUsage
+	}
+
+	data, err := readRef(ctx, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	text := strings.TrimRight(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+	if strings.Contains(text, hls.StreamInfField) {
+		master, err := hls.ParseMasterManifest(text)
+		if err != nil {
+			fmt.Fprintln(stdout, "error: "+err.Error())
+			return ErrInvalidPlaylist
+		}
+
+		printMaster(stdout, &master)
+		return nil
+	}
+
+	manifest, err := hls.ParseHlsManifest(text)
+	if err != nil {
+		fmt.Fprintln(stdout, "error: "+err.Error())
+		return ErrInvalidPlaylist
+	}
+
+	printManifest(stdout, &manifest)
+
+	issues := manifest.Validate()
+	if len(issues) > 0 {
+		fmt.Fprintln(stdout)
+	}
+
+	failed := false
+	for _, issue := range issues {
+		failed = failed || issue.Severity == hls.SeverityError || *strict
+		fmt.Fprintln(stdout, formatIssue(&manifest, issue))
+	}
+
+	if failed {
+		return ErrInvalidPlaylist
+	}
+
+	return nil
+}
+
+// formatIssue formats a validation issue, naming the path of its segment.
+func formatIssue(manifest *hls.Manifest, issue hls.Issue) string {
+	if issue.Segment < 0 {
+		return string(issue.Severity) + ": " + issue.Message
+	}
+
+	index := issue.Segment
+	for _, group := range manifest.SegmentGroups {
+		if index < len(group.Segments) {
+			return fmt.Sprintf("%s: segment %d (%s): %s", issue.Severity, manifest.MediaSequence+uint32(issue.Segment), group.Segments[index].Path, issue.Message)
+		}
+
+		index -= len(group.Segments)
+	}
+
+	return string(issue.Severity) + ": " + issue.Message
+}
+
+// printManifest prints the summary of a media playlist.
+func printManifest(w io.Writer, manifest *hls.Manifest) {
+	kind := "live"
+	if manifest.HasEndList {
+		kind = "VOD"
+	}
+
+	fmt.Fprintln(w, "Type:                   media playlist ("+kind+")")
+	fmt.Fprintln(w, "Version:                "+strconv.Itoa(int(max(manifest.Version, 1))))
+	fmt.Fprintln(w, "Target duration:        "+strconv.Itoa(int(manifest.TargetDuration))+"s")
+	fmt.Fprintln(w, "Media sequence:         "+strconv.FormatUint(uint64(manifest.MediaSequence), 10))
+	fmt.Fprintln(w, "Discontinuity sequence: "+strconv.FormatUint(uint64(manifest.DiscontinuitySequence), 10))
+	fmt.Fprintln(w, "Duration:               "+formatSeconds(manifest.Duration()))
+
+	count := manifest.SegmentCount()
+	segments := "Segments:               " + strconv.Itoa(count)
+	if count > 0 {
+		shortest := float32(-1)
+		for _, group := range manifest.SegmentGroups {
+			for _, segment := range group.Segments {
+				if shortest < 0 || segment.Duration < shortest {
+					shortest = segment.Duration
+				}
+			}
+		}
+
+		segments += " (min " + formatSeconds(float64(shortest)) + ", avg " + formatSeconds(manifest.Duration()/float64(count)) + ", max " + formatSeconds(float64(manifest.MaxDuration())) + ")"
+	}
+
+	fmt.Fprintln(w, segments)
+	fmt.Fprintln(w, "Discontinuities:        "+strconv.Itoa(max(len(manifest.SegmentGroups)-1, 0)))
+
+	features := manifestFeatures(manifest)
+	if len(features) == 0 {
+		features = []string{"none"}
+	}
+
+	fmt.Fprintln(w, "Features:               "+strings.Join(features, ", "))
+}
+
+// manifestFeatures returns the features used by the manifest, like the encryption methods and the DRM systems.
+func manifestFeatures(manifest *hls.Manifest) []string {
+	var features []string
+	add := func(feature string) {
+		if !slices.Contains(features, feature) {
+			features = append(features, feature)
+		}
+	}
+
+	if len(manifest.SegmentGroups) > 1 {
+		add("discontinuities")
+	}
+
+	for _, group := range manifest.SegmentGroups {
+		for _, segment := range group.Segments {
+			if segment.Title != "" {
+				add("segment titles")
+			}
+
+			for _, key := range segment.Keys {
+				add("encryption (" + string(key.Method) + ")")
+				if key.DRM() {
+					add("DRM (" + key.KeyFormat + ")")
+				}
+			}
+		}
+	}
+
+	return features
+}
+
+// printMaster prints the summary of a master playlist.
+func printMaster(w io.Writer, master *hls.MasterManifest) {
+	fmt.Fprintln(w, "Type:         master playlist")
+	fmt.Fprintln(w, "Version:      "+strconv.Itoa(int(max(master.Version, 1))))
+	fmt.Fprintln(w, "Renditions:   "+strconv.Itoa(len(master.Renditions)))
+	fmt.Fprintln(w, "Session keys: "+strconv.Itoa(len(master.SessionKeys)))
+	fmt.Fprintln(w, "Variants:     "+strconv.Itoa(len(master.Variants)))
+
+	for _, variant := range master.Variants {
+		details := []string{strconv.Itoa(variant.Bandwidth/1000) + " kbit/s"}
+		for _, detail := range []string{variant.Resolution, variant.Codecs, string(variant.Projection), string(variant.Stereo)} {
+			if detail != "" {
+				details = append(details, detail)
+			}
+		}
+
+		fmt.Fprintln(w, "  "+variant.URI+": "+strings.Join(details, ", "))
+	}
+
+	if len(master.IFrameVariants) > 0 {
+		fmt.Fprintln(w, "I-frames:     "+strconv.Itoa(len(master.IFrameVariants)))
+	}
+}
+
+// formatSeconds formats a duration in seconds with millisecond precision.
+func formatSeconds(seconds float64) string {
+	return strconv.FormatFloat(seconds, 'f', 3, 64) + "s"
+}
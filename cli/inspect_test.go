@@ -0,0 +1,55 @@
+package cli
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestInspect(t *testing.T) {
+	code, stdout, _ := run(t, "inspect", "../testdata/stream0.m3u8")
+	if code != 0 {
+		t.Errorf("expected a valid playlist, got %d", code)
+	}
+
+	for _, expected := range []string{"media playlist (VOD)", "Duration:               7.650s", "Segments:               2 (min 3.483s"} {
+		if !strings.Contains(stdout, expected) {
+			t.Errorf("expected the summary to contain %q, got %s", expected, stdout)
+		}
+	}
+
+	origin := httptest.NewServer(http.FileServer(http.Dir("../testdata")))
+	defer origin.Close()
+
+	if code, stdout, _ := run(t, "inspect", origin.URL+"/master.m3u8"); code != 0 || !strings.Contains(stdout, "Variants:     4") {
+		t.Errorf("expected the summary of the master playlist, got %d and %s", code, stdout)
+	}
+}
+
+func TestInspectIssues(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "broken.m3u8")
+	os.WriteFile(path, []byte("#EXTM3U\n#EXT-X-TARGETDURATION:4\n#EXT-X-MEDIA-SEQUENCE:10\n#EXTINF:4,\n10.ts\n#EXTINF:4,\n11.ts\n"), 0o644)
+
+	code, stdout, _ := run(t, "inspect", path)
+	if code != 0 || !strings.Contains(stdout, "warning: live playlist shorter than three target durations") {
+		t.Errorf("expected a playlist with warnings only to pass, got %d and %s", code, stdout)
+	}
+
+	if code, _, _ := run(t, "inspect", "-strict", path); code != 1 {
+		t.Errorf("expected the warnings to fail in strict mode, got %d", code)
+	}
+
+	os.WriteFile(path, []byte("#EXTM3U\n#EXT-X-TARGETDURATION:4\n#EXT-X-MEDIA-SEQUENCE:10\n#EXTINF:6,\n10.ts\n#EXT-X-ENDLIST\n"), 0o644)
+	code, stdout, stderr := run(t, "inspect", path)
+	if code != 1 || !strings.Contains(stdout, "error: segment 10 (10.ts): duration 6s exceeds the target duration of 4s") || !strings.Contains(stderr, ErrInvalidPlaylist.Error()) {
+		t.Errorf("expected the segment error, got %d and %s", code, stdout)
+	}
+
+	os.WriteFile(path, []byte("#EXTM3U\n#EXTINF:4,\n"), 0o644)
+	if code, stdout, _ := run(t, "inspect", path); code != 1 || !strings.Contains(stdout, "line") {
+		t.Errorf("expected the parse error with its line, got %d and %s", code, stdout)
+	}
+}
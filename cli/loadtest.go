@@ -0,0 +1,60 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"time"
+
+	"vrmix/loadtest"
+)
+
+// ErrErrorRate indicates that the requests of the load test failed more often than allowed.
+var ErrErrorRate = errors.New("error rate above the maximum")
+
+// loadtestCommand simulates players against a stream.
+var loadtestCommand = command{
+	name:    "loadtest",
+	usage:   "[flags] <url>",
+	summary: "Simulate concurrent players against a stream, reporting the latencies and errors, to size the hardware before an event.",
+	run:     runLoadtest,
+}
+
+// runLoadtest runs the loadtest command.
+func runLoadtest(ctx context.Context, flags *flag.FlagSet, args []string, stdout io.Writer) error {
+	clients := flags.Int("clients", 10, "players simulated at once")
+	duration := flags.Duration("duration", time.Minute, "time the players keep playing")
+	rampUp := flags.Duration("ramp-up", 0, "time over which the players start evenly")
+	seek := flags.Float64("seek", 0, "probability of each player to seek after each segment of a VOD")
+	speed := flags.Float64("speed", 1, "playback speed pacing the requests")
+	maxErrorRate := flags.Float64("max-error-rate", 1, "fraction of failed requests above which the command fails")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 || !isURL(flags.Arg(0)) || *clients < 1 || *speed <= 0 {
+		return ErrUsage
+	}
+
+	fmt.Fprintf(stdout, "simulating %d players for %s\n", *clients, *duration)
+	report, err := loadtest.Run(ctx, loadtest.Config{
+		URL:      flags.Arg(0),
+		Clients:  *clients,
+		Duration: *duration,
+		RampUp:   *rampUp,
+		Seek:     *seek,
+		Speed:    *speed,
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprint(stdout, report.String())
+	if rate := max(report.Playlists.ErrorRate(), report.Segments.ErrorRate()); rate > *maxErrorRate {
+		return fmt.Errorf("%w: %.2f%%", ErrErrorRate, 100*rate)
+	}
+
+	return nil
+}
@@ -0,0 +1,24 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoadtest(t *testing.T) {
+	server := newVODServer(t)
+
+	code, stdout, stderr := run(t, "loadtest", "-clients", "2", "-duration", "100ms", "-speed", "100", server.URL+"/master.m3u8")
+	if code != 0 || !strings.Contains(stdout, "simulating 2 players") || !strings.Contains(stdout, "playlist") {
+		t.Errorf("expected the report, got %d, %s and %s", code, stdout, stderr)
+	}
+
+	code, _, stderr = run(t, "loadtest", "-duration", "100ms", "-max-error-rate", "0", server.URL+"/missing.m3u8")
+	if code != 1 || !strings.Contains(stderr, ErrErrorRate.Error()) {
+		t.Errorf("expected the error rate to fail the command, got %d and %s", code, stderr)
+	}
+
+	if code, _, _ := run(t, "loadtest", "master.m3u8"); code != 2 {
+		t.Errorf("expected a URL to be required, got %d", code)
+	}
+}
@@ -0,0 +1,293 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"path"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"vrmix/config"
+	"vrmix/control"
+	"vrmix/hls"
+	"vrmix/logging"
+	"vrmix/server"
+	"vrmix/signer"
+	"vrmix/source"
+)
+
+// DevChannel is the ID of the unauthenticated channel served in development mode.
+const DevChannel = "dev"
+
+// serveCommand runs the server.
+var serveCommand = command{
+	name:    "serve",
+	usage:   "[flags]",
+	summary: "Serve the channels until interrupted, shutting down gracefully.",
+	run:     runServe,
+}
+
+// runServe runs the serve command.
+func runServe(ctx context.Context, flags *flag.FlagSet, args []string, stdout io.Writer) error {
+	layers := configFlags(flags)
+	dev := flags.Bool("dev", false, "serve an unauthenticated channel at /channels/"+DevChannel+"/playlist.m3u8, for quick testing")
+	devRefs := flags.String("dev-source", "", "comma separated references played by the development channel, the next one used when one fails")
+	media := flags.String("media", ".", "directory of the local media referenced by the development channel")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 0 || (*dev && *devRefs == "") {
+		return ErrUsage
+	}
+
+	resolved := layers()
+	cfg, err := config.Resolve(resolved)
+	if err != nil {
+		return err
+	}
+
+	limits := server.NewRateLimiter(cfg.RateLimit())
+	var reloader *config.Reloader
+	if resolved.File != "" {
+		reloader = config.NewReloader(resolved, cfg)
+		reloader.Apply = func(ctx context.Context, cfg config.Config, changes []config.Change) error {
+			limits.SetConfig(cfg.RateLimit())
+			return nil
+		}
+		reloader.Authorize = func(r *http.Request) bool {
+			return reloader.Current().Auth.Authorized(r)
+		}
+	}
+
+	mux := http.NewServeMux()
+	health := server.NewHealth(5 * time.Second)
+	health.Register(mux)
+
+	if reloader != nil {
+		mux.Handle("/admin/reload", reloader)
+		go reloadOnHangup(ctx, reloader)
+	}
+
+	channels, err := newChannels(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer channels.close()
+
+	if err := channels.start(ctx, cfg); err != nil {
+		return err
+	}
+
+	authorize := cfg.Auth.Authorized
+	if reloader != nil {
+		authorize = reloader.Authorize
+	}
+
+	mux.Handle("/api/", http.StripPrefix("/api", authorized(control.NewHandler(channels.service), authorize)))
+
+	streams := &server.StreamHandler{Streams: channels.controller, Cache: channels.cache, Cached: true, TTL: cfg.Cache.TTL, Redirect: cfg.Cache.Redirect}
+	if cfg.Auth.SigningSecret != "" {
+		urls := signer.New([]byte(cfg.Auth.SigningSecret))
+		streams.Streams = &signedStreams{Streams: channels.controller, signer: urls, expiry: cfg.Auth.URLExpiry}
+		mux.Handle("/streams/", server.Chain(streams, verifyStreams(urls)))
+		mux.Handle("GET /admin/streams/{id}/url", streamURLHandler(urls, cfg.Auth.URLExpiry, authorize))
+	} else {
+		mux.Handle("/streams/", streams)
+	}
+
+	if *dev {
+		files := &source.FileSource{Root: *media}
+		if err := files.Refresh(); err != nil {
+			return err
+		}
+
+		channel := &devChannel{failover: &source.Failover{
+			Source: source.Multi{&source.HTTPSource{}, files},
+			Refs:   strings.Split(*devRefs, ","),
+		}}
+
+		go func() {
+			if err := channel.failover.Run(ctx); err != nil && ctx.Err() == nil {
+				logging.Or(nil).Error("development channel stopped", slog.String(logging.ChannelKey, DevChannel), logging.Err(err))
+			}
+		}()
+
+		mux.Handle("/channels/"+DevChannel+"/", http.StripPrefix("/channels/"+DevChannel, channel))
+	}
+
+	serverConfig := server.Config{Addr: cfg.Server.Addr, Handler: server.Chain(mux, limits.Middleware()), DrainPeriod: cfg.Server.DrainPeriod}
+	scheme := "http"
+	if cfg.Server.TLSCert != "" {
+		certificates, err := server.NewCertReloader(cfg.Server.TLSCert, cfg.Server.TLSKey)
+		if err != nil {
+			return err
+		}
+
+		serverConfig.TLSConfig, scheme = server.NewTLSConfig(certificates), "https"
+	}
+
+	listener, err := net.Listen("tcp", cfg.Server.Addr)
+	if err != nil {
+		return err
+	}
+
+	srv := server.New(serverConfig)
+	health.AddCheck("server", srv.ReadinessCheck())
+
+	fmt.Fprintln(stdout, "listening on "+scheme+"://"+listener.Addr().String())
+	if *dev {
+		fmt.Fprintln(stdout, "development channel at "+scheme+"://"+listener.Addr().String()+"/channels/"+DevChannel+"/playlist.m3u8")
+	}
+
+	err = srv.RunListener(ctx, listener, cfg.Server.ShutdownTimeout)
+
+	// The segments being produced are abandoned once the shutdown timeout elapses, as no player waits for them anymore.
+	closeCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+	channels.scheduler.Close(closeCtx)
+
+	fmt.Fprintln(stdout, "stopped")
+	return err
+}
+
+// authorized returns a handler answering 401 to the requests not authorized by the function.
+func authorized(next http.Handler, authorize func(r *http.Request) bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !authorize(r) {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// devChannel serves the live playlist of a failover chain without authentication, with routes relative to the mount point:
+//
+//	GET /playlist.m3u8
+//	GET /segments/{name}
+type devChannel struct {
+	failover *source.Failover
+}
+
+// manifest returns the current playlist of the channel.
+func (c *devChannel) manifest() (hls.Manifest, error) {
+	return hls.ParseHlsManifest(strings.TrimRight(c.failover.Playlist(), "\n"))
+}
+
+// ServeHTTP serves the playlist, or a segment it references.
+func (c *devChannel) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	manifest, err := c.manifest()
+	if err != nil || manifest.SegmentCount() == 0 {
+		http.Error(w, "channel is starting", http.StatusServiceUnavailable)
+		return
+	}
+
+	if r.URL.Path == "/playlist.m3u8" {
+		c.servePlaylist(w, r, manifest)
+		return
+	}
+
+	name, found := strings.CutPrefix(r.URL.Path, "/segments/")
+	if !found {
+		http.NotFound(w, r)
+		return
+	}
+
+	// Only the segments of the current playlist are served, so the channel cannot be used to fetch arbitrary URIs.
+	sequence, err := strconv.ParseUint(strings.TrimSuffix(name, path.Ext(name)), 10, 32)
+	index := int(sequence) - int(manifest.MediaSequence)
+	if err != nil || index < 0 {
+		http.NotFound(w, r)
+		return
+	}
+
+	for _, group := range manifest.SegmentGroups {
+		if index >= len(group.Segments) {
+			index -= len(group.Segments)
+			continue
+		}
+
+		c.serveSegment(w, r, group.Segments[index].Path)
+		return
+	}
+
+	http.NotFound(w, r)
+}
+
+// servePlaylist serves the playlist with the segments named by their media sequence numbers.
+func (c *devChannel) servePlaylist(w http.ResponseWriter, r *http.Request, manifest hls.Manifest) {
+	sequence := uint64(manifest.MediaSequence)
+	for i := range manifest.SegmentGroups {
+		segments := manifest.SegmentGroups[i].Segments
+		for j := range segments {
+			u, err := url.Parse(segments[j].Path)
+			ext := ".ts"
+			if err == nil && path.Ext(u.Path) != "" {
+				ext = path.Ext(u.Path)
+			}
+
+			segments[j].Path = "segments/" + strconv.FormatUint(sequence, 10) + ext
+			sequence++
+		}
+	}
+
+	server.ServePlaylist(w, r, []byte(manifest.String()))
+}
+
+// serveSegment serves a segment opened through the source of the channel.
+func (c *devChannel) serveSegment(w http.ResponseWriter, r *http.Request, uri string) {
+	body, err := c.failover.OpenSegment(r.Context(), uri)
+	if errors.Is(err, source.ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+		return
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+		return
+	}
+
+	server.ServeSegment(w, r, uri, time.Time{}, bytes.NewReader(data), int64(len(data)))
+}
+
+// reloadOnHangup reloads the configuration each time the process receives SIGHUP, until the context is done.
+func reloadOnHangup(ctx context.Context, reloader *config.Reloader) {
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+	defer signal.Stop(hangups)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangups:
+			if _, err := reloader.Reload(ctx); err != nil {
+				logging.Or(nil).Error("configuration reload failed", logging.Err(err))
+			}
+		}
+	}
+}
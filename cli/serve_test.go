@@ -0,0 +1,222 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// syncBuffer is a buffer written by the server goroutine while the test reads it
+type syncBuffer struct {
+	mutex  sync.Mutex
+	buffer bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return b.buffer.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return b.buffer.String()
+}
+
+// get fetches the URL until it answers 200 OK or the deadline passes
+func get(t *testing.T, url string) string {
+	t.Helper()
+
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+		resp, err := http.Get(url)
+		if err != nil {
+			continue
+		}
+
+		data, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusOK {
+			return string(data)
+		}
+	}
+
+	t.Fatalf("expected %s to be served", url)
+	return ""
+}
+
+// serve runs the serve command until stopped, returning the base URL it listens on
+func serve(t *testing.T, args ...string) (string, func() (int, string, string)) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var stdout, stderr syncBuffer
+	done := make(chan int, 1)
+	go func() {
+		done <- Run(ctx, append([]string{"serve"}, args...), &stdout, &stderr)
+	}()
+
+	stop := func() (int, string, string) {
+		cancel()
+		code := <-done
+		return code, stdout.String(), stderr.String()
+	}
+
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if line, found := strings.CutPrefix(stdout.String(), "listening on "); found {
+			return strings.TrimSpace(strings.SplitN(line, "\n", 2)[0]), stop
+		}
+	}
+
+	_, _, output := stop()
+	t.Fatalf("expected the server to listen, got %s", output)
+	return "", nil
+}
+
+func TestServeDev(t *testing.T) {
+	media := t.TempDir()
+	os.WriteFile(filepath.Join(media, "stream.m3u8"), []byte("#EXTM3U\n#EXT-X-TARGETDURATION:4\n#EXTINF:4,\n0.ts\n#EXTINF:4,\n1.ts\n#EXT-X-ENDLIST\n"), 0o644)
+	os.WriteFile(filepath.Join(media, "0.ts"), []byte("segment"), 0o644)
+
+	base, stop := serve(t, "-addr", "127.0.0.1:0", "-dev", "-dev-source", "stream.m3u8", "-media", media, "-shutdown-timeout", "1s")
+
+	if body := get(t, base+"/healthz"); !strings.Contains(body, "server") {
+		t.Errorf("expected the health report, got %s", body)
+	}
+
+	playlist := get(t, base+"/channels/"+DevChannel+"/playlist.m3u8")
+	if !strings.Contains(playlist, "\nsegments/0.ts\n") {
+		t.Errorf("expected the segments to be served by the channel, got %s", playlist)
+	}
+
+	if segment := get(t, base+"/channels/"+DevChannel+"/segments/0.ts"); segment != "segment" {
+		t.Errorf("expected the segment, got %q", segment)
+	}
+
+	if resp, err := http.Get(base + "/channels/" + DevChannel + "/segments/7.ts"); err != nil || resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected segments out of the playlist to be rejected, got %v", resp.Status)
+	}
+
+	if code, stdout, stderr := stop(); code != 0 || !strings.Contains(stdout, "stopped") {
+		t.Errorf("expected a graceful shutdown, got %d and %s", code, stderr)
+	}
+}
+
+func TestServeUsage(t *testing.T) {
+	if code, _, _ := run(t, "serve", "-dev"); code != 2 {
+		t.Errorf("expected the development mode to require a source, got %d", code)
+	}
+
+	if code, _, stderr := run(t, "serve", "-tls-cert", "cert.pem"); code != 1 || !strings.Contains(stderr, "server.tls_key: required with server.tls_cert") {
+		t.Errorf("expected the certificate to require its key, got %d and %s", code, stderr)
+	}
+}
+
+func TestServeInvalidConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vrmix.yaml")
+	os.WriteFile(path, []byte("server:\n  addr: \":0\"\nlimits:\n  segment_rate: -1\n"), 0o600)
+
+	code, _, stderr := run(t, "serve", "-config", path)
+	if code != 1 || !strings.Contains(stderr, "limits.segment_rate (line 4)") {
+		t.Errorf("expected the offending key to be reported, got %d and %s", code, stderr)
+	}
+}
+
+func TestServeReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vrmix.yaml")
+	os.WriteFile(path, []byte("auth:\n  api_tokens: [token]\nlimits:\n  segment_rate: 10\n"), 0o600)
+
+	base, stop := serve(t, "-addr", "127.0.0.1:0", "-config", path)
+	defer stop()
+
+	os.WriteFile(path, []byte("server:\n  addr: \":9999\"\n  drain_period: 5s\nauth:\n  api_tokens: [token]\nlimits:\n  segment_rate: 20\n"), 0o600)
+	req, _ := http.NewRequest(http.MethodPost, base+"/admin/reload", nil)
+	req.Header.Set("Authorization", "Bearer token")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), `"applied":[{"key":"limits.segment_rate"`) || !strings.Contains(string(body), `"restart":[{"key":"server.drain_period"`) {
+		t.Errorf("expected the limits to be applied, the drain period to require a restart and the address to keep the flag, got %d and %s", resp.StatusCode, body)
+	}
+}
+
+func TestServeChannels(t *testing.T) {
+	dir := t.TempDir()
+	media := filepath.Join(dir, "media")
+	os.Mkdir(media, 0o755)
+	os.WriteFile(filepath.Join(media, "stream.m3u8"), []byte("#EXTM3U\n#EXT-X-TARGETDURATION:1\n#EXTINF:0.1,\n0.ts\n#EXTINF:0.1,\n1.ts\n#EXT-X-ENDLIST\n"), 0o644)
+	os.WriteFile(filepath.Join(media, "0.ts"), []byte("segment"), 0o644)
+	os.WriteFile(filepath.Join(media, "1.ts"), []byte("segment"), 0o644)
+
+	path := filepath.Join(dir, "vrmix.yaml")
+	os.WriteFile(path, []byte("sources:\n  - name: media\n    type: file\n    root: "+media+"\nchannels:\n  - id: news\n    sources: [media]\n    queue: [stream.m3u8]\n    loop: true\nauth:\n  signing_secret: 0123456789abcdef\n  api_tokens: [token]\n"), 0o600)
+
+	base, stop := serve(t, "-addr", "127.0.0.1:0", "-config", path, "-shutdown-timeout", "1s")
+	defer stop()
+
+	if resp, err := http.Get(base + "/streams/news/playlist.m3u8"); err != nil || resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected the unsigned playlist to be rejected, got %v", resp.Status)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, base+"/admin/streams/news/url", nil)
+	req.Header.Set("Authorization", "Bearer token")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signed, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	var segment string
+	for deadline := time.Now().Add(5 * time.Second); segment == "" && time.Now().Before(deadline); time.Sleep(50 * time.Millisecond) {
+		for _, line := range strings.Split(get(t, base+strings.TrimSpace(string(signed))), "\n") {
+			if line != "" && !strings.HasPrefix(line, "#") {
+				segment = line
+			}
+		}
+	}
+
+	if !strings.Contains(segment, "&sig=") {
+		t.Fatalf("expected the playlist to list signed segments, got %q", segment)
+	}
+
+	if data := get(t, base+"/streams/news/"+segment); data != "segment" {
+		t.Errorf("expected the segment of the queued media, got %q", data)
+	}
+
+	name, _, _ := strings.Cut(segment, "?")
+	if resp, err := http.Get(base + "/streams/news/" + name); err != nil || resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected the unsigned segment to be rejected, got %v", resp.Status)
+	}
+
+	if resp, err := http.Get(base + "/api/channels/news"); err != nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected the unauthenticated API request to be rejected, got %v", resp.Status)
+	}
+
+	req, _ = http.NewRequest(http.MethodGet, base+"/api/channels/news/queue", nil)
+	req.Header.Set("Authorization", "Bearer token")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	queue, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(queue), `"source":"stream.m3u8"`) {
+		t.Errorf("expected the queue of the channel over the API, got %v %s", resp.Status, queue)
+	}
+}
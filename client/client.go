@@ -0,0 +1,183 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"vrmix/control"
+)
+
+// APIError records a failed request to the management API.
+type APIError struct {
+	StatusCode int    // HTTP status code of the response
+	Message    string // Reason of the failure reported by the server
+}
+
+func (e *APIError) Error() string {
+	return "vrmix api: " + strconv.Itoa(e.StatusCode) + ": " + e.Message
+}
+
+// Unwrap returns the control error matching the status code, so callers can use errors.Is with control.ErrNotFound and similar.
+func (e *APIError) Unwrap() error {
+	switch e.StatusCode {
+	case http.StatusNotFound:
+		return control.ErrNotFound
+	case http.StatusConflict:
+		return control.ErrAlreadyExists
+	case http.StatusBadRequest:
+		return control.ErrInvalidArgument
+	default:
+		return nil
+	}
+}
+
+// Client calls the management REST API of a VRMix server, implementing control.Service so it can be used wherever a local service is expected.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+
+	// Header holds headers added to every request, like Authorization.
+	Header http.Header
+}
+
+var _ control.Service = (*Client)(nil)
+
+// New creates a new Client for the API mounted at the base URL, using http.DefaultClient when httpClient is nil.
+func New(baseURL string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	return &Client{baseURL: strings.TrimSuffix(baseURL, "/"), httpClient: httpClient, Header: http.Header{}}
+}
+
+// do sends a request with the body encoded as JSON and decodes the response into result, if not nil.
+func (c *Client) do(ctx context.Context, method string, path string, body any, result any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+
+	for key, values := range c.Header {
+		req.Header[key] = values
+	}
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		var errorResponse control.ErrorResponse
+		if err := json.NewDecoder(resp.Body).Decode(&errorResponse); err != nil || errorResponse.Error == "" {
+			errorResponse.Error = http.StatusText(resp.StatusCode)
+		}
+
+		return &APIError{StatusCode: resp.StatusCode, Message: errorResponse.Error}
+	}
+
+	if result == nil {
+		return nil
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return errors.Join(errors.New("vrmix api: invalid response"), err)
+	}
+
+	return nil
+}
+
+// channelPath returns the escaped path of a channel resource.
+func channelPath(id string, parts ...string) string {
+	path := "/channels/" + url.PathEscape(id)
+	for _, part := range parts {
+		path += "/" + url.PathEscape(part)
+	}
+
+	return path
+}
+
+// ListChannels returns every channel.
+func (c *Client) ListChannels(ctx context.Context) ([]control.Channel, error) {
+	var channels []control.Channel
+	err := c.do(ctx, http.MethodGet, "/channels", nil, &channels)
+	return channels, err
+}
+
+// GetChannel returns the channel with the ID.
+func (c *Client) GetChannel(ctx context.Context, id string) (control.Channel, error) {
+	var channel control.Channel
+	err := c.do(ctx, http.MethodGet, channelPath(id), nil, &channel)
+	return channel, err
+}
+
+// CreateChannel creates an idle channel with the ID and name.
+func (c *Client) CreateChannel(ctx context.Context, id string, name string) (control.Channel, error) {
+	var channel control.Channel
+	err := c.do(ctx, http.MethodPost, "/channels", control.CreateChannelRequest{ID: id, Name: name}, &channel)
+	return channel, err
+}
+
+// DeleteChannel stops and removes the channel with the ID.
+func (c *Client) DeleteChannel(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, channelPath(id), nil, nil)
+}
+
+// ListQueue returns the items queued on the channel, starting with the item being played.
+func (c *Client) ListQueue(ctx context.Context, channel string) ([]control.QueueItem, error) {
+	var items []control.QueueItem
+	err := c.do(ctx, http.MethodGet, channelPath(channel, "queue"), nil, &items)
+	return items, err
+}
+
+// Enqueue appends the item to the channel queue, returning it with its assigned ID.
+func (c *Client) Enqueue(ctx context.Context, channel string, item control.QueueItem) (control.QueueItem, error) {
+	var result control.QueueItem
+	err := c.do(ctx, http.MethodPost, channelPath(channel, "queue"), item, &result)
+	return result, err
+}
+
+// RemoveQueueItem removes the item from the channel queue.
+func (c *Client) RemoveQueueItem(ctx context.Context, channel string, id string) error {
+	return c.do(ctx, http.MethodDelete, channelPath(channel, "queue", id), nil, nil)
+}
+
+// Skip stops the item being played, advancing the channel to the next item.
+func (c *Client) Skip(ctx context.Context, channel string) error {
+	return c.do(ctx, http.MethodPost, channelPath(channel, "skip"), nil, nil)
+}
+
+// ListSessions returns the sessions watching the channel, or every session when the channel is empty.
+func (c *Client) ListSessions(ctx context.Context, channel string) ([]control.Session, error) {
+	var sessions []control.Session
+
+	path := "/sessions"
+	if channel != "" {
+		path = channelPath(channel, "sessions")
+	}
+
+	err := c.do(ctx, http.MethodGet, path, nil, &sessions)
+	return sessions, err
+}
@@ -0,0 +1,123 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"vrmix/control"
+)
+
+// fakeService is a control service keeping a single channel
+type fakeService struct {
+	control.Service
+	channel control.Channel
+	queue   []control.QueueItem
+	auth    string
+}
+
+func (s *fakeService) GetChannel(ctx context.Context, id string) (control.Channel, error) {
+	if id != s.channel.ID {
+		return control.Channel{}, control.ErrNotFound
+	}
+
+	return s.channel, nil
+}
+
+func (s *fakeService) CreateChannel(ctx context.Context, id string, name string) (control.Channel, error) {
+	if id == s.channel.ID {
+		return control.Channel{}, control.ErrAlreadyExists
+	}
+
+	return control.Channel{ID: id, Name: name, State: control.ChannelIdle}, nil
+}
+
+func (s *fakeService) Enqueue(ctx context.Context, channel string, item control.QueueItem) (control.QueueItem, error) {
+	item.ID = "1"
+	s.queue = append(s.queue, item)
+	return item, nil
+}
+
+func (s *fakeService) ListQueue(ctx context.Context, channel string) ([]control.QueueItem, error) {
+	return s.queue, nil
+}
+
+func (s *fakeService) Skip(ctx context.Context, channel string) error {
+	s.queue = nil
+	return nil
+}
+
+// newTestClient starts the REST API of a fake service and returns a client for it
+func newTestClient(t *testing.T) (*Client, *fakeService) {
+	service := &fakeService{channel: control.Channel{ID: "main", Name: "Main", State: control.ChannelIdle}}
+
+	server := httptest.NewServer(control.NewHandler(service))
+	t.Cleanup(server.Close)
+
+	return New(server.URL+"/", server.Client()), service
+}
+
+func TestClient(t *testing.T) {
+	c, _ := newTestClient(t)
+	ctx := context.Background()
+
+	channel, err := c.GetChannel(ctx, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if channel.Name != "Main" {
+		t.Errorf("expected channel Main, got %s", channel.Name)
+	}
+
+	created, err := c.CreateChannel(ctx, "second", "Second")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if created.ID != "second" || created.State != control.ChannelIdle {
+		t.Errorf("expected idle channel second, got %s and %s", created.ID, created.State)
+	}
+
+	item, err := c.Enqueue(ctx, "main", control.QueueItem{Source: "https://origin.example/stream.m3u8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if item.ID != "1" {
+		t.Errorf("expected item 1, got %s", item.ID)
+	}
+
+	items, err := c.ListQueue(ctx, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(items) != 1 || items[0].Source != "https://origin.example/stream.m3u8" {
+		t.Errorf("expected the enqueued item, got %v", items)
+	}
+
+	if err := c.Skip(ctx, "main"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	c, _ := newTestClient(t)
+	ctx := context.Background()
+
+	_, err := c.GetChannel(ctx, "missing")
+	if !errors.Is(err, control.ErrNotFound) {
+		t.Errorf("expected not found, got %v", err)
+	}
+
+	var apiError *APIError
+	if !errors.As(err, &apiError) || apiError.StatusCode != 404 {
+		t.Errorf("expected api error with status 404, got %v", err)
+	}
+
+	if _, err := c.CreateChannel(ctx, "main", "Main"); !errors.Is(err, control.ErrAlreadyExists) {
+		t.Errorf("expected already exists, got %v", err)
+	}
+}
@@ -0,0 +1,2 @@
+// Package client contains a typed Go client for the VRMix management REST API.
+package client
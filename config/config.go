@@ -0,0 +1,242 @@
+package config
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"vrmix/profile"
+	"vrmix/secret"
+	"vrmix/server"
+)
+
+// Config represents the configuration file of a deployment.
+type Config struct {
+	Server   Server    `yaml:"server" toml:"server"`     // HTTP server settings
+	Sources  []Source  `yaml:"sources" toml:"sources"`   // Sources the channels play from
+	Channels []Channel `yaml:"channels" toml:"channels"` // Channels served
+	Cache    Cache     `yaml:"cache" toml:"cache"`       // Cache backend of the segments
+	Profiles []Profile `yaml:"profiles" toml:"profiles"` // Encoding profiles added to the presets
+	Auth     Auth      `yaml:"auth" toml:"auth"`         // Authentication of the players and the API
+	Limits   Limits    `yaml:"limits" toml:"limits"`     // Limits applied to each client
+}
+
+// Server represents the settings of the HTTP server.
+type Server struct {
+	Addr            string        `yaml:"addr" toml:"addr"`                         // Address to listen on, defaults to ":8080"
+	TLSCert         string        `yaml:"tls_cert" toml:"tls_cert"`                 // PEM certificate chain served over HTTPS, empty to serve plain HTTP
+	TLSKey          string        `yaml:"tls_key" toml:"tls_key"`                   // PEM private key of the certificate
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout"` // Maximum time of the graceful shutdown, defaults to 30 seconds
+	DrainPeriod     time.Duration `yaml:"drain_period" toml:"drain_period"`         // Maximum time the existing sessions keep being served after the shutdown starts
+}
+
+// Source represents a named source of media, the fields used depending on its type.
+type Source struct {
+	Name      string `yaml:"name" toml:"name"`             // Name referenced by the channels
+	Type      string `yaml:"type" toml:"type"`             // Type of the source, one of SourceTypes
+	URL       string `yaml:"url" toml:"url"`               // URL of the server, for the webdav, jellyfin, plex and crawler sources
+	Root      string `yaml:"root" toml:"root"`             // Directory holding the media, for the file source
+	Addr      string `yaml:"addr" toml:"addr"`             // Address of the server, for the sftp and ftp sources
+	Bucket    string `yaml:"bucket" toml:"bucket"`         // Bucket holding the media, for the s3 source
+	Region    string `yaml:"region" toml:"region"`         // Region of the bucket, for the s3 source
+	Endpoint  string `yaml:"endpoint" toml:"endpoint"`     // Endpoint of S3 compatible storage, empty for AWS
+	Prefix    string `yaml:"prefix" toml:"prefix"`         // Key prefix holding the media, for the s3 source
+	User      string `yaml:"user" toml:"user"`             // User to authenticate as
+	Password  string `yaml:"password" toml:"password"`     // Password of the user, may reference a secret
+	Token     string `yaml:"token" toml:"token"`           // API token, may reference a secret
+	AccessKey string `yaml:"access_key" toml:"access_key"` // Access key ID, for the s3 source
+	SecretKey string `yaml:"secret_key" toml:"secret_key"` // Secret access key, may reference a secret
+}
+
+// SourceTypes are the supported types of sources.
+var SourceTypes = []string{"http", "file", "s3", "sftp", "ftp", "webdav", "jellyfin", "plex", "crawler"}
+
+// Channel represents a channel served with its initial queue.
+type Channel struct {
+	ID      string   `yaml:"id" toml:"id"`           // Unique ID of the channel, used in the stream URLs
+	Name    string   `yaml:"name" toml:"name"`       // Human readable name of the channel, defaults to the ID
+	Sources []string `yaml:"sources" toml:"sources"` // Names of the sources resolving the queue, tried in order
+	Queue   []string `yaml:"queue" toml:"queue"`     // References of the media queued when the channel starts
+	Profile string   `yaml:"profile" toml:"profile"` // Encoding profile of the channel, empty to select it from the layout of each item
+	Loop    bool     `yaml:"loop" toml:"loop"`       // Whether the queue starts over once played
+}
+
+// Cache represents the cache backend of the segments.
+type Cache struct {
+	Backend  string        `yaml:"backend" toml:"backend"`     // Backend of the cache, one of CacheBackends, defaults to "memory"
+	Dir      string        `yaml:"dir" toml:"dir"`             // Directory of the disk backend
+	Source   string        `yaml:"source" toml:"source"`       // Name of the s3 source storing the s3 backend
+	MaxBytes int64         `yaml:"max_bytes" toml:"max_bytes"` // Size above which the least recently used segments are evicted, zero for no limit
+	TTL      time.Duration `yaml:"ttl" toml:"ttl"`             // Time a segment is kept, zero to keep it until evicted
+	Redirect time.Duration `yaml:"redirect" toml:"redirect"`   // Time the presigned URLs the players are redirected to for the hits of the s3 backend are valid, zero to serve the segments
+}
+
+// CacheBackends are the supported cache backends.
+var CacheBackends = []string{"memory", "disk", "s3"}
+
+// Profile represents an encoding profile derived from a preset.
+type Profile struct {
+	Name    string        `yaml:"name" toml:"name"`         // Name of the profile, selectable by the channels
+	Base    string        `yaml:"base" toml:"base"`         // Name of the preset the profile derives from
+	Codec   string        `yaml:"codec" toml:"codec"`       // Video encoder of ffmpeg, empty to keep the one of the preset
+	Preset  string        `yaml:"preset" toml:"preset"`     // Preset of the encoder, empty to keep the one of the preset
+	GOP     time.Duration `yaml:"gop" toml:"gop"`           // Interval between keyframes, zero to keep the one of the preset
+	MaxRate float64       `yaml:"max_rate" toml:"max_rate"` // Peak bitrate as a multiple of the target bitrate, zero to keep the one of the preset
+}
+
+// Auth represents the authentication of the players and the API.
+type Auth struct {
+	SigningSecret string        `yaml:"signing_secret" toml:"signing_secret"` // Secret signing the stream URLs, may reference a secret, empty to serve unsigned URLs
+	URLExpiry     time.Duration `yaml:"url_expiry" toml:"url_expiry"`         // Validity of the signed URLs, defaults to six hours
+	APITokens     []string      `yaml:"api_tokens" toml:"api_tokens"`         // Bearer tokens accepted by the management API, may reference secrets
+}
+
+// Limits represents the limits applied to each client, the zero fields using the defaults of server.DefaultRateLimitConfig.
+type Limits struct {
+	PlaylistRate  float64       `yaml:"playlist_rate" toml:"playlist_rate"`   // Playlist requests per second allowed for each client
+	PlaylistBurst int           `yaml:"playlist_burst" toml:"playlist_burst"` // Playlist requests allowed above the rate at once
+	SegmentRate   float64       `yaml:"segment_rate" toml:"segment_rate"`     // Segment requests per second allowed for each client
+	SegmentBurst  int           `yaml:"segment_burst" toml:"segment_burst"`   // Segment requests allowed above the rate at once
+	BanThreshold  int           `yaml:"ban_threshold" toml:"ban_threshold"`   // Rejected requests within the ban window before the client is banned
+	BanWindow     time.Duration `yaml:"ban_window" toml:"ban_window"`         // Window used to count rejected requests
+	BanDuration   time.Duration `yaml:"ban_duration" toml:"ban_duration"`     // Time a client stays banned
+	MaxSessions   int           `yaml:"max_sessions" toml:"max_sessions"`     // Sessions allowed on each channel, zero for no limit
+}
+
+// Authorized returns true if the request carries one of the API tokens in its Authorization header.
+func (a Auth) Authorized(r *http.Request) bool {
+	given, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !found {
+		return false
+	}
+
+	for _, token := range a.APITokens {
+		if subtle.ConstantTimeCompare([]byte(given), []byte(token)) == 1 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// applyDefaults sets the defaults of the unset fields.
+func (c *Config) applyDefaults() {
+	if c.Server.Addr == "" {
+		c.Server.Addr = ":8080"
+	}
+
+	if c.Server.ShutdownTimeout == 0 {
+		c.Server.ShutdownTimeout = 30 * time.Second
+	}
+
+	if c.Cache.Backend == "" {
+		c.Cache.Backend = "memory"
+	}
+
+	if c.Auth.URLExpiry == 0 {
+		c.Auth.URLExpiry = 6 * time.Hour
+	}
+
+	for i := range c.Channels {
+		if c.Channels[i].Name == "" {
+			c.Channels[i].Name = c.Channels[i].ID
+		}
+	}
+}
+
+// Source returns the source with the name.
+func (c *Config) Source(name string) (Source, bool) {
+	for _, source := range c.Sources {
+		if source.Name == name {
+			return source, true
+		}
+	}
+
+	return Source{}, false
+}
+
+// Profile returns the profile with the name, either defined by the configuration or a preset.
+func (c *Config) Profile(name string) (profile.Profile, error) {
+	for _, p := range c.Profiles {
+		if p.Name != name {
+			continue
+		}
+
+		base, err := profile.Lookup(p.Base)
+		if err != nil {
+			return profile.Profile{}, fmt.Errorf("profile %s: %w", name, err)
+		}
+
+		base.Name = p.Name
+		if p.Codec != "" {
+			base.Codec = p.Codec
+		}
+
+		if p.Preset != "" {
+			base.Preset = p.Preset
+		}
+
+		if p.GOP != 0 {
+			base.GOP = p.GOP
+		}
+
+		if p.MaxRate != 0 {
+			base.MaxRate = p.MaxRate
+		}
+
+		return base, nil
+	}
+
+	return profile.Lookup(name)
+}
+
+// RateLimit returns the limits of the server, keeping the defaults of the unset fields.
+func (c *Config) RateLimit() server.RateLimitConfig {
+	config := server.DefaultRateLimitConfig()
+	if c.Limits.PlaylistRate != 0 {
+		config.PlaylistRate = c.Limits.PlaylistRate
+	}
+
+	if c.Limits.PlaylistBurst != 0 {
+		config.PlaylistBurst = c.Limits.PlaylistBurst
+	}
+
+	if c.Limits.SegmentRate != 0 {
+		config.SegmentRate = c.Limits.SegmentRate
+	}
+
+	if c.Limits.SegmentBurst != 0 {
+		config.SegmentBurst = c.Limits.SegmentBurst
+	}
+
+	if c.Limits.BanThreshold != 0 {
+		config.BanThreshold = c.Limits.BanThreshold
+	}
+
+	if c.Limits.BanWindow != 0 {
+		config.BanWindow = c.Limits.BanWindow
+	}
+
+	if c.Limits.BanDuration != 0 {
+		config.BanDuration = c.Limits.BanDuration
+	}
+
+	return config
+}
+
+// ExpandSecrets replaces the credentials and secrets referencing the store with secret.RefPrefix by their values.
+func (c *Config) ExpandSecrets(ctx context.Context, store secret.Store) error {
+	values := []*string{&c.Auth.SigningSecret}
+	for i := range c.Auth.APITokens {
+		values = append(values, &c.Auth.APITokens[i])
+	}
+
+	for i := range c.Sources {
+		values = append(values, &c.Sources[i].Password, &c.Sources[i].Token, &c.Sources[i].SecretKey)
+	}
+
+	return secret.Expand(ctx, store, values...)
+}
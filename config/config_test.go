@@ -0,0 +1,48 @@
+package config
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"vrmix/secret"
+)
+
+func TestProfile(t *testing.T) {
+	config := Config{Profiles: []Profile{{Name: "sharp", Base: "vr360", Preset: "slow", GOP: 2 * time.Second}}}
+
+	p, err := config.Profile("sharp")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if p.Name != "sharp" || p.Preset != "slow" || p.GOP != 2*time.Second || p.Codec != "libx265" || len(p.Rungs) == 0 {
+		t.Errorf("expected the preset with the overrides, got %+v", p)
+	}
+
+	if p, err := config.Profile("flat"); err != nil || p.Name != "flat" {
+		t.Errorf("expected the preset, got %+v and %v", p, err)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	config := Config{Limits: Limits{SegmentRate: 20}}
+
+	limits := config.RateLimit()
+	if limits.SegmentRate != 20 || limits.PlaylistRate != 2 {
+		t.Errorf("expected the defaults with the overrides, got %+v", limits)
+	}
+}
+
+func TestExpandSecrets(t *testing.T) {
+	t.Setenv("VRMIX_NAS_PASSWORD", "hunter2")
+	config := Config{Sources: []Source{{Name: "nas", Type: "sftp", Password: "secret:nas-password"}}}
+
+	if err := config.ExpandSecrets(context.Background(), &secret.EnvStore{Prefix: "VRMIX_"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if config.Sources[0].Password != "hunter2" {
+		t.Errorf("expected the password to be expanded, got %q", config.Sources[0].Password)
+	}
+}
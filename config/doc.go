@@ -0,0 +1,2 @@
+// Package config loads the configuration file of a deployment, defining its channels, sources, cache, profiles, authentication and limits.
+package config
@@ -0,0 +1,216 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix is the prefix of the environment variables overriding the configuration, like VRMIX_SERVER_ADDR.
+const EnvPrefix = "VRMIX_"
+
+// Layers represents the layers resolved into a configuration, each one overriding the keys set by the previous ones.
+type Layers struct {
+	File      string   // Path of the configuration file, empty for none
+	EnvPrefix string   // Prefix of the environment variables, like EnvPrefix, empty to ignore the environment
+	Environ   []string // Environment variables as "KEY=value", like os.Environ()
+
+	// Flags sets the keys given on the command line, nil for none.
+	Flags func(config *Config)
+}
+
+// Resolve resolves the configuration with the precedence defaults < file < environment variables < flags, then validates it.
+//
+// The environment variables override the keys of the server, cache, auth and limits sections, named by the prefix and the path of the key, like VRMIX_LIMITS_SEGMENT_RATE for limits.segment_rate. Lists take comma separated values.
+func Resolve(layers Layers) (Config, error) {
+	var config Config
+	var lines map[string]int
+	if layers.File != "" {
+		var err error
+		if config, lines, err = decodeFile(layers.File); err != nil {
+			return Config{}, fmt.Errorf("%s: %w", layers.File, err)
+		}
+	}
+
+	if layers.EnvPrefix != "" {
+		keys, err := config.applyEnv(layers.EnvPrefix, layers.Environ)
+		if err != nil {
+			return Config{}, err
+		}
+
+		// The keys set by the environment are no longer located in the file, nor at the line of their section.
+		for _, key := range keys {
+			if lines != nil {
+				lines[key] = 0
+			}
+		}
+	}
+
+	if layers.Flags != nil {
+		layers.Flags(&config)
+	}
+
+	config.applyDefaults()
+	if err := config.validate(lines); err != nil {
+		return Config{}, err
+	}
+
+	return config, nil
+}
+
+// EnvName returns the name of the environment variable overriding the key, like VRMIX_LIMITS_SEGMENT_RATE for limits.segment_rate.
+func EnvName(prefix string, key string) string {
+	return prefix + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// durationType is the type of the duration keys, parsed like "30s".
+var durationType = reflect.TypeFor[time.Duration]()
+
+// applyEnv sets the keys of the sections overridden by the environment variables, returning the keys set.
+func (c *Config) applyEnv(prefix string, environ []string) ([]string, error) {
+	values := map[string]string{}
+	for _, variable := range environ {
+		if name, value, found := strings.Cut(variable, "="); found && strings.HasPrefix(name, prefix) {
+			values[name] = value
+		}
+	}
+
+	var keys []string
+	var errs []error
+	sections := reflect.ValueOf(c).Elem()
+	for i := range sections.NumField() {
+		section := sections.Field(i)
+		if section.Kind() != reflect.Struct {
+			continue
+		}
+
+		sectionName := yamlName(sections.Type().Field(i))
+		for j := range section.NumField() {
+			key := sectionName + "." + yamlName(section.Type().Field(j))
+			name := EnvName(prefix, key)
+			value, found := values[name]
+			if !found {
+				continue
+			}
+
+			if err := setValue(section.Field(j), value); err != nil {
+				errs = append(errs, &FieldError{Key: name, Err: err})
+				continue
+			}
+
+			keys = append(keys, key)
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	return keys, nil
+}
+
+// setValue parses the text into the value, by its type.
+func setValue(value reflect.Value, text string) error {
+	if value.Type() == durationType {
+		d, err := time.ParseDuration(text)
+		if err != nil {
+			return fmt.Errorf("%w: %q is not a duration", ErrInvalidValue, text)
+		}
+
+		value.SetInt(int64(d))
+		return nil
+	}
+
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString(text)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(text, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%w: %q is not an integer", ErrInvalidValue, text)
+		}
+
+		value.SetInt(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return fmt.Errorf("%w: %q is not a number", ErrInvalidValue, text)
+		}
+
+		value.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(text)
+		if err != nil {
+			return fmt.Errorf("%w: %q is not a boolean", ErrInvalidValue, text)
+		}
+
+		value.SetBool(b)
+	case reflect.Slice:
+		var items []string
+		for _, item := range strings.Split(text, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+
+		value.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("%w: cannot be set from the environment", ErrInvalidValue)
+	}
+
+	return nil
+}
+
+// yamlName returns the key naming the field.
+func yamlName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+	return name
+}
+
+// Redacted is the value replacing the secrets in the dumped configuration.
+const Redacted = "REDACTED"
+
+// Redact returns a copy of the configuration with the secrets replaced by Redacted, keeping the references to the secret stores.
+func (c Config) Redact() Config {
+	redact := func(value *string) {
+		if *value != "" && !isSecretRef(*value) {
+			*value = Redacted
+		}
+	}
+
+	redact(&c.Auth.SigningSecret)
+	c.Auth.APITokens = append([]string(nil), c.Auth.APITokens...)
+	for i := range c.Auth.APITokens {
+		redact(&c.Auth.APITokens[i])
+	}
+
+	c.Sources = append([]Source(nil), c.Sources...)
+	for i := range c.Sources {
+		redact(&c.Sources[i].Password)
+		redact(&c.Sources[i].Token)
+		redact(&c.Sources[i].SecretKey)
+	}
+
+	return c
+}
+
+// Marshal encodes the configuration in the format, with every key so the defaults are visible.
+func (c Config) Marshal(format Format) ([]byte, error) {
+	switch format {
+	case FormatYAML:
+		return yaml.Marshal(c)
+	case FormatTOML:
+		var buffer bytes.Buffer
+		err := toml.NewEncoder(&buffer).Encode(c)
+		return buffer.Bytes(), err
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownFormat, format)
+	}
+}
@@ -0,0 +1,92 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestResolve(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vrmix.yaml")
+	os.WriteFile(path, []byte("server:\n  addr: \":9000\"\n  drain_period: 5s\nlimits:\n  segment_rate: 20\n  playlist_rate: 4\n"), 0o600)
+
+	config, err := Resolve(Layers{
+		File:      path,
+		EnvPrefix: EnvPrefix,
+		Environ:   []string{"VRMIX_SERVER_ADDR=:9100", "VRMIX_LIMITS_SEGMENT_RATE=30", "VRMIX_SERVER_DRAIN_PERIOD=1m", "VRMIX_AUTH_API_TOKENS=a, b", "OTHER_SERVER_ADDR=:1"},
+		Flags:     func(c *Config) { c.Server.Addr = ":9200" },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if config.Server.Addr != ":9200" {
+		t.Errorf("expected the flag to take precedence, got %q", config.Server.Addr)
+	}
+
+	if config.Limits.SegmentRate != 30 || config.Server.DrainPeriod != time.Minute || !reflect.DeepEqual(config.Auth.APITokens, []string{"a", "b"}) {
+		t.Errorf("expected the environment to override the file, got %+v", config)
+	}
+
+	if config.Limits.PlaylistRate != 4 || config.Server.ShutdownTimeout != 30*time.Second {
+		t.Errorf("expected the file and the defaults to be kept, got %+v", config)
+	}
+}
+
+func TestResolveEnvErrors(t *testing.T) {
+	_, err := Resolve(Layers{EnvPrefix: EnvPrefix, Environ: []string{"VRMIX_LIMITS_SEGMENT_BURST=many"}})
+	var fieldErr *FieldError
+	if !errors.As(err, &fieldErr) || fieldErr.Key != "VRMIX_LIMITS_SEGMENT_BURST" || !errors.Is(err, ErrInvalidValue) {
+		t.Errorf("expected the variable to be reported, got %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "vrmix.yaml")
+	os.WriteFile(path, []byte("limits:\n  segment_rate: 20\n"), 0o600)
+
+	_, err = Resolve(Layers{File: path, EnvPrefix: EnvPrefix, Environ: []string{"VRMIX_LIMITS_SEGMENT_RATE=-1"}})
+	if err == nil || err.Error() != "limits.segment_rate: invalid value: must not be negative" {
+		t.Errorf("expected the overridden key not to be located in the file, got %v", err)
+	}
+}
+
+func TestEnvName(t *testing.T) {
+	if name := EnvName(EnvPrefix, "limits.segment_rate"); name != "VRMIX_LIMITS_SEGMENT_RATE" {
+		t.Errorf("expected VRMIX_LIMITS_SEGMENT_RATE, got %s", name)
+	}
+}
+
+func TestRedactMarshal(t *testing.T) {
+	config := Config{
+		Sources: []Source{{Name: "nas", Type: "sftp", Addr: "nas:22", User: "vrmix", Password: "hunter2"}},
+		Auth:    Auth{SigningSecret: "secret:signing", APITokens: []string{"token"}},
+	}
+	config.applyDefaults()
+
+	redacted := config.Redact()
+	if redacted.Sources[0].Password != Redacted || redacted.Auth.APITokens[0] != Redacted || redacted.Auth.SigningSecret != "secret:signing" {
+		t.Errorf("expected the secrets to be redacted but not the references, got %+v", redacted)
+	}
+
+	if config.Sources[0].Password != "hunter2" || config.Auth.APITokens[0] != "token" {
+		t.Errorf("expected the original to be untouched, got %+v", config)
+	}
+
+	for _, format := range []Format{FormatYAML, FormatTOML} {
+		data, err := config.Marshal(format)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		parsed, err := Parse(data, format)
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+
+		if again, _ := parsed.Marshal(format); string(again) != string(data) {
+			t.Errorf("%s: expected the dump to parse back, got %s and %s", format, again, data)
+		}
+	}
+}
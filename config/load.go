@@ -0,0 +1,232 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Format represents the syntax of a configuration file.
+type Format string
+
+const (
+	// FormatYAML is the format of the .yaml and .yml files.
+	FormatYAML Format = "yaml"
+
+	// FormatTOML is the format of the .toml files.
+	FormatTOML Format = "toml"
+)
+
+var (
+	// ErrUnknownFormat indicates that the format of the file cannot be told from its extension.
+	ErrUnknownFormat = errors.New("unknown configuration format")
+
+	// ErrUnknownKey indicates that the file sets a key that is not part of the configuration, usually a typo.
+	ErrUnknownKey = errors.New("unknown key")
+)
+
+// FieldError records an error caused by a key of the configuration file.
+type FieldError struct {
+	Key  string // Path of the offending key, like "channels[1].sources[0]"
+	Line int    // Line of the key in the file, zero if unknown
+	Err  error  // The reason for the error
+}
+
+func (e *FieldError) Error() string {
+	if e.Line == 0 {
+		return e.Key + ": " + e.Err.Error()
+	}
+
+	return e.Key + " (line " + strconv.Itoa(e.Line) + "): " + e.Err.Error()
+}
+
+func (e *FieldError) Unwrap() error { return e.Err }
+
+// FormatOf returns the format of the file from its extension.
+func FormatOf(path string) (Format, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return FormatYAML, nil
+	case ".toml":
+		return FormatTOML, nil
+	default:
+		return "", fmt.Errorf("%w: %s", ErrUnknownFormat, path)
+	}
+}
+
+// Load reads the configuration file, in the format told by its extension, and validates it.
+func Load(path string) (Config, error) {
+	return Resolve(Layers{File: path})
+}
+
+// Parse parses the configuration in the format, sets the defaults of the unset fields and validates it, reporting every offending key.
+func Parse(data []byte, format Format) (Config, error) {
+	config, lines, err := decode(data, format)
+	if err != nil {
+		return Config{}, err
+	}
+
+	config.applyDefaults()
+	if err := config.validate(lines); err != nil {
+		return Config{}, err
+	}
+
+	return config, nil
+}
+
+// decodeFile decodes the configuration file, in the format told by its extension, returning the lines of its keys.
+func decodeFile(path string) (Config, map[string]int, error) {
+	format, err := FormatOf(path)
+	if err != nil {
+		return Config{}, nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, nil, err
+	}
+
+	return decode(data, format)
+}
+
+// decode decodes the configuration in the format without setting the defaults, returning the lines of its keys when the format tracks them.
+func decode(data []byte, format Format) (Config, map[string]int, error) {
+	var config Config
+	var lines map[string]int
+	var errs []error
+
+	switch format {
+	case FormatYAML:
+		var root yaml.Node
+		if err := yaml.Unmarshal(data, &root); err != nil {
+			return Config{}, nil, err
+		}
+
+		lines = map[string]int{}
+		if len(root.Content) > 0 {
+			errs = checkYAMLKeys(root.Content[0], reflect.TypeOf(config), "", lines)
+			if err := root.Content[0].Decode(&config); err != nil {
+				errs = append(errs, yamlError(err, lines))
+			}
+		}
+	case FormatTOML:
+		metadata, err := toml.NewDecoder(bytes.NewReader(data)).Decode(&config)
+		if err != nil {
+			return Config{}, nil, err
+		}
+
+		for _, key := range metadata.Undecoded() {
+			errs = append(errs, &FieldError{Key: key.String(), Err: ErrUnknownKey})
+		}
+	default:
+		return Config{}, nil, fmt.Errorf("%w: %s", ErrUnknownFormat, format)
+	}
+
+	if len(errs) > 0 {
+		return Config{}, nil, errors.Join(errs...)
+	}
+
+	return config, lines, nil
+}
+
+// checkYAMLKeys reports the keys of the node unknown to the type, recording the line of every key by its path.
+func checkYAMLKeys(node *yaml.Node, t reflect.Type, path string, lines map[string]int) []error {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	var errs []error
+	switch {
+	case t.Kind() == reflect.Struct && node.Kind == yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			keyPath := joinKey(path, key.Value)
+			lines[keyPath] = key.Line
+
+			field, found := yamlField(t, key.Value)
+			if !found {
+				errs = append(errs, &FieldError{Key: keyPath, Line: key.Line, Err: ErrUnknownKey})
+				continue
+			}
+
+			errs = append(errs, checkYAMLKeys(value, field.Type, keyPath, lines)...)
+		}
+	case t.Kind() == reflect.Slice && node.Kind == yaml.SequenceNode:
+		for i, item := range node.Content {
+			itemPath := path + "[" + strconv.Itoa(i) + "]"
+			lines[itemPath] = item.Line
+			errs = append(errs, checkYAMLKeys(item, t.Elem(), itemPath, lines)...)
+		}
+	}
+
+	return errs
+}
+
+// yamlField returns the field of the struct type named by the YAML key.
+func yamlField(t reflect.Type, key string) (reflect.StructField, bool) {
+	for i := range t.NumField() {
+		field := t.Field(i)
+		if yamlName(field) == key {
+			return field, true
+		}
+	}
+
+	return reflect.StructField{}, false
+}
+
+// joinKey returns the path of the key nested in the parent path.
+func joinKey(parent string, key string) string {
+	if parent == "" {
+		return key
+	}
+
+	return parent + "." + key
+}
+
+// yamlLine matches the line prefixing the messages of the YAML decoding errors.
+var yamlLine = regexp.MustCompile(`^line (\d+): `)
+
+// yamlError converts the type errors of the YAML decoder into field errors naming the key at their line.
+func yamlError(err error, lines map[string]int) error {
+	var typeErr *yaml.TypeError
+	if !errors.As(err, &typeErr) {
+		return err
+	}
+
+	keys := make(map[int]string, len(lines))
+	for key, line := range lines {
+		// The deepest key of a line is the one holding the value.
+		if current, found := keys[line]; !found || len(key) > len(current) {
+			keys[line] = key
+		}
+	}
+
+	errs := make([]error, 0, len(typeErr.Errors))
+	for _, message := range typeErr.Errors {
+		match := yamlLine.FindStringSubmatch(message)
+		if match == nil {
+			errs = append(errs, errors.New(message))
+			continue
+		}
+
+		line, _ := strconv.Atoi(match[1])
+		key, found := keys[line]
+		if !found {
+			errs = append(errs, errors.New(message))
+			continue
+		}
+
+		errs = append(errs, &FieldError{Key: key, Line: line, Err: errors.New(strings.TrimPrefix(message, match[0]))})
+	}
+
+	return errors.Join(errs...)
+}
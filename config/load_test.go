@@ -0,0 +1,104 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testYAML = `server:
+  addr: ":9000"
+  shutdown_timeout: 10s
+sources:
+  - name: nas
+    type: file
+    root: /media
+channels:
+  - id: lobby
+    sources: [nas]
+    queue: [intro.m3u8]
+    profile: vr180
+`
+
+const testTOML = `[server]
+addr = ":9000"
+shutdown_timeout = "10s"
+
+[[sources]]
+name = "nas"
+type = "file"
+root = "/media"
+
+[[channels]]
+id = "lobby"
+sources = ["nas"]
+queue = ["intro.m3u8"]
+profile = "vr180"
+`
+
+func TestParse(t *testing.T) {
+	for format, data := range map[Format]string{FormatYAML: testYAML, FormatTOML: testTOML} {
+		config, err := Parse([]byte(data), format)
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+
+		if config.Server.Addr != ":9000" || config.Server.ShutdownTimeout != 10*time.Second {
+			t.Errorf("%s: expected the server settings, got %+v", format, config.Server)
+		}
+
+		if len(config.Channels) != 1 || config.Channels[0].Name != "lobby" || config.Channels[0].Queue[0] != "intro.m3u8" {
+			t.Errorf("%s: expected the channel named after its ID, got %+v", format, config.Channels)
+		}
+
+		if config.Cache.Backend != "memory" {
+			t.Errorf("%s: expected the memory cache by default, got %q", format, config.Cache.Backend)
+		}
+	}
+}
+
+func TestParseUnknownKey(t *testing.T) {
+	_, err := Parse([]byte("server:\n  adr: \":9000\"\n"), FormatYAML)
+	var fieldErr *FieldError
+	if !errors.As(err, &fieldErr) || fieldErr.Key != "server.adr" || fieldErr.Line != 2 || !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("expected the unknown key at line 2, got %v", err)
+	}
+
+	_, err = Parse([]byte("[server]\nadr = \":9000\"\n"), FormatTOML)
+	if !errors.As(err, &fieldErr) || fieldErr.Key != "server.adr" || !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("expected the unknown key, got %v", err)
+	}
+}
+
+func TestParseTypeError(t *testing.T) {
+	_, err := Parse([]byte("limits:\n  playlist_burst: many\n"), FormatYAML)
+	var fieldErr *FieldError
+	if !errors.As(err, &fieldErr) || fieldErr.Key != "limits.playlist_burst" || fieldErr.Line != 2 {
+		t.Errorf("expected the error to name the key, got %v", err)
+	}
+}
+
+func TestParseValidates(t *testing.T) {
+	data := strings.Replace(testYAML, "sources: [nas]", "sources: [nas, cloud]", 1)
+	_, err := Parse([]byte(data), FormatYAML)
+	if err == nil || err.Error() != `channels[0].sources[1] (line 10): undefined: source "cloud"` {
+		t.Errorf("expected the undefined source to be located, got %v", err)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "vrmix.yml")
+	os.WriteFile(path, []byte(testYAML), 0o600)
+
+	if config, err := Load(path); err != nil || config.Server.Addr != ":9000" {
+		t.Errorf("expected the file to be loaded, got %+v and %v", config, err)
+	}
+
+	if _, err := Load(filepath.Join(dir, "vrmix.ini")); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("expected ErrUnknownFormat, got %v", err)
+	}
+}
@@ -0,0 +1,210 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+
+	"vrmix/logging"
+)
+
+// ChangeKind represents how a key differs between two configurations.
+type ChangeKind string
+
+const (
+	// ChangeAdded indicates that the key is only set by the new configuration.
+	ChangeAdded ChangeKind = "added"
+
+	// ChangeRemoved indicates that the key is only set by the old configuration.
+	ChangeRemoved ChangeKind = "removed"
+
+	// ChangeModified indicates that the value of the key changed.
+	ChangeModified ChangeKind = "modified"
+)
+
+// Change represents a difference between two configurations.
+type Change struct {
+	Key     string     `json:"key"`     // Path of the key, like "limits.segment_rate" or "channels.lobby"
+	Kind    ChangeKind `json:"kind"`    // How the key differs
+	Restart bool       `json:"restart"` // Whether the change only takes effect after a restart
+}
+
+// restartKeys are the keys whose changes only take effect after a restart, as they are bound when the process starts.
+var restartKeys = []string{"server.", "cache.", "auth.signing_secret"}
+
+// Diff returns the differences between the old and the new configuration, with the channels, sources and profiles compared by name.
+func Diff(old Config, new Config) []Change {
+	var changes []Change
+	changes = diffFields(changes, "server", old.Server, new.Server)
+	changes = diffNamed(changes, "sources", old.Sources, new.Sources, func(s Source) string { return s.Name })
+	changes = diffNamed(changes, "channels", old.Channels, new.Channels, func(c Channel) string { return c.ID })
+	changes = diffFields(changes, "cache", old.Cache, new.Cache)
+	changes = diffNamed(changes, "profiles", old.Profiles, new.Profiles, func(p Profile) string { return p.Name })
+	changes = diffFields(changes, "auth", old.Auth, new.Auth)
+	changes = diffFields(changes, "limits", old.Limits, new.Limits)
+
+	for i := range changes {
+		for _, prefix := range restartKeys {
+			if strings.HasPrefix(changes[i].Key, prefix) {
+				changes[i].Restart = true
+			}
+		}
+	}
+
+	return changes
+}
+
+// diffFields appends the fields of the section that differ, named by their keys.
+func diffFields[T any](changes []Change, section string, old T, new T) []Change {
+	oldValue, newValue := reflect.ValueOf(old), reflect.ValueOf(new)
+	for i := range oldValue.NumField() {
+		if reflect.DeepEqual(oldValue.Field(i).Interface(), newValue.Field(i).Interface()) {
+			continue
+		}
+
+		changes = append(changes, Change{Key: section + "." + yamlName(oldValue.Type().Field(i)), Kind: ChangeModified})
+	}
+
+	return changes
+}
+
+// diffNamed appends the items of the list added, removed or modified, named by their names.
+func diffNamed[T any](changes []Change, section string, old []T, new []T, name func(T) string) []Change {
+	previous := make(map[string]T, len(old))
+	for _, item := range old {
+		previous[name(item)] = item
+	}
+
+	for _, item := range new {
+		key := section + "." + name(item)
+		before, found := previous[name(item)]
+		delete(previous, name(item))
+
+		switch {
+		case !found:
+			changes = append(changes, Change{Key: key, Kind: ChangeAdded})
+		case !reflect.DeepEqual(before, item):
+			changes = append(changes, Change{Key: key, Kind: ChangeModified})
+		}
+	}
+
+	for _, item := range old {
+		if _, found := previous[name(item)]; found {
+			changes = append(changes, Change{Key: section + "." + name(item), Kind: ChangeRemoved})
+		}
+	}
+
+	return changes
+}
+
+// ReloadResult represents the outcome of a reload.
+type ReloadResult struct {
+	Applied []Change `json:"applied"` // Changes applied to the running process
+	Restart []Change `json:"restart"` // Changes ignored until the process restarts
+}
+
+// Reloader reloads the configuration file, applying the changes that take effect without a restart and keeping the active sessions.
+type Reloader struct {
+	Layers Layers // Layers resolved into the configuration, the file being read again on each reload
+
+	// Apply applies the new configuration to the running subsystems, like adjusting the limits or creating the new channels.
+	Apply func(ctx context.Context, config Config, changes []Change) error
+
+	// Authorize checks the credentials of a reload requested over HTTP, nil rejects every request.
+	Authorize func(r *http.Request) bool
+
+	// Logger receives the changes applied and ignored by each reload, defaults to slog.Default.
+	Logger *slog.Logger
+
+	mutex   sync.Mutex
+	current Config
+}
+
+// NewReloader creates a new Reloader of the layers, with the configuration resolved when the process started.
+func NewReloader(layers Layers, current Config) *Reloader {
+	return &Reloader{Layers: layers, current: current}
+}
+
+// Current returns the configuration in effect, which keeps the old values of the keys requiring a restart.
+func (r *Reloader) Current() Config {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.current
+}
+
+// Reload resolves the layers again and applies the changes, leaving the configuration in effect untouched when the file is invalid or the changes cannot be applied.
+func (r *Reloader) Reload(ctx context.Context) (ReloadResult, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	loaded, err := Resolve(r.Layers)
+	if err != nil {
+		return ReloadResult{}, err
+	}
+
+	// The keys requiring a restart keep their values, so the configuration reflects what is running and the next reloads keep reporting them.
+	next := loaded
+	next.Server, next.Cache, next.Auth.SigningSecret = r.current.Server, r.current.Cache, r.current.Auth.SigningSecret
+
+	var result ReloadResult
+	for _, change := range Diff(r.current, loaded) {
+		if change.Restart {
+			result.Restart = append(result.Restart, change)
+		} else {
+			result.Applied = append(result.Applied, change)
+		}
+	}
+
+	if r.Apply != nil && len(result.Applied) > 0 {
+		if err := r.Apply(ctx, next, result.Applied); err != nil {
+			return ReloadResult{}, err
+		}
+	}
+
+	r.current = next
+
+	logger := logging.Or(r.Logger)
+	for _, change := range result.Applied {
+		logger.Info("configuration change applied", slog.String("key", change.Key), slog.String("kind", string(change.Kind)))
+	}
+
+	for _, change := range result.Restart {
+		logger.Warn("configuration change requires a restart", slog.String("key", change.Key), slog.String("kind", string(change.Kind)))
+	}
+
+	return result, nil
+}
+
+// reloadError represents the body of a failed reload request.
+type reloadError struct {
+	Error string `json:"error"` // Reason of the failure
+}
+
+// ServeHTTP reloads the configuration on POST, answering the changes applied and the ones requiring a restart as JSON.
+func (r *Reloader) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if r.Authorize == nil || !r.Authorize(req) {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	result, err := r.Reload(req.Context())
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(reloadError{Error: err.Error()})
+		return
+	}
+
+	json.NewEncoder(w).Encode(result)
+}
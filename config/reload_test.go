@@ -0,0 +1,109 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	old := Config{
+		Server:   Server{Addr: ":8080"},
+		Channels: []Channel{{ID: "lobby"}, {ID: "stage", Queue: []string{"a.m3u8"}}, {ID: "old"}},
+		Limits:   Limits{SegmentRate: 10},
+	}
+	new := Config{
+		Server:   Server{Addr: ":9090"},
+		Channels: []Channel{{ID: "lobby"}, {ID: "stage", Queue: []string{"b.m3u8"}}, {ID: "new"}},
+		Limits:   Limits{SegmentRate: 20},
+	}
+
+	expected := []Change{
+		{Key: "server.addr", Kind: ChangeModified, Restart: true},
+		{Key: "channels.stage", Kind: ChangeModified},
+		{Key: "channels.new", Kind: ChangeAdded},
+		{Key: "channels.old", Kind: ChangeRemoved},
+		{Key: "limits.segment_rate", Kind: ChangeModified},
+	}
+	if changes := Diff(old, new); !reflect.DeepEqual(changes, expected) {
+		t.Errorf("expected %+v, got %+v", expected, changes)
+	}
+}
+
+func TestReloader(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vrmix.yaml")
+	os.WriteFile(path, []byte("server:\n  addr: \":8080\"\nlimits:\n  segment_rate: 10\n"), 0o600)
+
+	current, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var applied Config
+	r := NewReloader(Layers{File: path}, current)
+	r.Apply = func(ctx context.Context, config Config, changes []Change) error {
+		applied = config
+		return nil
+	}
+
+	os.WriteFile(path, []byte("server:\n  addr: \":9090\"\nlimits:\n  segment_rate: 20\n"), 0o600)
+	result, err := r.Reload(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result.Applied) != 1 || result.Applied[0].Key != "limits.segment_rate" || len(result.Restart) != 1 || result.Restart[0].Key != "server.addr" {
+		t.Errorf("expected the limits to be applied and the address to require a restart, got %+v", result)
+	}
+
+	if applied.Limits.SegmentRate != 20 || r.Current().Server.Addr != ":8080" {
+		t.Errorf("expected the address in effect to be kept, got %+v", r.Current())
+	}
+
+	if result, _ := r.Reload(context.Background()); len(result.Applied) != 0 || len(result.Restart) != 1 {
+		t.Errorf("expected the pending restart to be reported again, got %+v", result)
+	}
+
+	os.WriteFile(path, []byte("limits:\n  segment_rate: -1\n"), 0o600)
+	if _, err := r.Reload(context.Background()); err == nil || r.Current().Limits.SegmentRate != 20 {
+		t.Errorf("expected the invalid file to be rejected, got %v", err)
+	}
+}
+
+func TestReloaderHTTP(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vrmix.toml")
+	os.WriteFile(path, []byte("[limits]\nsegment_rate = 20\n"), 0o600)
+
+	r := NewReloader(Layers{File: path}, Config{})
+	r.Authorize = Auth{APITokens: []string{"token"}}.Authorized
+
+	req := httptest.NewRequest(http.MethodPost, "/reload", nil)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 without a token, got %d", rec.Code)
+	}
+
+	req.Header.Set("Authorization", "Bearer token")
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	var result ReloadResult
+	json.NewDecoder(rec.Body).Decode(&result)
+	if rec.Code != http.StatusOK || len(result.Applied) == 0 {
+		t.Errorf("expected the changes applied, got %d and %+v", rec.Code, result)
+	}
+
+	os.WriteFile(path, []byte("[limits]\nsegment_rate = -1\n"), 0o600)
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnprocessableEntity || !strings.Contains(rec.Body.String(), "limits.segment_rate") {
+		t.Errorf("expected the invalid key to be reported, got %d and %s", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,250 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"vrmix/profile"
+	"vrmix/secret"
+)
+
+var (
+	// ErrRequired indicates that a required key is missing or empty.
+	ErrRequired = errors.New("required")
+
+	// ErrInvalidValue indicates that the value of a key is not allowed.
+	ErrInvalidValue = errors.New("invalid value")
+
+	// ErrDuplicate indicates that a name or ID is defined more than once.
+	ErrDuplicate = errors.New("duplicate")
+
+	// ErrUndefined indicates that a key references a source or profile that is not defined.
+	ErrUndefined = errors.New("undefined")
+)
+
+// idPattern matches the names and IDs usable in URLs.
+var idPattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_-]*$`)
+
+// validator collects the errors of the keys, locating them with the lines recorded while parsing.
+type validator struct {
+	lines map[string]int
+	errs  []error
+}
+
+// line returns the line of the key, or of its closest parent when the key is missing from the file.
+func (v *validator) line(key string) int {
+	for key != "" {
+		if line, found := v.lines[key]; found {
+			return line
+		}
+
+		key = key[:max(strings.LastIndexAny(key, ".["), 0)]
+	}
+
+	return 0
+}
+
+// fail records the error of the key.
+func (v *validator) fail(key string, err error) {
+	v.errs = append(v.errs, &FieldError{Key: key, Line: v.line(key), Err: err})
+}
+
+// require records ErrRequired when the value of the key is empty.
+func (v *validator) require(key string, value string) {
+	if value == "" {
+		v.fail(key, ErrRequired)
+	}
+}
+
+// nonNegative records ErrInvalidValue when the value of the key is negative.
+func (v *validator) nonNegative(key string, negative bool) {
+	if negative {
+		v.fail(key, fmt.Errorf("%w: must not be negative", ErrInvalidValue))
+	}
+}
+
+// oneOf records ErrInvalidValue when the value of the key is not one of the allowed values.
+func (v *validator) oneOf(key string, value string, allowed []string) {
+	if !slices.Contains(allowed, value) {
+		v.fail(key, fmt.Errorf("%w: %q, expected one of %v", ErrInvalidValue, value, allowed))
+	}
+}
+
+// name records an error when the name of the key is empty, not usable in URLs or already in the set.
+func (v *validator) name(key string, value string, names map[string]bool) {
+	switch {
+	case value == "":
+		v.fail(key, ErrRequired)
+	case !idPattern.MatchString(value):
+		v.fail(key, fmt.Errorf("%w: %q may only contain letters, digits, '-' and '_'", ErrInvalidValue, value))
+	case names[value]:
+		v.fail(key, fmt.Errorf("%w: %q", ErrDuplicate, value))
+	}
+
+	names[value] = true
+}
+
+// Validate checks the configuration, reporting every offending key.
+func (c *Config) Validate() error {
+	return c.validate(nil)
+}
+
+// validate checks the configuration, locating the offending keys with the lines recorded while parsing.
+func (c *Config) validate(lines map[string]int) error {
+	v := &validator{lines: lines}
+	c.validateServer(v)
+	c.validateSources(v)
+	c.validateProfiles(v)
+	c.validateChannels(v)
+	c.validateCache(v)
+	c.validateAuth(v)
+	c.validateLimits(v)
+
+	return errors.Join(v.errs...)
+}
+
+// validateServer checks the server section.
+func (c *Config) validateServer(v *validator) {
+	v.require("server.addr", c.Server.Addr)
+	if (c.Server.TLSCert == "") != (c.Server.TLSKey == "") {
+		if c.Server.TLSCert == "" {
+			v.fail("server.tls_cert", fmt.Errorf("%w with server.tls_key", ErrRequired))
+		} else {
+			v.fail("server.tls_key", fmt.Errorf("%w with server.tls_cert", ErrRequired))
+		}
+	}
+
+	v.nonNegative("server.shutdown_timeout", c.Server.ShutdownTimeout < 0)
+	v.nonNegative("server.drain_period", c.Server.DrainPeriod < 0)
+}
+
+// validateSources checks the sources and the keys required by their types.
+func (c *Config) validateSources(v *validator) {
+	names := map[string]bool{}
+	for i, source := range c.Sources {
+		key := "sources[" + strconv.Itoa(i) + "]"
+		v.name(key+".name", source.Name, names)
+		if source.Type == "" {
+			v.fail(key+".type", ErrRequired)
+			continue
+		}
+
+		v.oneOf(key+".type", source.Type, SourceTypes)
+		switch source.Type {
+		case "file":
+			v.require(key+".root", source.Root)
+		case "s3":
+			v.require(key+".bucket", source.Bucket)
+			v.require(key+".region", source.Region)
+		case "sftp":
+			v.require(key+".addr", source.Addr)
+			v.require(key+".user", source.User)
+		case "ftp":
+			v.require(key+".addr", source.Addr)
+		case "webdav", "jellyfin", "plex", "crawler":
+			v.require(key+".url", source.URL)
+		}
+	}
+}
+
+// validateProfiles checks the profiles and the presets they derive from.
+func (c *Config) validateProfiles(v *validator) {
+	names := map[string]bool{}
+	for _, preset := range profile.Presets {
+		names[preset.Name] = true
+	}
+
+	for i, p := range c.Profiles {
+		key := "profiles[" + strconv.Itoa(i) + "]"
+		v.name(key+".name", p.Name, names)
+		if p.Base == "" {
+			v.fail(key+".base", ErrRequired)
+		} else if _, err := profile.Lookup(p.Base); err != nil {
+			v.fail(key+".base", fmt.Errorf("%w: preset %q", ErrUndefined, p.Base))
+		}
+
+		v.nonNegative(key+".gop", p.GOP < 0)
+		if p.MaxRate != 0 && p.MaxRate < 1 {
+			v.fail(key+".max_rate", fmt.Errorf("%w: must be at least 1", ErrInvalidValue))
+		}
+	}
+}
+
+// validateChannels checks the channels and the sources and profiles they reference.
+func (c *Config) validateChannels(v *validator) {
+	ids := map[string]bool{}
+	for i, channel := range c.Channels {
+		key := "channels[" + strconv.Itoa(i) + "]"
+		v.name(key+".id", channel.ID, ids)
+		for j, name := range channel.Sources {
+			if _, found := c.Source(name); !found {
+				v.fail(key+".sources["+strconv.Itoa(j)+"]", fmt.Errorf("%w: source %q", ErrUndefined, name))
+			}
+		}
+
+		for j, ref := range channel.Queue {
+			v.require(key+".queue["+strconv.Itoa(j)+"]", ref)
+		}
+
+		if channel.Profile != "" {
+			if _, err := c.Profile(channel.Profile); err != nil {
+				v.fail(key+".profile", fmt.Errorf("%w: profile %q", ErrUndefined, channel.Profile))
+			}
+		}
+	}
+}
+
+// validateCache checks the cache backend and the keys it requires.
+func (c *Config) validateCache(v *validator) {
+	v.oneOf("cache.backend", c.Cache.Backend, CacheBackends)
+	switch c.Cache.Backend {
+	case "disk":
+		v.require("cache.dir", c.Cache.Dir)
+	case "s3":
+		if c.Cache.Source == "" {
+			v.fail("cache.source", ErrRequired)
+		} else if source, found := c.Source(c.Cache.Source); !found || source.Type != "s3" {
+			v.fail("cache.source", fmt.Errorf("%w: s3 source %q", ErrUndefined, c.Cache.Source))
+		}
+	}
+
+	v.nonNegative("cache.max_bytes", c.Cache.MaxBytes < 0)
+	v.nonNegative("cache.ttl", c.Cache.TTL < 0)
+	v.nonNegative("cache.redirect", c.Cache.Redirect < 0)
+	if c.Cache.Redirect > 0 && c.Cache.Backend != "s3" {
+		v.fail("cache.redirect", fmt.Errorf("%w: requires the s3 backend", ErrInvalidValue))
+	}
+}
+
+// validateAuth checks the signing secret and the API tokens.
+func (c *Config) validateAuth(v *validator) {
+	if c.Auth.SigningSecret != "" && len(c.Auth.SigningSecret) < 16 && !isSecretRef(c.Auth.SigningSecret) {
+		v.fail("auth.signing_secret", fmt.Errorf("%w: must be at least 16 bytes long", ErrInvalidValue))
+	}
+
+	v.nonNegative("auth.url_expiry", c.Auth.URLExpiry < 0)
+	for i, token := range c.Auth.APITokens {
+		v.require("auth.api_tokens["+strconv.Itoa(i)+"]", token)
+	}
+}
+
+// validateLimits checks that the limits are not negative.
+func (c *Config) validateLimits(v *validator) {
+	v.nonNegative("limits.playlist_rate", c.Limits.PlaylistRate < 0)
+	v.nonNegative("limits.playlist_burst", c.Limits.PlaylistBurst < 0)
+	v.nonNegative("limits.segment_rate", c.Limits.SegmentRate < 0)
+	v.nonNegative("limits.segment_burst", c.Limits.SegmentBurst < 0)
+	v.nonNegative("limits.ban_threshold", c.Limits.BanThreshold < 0)
+	v.nonNegative("limits.ban_window", c.Limits.BanWindow < 0)
+	v.nonNegative("limits.ban_duration", c.Limits.BanDuration < 0)
+	v.nonNegative("limits.max_sessions", c.Limits.MaxSessions < 0)
+}
+
+// isSecretRef returns true if the value references a secret, so it is only known once expanded.
+func isSecretRef(value string) bool {
+	return strings.HasPrefix(value, secret.RefPrefix)
+}
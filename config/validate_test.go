@@ -0,0 +1,62 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+	config := Config{
+		Server:   Server{Addr: ":8080", TLSCert: "cert.pem"},
+		Sources:  []Source{{Name: "nas", Type: "file"}, {Name: "nas", Type: "gopher"}},
+		Channels: []Channel{{ID: "lobby/1", Profile: "vr720"}},
+		Profiles: []Profile{{Name: "flat", Base: "flat"}, {Name: "sharp", Base: "vr360", MaxRate: 0.5}},
+		Cache:    Cache{Backend: "s3", Source: "nas", Redirect: -1},
+		Auth:     Auth{SigningSecret: "short", APITokens: []string{""}},
+		Limits:   Limits{SegmentRate: -1},
+	}
+
+	err := config.Validate()
+	for _, expected := range []string{
+		"server.tls_key: required with server.tls_cert",
+		"sources[0].root: required",
+		`sources[1].name: duplicate: "nas"`,
+		`sources[1].type: invalid value: "gopher"`,
+		`profiles[0].name: duplicate: "flat"`,
+		"profiles[1].max_rate: invalid value: must be at least 1",
+		`channels[0].id: invalid value: "lobby/1"`,
+		`channels[0].profile: undefined: profile "vr720"`,
+		`cache.source: undefined: s3 source "nas"`,
+		"cache.redirect: invalid value: must not be negative",
+		"auth.signing_secret: invalid value",
+		"auth.api_tokens[0]: required",
+		"limits.segment_rate: invalid value: must not be negative",
+	} {
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected %q to be reported, got %v", expected, err)
+		}
+	}
+
+	if !errors.Is(err, ErrRequired) || !errors.Is(err, ErrDuplicate) {
+		t.Errorf("expected the errors to be matchable, got %v", err)
+	}
+}
+
+func TestValidateLine(t *testing.T) {
+	_, err := Parse([]byte("sources:\n  - type: file\n    root: /media\n"), FormatYAML)
+	if err == nil || err.Error() != "sources[0].name (line 2): required" {
+		t.Errorf("expected the missing key to be located at its parent, got %v", err)
+	}
+
+	config := Config{Auth: Auth{SigningSecret: "secret:signing"}}
+	config.applyDefaults()
+	if err := config.Validate(); err != nil {
+		t.Errorf("expected the secret reference to be accepted, got %v", err)
+	}
+	config.Cache.Redirect = time.Minute
+	if err := config.Validate(); err == nil || !strings.Contains(err.Error(), "cache.redirect: invalid value: requires the s3 backend") {
+		t.Errorf("expected the redirect to require the s3 backend, got %v", err)
+	}
+}
@@ -0,0 +1,287 @@
+package control
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Actions recorded in the audit log.
+const (
+	// ActionCreateChannel is recorded when a channel is created.
+	ActionCreateChannel = "channel.create"
+
+	// ActionDeleteChannel is recorded when a channel is deleted.
+	ActionDeleteChannel = "channel.delete"
+
+	// ActionEnqueue is recorded when an item is queued on a channel.
+	ActionEnqueue = "queue.enqueue"
+
+	// ActionRemoveQueueItem is recorded when an item is removed from a channel queue.
+	ActionRemoveQueueItem = "queue.remove"
+
+	// ActionSkip is recorded when the item being played on a channel is skipped.
+	ActionSkip = "channel.skip"
+)
+
+// actorContextKey is the context key holding the actor of a request.
+type actorContextKey struct{}
+
+// WithActor returns a copy of the context carrying the actor, like the admin authenticated by the API middleware or interceptor.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorContextKey{}, actor)
+}
+
+// ActorFromContext returns the actor of the context, or an empty string if none was set.
+func ActorFromContext(ctx context.Context) string {
+	actor, _ := ctx.Value(actorContextKey{}).(string)
+	return actor
+}
+
+// AuditEntry represents a management mutation recorded in the audit log.
+type AuditEntry struct {
+	ID     int64           `json:"id"`               // Sequential ID of the entry
+	Time   time.Time       `json:"time"`             // Time of the mutation
+	Actor  string          `json:"actor"`            // Who performed the mutation, empty if unknown
+	Action string          `json:"action"`           // What was performed, like "channel.create"
+	Target string          `json:"target"`           // Channel, or channel and item separated by a slash, the mutation applied to
+	Before json.RawMessage `json:"before,omitempty"` // Summary of the target before the mutation
+	After  json.RawMessage `json:"after,omitempty"`  // Summary of the target after the mutation
+	Error  string          `json:"error,omitempty"`  // Reason of the failure, if the mutation failed
+}
+
+// AuditFilter represents the criteria of an audit log query, each zero field matching every entry.
+type AuditFilter struct {
+	Actor  string    // Actor of the entries
+	Action string    // Action of the entries
+	Target string    // Target of the entries
+	Since  time.Time // Earliest time of the entries
+	Until  time.Time // Latest time of the entries
+	Limit  int       // Most recent entries returned, zero for every entry
+}
+
+// matches returns true if the entry matches the filter.
+func (f AuditFilter) matches(entry AuditEntry) bool {
+	return (f.Actor == "" || entry.Actor == f.Actor) &&
+		(f.Action == "" || entry.Action == f.Action) &&
+		(f.Target == "" || entry.Target == f.Target) &&
+		(f.Since.IsZero() || !entry.Time.Before(f.Since)) &&
+		(f.Until.IsZero() || !entry.Time.After(f.Until))
+}
+
+// AuditLog is an append-only log of the management mutations, stored as JSON lines in a file.
+type AuditLog struct {
+	mutex  sync.Mutex
+	file   *os.File
+	nextID int64
+	now    func() time.Time
+}
+
+// OpenAuditLog opens the audit log stored in the file, creating it if needed.
+func OpenAuditLog(path string) (*AuditLog, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	log := &AuditLog{file: file, nextID: 1, now: time.Now}
+	entries, err := log.Query(AuditFilter{})
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	if len(entries) > 0 {
+		log.nextID = entries[len(entries)-1].ID + 1
+	}
+
+	return log, nil
+}
+
+// Append records the entry, assigning its ID and time.
+func (l *AuditLog) Append(entry AuditEntry) (AuditEntry, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	entry.ID = l.nextID
+	entry.Time = l.now()
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return AuditEntry{}, err
+	}
+
+	if _, err := l.file.Write(append(data, '\n')); err != nil {
+		return AuditEntry{}, err
+	}
+
+	l.nextID++
+	return entry, nil
+}
+
+// Query returns the entries matching the filter, oldest first.
+func (l *AuditLog) Query(filter AuditFilter) ([]AuditEntry, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if _, err := l.file.Seek(0, 0); err != nil {
+		return nil, err
+	}
+
+	var entries []AuditEntry
+	scanner := bufio.NewScanner(l.file)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var entry AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, err
+		}
+
+		if filter.matches(entry) {
+			entries = append(entries, entry)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if filter.Limit > 0 && len(entries) > filter.Limit {
+		entries = entries[len(entries)-filter.Limit:]
+	}
+
+	return entries, nil
+}
+
+// Close closes the file of the audit log.
+func (l *AuditLog) Close() error {
+	return l.file.Close()
+}
+
+// ServeHTTP writes the entries as JSON, filtered by the "actor", "action", "target", "since", "until" and "limit" query parameters, the times in RFC 3339.
+func (l *AuditLog) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := AuditFilter{Actor: query.Get("actor"), Action: query.Get("action"), Target: query.Get("target")}
+
+	var errs []error
+	if since := query.Get("since"); since != "" {
+		var err error
+		filter.Since, err = time.Parse(time.RFC3339, since)
+		errs = append(errs, err)
+	}
+
+	if until := query.Get("until"); until != "" {
+		var err error
+		filter.Until, err = time.Parse(time.RFC3339, until)
+		errs = append(errs, err)
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		var err error
+		filter.Limit, err = strconv.Atoi(limit)
+		errs = append(errs, err)
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		writeResult(w, 0, nil, errors.Join(ErrInvalidArgument, err))
+		return
+	}
+
+	entries, err := l.Query(filter)
+	if entries == nil {
+		entries = []AuditEntry{}
+	}
+
+	writeResult(w, http.StatusOK, entries, err)
+}
+
+// auditedService is a Service recording its mutations in an audit log.
+type auditedService struct {
+	Service
+	log *AuditLog
+}
+
+// NewAuditedService returns a service recording every mutation of the service in the log, with the actor of the context and a summary of the target before and after, failing the mutation when it cannot be recorded.
+func NewAuditedService(service Service, log *AuditLog) Service {
+	return &auditedService{Service: service, log: log}
+}
+
+// summary returns the JSON summary of the value, nil when absent.
+func summary(value any, ok bool) json.RawMessage {
+	if !ok {
+		return nil
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil
+	}
+
+	return data
+}
+
+// record appends an entry of the mutation to the log, returning the error of the mutation or of the log.
+func (s *auditedService) record(ctx context.Context, action string, target string, before json.RawMessage, after json.RawMessage, err error) error {
+	entry := AuditEntry{Actor: ActorFromContext(ctx), Action: action, Target: target, Before: before, After: after}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+
+	if _, logErr := s.log.Append(entry); logErr != nil {
+		return errors.Join(err, logErr)
+	}
+
+	return err
+}
+
+// channelSummary returns the summary of the channel with the ID.
+func (s *auditedService) channelSummary(ctx context.Context, id string) json.RawMessage {
+	channel, err := s.Service.GetChannel(ctx, id)
+	return summary(channel, err == nil)
+}
+
+func (s *auditedService) CreateChannel(ctx context.Context, id string, name string) (Channel, error) {
+	channel, err := s.Service.CreateChannel(ctx, id, name)
+	return channel, s.record(ctx, ActionCreateChannel, id, nil, summary(channel, err == nil), err)
+}
+
+func (s *auditedService) DeleteChannel(ctx context.Context, id string) error {
+	before := s.channelSummary(ctx, id)
+	err := s.Service.DeleteChannel(ctx, id)
+	return s.record(ctx, ActionDeleteChannel, id, before, nil, err)
+}
+
+func (s *auditedService) Enqueue(ctx context.Context, channel string, item QueueItem) (QueueItem, error) {
+	queued, err := s.Service.Enqueue(ctx, channel, item)
+	if err != nil {
+		return queued, s.record(ctx, ActionEnqueue, channel, nil, summary(item, true), err)
+	}
+
+	return queued, s.record(ctx, ActionEnqueue, channel+"/"+queued.ID, nil, summary(queued, true), nil)
+}
+
+func (s *auditedService) RemoveQueueItem(ctx context.Context, channel string, id string) error {
+	var before json.RawMessage
+	if items, err := s.Service.ListQueue(ctx, channel); err == nil {
+		for _, item := range items {
+			if item.ID == id {
+				before = summary(item, true)
+			}
+		}
+	}
+
+	err := s.Service.RemoveQueueItem(ctx, channel, id)
+	return s.record(ctx, ActionRemoveQueueItem, channel+"/"+id, before, nil, err)
+}
+
+func (s *auditedService) Skip(ctx context.Context, channel string) error {
+	before := s.channelSummary(ctx, channel)
+	err := s.Service.Skip(ctx, channel)
+	return s.record(ctx, ActionSkip, channel, before, s.channelSummary(ctx, channel), err)
+}
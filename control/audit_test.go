@@ -0,0 +1,102 @@
+package control
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func TestAuditedService(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	log, err := OpenAuditLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	service := NewAuditedService(newMemoryService(), log)
+	ctx := WithActor(context.Background(), "alice")
+
+	if _, err := service.CreateChannel(ctx, "second", "Second"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := service.CreateChannel(ctx, "second", "Second"); err != ErrAlreadyExists {
+		t.Errorf("expected the service error, got %v", err)
+	}
+
+	if err := service.DeleteChannel(WithActor(context.Background(), "bob"), "second"); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := log.Query(AuditFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 entries, got %d", len(entries))
+	}
+
+	if entries[0].Actor != "alice" || entries[0].Action != ActionCreateChannel || entries[0].Target != "second" || entries[0].After == nil {
+		t.Errorf("expected the creation by alice, got %+v", entries[0])
+	}
+
+	if entries[1].Error == "" {
+		t.Errorf("expected the failed creation to be recorded, got %+v", entries[1])
+	}
+
+	var before Channel
+	if err := json.Unmarshal(entries[2].Before, &before); err != nil || before.Name != "Second" {
+		t.Errorf("expected the deleted channel summary, got %s", entries[2].Before)
+	}
+
+	log.Close()
+
+	log, err = OpenAuditLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer log.Close()
+
+	entry, err := log.Append(AuditEntry{Action: ActionSkip, Target: "main"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if entry.ID != 4 {
+		t.Errorf("expected the IDs to continue after reopening, got %d", entry.ID)
+	}
+}
+
+func TestAuditLogHandler(t *testing.T) {
+	log, err := OpenAuditLog(filepath.Join(t.TempDir(), "audit.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer log.Close()
+
+	log.Append(AuditEntry{Actor: "alice", Action: ActionSkip, Target: "main"})
+	log.Append(AuditEntry{Actor: "bob", Action: ActionSkip, Target: "main"})
+	log.Append(AuditEntry{Actor: "alice", Action: ActionDeleteChannel, Target: "main"})
+
+	w := httptest.NewRecorder()
+	log.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/audit?actor=alice&limit=1", nil))
+
+	var entries []AuditEntry
+	if err := json.NewDecoder(w.Body).Decode(&entries); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 1 || entries[0].Action != ActionDeleteChannel {
+		t.Errorf("expected the latest entry of alice, got %+v", entries)
+	}
+
+	w = httptest.NewRecorder()
+	log.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/audit?since=yesterday", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+}
@@ -0,0 +1,112 @@
+package control
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"vrmix/hls"
+)
+
+var (
+	// ErrNotFound indicates that the channel, queue item or session does not exist.
+	ErrNotFound = errors.New("not found")
+
+	// ErrAlreadyExists indicates that a channel with the same ID already exists.
+	ErrAlreadyExists = errors.New("already exists")
+
+	// ErrInvalidArgument indicates that the request is malformed.
+	ErrInvalidArgument = errors.New("invalid argument")
+
+	// ErrIncompatibleLayout indicates that the projection, frame packing or eye resolution of an item differs from the items queued on the channel.
+	ErrIncompatibleLayout = fmt.Errorf("%w: incompatible video layout", ErrInvalidArgument)
+)
+
+// ChannelState represents the playback state of a channel.
+type ChannelState string
+
+const (
+	// ChannelIdle indicates that the channel has nothing to play.
+	ChannelIdle ChannelState = "idle"
+
+	// ChannelPlaying indicates that the channel is playing a queue item.
+	ChannelPlaying ChannelState = "playing"
+
+	// ChannelErrored indicates that the channel failed and stopped advancing.
+	ChannelErrored ChannelState = "errored"
+)
+
+// Channel represents a stream mixing the items of its queue.
+type Channel struct {
+	ID       string       `json:"id"`                // Unique ID of the channel, used in the stream URLs
+	Name     string       `json:"name"`              // Human readable name of the channel
+	State    ChannelState `json:"state"`             // Playback state of the channel
+	Current  *QueueItem   `json:"current,omitempty"` // Item being played, if any
+	Sessions int          `json:"sessions"`          // Active sessions watching the channel
+}
+
+// QueueItem represents a media queued on a channel.
+type QueueItem struct {
+	ID       string  `json:"id"`              // Unique ID of the item in the channel
+	Source   string  `json:"source"`          // URL or logical ID of the media
+	Title    string  `json:"title,omitempty"` // Human readable title of the media
+	Duration float64 `json:"duration"`        // Duration of the media in seconds, zero if unknown
+
+	// Fallbacks are the references played in order when the source fails, like a backup URL and a local file.
+	Fallbacks []string `json:"fallbacks,omitempty"`
+
+	// Projection, Stereo and Resolution describe how the video is rendered in a headset, empty if unknown.
+	Projection hls.Projection   `json:"projection,omitempty"`
+	Stereo     hls.StereoLayout `json:"stereo,omitempty"`
+	Resolution string           `json:"resolution,omitempty"`
+}
+
+// Layout returns the projection, stereo layout and resolution of the item.
+func (i QueueItem) Layout() hls.Layout {
+	return hls.Layout{Projection: i.Projection, Stereo: i.Stereo, Resolution: i.Resolution}
+}
+
+// Chain returns the source of the item followed by its fallbacks.
+func (i QueueItem) Chain() []string {
+	return append([]string{i.Source}, i.Fallbacks...)
+}
+
+// Session represents a player watching a channel.
+type Session struct {
+	ID       string    `json:"id"`        // Unique ID of the session
+	Channel  string    `json:"channel"`   // Channel being watched
+	Remote   string    `json:"remote"`    // IP address of the player
+	Started  time.Time `json:"started"`   // Time of the first request
+	LastSeen time.Time `json:"last_seen"` // Time of the last request
+}
+
+// Service is the management surface of channels, queues and sessions, implemented by the stream controller and exposed over REST and gRPC.
+type Service interface {
+	// ListChannels returns every channel.
+	ListChannels(ctx context.Context) ([]Channel, error)
+
+	// GetChannel returns the channel with the ID.
+	GetChannel(ctx context.Context, id string) (Channel, error)
+
+	// CreateChannel creates an idle channel with the ID and name.
+	CreateChannel(ctx context.Context, id string, name string) (Channel, error)
+
+	// DeleteChannel stops and removes the channel with the ID.
+	DeleteChannel(ctx context.Context, id string) error
+
+	// ListQueue returns the items queued on the channel, starting with the item being played.
+	ListQueue(ctx context.Context, channel string) ([]QueueItem, error)
+
+	// Enqueue appends the item to the channel queue, returning it with its assigned ID.
+	Enqueue(ctx context.Context, channel string, item QueueItem) (QueueItem, error)
+
+	// RemoveQueueItem removes the item from the channel queue.
+	RemoveQueueItem(ctx context.Context, channel string, id string) error
+
+	// Skip stops the item being played, advancing the channel to the next item.
+	Skip(ctx context.Context, channel string) error
+
+	// ListSessions returns the sessions watching the channel, or every session when the channel is empty.
+	ListSessions(ctx context.Context, channel string) ([]Session, error)
+}
@@ -0,0 +1,165 @@
+package control
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// memoryService is an in-memory Service used to test the transports
+type memoryService struct {
+	mutex    sync.Mutex
+	channels map[string]*Channel
+	queues   map[string][]QueueItem
+	sessions []Session
+	nextID   int
+}
+
+// newMemoryService creates a memory service with a "main" channel watched by one session
+func newMemoryService() *memoryService {
+	return &memoryService{
+		channels: map[string]*Channel{"main": {ID: "main", Name: "Main", State: ChannelIdle}},
+		queues:   map[string][]QueueItem{},
+		sessions: []Session{{ID: "s1", Channel: "main", Remote: "10.0.0.1", Started: time.Unix(1000, 0), LastSeen: time.Unix(1010, 0)}},
+	}
+}
+
+func (s *memoryService) ListChannels(ctx context.Context) ([]Channel, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var channels []Channel
+	for _, channel := range s.channels {
+		channels = append(channels, *channel)
+	}
+
+	return channels, nil
+}
+
+func (s *memoryService) GetChannel(ctx context.Context, id string) (Channel, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	channel, ok := s.channels[id]
+	if !ok {
+		return Channel{}, ErrNotFound
+	}
+
+	return *channel, nil
+}
+
+func (s *memoryService) CreateChannel(ctx context.Context, id string, name string) (Channel, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if id == "" {
+		return Channel{}, ErrInvalidArgument
+	}
+
+	if _, ok := s.channels[id]; ok {
+		return Channel{}, ErrAlreadyExists
+	}
+
+	channel := &Channel{ID: id, Name: name, State: ChannelIdle}
+	s.channels[id] = channel
+	return *channel, nil
+}
+
+func (s *memoryService) DeleteChannel(ctx context.Context, id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.channels[id]; !ok {
+		return ErrNotFound
+	}
+
+	delete(s.channels, id)
+	delete(s.queues, id)
+	return nil
+}
+
+func (s *memoryService) ListQueue(ctx context.Context, channel string) ([]QueueItem, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.channels[channel]; !ok {
+		return nil, ErrNotFound
+	}
+
+	return s.queues[channel], nil
+}
+
+func (s *memoryService) Enqueue(ctx context.Context, channel string, item QueueItem) (QueueItem, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	c, ok := s.channels[channel]
+	if !ok {
+		return QueueItem{}, ErrNotFound
+	}
+
+	s.nextID += 1
+	item.ID = strconv.Itoa(s.nextID)
+	s.queues[channel] = append(s.queues[channel], item)
+
+	if c.Current == nil {
+		c.Current = &item
+		c.State = ChannelPlaying
+	}
+
+	return item, nil
+}
+
+func (s *memoryService) RemoveQueueItem(ctx context.Context, channel string, id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for i, item := range s.queues[channel] {
+		if item.ID == id {
+			s.queues[channel] = append(s.queues[channel][:i], s.queues[channel][i+1:]...)
+			return nil
+		}
+	}
+
+	return ErrNotFound
+}
+
+func (s *memoryService) Skip(ctx context.Context, channel string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	c, ok := s.channels[channel]
+	if !ok {
+		return ErrNotFound
+	}
+
+	queue := s.queues[channel]
+	if len(queue) > 0 {
+		queue = queue[1:]
+		s.queues[channel] = queue
+	}
+
+	c.Current = nil
+	c.State = ChannelIdle
+	if len(queue) > 0 {
+		c.Current = &queue[0]
+		c.State = ChannelPlaying
+	}
+
+	return nil
+}
+
+func (s *memoryService) ListSessions(ctx context.Context, channel string) ([]Session, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var sessions []Session
+	for _, session := range s.sessions {
+		if channel == "" || session.Channel == channel {
+			sessions = append(sessions, session)
+		}
+	}
+
+	return sessions, nil
+}
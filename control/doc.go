@@ -0,0 +1,4 @@
+// Package control contains the management surface of VRMix channels, queues and sessions, independent of the transport exposing it.
+package control
+
+//go:generate protoc -I .. --go_out=.. --go_opt=paths=source_relative --go-grpc_out=.. --go-grpc_opt=paths=source_relative ../api/control/v1/control.proto
@@ -0,0 +1,201 @@
+package control
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	controlv1 "vrmix/api/control/v1"
+	"vrmix/hls"
+)
+
+// GRPCServer exposes a Service over gRPC, following the published vrmix.control.v1 proto.
+type GRPCServer struct {
+	controlv1.UnimplementedControlServer
+	service Service
+}
+
+// NewGRPCServer creates a new GRPCServer exposing the service.
+func NewGRPCServer(service Service) *GRPCServer {
+	return &GRPCServer{service: service}
+}
+
+// Register registers the server on the gRPC registrar, like a *grpc.Server.
+func (s *GRPCServer) Register(registrar grpc.ServiceRegistrar) {
+	controlv1.RegisterControlServer(registrar, s)
+}
+
+// grpcError converts a service error to a gRPC status error.
+func grpcError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, ErrNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, ErrAlreadyExists):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, ErrInvalidArgument):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
+
+// toProtoItem converts a queue item to its proto message.
+func toProtoItem(item *QueueItem) *controlv1.QueueItem {
+	if item == nil {
+		return nil
+	}
+
+	return &controlv1.QueueItem{
+		Id:         item.ID,
+		Source:     item.Source,
+		Title:      item.Title,
+		Duration:   item.Duration,
+		Fallbacks:  item.Fallbacks,
+		Projection: string(item.Projection),
+		Stereo:     string(item.Stereo),
+		Resolution: item.Resolution,
+	}
+}
+
+// fromProtoItem converts a proto message to a queue item.
+func fromProtoItem(item *controlv1.QueueItem) QueueItem {
+	return QueueItem{
+		ID:         item.GetId(),
+		Source:     item.GetSource(),
+		Title:      item.GetTitle(),
+		Duration:   item.GetDuration(),
+		Fallbacks:  item.GetFallbacks(),
+		Projection: hls.Projection(item.GetProjection()),
+		Stereo:     hls.StereoLayout(item.GetStereo()),
+		Resolution: item.GetResolution(),
+	}
+}
+
+// toProtoChannel converts a channel to its proto message.
+func toProtoChannel(channel *Channel) *controlv1.Channel {
+	return &controlv1.Channel{
+		Id:       channel.ID,
+		Name:     channel.Name,
+		State:    string(channel.State),
+		Current:  toProtoItem(channel.Current),
+		Sessions: int32(channel.Sessions),
+	}
+}
+
+// toProtoSession converts a session to its proto message.
+func toProtoSession(session *Session) *controlv1.Session {
+	return &controlv1.Session{
+		Id:       session.ID,
+		Channel:  session.Channel,
+		Remote:   session.Remote,
+		Started:  timestamppb.New(session.Started),
+		LastSeen: timestamppb.New(session.LastSeen),
+	}
+}
+
+func (s *GRPCServer) ListChannels(ctx context.Context, req *controlv1.ListChannelsRequest) (*controlv1.ListChannelsResponse, error) {
+	channels, err := s.service.ListChannels(ctx)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	resp := &controlv1.ListChannelsResponse{}
+	for i := range channels {
+		resp.Channels = append(resp.Channels, toProtoChannel(&channels[i]))
+	}
+
+	return resp, nil
+}
+
+func (s *GRPCServer) GetChannel(ctx context.Context, req *controlv1.GetChannelRequest) (*controlv1.Channel, error) {
+	channel, err := s.service.GetChannel(ctx, req.GetId())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	return toProtoChannel(&channel), nil
+}
+
+func (s *GRPCServer) CreateChannel(ctx context.Context, req *controlv1.CreateChannelRequest) (*controlv1.Channel, error) {
+	channel, err := s.service.CreateChannel(ctx, req.GetId(), req.GetName())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	return toProtoChannel(&channel), nil
+}
+
+func (s *GRPCServer) DeleteChannel(ctx context.Context, req *controlv1.DeleteChannelRequest) (*controlv1.DeleteChannelResponse, error) {
+	if err := s.service.DeleteChannel(ctx, req.GetId()); err != nil {
+		return nil, grpcError(err)
+	}
+
+	return &controlv1.DeleteChannelResponse{}, nil
+}
+
+func (s *GRPCServer) ListQueue(ctx context.Context, req *controlv1.ListQueueRequest) (*controlv1.ListQueueResponse, error) {
+	items, err := s.service.ListQueue(ctx, req.GetChannel())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	resp := &controlv1.ListQueueResponse{}
+	for i := range items {
+		resp.Items = append(resp.Items, toProtoItem(&items[i]))
+	}
+
+	return resp, nil
+}
+
+func (s *GRPCServer) Enqueue(ctx context.Context, req *controlv1.EnqueueRequest) (*controlv1.QueueItem, error) {
+	if req.GetItem() == nil {
+		return nil, status.Error(codes.InvalidArgument, "missing item")
+	}
+
+	item, err := s.service.Enqueue(ctx, req.GetChannel(), fromProtoItem(req.GetItem()))
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	return toProtoItem(&item), nil
+}
+
+func (s *GRPCServer) RemoveQueueItem(ctx context.Context, req *controlv1.RemoveQueueItemRequest) (*controlv1.RemoveQueueItemResponse, error) {
+	if err := s.service.RemoveQueueItem(ctx, req.GetChannel(), req.GetId()); err != nil {
+		return nil, grpcError(err)
+	}
+
+	return &controlv1.RemoveQueueItemResponse{}, nil
+}
+
+func (s *GRPCServer) Skip(ctx context.Context, req *controlv1.SkipRequest) (*controlv1.SkipResponse, error) {
+	if err := s.service.Skip(ctx, req.GetChannel()); err != nil {
+		return nil, grpcError(err)
+	}
+
+	return &controlv1.SkipResponse{}, nil
+}
+
+func (s *GRPCServer) ListSessions(ctx context.Context, req *controlv1.ListSessionsRequest) (*controlv1.ListSessionsResponse, error) {
+	sessions, err := s.service.ListSessions(ctx, req.GetChannel())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	resp := &controlv1.ListSessionsResponse{}
+	for i := range sessions {
+		resp.Sessions = append(resp.Sessions, toProtoSession(&sessions[i]))
+	}
+
+	return resp, nil
+}
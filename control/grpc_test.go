@@ -0,0 +1,101 @@
+package control
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	controlv1 "vrmix/api/control/v1"
+)
+
+// newGRPCClient starts a gRPC server over an in-memory listener and returns a client connected to it
+func newGRPCClient(t *testing.T) controlv1.ControlClient {
+	listener := bufconn.Listen(1024 * 1024)
+
+	server := grpc.NewServer()
+	NewGRPCServer(newMemoryService()).Register(server)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return controlv1.NewControlClient(conn)
+}
+
+func TestGRPCServer(t *testing.T) {
+	client := newGRPCClient(t)
+	ctx := context.Background()
+
+	if _, err := client.CreateChannel(ctx, &controlv1.CreateChannelRequest{Id: "second", Name: "Second"}); err != nil {
+		t.Fatal(err)
+	}
+
+	channels, err := client.ListChannels(ctx, &controlv1.ListChannelsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(channels.GetChannels()) != 2 {
+		t.Errorf("expected 2 channels, got %d", len(channels.GetChannels()))
+	}
+
+	item, err := client.Enqueue(ctx, &controlv1.EnqueueRequest{Channel: "second", Item: &controlv1.QueueItem{Source: "https://origin.example/stream.m3u8", Fallbacks: []string{"https://backup.example/stream.m3u8"}, Projection: "equirectangular", Resolution: "7680x3840"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(item.GetFallbacks()) != 1 || item.GetProjection() != "equirectangular" || item.GetResolution() != "7680x3840" {
+		t.Errorf("expected the fallback and projection to be kept, got %v", item)
+	}
+
+	channel, err := client.GetChannel(ctx, &controlv1.GetChannelRequest{Id: "second"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if channel.GetState() != string(ChannelPlaying) || channel.GetCurrent().GetId() != item.GetId() {
+		t.Errorf("expected channel to play item %s, got %s and %v", item.GetId(), channel.GetState(), channel.GetCurrent())
+	}
+
+	sessions, err := client.ListSessions(ctx, &controlv1.ListSessionsRequest{Channel: "main"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(sessions.GetSessions()) != 1 || sessions.GetSessions()[0].GetStarted().GetSeconds() != 1000 {
+		t.Errorf("expected 1 session started at 1000, got %v", sessions.GetSessions())
+	}
+}
+
+func TestGRPCServerErrors(t *testing.T) {
+	client := newGRPCClient(t)
+	ctx := context.Background()
+
+	_, err := client.GetChannel(ctx, &controlv1.GetChannelRequest{Id: "missing"})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("expected not found, got %v", err)
+	}
+
+	_, err = client.CreateChannel(ctx, &controlv1.CreateChannelRequest{Id: "main"})
+	if status.Code(err) != codes.AlreadyExists {
+		t.Errorf("expected already exists, got %v", err)
+	}
+
+	_, err = client.Enqueue(ctx, &controlv1.EnqueueRequest{Channel: "main"})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected invalid argument, got %v", err)
+	}
+}
@@ -0,0 +1,52 @@
+package control
+
+import (
+	"context"
+	"fmt"
+
+	"vrmix/hls"
+)
+
+// CheckLayout returns ErrIncompatibleLayout when the projection, frame packing or eye aspect ratio of the item differs from an item of the queue, as the discontinuity between them would break the rendering in a headset, items with an unknown layout being compatible with anything.
+func CheckLayout(queue []QueueItem, item QueueItem) error {
+	for _, queued := range queue {
+		if err := queued.Layout().Check(item.Layout()); err != nil {
+			return fmt.Errorf("%w with item %s: %w", ErrIncompatibleLayout, queued.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// LayoutResolver returns the projection, stereo layout and resolution of the media referenced by a queue item, like source.ResolveLayout.
+type LayoutResolver func(ctx context.Context, ref string) (hls.Layout, error)
+
+// layoutCheckedService is a Service rejecting the items incompatible with the queue of the channel.
+type layoutCheckedService struct {
+	Service
+	resolve LayoutResolver
+}
+
+// NewLayoutCheckedService returns a service rejecting with ErrIncompatibleLayout the items whose layout differs from the items queued on the channel, as a headset cannot switch how it renders a stream mid-playback, filling the layout of the items without one through the resolver when not nil.
+func NewLayoutCheckedService(service Service, resolve LayoutResolver) Service {
+	return &layoutCheckedService{Service: service, resolve: resolve}
+}
+
+func (s *layoutCheckedService) Enqueue(ctx context.Context, channel string, item QueueItem) (QueueItem, error) {
+	if s.resolve != nil && item.Layout() == (hls.Layout{}) {
+		if layout, err := s.resolve(ctx, item.Source); err == nil {
+			item.Projection, item.Stereo, item.Resolution = layout.Projection, layout.Stereo, layout.Resolution
+		}
+	}
+
+	queue, err := s.Service.ListQueue(ctx, channel)
+	if err != nil {
+		return QueueItem{}, err
+	}
+
+	if err := CheckLayout(queue, item); err != nil {
+		return QueueItem{}, err
+	}
+
+	return s.Service.Enqueue(ctx, channel, item)
+}
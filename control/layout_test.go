@@ -0,0 +1,61 @@
+package control
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"vrmix/hls"
+)
+
+func TestLayoutCheckedService(t *testing.T) {
+	service := NewLayoutCheckedService(newMemoryService(), nil)
+	ctx := context.Background()
+
+	vr180 := QueueItem{Source: "https://origin.example/a.m3u8", Projection: hls.ProjectionHalfEquirectangular, Stereo: hls.StereoSideBySide, Resolution: "5760x2880"}
+	if _, err := service.Enqueue(ctx, "main", vr180); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := service.Enqueue(ctx, "main", QueueItem{Source: "https://origin.example/b.m3u8"}); err != nil {
+		t.Errorf("expected an item with unknown layout to be accepted, got %v", err)
+	}
+
+	squeezed := QueueItem{Source: "https://origin.example/d.m3u8", Projection: hls.ProjectionHalfEquirectangular, Stereo: hls.StereoSideBySide, Resolution: "3840x1080"}
+	if _, err := service.Enqueue(ctx, "main", squeezed); !errors.Is(err, ErrIncompatibleLayout) || !errors.Is(err, hls.ErrLayoutMismatch) {
+		t.Errorf("expected an eye aspect mismatch, got %v", err)
+	}
+
+	topBottom := QueueItem{Source: "https://origin.example/c.m3u8", Projection: hls.ProjectionHalfEquirectangular, Stereo: hls.StereoTopBottom}
+	_, err := service.Enqueue(ctx, "main", topBottom)
+	if !errors.Is(err, ErrIncompatibleLayout) || StatusCode(err) != http.StatusBadRequest {
+		t.Errorf("expected an incompatible layout error, got %v", err)
+	}
+}
+
+func TestLayoutCheckedServiceResolver(t *testing.T) {
+	resolve := func(ctx context.Context, ref string) (hls.Layout, error) {
+		if ref == "https://origin.example/flat.m3u8" {
+			return hls.Layout{Projection: hls.ProjectionRectilinear}, nil
+		}
+
+		return hls.Layout{Projection: hls.ProjectionEquirectangular, Stereo: hls.StereoTopBottom}, nil
+	}
+
+	service := NewLayoutCheckedService(newMemoryService(), resolve)
+	ctx := context.Background()
+
+	item, err := service.Enqueue(ctx, "main", QueueItem{Source: "https://origin.example/360.m3u8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if item.Projection != hls.ProjectionEquirectangular || item.Stereo != hls.StereoTopBottom {
+		t.Errorf("expected the resolved layout to be filled, got %+v", item)
+	}
+
+	if _, err := service.Enqueue(ctx, "main", QueueItem{Source: "https://origin.example/flat.m3u8"}); !errors.Is(err, ErrIncompatibleLayout) {
+		t.Errorf("expected the resolved flat item to be rejected, got %v", err)
+	}
+}
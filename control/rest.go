@@ -0,0 +1,176 @@
+package control
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// ErrorResponse represents the body of a failed REST request.
+type ErrorResponse struct {
+	Error string `json:"error"` // Reason of the failure
+}
+
+// CreateChannelRequest represents the body of a channel creation REST request.
+type CreateChannelRequest struct {
+	ID   string `json:"id"`   // Unique ID of the channel
+	Name string `json:"name"` // Human readable name of the channel
+}
+
+// restHandler exposes a Service over REST.
+type restHandler struct {
+	service Service
+}
+
+// NewHandler returns a handler exposing the service over REST, with routes relative to the mount point:
+//
+//	GET    /channels
+//	POST   /channels
+//	GET    /channels/{id}
+//	DELETE /channels/{id}
+//	GET    /channels/{id}/queue
+//	POST   /channels/{id}/queue
+//	DELETE /channels/{id}/queue/{item}
+//	POST   /channels/{id}/skip
+//	GET    /channels/{id}/sessions
+//	GET    /sessions
+func NewHandler(service Service) http.Handler {
+	h := &restHandler{service: service}
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /channels", h.listChannels)
+	mux.HandleFunc("POST /channels", h.createChannel)
+	mux.HandleFunc("GET /channels/{id}", h.getChannel)
+	mux.HandleFunc("DELETE /channels/{id}", h.deleteChannel)
+	mux.HandleFunc("GET /channels/{id}/queue", h.listQueue)
+	mux.HandleFunc("POST /channels/{id}/queue", h.enqueue)
+	mux.HandleFunc("DELETE /channels/{id}/queue/{item}", h.removeQueueItem)
+	mux.HandleFunc("POST /channels/{id}/skip", h.skip)
+	mux.HandleFunc("GET /channels/{id}/sessions", h.listChannelSessions)
+	mux.HandleFunc("GET /sessions", h.listSessions)
+
+	return mux
+}
+
+// StatusCode returns the HTTP status code of a service error.
+func StatusCode(err error) int {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrAlreadyExists):
+		return http.StatusConflict
+	case errors.Is(err, ErrInvalidArgument):
+		return http.StatusBadRequest
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// writeJSON writes the value as JSON with the status code.
+func writeJSON(w http.ResponseWriter, code int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(value)
+}
+
+// writeResult writes the value, or the error if any.
+func writeResult(w http.ResponseWriter, code int, value any, err error) {
+	if err != nil {
+		writeJSON(w, StatusCode(err), ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if value == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	writeJSON(w, code, value)
+}
+
+// readJSON decodes the request body into the value.
+func readJSON(r *http.Request, value any) error {
+	if err := json.NewDecoder(r.Body).Decode(value); err != nil {
+		return errors.Join(ErrInvalidArgument, err)
+	}
+
+	return nil
+}
+
+func (h *restHandler) listChannels(w http.ResponseWriter, r *http.Request) {
+	channels, err := h.service.ListChannels(r.Context())
+	if channels == nil {
+		channels = []Channel{}
+	}
+
+	writeResult(w, http.StatusOK, channels, err)
+}
+
+func (h *restHandler) createChannel(w http.ResponseWriter, r *http.Request) {
+	var req CreateChannelRequest
+	if err := readJSON(r, &req); err != nil {
+		writeResult(w, 0, nil, err)
+		return
+	}
+
+	channel, err := h.service.CreateChannel(r.Context(), req.ID, req.Name)
+	writeResult(w, http.StatusCreated, channel, err)
+}
+
+func (h *restHandler) getChannel(w http.ResponseWriter, r *http.Request) {
+	channel, err := h.service.GetChannel(r.Context(), r.PathValue("id"))
+	writeResult(w, http.StatusOK, channel, err)
+}
+
+func (h *restHandler) deleteChannel(w http.ResponseWriter, r *http.Request) {
+	writeResult(w, 0, nil, h.service.DeleteChannel(r.Context(), r.PathValue("id")))
+}
+
+func (h *restHandler) listQueue(w http.ResponseWriter, r *http.Request) {
+	items, err := h.service.ListQueue(r.Context(), r.PathValue("id"))
+	if items == nil {
+		items = []QueueItem{}
+	}
+
+	writeResult(w, http.StatusOK, items, err)
+}
+
+func (h *restHandler) enqueue(w http.ResponseWriter, r *http.Request) {
+	var item QueueItem
+	if err := readJSON(r, &item); err != nil {
+		writeResult(w, 0, nil, err)
+		return
+	}
+
+	item, err := h.service.Enqueue(r.Context(), r.PathValue("id"), item)
+	writeResult(w, http.StatusCreated, item, err)
+}
+
+func (h *restHandler) removeQueueItem(w http.ResponseWriter, r *http.Request) {
+	writeResult(w, 0, nil, h.service.RemoveQueueItem(r.Context(), r.PathValue("id"), r.PathValue("item")))
+}
+
+func (h *restHandler) skip(w http.ResponseWriter, r *http.Request) {
+	writeResult(w, 0, nil, h.service.Skip(r.Context(), r.PathValue("id")))
+}
+
+func (h *restHandler) listChannelSessions(w http.ResponseWriter, r *http.Request) {
+	sessions, err := h.service.ListSessions(r.Context(), r.PathValue("id"))
+	if sessions == nil {
+		sessions = []Session{}
+	}
+
+	writeResult(w, http.StatusOK, sessions, err)
+}
+
+func (h *restHandler) listSessions(w http.ResponseWriter, r *http.Request) {
+	sessions, err := h.service.ListSessions(r.Context(), r.URL.Query().Get("channel"))
+	if sessions == nil {
+		sessions = []Session{}
+	}
+
+	writeResult(w, http.StatusOK, sessions, err)
+}
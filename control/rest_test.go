@@ -0,0 +1,79 @@
+package control
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// restRequest sends a request to the REST handler of a memory service
+func restRequest(handler http.Handler, method string, path string, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+
+	return w
+}
+
+func TestRESTHandler(t *testing.T) {
+	handler := NewHandler(newMemoryService())
+
+	if w := restRequest(handler, http.MethodPost, "/channels/main/queue", `{"source":"https://origin.example/a.m3u8"}`); w.Code != http.StatusCreated {
+		t.Errorf("expected status %d, got %d", http.StatusCreated, w.Code)
+	}
+
+	w := restRequest(handler, http.MethodGet, "/channels/main", "")
+
+	var channel Channel
+	if err := json.NewDecoder(w.Body).Decode(&channel); err != nil {
+		t.Fatal(err)
+	}
+
+	if channel.State != ChannelPlaying || channel.Current == nil {
+		t.Errorf("expected channel to be playing, got %s", channel.State)
+	}
+
+	if w := restRequest(handler, http.MethodPost, "/channels/main/skip", ""); w.Code != http.StatusNoContent {
+		t.Errorf("expected status %d, got %d", http.StatusNoContent, w.Code)
+	}
+
+	w = restRequest(handler, http.MethodGet, "/sessions?channel=main", "")
+
+	var sessions []Session
+	if err := json.NewDecoder(w.Body).Decode(&sessions); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(sessions) != 1 {
+		t.Errorf("expected 1 session, got %d", len(sessions))
+	}
+}
+
+func TestRESTHandlerErrors(t *testing.T) {
+	handler := NewHandler(newMemoryService())
+
+	cases := []struct {
+		method string
+		path   string
+		body   string
+		code   int
+	}{
+		{http.MethodGet, "/channels/missing", "", http.StatusNotFound},
+		{http.MethodPost, "/channels", `{"id":"main"}`, http.StatusConflict},
+		{http.MethodPost, "/channels", `{`, http.StatusBadRequest},
+		{http.MethodDelete, "/channels/main/queue/missing", "", http.StatusNotFound},
+	}
+
+	for _, c := range cases {
+		w := restRequest(handler, c.method, c.path, c.body)
+		if w.Code != c.code {
+			t.Errorf("expected status %d for %s %s, got %d", c.code, c.method, c.path, w.Code)
+		}
+
+		var resp ErrorResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil || resp.Error == "" {
+			t.Errorf("expected error body for %s %s", c.method, c.path)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package convert
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"vrmix/hls"
+)
+
+const (
+	// PlaylistName is the name of the media playlist written in the directory of a conversion.
+	PlaylistName = "index.m3u8"
+
+	// InitName is the name of the initialization segment written in the directory of a FormatFMP4 conversion.
+	InitName = "init.mp4"
+)
+
+// defaultSegmentDuration is the target duration of the segments when the options set none.
+const defaultSegmentDuration = 4 * time.Second
+
+// ErrUnknownFormat indicates that the segment format is not supported.
+var ErrUnknownFormat = errors.New("unknown segment format")
+
+// Format represents the container of the segments.
+type Format string
+
+const (
+	// FormatTS is the MPEG transport stream segments, played everywhere.
+	FormatTS Format = "ts"
+
+	// FormatFMP4 is the fragmented MP4 segments, required by HEVC on most players, sharing the InitName initialization segment.
+	FormatFMP4 Format = "fmp4"
+)
+
+// Options represents how a media is converted.
+type Options struct {
+	VideoCodec      string        // Video encoder of ffmpeg, defaults to "libx264"
+	AudioCodec      string        // Audio encoder of ffmpeg, defaults to "aac"
+	VideoBitrate    int           // Video bits per second, zero to leave it to the encoder
+	AudioBitrate    int           // Audio bits per second, zero to leave it to the encoder
+	CodecArgs       []string      // Codec arguments replacing the codecs and the bitrates, like the ones of profile.Profile.CodecArgs
+	SegmentDuration time.Duration // Target duration of the segments, defaults to 4 seconds
+	Format          Format        // Container of the segments, defaults to FormatTS
+	Duration        time.Duration // Duration of the input, reporting the progress as a fraction, zero if unknown
+	NoAudio         bool          // Indicates if the input has no audio, required by ConvertLadder which maps the audio of every rendition
+}
+
+// codecs returns the video and audio encoders.
+func (o *Options) codecs() (string, string) {
+	videoCodec, audioCodec := o.VideoCodec, o.AudioCodec
+	if videoCodec == "" {
+		videoCodec = "libx264"
+	}
+
+	if audioCodec == "" {
+		audioCodec = "aac"
+	}
+
+	return videoCodec, audioCodec
+}
+
+// keyframeArgs returns the ffmpeg arguments starting every segment of the duration, formatted in seconds, with a keyframe.
+func keyframeArgs(seconds string) []string {
+	// A keyframe starting every segment keeps the segments close to their target duration.
+	return []string{"-force_key_frames", "expr:gte(t,n_forced*" + seconds + ")", "-sc_threshold", "0"}
+}
+
+// Progress represents how far a conversion went.
+type Progress struct {
+	Time     time.Duration // Time of the input converted
+	Fraction float64       // Converted fraction of the input, between 0 and 1, zero until done when the duration of the input is unknown
+	Done     bool          // Indicates if ffmpeg finished writing the segments
+}
+
+// Converter transcodes media readable by ffmpeg, like mp4, mkv and webm files or URLs, into HLS segments.
+type Converter struct {
+	Binary string // Path to the ffmpeg binary, defaults to "ffmpeg"
+
+	prefixArgs []string
+}
+
+// segmentArgs returns the ffmpeg arguments writing the segments of the format in the directory.
+func segmentArgs(format Format, dir string) ([]string, error) {
+	switch format {
+	case FormatTS, "":
+		return []string{"-hls_segment_type", "mpegts", "-hls_segment_filename", filepath.Join(dir, "segment%d.ts")}, nil
+	case FormatFMP4:
+		return []string{"-hls_segment_type", "fmp4", "-hls_fmp4_init_filename", InitName, "-hls_segment_filename", filepath.Join(dir, "segment%d.m4s")}, nil
+	default:
+		return nil, ErrUnknownFormat
+	}
+}
+
+// args returns the arguments of ffmpeg converting the input into segments written in the directory.
+func (c *Converter) args(input string, dir string, options Options) ([]string, error) {
+	segmentArgs, err := segmentArgs(options.Format, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	seconds := strconv.FormatFloat(hls.SegmentDuration(options.SegmentDuration, defaultSegmentDuration).Seconds(), 'f', -1, 64)
+
+	args := append([]string{}, c.prefixArgs...)
+	args = append(args, "-hide_banner", "-loglevel", "error", "-nostdin", "-nostats", "-progress", "pipe:1", "-y", "-i", input, "-map", "0:v:0", "-map", "0:a:0?")
+
+	codecArgs := options.CodecArgs
+	if codecArgs == nil {
+		videoCodec, audioCodec := options.codecs()
+		codecArgs = []string{"-c:v", videoCodec}
+		if options.VideoBitrate > 0 {
+			codecArgs = append(codecArgs, "-b:v", strconv.Itoa(options.VideoBitrate))
+		}
+
+		codecArgs = append(codecArgs, "-c:a", audioCodec)
+		if options.AudioBitrate > 0 {
+			codecArgs = append(codecArgs, "-b:a", strconv.Itoa(options.AudioBitrate))
+		}
+
+		codecArgs = append(codecArgs, keyframeArgs(seconds)...)
+	}
+
+	args = append(args, codecArgs...)
+	args = append(args, "-f", "hls", "-hls_time", seconds, "-hls_list_size", "0")
+	args = append(args, segmentArgs...)
+	return append(args, filepath.Join(dir, PlaylistName)), nil
+}
+
+// Convert transcodes the input into segments written in the directory, calling progress, if not nil, as ffmpeg reports it, and returns the media playlist of the segments, their paths relative to the directory.
+func (c *Converter) Convert(ctx context.Context, input string, dir string, options Options, progress func(Progress)) (hls.Manifest, error) {
+	args, err := c.args(input, dir, options)
+	if err != nil {
+		return hls.Manifest{}, err
+	}
+
+	if err := c.run(ctx, dir, args, options.Duration, progress); err != nil {
+		return hls.Manifest{}, err
+	}
+
+	return readPlaylist(filepath.Join(dir, PlaylistName))
+}
+
+// run runs ffmpeg with the arguments after creating the output directory, calling progress, if not nil, as ffmpeg reports it.
+func (c *Converter) run(ctx context.Context, dir string, args []string, duration time.Duration, progress func(Progress)) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	binary := c.Binary
+	if binary == "" {
+		binary = "ffmpeg"
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, binary, args...)
+	cmd.Stderr = &stderr
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	readProgress(stdout, duration, progress)
+
+	if err := cmd.Wait(); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return errors.Join(err, errors.New(message))
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// readProgress reads the key=value blocks written by ffmpeg -progress until the output ends, calling progress at the end of each block.
+func readProgress(output io.Reader, duration time.Duration, progress func(Progress)) {
+	var current Progress
+	scanner := bufio.NewScanner(output)
+	for scanner.Scan() {
+		key, value, _ := strings.Cut(scanner.Text(), "=")
+		switch key {
+		case "out_time_us":
+			if microseconds, err := strconv.ParseInt(value, 10, 64); err == nil && microseconds >= 0 {
+				current.Time = time.Duration(microseconds) * time.Microsecond
+			}
+		case "progress":
+			current.Done = value == "end"
+			if duration > 0 {
+				current.Fraction = min(float64(current.Time)/float64(duration), 1)
+			}
+
+			if current.Done {
+				current.Fraction = 1
+			}
+
+			if progress != nil {
+				progress(current)
+			}
+		}
+	}
+
+	// A line too long stops the scanner, so the rest is discarded for ffmpeg not to block writing it.
+	io.Copy(io.Discard, output)
+}
+
+// readPlaylist parses the media playlist written by ffmpeg.
+func readPlaylist(path string) (hls.Manifest, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return hls.Manifest{}, err
+	}
+	defer file.Close()
+
+	return hls.ParseHlsManifestReader(file)
+}
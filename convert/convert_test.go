@@ -0,0 +1,121 @@
+package convert
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestHelperFFmpeg is not a real test, it acts as ffmpeg when run by the conversion tests
+func TestHelperFFmpeg(t *testing.T) {
+	if os.Getenv("VRMIX_HELPER_FFMPEG") == "" {
+		return
+	}
+
+	if slices.Contains(os.Args, "missing.mp4") {
+		fmt.Fprint(os.Stderr, "missing.mp4: No such file or directory")
+		os.Exit(1)
+	}
+
+	playlist := os.Args[len(os.Args)-1]
+	header := "#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-TARGETDURATION:4\n#EXT-X-MEDIA-SEQUENCE:0\n"
+
+	// A subtitles extraction writes the playlist of the WebVTT segments as the segment list.
+	if i := slices.Index(os.Args, "-segment_list"); i >= 0 {
+		fmt.Print("out_time_us=6000000\nprogress=end\n")
+		os.WriteFile(os.Args[i+1], []byte(header+"#EXT-X-ALLOW-CACHE:YES\n#EXTINF:4.000000,\nsegment0.vtt\n#EXTINF:2.000000,\nsegment1.vtt\n#EXT-X-ENDLIST\n"), 0o644)
+		os.Exit(0)
+	}
+	if slices.Contains(os.Args, "fmp4") {
+		header = strings.Replace(header, "VERSION:3", "VERSION:7", 1) + "#EXT-X-MAP:URI=\"init.mp4\"\n"
+	}
+
+	fmt.Print("frame=10\nout_time_us=4000000\nprogress=continue\n")
+	fmt.Print("frame=20\nout_time_us=6000000\nprogress=end\n")
+	data := []byte(header + "#EXTINF:4.000000,\nsegment0.ts\n#EXTINF:2.000000,\nsegment1.ts\n#EXT-X-ENDLIST\n")
+
+	// A ladder conversion writes the playlist of every variant stream in the directory of its name.
+	if i := slices.Index(os.Args, "-var_stream_map"); i >= 0 {
+		for _, stream := range strings.Fields(os.Args[i+1]) {
+			_, name, _ := strings.Cut(stream, "name:")
+			variant := strings.ReplaceAll(playlist, "%v", name)
+			os.MkdirAll(filepath.Dir(variant), 0o755)
+			os.WriteFile(variant, data, 0o644)
+		}
+
+		os.Exit(0)
+	}
+
+	os.WriteFile(playlist, data, 0o644)
+	os.Exit(0)
+}
+
+// helperConverter returns a converter running TestHelperFFmpeg as ffmpeg
+func helperConverter(t *testing.T) *Converter {
+	t.Setenv("VRMIX_HELPER_FFMPEG", "1")
+	return &Converter{Binary: os.Args[0], prefixArgs: []string{"-test.run=TestHelperFFmpeg", "--"}}
+}
+
+func TestConvertArgs(t *testing.T) {
+	c := &Converter{}
+
+	args, _ := c.args("input.mkv", "/out", Options{VideoCodec: "libx265", VideoBitrate: 8000000, AudioBitrate: 128000, SegmentDuration: 2 * time.Second, Format: FormatFMP4})
+	joined := strings.Join(args, " ")
+	for _, expected := range []string{"-progress pipe:1", "-i input.mkv", "-c:v libx265 -b:v 8000000 -c:a aac -b:a 128000", "n_forced*2)", "-hls_time 2", "-hls_segment_type fmp4", "/out/segment%d.m4s /out/index.m3u8"} {
+		if !strings.Contains(joined, expected) {
+			t.Errorf("expected %q in %q", expected, joined)
+		}
+	}
+
+	args, _ = c.args("input.webm", "/out", Options{CodecArgs: []string{"-c:v", "libvpx", "-c:a", "copy"}})
+	joined = strings.Join(args, " ")
+	if !strings.Contains(joined, "-c:v libvpx -c:a copy -f hls -hls_time 4") || strings.Contains(joined, "libx264") {
+		t.Errorf("expected the codec arguments to replace the codecs, got %q", joined)
+	}
+
+	if _, err := c.args("input.mp4", "/out", Options{Format: "dash"}); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("expected an unknown format, got %v", err)
+	}
+}
+
+func TestConvert(t *testing.T) {
+	c := helperConverter(t)
+	dir := filepath.Join(t.TempDir(), "segments")
+
+	var progress []Progress
+	manifest, err := c.Convert(context.Background(), "input.mp4", dir, Options{Duration: 8 * time.Second}, func(p Progress) {
+		progress = append(progress, p)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if manifest.SegmentCount() != 2 || !manifest.HasEndList || manifest.SegmentGroups[0].Segments[1].Path != "segment1.ts" {
+		t.Errorf("expected the playlist of 2 segments, got %s", manifest.String())
+	}
+
+	expected := []Progress{{Time: 4 * time.Second, Fraction: 0.5}, {Time: 6 * time.Second, Fraction: 1, Done: true}}
+	if !slices.Equal(progress, expected) {
+		t.Errorf("expected %v, got %v", expected, progress)
+	}
+
+	manifest, err = c.Convert(context.Background(), "input.mp4", dir, Options{Format: FormatFMP4}, nil)
+	if err != nil || manifest.SegmentCount() != 2 || manifest.SegmentGroups[0].Map.URI != InitName {
+		t.Errorf("expected the fMP4 playlist with its initialization segment, got %v", err)
+	}
+}
+
+func TestConvertFailure(t *testing.T) {
+	c := helperConverter(t)
+
+	_, err := c.Convert(context.Background(), "missing.mp4", t.TempDir(), Options{}, nil)
+	if err == nil || !strings.Contains(err.Error(), "No such file") {
+		t.Errorf("expected the error of ffmpeg, got %v", err)
+	}
+}
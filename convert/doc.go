@@ -0,0 +1,2 @@
+// Package convert transcodes downloaded media into HLS segments with ffmpeg, reporting the progress of each conversion.
+package convert
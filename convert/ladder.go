@@ -0,0 +1,182 @@
+package convert
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"vrmix/hls"
+)
+
+// MasterName is the name of the master playlist written in the directory of a ladder conversion, each rendition being written in the subdirectory of its name.
+const MasterName = "master.m3u8"
+
+var (
+	// ErrNoRenditions indicates that a ladder has no renditions to convert.
+	ErrNoRenditions = errors.New("ladder without renditions")
+
+	// ErrInvalidRendition indicates that a rendition has no size or bitrate, or a name which is empty, duplicated or not a plain directory name.
+	ErrInvalidRendition = errors.New("invalid rendition")
+)
+
+// Rendition represents an encoding of a ladder, scaled from the same decoded frames of the input as the other renditions.
+type Rendition struct {
+	Name         string // Name of the rendition, naming its subdirectory, like "720p"
+	Width        int    // Width of the frames in pixels
+	Height       int    // Height of the frames in pixels
+	VideoBitrate int    // Target video bits per second
+	MaxBitrate   int    // Peak video bits per second, zero to leave it to the encoder
+	AudioBitrate int    // Audio bits per second, zero to leave it to the encoder
+}
+
+// DefaultLadder is the ladder of rectilinear videos, from the highest to the lowest quality.
+var DefaultLadder = []Rendition{
+	{Name: "1080p", Width: 1920, Height: 1080, VideoBitrate: 5_000_000, MaxBitrate: 7_500_000, AudioBitrate: 128_000},
+	{Name: "720p", Width: 1280, Height: 720, VideoBitrate: 3_000_000, MaxBitrate: 4_500_000, AudioBitrate: 128_000},
+	{Name: "480p", Width: 854, Height: 480, VideoBitrate: 1_200_000, MaxBitrate: 1_800_000, AudioBitrate: 96_000},
+}
+
+// Ladder represents the renditions written by a ladder conversion.
+type Ladder struct {
+	Master    hls.MasterManifest // Master playlist written as MasterName, referencing the media playlists of the renditions as "<name>/index.m3u8"
+	Manifests []hls.Manifest     // Media playlists of the renditions in the order of the ladder, their paths relative to the subdirectory of each rendition
+}
+
+// validate returns ErrNoRenditions or ErrInvalidRendition if the renditions cannot be converted together.
+func validate(renditions []Rendition) error {
+	if len(renditions) == 0 {
+		return ErrNoRenditions
+	}
+
+	names := map[string]bool{}
+	for _, rendition := range renditions {
+		// The name is a directory and a value of -var_stream_map, separated by spaces, commas and colons.
+		if rendition.Name == "" || rendition.Name == "." || rendition.Name == ".." || strings.ContainsAny(rendition.Name, " ,:/\\%") || names[rendition.Name] {
+			return ErrInvalidRendition
+		}
+
+		if rendition.Width <= 0 || rendition.Height <= 0 || rendition.VideoBitrate <= 0 {
+			return ErrInvalidRendition
+		}
+
+		names[rendition.Name] = true
+	}
+
+	return nil
+}
+
+// ladderArgs returns the arguments of ffmpeg decoding the input once and encoding it into the renditions, each written in the subdirectory of its name.
+func (c *Converter) ladderArgs(input string, dir string, renditions []Rendition, options Options) ([]string, error) {
+	if err := validate(renditions); err != nil {
+		return nil, err
+	}
+
+	segmentArgs, err := segmentArgs(options.Format, filepath.Join(dir, "%v"))
+	if err != nil {
+		return nil, err
+	}
+
+	seconds := strconv.FormatFloat(hls.SegmentDuration(options.SegmentDuration, defaultSegmentDuration).Seconds(), 'f', -1, 64)
+	videoCodec, audioCodec := options.codecs()
+
+	// The decoded frames are split once per rendition and scaled, so the input is only downloaded and decoded once.
+	var filter strings.Builder
+	filter.WriteString("[0:v:0]split=" + strconv.Itoa(len(renditions)))
+	for i := range renditions {
+		filter.WriteString("[s" + strconv.Itoa(i) + "]")
+	}
+
+	for i, rendition := range renditions {
+		filter.WriteString(";[s" + strconv.Itoa(i) + "]scale=" + strconv.Itoa(rendition.Width) + ":" + strconv.Itoa(rendition.Height) + "[v" + strconv.Itoa(i) + "]")
+	}
+
+	args := append([]string{}, c.prefixArgs...)
+	args = append(args, "-hide_banner", "-loglevel", "error", "-nostdin", "-nostats", "-progress", "pipe:1", "-y", "-i", input, "-filter_complex", filter.String())
+
+	streams := make([]string, 0, len(renditions))
+	for i, rendition := range renditions {
+		index := strconv.Itoa(i)
+		args = append(args, "-map", "[v"+index+"]")
+		stream := "v:" + index
+		if !options.NoAudio {
+			args = append(args, "-map", "0:a:0")
+			stream += ",a:" + index
+		}
+
+		streams = append(streams, stream+",name:"+rendition.Name)
+	}
+
+	args = append(args, "-c:v", videoCodec)
+	if !options.NoAudio {
+		args = append(args, "-c:a", audioCodec)
+	}
+
+	for i, rendition := range renditions {
+		index := strconv.Itoa(i)
+		args = append(args, "-b:v:"+index, strconv.Itoa(rendition.VideoBitrate))
+		if rendition.MaxBitrate > 0 {
+			args = append(args, "-maxrate:v:"+index, strconv.Itoa(rendition.MaxBitrate), "-bufsize:v:"+index, strconv.Itoa(rendition.MaxBitrate*2))
+		}
+
+		if !options.NoAudio && rendition.AudioBitrate > 0 {
+			args = append(args, "-b:a:"+index, strconv.Itoa(rendition.AudioBitrate))
+		}
+	}
+
+	args = append(args, keyframeArgs(seconds)...)
+	args = append(args, "-f", "hls", "-hls_time", seconds, "-hls_list_size", "0")
+	args = append(args, segmentArgs...)
+	args = append(args, "-var_stream_map", strings.Join(streams, " "))
+	return append(args, filepath.Join(dir, "%v", PlaylistName)), nil
+}
+
+// master returns the master playlist of the renditions, their bandwidth being the one requested as the encoders are not bound to report it.
+func master(renditions []Rendition) hls.MasterManifest {
+	// The keyframes forced at the start of every segment make the segments of the renditions independent and aligned.
+	manifest := hls.MasterManifest{Version: 3, IndependentSegments: true}
+	for _, rendition := range renditions {
+		peak := max(rendition.MaxBitrate, rendition.VideoBitrate)
+		manifest.Variants = append(manifest.Variants, hls.Variant{
+			URI:              path.Join(rendition.Name, PlaylistName),
+			Bandwidth:        peak + rendition.AudioBitrate,
+			AverageBandwidth: rendition.VideoBitrate + rendition.AudioBitrate,
+			Resolution:       strconv.Itoa(rendition.Width) + "x" + strconv.Itoa(rendition.Height),
+		})
+	}
+
+	return manifest
+}
+
+// ConvertLadder transcodes the input into every rendition of the ladder with a single ffmpeg process, calling progress, if not nil, as ffmpeg reports it, and writes the master playlist referencing them as MasterName in the directory.
+//
+// The VideoBitrate, AudioBitrate and CodecArgs of the options are replaced by the ones of the renditions.
+func (c *Converter) ConvertLadder(ctx context.Context, input string, dir string, renditions []Rendition, options Options, progress func(Progress)) (Ladder, error) {
+	args, err := c.ladderArgs(input, dir, renditions, options)
+	if err != nil {
+		return Ladder{}, err
+	}
+
+	if err := c.run(ctx, dir, args, options.Duration, progress); err != nil {
+		return Ladder{}, err
+	}
+
+	ladder := Ladder{Master: master(renditions)}
+	for _, rendition := range renditions {
+		manifest, err := readPlaylist(filepath.Join(dir, rendition.Name, PlaylistName))
+		if err != nil {
+			return Ladder{}, err
+		}
+
+		ladder.Manifests = append(ladder.Manifests, manifest)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, MasterName), []byte(ladder.Master.String()), 0o644); err != nil {
+		return Ladder{}, err
+	}
+
+	return ladder, nil
+}
@@ -0,0 +1,86 @@
+package convert
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"vrmix/hls"
+)
+
+func TestLadderArgs(t *testing.T) {
+	c := &Converter{}
+
+	args, err := c.ladderArgs("input.mkv", "/out", DefaultLadder[1:], Options{SegmentDuration: 2 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	joined := strings.Join(args, " ")
+	for _, expected := range []string{
+		"-i input.mkv -filter_complex [0:v:0]split=2[s0][s1];[s0]scale=1280:720[v0];[s1]scale=854:480[v1]",
+		"-map [v0] -map 0:a:0 -map [v1] -map 0:a:0 -c:v libx264 -c:a aac",
+		"-b:v:0 3000000 -maxrate:v:0 4500000 -bufsize:v:0 9000000 -b:a:0 128000 -b:v:1 1200000",
+		"n_forced*2)", "-hls_time 2", "/out/%v/segment%d.ts",
+		"-var_stream_map v:0,a:0,name:720p v:1,a:1,name:480p /out/%v/index.m3u8",
+	} {
+		if !strings.Contains(joined, expected) {
+			t.Errorf("expected %q in %q", expected, joined)
+		}
+	}
+
+	args, _ = c.ladderArgs("input.mkv", "/out", DefaultLadder[:1], Options{NoAudio: true})
+	joined = strings.Join(args, " ")
+	if strings.Contains(joined, "0:a:0") || strings.Contains(joined, "-c:a") || !strings.Contains(joined, "-var_stream_map v:0,name:1080p") {
+		t.Errorf("expected the renditions without audio, got %q", joined)
+	}
+
+	for _, renditions := range [][]Rendition{
+		nil,
+		{{Name: "720p", Width: 1280, Height: 720}},
+		{{Name: "a b", Width: 1280, Height: 720, VideoBitrate: 1}},
+		{{Name: "../720p", Width: 1280, Height: 720, VideoBitrate: 1}},
+		{DefaultLadder[0], DefaultLadder[0]},
+	} {
+		if _, err := c.ladderArgs("input.mkv", "/out", renditions, Options{}); !errors.Is(err, ErrNoRenditions) && !errors.Is(err, ErrInvalidRendition) {
+			t.Errorf("expected %+v to be rejected, got %v", renditions, err)
+		}
+	}
+
+	if _, err := c.ladderArgs("input.mkv", "/out", DefaultLadder, Options{Format: "dash"}); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("expected an unknown format, got %v", err)
+	}
+}
+
+func TestConvertLadder(t *testing.T) {
+	c := helperConverter(t)
+	dir := filepath.Join(t.TempDir(), "ladder")
+
+	ladder, err := c.ConvertLadder(context.Background(), "input.mp4", dir, DefaultLadder, Options{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ladder.Manifests) != 3 || ladder.Manifests[2].SegmentCount() != 2 {
+		t.Errorf("expected the playlist of every rendition, got %+v", ladder.Manifests)
+	}
+
+	variant := ladder.Master.Variants[1]
+	if len(ladder.Master.Variants) != 3 || variant.URI != "720p/index.m3u8" || variant.Bandwidth != 4_628_000 || variant.AverageBandwidth != 3_128_000 || variant.Resolution != "1280x720" {
+		t.Errorf("expected the variants of the renditions, got %+v", ladder.Master.Variants)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, MasterName))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	written, err := hls.ParseMasterManifest(string(data))
+	if err != nil || len(written.Variants) != 3 || written.Variants[2].URI != "480p/index.m3u8" {
+		t.Errorf("expected the master playlist to be written, got %s and %v", data, err)
+	}
+}
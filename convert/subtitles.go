@@ -0,0 +1,53 @@
+package convert
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+
+	"vrmix/hls"
+)
+
+// textSubtitlesCodecs are the codecs of the subtitles ffmpeg converts to WebVTT, the bitmap ones like PGS needing OCR.
+var textSubtitlesCodecs = []string{"mov_text", "subrip", "srt", "webvtt", "ass", "ssa", "text"}
+
+// SupportsSubtitles returns true if the subtitles of the codec, as reported by ffprobe, can be extracted into WebVTT, like mov_text and subrip.
+func SupportsSubtitles(codec string) bool {
+	return slices.Contains(textSubtitlesCodecs, codec)
+}
+
+// subtitlesArgs returns the arguments of ffmpeg extracting the subtitles stream of the input into WebVTT segments written in the directory.
+func (c *Converter) subtitlesArgs(input string, dir string, stream int, options Options) []string {
+	seconds := strconv.FormatFloat(hls.SegmentDuration(options.SegmentDuration, defaultSegmentDuration).Seconds(), 'f', -1, 64)
+
+	args := append([]string{}, c.prefixArgs...)
+	args = append(args, "-hide_banner", "-loglevel", "error", "-nostdin", "-nostats", "-progress", "pipe:1", "-y", "-i", input, "-map", "0:"+strconv.Itoa(stream), "-c:s", "webvtt")
+
+	// The segment muxer cuts the cues at the segment duration of the video, so the players load the captions along with the segments they play.
+	args = append(args, "-f", "segment", "-segment_format", "webvtt", "-segment_time", seconds, "-segment_list_type", "m3u8", "-segment_list", filepath.Join(dir, PlaylistName))
+	return append(args, filepath.Join(dir, "segment%d.vtt"))
+}
+
+// ExtractSubtitles converts the embedded subtitles stream of the input at the index, like a mov_text or subrip stream, into WebVTT segments written in the directory, calling progress, if not nil, as ffmpeg reports it, and returns the media playlist of the segments, to be offered as a subtitles rendition with hls.MasterManifest.AddSubtitles.
+func (c *Converter) ExtractSubtitles(ctx context.Context, input string, dir string, stream int, options Options, progress func(Progress)) (hls.Manifest, error) {
+	if err := c.run(ctx, dir, c.subtitlesArgs(input, dir, stream, options), options.Duration, progress); err != nil {
+		return hls.Manifest{}, err
+	}
+
+	file, err := os.Open(filepath.Join(dir, PlaylistName))
+	if err != nil {
+		return hls.Manifest{}, err
+	}
+	defer file.Close()
+
+	// The segment muxer writes the EXT-X-ALLOW-CACHE tag removed from HLS, which is dropped.
+	manifest, err := hls.ParseOptions{KeepUnknownTags: true}.ParseReader(file)
+	if err != nil {
+		return hls.Manifest{}, err
+	}
+
+	manifest.UnknownTags = nil
+	return manifest, nil
+}
@@ -0,0 +1,43 @@
+package convert
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSupportsSubtitles(t *testing.T) {
+	for codec, expected := range map[string]bool{"mov_text": true, "subrip": true, "ass": true, "hdmv_pgs_subtitle": false, "dvd_subtitle": false} {
+		if SupportsSubtitles(codec) != expected {
+			t.Errorf("expected %s to be supported %t", codec, expected)
+		}
+	}
+}
+
+func TestSubtitlesArgs(t *testing.T) {
+	c := &Converter{}
+
+	joined := strings.Join(c.subtitlesArgs("input.mp4", "/out", 3, Options{SegmentDuration: 6 * time.Second}), " ")
+	for _, expected := range []string{"-i input.mp4 -map 0:3 -c:s webvtt", "-f segment -segment_format webvtt -segment_time 6", "-segment_list /out/index.m3u8 /out/segment%d.vtt"} {
+		if !strings.Contains(joined, expected) {
+			t.Errorf("expected %q in %q", expected, joined)
+		}
+	}
+}
+
+func TestExtractSubtitles(t *testing.T) {
+	c := helperConverter(t)
+	dir := filepath.Join(t.TempDir(), "subtitles")
+
+	var done bool
+	manifest, err := c.ExtractSubtitles(context.Background(), "input.mp4", dir, 2, Options{}, func(p Progress) { done = p.Done })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !manifest.IsSubtitles() || manifest.SegmentCount() != 2 || !done {
+		t.Errorf("expected the playlist of the WebVTT segments, got %s", manifest.String())
+	}
+}
@@ -0,0 +1,358 @@
+package dash
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"math"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"vrmix/hls"
+)
+
+const (
+	// Namespace is the XML namespace of the MPD.
+	Namespace = "urn:mpeg:dash:schema:mpd:2011"
+
+	// ProfileLive is the profile of the MPDs of fragmented MP4 segments.
+	ProfileLive = "urn:mpeg:dash:profile:isoff-live:2011"
+
+	// ProfileMPEG2TS is the profile of the MPDs of MPEG transport stream segments.
+	ProfileMPEG2TS = "urn:mpeg:dash:profile:mp2t-main:2011"
+
+	// timescale is the units per second of the segment timelines, milliseconds being as precise as the EXTINF durations written.
+	timescale = 1000
+)
+
+var (
+	// ErrNoStreams indicates that no stream was given to convert.
+	ErrNoStreams = errors.New("no streams")
+
+	// ErrNoSegments indicates that a stream has no segments, or a group of segments without any.
+	ErrNoSegments = errors.New("stream without segments")
+
+	// ErrMissingBandwidth indicates that a stream has no bandwidth, required by every DASH representation.
+	ErrMissingBandwidth = errors.New("missing stream bandwidth")
+
+	// ErrMisaligned indicates that the streams have different numbers of discontinuities, so their periods cannot be shared.
+	ErrMisaligned = errors.New("streams with different discontinuities")
+
+	// ErrEncrypted indicates that a stream has encrypted segments, whose keys DASH cannot declare as HLS does.
+	ErrEncrypted = errors.New("encrypted segments are not supported")
+
+	// ErrMissingAvailabilityStart indicates that a live stream was converted without the time it became available.
+	ErrMissingAvailabilityStart = errors.New("missing availability start of the live stream")
+)
+
+// Stream represents a variant converted into a representation of the MPD.
+type Stream struct {
+	ID       string        // ID of the representation, defaults to its index
+	Variant  hls.Variant   // Bandwidth, codecs and resolution of the stream, the bandwidth being required
+	Manifest *hls.Manifest // Media playlist of the stream, its segment paths and maps written as they are
+}
+
+// Options represents how the streams are converted.
+type Options struct {
+	BaseURL           string    // URL the segment paths are relative to, empty for the URL of the MPD
+	AvailabilityStart time.Time // Time the first segment of the playlist of a live stream started, required when the playlists have no EXT-X-ENDLIST tag
+}
+
+// MPD represents a MPEG-DASH media presentation description, limited to what a HLS media playlist can describe.
+type MPD struct {
+	XMLName                   xml.Name `xml:"MPD"`
+	Namespace                 string   `xml:"xmlns,attr"`
+	Profiles                  string   `xml:"profiles,attr"`
+	Type                      string   `xml:"type,attr"`
+	AvailabilityStartTime     string   `xml:"availabilityStartTime,attr,omitempty"`
+	MediaPresentationDuration string   `xml:"mediaPresentationDuration,attr,omitempty"`
+	MinimumUpdatePeriod       string   `xml:"minimumUpdatePeriod,attr,omitempty"`
+	TimeShiftBufferDepth      string   `xml:"timeShiftBufferDepth,attr,omitempty"`
+	MinBufferTime             string   `xml:"minBufferTime,attr"`
+	BaseURL                   string   `xml:"BaseURL,omitempty"`
+	Periods                   []Period `xml:"Period"`
+}
+
+// Period represents a part of the presentation, one for each group of segments between discontinuities.
+type Period struct {
+	ID             string          `xml:"id,attr"`
+	Start          string          `xml:"start,attr"`
+	AdaptationSets []AdaptationSet `xml:"AdaptationSet"`
+}
+
+// AdaptationSet represents the switchable representations of a period.
+type AdaptationSet struct {
+	MimeType         string           `xml:"mimeType,attr"`
+	SegmentAlignment bool             `xml:"segmentAlignment,attr"`
+	Representations  []Representation `xml:"Representation"`
+}
+
+// Representation represents a stream of a period.
+type Representation struct {
+	ID              string           `xml:"id,attr"`
+	Bandwidth       int              `xml:"bandwidth,attr"`
+	Codecs          string           `xml:"codecs,attr,omitempty"`
+	Width           int              `xml:"width,attr,omitempty"`
+	Height          int              `xml:"height,attr,omitempty"`
+	SegmentTemplate *SegmentTemplate `xml:"SegmentTemplate,omitempty"`
+	SegmentList     *SegmentList     `xml:"SegmentList,omitempty"`
+}
+
+// SegmentTemplate represents segments whose paths only differ by their number.
+type SegmentTemplate struct {
+	Timescale       int             `xml:"timescale,attr"`
+	Media           string          `xml:"media,attr"`
+	Initialization  string          `xml:"initialization,attr,omitempty"`
+	StartNumber     int             `xml:"startNumber,attr"`
+	SegmentTimeline SegmentTimeline `xml:"SegmentTimeline"`
+}
+
+// SegmentList represents segments listed one by one, like the sub-ranges of a single file.
+type SegmentList struct {
+	Timescale       int             `xml:"timescale,attr"`
+	Initialization  *URL            `xml:"Initialization,omitempty"`
+	SegmentTimeline SegmentTimeline `xml:"SegmentTimeline"`
+	SegmentURLs     []SegmentURL    `xml:"SegmentURL"`
+}
+
+// URL represents a resource, or a sub-range of it.
+type URL struct {
+	SourceURL string `xml:"sourceURL,attr"`
+	Range     string `xml:"range,attr,omitempty"`
+}
+
+// SegmentURL represents a segment of a SegmentList.
+type SegmentURL struct {
+	Media      string `xml:"media,attr"`
+	MediaRange string `xml:"mediaRange,attr,omitempty"`
+}
+
+// SegmentTimeline represents the durations of the segments, in units of the timescale.
+type SegmentTimeline struct {
+	Segments []S `xml:"S"`
+}
+
+// S represents a run of segments with the same duration.
+type S struct {
+	Time     *int64 `xml:"t,attr"`
+	Duration int64  `xml:"d,attr"`
+	Repeat   int    `xml:"r,attr,omitempty"`
+}
+
+// FromManifest converts a single media playlist into a MPD, its variant giving the bandwidth of the representation.
+func FromManifest(manifest *hls.Manifest, variant hls.Variant, options Options) (*MPD, error) {
+	return New([]Stream{{Variant: variant, Manifest: manifest}}, options)
+}
+
+// New converts the streams into a MPD, each segment group becoming a period shared by the streams, the segments being served from the same paths.
+func New(streams []Stream, options Options) (*MPD, error) {
+	if len(streams) == 0 {
+		return nil, ErrNoStreams
+	}
+
+	first := streams[0].Manifest
+	for _, stream := range streams {
+		if stream.Variant.Bandwidth <= 0 {
+			return nil, ErrMissingBandwidth
+		}
+
+		if len(stream.Manifest.SegmentGroups) != len(first.SegmentGroups) {
+			return nil, ErrMisaligned
+		}
+
+		if len(stream.Manifest.SegmentGroups) == 0 {
+			return nil, ErrNoSegments
+		}
+
+		for _, group := range stream.Manifest.SegmentGroups {
+			if len(group.Segments) == 0 {
+				return nil, ErrNoSegments
+			}
+
+			for _, segment := range group.Segments {
+				if len(segment.Keys) > 0 {
+					return nil, ErrEncrypted
+				}
+			}
+		}
+	}
+
+	mpd := &MPD{Namespace: Namespace, Profiles: ProfileLive, Type: "static", MinBufferTime: duration(float64(first.TargetDuration) * 2), BaseURL: options.BaseURL}
+	if first.HasEndList {
+		mpd.MediaPresentationDuration = duration(first.Duration())
+	} else {
+		if options.AvailabilityStart.IsZero() {
+			return nil, ErrMissingAvailabilityStart
+		}
+
+		mpd.Type = "dynamic"
+		mpd.AvailabilityStartTime = options.AvailabilityStart.UTC().Format(time.RFC3339)
+		mpd.MinimumUpdatePeriod = duration(float64(first.TargetDuration))
+		mpd.TimeShiftBufferDepth = duration(first.Duration())
+	}
+
+	// The periods start with their group, the first one at the availability start of a live stream.
+	start := 0.0
+	for i := range first.SegmentGroups {
+		mimeType := mimeTypeOf(first.SegmentGroups[i].Segments[0].Path)
+		if mimeType == "video/mp2t" {
+			mpd.Profiles = ProfileMPEG2TS
+		}
+
+		adaptationSet := AdaptationSet{MimeType: mimeType, SegmentAlignment: true}
+		for j, stream := range streams {
+			representation := Representation{ID: stream.ID, Bandwidth: stream.Variant.Bandwidth, Codecs: stream.Variant.Codecs}
+			if representation.ID == "" {
+				representation.ID = strconv.Itoa(j)
+			}
+
+			representation.Width, representation.Height = resolution(stream.Variant.Resolution)
+			group := &stream.Manifest.SegmentGroups[i]
+			if template, ok := templateOf(group); ok {
+				representation.SegmentTemplate = template
+			} else {
+				representation.SegmentList = listOf(group)
+			}
+
+			adaptationSet.Representations = append(adaptationSet.Representations, representation)
+		}
+
+		mpd.Periods = append(mpd.Periods, Period{ID: strconv.Itoa(int(first.DiscontinuitySequence) + i), Start: duration(start), AdaptationSets: []AdaptationSet{adaptationSet}})
+		start += first.SegmentGroups[i].Duration()
+	}
+
+	return mpd, nil
+}
+
+// WriteTo writes the MPD as an indented XML document.
+func (m *MPD) WriteTo(w io.Writer) (int64, error) {
+	data, err := xml.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := io.WriteString(w, xml.Header+string(data)+"\n")
+	return int64(n), err
+}
+
+// templateOf returns the template of the group when its paths only differ by consecutive numbers and its segments are whole resources.
+func templateOf(group *hls.SegmentGroup) (*SegmentTemplate, bool) {
+	if group.Map.ByteRange.Length > 0 || strings.Contains(group.Map.URI, "$") {
+		return nil, false
+	}
+
+	prefix, number, suffix, ok := splitNumber(group.Segments[0].Path)
+	if !ok || strings.Contains(prefix+suffix, "$") {
+		return nil, false
+	}
+
+	for i, segment := range group.Segments {
+		p, n, s, ok := splitNumber(segment.Path)
+		if !ok || p != prefix || s != suffix || n != number+i || segment.ByteRange.Length > 0 {
+			return nil, false
+		}
+	}
+
+	return &SegmentTemplate{Timescale: timescale, Media: prefix + "$Number$" + suffix, Initialization: group.Map.URI, StartNumber: number, SegmentTimeline: timelineOf(group)}, true
+}
+
+// listOf returns the list of the segments of the group.
+func listOf(group *hls.SegmentGroup) *SegmentList {
+	list := &SegmentList{Timescale: timescale, SegmentTimeline: timelineOf(group)}
+	if group.Map.URI != "" {
+		list.Initialization = &URL{SourceURL: group.Map.URI, Range: rangeOf(group.Map.ByteRange)}
+	}
+
+	for _, segment := range group.Segments {
+		list.SegmentURLs = append(list.SegmentURLs, SegmentURL{Media: segment.Path, MediaRange: rangeOf(segment.ByteRange)})
+	}
+
+	return list
+}
+
+// timelineOf returns the timeline of the segments of the group, starting at zero in its period.
+func timelineOf(group *hls.SegmentGroup) SegmentTimeline {
+	var timeline SegmentTimeline
+	for i, segment := range group.Segments {
+		d := int64(math.Round(float64(segment.Duration) * timescale))
+		if last := len(timeline.Segments) - 1; last >= 0 && timeline.Segments[last].Duration == d {
+			timeline.Segments[last].Repeat++
+			continue
+		}
+
+		s := S{Duration: d}
+		if i == 0 {
+			s.Time = new(int64)
+		}
+
+		timeline.Segments = append(timeline.Segments, s)
+	}
+
+	return timeline
+}
+
+// splitNumber splits the path around the last number of its name before the extension, failing when there is none or it has leading zeros.
+func splitNumber(p string) (string, int, string, bool) {
+	base := strings.LastIndexByte(p, '/') + 1
+	end := len(p) - len(path.Ext(p[base:]))
+	for end > base && (p[end-1] < '0' || p[end-1] > '9') {
+		end--
+	}
+
+	begin := end
+	for begin > base && p[begin-1] >= '0' && p[begin-1] <= '9' {
+		begin--
+	}
+
+	if begin == end || (p[begin] == '0' && end-begin > 1) {
+		return "", 0, "", false
+	}
+
+	number, err := strconv.Atoi(p[begin:end])
+	if err != nil {
+		return "", 0, "", false
+	}
+
+	return p[:begin], number, p[end:], true
+}
+
+// rangeOf returns the byte range as the inclusive "<first>-<last>" of DASH, empty for the whole resource.
+func rangeOf(r hls.ByteRange) string {
+	if r.Length == 0 {
+		return ""
+	}
+
+	return strconv.FormatUint(r.Offset, 10) + "-" + strconv.FormatUint(r.End()-1, 10)
+}
+
+// mimeTypeOf returns the MIME type of the segments with the path.
+func mimeTypeOf(p string) string {
+	if strings.EqualFold(path.Ext(strings.SplitN(p, "?", 2)[0]), ".ts") {
+		return "video/mp2t"
+	}
+
+	return "video/mp4"
+}
+
+// resolution parses a resolution like "1920x1080", returning zeros if it is unknown or malformed.
+func resolution(value string) (int, int) {
+	width, height, found := strings.Cut(value, "x")
+	w, err := strconv.Atoi(width)
+	if !found || err != nil {
+		return 0, 0
+	}
+
+	h, err := strconv.Atoi(height)
+	if err != nil {
+		return 0, 0
+	}
+
+	return w, h
+}
+
+// duration formats seconds as a xs:duration, like "PT4.5S".
+func duration(seconds float64) string {
+	return "PT" + strconv.FormatFloat(math.Round(seconds*timescale)/timescale, 'f', -1, 64) + "S"
+}
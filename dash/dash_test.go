@@ -0,0 +1,203 @@
+package dash
+
+import (
+	"encoding/xml"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"vrmix/hls"
+)
+
+// parse parses a media playlist
+func parse(t *testing.T, data string) *hls.Manifest {
+	t.Helper()
+
+	manifest, err := hls.ParseHlsManifest(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &manifest
+}
+
+// write returns the MPD as XML
+func write(t *testing.T, mpd *MPD) string {
+	t.Helper()
+
+	var builder strings.Builder
+	if _, err := mpd.WriteTo(&builder); err != nil {
+		t.Fatal(err)
+	}
+
+	return builder.String()
+}
+
+func TestFromManifestTemplate(t *testing.T) {
+	manifest := parse(t, `#EXTM3U
+#EXT-X-VERSION:6
+#EXT-X-TARGETDURATION:4
+#EXT-X-MAP:URI="init.mp4"
+#EXTINF:4,
+segment1.m4s
+#EXTINF:4,
+segment2.m4s
+#EXTINF:2.5,
+segment3.m4s
+#EXT-X-ENDLIST`)
+
+	mpd, err := FromManifest(manifest, hls.Variant{Bandwidth: 5000000, Codecs: "avc1.640028,mp4a.40.2", Resolution: "3840x1920"}, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if mpd.Type != "static" || mpd.MediaPresentationDuration != "PT10.5S" || mpd.Profiles != ProfileLive {
+		t.Errorf("expected a static fragmented MP4 presentation of 10.5 seconds, got %+v", mpd)
+	}
+
+	representation := mpd.Periods[0].AdaptationSets[0].Representations[0]
+	if representation.ID != "0" || representation.Width != 3840 || representation.Height != 1920 || representation.SegmentList != nil {
+		t.Errorf("expected a templated 3840x1920 representation, got %+v", representation)
+	}
+
+	template := representation.SegmentTemplate
+	if template == nil || template.Media != "segment$Number$.m4s" || template.StartNumber != 1 || template.Initialization != "init.mp4" {
+		t.Fatalf("expected the paths to be templated from segment 1, got %+v", template)
+	}
+
+	timeline := template.SegmentTimeline.Segments
+	if len(timeline) != 2 || *timeline[0].Time != 0 || timeline[0].Duration != 4000 || timeline[0].Repeat != 1 || timeline[1].Duration != 2500 || timeline[1].Time != nil {
+		t.Errorf("expected the timeline to repeat the equal durations, got %+v", timeline)
+	}
+
+	output := write(t, mpd)
+	if !strings.HasPrefix(output, xml.Header) || !strings.Contains(output, `<S t="0" d="4000" r="1"></S>`) || !strings.Contains(output, `mimeType="video/mp4"`) {
+		t.Errorf("expected the MPD as XML, got %s", output)
+	}
+
+	var decoded MPD
+	if err := xml.Unmarshal([]byte(output), &decoded); err != nil || decoded.Periods[0].AdaptationSets[0].Representations[0].Bandwidth != 5000000 {
+		t.Errorf("expected the MPD to be decoded back, got %v", err)
+	}
+}
+
+func TestFromManifestList(t *testing.T) {
+	manifest := parse(t, `#EXTM3U
+#EXT-X-VERSION:6
+#EXT-X-TARGETDURATION:4
+#EXT-X-MAP:URI="single.mp4",BYTERANGE="720@0"
+#EXTINF:4,
+#EXT-X-BYTERANGE:1000@720
+single.mp4
+#EXTINF:4,
+#EXT-X-BYTERANGE:1000
+single.mp4
+#EXT-DISCONTINUITY
+#EXTINF:4,
+intro.m4s
+#EXTINF:4,
+outro.m4s
+#EXT-X-ENDLIST`)
+
+	mpd, err := FromManifest(manifest, hls.Variant{Bandwidth: 1000000}, Options{BaseURL: "https://cdn.example/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(mpd.Periods) != 2 || mpd.Periods[1].Start != "PT8S" || mpd.BaseURL != "https://cdn.example/" {
+		t.Fatalf("expected a period for each group, got %+v", mpd.Periods)
+	}
+
+	list := mpd.Periods[0].AdaptationSets[0].Representations[0].SegmentList
+	if list == nil || *list.Initialization != (URL{SourceURL: "single.mp4", Range: "0-719"}) {
+		t.Fatalf("expected the sub-ranges to be listed with their initialization section, got %+v", list)
+	}
+
+	expected := []SegmentURL{{Media: "single.mp4", MediaRange: "720-1719"}, {Media: "single.mp4", MediaRange: "1720-2719"}}
+	if len(list.SegmentURLs) != 2 || list.SegmentURLs[0] != expected[0] || list.SegmentURLs[1] != expected[1] {
+		t.Errorf("expected the segments %+v, got %+v", expected, list.SegmentURLs)
+	}
+
+	// Names without numbers are listed too, the initialization section applying until replaced.
+	if representation := mpd.Periods[1].AdaptationSets[0].Representations[0]; representation.SegmentList == nil || representation.SegmentList.Initialization.SourceURL != "single.mp4" {
+		t.Errorf("expected the segments to be listed with the initialization section, got %+v", representation)
+	}
+}
+
+func TestNewLive(t *testing.T) {
+	manifest := parse(t, `#EXTM3U
+#EXT-X-TARGETDURATION:4
+#EXT-X-MEDIA-SEQUENCE:40
+#EXTINF:4,
+low/40.ts
+#EXTINF:4,
+low/41.ts`)
+
+	high := *manifest
+	high.SegmentGroups = []hls.SegmentGroup{{Segments: []hls.Segment{{Path: "high/40.ts", Duration: 4}, {Path: "high/41.ts", Duration: 4}}}}
+
+	if _, err := FromManifest(manifest, hls.Variant{Bandwidth: 1}, Options{}); !errors.Is(err, ErrMissingAvailabilityStart) {
+		t.Errorf("expected %v, got %v", ErrMissingAvailabilityStart, err)
+	}
+
+	start := time.Date(2024, 1, 1, 0, 2, 40, 0, time.UTC)
+	mpd, err := New([]Stream{{ID: "low", Variant: hls.Variant{Bandwidth: 800000}, Manifest: manifest}, {ID: "high", Variant: hls.Variant{Bandwidth: 5000000}, Manifest: &high}}, Options{AvailabilityStart: start})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if mpd.Type != "dynamic" || mpd.AvailabilityStartTime != "2024-01-01T00:02:40Z" || mpd.MinimumUpdatePeriod != "PT4S" || mpd.TimeShiftBufferDepth != "PT8S" || mpd.Profiles != ProfileMPEG2TS {
+		t.Errorf("expected a dynamic MPEG-TS presentation, got %+v", mpd)
+	}
+
+	representations := mpd.Periods[0].AdaptationSets[0].Representations
+	if len(representations) != 2 || representations[1].ID != "high" || representations[1].SegmentTemplate.Media != "high/$Number$.ts" || representations[1].SegmentTemplate.StartNumber != 40 {
+		t.Errorf("expected a templated representation for each stream, got %+v", representations)
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	plain := parse(t, "#EXTM3U\n#EXTINF:4,\na.ts\n#EXT-X-ENDLIST")
+	encrypted := parse(t, "#EXTM3U\n#EXT-X-KEY:METHOD=AES-128,URI=\"key\"\n#EXTINF:4,\na.ts\n#EXT-X-ENDLIST")
+	discontinuous := parse(t, "#EXTM3U\n#EXTINF:4,\na.ts\n#EXT-DISCONTINUITY\n#EXTINF:4,\nb.ts\n#EXT-X-ENDLIST")
+	empty := &hls.Manifest{HasEndList: true}
+
+	for _, test := range []struct {
+		name     string
+		streams  []Stream
+		expected error
+	}{
+		{"no streams", nil, ErrNoStreams},
+		{"no segments", []Stream{{Variant: hls.Variant{Bandwidth: 1}, Manifest: empty}}, ErrNoSegments},
+		{"no bandwidth", []Stream{{Manifest: plain}}, ErrMissingBandwidth},
+		{"encrypted", []Stream{{Variant: hls.Variant{Bandwidth: 1}, Manifest: encrypted}}, ErrEncrypted},
+		{"misaligned", []Stream{{Variant: hls.Variant{Bandwidth: 1}, Manifest: plain}, {Variant: hls.Variant{Bandwidth: 1}, Manifest: discontinuous}}, ErrMisaligned},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := New(test.streams, Options{}); !errors.Is(err, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, err)
+			}
+		})
+	}
+}
+
+func TestSplitNumber(t *testing.T) {
+	for p, expected := range map[string]struct {
+		prefix string
+		number int
+		suffix string
+		ok     bool
+	}{
+		"segment12.ts":     {"segment", 12, ".ts", true},
+		"v2/segment0.m4s":  {"v2/segment", 0, ".m4s", true},
+		"v2/segment.ts":    {"", 0, "", false},
+		"segment007.ts":    {"", 0, "", false},
+		"a/b-3-part-14.ts": {"a/b-3-part-", 14, ".ts", true},
+	} {
+		prefix, number, suffix, ok := splitNumber(p)
+		if prefix != expected.prefix || number != expected.number || suffix != expected.suffix || ok != expected.ok {
+			t.Errorf("expected %s to be split as %+v, got %q %d %q %v", p, expected, prefix, number, suffix, ok)
+		}
+	}
+}
@@ -0,0 +1,2 @@
+// Package dash converts HLS media playlists into MPEG-DASH manifests, serving the clients that only speak DASH from the same segments.
+package dash
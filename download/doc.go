@@ -0,0 +1,2 @@
+// Package download fetches the segments of the sources into the cache, retrying and resuming failed transfers within per-host and bandwidth limits.
+package download
@@ -0,0 +1,336 @@
+package download
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"vrmix/cache"
+	"vrmix/logging"
+	"vrmix/scheduler"
+)
+
+var (
+	// ErrUnexpectedStatus indicates that the origin answered with a status other than the segment.
+	ErrUnexpectedStatus = errors.New("unexpected status")
+	// ErrTooLarge indicates that the segment is larger than the configured maximum size.
+	ErrTooLarge = errors.New("segment too large")
+)
+
+const (
+	// chunkSize is the size of the reads of the bodies, the unit in which the bandwidth is consumed.
+	chunkSize = 32 * 1024
+
+	// maxPreallocation is the largest buffer allocated from the length announced by an origin.
+	maxPreallocation = 64 << 20
+)
+
+// Config represents the configuration of a Downloader.
+type Config struct {
+	Client         *http.Client  // Client fetching the segments, defaults to http.DefaultClient
+	Retries        int           // Retries after a failed attempt, defaults to 3, negative for none
+	Backoff        time.Duration // Delay before the first retry, doubled on each retry, defaults to 500 milliseconds
+	MaxBackoff     time.Duration // Longest delay between retries, defaults to 10 seconds
+	PerHost        int           // Concurrent downloads from each host, defaults to 4
+	BytesPerSecond int64         // Bandwidth shared by every download, zero for no limit
+	Cache          *cache.Cache  // Cache the segments are written into, nil to only return them
+	TTL            time.Duration // Time the segments are cached, zero for the TTL of the cache
+	MaxSize        int64         // Largest segment accepted, defaults to 64 MiB
+
+	// Logger receives the retried attempts, defaults to slog.Default.
+	Logger *slog.Logger
+}
+
+// Downloader fetches segments into the cache, resuming the interrupted transfers with Range requests.
+type Downloader struct {
+	config    Config
+	mutex     sync.Mutex
+	hosts     map[string]chan struct{} // slots of the concurrent downloads of each host
+	bandwidth *limiter                 // nil without a bandwidth limit
+}
+
+// New creates a new Downloader.
+func New(config Config) *Downloader {
+	if config.Client == nil {
+		config.Client = http.DefaultClient
+	}
+
+	if config.Retries == 0 {
+		config.Retries = 3
+	}
+
+	if config.Backoff <= 0 {
+		config.Backoff = 500 * time.Millisecond
+	}
+
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = 10 * time.Second
+	}
+
+	if config.PerHost <= 0 {
+		config.PerHost = 4
+	}
+
+	if config.MaxSize <= 0 {
+		config.MaxSize = 64 << 20
+	}
+
+	d := &Downloader{config: config, hosts: map[string]chan struct{}{}}
+	if config.BytesPerSecond > 0 {
+		d.bandwidth = newLimiter(float64(config.BytesPerSecond))
+	}
+
+	return d
+}
+
+// Download fetches the segment at the URL, writes it into the cache with the key and returns it.
+func (d *Downloader) Download(ctx context.Context, key string, rawURL string) ([]byte, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	release, err := d.acquire(ctx, u.Host)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	var t transfer
+	backoff := d.config.Backoff
+	for attempt := 0; ; attempt++ {
+		err = d.attempt(ctx, u, &t)
+		if err == nil {
+			break
+		}
+
+		var permanent permanentError
+		if errors.As(err, &permanent) || attempt >= d.config.Retries || ctx.Err() != nil {
+			return nil, err
+		}
+
+		logging.Or(d.config.Logger).Warn("segment download failed, retrying", slog.String("url", rawURL), slog.Int("received", len(t.data)), logging.Err(err))
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+
+		backoff = min(backoff*2, d.config.MaxBackoff)
+	}
+
+	if d.config.Cache != nil {
+		if err := d.config.Cache.Put(key, t.data, d.config.TTL); err != nil {
+			return nil, err
+		}
+	}
+
+	return t.data, nil
+}
+
+// Job returns the scheduler job downloading the segment into the cache, identified by its key.
+func (d *Downloader) Job(key string, rawURL string, priority scheduler.Priority) scheduler.Job {
+	return scheduler.Job{ID: key, Kind: scheduler.KindDownload, Priority: priority, Run: func(ctx context.Context) error {
+		_, err := d.Download(ctx, key, rawURL)
+		return err
+	}}
+}
+
+// acquire waits for a download slot of the host, returning the function releasing it.
+func (d *Downloader) acquire(ctx context.Context, host string) (func(), error) {
+	d.mutex.Lock()
+	slots := d.hosts[host]
+	if slots == nil {
+		slots = make(chan struct{}, d.config.PerHost)
+		d.hosts[host] = slots
+	}
+	d.mutex.Unlock()
+
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// transfer is the state of a download kept between its attempts.
+type transfer struct {
+	data      []byte
+	validator string // ETag or Last-Modified of the first response, so a resumed transfer fails over to the whole segment if it changed
+}
+
+// permanentError is an error retrying cannot fix, like a missing segment.
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string { return e.err.Error() }
+
+func (e permanentError) Unwrap() error { return e.err }
+
+// attempt fetches the segment, or the rest of it when a previous attempt was interrupted.
+func (d *Downloader) attempt(ctx context.Context, u *url.URL, t *transfer) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return permanentError{err}
+	}
+
+	resuming := len(t.data) > 0
+	if resuming {
+		req.Header.Set("Range", "bytes="+strconv.Itoa(len(t.data))+"-")
+		if t.validator != "" {
+			req.Header.Set("If-Range", t.validator)
+		}
+	}
+
+	resp, err := d.config.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		// The whole segment is received, even when resuming if the origin ignored the range or the segment changed.
+		t.data = t.data[:0]
+		t.validator = resp.Header.Get("ETag")
+		if t.validator == "" {
+			t.validator = resp.Header.Get("Last-Modified")
+		}
+	case resp.StatusCode == http.StatusPartialContent && resuming && rangeStart(resp.Header.Get("Content-Range")) == len(t.data):
+	case resp.StatusCode == http.StatusPartialContent && resuming:
+		// A range other than the one requested is dropped, the next attempt receiving the whole segment.
+		t.data, t.validator = t.data[:0], ""
+		return fmt.Errorf("%w: %d", ErrUnexpectedStatus, resp.StatusCode)
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return fmt.Errorf("%w: %d", ErrUnexpectedStatus, resp.StatusCode)
+	default:
+		return permanentError{fmt.Errorf("%w: %d", ErrUnexpectedStatus, resp.StatusCode)}
+	}
+
+	if resp.ContentLength > d.config.MaxSize-int64(len(t.data)) {
+		return permanentError{fmt.Errorf("%w: %d bytes", ErrTooLarge, int64(len(t.data))+resp.ContentLength)}
+	}
+
+	// The announced length only sizes the buffer up to a bound, as it comes from the origin.
+	if expected := int(min(resp.ContentLength, maxPreallocation)); cap(t.data)-len(t.data) < expected {
+		data := make([]byte, len(t.data), len(t.data)+expected)
+		copy(data, t.data)
+		t.data = data
+	}
+
+	buffer := make([]byte, chunkSize)
+	for {
+		if d.bandwidth != nil {
+			if err := d.bandwidth.wait(ctx, chunkSize); err != nil {
+				return err
+			}
+		}
+
+		n, err := resp.Body.Read(buffer)
+		t.data = append(t.data, buffer[:n]...)
+		if d.bandwidth != nil && n < chunkSize {
+			d.bandwidth.refund(chunkSize - n)
+		}
+
+		if int64(len(t.data)) > d.config.MaxSize {
+			// An origin sending more than it announced, or without announcing, is cut off at the maximum size.
+			t.data, t.validator = t.data[:0], ""
+			return permanentError{fmt.Errorf("%w: over %d bytes", ErrTooLarge, d.config.MaxSize)}
+		}
+
+		if errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+// rangeStart returns the first byte of a Content-Range header, or -1 if it is malformed.
+func rangeStart(contentRange string) int {
+	bytesRange, found := strings.CutPrefix(contentRange, "bytes ")
+	if !found {
+		return -1
+	}
+
+	start, _, _ := strings.Cut(bytesRange, "-")
+	value, err := strconv.Atoi(start)
+	if err != nil {
+		return -1
+	}
+
+	return value
+}
+
+// limiter is a token bucket of bytes, holding up to a second of bandwidth.
+type limiter struct {
+	mutex  sync.Mutex
+	rate   float64 // bytes per second
+	tokens float64 // bytes available, negative when the reads are ahead of the rate
+	last   time.Time
+	now    func() time.Time
+}
+
+// newLimiter creates a full limiter of the rate.
+func newLimiter(rate float64) *limiter {
+	return &limiter{rate: rate, tokens: rate, now: time.Now}
+}
+
+// reserve consumes n bytes, returning how long to wait until the bandwidth allows them.
+func (l *limiter) reserve(n int) time.Duration {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := l.now()
+	if !l.last.IsZero() {
+		l.tokens = min(l.rate, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	}
+
+	l.last = now
+	l.tokens -= float64(n)
+	if l.tokens >= 0 {
+		return 0
+	}
+
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
+
+// refund returns bytes reserved but not read.
+func (l *limiter) refund(n int) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.tokens = min(l.rate, l.tokens+float64(n))
+}
+
+// wait consumes n bytes, waiting until the bandwidth allows them or the context is done.
+func (l *limiter) wait(ctx context.Context, n int) error {
+	delay := l.reserve(n)
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		l.refund(n)
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,204 @@
+package download
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"vrmix/cache"
+	"vrmix/scheduler"
+)
+
+// segmentData is the content of the segments served by the test origins
+var segmentData = bytes.Repeat([]byte("0123456789"), 10000)
+
+func TestDownloadRetry(t *testing.T) {
+	var requests atomic.Int32
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		w.Write(segmentData)
+	}))
+	defer origin.Close()
+
+	segments, err := cache.Open(cache.Config{Dir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := New(Config{Backoff: time.Millisecond, Cache: segments})
+	data, err := d.Download(context.Background(), "main/0.ts", origin.URL+"/0.ts")
+	if err != nil || !bytes.Equal(data, segmentData) || requests.Load() != 3 {
+		t.Fatalf("expected the segment after 2 retries, got %d requests and %v", requests.Load(), err)
+	}
+
+	if cached, err := segments.Get("main/0.ts"); err != nil || !bytes.Equal(cached, segmentData) {
+		t.Errorf("expected the segment to be cached, got %v", err)
+	}
+
+	d = New(Config{Backoff: time.Millisecond, Retries: -1})
+	requests.Store(0)
+	if _, err := d.Download(context.Background(), "main/0.ts", origin.URL+"/0.ts"); !errors.Is(err, ErrUnexpectedStatus) || requests.Load() != 1 {
+		t.Errorf("expected a single attempt, got %d requests and %v", requests.Load(), err)
+	}
+}
+
+func TestDownloadPermanentFailure(t *testing.T) {
+	var requests atomic.Int32
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		http.NotFound(w, r)
+	}))
+	defer origin.Close()
+
+	d := New(Config{Backoff: time.Millisecond})
+	if _, err := d.Download(context.Background(), "main/0.ts", origin.URL+"/0.ts"); !errors.Is(err, ErrUnexpectedStatus) || requests.Load() != 1 {
+		t.Errorf("expected a missing segment not to be retried, got %d requests and %v", requests.Load(), err)
+	}
+}
+
+func TestDownloadMaxSize(t *testing.T) {
+	var requests atomic.Int32
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.URL.Path == "/chunked.ts" {
+			// Flushing first sends the segment without a Content-Length.
+			w.(http.Flusher).Flush()
+		}
+
+		w.Write(segmentData)
+	}))
+	defer origin.Close()
+
+	d := New(Config{Backoff: time.Millisecond, MaxSize: int64(len(segmentData)) - 1})
+	for _, path := range []string{"/0.ts", "/chunked.ts"} {
+		requests.Store(0)
+		if _, err := d.Download(context.Background(), "main"+path, origin.URL+path); !errors.Is(err, ErrTooLarge) || requests.Load() != 1 {
+			t.Errorf("expected %s to be refused once, got %d requests and %v", path, requests.Load(), err)
+		}
+	}
+
+	d = New(Config{MaxSize: int64(len(segmentData))})
+	if data, err := d.Download(context.Background(), "main/0.ts", origin.URL+"/0.ts"); err != nil || !bytes.Equal(data, segmentData) {
+		t.Errorf("expected a segment of the maximum size, got %v", err)
+	}
+}
+
+func TestDownloadResume(t *testing.T) {
+	var ranges []string
+	var mutex sync.Mutex
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		ranges = append(ranges, r.Header.Get("Range"))
+		first := len(ranges) == 1
+		mutex.Unlock()
+
+		w.Header().Set("ETag", `"segment"`)
+		if first {
+			// The connection drops halfway through the segment.
+			w.Header().Set("Content-Length", strconv.Itoa(len(segmentData)))
+			w.Write(segmentData[:40000])
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}
+
+		http.ServeContent(w, r, "0.ts", time.Time{}, bytes.NewReader(segmentData))
+	}))
+	defer origin.Close()
+
+	d := New(Config{Backoff: time.Millisecond})
+	data, err := d.Download(context.Background(), "main/0.ts", origin.URL+"/0.ts")
+	if err != nil || !bytes.Equal(data, segmentData) {
+		t.Fatalf("expected the resumed segment, got %d bytes and %v", len(data), err)
+	}
+
+	if len(ranges) != 2 || ranges[0] != "" || ranges[1] != "bytes=40000-" {
+		t.Errorf("expected the second request to resume the segment, got %q", ranges)
+	}
+}
+
+func TestDownloadPerHost(t *testing.T) {
+	var current, peak atomic.Int32
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := current.Add(1)
+		defer current.Add(-1)
+
+		for p := peak.Load(); n > p && !peak.CompareAndSwap(p, n); p = peak.Load() {
+		}
+
+		time.Sleep(10 * time.Millisecond)
+		w.Write([]byte("segment"))
+	}))
+	defer origin.Close()
+
+	s := scheduler.New(scheduler.Config{Workers: 8})
+	defer s.Close(context.Background())
+
+	d := New(Config{PerHost: 2})
+	for i := range 8 {
+		s.Submit(d.Job("main/"+strconv.Itoa(i)+".ts", origin.URL+"/"+strconv.Itoa(i)+".ts", scheduler.PriorityPrefetch))
+	}
+
+	for i := range 8 {
+		if err := s.Await(context.Background(), "main/"+strconv.Itoa(i)+".ts"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if peak.Load() != 2 {
+		t.Errorf("expected at most 2 concurrent downloads from the host, got %d", peak.Load())
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := newLimiter(1000)
+	l.now = func() time.Time { return now }
+
+	if delay := l.reserve(1000); delay != 0 {
+		t.Errorf("expected a second of bandwidth to be available at once, got %s", delay)
+	}
+
+	if delay := l.reserve(500); delay != 500*time.Millisecond {
+		t.Errorf("expected to wait half a second, got %s", delay)
+	}
+
+	now = now.Add(time.Second)
+	l.refund(100)
+	if delay := l.reserve(600); delay != 0 {
+		t.Errorf("expected the refilled and refunded bytes to be available, got %s", delay)
+	}
+
+	now = now.Add(time.Hour)
+	if delay := l.reserve(1500); delay != 500*time.Millisecond {
+		t.Errorf("expected the bucket to hold a second of bandwidth at most, got %s", delay)
+	}
+}
+
+func TestDownloadBandwidth(t *testing.T) {
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(segmentData)
+	}))
+	defer origin.Close()
+
+	// The first second of bandwidth is available at once, the rest of the segment taking about half a second.
+	d := New(Config{BytesPerSecond: 64 * 1024})
+	start := time.Now()
+	if _, err := d.Download(context.Background(), "main/0.ts", origin.URL+"/0.ts"); err != nil {
+		t.Fatal(err)
+	}
+
+	if elapsed := time.Since(start); elapsed < 300*time.Millisecond {
+		t.Errorf("expected the bandwidth to slow the download down, took %s", elapsed)
+	}
+}
@@ -0,0 +1,71 @@
+package encrypt
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"errors"
+)
+
+// KeySize is the size in bytes of the AES-128 content keys.
+const KeySize = 16
+
+var (
+	// ErrInvalidKey indicates that a content key is not KeySize bytes long.
+	ErrInvalidKey = errors.New("invalid content key")
+
+	// ErrInvalidPadding indicates that a decrypted segment does not end with a valid PKCS7 padding, usually because the key or the IV is wrong.
+	ErrInvalidPadding = errors.New("invalid padding")
+)
+
+// Encrypt encrypts the segment with AES-128 in CBC mode and PKCS7 padding, as expected by the AES-128 method of HLS.
+func Encrypt(key []byte, iv []byte, data []byte) ([]byte, error) {
+	block, err := newCipher(key, iv)
+	if err != nil {
+		return nil, err
+	}
+
+	padding := aes.BlockSize - len(data)%aes.BlockSize
+	encrypted := append(bytes.Clone(data), bytes.Repeat([]byte{byte(padding)}, padding)...)
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(encrypted, encrypted)
+
+	return encrypted, nil
+}
+
+// Decrypt decrypts a segment encrypted with AES-128 in CBC mode and PKCS7 padding.
+func Decrypt(key []byte, iv []byte, data []byte) ([]byte, error) {
+	block, err := newCipher(key, iv)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) == 0 || len(data)%aes.BlockSize != 0 {
+		return nil, ErrInvalidPadding
+	}
+
+	decrypted := bytes.Clone(data)
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(decrypted, decrypted)
+
+	padding := int(decrypted[len(decrypted)-1])
+	if padding == 0 || padding > aes.BlockSize || !bytes.Equal(decrypted[len(decrypted)-padding:], bytes.Repeat([]byte{byte(padding)}, padding)) {
+		return nil, ErrInvalidPadding
+	}
+
+	return decrypted[:len(decrypted)-padding], nil
+}
+
+// newCipher returns the AES cipher of the key, checking the key and IV sizes.
+func newCipher(key []byte, iv []byte) (cipher.Block, error) {
+	if len(key) != KeySize || len(iv) != aes.BlockSize {
+		return nil, ErrInvalidKey
+	}
+
+	return aes.NewCipher(key)
+}
+
+// SegmentIV returns the IV of a segment derived from its name, so the IV signaled in the playlist and the IV used when serving the segment match without tracking media sequence numbers.
+func SegmentIV(name string) []byte {
+	sum := sha256.Sum256([]byte(name))
+	return sum[:aes.BlockSize]
+}
@@ -0,0 +1,48 @@
+package encrypt
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestEncryptDecrypt(t *testing.T) {
+	key, iv := bytes.Repeat([]byte{1}, KeySize), SegmentIV("segment0.ts")
+
+	for _, size := range []int{0, 15, 16, 1000} {
+		data := bytes.Repeat([]byte{0x47}, size)
+
+		encrypted, err := Encrypt(key, iv, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(encrypted)%16 != 0 || len(encrypted) <= size {
+			t.Errorf("expected a padded ciphertext for %d bytes, got %d bytes", size, len(encrypted))
+		}
+
+		decrypted, err := Decrypt(key, iv, encrypted)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(decrypted, data) {
+			t.Errorf("expected the %d bytes back, got %d bytes", size, len(decrypted))
+		}
+	}
+
+	encrypted, _ := Encrypt(key, iv, []byte("segment"))
+	if _, err := Decrypt(bytes.Repeat([]byte{2}, KeySize), iv, encrypted); !errors.Is(err, ErrInvalidPadding) {
+		t.Errorf("expected ErrInvalidPadding with the wrong key, got %v", err)
+	}
+
+	if _, err := Encrypt(key[:8], iv, nil); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("expected ErrInvalidKey, got %v", err)
+	}
+}
+
+func TestSegmentIV(t *testing.T) {
+	if !bytes.Equal(SegmentIV("segment0.ts"), SegmentIV("segment0.ts")) || bytes.Equal(SegmentIV("segment0.ts"), SegmentIV("segment1.ts")) {
+		t.Error("expected a stable IV per segment name")
+	}
+}
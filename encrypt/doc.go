@@ -0,0 +1,2 @@
+// Package encrypt encrypts the segments served by VRMix with AES-128, signaling its own key endpoint in the playlists so channels stay protected when segment URLs leak.
+package encrypt
@@ -0,0 +1,294 @@
+package encrypt
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"vrmix/hls"
+	"vrmix/logging"
+	"vrmix/server"
+)
+
+// KeyParam is the query parameter added to the segment URLs of the encrypted playlists, naming the key the segment is encrypted with.
+const KeyParam = "kid"
+
+var (
+	// ErrByteRanges indicates that the playlist addresses sub-ranges of its resources, which no longer match once each resource is encrypted as a whole.
+	ErrByteRanges = errors.New("cannot encrypt a playlist with byte ranges")
+
+	// ErrEncrypted indicates that the playlist already signals keys for its segments, like SAMPLE-AES passed through, which would be advertised with the wrong method and key.
+	ErrEncrypted = errors.New("cannot encrypt a playlist of encrypted segments")
+)
+
+// bufferedResponse is a response written by the wrapped handler, kept in memory so it can be encrypted.
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (b *bufferedResponse) Header() http.Header { return b.header }
+
+func (b *bufferedResponse) WriteHeader(status int) {
+	if b.status == 0 {
+		b.status = status
+	}
+}
+
+func (b *bufferedResponse) Write(data []byte) (int, error) {
+	b.WriteHeader(http.StatusOK)
+	return b.body.Write(data)
+}
+
+// Encrypter encrypts the segments of the channels with AES-128, signaling the keys in their media playlists.
+type Encrypter struct {
+	Keys Keys // Keys of the channels
+
+	// Channel returns the channel of the request, an empty channel leaving the response clear, defaults to the stream ID of the routes of server.StreamHandler.
+	Channel func(r *http.Request) string
+
+	// KeyURL returns the URI of a key signaled in the playlists, defaults to "/keys/{channel}/{id}" served by a KeyHandler.
+	KeyURL func(channel string, id string) string
+
+	// Logger receives the responses that could not be encrypted, defaults to slog.Default.
+	Logger *slog.Logger
+}
+
+// keyURL returns the URI of the key of the channel.
+func (e *Encrypter) keyURL(channel string, id string) string {
+	if e.KeyURL != nil {
+		return e.KeyURL(channel, id)
+	}
+
+	return "/keys/" + url.PathEscape(channel) + "/" + url.PathEscape(id)
+}
+
+// channel returns the channel of the request.
+func (e *Encrypter) channel(r *http.Request) string {
+	if e.Channel != nil {
+		return e.Channel(r)
+	}
+
+	rest, found := strings.CutPrefix(r.URL.Path, "/streams/")
+	if !found {
+		return ""
+	}
+
+	id, _, _ := strings.Cut(rest, "/")
+	return id
+}
+
+// Playlist encrypts every segment of the manifest with the current key of the channel, or the key of its media sequence when the keys rotate, adding the key ID to the segment URLs and signaling the key and the IV of each segment.
+//
+// The initialization sections stay clear, their EXT-X-MAP tags preceding the EXT-X-KEY tags, playlists with byte ranges are rejected with ErrByteRanges, and playlists with encrypted segments with ErrEncrypted.
+func (e *Encrypter) Playlist(ctx context.Context, channel string, manifest *hls.Manifest) error {
+	for _, group := range manifest.SegmentGroups {
+		if group.Map.ByteRange.Length > 0 {
+			return ErrByteRanges
+		}
+
+		for _, segment := range group.Segments {
+			if segment.ByteRange.Length > 0 {
+				return ErrByteRanges
+			}
+
+			for _, key := range segment.Keys {
+				if key.Method != hls.MethodNone {
+					return ErrEncrypted
+				}
+			}
+		}
+	}
+
+	rotating, ok := e.Keys.(RotatingKeys)
+
+	var key ContentKey
+	var err error
+	if !ok {
+		if key, err = e.Keys.Current(ctx, channel); err != nil {
+			return err
+		}
+	}
+
+	sequence := int64(manifest.MediaSequence)
+	for i := range manifest.SegmentGroups {
+		segments := manifest.SegmentGroups[i].Segments
+		for j := range segments {
+			if ok {
+				if key, err = rotating.ForSequence(ctx, channel, sequence); err != nil {
+					return err
+				}
+			}
+			sequence++
+
+			segment, err := url.Parse(segments[j].Path)
+			if err != nil {
+				return err
+			}
+
+			query := segment.Query()
+			query.Set(KeyParam, key.ID)
+			segment.RawQuery = query.Encode()
+
+			segments[j].Keys = []hls.Key{{Method: hls.MethodAES128, URI: e.keyURL(channel, key.ID), IV: hls.FormatIV(SegmentIV(path.Base(segment.Path)))}}
+			segments[j].Path = segment.String()
+		}
+	}
+
+	if manifest.Version < 2 {
+		manifest.Version = 2
+	}
+
+	return nil
+}
+
+// Segment encrypts the segment with the key of the channel named by the request, or its current key when the request names none, so leaked URLs never deliver clear media.
+//
+// The initialization sections of fragmented MP4 are returned as is, as the playlists signal them clear and they hold no media.
+func (e *Encrypter) Segment(ctx context.Context, channel string, r *http.Request, data []byte) ([]byte, error) {
+	if isInitSection(data) {
+		return data, nil
+	}
+
+	var key ContentKey
+	var err error
+	if id := r.URL.Query().Get(KeyParam); id != "" {
+		key, err = e.Keys.Lookup(ctx, channel, id)
+	} else {
+		key, err = e.Keys.Current(ctx, channel)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return Encrypt(key.Key, SegmentIV(path.Base(r.URL.Path)), data)
+}
+
+// Middleware returns a middleware encrypting the media playlists and the segments served by the handler for the requests of a channel, answering 404 Not Found for unknown keys and 502 Bad Gateway for playlists which cannot be parsed.
+func (e *Encrypter) Middleware() server.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			channel := e.channel(r)
+			if channel == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// The wrapped handler serves the whole clear response, the ranges and validators applying to the encrypted one.
+			inner := r.Clone(r.Context())
+			inner.Method = http.MethodGet
+			for _, header := range []string{"Range", "If-Range", "If-None-Match", "If-Modified-Since", "Accept-Encoding"} {
+				inner.Header.Del(header)
+			}
+
+			response := &bufferedResponse{header: make(http.Header)}
+			next.ServeHTTP(response, inner)
+
+			if response.status != http.StatusOK {
+				copyResponse(w, response)
+				return
+			}
+
+			if path.Ext(r.URL.Path) == ".m3u8" {
+				e.servePlaylist(w, r, channel, response)
+				return
+			}
+
+			encrypted, err := e.Segment(r.Context(), channel, r, response.body.Bytes())
+			if err != nil {
+				e.fail(w, r, channel, err)
+				return
+			}
+
+			modTime, _ := http.ParseTime(response.header.Get("Last-Modified"))
+			server.ServeSegment(w, r, r.URL.Path, modTime, bytes.NewReader(encrypted), int64(len(encrypted)))
+		})
+	}
+}
+
+// servePlaylist encrypts a media playlist, serving master playlists as is, as they reference no segment.
+func (e *Encrypter) servePlaylist(w http.ResponseWriter, r *http.Request, channel string, response *bufferedResponse) {
+	body := response.body.String()
+	if strings.Contains(body, hls.StreamInfField) || strings.Contains(body, hls.IFrameStreamInfField) {
+		copyResponse(w, response)
+		return
+	}
+
+	// A playlist which cannot be parsed is not passed through, as its segments would be signaled clear.
+	manifest, err := hls.ParseHlsManifest(strings.TrimRight(body, "\n"))
+	if err != nil {
+		logging.Or(e.Logger).Error("failed to parse playlist to encrypt", slog.String(logging.ChannelKey, channel), slog.String("path", r.URL.Path), logging.Err(err))
+		http.Error(w, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+		return
+	}
+
+	if err := e.Playlist(r.Context(), channel, &manifest); err != nil {
+		e.fail(w, r, channel, err)
+		return
+	}
+
+	server.ServePlaylist(w, r, []byte(manifest.String()))
+}
+
+// fail logs the error, answering 404 Not Found for unknown keys and 500 Internal Server Error otherwise.
+func (e *Encrypter) fail(w http.ResponseWriter, r *http.Request, channel string, err error) {
+	if errors.Is(err, ErrKeyNotFound) {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+
+	logging.Or(e.Logger).Error("encryption failed", slog.String(logging.ChannelKey, channel), slog.String("path", r.URL.Path), logging.Err(err))
+	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+}
+
+// isInitSection returns true if the data is a fragmented MP4 initialization section, with a moov box and no media.
+func isInitSection(data []byte) bool {
+	moov := false
+	for len(data) >= 8 {
+		size := uint64(binary.BigEndian.Uint32(data))
+		header := uint64(8)
+		if size == 1 && len(data) >= 16 {
+			size, header = binary.BigEndian.Uint64(data[8:]), 16
+		} else if size == 0 {
+			size = uint64(len(data))
+		}
+
+		switch string(data[4:8]) {
+		case "moov":
+			moov = true
+		case "moof", "mdat":
+			return false
+		}
+
+		if size < header || size > uint64(len(data)) {
+			break
+		}
+
+		data = data[size:]
+	}
+
+	return moov
+}
+
+// copyResponse writes the buffered response as is.
+func copyResponse(w http.ResponseWriter, response *bufferedResponse) {
+	for key, values := range response.header {
+		w.Header()[key] = values
+	}
+
+	status := response.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	w.WriteHeader(status)
+	w.Write(response.body.Bytes())
+}
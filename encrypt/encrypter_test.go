@@ -0,0 +1,169 @@
+package encrypt
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"vrmix/hls"
+	"vrmix/server"
+)
+
+// clearPlaylist is the media playlist served by the wrapped handler
+const clearPlaylist = "#EXTM3U\n#EXT-X-VERSION:1\n#EXT-X-TARGETDURATION:4\n#EXT-X-MEDIA-SEQUENCE:0\n#EXTINF:4,\nsegment0.ts\n#EXTINF:4,\nsegment1.ts\n"
+
+// newEncryptedHandler returns the encrypting middleware wrapping a handler serving the clear playlist and segments of the channel main
+func newEncryptedHandler(keys Keys) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/main/index.m3u8", func(w http.ResponseWriter, r *http.Request) {
+		server.ServePlaylist(w, r, []byte(clearPlaylist))
+	})
+	mux.HandleFunc("/{channel}/{segment}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("clear " + r.PathValue("segment")))
+	})
+
+	encrypter := &Encrypter{Keys: keys, Channel: func(r *http.Request) string {
+		channel, _, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+		if channel == "flat" {
+			return ""
+		}
+
+		return channel
+	}}
+
+	return encrypter.Middleware()(mux)
+}
+
+func TestEncrypterMiddleware(t *testing.T) {
+	keys := &MemoryKeys{}
+	handler := newEncryptedHandler(keys)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/main/index.m3u8", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+
+	manifest, err := hls.ParseHlsManifest(strings.TrimRight(rec.Body.String(), "\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, _ := keys.Current(context.Background(), "main")
+	segment := manifest.SegmentGroups[0].Segments[1]
+	if len(segment.Keys) != 1 || segment.Keys[0].Method != hls.MethodAES128 || segment.Keys[0].URI != "/keys/main/"+key.ID || manifest.Version < 2 {
+		t.Fatalf("expected the segments to be signaled encrypted, got %s", rec.Body.String())
+	}
+
+	segmentURL, _ := url.Parse(segment.Path)
+	if segmentURL.Query().Get(KeyParam) != key.ID {
+		t.Errorf("expected the key ID in the segment URL, got %s", segment.Path)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/main/"+segment.Path, nil))
+
+	iv, _ := hls.ParseIV(segment.Keys[0].IV)
+	decrypted, err := Decrypt(key.Key, iv, rec.Body.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(decrypted) != "clear segment1.ts" {
+		t.Errorf("expected the segment to decrypt with the signaled key and IV, got %q", decrypted)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/main/"+segment.Path, nil)
+	req.Header.Set("Range", "bytes=0-7")
+	ranged := httptest.NewRecorder()
+	handler.ServeHTTP(ranged, req)
+	if ranged.Code != http.StatusPartialContent || !bytes.Equal(ranged.Body.Bytes(), rec.Body.Bytes()[:8]) {
+		t.Errorf("expected the range of the encrypted segment, got %d", ranged.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/main/segment1.ts?kid=unknown", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown key, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/main/segment1.ts", nil))
+	if strings.Contains(rec.Body.String(), "clear") {
+		t.Error("expected a segment requested without key ID to be encrypted")
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/flat/segment1.ts", nil))
+	if rec.Body.String() != "clear segment1.ts" {
+		t.Errorf("expected the requests outside channels to stay clear, got %q", rec.Body.String())
+	}
+}
+
+// box returns an MP4 box of the type holding the payload
+func box(kind string, payload string) string {
+	size := len(payload) + 8
+	return string([]byte{byte(size >> 24), byte(size >> 16), byte(size >> 8), byte(size)}) + kind + payload
+}
+
+func TestEncrypterStreams(t *testing.T) {
+	playlists := map[string]string{
+		"master.m3u8": "#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=1000\nlow/index.m3u8\n",
+		"fmp4.m3u8":   "#EXTM3U\n#EXT-X-VERSION:7\n#EXT-X-TARGETDURATION:4\n#EXT-X-MAP:URI=\"init.mp4\"\n#EXTINF:4,\n0.m4s\n",
+		"ranges.m3u8": "#EXTM3U\n#EXT-X-VERSION:4\n#EXT-X-TARGETDURATION:4\n#EXTINF:4,\n#EXT-X-BYTERANGE:100@0\nall.ts\n",
+		"bad.m3u8":    "#EXTM3U\n#EXT-X-TARGETDURATION:x\n",
+		"sample.m3u8": "#EXTM3U\n#EXT-X-VERSION:5\n#EXT-X-TARGETDURATION:4\n#EXT-X-KEY:METHOD=SAMPLE-AES,URI=\"skd://key\"\n#EXTINF:4,\nsample.ts\n",
+	}
+
+	init := box("ftyp", "iso6") + box("moov", "tracks")
+	mux := http.NewServeMux()
+	mux.HandleFunc("/streams/main/{name}", func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("name")
+		if playlist, ok := playlists[name]; ok {
+			w.Write([]byte(playlist))
+		} else if name == "init.mp4" {
+			w.Write([]byte(init))
+		} else {
+			w.Write([]byte(box("moof", "fragment") + box("mdat", "media")))
+		}
+	})
+
+	handler := (&Encrypter{Keys: &MemoryKeys{}}).Middleware()(mux)
+	get := func(name string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/streams/main/"+name, nil))
+		return rec
+	}
+
+	if rec := get("master.m3u8"); rec.Body.String() != playlists["master.m3u8"] {
+		t.Errorf("expected the master playlist as is, got %s", rec.Body.String())
+	}
+
+	if rec := get("fmp4.m3u8"); !strings.Contains(rec.Body.String(), "#EXT-X-MAP:URI=\"init.mp4\"\n#EXT-X-KEY:METHOD=AES-128") {
+		t.Errorf("expected the initialization section to be signaled clear, got %s", rec.Body.String())
+	}
+
+	if rec := get("init.mp4"); rec.Body.String() != init {
+		t.Errorf("expected the initialization section to be served clear, got %q", rec.Body.String())
+	}
+
+	if rec := get("0.m4s"); strings.Contains(rec.Body.String(), "media") {
+		t.Errorf("expected the media segment of the stream channel to be encrypted, got %q", rec.Body.String())
+	}
+
+	if rec := get("ranges.m3u8"); rec.Code != http.StatusInternalServerError || strings.Contains(rec.Body.String(), "all.ts") {
+		t.Errorf("expected the playlist with byte ranges to be rejected, got %d and %s", rec.Code, rec.Body.String())
+	}
+
+	if rec := get("sample.m3u8"); rec.Code != http.StatusInternalServerError || strings.Contains(rec.Body.String(), "sample.ts") {
+		t.Errorf("expected the playlist of encrypted segments to be rejected, got %d and %s", rec.Code, rec.Body.String())
+	}
+
+	if rec := get("bad.m3u8"); rec.Code != http.StatusBadGateway {
+		t.Errorf("expected the invalid playlist to be rejected, got %d and %s", rec.Code, rec.Body.String())
+	}
+}
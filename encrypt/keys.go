@@ -0,0 +1,130 @@
+package encrypt
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+// ErrKeyNotFound indicates that the channel has no key with the ID.
+var ErrKeyNotFound = errors.New("content key not found")
+
+// ContentKey represents a key encrypting the segments of a channel.
+type ContentKey struct {
+	ID  string // ID of the key, referenced by the playlists and the segment URLs
+	Key []byte // AES-128 key, KeySize bytes long
+}
+
+// NewContentKey returns a random content key with a random ID.
+func NewContentKey() ContentKey {
+	id := make([]byte, 8)
+	rand.Read(id)
+
+	key := make([]byte, KeySize)
+	rand.Read(key)
+
+	return ContentKey{ID: hex.EncodeToString(id), Key: key}
+}
+
+// Keys provides the content keys of the channels.
+type Keys interface {
+	// Current returns the key encrypting the new segments of the channel.
+	Current(ctx context.Context, channel string) (ContentKey, error)
+
+	// Lookup returns the key of the channel with the ID, or ErrKeyNotFound.
+	Lookup(ctx context.Context, channel string, id string) (ContentKey, error)
+}
+
+// RotatingKeys is implemented by the Keys rotating the keys of the channels, so each segment keeps the key it was first signaled with and the playlists signal new keys at the rotation boundaries.
+type RotatingKeys interface {
+	Keys
+
+	// ForSequence returns the key of the segment of the channel with the media sequence.
+	ForSequence(ctx context.Context, channel string, sequence int64) (ContentKey, error)
+}
+
+// MemoryKeys is a Keys generating a random key for each channel on first use, lost when the process exits.
+type MemoryKeys struct {
+	mutex sync.Mutex
+	keys  map[string]ContentKey
+}
+
+// Current returns the key of the channel, generating it on first use.
+func (k *MemoryKeys) Current(ctx context.Context, channel string) (ContentKey, error) {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	key, ok := k.keys[channel]
+	if !ok {
+		if k.keys == nil {
+			k.keys = make(map[string]ContentKey)
+		}
+
+		key = NewContentKey()
+		k.keys[channel] = key
+	}
+
+	return key, nil
+}
+
+// Lookup returns the key of the channel if it has the ID.
+func (k *MemoryKeys) Lookup(ctx context.Context, channel string, id string) (ContentKey, error) {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	key, ok := k.keys[channel]
+	if !ok || key.ID != id {
+		return ContentKey{}, ErrKeyNotFound
+	}
+
+	return key, nil
+}
+
+// KeyHandler delivers the content keys to the players as KeySize raw bytes, with routes relative to the mount point:
+//
+//	GET /{channel}/{id}
+type KeyHandler struct {
+	Keys Keys // Keys delivered
+
+	// Authorize checks the credentials of a request once routed, so the path values like the channel are available, nil rejects every request.
+	Authorize func(r *http.Request) bool
+
+	once sync.Once
+	mux  *http.ServeMux
+}
+
+// ServeHTTP delivers the requested key.
+func (h *KeyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.once.Do(func() {
+		h.mux = http.NewServeMux()
+		h.mux.HandleFunc("GET /{channel}/{id}", h.key)
+	})
+
+	h.mux.ServeHTTP(w, r)
+}
+
+// key checks the credentials, writing the requested key.
+func (h *KeyHandler) key(w http.ResponseWriter, r *http.Request) {
+	if h.Authorize == nil || !h.Authorize(r) {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	key, err := h.Keys.Lookup(r.Context(), r.PathValue("channel"), r.PathValue("id"))
+	if errors.Is(err, ErrKeyNotFound) {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", strconv.Itoa(len(key.Key)))
+	w.Header().Set("Cache-Control", "private, no-store")
+	w.Write(key.Key)
+}
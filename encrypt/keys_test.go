@@ -0,0 +1,63 @@
+package encrypt
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMemoryKeys(t *testing.T) {
+	keys := &MemoryKeys{}
+	ctx := context.Background()
+
+	key, err := keys.Current(ctx, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(key.Key) != KeySize || key.ID == "" {
+		t.Errorf("expected a random key with an ID, got %+v", key)
+	}
+
+	if again, _ := keys.Current(ctx, "main"); again.ID != key.ID {
+		t.Errorf("expected the same key for the channel, got %s and %s", key.ID, again.ID)
+	}
+
+	if _, err := keys.Lookup(ctx, "other", key.ID); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("expected the key to be bound to its channel, got %v", err)
+	}
+}
+
+func TestKeyHandler(t *testing.T) {
+	keys := &MemoryKeys{}
+	key, _ := keys.Current(context.Background(), "main")
+
+	handler := http.StripPrefix("/keys", &KeyHandler{Keys: keys, Authorize: func(r *http.Request) bool { return r.Header.Get("Authorization") == "Bearer token" }})
+
+	cases := []struct {
+		path   string
+		auth   string
+		status int
+	}{
+		{"/keys/main/" + key.ID, "Bearer token", http.StatusOK},
+		{"/keys/main/" + key.ID, "", http.StatusUnauthorized},
+		{"/keys/main/unknown", "Bearer token", http.StatusNotFound},
+	}
+
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodGet, c.path, nil)
+		req.Header.Set("Authorization", c.auth)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != c.status {
+			t.Errorf("expected %d for %s, got %d", c.status, c.path, rec.Code)
+		}
+
+		if c.status == http.StatusOK && (rec.Body.String() != string(key.Key) || rec.Header().Get("Cache-Control") != "private, no-store") {
+			t.Errorf("expected the raw key without caching, got %x", rec.Body.Bytes())
+		}
+	}
+}
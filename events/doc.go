@@ -0,0 +1,2 @@
+// Package events implements the bus carrying the lifecycle events of the VRMix subsystems to the webhooks, the live clients and the metrics.
+package events
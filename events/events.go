@@ -0,0 +1,296 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"vrmix/logging"
+)
+
+// Type represents the kind of a lifecycle event.
+type Type string
+
+const (
+	// ChannelCreated is published when a channel is created.
+	ChannelCreated Type = "channel.created"
+
+	// ChannelDeleted is published when a channel is deleted.
+	ChannelDeleted Type = "channel.deleted"
+
+	// ChannelErrored is published when a channel fails and stops advancing.
+	ChannelErrored Type = "channel.errored"
+
+	// ItemStarted is published when a channel starts playing a queue item.
+	ItemStarted Type = "item.started"
+
+	// ItemEnded is published when a channel finishes playing a queue item.
+	ItemEnded Type = "item.ended"
+
+	// SessionStarted is published when a player starts watching a channel.
+	SessionStarted Type = "session.started"
+
+	// SessionEnded is published when a session expires.
+	SessionEnded Type = "session.ended"
+
+	// SessionThreshold is published when the session count of a channel crosses a configured threshold.
+	SessionThreshold Type = "sessions.threshold"
+
+	// JobQueued is published when a download or conversion job is queued.
+	JobQueued Type = "job.queued"
+
+	// JobProgress is published when a job progresses, with the "kind" and "progress" data.
+	JobProgress Type = "job.progress"
+
+	// JobFinished is published when a job finishes.
+	JobFinished Type = "job.finished"
+
+	// ConversionFailed is published when a conversion job fails, with the "error" data.
+	ConversionFailed Type = "conversion.failed"
+
+	// CacheEvicted is published when segments are evicted from the cache.
+	CacheEvicted Type = "cache.evicted"
+
+	// StreamStarted is published when an ingested stream starts being published.
+	StreamStarted Type = "stream.started"
+
+	// StreamStopped is published when an ingested stream stops being published.
+	StreamStopped Type = "stream.stopped"
+
+	// AlertRaised is published when a condition affecting the playback of a channel is raised or changes severity, with the "condition", "severity" and "message" data.
+	AlertRaised Type = "alert.raised"
+
+	// AlertResolved is published when a condition affecting the playback of a channel is back to normal.
+	AlertResolved Type = "alert.resolved"
+)
+
+// ErrSlowConsumer indicates that a subscription was closed because it could not keep up with the events.
+var ErrSlowConsumer = errors.New("subscriber too slow")
+
+// Event represents something that happened in a subsystem.
+type Event struct {
+	Type    Type           `json:"type"`              // Type of the event
+	Time    time.Time      `json:"time"`              // Time the event happened, set when published if zero
+	Channel string         `json:"channel,omitempty"` // Channel related to the event, if any
+	Session string         `json:"session,omitempty"` // Session related to the event, if any
+	Job     string         `json:"job,omitempty"`     // Job related to the event, if any
+	Data    map[string]any `json:"data,omitempty"`    // Details of the event
+}
+
+// Policy represents how a subscription handles events published while its buffer is full.
+type Policy int
+
+const (
+	// DropNewest drops the events published while the buffer is full.
+	DropNewest Policy = iota
+
+	// DropOldest drops the oldest buffered event to make room for the new one, for subscribers only caring about the latest state.
+	DropOldest
+
+	// Disconnect closes the subscription with ErrSlowConsumer, for subscribers that cannot miss events.
+	Disconnect
+)
+
+// Options represents the filter and buffering of a subscription.
+type Options struct {
+	Types   []Type // Types received, empty for every type
+	Channel string // Channel received, empty for every channel
+	Buffer  int    // Events buffered before the policy applies, defaults to 64
+	Policy  Policy // Handling of the events published while the buffer is full
+}
+
+// Subscription receives the events published on a bus matching its options.
+type Subscription struct {
+	bus     *Bus
+	options Options
+	events  chan Event
+	mutex   sync.Mutex
+	closed  bool
+	err     error
+	dropped atomic.Uint64
+}
+
+// Bus delivers the published events to every matching subscription without ever blocking the publisher, so a slow consumer cannot stall the subsystem publishing.
+type Bus struct {
+	Logger *slog.Logger // Logger receiving the dropped events and disconnected subscribers, defaults to slog.Default
+
+	mutex         sync.RWMutex
+	subscriptions map[*Subscription]struct{}
+}
+
+// Subscribe registers a subscription with the options.
+func (b *Bus) Subscribe(options Options) *Subscription {
+	if options.Buffer <= 0 {
+		options.Buffer = 64
+	}
+
+	s := &Subscription{bus: b, options: options, events: make(chan Event, options.Buffer)}
+
+	b.mutex.Lock()
+	if b.subscriptions == nil {
+		b.subscriptions = make(map[*Subscription]struct{})
+	}
+	b.subscriptions[s] = struct{}{}
+	b.mutex.Unlock()
+
+	return s
+}
+
+// Handle subscribes with the options and calls the handler for each event until the context is done or the subscription is closed.
+func (b *Bus) Handle(ctx context.Context, options Options, handler func(event Event)) *Subscription {
+	s := b.Subscribe(options)
+	stop := context.AfterFunc(ctx, s.Close)
+
+	go func() {
+		defer stop()
+
+		for event := range s.events {
+			handler(event)
+		}
+	}()
+
+	return s
+}
+
+// Publish delivers the event to the matching subscriptions, setting its time if zero.
+func (b *Bus) Publish(event Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	b.mutex.RLock()
+	var slow []*Subscription
+	for s := range b.subscriptions {
+		if s.matches(event) && !s.deliver(event) {
+			slow = append(slow, s)
+		}
+	}
+	b.mutex.RUnlock()
+
+	for _, s := range slow {
+		logging.Or(b.Logger).Warn("disconnecting slow subscriber", slog.String("event", string(event.Type)), slog.String(logging.ChannelKey, event.Channel))
+		s.close(ErrSlowConsumer)
+	}
+}
+
+// Subscribers returns the number of subscriptions.
+func (b *Bus) Subscribers() int {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	return len(b.subscriptions)
+}
+
+// matches returns true if the subscription receives the event.
+func (s *Subscription) matches(event Event) bool {
+	if len(s.options.Types) > 0 && !slices.Contains(s.options.Types, event.Type) {
+		return false
+	}
+
+	return s.options.Channel == "" || s.options.Channel == event.Channel
+}
+
+// deliver buffers the event following the policy, returning false when the subscription must be disconnected.
+func (s *Subscription) deliver(event Event) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.closed {
+		return true
+	}
+
+	select {
+	case s.events <- event:
+		return true
+	default:
+	}
+
+	switch s.options.Policy {
+	case DropOldest:
+		select {
+		case <-s.events:
+		default:
+		}
+		s.events <- event
+	case Disconnect:
+		return false
+	}
+
+	s.dropped.Add(1)
+	return true
+}
+
+// Events returns the channel receiving the events, closed when the subscription is closed.
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Dropped returns the number of events dropped because the buffer was full.
+func (s *Subscription) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Err returns ErrSlowConsumer if the subscription was disconnected for being too slow, nil otherwise.
+func (s *Subscription) Err() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.err
+}
+
+// Close removes the subscription from the bus, closing its channel after the buffered events.
+func (s *Subscription) Close() {
+	s.close(nil)
+}
+
+// close removes the subscription with the error.
+func (s *Subscription) close(err error) {
+	s.bus.mutex.Lock()
+	delete(s.bus.subscriptions, s)
+	s.bus.mutex.Unlock()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if !s.closed {
+		s.closed = true
+		s.err = err
+		close(s.events)
+	}
+}
+
+// Counter counts the events published on a bus by type, as the source of the event metrics.
+type Counter struct {
+	mutex  sync.Mutex
+	counts map[Type]uint64
+}
+
+// Count subscribes the counter to every event of the bus until the context is done.
+func (c *Counter) Count(ctx context.Context, bus *Bus) *Subscription {
+	return bus.Handle(ctx, Options{Buffer: 1024}, func(event Event) {
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+
+		if c.counts == nil {
+			c.counts = make(map[Type]uint64)
+		}
+		c.counts[event.Type]++
+	})
+}
+
+// Counts returns a copy of the counts by type.
+func (c *Counter) Counts() map[Type]uint64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	counts := make(map[Type]uint64, len(c.counts))
+	for eventType, count := range c.counts {
+		counts[eventType] = count
+	}
+
+	return counts
+}
@@ -0,0 +1,96 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBusFilter(t *testing.T) {
+	bus := &Bus{}
+	items := bus.Subscribe(Options{Types: []Type{ItemStarted}, Channel: "main"})
+	all := bus.Subscribe(Options{})
+
+	bus.Publish(Event{Type: ItemStarted, Channel: "main"})
+	bus.Publish(Event{Type: ItemStarted, Channel: "other"})
+	bus.Publish(Event{Type: ChannelErrored, Channel: "main"})
+
+	items.Close()
+	all.Close()
+
+	var received []Event
+	for event := range items.Events() {
+		received = append(received, event)
+	}
+
+	if len(received) != 1 || received[0].Channel != "main" || received[0].Time.IsZero() {
+		t.Errorf("expected the item of the main channel with its time, got %v", received)
+	}
+
+	if len(all.Events()) != 3 {
+		t.Errorf("expected every event, got %d", len(all.Events()))
+	}
+
+	if bus.Subscribers() != 0 {
+		t.Errorf("expected no subscriber after closing, got %d", bus.Subscribers())
+	}
+}
+
+func TestBusSlowConsumer(t *testing.T) {
+	bus := &Bus{}
+	newest := bus.Subscribe(Options{Buffer: 1})
+	oldest := bus.Subscribe(Options{Buffer: 1, Policy: DropOldest})
+	disconnect := bus.Subscribe(Options{Buffer: 1, Policy: Disconnect})
+
+	bus.Publish(Event{Type: JobQueued, Job: "1"})
+	bus.Publish(Event{Type: JobQueued, Job: "2"})
+
+	if event := <-newest.Events(); event.Job != "1" || newest.Dropped() != 1 {
+		t.Errorf("expected the first event and 1 dropped, got %s and %d", event.Job, newest.Dropped())
+	}
+
+	if event := <-oldest.Events(); event.Job != "2" || oldest.Dropped() != 1 {
+		t.Errorf("expected the last event and 1 dropped, got %s and %d", event.Job, oldest.Dropped())
+	}
+
+	<-disconnect.Events()
+	if _, ok := <-disconnect.Events(); ok || !errors.Is(disconnect.Err(), ErrSlowConsumer) {
+		t.Errorf("expected the slow subscriber to be disconnected, got %v", disconnect.Err())
+	}
+
+	if bus.Subscribers() != 2 {
+		t.Errorf("expected 2 subscribers left, got %d", bus.Subscribers())
+	}
+}
+
+func TestCounter(t *testing.T) {
+	bus := &Bus{}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var counter Counter
+	counter.Count(ctx, bus)
+
+	bus.Publish(Event{Type: SessionStarted})
+	bus.Publish(Event{Type: SessionStarted})
+	bus.Publish(Event{Type: SessionEnded})
+
+	deadline := time.Now().Add(time.Second)
+	for counter.Counts()[SessionEnded] != 1 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	if counts := counter.Counts(); counts[SessionStarted] != 2 || counts[SessionEnded] != 1 {
+		t.Errorf("expected 2 started and 1 ended, got %v", counts)
+	}
+
+	cancel()
+	for bus.Subscribers() != 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	if bus.Subscribers() != 0 {
+		t.Errorf("expected the counter to unsubscribe, got %d subscribers", bus.Subscribers())
+	}
+}
@@ -0,0 +1,2 @@
+// Package guard monitors the CPU, memory and cache disk used by the process, shedding load while they exceed their limits.
+package guard
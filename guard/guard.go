@@ -0,0 +1,275 @@
+package guard
+
+import (
+	"context"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"runtime"
+	"runtime/metrics"
+	"strconv"
+	"sync"
+	"time"
+
+	"vrmix/logging"
+)
+
+// Usage represents the resources used by the process.
+type Usage struct {
+	CPU    float64 `json:"cpu"`    // Fraction of the usable cores busy since the previous sample
+	Memory uint64  `json:"memory"` // Bytes of memory obtained from the system and not released
+	Disk   int64   `json:"disk"`   // Bytes stored in the cache directory
+}
+
+// Limits represents the usage above which load is shed, each zero field being unlimited.
+type Limits struct {
+	CPU    float64 // Fraction of the usable cores, like 0.9
+	Memory uint64  // Bytes of memory
+	Disk   int64   // Bytes stored in the cache directory
+}
+
+// exceeded returns true if the usage exceeds the limits scaled by the ratio.
+func (l Limits) exceeded(usage Usage, ratio float64) bool {
+	return (l.CPU > 0 && usage.CPU > l.CPU*ratio) ||
+		(l.Memory > 0 && float64(usage.Memory) > float64(l.Memory)*ratio) ||
+		(l.Disk > 0 && float64(usage.Disk) > float64(l.Disk)*ratio)
+}
+
+// Guard samples the usage of the process, shedding load while it exceeds the limits and recovering once it drops below the recovery ratio of every limit.
+type Guard struct {
+	Limits       Limits        // Usage above which load is shed
+	RecoverRatio float64       // Fraction of the limits the usage must drop below to recover, defaults to 0.8
+	Interval     time.Duration // Interval between the samples, defaults to five seconds
+	CacheDir     string        // Directory of the disk cache, empty to not sample the disk
+	IdleTimeout  time.Duration // Time without requests after which a session is new again, defaults to 30 seconds
+
+	// Sample returns the usage of the process, defaults to the Go runtime metrics and the size of the cache directory.
+	Sample func() (Usage, error)
+
+	// PausePrefetch is called with true when the prefetching must pause and false when it may resume, nil to ignore.
+	PausePrefetch func(paused bool)
+
+	// SetConcurrency is called with the reduced concurrency of the conversions when shedding and the normal one when recovering, nil to ignore.
+	SetConcurrency func(n int)
+
+	// Concurrency and ReducedConcurrency are the conversion concurrency passed to SetConcurrency.
+	Concurrency, ReducedConcurrency int
+
+	// Session returns the session ID of the request, nil to reject every request while shedding.
+	Session func(r *http.Request) string
+
+	// Logger receives the changes of pressure, defaults to slog.Default.
+	Logger *slog.Logger
+
+	mutex      sync.Mutex
+	usage      Usage
+	shedding   bool
+	sessions   map[string]time.Time
+	lastCPU    float64
+	lastSample time.Time
+	now        func() time.Time
+}
+
+// time returns the current time.
+func (g *Guard) time() time.Time {
+	if g.now == nil {
+		return time.Now()
+	}
+
+	return g.now()
+}
+
+// sample returns the usage of the process from the Go runtime metrics and the cache directory.
+func (g *Guard) sample() (Usage, error) {
+	samples := []metrics.Sample{
+		{Name: "/cpu/classes/total:cpu-seconds"},
+		{Name: "/cpu/classes/idle:cpu-seconds"},
+		{Name: "/memory/classes/total:bytes"},
+		{Name: "/memory/classes/heap/released:bytes"},
+	}
+	metrics.Read(samples)
+
+	now := g.time()
+	busy := samples[0].Value.Float64() - samples[1].Value.Float64()
+	usage := Usage{Memory: samples[2].Value.Uint64() - samples[3].Value.Uint64()}
+
+	g.mutex.Lock()
+	if elapsed := now.Sub(g.lastSample).Seconds(); !g.lastSample.IsZero() && elapsed > 0 {
+		usage.CPU = (busy - g.lastCPU) / (elapsed * float64(runtime.GOMAXPROCS(0)))
+	}
+	g.lastCPU, g.lastSample = busy, now
+	g.mutex.Unlock()
+
+	if g.CacheDir != "" {
+		err := filepath.WalkDir(g.CacheDir, func(_ string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+
+			if info, err := d.Info(); err == nil {
+				usage.Disk += info.Size()
+			}
+
+			return nil
+		})
+
+		if err != nil {
+			return usage, err
+		}
+	}
+
+	return usage, nil
+}
+
+// Check samples the usage, starting or stopping to shed load when it crosses the limits.
+func (g *Guard) Check() error {
+	sample := g.Sample
+	if sample == nil {
+		sample = g.sample
+	}
+
+	usage, err := sample()
+	if err != nil {
+		return err
+	}
+
+	ratio := g.RecoverRatio
+	if ratio <= 0 || ratio > 1 {
+		ratio = 0.8
+	}
+
+	g.mutex.Lock()
+	g.usage = usage
+	shedding := g.shedding
+	if !shedding && g.Limits.exceeded(usage, 1) {
+		shedding = true
+	} else if shedding && !g.Limits.exceeded(usage, ratio) {
+		shedding = false
+	}
+
+	changed := shedding != g.shedding
+	g.shedding = shedding
+	g.mutex.Unlock()
+
+	if changed {
+		g.apply(shedding, usage)
+	}
+
+	return nil
+}
+
+// apply sheds or restores the load through the hooks.
+func (g *Guard) apply(shedding bool, usage Usage) {
+	attrs := []any{slog.Float64("cpu", usage.CPU), slog.Uint64("memory", usage.Memory), slog.Int64("disk", usage.Disk)}
+	if shedding {
+		logging.Or(g.Logger).Warn("shedding load", attrs...)
+	} else {
+		logging.Or(g.Logger).Info("load recovered", attrs...)
+	}
+
+	if g.PausePrefetch != nil {
+		g.PausePrefetch(shedding)
+	}
+
+	if g.SetConcurrency != nil {
+		if shedding {
+			g.SetConcurrency(max(g.ReducedConcurrency, 1))
+		} else {
+			g.SetConcurrency(max(g.Concurrency, 1))
+		}
+	}
+}
+
+// Run samples the usage at each interval until the context is done.
+func (g *Guard) Run(ctx context.Context) error {
+	interval := g.Interval
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := g.Check(); err != nil {
+			logging.Or(g.Logger).Warn("resource sampling failed", logging.Err(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Shedding returns true while load is being shed.
+func (g *Guard) Shedding() bool {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	return g.shedding
+}
+
+// Usage returns the last usage sampled.
+func (g *Guard) Usage() Usage {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	return g.usage
+}
+
+// admit records the session, returning false when it is new while shedding.
+func (g *Guard) admit(session string) bool {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	idleTimeout := g.IdleTimeout
+	if idleTimeout <= 0 {
+		idleTimeout = 30 * time.Second
+	}
+
+	if g.sessions == nil {
+		g.sessions = make(map[string]time.Time)
+	}
+
+	now := g.time()
+	for id, lastSeen := range g.sessions {
+		if now.Sub(lastSeen) > idleTimeout {
+			delete(g.sessions, id)
+		}
+	}
+
+	if _, known := g.sessions[session]; !known && (g.shedding || session == "") {
+		return !g.shedding
+	}
+
+	g.sessions[session] = now
+	return true
+}
+
+// Middleware returns a middleware rejecting the requests of new sessions with 503 Service Unavailable while shedding, the sessions already watching being served.
+func (g *Guard) Middleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			session := ""
+			if g.Session != nil {
+				session = g.Session(r)
+			}
+
+			if !g.admit(session) {
+				interval := g.Interval
+				if interval <= 0 {
+					interval = 5 * time.Second
+				}
+
+				w.Header().Set("Retry-After", strconv.Itoa(int(max(interval.Seconds(), 1))))
+				http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
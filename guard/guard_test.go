@@ -0,0 +1,91 @@
+package guard
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestGuard(t *testing.T) {
+	usage := Usage{Memory: 100}
+	var paused []bool
+	var concurrency []int
+
+	g := &Guard{
+		Limits:             Limits{Memory: 1000},
+		Sample:             func() (Usage, error) { return usage, nil },
+		PausePrefetch:      func(p bool) { paused = append(paused, p) },
+		SetConcurrency:     func(n int) { concurrency = append(concurrency, n) },
+		Concurrency:        4,
+		ReducedConcurrency: 1,
+		Session:            func(r *http.Request) string { return r.URL.Query().Get("session") },
+	}
+
+	handler := g.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	request := func(session string) int {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/main/index.m3u8?session="+session, nil))
+		return w.Code
+	}
+
+	g.Check()
+	if code := request("old"); code != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, code)
+	}
+
+	usage.Memory = 1200
+	g.Check()
+	if !g.Shedding() {
+		t.Fatal("expected the guard to shed load")
+	}
+
+	if code := request("new"); code != http.StatusServiceUnavailable {
+		t.Errorf("expected new sessions to be rejected, got %d", code)
+	}
+
+	if code := request("old"); code != http.StatusOK {
+		t.Errorf("expected known sessions to be served, got %d", code)
+	}
+
+	usage.Memory = 900
+	g.Check()
+	if !g.Shedding() {
+		t.Error("expected the guard to shed load until the usage drops below the recovery ratio")
+	}
+
+	usage.Memory = 700
+	g.Check()
+	if g.Shedding() {
+		t.Error("expected the guard to recover")
+	}
+
+	if len(paused) != 2 || !paused[0] || paused[1] {
+		t.Errorf("expected the prefetch to pause then resume, got %v", paused)
+	}
+
+	if len(concurrency) != 2 || concurrency[0] != 1 || concurrency[1] != 4 {
+		t.Errorf("expected the concurrency to be reduced then restored, got %v", concurrency)
+	}
+}
+
+func TestSample(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "segment.ts"), make([]byte, 1234), 0o644)
+
+	now := time.Now()
+	g := &Guard{CacheDir: dir, now: func() time.Time { return now }}
+	g.sample()
+
+	now = now.Add(time.Second)
+	usage, err := g.sample()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if usage.Disk != 1234 || usage.Memory == 0 || usage.CPU < 0 {
+		t.Errorf("expected the cache size and process memory, got %+v", usage)
+	}
+}
@@ -0,0 +1,81 @@
+package hls
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrInvalidAttributes indicates that an attribute list is malformed.
+var ErrInvalidAttributes = errors.New("invalid attribute list")
+
+// Attribute represents an attribute of a tag, like BANDWIDTH=800000 or CODECS="avc1.640028".
+type Attribute struct {
+	Key    string // Name of the attribute
+	Value  string // Value of the attribute, without the quotes
+	Quoted bool   // Indicates if the value is a quoted string
+}
+
+// String returns the attribute as written in a tag.
+func (a Attribute) String() string {
+	if a.Quoted {
+		return a.Key + `="` + a.Value + `"`
+	}
+
+	return a.Key + "=" + a.Value
+}
+
+// parseAttributes parses a comma separated attribute list, keeping the order of the attributes and rejecting unquoted values with quotes or whitespace.
+func parseAttributes(list string) ([]Attribute, error) {
+	var attributes []Attribute
+
+	for list != "" {
+		key, rest, found := strings.Cut(list, "=")
+		if !found || key == "" || strings.ContainsAny(key, `",`) {
+			return nil, ErrInvalidAttributes
+		}
+
+		attribute := Attribute{Key: key}
+		if value, ok := strings.CutPrefix(rest, `"`); ok {
+			end := strings.IndexByte(value, '"')
+			if end < 0 {
+				return nil, ErrInvalidAttributes
+			}
+
+			attribute.Value, attribute.Quoted, rest = value[:end], true, value[end+1:]
+			if rest != "" && rest[0] != ',' {
+				return nil, ErrInvalidAttributes
+			}
+		} else {
+			attribute.Value, _, _ = strings.Cut(rest, ",")
+			if strings.ContainsAny(attribute.Value, "\" \t\r") {
+				return nil, ErrInvalidAttributes
+			}
+
+			rest = rest[len(attribute.Value):]
+		}
+
+		attributes = append(attributes, attribute)
+		list = strings.TrimPrefix(rest, ",")
+	}
+
+	return attributes, nil
+}
+
+// enumerated returns the value of an attribute written as an enumerated string, rejecting quoted values as they would be written without their quotes.
+func enumerated(attribute Attribute) (string, error) {
+	if attribute.Quoted {
+		return "", ErrInvalidAttributes
+	}
+
+	return attribute.Value, nil
+}
+
+// formatAttributes returns the attributes as a comma separated attribute list.
+func formatAttributes(attributes []Attribute) string {
+	parts := make([]string, len(attributes))
+	for i, attribute := range attributes {
+		parts[i] = attribute.String()
+	}
+
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,71 @@
+package hls
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ByteRangeField is the field that indicates that the next segment is a sub-range of its resource.
+const ByteRangeField = "#EXT-X-BYTERANGE"
+
+// ErrInvalidByteRange indicates that a byte range is malformed or misses its offset without following a sub-range of the same resource.
+var ErrInvalidByteRange = errors.New("invalid byte range")
+
+// ByteRange represents the sub-range of the resource of a segment, like a segment of a single file VOD.
+type ByteRange struct {
+	Length uint64 // Length of the sub-range in bytes, zero when the segment is the whole resource
+	Offset uint64 // Offset of the sub-range from the start of the resource
+}
+
+// End returns the offset of the byte following the sub-range.
+func (r ByteRange) End() uint64 {
+	return r.Offset + r.Length
+}
+
+// String returns the byte range as "<n>@<o>".
+func (r ByteRange) String() string {
+	return strconv.FormatUint(r.Length, 10) + "@" + strconv.FormatUint(r.Offset, 10)
+}
+
+// parseByteRange parses the value of an EXT-X-BYTERANGE tag, "<n>[@<o>]", returning whether the offset is present.
+func parseByteRange(value string) (ByteRange, bool, error) {
+	length, offset, hasOffset := strings.Cut(value, "@")
+
+	var r ByteRange
+	var err error
+	if r.Length, err = strconv.ParseUint(length, 10, 64); err != nil || r.Length == 0 {
+		return r, false, ErrInvalidByteRange
+	}
+
+	if hasOffset {
+		// The sub-range must end within the offsets representable.
+		if r.Offset, err = strconv.ParseUint(offset, 10, 64); err != nil || r.Offset > math.MaxUint64-r.Length {
+			return r, false, ErrInvalidByteRange
+		}
+	}
+
+	return r, hasOffset, nil
+}
+
+// writeByteRange writes the EXT-X-BYTERANGE tag of the segment following the previous one, leaving the offset out when the sub-range follows the one of the previous segment.
+func writeByteRange(w manifestWriter, previous *Segment, segment *Segment) {
+	if segment.ByteRange.Length == 0 {
+		return
+	}
+
+	w.WriteString(ByteRangeField + ":")
+	w.WriteString(strconv.FormatUint(segment.ByteRange.Length, 10))
+	if !segment.follows(previous) {
+		w.WriteByte('@')
+		w.WriteString(strconv.FormatUint(segment.ByteRange.Offset, 10))
+	}
+
+	w.WriteByte('\n')
+}
+
+// follows returns true if the sub-range of the segment starts where the one of the previous segment, nil for none, ends in the same resource.
+func (s *Segment) follows(previous *Segment) bool {
+	return previous != nil && previous.ByteRange.Length > 0 && previous.Path == s.Path && previous.ByteRange.End() == s.ByteRange.Offset
+}
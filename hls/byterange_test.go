@@ -0,0 +1,87 @@
+package hls
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// singleFileManifest is a VOD of a single file, as written by ffmpeg with -hls_flags single_file
+const singleFileManifest = `#EXTM3U
+#EXT-X-VERSION:4
+#EXT-X-TARGETDURATION:4
+#EXT-X-MEDIA-SEQUENCE:0
+#EXTINF:4,
+#EXT-X-BYTERANGE:1000@0
+movie.ts
+#EXTINF:4,
+#EXT-X-BYTERANGE:800
+movie.ts
+#EXT-X-BYTERANGE:500@1800
+#EXTINF:2,
+movie.ts
+#EXTINF:4,
+intro.ts
+#EXT-X-ENDLIST`
+
+func TestParseByteRange(t *testing.T) {
+	manifest, err := ParseHlsManifest(singleFileManifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []ByteRange{{1000, 0}, {800, 1000}, {500, 1800}, {}}
+	for i, segment := range manifest.SegmentGroups[0].Segments {
+		if segment.ByteRange != expected[i] {
+			t.Errorf("expected the byte range of segment %d to be %+v, got %+v", i, expected[i], segment.ByteRange)
+		}
+	}
+
+	output := manifest.String()
+	for _, tag := range []string{ByteRangeField + ":1000@0\n", ByteRangeField + ":800\n", ByteRangeField + ":500\n"} {
+		if !strings.Contains(output, tag) {
+			t.Errorf("expected %q in the manifest, got %s", tag, output)
+		}
+	}
+
+	if strings.Count(output, ByteRangeField) != 3 {
+		t.Errorf("expected only the sub-ranges to be written, got %s", output)
+	}
+
+	manifest.RemoveFromStart(1)
+	reparsed, err := ParseHlsManifest(manifest.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reparsed.SegmentGroups[0].Segments[0].ByteRange != (ByteRange{800, 1000}) {
+		t.Errorf("expected the offset of the first segment to be written once the previous one is removed, got %+v", reparsed.SegmentGroups[0].Segments[0].ByteRange)
+	}
+}
+
+func TestParseByteRangeErrors(t *testing.T) {
+	for _, data := range []string{
+		"#EXTM3U\n#EXTINF:4,\n#EXT-X-BYTERANGE:100\na.ts",
+		"#EXTM3U\n#EXTINF:4,\na.ts\n#EXTINF:4,\n#EXT-X-BYTERANGE:100\na.ts",
+		"#EXTM3U\n#EXTINF:4,\n#EXT-X-BYTERANGE:100@0\na.ts\n#EXTINF:4,\n#EXT-X-BYTERANGE:100\nb.ts",
+		"#EXTM3U\n#EXTINF:4,\n#EXT-X-BYTERANGE:0@0\na.ts",
+		"#EXTM3U\n#EXTINF:4,\n#EXT-X-BYTERANGE:100@\na.ts",
+		"#EXTM3U\n#EXTINF:4,\n#EXT-X-BYTERANGE:100@18446744073709551610\na.ts",
+	} {
+		if _, err := ParseHlsManifest(data); !errors.Is(err, ErrInvalidByteRange) {
+			t.Errorf("expected an invalid byte range parsing %q, got %v", data, err)
+		}
+	}
+}
+
+func TestValidateByteRange(t *testing.T) {
+	manifest, err := ParseHlsManifest(strings.Replace(singleFileManifest, "#EXT-X-VERSION:4", "#EXT-X-VERSION:3", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	issues := manifest.Validate()
+	if len(issues) != 1 || !strings.Contains(issues[0].Message, "version 4 required by the EXT-X-BYTERANGE tag") {
+		t.Errorf("expected the version required by the byte ranges, got %+v", issues)
+	}
+}
@@ -1,64 +1,68 @@
 package hls
 
-import "slices"
-
 // RemoveFromStart removes n segments from the start of the manifest returning the count of segments group and segments removed and updating the media sequence and discontinuity sequence.
+//
+// The groups are resliced in place in O(removed), so copies of the manifest made by assignment share them and must be made with Clone instead.
 func (m *Manifest) RemoveFromStart(n int) (int, int) {
-	var newGroups []SegmentGroup
 	segmentsRemoved := 0
 	segmentsGroupRemoved := 0
 
-	for _, group := range m.SegmentGroups {
-		if n <= 0 {
-			newGroups = append(newGroups, group)
-			continue
-		}
-
+	for segmentsGroupRemoved < len(m.SegmentGroups) && n > 0 {
+		group := &m.SegmentGroups[segmentsGroupRemoved]
 		removed := group.RemoveFromStart(n)
 		segmentsRemoved += removed
 		n -= removed
+		m.MediaSequence += uint32(removed)
 
-		if len(group.Segments) == 0 {
-			m.DiscontinuitySequence += 1
-			m.MediaSequence += uint32(removed)
-			segmentsGroupRemoved += 1
-			continue
+		if len(group.Segments) > 0 {
+			break
 		}
 
-		newGroups = append(newGroups, group)
-		m.MediaSequence += uint32(removed)
+		segmentsGroupRemoved += 1
+	}
+
+	m.DiscontinuitySequence += m.discontinuitiesBefore(segmentsGroupRemoved)
+
+	// The removed groups are cleared so their segments can be collected while the backing array is still in use.
+	clear(m.SegmentGroups[:segmentsGroupRemoved])
+	m.SegmentGroups = m.SegmentGroups[segmentsGroupRemoved:]
+	if len(m.SegmentGroups) == 0 {
+		m.SegmentGroups = nil
 	}
 
-	m.SegmentGroups = newGroups
 	return segmentsGroupRemoved, segmentsRemoved
 }
 
 // RemoveFromEnd removes n segments from the end of the manifest returning the count of segments group and segments removed.
+//
+// The groups are resliced in place in O(removed), so copies of the manifest made by assignment share them and must be made with Clone instead.
 func (m *Manifest) RemoveFromEnd(n int) (int, int) {
-	var newGroups []SegmentGroup
 	segmentsRemoved := 0
 	segmentsGroupRemoved := 0
 
-	for _, group := range slices.Backward(m.SegmentGroups) {
-		if n <= 0 {
-			newGroups = append([]SegmentGroup{group}, newGroups...)
-			continue
-		}
-
+	for segmentsGroupRemoved < len(m.SegmentGroups) && n > 0 {
+		group := &m.SegmentGroups[len(m.SegmentGroups)-1-segmentsGroupRemoved]
 		removed := group.RemoveFromEnd(n)
 		segmentsRemoved += removed
 		n -= removed
 
-		if len(group.Segments) == 0 {
-			segmentsGroupRemoved += 1
+		if len(group.Segments) > 0 {
+			break
+		}
+
+		if !group.Continuous {
 			m.DiscontinuitySequence += 1
-			continue
 		}
+		segmentsGroupRemoved += 1
+	}
 
-		newGroups = append([]SegmentGroup{group}, newGroups...)
+	kept := len(m.SegmentGroups) - segmentsGroupRemoved
+	clear(m.SegmentGroups[kept:])
+	m.SegmentGroups = m.SegmentGroups[:kept]
+	if kept == 0 {
+		m.SegmentGroups = nil
 	}
 
-	m.SegmentGroups = newGroups
 	m.MediaSequence += uint32(segmentsRemoved)
 	return segmentsGroupRemoved, segmentsRemoved
 }
@@ -71,10 +75,12 @@ func (g *SegmentGroup) RemoveFromStart(n int) int {
 	oldLen := len(g.Segments)
 
 	if n >= len(g.Segments) {
+		clear(g.Segments)
 		g.Segments = []Segment{}
 		return oldLen
 	}
 
+	clear(g.Segments[:n])
 	g.Segments = g.Segments[n:]
 	return oldLen - len(g.Segments)
 }
@@ -88,10 +94,24 @@ func (g *SegmentGroup) RemoveFromEnd(n int) int {
 	oldLen := len(g.Segments)
 
 	if n >= len(g.Segments) {
+		clear(g.Segments)
 		g.Segments = []Segment{}
 		return oldLen
 	}
 
+	clear(g.Segments[len(g.Segments)-n:])
 	g.Segments = g.Segments[:len(g.Segments)-n]
 	return oldLen - len(g.Segments)
 }
+
+// discontinuitiesBefore returns the discontinuities preceding the group at the index, one per group after the first one not continuing the previous group, the index past the last group counting as one.
+func (m *Manifest) discontinuitiesBefore(index int) uint32 {
+	var discontinuities uint32
+	for i := 1; i <= index; i++ {
+		if i >= len(m.SegmentGroups) || !m.SegmentGroups[i].Continuous {
+			discontinuities++
+		}
+	}
+
+	return discontinuities
+}
@@ -1,6 +1,9 @@
 package hls
 
-import "testing"
+import (
+	"strconv"
+	"testing"
+)
 
 func TestRemoveEnd(t *testing.T) {
 	manifest0 := readManifest(t, "../testdata/stream0.m3u8")
@@ -63,3 +66,78 @@ func TestRemoveStart(t *testing.T) {
 		t.Errorf("expected discontinuity sequence to be 1, got %d", manifest0.DiscontinuitySequence)
 	}
 }
+
+func TestRemoveAcrossGroups(t *testing.T) {
+	manifest, err := ParseHlsManifest(generateManifest(5000))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if groups, segments := manifest.RemoveFromStart(2500); groups != 2 || segments != 2500 {
+		t.Errorf("expected 2 groups and 2500 segments to be removed, got %d and %d", groups, segments)
+	}
+
+	if manifest.MediaSequence != 2500 || manifest.DiscontinuitySequence != 2 || len(manifest.SegmentGroups) != 3 {
+		t.Errorf("expected the sequences to follow the removed segments, got %d, %d and %d groups", manifest.MediaSequence, manifest.DiscontinuitySequence, len(manifest.SegmentGroups))
+	}
+
+	if path := manifest.SegmentGroups[0].Segments[0].Path; path != "segments/2500.ts" {
+		t.Errorf("expected the first segment to be segments/2500.ts, got %s", path)
+	}
+
+	if groups, segments := manifest.RemoveFromEnd(1200); groups != 1 || segments != 1200 {
+		t.Errorf("expected 1 group and 1200 segments to be removed, got %d and %d", groups, segments)
+	}
+
+	last := manifest.SegmentGroups[len(manifest.SegmentGroups)-1].Segments
+	if path := last[len(last)-1].Path; path != "segments/3799.ts" {
+		t.Errorf("expected the last segment to be segments/3799.ts, got %s", path)
+	}
+
+	if groups, segments := manifest.RemoveFromEnd(10000); groups != 2 || segments != 1300 || manifest.SegmentGroups != nil {
+		t.Errorf("expected every segment to be removed, got %d, %d and %v", groups, segments, manifest.SegmentGroups)
+	}
+}
+
+func TestRemoveShared(t *testing.T) {
+	manifest, err := ParseHlsManifest(generateManifest(2000))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clone := manifest.Clone()
+	manifest.RemoveFromStart(1500)
+
+	if clone.SegmentCount() != 2000 || clone.SegmentGroups[0].Segments[0].Path != "segments/0.ts" {
+		t.Errorf("expected the clone to keep its segments, got %d", clone.SegmentCount())
+	}
+}
+
+func BenchmarkRemove(b *testing.B) {
+	for _, size := range benchmarkSizes {
+		manifest := benchmarkManifest(b, size)
+		n := max(size/4, 1)
+
+		b.Run("start/"+strconv.Itoa(size), func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				b.StopTimer()
+				m := manifest.Clone()
+				b.StartTimer()
+
+				m.RemoveFromStart(n)
+			}
+		})
+
+		b.Run("end/"+strconv.Itoa(size), func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				b.StopTimer()
+				m := manifest.Clone()
+				b.StartTimer()
+
+				m.RemoveFromEnd(n)
+			}
+		})
+	}
+}
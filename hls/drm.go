@@ -0,0 +1,62 @@
+package hls
+
+import (
+	"encoding/base64"
+	"slices"
+	"strings"
+)
+
+const (
+	// SessionKeyField is the master manifest field that declares a key of the media playlists, so players acquire its license before loading them.
+	SessionKeyField = "#EXT-X-SESSION-KEY"
+
+	// FairPlayKeyFormat is the key format of FairPlay Streaming, whose URI is a "skd://" URI passed to the license server.
+	FairPlayKeyFormat = "com.apple.streamingkeydelivery"
+
+	// WidevineKeyFormat is the key format of Widevine, whose URI is a data URI holding a pssh box.
+	WidevineKeyFormat = "urn:uuid:edef8ba9-79d6-4ace-a3c8-27dcd51d21ed"
+
+	// PlayReadyKeyFormat is the key format of PlayReady, whose URI is a data URI holding a PlayReady object.
+	PlayReadyKeyFormat = "com.microsoft.playready"
+
+	// psshDataPrefix is the prefix of the data URIs holding a pssh box or a PlayReady object.
+	psshDataPrefix = "data:text/plain;base64,"
+)
+
+// DRM returns true if the key is acquired from a license server of a DRM system instead of being fetched as is, so its URI must reach the player untouched.
+func (k *Key) DRM() bool {
+	return k.Method != MethodNone && k.format() != IdentityKeyFormat
+}
+
+// PSSH returns the pssh box or PlayReady object carried by the data URI of the key, false when the URI is not a data URI.
+func (k *Key) PSSH() ([]byte, bool) {
+	encoded, found := strings.CutPrefix(k.URI, psshDataPrefix)
+	if !found {
+		return nil, false
+	}
+
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	return data, err == nil
+}
+
+// PSSHKey returns the key signaling the pssh box of a CMAF stream encrypted with the method, like a Widevine pssh box referenced by WidevineKeyFormat.
+func PSSHKey(method EncryptionMethod, format string, pssh []byte) Key {
+	return Key{Method: method, URI: psshDataPrefix + base64.StdEncoding.EncodeToString(pssh), KeyFormat: format, KeyFormatVersions: "1"}
+}
+
+// SessionKeys returns the distinct DRM keys of the manifest without their IVs, to be declared as session keys by the master manifest.
+func (m *Manifest) SessionKeys() []Key {
+	var keys []Key
+	for _, group := range m.SegmentGroups {
+		for _, segment := range group.Segments {
+			for _, key := range segment.Keys {
+				key.IV = ""
+				if key.DRM() && !slices.Contains(keys, key) {
+					keys = append(keys, key)
+				}
+			}
+		}
+	}
+
+	return keys
+}
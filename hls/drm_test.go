@@ -0,0 +1,44 @@
+package hls
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestSessionKeys(t *testing.T) {
+	widevine := PSSHKey(MethodSampleAESCTR, WidevineKeyFormat, []byte("pssh box"))
+	if pssh, ok := widevine.PSSH(); !ok || !bytes.Equal(pssh, []byte("pssh box")) {
+		t.Errorf("expected the pssh box in the data URI, got %q", pssh)
+	}
+
+	manifest := Manifest{SegmentGroups: []SegmentGroup{{Segments: []Segment{
+		{Path: "0.m4s", Keys: []Key{widevine, {Method: MethodSampleAESCTR, URI: "skd://asset", IV: "0x01", KeyFormat: FairPlayKeyFormat}}},
+		{Path: "1.m4s", Keys: []Key{widevine, {Method: MethodSampleAESCTR, URI: "skd://asset", IV: "0x02", KeyFormat: FairPlayKeyFormat}}},
+		{Path: "2.ts", Keys: []Key{{Method: MethodAES128, URI: "key.bin"}}},
+	}}}}
+
+	keys := manifest.SessionKeys()
+	if len(keys) != 2 || keys[0] != widevine || keys[1].URI != "skd://asset" || keys[1].IV != "" {
+		t.Fatalf("expected the Widevine and FairPlay keys without IVs, got %+v", keys)
+	}
+
+	master := MasterManifest{SessionKeys: keys, Variants: []Variant{{URI: "video.m3u8", Bandwidth: 1000}}}
+	output := master.String()
+	if !strings.Contains(output, SessionKeyField+`:METHOD=SAMPLE-AES-CTR,URI="skd://asset",KEYFORMAT="com.apple.streamingkeydelivery"`) {
+		t.Errorf("expected the FairPlay session key, got %s", output)
+	}
+
+	parsed, err := ParseMasterManifest(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(parsed.SessionKeys) != 2 || parsed.SessionKeys[0] != widevine {
+		t.Errorf("expected the session keys to survive writing, got %+v", parsed.SessionKeys)
+	}
+
+	if _, err := ParseMasterManifest("#EXTM3U\n" + SessionKeyField + ":METHOD=NONE\n"); err == nil {
+		t.Error("expected session keys to never be NONE")
+	}
+}
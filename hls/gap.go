@@ -0,0 +1,37 @@
+package hls
+
+import "errors"
+
+// GapField is the field that indicates that the next segment is unavailable, the players skipping it instead of requesting it.
+const GapField = "#EXT-X-GAP"
+
+// ErrSegmentIndex indicates that no segment of the manifest has the index.
+var ErrSegmentIndex = errors.New("segment index out of range")
+
+// MarkGap marks the segment at the index, counting the segments of every group, as unavailable, like a segment which failed to be produced, so the players skip it instead of stalling; the segment is modified in place, so it must be cloned first when shared.
+func (m *Manifest) MarkGap(index int) error {
+	if index < 0 {
+		return ErrSegmentIndex
+	}
+
+	for i := range m.SegmentGroups {
+		segments := m.SegmentGroups[i].Segments
+		if index < len(segments) {
+			segments[index].Gap = true
+			return nil
+		}
+
+		index -= len(segments)
+	}
+
+	return ErrSegmentIndex
+}
+
+// MarkGap marks the segment of the window with the media sequence number as unavailable, returning false if the window does not have it.
+func (w *LiveWindow) MarkGap(sequence uint32) bool {
+	if sequence < w.manifest.MediaSequence {
+		return false
+	}
+
+	return w.manifest.MarkGap(int(sequence-w.manifest.MediaSequence)) == nil
+}
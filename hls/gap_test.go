@@ -0,0 +1,65 @@
+package hls
+
+import (
+	"errors"
+	"testing"
+)
+
+// gapManifest is a live playlist whose second segment failed to be produced
+const gapManifest = `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-TARGETDURATION:4
+#EXT-X-MEDIA-SEQUENCE:10
+#EXT-X-DISCONTINUITY-SEQUENCE:0
+#EXTINF:4,
+10.ts
+#EXT-X-GAP
+#EXTINF:4,
+11.ts
+#EXT-DISCONTINUITY
+#EXTINF:4,
+12.ts
+`
+
+func TestParseGap(t *testing.T) {
+	manifest, err := ParseHlsManifest(gapManifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, second := manifest.SegmentGroups[0], manifest.SegmentGroups[1]
+	if first.Segments[0].Gap || !first.Segments[1].Gap || second.Segments[0].Gap {
+		t.Errorf("expected only the second segment to be a gap, got %+v", manifest.SegmentGroups)
+	}
+
+	if output := manifest.String(); output != gapManifest {
+		t.Errorf("expected the manifest to be written as parsed, got %s", output)
+	}
+}
+
+func TestMarkGap(t *testing.T) {
+	manifest, err := ParseHlsManifest(gapManifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := manifest.MarkGap(2); err != nil || !manifest.SegmentGroups[1].Segments[0].Gap {
+		t.Errorf("expected the segment of the second group to be a gap, got %v", err)
+	}
+
+	for _, index := range []int{-1, 3} {
+		if err := manifest.MarkGap(index); !errors.Is(err, ErrSegmentIndex) {
+			t.Errorf("expected the index %d to be out of range, got %v", index, err)
+		}
+	}
+
+	window := NewLiveWindow(Manifest{Version: 3, MediaSequence: 10}, 2)
+	window.Append(Segment{Path: "10.ts", Duration: 4}, Segment{Path: "11.ts", Duration: 4}, Segment{Path: "12.ts", Duration: 4})
+	if window.MarkGap(10) || window.MarkGap(13) || !window.MarkGap(12) {
+		t.Errorf("expected only the segments of the window to be marked")
+	}
+
+	if segments := window.Manifest().SegmentGroups[0].Segments; segments[0].Gap || !segments[1].Gap {
+		t.Errorf("expected the last segment to be a gap, got %+v", segments)
+	}
+}
@@ -0,0 +1,2 @@
+// Package hlstest provides an in-process HLS origin and golden playlist assertions, so code fetching HLS streams can be tested without a CDN.
+package hlstest
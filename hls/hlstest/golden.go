@@ -0,0 +1,67 @@
+package hlstest
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// UpdateEnv is the environment variable which, when set, has AssertGolden write the golden files instead of comparing them.
+const UpdateEnv = "HLSTEST_UPDATE"
+
+// AssertPlaylist reports the first line differing between the playlists, ignoring the line endings and the trailing whitespace.
+func AssertPlaylist(t testing.TB, got string, want string) {
+	t.Helper()
+
+	gotLines, wantLines := playlistLines(got), playlistLines(want)
+	for i := range max(len(gotLines), len(wantLines)) {
+		var gotLine, wantLine string
+		if i < len(gotLines) {
+			gotLine = gotLines[i]
+		}
+
+		if i < len(wantLines) {
+			wantLine = wantLines[i]
+		}
+
+		if gotLine != wantLine {
+			t.Errorf("expected line %d of the playlist to be %q, got %q", i+1, wantLine, gotLine)
+			return
+		}
+	}
+}
+
+// playlistLines returns the lines of the playlist without their trailing whitespace nor the trailing empty lines.
+func playlistLines(playlist string) []string {
+	lines := strings.Split(strings.TrimRight(playlist, " \t\r\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t\r")
+	}
+
+	return lines
+}
+
+// AssertGolden compares the playlist with the golden file at the path, writing the file instead when UpdateEnv is set.
+func AssertGolden(t testing.TB, got string, path string) {
+	t.Helper()
+
+	if os.Getenv(UpdateEnv) != "" {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("expected the golden file %s, set %s=1 to write it: %v", path, UpdateEnv, err)
+	}
+
+	AssertPlaylist(t, got, string(want))
+}
@@ -0,0 +1,57 @@
+package hlstest
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// recorder is a testing.TB recording the errors reported instead of failing the test
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, format)
+}
+
+func (r *recorder) Helper() {}
+
+func TestAssertPlaylist(t *testing.T) {
+	r := &recorder{TB: t}
+	AssertPlaylist(r, "#EXTM3U\r\n#EXTINF:2,\r\na.ts  \r\n\r\n", "#EXTM3U\n#EXTINF:2,\na.ts\n")
+	if len(r.errors) != 0 {
+		t.Errorf("expected the line endings and trailing whitespace to be ignored, got %v", r.errors)
+	}
+
+	AssertPlaylist(r, "#EXTM3U\n#EXTINF:2,\na.ts\n", "#EXTM3U\n#EXTINF:2,\na.ts\nb.ts\n")
+	if len(r.errors) != 1 {
+		t.Errorf("expected a missing line to be reported, got %v", r.errors)
+	}
+}
+
+func TestAssertGolden(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "golden", "live.m3u8")
+	origin := NewOrigin()
+	defer origin.Close()
+
+	stream := origin.Live("live", 2, 2)
+
+	t.Setenv(UpdateEnv, "1")
+	AssertGolden(t, stream.Playlist(), path)
+	if data, err := os.ReadFile(path); err != nil || !strings.Contains(string(data), "live/1.ts") {
+		t.Fatalf("expected the golden file to be written, got %v", err)
+	}
+
+	t.Setenv(UpdateEnv, "")
+	AssertGolden(t, stream.Playlist(), path)
+
+	stream.Advance(1)
+	r := &recorder{TB: t}
+	AssertGolden(r, stream.Playlist(), path)
+	if len(r.errors) != 1 {
+		t.Errorf("expected the advanced playlist to differ from the golden file, got %v", r.errors)
+	}
+}
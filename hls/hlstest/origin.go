@@ -0,0 +1,297 @@
+package hlstest
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"vrmix/hls"
+)
+
+// PartsShown is the number of last segments of a low latency playlist listing their parts.
+const PartsShown = 3
+
+// Origin is an in-process HLS origin serving live and VOD playlists, with configurable latencies and injected failures.
+type Origin struct {
+	URL string // Base URL of the origin, like "http://127.0.0.1:1234"
+
+	server          *httptest.Server
+	mutex           sync.Mutex
+	streams         map[string]*Stream
+	masters         map[string][]*Stream
+	failures        map[string]*failure
+	requests        map[string]int
+	playlistLatency time.Duration
+	segmentLatency  time.Duration
+	changed         chan struct{} // closed and replaced when a stream changes, waking the blocked playlist reloads
+}
+
+// failure is a status answered instead of a path.
+type failure struct {
+	status    int // HTTP status answered
+	remaining int // requests still failing, negative to fail until recovered
+}
+
+// NewOrigin starts an origin, which must be closed once the test ends.
+func NewOrigin() *Origin {
+	origin := &Origin{
+		streams:  map[string]*Stream{},
+		masters:  map[string][]*Stream{},
+		failures: map[string]*failure{},
+		requests: map[string]int{},
+		changed:  make(chan struct{}),
+	}
+
+	origin.server = httptest.NewServer(origin)
+	origin.URL = origin.server.URL
+	return origin
+}
+
+// Close shuts the origin down, blocking until its requests end.
+func (o *Origin) Close() {
+	o.server.CloseClientConnections()
+	o.server.Close()
+}
+
+// Client returns a client for the origin.
+func (o *Origin) Client() *http.Client {
+	return o.server.Client()
+}
+
+// SetLatency delays the answers to the playlist and segment requests.
+func (o *Origin) SetLatency(playlist time.Duration, segment time.Duration) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	o.playlistLatency, o.segmentLatency = playlist, segment
+}
+
+// Fail answers the next count requests to the path, like "/live.m3u8", with the status, or every request until Recover when count is negative.
+func (o *Origin) Fail(path string, status int, count int) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	o.failures[path] = &failure{status: status, remaining: count}
+}
+
+// Recover stops failing the requests to the path.
+func (o *Origin) Recover(path string) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	delete(o.failures, path)
+}
+
+// Requests returns the number of requests to the path, including the failed ones.
+func (o *Origin) Requests(path string) int {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	return o.requests[path]
+}
+
+// VOD adds a VOD playlist served at /name.m3u8 with the number of segments of the duration.
+func (o *Origin) VOD(name string, segments int, duration float32) *Stream {
+	stream := o.add(name, 0, duration)
+	stream.Advance(segments)
+	stream.End()
+
+	return stream
+}
+
+// Live adds a live playlist served at /name.m3u8 keeping the window of segments of the duration, advanced by the test.
+func (o *Origin) Live(name string, window int, duration float32) *Stream {
+	stream := o.add(name, window, duration)
+	stream.Advance(window)
+
+	return stream
+}
+
+// add adds an empty stream.
+func (o *Origin) add(name string, window int, duration float32) *Stream {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	stream := &Stream{
+		origin:   o,
+		name:     name,
+		window:   window,
+		duration: duration,
+		manifest: hls.Manifest{Version: 3, TargetDuration: uint8(math.Ceil(float64(duration)))},
+	}
+
+	o.streams[name] = stream
+	return stream
+}
+
+// Master adds a master playlist served at /name.m3u8 listing the streams as variants of increasing bandwidth.
+func (o *Origin) Master(name string, streams ...*Stream) string {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	o.masters[name] = streams
+	return o.URL + "/" + name + ".m3u8"
+}
+
+// notify wakes the blocked playlist reloads, called with the mutex held.
+func (o *Origin) notify() {
+	close(o.changed)
+	o.changed = make(chan struct{})
+}
+
+// Segment returns the content served for the segment of the stream with the media sequence number.
+func Segment(name string, sequence int) []byte {
+	return []byte("segment " + name + " " + strconv.Itoa(sequence) + "\n")
+}
+
+// Part returns the content served for the part of the segment of the stream with the media sequence number.
+func Part(name string, sequence int, part int) []byte {
+	return []byte("part " + name + " " + strconv.Itoa(sequence) + "." + strconv.Itoa(part) + "\n")
+}
+
+func (o *Origin) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Path
+
+	o.mutex.Lock()
+	o.requests[path]++
+	status := 0
+	if f := o.failures[path]; f != nil && f.remaining != 0 {
+		status = f.status
+		f.remaining--
+	}
+	playlistLatency, segmentLatency := o.playlistLatency, o.segmentLatency
+	o.mutex.Unlock()
+
+	if status != 0 {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	if name, found := strings.CutSuffix(strings.TrimPrefix(path, "/"), ".m3u8"); found {
+		if !sleep(r.Context(), playlistLatency) {
+			return
+		}
+
+		o.servePlaylist(w, r, name)
+		return
+	}
+
+	if !sleep(r.Context(), segmentLatency) {
+		return
+	}
+
+	o.serveSegment(w, path)
+}
+
+// servePlaylist serves a master or media playlist, blocking the reloads of low latency playlists asking for a segment not yet available.
+func (o *Origin) servePlaylist(w http.ResponseWriter, r *http.Request, name string) {
+	o.mutex.Lock()
+	streams, master := o.masters[name]
+	stream := o.streams[name]
+	o.mutex.Unlock()
+
+	var playlist string
+	switch {
+	case master:
+		manifest := hls.MasterManifest{Version: 3}
+		for i, stream := range streams {
+			manifest.Variants = append(manifest.Variants, hls.Variant{Bandwidth: 800000 * (i + 1), URI: stream.name + ".m3u8"})
+		}
+
+		playlist = manifest.String()
+	case stream != nil:
+		if msn := r.URL.Query().Get("_HLS_msn"); msn != "" && stream.LowLatency() > 0 {
+			sequence, err := strconv.Atoi(msn)
+			if err != nil || !o.wait(r.Context(), stream, sequence) {
+				http.Error(w, "invalid _HLS_msn", http.StatusBadRequest)
+				return
+			}
+		}
+
+		playlist = stream.Playlist()
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+	w.Write([]byte(playlist))
+}
+
+// wait blocks until the segment of the stream is available, returning false when it is too far in the future to be waited for.
+func (o *Origin) wait(ctx context.Context, stream *Stream, sequence int) bool {
+	for {
+		o.mutex.Lock()
+		next, ended, changed := stream.next, stream.manifest.HasEndList, o.changed
+		o.mutex.Unlock()
+
+		if sequence < next || ended {
+			return true
+		}
+
+		// Clients may only ask for the next segments, as the specification has servers reject the others.
+		if sequence > next+1 {
+			return false
+		}
+
+		select {
+		case <-ctx.Done():
+			return true
+		case <-changed:
+		}
+	}
+}
+
+// serveSegment serves a segment or part, like /name/12.ts or /name/12.3.ts.
+func (o *Origin) serveSegment(w http.ResponseWriter, path string) {
+	name, file, _ := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+	number, found := strings.CutSuffix(file, ".ts")
+	sequenceValue, partValue, isPart := strings.Cut(number, ".")
+
+	sequence, err := strconv.Atoi(sequenceValue)
+	part, partErr := strconv.Atoi(partValue)
+	if !found || err != nil || (isPart && partErr != nil) {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+
+	o.mutex.Lock()
+	stream := o.streams[name]
+	available := stream != nil && sequence >= 0 && sequence < stream.next && (!isPart || (part >= 0 && part < stream.parts))
+	o.mutex.Unlock()
+
+	if !available {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "video/mp2t")
+	if isPart {
+		w.Write(Part(name, sequence, part))
+		return
+	}
+
+	w.Write(Segment(name, sequence))
+}
+
+// sleep waits for the duration, returning false if the context ends first.
+func sleep(ctx context.Context, duration time.Duration) bool {
+	if duration <= 0 {
+		return true
+	}
+
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -0,0 +1,97 @@
+package hlstest
+
+import (
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"vrmix/hls"
+)
+
+// fetch returns the status and body answered by the origin to the URL
+func fetch(t *testing.T, url string) (int, string) {
+	t.Helper()
+
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(body)
+}
+
+func TestOriginVOD(t *testing.T) {
+	origin := NewOrigin()
+	defer origin.Close()
+
+	stream := origin.VOD("movie", 3, 4)
+
+	_, playlist := fetch(t, stream.URL())
+	manifest, err := hls.ParseHlsManifest(playlist)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !manifest.HasEndList || manifest.SegmentCount() != 3 || manifest.TargetDuration != 4 {
+		t.Errorf("expected a VOD playlist of 3 segments, got %s", playlist)
+	}
+
+	if status, body := fetch(t, origin.URL+"/"+manifest.SegmentGroups[0].Segments[2].Path); status != http.StatusOK || body != string(Segment("movie", 2)) {
+		t.Errorf("expected the segment, got %d and %q", status, body)
+	}
+
+	if status, _ := fetch(t, origin.URL+"/movie/3.ts"); status != http.StatusNotFound {
+		t.Errorf("expected the segments out of the playlist to be missing, got %d", status)
+	}
+}
+
+func TestOriginFailures(t *testing.T) {
+	origin := NewOrigin()
+	defer origin.Close()
+
+	stream := origin.Live("live", 3, 2)
+	origin.Fail("/live.m3u8", http.StatusServiceUnavailable, 2)
+
+	for _, expected := range []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusOK} {
+		if status, _ := fetch(t, stream.URL()); status != expected {
+			t.Errorf("expected %d, got %d", expected, status)
+		}
+	}
+
+	origin.Fail("/live/0.ts", http.StatusNotFound, -1)
+	for range 3 {
+		if status, _ := fetch(t, origin.URL+"/live/0.ts"); status != http.StatusNotFound {
+			t.Errorf("expected the segment to fail until recovered, got %d", status)
+		}
+	}
+
+	origin.Recover("/live/0.ts")
+	if status, _ := fetch(t, origin.URL+"/live/0.ts"); status != http.StatusOK {
+		t.Errorf("expected the segment to recover, got %d", status)
+	}
+
+	if requests := origin.Requests("/live/0.ts"); requests != 4 {
+		t.Errorf("expected 4 requests, got %d", requests)
+	}
+}
+
+func TestOriginLatency(t *testing.T) {
+	origin := NewOrigin()
+	defer origin.Close()
+
+	stream := origin.VOD("movie", 1, 2)
+	origin.SetLatency(0, 50*time.Millisecond)
+
+	start := time.Now()
+	fetch(t, stream.URL())
+	playlist := time.Since(start)
+
+	start = time.Now()
+	fetch(t, origin.URL+"/movie/0.ts")
+	if segment := time.Since(start); segment < 50*time.Millisecond || playlist >= 50*time.Millisecond {
+		t.Errorf("expected only the segments to be delayed, got %s and %s", playlist, segment)
+	}
+}
@@ -0,0 +1,132 @@
+package hlstest
+
+import (
+	"strconv"
+	"strings"
+
+	"vrmix/hls"
+)
+
+// Stream is a media playlist served by an origin.
+type Stream struct {
+	origin   *Origin
+	name     string
+	window   int     // segments kept by a live playlist, 0 to keep every segment
+	duration float32 // duration of each segment
+	manifest hls.Manifest
+	next     int  // media sequence number of the next segment
+	split    bool // whether the next segment starts a new group
+	parts    int  // parts of each segment of a low latency playlist, 0 otherwise
+}
+
+// URL returns the URL of the playlist.
+func (s *Stream) URL() string {
+	return s.origin.URL + "/" + s.name + ".m3u8"
+}
+
+// Advance appends n segments to the playlist, sliding the window of a live playlist.
+func (s *Stream) Advance(n int) {
+	s.origin.mutex.Lock()
+	defer s.origin.mutex.Unlock()
+
+	for range n {
+		if s.split || len(s.manifest.SegmentGroups) == 0 {
+			s.manifest.SegmentGroups = append(s.manifest.SegmentGroups, hls.SegmentGroup{})
+			s.split = false
+		}
+
+		last := &s.manifest.SegmentGroups[len(s.manifest.SegmentGroups)-1]
+		last.Segments = append(last.Segments, hls.Segment{Path: s.name + "/" + strconv.Itoa(s.next) + ".ts", Duration: s.duration})
+		s.next++
+	}
+
+	if count := s.manifest.SegmentCount(); s.window > 0 && count > s.window {
+		s.manifest.RemoveFromStart(count - s.window)
+	}
+
+	s.origin.notify()
+}
+
+// Discontinuity starts a new group with the next segment appended.
+func (s *Stream) Discontinuity() {
+	s.origin.mutex.Lock()
+	defer s.origin.mutex.Unlock()
+
+	s.split = true
+}
+
+// End ends the playlist with the #EXT-X-ENDLIST tag.
+func (s *Stream) End() {
+	s.origin.mutex.Lock()
+	defer s.origin.mutex.Unlock()
+
+	s.manifest.HasEndList = true
+	s.origin.notify()
+}
+
+// SetLowLatency serves the playlist as a low latency one, splitting each segment in parts and blocking the reloads asking for the next segment, or as a regular one when parts is 0.
+func (s *Stream) SetLowLatency(parts int) {
+	s.origin.mutex.Lock()
+	defer s.origin.mutex.Unlock()
+
+	s.parts = max(parts, 0)
+}
+
+// LowLatency returns the parts of each segment of a low latency playlist, 0 otherwise.
+func (s *Stream) LowLatency() int {
+	s.origin.mutex.Lock()
+	defer s.origin.mutex.Unlock()
+
+	return s.parts
+}
+
+// Manifest returns a copy of the playlist, without the low latency tags.
+func (s *Stream) Manifest() hls.Manifest {
+	s.origin.mutex.Lock()
+	defer s.origin.mutex.Unlock()
+
+	return s.manifest.Clone()
+}
+
+// Playlist returns the playlist as served.
+func (s *Stream) Playlist() string {
+	s.origin.mutex.Lock()
+	defer s.origin.mutex.Unlock()
+
+	playlist := s.manifest.String()
+	if s.parts == 0 {
+		return playlist
+	}
+
+	return s.lowLatency(playlist)
+}
+
+// lowLatency adds the server control and the parts of the last segments to the playlist.
+func (s *Stream) lowLatency(playlist string) string {
+	part := strconv.FormatFloat(float64(s.duration)/float64(s.parts), 'f', -1, 32)
+	holdBack := strconv.FormatFloat(3*float64(s.duration)/float64(s.parts), 'f', -1, 32)
+
+	var builder strings.Builder
+	segment := 0
+	first := s.manifest.SegmentCount() - PartsShown
+	sequence := int(s.manifest.MediaSequence)
+	for line := range strings.SplitSeq(strings.TrimSuffix(playlist, "\n"), "\n") {
+		if strings.HasPrefix(line, hls.SegmentField+":") {
+			if segment >= first {
+				for i := range s.parts {
+					builder.WriteString(`#EXT-X-PART:DURATION=` + part + `,URI="` + s.name + "/" + strconv.Itoa(sequence+segment) + "." + strconv.Itoa(i) + ".ts\"\n")
+				}
+			}
+
+			segment++
+		}
+
+		builder.WriteString(line + "\n")
+		if strings.HasPrefix(line, hls.DiscontinuitySequenceField+":") {
+			builder.WriteString("#EXT-X-SERVER-CONTROL:CAN-BLOCK-RELOAD=YES,PART-HOLD-BACK=" + holdBack + "\n")
+			builder.WriteString("#EXT-X-PART-INF:PART-TARGET=" + part + "\n")
+		}
+	}
+
+	return builder.String()
+}
@@ -0,0 +1,75 @@
+package hlstest
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"vrmix/hls"
+)
+
+func TestStreamLive(t *testing.T) {
+	origin := NewOrigin()
+	defer origin.Close()
+
+	stream := origin.Live("live", 3, 2)
+	stream.Advance(2)
+	stream.Discontinuity()
+	stream.Advance(1)
+
+	manifest := stream.Manifest()
+	if manifest.MediaSequence != 3 || manifest.SegmentCount() != 3 || len(manifest.SegmentGroups) != 2 || manifest.HasEndList {
+		t.Errorf("expected a sliding window with a discontinuity, got %s", stream.Playlist())
+	}
+
+	master := origin.Master("master", stream, origin.VOD("backup", 1, 2))
+	_, playlist := fetch(t, master)
+	if variants, err := hls.ParseMasterManifest(playlist); err != nil || len(variants.Variants) != 2 || variants.Variants[1].URI != "backup.m3u8" {
+		t.Errorf("expected both streams as variants, got %s", playlist)
+	}
+}
+
+func TestStreamLowLatency(t *testing.T) {
+	origin := NewOrigin()
+	defer origin.Close()
+
+	stream := origin.Live("live", 4, 2)
+	stream.SetLowLatency(4)
+
+	playlist := stream.Playlist()
+	if !strings.Contains(playlist, "#EXT-X-SERVER-CONTROL:CAN-BLOCK-RELOAD=YES,PART-HOLD-BACK=1.5\n#EXT-X-PART-INF:PART-TARGET=0.5\n") || strings.Contains(playlist, `"live/0.0.ts"`) || !strings.Contains(playlist, `#EXT-X-PART:DURATION=0.5,URI="live/3.3.ts"`+"\n#EXTINF:2,\nlive/3.ts\n") {
+		t.Errorf("expected the parts of the last segments, got %s", playlist)
+	}
+
+	if status, body := fetch(t, origin.URL+"/live/3.3.ts"); status != http.StatusOK || body != string(Part("live", 3, 3)) {
+		t.Errorf("expected the part, got %d and %q", status, body)
+	}
+
+	if status, _ := fetch(t, stream.URL()+"?_HLS_msn=9"); status != http.StatusBadRequest {
+		t.Errorf("expected reloads far in the future to be rejected, got %d", status)
+	}
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		stream.Advance(1)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, stream.URL()+"?_HLS_msn=4", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if !strings.Contains(string(body), "live/4.ts") || time.Since(start) < 50*time.Millisecond {
+		t.Errorf("expected the reload to block until the segment is available, got %s", body)
+	}
+}
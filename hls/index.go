@@ -0,0 +1,228 @@
+package hls
+
+import (
+	"slices"
+	"sort"
+)
+
+// IndexedManifest is a manifest kept with the cumulative durations of its segments and the boundaries of its groups, updated incrementally by its mutations, so the counts, durations and lookups of large playlists never walk every segment.
+type IndexedManifest struct {
+	manifest  Manifest
+	removed   int       // segments removed from the start since indexing, the position of the first segment
+	start     float64   // time at which the first segment starts, the end of the last removed segment
+	ends      []float64 // time at which each segment ends, since the first segment indexed
+	groupEnds []int     // position after the last segment of each group, counting the removed segments
+}
+
+// NewIndexedManifest indexes the manifest, taking ownership of its groups.
+func NewIndexedManifest(m Manifest) *IndexedManifest {
+	index := &IndexedManifest{manifest: m}
+	index.manifest.SegmentGroups = nil
+	index.appendGroups(m.SegmentGroups)
+
+	return index
+}
+
+// appendGroups appends the groups to the manifest, indexing their segments.
+func (x *IndexedManifest) appendGroups(groups []SegmentGroup) {
+	x.manifest.SegmentGroups = append(x.manifest.SegmentGroups, groups...)
+	for _, group := range groups {
+		x.indexSegments(group.Segments)
+		x.groupEnds = append(x.groupEnds, x.removed+len(x.ends))
+	}
+}
+
+// indexSegments appends the end of each segment to the index.
+func (x *IndexedManifest) indexSegments(segments []Segment) {
+	end := x.start
+	if len(x.ends) > 0 {
+		end = x.ends[len(x.ends)-1]
+	}
+
+	for _, segment := range segments {
+		end += float64(segment.Duration)
+		x.ends = append(x.ends, end)
+	}
+}
+
+// Manifest returns the manifest, sharing its segments with the index, so it must be cloned to be modified.
+func (x *IndexedManifest) Manifest() Manifest {
+	return x.manifest
+}
+
+// String returns the manifest as a string.
+func (x *IndexedManifest) String() string {
+	return x.manifest.String()
+}
+
+// SegmentCount returns the number of segments in the manifest.
+func (x *IndexedManifest) SegmentCount() int {
+	return len(x.ends)
+}
+
+// Duration returns the total duration of the manifest.
+func (x *IndexedManifest) Duration() float64 {
+	if len(x.ends) == 0 {
+		return 0
+	}
+
+	return x.ends[len(x.ends)-1] - x.start
+}
+
+// Locate returns the group of the segment with the media sequence number and its index in the group, or false if the manifest does not have it.
+func (x *IndexedManifest) Locate(sequence uint32) (int, int, bool) {
+	i := int(sequence) - int(x.manifest.MediaSequence)
+	if i < 0 || i >= len(x.ends) {
+		return 0, 0, false
+	}
+
+	position := x.removed + i
+	group := sort.SearchInts(x.groupEnds, position+1)
+	return group, position - x.groupStart(group), true
+}
+
+// groupStart returns the position of the first segment of the group.
+func (x *IndexedManifest) groupStart(group int) int {
+	if group == 0 {
+		return x.removed
+	}
+
+	return x.groupEnds[group-1]
+}
+
+// Segment returns the segment with the media sequence number, or false if the manifest does not have it.
+func (x *IndexedManifest) Segment(sequence uint32) (Segment, bool) {
+	group, index, ok := x.Locate(sequence)
+	if !ok {
+		return Segment{}, false
+	}
+
+	return x.manifest.SegmentGroups[group].Segments[index], true
+}
+
+// SegmentAt returns the media sequence number of the segment playing at the time since the start of the manifest, or false if the time is out of it.
+func (x *IndexedManifest) SegmentAt(seconds float64) (uint32, bool) {
+	if seconds < 0 || seconds >= x.Duration() {
+		return 0, false
+	}
+
+	i := sort.Search(len(x.ends), func(i int) bool { return x.ends[i] > x.start+seconds })
+	return x.manifest.MediaSequence + uint32(i), true
+}
+
+// Start returns the time at which the segment with the media sequence number starts since the start of the manifest, or false if the manifest does not have it.
+func (x *IndexedManifest) Start(sequence uint32) (float64, bool) {
+	i := int(sequence) - int(x.manifest.MediaSequence)
+	if i < 0 || i >= len(x.ends) {
+		return 0, false
+	}
+
+	if i == 0 {
+		return 0, true
+	}
+
+	return x.ends[i-1] - x.start, true
+}
+
+// Window returns the manifest with only its last segments, as RemoveFromStart would leave it, sharing the segments with the index.
+func (x *IndexedManifest) Window(size int) Manifest {
+	window := x.manifest
+	if size >= len(x.ends) {
+		window.SegmentGroups = slices.Clip(window.SegmentGroups)
+		return window
+	}
+
+	first := len(x.ends) - max(size, 0)
+	group, index, _ := x.Locate(x.manifest.MediaSequence + uint32(first))
+	if size <= 0 {
+		group, index = len(x.manifest.SegmentGroups), 0
+	}
+
+	window.SegmentGroups = slices.Clone(x.manifest.SegmentGroups[group:])
+	if len(window.SegmentGroups) > 0 {
+		segments := window.SegmentGroups[0].Segments
+		window.SegmentGroups[0].Segments = segments[index:len(segments):len(segments)]
+	} else {
+		window.SegmentGroups = nil
+	}
+
+	window.MediaSequence += uint32(first)
+	window.DiscontinuitySequence += x.manifest.discontinuitiesBefore(group)
+	return window
+}
+
+// Append appends the segments to the last group of the manifest, starting one if there is none, and raises the target duration to fit them.
+func (x *IndexedManifest) Append(segments ...Segment) {
+	if len(x.manifest.SegmentGroups) == 0 {
+		x.AppendGroup(segments...)
+		return
+	}
+
+	last := &x.manifest.SegmentGroups[len(x.manifest.SegmentGroups)-1]
+	last.Segments = append(last.Segments, segments...)
+	x.indexSegments(segments)
+	x.groupEnds[len(x.groupEnds)-1] = x.removed + len(x.ends)
+	x.fitTargetDuration(segments)
+}
+
+// AppendGroup appends the segments as a new group of the manifest, after a discontinuity, and raises the target duration to fit them.
+func (x *IndexedManifest) AppendGroup(segments ...Segment) {
+	x.appendGroups([]SegmentGroup{{Segments: segments}})
+	x.fitTargetDuration(segments)
+}
+
+// fitTargetDuration raises the target duration of the manifest to the target duration of the longest segment.
+func (x *IndexedManifest) fitTargetDuration(segments []Segment) {
+	group := SegmentGroup{Segments: segments}
+	x.manifest.TargetDuration = max(x.manifest.TargetDuration, group.MaxTargetDuration())
+}
+
+// Merge merges the manifest into the indexed one as Manifest.Merge does, indexing only the merged segments, whose IVs are pinned when they change media sequence numbers as the segments already indexed keep their position.
+func (x *IndexedManifest) Merge(m2 Manifest) bool {
+	groups := slices.Clone(m2.SegmentGroups)
+	if sequence := uint64(x.manifest.MediaSequence) + uint64(len(x.ends)); sequence != uint64(m2.MediaSequence) {
+		pinIVs(groups, uint64(m2.MediaSequence), true)
+	}
+
+	if len(groups) > 0 {
+		groups[0].Continuous = false
+	}
+
+	hasBreakingChange := !x.manifest.IsCompatible(m2)
+	x.manifest.TargetDuration = max(x.manifest.TargetDuration, m2.TargetDuration)
+	x.manifest.Version = max(x.manifest.Version, m2.Version)
+	x.appendGroups(groups)
+
+	return hasBreakingChange
+}
+
+// SetEndList sets whether the manifest ends with the #EXT-X-ENDLIST tag.
+func (x *IndexedManifest) SetEndList(hasEndList bool) {
+	x.manifest.HasEndList = hasEndList
+}
+
+// RemoveFromStart removes n segments from the start of the manifest, as Manifest.RemoveFromStart does, in O(removed).
+func (x *IndexedManifest) RemoveFromStart(n int) (int, int) {
+	groupsRemoved, segmentsRemoved := x.manifest.RemoveFromStart(n)
+	if segmentsRemoved > 0 {
+		x.start = x.ends[segmentsRemoved-1]
+	}
+
+	x.ends = x.ends[segmentsRemoved:]
+	x.groupEnds = x.groupEnds[groupsRemoved:]
+	x.removed += segmentsRemoved
+
+	return groupsRemoved, segmentsRemoved
+}
+
+// RemoveFromEnd removes n segments from the end of the manifest, as Manifest.RemoveFromEnd does, in O(removed).
+func (x *IndexedManifest) RemoveFromEnd(n int) (int, int) {
+	groupsRemoved, segmentsRemoved := x.manifest.RemoveFromEnd(n)
+	x.ends = x.ends[:len(x.ends)-segmentsRemoved]
+	x.groupEnds = x.groupEnds[:len(x.groupEnds)-groupsRemoved]
+	if len(x.groupEnds) > 0 {
+		x.groupEnds[len(x.groupEnds)-1] = x.removed + len(x.ends)
+	}
+
+	return groupsRemoved, segmentsRemoved
+}
@@ -0,0 +1,152 @@
+package hls
+
+import (
+	"math"
+	"strconv"
+	"testing"
+)
+
+// indexedSegment returns the segment numbered i of a generated manifest
+func indexedSegment(i int) Segment {
+	return Segment{Path: "segments/" + strconv.Itoa(i) + ".ts", Duration: float32(2 + i%3)}
+}
+
+// checkIndex compares the index with the counts and lookups of the manifest walked segment by segment
+func checkIndex(t *testing.T, index *IndexedManifest) {
+	t.Helper()
+
+	manifest := index.Manifest()
+	if index.SegmentCount() != manifest.SegmentCount() {
+		t.Fatalf("expected %d segments, got %d", manifest.SegmentCount(), index.SegmentCount())
+	}
+
+	if math.Abs(index.Duration()-manifest.Duration()) > 1e-6 {
+		t.Fatalf("expected a duration of %f, got %f", manifest.Duration(), index.Duration())
+	}
+
+	sequence, start := manifest.MediaSequence, 0.0
+	for g, group := range manifest.SegmentGroups {
+		for i, segment := range group.Segments {
+			if found, index, ok := index.Locate(sequence); !ok || found != g || index != i {
+				t.Fatalf("expected segment %d in group %d at %d, got %d at %d", sequence, g, i, found, index)
+			}
+
+			if at, ok := index.SegmentAt(start + float64(segment.Duration)/2); !ok || at != sequence {
+				t.Fatalf("expected segment %d to play at %f, got %d", sequence, start, at)
+			}
+
+			if found, ok := index.Start(sequence); !ok || math.Abs(found-start) > 1e-6 {
+				t.Fatalf("expected segment %d to start at %f, got %f", sequence, start, found)
+			}
+
+			sequence++
+			start += float64(segment.Duration)
+		}
+	}
+
+	if _, ok := index.Segment(sequence); ok {
+		t.Fatalf("expected segment %d to be out of the manifest", sequence)
+	}
+
+	if _, ok := index.SegmentAt(start); ok {
+		t.Fatalf("expected %f to be out of the manifest", start)
+	}
+}
+
+func TestIndexedManifest(t *testing.T) {
+	manifest, err := ParseHlsManifest(generateManifest(2500))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	index := NewIndexedManifest(manifest.Clone())
+	checkIndex(t, index)
+
+	index.RemoveFromStart(1200)
+	manifest.RemoveFromStart(1200)
+	checkIndex(t, index)
+
+	for i := range 10 {
+		index.Append(indexedSegment(i))
+	}
+
+	index.AppendGroup(indexedSegment(10), indexedSegment(11))
+	checkIndex(t, index)
+
+	index.RemoveFromEnd(5)
+	checkIndex(t, index)
+
+	merged, _ := ParseHlsManifest(generateManifest(1500))
+	index.Merge(merged)
+	checkIndex(t, index)
+
+	next := index.Manifest().MediaSequence + uint32(index.SegmentCount())
+	index.RemoveFromStart(10000)
+	checkIndex(t, index)
+
+	index.Append(indexedSegment(0))
+	if index.SegmentCount() != 1 || index.Manifest().MediaSequence != next {
+		t.Errorf("expected the appended segment to follow the removed ones, got %d segments from %d", index.SegmentCount(), index.Manifest().MediaSequence)
+	}
+
+	checkIndex(t, index)
+}
+
+func TestIndexedManifestWindow(t *testing.T) {
+	manifest, err := ParseHlsManifest(generateManifest(3500))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	index := NewIndexedManifest(manifest.Clone())
+	index.RemoveFromStart(300)
+	manifest.RemoveFromStart(300)
+
+	for _, size := range []int{0, 1, 200, 700, 2200, 3200, 5000} {
+		expected := manifest.Clone()
+		if count := expected.SegmentCount(); count > size {
+			expected.RemoveFromStart(count - size)
+		}
+
+		window := index.Window(size)
+		if window.String() != expected.String() {
+			t.Errorf("expected the window of %d segments to be written as removing the start does", size)
+		}
+	}
+
+	if index.SegmentCount() != 3200 {
+		t.Errorf("expected the window to leave the manifest, got %d segments", index.SegmentCount())
+	}
+}
+
+func BenchmarkIndexedManifest(b *testing.B) {
+	for _, size := range benchmarkSizes {
+		manifest := benchmarkManifest(b, size)
+		index := NewIndexedManifest(manifest.Clone())
+
+		b.Run("duration/"+strconv.Itoa(size), func(b *testing.B) {
+			for b.Loop() {
+				_ = index.Duration()
+			}
+		})
+
+		b.Run("walk/"+strconv.Itoa(size), func(b *testing.B) {
+			for b.Loop() {
+				_ = manifest.Duration()
+			}
+		})
+
+		b.Run("segment-at/"+strconv.Itoa(size), func(b *testing.B) {
+			for b.Loop() {
+				_, _ = index.SegmentAt(index.Duration() / 2)
+			}
+		})
+
+		b.Run("window/"+strconv.Itoa(size), func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				_ = index.Window(6)
+			}
+		})
+	}
+}
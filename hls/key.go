@@ -0,0 +1,259 @@
+package hls
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"slices"
+	"strings"
+)
+
+const (
+	// KeyField is the field that indicates how the following segments are encrypted.
+	KeyField = "#EXT-X-KEY"
+
+	// IdentityKeyFormat is the key format of the keys delivered as 16 raw bytes, the default when KEYFORMAT is missing.
+	IdentityKeyFormat = "identity"
+)
+
+// ErrInvalidIV indicates that an initialization vector is not a 128 bits hexadecimal number.
+var ErrInvalidIV = errors.New("invalid initialization vector")
+
+// EncryptionMethod represents how segments are encrypted.
+type EncryptionMethod string
+
+const (
+	// MethodNone indicates that the segments are not encrypted.
+	MethodNone EncryptionMethod = "NONE"
+
+	// MethodAES128 indicates that the whole segments are encrypted with AES-128 in CBC mode with PKCS7 padding.
+	MethodAES128 EncryptionMethod = "AES-128"
+
+	// MethodSampleAES indicates that the media samples are encrypted with AES-128, leaving the container clear.
+	MethodSampleAES EncryptionMethod = "SAMPLE-AES"
+
+	// MethodSampleAESCTR indicates that the media samples are encrypted with AES-128 in CTR mode, as in CENC.
+	MethodSampleAESCTR EncryptionMethod = "SAMPLE-AES-CTR"
+)
+
+// Key represents a key encrypting segments, as declared by an EXT-X-KEY tag.
+type Key struct {
+	Method            EncryptionMethod // Encryption method of the segments
+	URI               string           // URI of the key, empty for MethodNone
+	IV                string           // Initialization vector as a hexadecimal number prefixed by "0x", empty to use the media sequence number of each segment
+	KeyFormat         string           // Format of the key, like "com.apple.streamingkeydelivery", empty for IdentityKeyFormat
+	KeyFormatVersions string           // Versions of the key format, like "1/2", empty for version 1
+}
+
+// format returns the key format, defaulting to IdentityKeyFormat.
+func (k *Key) format() string {
+	if k.KeyFormat == "" {
+		return IdentityKeyFormat
+	}
+
+	return k.KeyFormat
+}
+
+// parseKey parses the attribute list of an EXT-X-KEY tag.
+func parseKey(list string) (Key, error) {
+	attributes, err := parseAttributes(list)
+	if err != nil {
+		return Key{}, err
+	}
+
+	var key Key
+	for _, attribute := range attributes {
+		switch attribute.Key {
+		case "METHOD":
+			method, err := enumerated(attribute)
+			if err != nil {
+				return Key{}, err
+			}
+
+			key.Method = EncryptionMethod(method)
+		case "URI":
+			key.URI = attribute.Value
+		case "IV":
+			if _, err := ParseIV(attribute.Value); err != nil {
+				return Key{}, err
+			}
+
+			key.IV = attribute.Value
+		case "KEYFORMAT":
+			key.KeyFormat = attribute.Value
+		case "KEYFORMATVERSIONS":
+			key.KeyFormatVersions = attribute.Value
+		}
+	}
+
+	if key.Method == "" || (key.Method != MethodNone && key.URI == "") {
+		return Key{}, ErrInvalidAttributes
+	}
+
+	return key, nil
+}
+
+// String returns the key as an EXT-X-KEY tag.
+func (k *Key) String() string {
+	attributes := []Attribute{{Key: "METHOD", Value: string(k.Method)}}
+	if k.URI != "" {
+		attributes = append(attributes, Attribute{Key: "URI", Value: k.URI, Quoted: true})
+	}
+
+	if k.IV != "" {
+		attributes = append(attributes, Attribute{Key: "IV", Value: k.IV})
+	}
+
+	if k.KeyFormat != "" {
+		attributes = append(attributes, Attribute{Key: "KEYFORMAT", Value: k.KeyFormat, Quoted: true})
+	}
+
+	if k.KeyFormatVersions != "" {
+		attributes = append(attributes, Attribute{Key: "KEYFORMATVERSIONS", Value: k.KeyFormatVersions, Quoted: true})
+	}
+
+	return KeyField + ":" + formatAttributes(attributes)
+}
+
+// applyKey returns the keys applying to the next segments after an EXT-X-KEY tag, which replaces the key of the same format or clears every key.
+func applyKey(keys []Key, key Key) []Key {
+	if key.Method == MethodNone {
+		return nil
+	}
+
+	keys = slices.DeleteFunc(slices.Clone(keys), func(k Key) bool { return k.format() == key.format() })
+	return append(keys, key)
+}
+
+// writeKeys writes the EXT-X-KEY tags switching from the previous keys to the keys of the next segment.
+func writeKeys(w manifestWriter, previous []Key, keys []Key) {
+	if slices.Equal(previous, keys) {
+		return
+	}
+
+	for _, key := range previous {
+		if !slices.ContainsFunc(keys, func(k Key) bool { return k.format() == key.format() }) {
+			w.WriteString(KeyField + ":METHOD=" + string(MethodNone) + "\n")
+			previous = nil
+			break
+		}
+	}
+
+	for _, key := range keys {
+		if !slices.Contains(previous, key) {
+			w.WriteString(key.String() + "\n")
+		}
+	}
+}
+
+// ParseIV parses an initialization vector written as a hexadecimal number prefixed by "0x".
+func ParseIV(iv string) ([]byte, error) {
+	digits, found := strings.CutPrefix(strings.ToLower(iv), "0x")
+	if !found || len(digits) == 0 || len(digits) > 32 {
+		return nil, ErrInvalidIV
+	}
+
+	value, err := hex.DecodeString(strings.Repeat("0", 32-len(digits)) + digits)
+	if err != nil {
+		return nil, ErrInvalidIV
+	}
+
+	return value, nil
+}
+
+// FormatIV returns the initialization vector as a hexadecimal number prefixed by "0x".
+func FormatIV(iv []byte) string {
+	return "0x" + hex.EncodeToString(iv)
+}
+
+// SequenceIV returns the initialization vector of a segment encrypted with AES-128 without an explicit IV, which is its media sequence number.
+func SequenceIV(sequence uint64) []byte {
+	iv := make([]byte, 16)
+	binary.BigEndian.PutUint64(iv[8:], sequence)
+	return iv
+}
+
+// usesSequenceIV returns true if the key decrypts each segment with its media sequence number when it declares no IV.
+func (k *Key) usesSequenceIV() bool {
+	return k.IV == "" && (k.Method == MethodAES128 || k.Method == MethodSampleAES)
+}
+
+// PinIVs writes the IV of the keys declaring none on each segment, which is the media sequence number of the segment, so the segments still decrypt once moved to another position like when manifests are merged.
+func (m *Manifest) PinIVs() {
+	pinIVs(m.SegmentGroups, uint64(m.MediaSequence), false)
+}
+
+// pinIVs pins the IVs of the segments of the groups as PinIVs does, the first segment having the media sequence number, cloning the segments of each group it pins first when they are shared, so the manifest the groups were copied from is left untouched.
+func pinIVs(groups []SegmentGroup, sequence uint64, shared bool) {
+	for i := range groups {
+		cloned := !shared
+		segments := groups[i].Segments
+		for j := range segments {
+			if slices.ContainsFunc(segments[j].Keys, func(k Key) bool { return k.usesSequenceIV() }) {
+				if !cloned {
+					segments, cloned = slices.Clone(segments), true
+					groups[i].Segments = segments
+				}
+
+				keys := slices.Clone(segments[j].Keys)
+				for k := range keys {
+					if keys[k].usesSequenceIV() {
+						keys[k].IV = FormatIV(SequenceIV(sequence))
+					}
+				}
+
+				segments[j].Keys = keys
+			}
+
+			sequence++
+		}
+	}
+}
+
+// RewriteKeyURIs replaces the URIs of the keys fetched by the players, like to serve them from a key proxy, leaving the DRM keys untouched.
+//
+// The segments sharing the keys of an EXT-X-KEY tag keep sharing the rewritten keys, so each key is rewritten once.
+func (m *Manifest) RewriteKeyURIs(rewrite func(uri string) string) {
+	type shared struct {
+		first *Key
+		count int
+	}
+
+	rewritten := map[shared][]Key{}
+	for i := range m.SegmentGroups {
+		segments := m.SegmentGroups[i].Segments
+		for j := range segments {
+			if len(segments[j].Keys) == 0 {
+				continue
+			}
+
+			original := shared{&segments[j].Keys[0], len(segments[j].Keys)}
+			keys, found := rewritten[original]
+			if !found {
+				keys = slices.Clone(segments[j].Keys)
+				for k := range keys {
+					if !keys[k].DRM() && keys[k].URI != "" {
+						keys[k].URI = rewrite(keys[k].URI)
+					}
+				}
+
+				rewritten[original] = keys
+			}
+
+			segments[j].Keys = keys
+		}
+	}
+}
+
+// Encryption returns the encryption method of the first encrypted segment of the manifest, or MethodNone when every segment is clear.
+func (m *Manifest) Encryption() EncryptionMethod {
+	for _, group := range m.SegmentGroups {
+		for _, segment := range group.Segments {
+			if len(segment.Keys) > 0 {
+				return segment.Keys[0].Method
+			}
+		}
+	}
+
+	return MethodNone
+}
@@ -0,0 +1,193 @@
+package hls
+
+import (
+	"bytes"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// encryptedManifest is a manifest rotating its key, adding a FairPlay key and going clear
+const encryptedManifest = `#EXTM3U
+#EXT-X-VERSION:5
+#EXT-X-TARGETDURATION:4
+#EXT-X-MEDIA-SEQUENCE:10
+#EXT-X-KEY:METHOD=AES-128,URI="https://keys.example/1",IV=0x0000000000000000000000000000000A
+#EXTINF:4,
+10.ts
+#EXT-X-KEY:METHOD=SAMPLE-AES,URI="https://keys.example/2"
+#EXT-X-KEY:METHOD=SAMPLE-AES,URI="skd://asset",KEYFORMAT="com.apple.streamingkeydelivery",KEYFORMATVERSIONS="1"
+#EXTINF:4,
+11.ts
+#EXTINF:4,
+12.ts
+#EXT-X-KEY:METHOD=NONE
+#EXTINF:4,
+13.ts`
+
+func TestParseKeys(t *testing.T) {
+	manifest, err := ParseHlsManifest(encryptedManifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	segments := manifest.SegmentGroups[0].Segments
+	if len(segments[0].Keys) != 1 || segments[0].Keys[0].Method != MethodAES128 || segments[0].Keys[0].IV != "0x0000000000000000000000000000000A" {
+		t.Errorf("expected the AES-128 key on the first segment, got %+v", segments[0].Keys)
+	}
+
+	if len(segments[1].Keys) != 2 || segments[1].Keys[0].URI != "https://keys.example/2" || segments[1].Keys[1].KeyFormat != "com.apple.streamingkeydelivery" {
+		t.Errorf("expected the rotated identity key and the FairPlay key, got %+v", segments[1].Keys)
+	}
+
+	if !slices.Equal(segments[1].Keys, segments[2].Keys) {
+		t.Errorf("expected the keys to apply until the next key tag, got %+v", segments[2].Keys)
+	}
+
+	if len(segments[3].Keys) != 0 {
+		t.Errorf("expected the last segment to be clear, got %+v", segments[3].Keys)
+	}
+
+	output := manifest.String()
+	if strings.Count(output, KeyField) != 4 {
+		t.Errorf("expected a key tag only where the keys change, got %s", output)
+	}
+
+	parsed, err := ParseHlsManifest(strings.TrimRight(output, "\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, segment := range parsed.SegmentGroups[0].Segments {
+		if !slices.Equal(segment.Keys, segments[i].Keys) {
+			t.Errorf("expected the keys of segment %d to survive writing, got %+v", i, segment.Keys)
+		}
+	}
+}
+
+func TestWriteKeysDroppingFormat(t *testing.T) {
+	identity := Key{Method: MethodSampleAES, URI: "https://keys.example/1"}
+	fairPlay := Key{Method: MethodSampleAES, URI: "skd://asset", KeyFormat: "com.apple.streamingkeydelivery"}
+
+	var builder strings.Builder
+	writeKeys(&builder, []Key{identity, fairPlay}, []Key{identity})
+	if builder.String() != "#EXT-X-KEY:METHOD=NONE\n"+identity.String()+"\n" {
+		t.Errorf("expected the keys to be cleared before the remaining key, got %q", builder.String())
+	}
+}
+
+func TestParseKeyErrors(t *testing.T) {
+	for _, line := range []string{"#EXT-X-KEY:URI=\"k\"", "#EXT-X-KEY:METHOD=AES-128", "#EXT-X-KEY:METHOD=AES-128,URI=\"k\",IV=12"} {
+		_, err := ParseHlsManifest("#EXTM3U\n" + line)
+		var parseErr *ParseError
+		if !errors.As(err, &parseErr) || parseErr.Field != KeyField {
+			t.Errorf("expected a key parse error for %s, got %v", line, err)
+		}
+	}
+}
+
+func TestIV(t *testing.T) {
+	iv, err := ParseIV("0x1F")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(iv, SequenceIV(31)) {
+		t.Errorf("expected the IV of sequence 31, got %x", iv)
+	}
+
+	if FormatIV(iv) != "0x0000000000000000000000000000001f" {
+		t.Errorf("expected a zero padded IV, got %s", FormatIV(iv))
+	}
+
+	for _, invalid := range []string{"", "1F", "0x", "0xZZ", "0x" + strings.Repeat("0", 33)} {
+		if _, err := ParseIV(invalid); !errors.Is(err, ErrInvalidIV) {
+			t.Errorf("expected ErrInvalidIV for %q, got %v", invalid, err)
+		}
+	}
+}
+
+func TestPinIVs(t *testing.T) {
+	first, err := ParseHlsManifest("#EXTM3U\n#EXT-X-MEDIA-SEQUENCE:7\n#EXT-X-KEY:METHOD=SAMPLE-AES,URI=\"https://keys.example/a\"\n#EXTINF:4,\na.ts\n#EXT-X-KEY:METHOD=SAMPLE-AES-CTR,URI=\"https://keys.example/b\"\n#EXTINF:4,\nb.ts")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := ParseHlsManifest("#EXTM3U\n#EXT-X-MEDIA-SEQUENCE:100\n#EXT-X-KEY:METHOD=AES-128,URI=\"https://keys.example/c\"\n#EXTINF:4,\nc.ts")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if first.Encryption() != MethodSampleAES {
+		t.Errorf("expected SAMPLE-AES, got %s", first.Encryption())
+	}
+
+	first.Merge(second)
+	if iv := first.SegmentGroups[0].Segments[0].Keys[0].IV; iv != "" {
+		t.Errorf("expected the segments keeping their sequence to keep their implicit IV, got %s", iv)
+	}
+
+	merged := first.SegmentGroups[1].Segments[0]
+	if iv, _ := ParseIV(merged.Keys[0].IV); !bytes.Equal(iv, SequenceIV(100)) {
+		t.Errorf("expected the merged segment to keep the IV of its original sequence, got %s", merged.Keys[0].IV)
+	}
+
+	if iv := second.SegmentGroups[0].Segments[0].Keys[0].IV; iv != "" {
+		t.Errorf("expected the merged manifest to be left untouched, got the IV %s", iv)
+	}
+
+	first.PinIVs()
+	segments := first.SegmentGroups[0].Segments
+	if iv, _ := ParseIV(segments[0].Keys[0].IV); !bytes.Equal(iv, SequenceIV(7)) {
+		t.Errorf("expected the IV of sequence 7 to be pinned, got %s", segments[0].Keys[0].IV)
+	}
+
+	if segments[1].Keys[0].IV != "" {
+		t.Errorf("expected SAMPLE-AES-CTR to keep its IV in the samples, got %s", segments[1].Keys[0].IV)
+	}
+}
+
+func TestRewriteKeyURIs(t *testing.T) {
+	manifest, err := ParseHlsManifest(encryptedManifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	original := manifest.Clone()
+	var rewritten []string
+	manifest.RewriteKeyURIs(func(uri string) string {
+		rewritten = append(rewritten, uri)
+		return strings.Replace(uri, "https://keys.example/", "/keys/", 1)
+	})
+
+	if !slices.Equal(rewritten, []string{"https://keys.example/1", "https://keys.example/2"}) {
+		t.Errorf("expected each fetched key to be rewritten once, got %v", rewritten)
+	}
+
+	written := manifest.String()
+	if !strings.Contains(written, `URI="/keys/1"`) || !strings.Contains(written, `URI="/keys/2"`) || !strings.Contains(written, `URI="skd://asset"`) {
+		t.Errorf("expected the fetched keys to be rewritten and the DRM key untouched, got %s", written)
+	}
+
+	segments := manifest.SegmentGroups[0].Segments
+	if &segments[1].Keys[0] != &segments[2].Keys[0] {
+		t.Errorf("expected the segments to keep sharing their keys")
+	}
+
+	if original.String() != encryptedManifestWritten(t) {
+		t.Errorf("expected the clone to keep its keys, got %s", original.String())
+	}
+}
+
+// encryptedManifestWritten returns encryptedManifest as written once parsed
+func encryptedManifestWritten(t *testing.T) string {
+	t.Helper()
+
+	manifest, err := ParseHlsManifest(encryptedManifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return manifest.String()
+}
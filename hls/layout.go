@@ -0,0 +1,94 @@
+package hls
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrInvalidResolution indicates that a resolution is not formatted as "<width>x<height>".
+	ErrInvalidResolution = errors.New("invalid resolution")
+
+	// ErrLayoutMismatch indicates that two videos cannot follow each other in a stream without the player changing how it renders them.
+	ErrLayoutMismatch = errors.New("video layout mismatch")
+)
+
+// eyeAspectTolerance is the relative difference tolerated between the aspect ratios of the eyes of two stereo videos, absorbing the rounding of encoders.
+const eyeAspectTolerance = 0.01
+
+// ParseResolution parses a resolution formatted as "<width>x<height>", like the RESOLUTION attribute of a variant.
+func ParseResolution(resolution string) (int, int, error) {
+	w, h, found := strings.Cut(resolution, "x")
+	if !found {
+		return 0, 0, ErrInvalidResolution
+	}
+
+	width, err := strconv.Atoi(w)
+	if err != nil || width <= 0 {
+		return 0, 0, ErrInvalidResolution
+	}
+
+	height, err := strconv.Atoi(h)
+	if err != nil || height <= 0 {
+		return 0, 0, ErrInvalidResolution
+	}
+
+	return width, height, nil
+}
+
+// EyeResolution returns the resolution of the view of each eye, halving the packed frames of side by side and top bottom videos.
+func (l Layout) EyeResolution() (int, int, error) {
+	width, height, err := ParseResolution(l.Resolution)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	switch l.Stereo {
+	case StereoSideBySide:
+		width /= 2
+	case StereoTopBottom:
+		height /= 2
+	}
+
+	return width, height, nil
+}
+
+// isStereoscopic returns true if the layout carries a view for each eye.
+func (l Layout) isStereoscopic() bool {
+	return l.Stereo != "" && l.Stereo != StereoMono
+}
+
+// Check returns ErrLayoutMismatch describing the first difference in projection, frame packing or eye aspect ratio that prevents the videos from following each other in a stream, unknown fields being compatible with anything.
+func (l Layout) Check(other Layout) error {
+	if l.Projection != "" && other.Projection != "" && l.Projection != other.Projection {
+		return fmt.Errorf("%w: projection %s differs from %s", ErrLayoutMismatch, other.Projection, l.Projection)
+	}
+
+	if l.Stereo != "" && other.Stereo != "" && l.Stereo != other.Stereo {
+		return fmt.Errorf("%w: frame packing %s differs from %s", ErrLayoutMismatch, other.Stereo, l.Stereo)
+	}
+
+	if !l.isStereoscopic() || !other.isStereoscopic() {
+		return nil
+	}
+
+	width, height, err := l.EyeResolution()
+	if err != nil {
+		return nil
+	}
+
+	otherWidth, otherHeight, err := other.EyeResolution()
+	if err != nil {
+		return nil
+	}
+
+	aspect, otherAspect := float64(width)/float64(height), float64(otherWidth)/float64(otherHeight)
+	if math.Abs(aspect-otherAspect) > eyeAspectTolerance*max(aspect, otherAspect) {
+		return fmt.Errorf("%w: eyes of %dx%d differ from %dx%d", ErrLayoutMismatch, otherWidth, otherHeight, width, height)
+	}
+
+	return nil
+}
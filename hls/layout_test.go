@@ -0,0 +1,81 @@
+package hls
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseResolution(t *testing.T) {
+	width, height, err := ParseResolution("7680x3840")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if width != 7680 || height != 3840 {
+		t.Errorf("expected 7680x3840, got %dx%d", width, height)
+	}
+
+	for _, resolution := range []string{"", "7680", "x3840", "7680x", "-1x100", "axb"} {
+		if _, _, err := ParseResolution(resolution); !errors.Is(err, ErrInvalidResolution) {
+			t.Errorf("expected ErrInvalidResolution for %q, got %v", resolution, err)
+		}
+	}
+}
+
+func TestEyeResolution(t *testing.T) {
+	cases := []struct {
+		layout        Layout
+		width, height int
+	}{
+		{Layout{Stereo: StereoSideBySide, Resolution: "7680x3840"}, 3840, 3840},
+		{Layout{Stereo: StereoTopBottom, Resolution: "3840x3840"}, 3840, 1920},
+		{Layout{Stereo: StereoMultiview, Resolution: "4320x4320"}, 4320, 4320},
+		{Layout{Resolution: "1920x1080"}, 1920, 1080},
+	}
+
+	for _, c := range cases {
+		width, height, err := c.layout.EyeResolution()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if width != c.width || height != c.height {
+			t.Errorf("expected %dx%d for %+v, got %dx%d", c.width, c.height, c.layout, width, height)
+		}
+	}
+}
+
+func TestLayoutCheck(t *testing.T) {
+	vr180 := Layout{Projection: ProjectionHalfEquirectangular, Stereo: StereoSideBySide, Resolution: "5760x2880"}
+
+	cases := []struct {
+		other  Layout
+		reason string
+	}{
+		{Layout{Projection: ProjectionHalfEquirectangular, Stereo: StereoSideBySide, Resolution: "3840x1920"}, ""},
+		{Layout{Projection: ProjectionHalfEquirectangular, Stereo: StereoSideBySide, Resolution: "3842x1920"}, ""},
+		{Layout{Projection: ProjectionHalfEquirectangular, Stereo: StereoSideBySide}, ""},
+		{Layout{Projection: ProjectionEquirectangular, Stereo: StereoSideBySide, Resolution: "5760x2880"}, "projection"},
+		{Layout{Projection: ProjectionHalfEquirectangular, Stereo: StereoTopBottom, Resolution: "2880x5760"}, "frame packing"},
+		{Layout{Projection: ProjectionHalfEquirectangular, Stereo: StereoSideBySide, Resolution: "3840x2160"}, "eyes"},
+	}
+
+	for _, c := range cases {
+		err := vr180.Check(c.other)
+		if c.reason == "" {
+			if err != nil {
+				t.Errorf("expected %+v to be consistent, got %v", c.other, err)
+			}
+			continue
+		}
+
+		if !errors.Is(err, ErrLayoutMismatch) || !strings.Contains(err.Error(), c.reason) {
+			t.Errorf("expected a %s mismatch for %+v, got %v", c.reason, c.other, err)
+		}
+	}
+
+	if err := (Layout{Stereo: StereoMono, Resolution: "1920x1080"}).Check(Layout{Stereo: StereoMono, Resolution: "1080x1920"}); err != nil {
+		t.Errorf("expected mono videos of any resolution to be consistent, got %v", err)
+	}
+}
@@ -0,0 +1,79 @@
+package hls
+
+// LiveWindow is a live manifest keeping only its last segments, sliding as segments are appended, like a live stream simulated from VOD content.
+type LiveWindow struct {
+	manifest      Manifest
+	size          int  // segments kept
+	discontinuity bool // whether the next segment appended starts a new group
+	nextMap       Map  // initialization section of the next segments appended
+}
+
+// NewLiveWindow creates a window of size segments, at least one, starting with the last segments of the manifest and taking ownership of its groups.
+func NewLiveWindow(m Manifest, size int) *LiveWindow {
+	w := &LiveWindow{manifest: m, size: max(size, 1)}
+	if len(m.SegmentGroups) > 0 {
+		w.nextMap = m.SegmentGroups[len(m.SegmentGroups)-1].Map
+	}
+
+	w.slide()
+
+	return w
+}
+
+// Size returns the number of segments kept by the window.
+func (w *LiveWindow) Size() int {
+	return w.size
+}
+
+// Manifest returns the manifest of the window, sharing its segments with the window, so it must be cloned to be modified or kept while the window slides.
+func (w *LiveWindow) Manifest() Manifest {
+	return w.manifest
+}
+
+// String returns the manifest of the window as a string.
+func (w *LiveWindow) String() string {
+	return w.manifest.String()
+}
+
+// Append appends the segments, after a discontinuity if one was marked, raising the target duration to fit them, then removes the segments sliding out of the window from the start, advancing the media sequence and the discontinuity sequence.
+func (w *LiveWindow) Append(segments ...Segment) {
+	if len(segments) == 0 {
+		return
+	}
+
+	groups := w.manifest.SegmentGroups
+	if w.discontinuity || len(groups) == 0 || groups[len(groups)-1].Map != w.nextMap {
+		// A new initialization section without a discontinuity continues the segments of the previous group.
+		w.manifest.SegmentGroups = append(w.manifest.SegmentGroups, SegmentGroup{Map: w.nextMap, Continuous: !w.discontinuity && len(groups) > 0})
+		w.discontinuity = false
+	}
+
+	last := &w.manifest.SegmentGroups[len(w.manifest.SegmentGroups)-1]
+	last.Segments = append(last.Segments, segments...)
+
+	group := SegmentGroup{Segments: segments}
+	w.manifest.TargetDuration = max(w.manifest.TargetDuration, group.MaxTargetDuration())
+	w.slide()
+}
+
+// Discontinuity marks a discontinuity before the next segment appended, like when the next item of a queue starts, ignored while the window is empty.
+func (w *LiveWindow) Discontinuity() {
+	w.discontinuity = len(w.manifest.SegmentGroups) > 0
+}
+
+// SetMap sets the initialization section of the next segments appended, like the init segment of the fMP4 segments of the next item, starting a group continuing the previous one when it changes.
+func (w *LiveWindow) SetMap(m Map) {
+	w.nextMap = m
+}
+
+// End ends the manifest with the #EXT-X-ENDLIST tag, once the content is over.
+func (w *LiveWindow) End() {
+	w.manifest.HasEndList = true
+}
+
+// slide removes the segments out of the window from the start of the manifest.
+func (w *LiveWindow) slide() {
+	if count := w.manifest.SegmentCount(); count > w.size {
+		w.manifest.RemoveFromStart(count - w.size)
+	}
+}
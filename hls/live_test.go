@@ -0,0 +1,82 @@
+package hls
+
+import (
+	"strconv"
+	"testing"
+)
+
+// liveSegments returns n segments of 4 seconds numbered from the first one
+func liveSegments(first int, n int) []Segment {
+	segments := make([]Segment, n)
+	for i := range segments {
+		segments[i] = Segment{Path: strconv.Itoa(first+i) + ".ts", Duration: 4}
+	}
+
+	return segments
+}
+
+func TestLiveWindow(t *testing.T) {
+	w := NewLiveWindow(Manifest{Version: 3, SegmentGroups: []SegmentGroup{{Segments: liveSegments(0, 5)}}}, 3)
+
+	manifest := w.Manifest()
+	if manifest.SegmentCount() != 3 || manifest.MediaSequence != 2 || manifest.SegmentGroups[0].Segments[0].Path != "2.ts" {
+		t.Errorf("expected the last 3 segments of the manifest, got %s", w.String())
+	}
+
+	w.Append(liveSegments(5, 2)...)
+	w.Discontinuity()
+	w.Append(Segment{Path: "ad.ts", Duration: 6.5})
+
+	manifest = w.Manifest()
+	if manifest.MediaSequence != 5 || manifest.DiscontinuitySequence != 0 || len(manifest.SegmentGroups) != 2 || manifest.TargetDuration != 7 {
+		t.Errorf("expected the window to slide to a discontinuity, got %s", w.String())
+	}
+
+	w.Append(liveSegments(7, 2)...)
+	manifest = w.Manifest()
+	if manifest.MediaSequence != 7 || manifest.DiscontinuitySequence != 1 || len(manifest.SegmentGroups) != 1 || manifest.SegmentGroups[0].Segments[0].Path != "ad.ts" {
+		t.Errorf("expected the discontinuity sequence to advance once the discontinuity slides out, got %s", w.String())
+	}
+
+	w.End()
+	if !w.Manifest().HasEndList {
+		t.Errorf("expected the window to end")
+	}
+}
+
+func TestLiveWindowEmpty(t *testing.T) {
+	w := NewLiveWindow(Manifest{}, 0)
+	w.Discontinuity()
+	w.Append()
+	w.Append(liveSegments(0, 2)...)
+
+	manifest := w.Manifest()
+	if w.Size() != 1 || len(manifest.SegmentGroups) != 1 || manifest.MediaSequence != 1 || manifest.DiscontinuitySequence != 0 {
+		t.Errorf("expected a window of one segment without discontinuity, got %s", w.String())
+	}
+}
+
+func BenchmarkLiveWindow(b *testing.B) {
+	w := NewLiveWindow(Manifest{}, 6)
+	segment := Segment{Path: "segment.ts", Duration: 4}
+
+	for i := 0; b.Loop(); i++ {
+		if i%100 == 0 {
+			w.Discontinuity()
+		}
+
+		w.Append(segment)
+	}
+}
+
+func TestLiveWindowMap(t *testing.T) {
+	w := NewLiveWindow(Manifest{SegmentGroups: []SegmentGroup{{Map: Map{URI: "a/init.mp4"}, Segments: liveSegments(0, 1)}}}, 4)
+	w.Append(liveSegments(1, 1)...)
+	w.SetMap(Map{URI: "b/init.mp4"})
+	w.Append(liveSegments(2, 2)...)
+
+	manifest := w.Manifest()
+	if len(manifest.SegmentGroups) != 2 || len(manifest.SegmentGroups[0].Segments) != 2 || manifest.SegmentGroups[1].Map.URI != "b/init.mp4" {
+		t.Errorf("expected the new initialization section to start a group, got %s", w.String())
+	}
+}
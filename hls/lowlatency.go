@@ -0,0 +1,313 @@
+package hls
+
+import (
+	"math"
+	"strconv"
+)
+
+const (
+	// PartField is the field that indicates a partial segment of a Low-Latency HLS playlist.
+	PartField = "#EXT-X-PART"
+
+	// PartInfField is the field that indicates the target duration of the partial segments.
+	PartInfField = "#EXT-X-PART-INF"
+
+	// ServerControlField is the field that indicates the delivery directives supported by the server.
+	ServerControlField = "#EXT-X-SERVER-CONTROL"
+
+	// PreloadHintField is the field that indicates a resource the players can request before it is available.
+	PreloadHintField = "#EXT-X-PRELOAD-HINT"
+
+	// SkipField is the field that replaces the segments skipped by a playlist delta update.
+	SkipField = "#EXT-X-SKIP"
+)
+
+// Part represents a partial segment of a Low-Latency HLS playlist, published while its segment is being produced.
+type Part struct {
+	URI         string    // URI of the partial segment
+	Duration    float32   // Duration of the partial segment
+	Independent bool      // Indicates if the partial segment starts with an independent frame
+	Gap         bool      // Indicates if the partial segment is unavailable
+	ByteRange   ByteRange // Sub-range of the resource at URI, zero for the whole resource
+}
+
+// follows returns true if the sub-range of the part starts where the one of the previous part, nil for none, ends in the same resource.
+func (p *Part) follows(previous *Part) bool {
+	return previous != nil && previous.ByteRange.Length > 0 && previous.URI == p.URI && previous.ByteRange.End() == p.ByteRange.Offset
+}
+
+// ServerControl represents the delivery directives supported by the server of a Low-Latency HLS playlist, each zero field being unsupported.
+type ServerControl struct {
+	CanSkipUntil      float32 // Seconds from the live edge before which the segments can be skipped by a delta update
+	CanSkipDateRanges bool    // Indicates if a delta update can also skip the date ranges
+	HoldBack          float32 // Minimum seconds from the live edge to start the playback at
+	PartHoldBack      float32 // Minimum seconds from the live edge to start the low-latency playback at
+	CanBlockReload    bool    // Indicates if the server holds the playlist requests until the requested segment is available
+}
+
+// HintType represents the kind of resource a preload hint refers to.
+type HintType string
+
+const (
+	// HintPart is a hint of the next partial segment.
+	HintPart HintType = "PART"
+
+	// HintMap is a hint of the next initialization section.
+	HintMap HintType = "MAP"
+)
+
+// PreloadHint represents a resource the players can request before it is available, the server answering once it is.
+type PreloadHint struct {
+	Type            HintType // Kind of the resource
+	URI             string   // URI of the resource
+	ByteRangeStart  uint64   // Offset of the sub-range of the resource
+	ByteRangeLength uint64   // Length of the sub-range of the resource, zero when it runs to the end of the resource
+}
+
+// parseSeconds parses a non-negative decimal number of seconds.
+func parseSeconds(value string) (float32, error) {
+	seconds, err := strconv.ParseFloat(value, 32)
+	if err != nil {
+		return 0, err
+	}
+
+	if seconds < 0 || math.IsNaN(seconds) || math.IsInf(seconds, 0) {
+		return 0, ErrInvalidDuration
+	}
+
+	return float32(seconds), nil
+}
+
+// formatSeconds formats a number of seconds as the EXTINF durations are.
+func formatSeconds(seconds float32) string {
+	return strconv.FormatFloat(float64(seconds), 'f', -1, 32)
+}
+
+// yes returns true if the attribute is the enumerated string YES, rejecting the quoted ones.
+func yes(attribute Attribute) (bool, error) {
+	value, err := enumerated(attribute)
+	return value == "YES", err
+}
+
+// parsePart parses the attribute list of an EXT-X-PART tag, the offset of its sub-range defaulting to the end of the sub-range of the previous part, nil for none, of the same resource.
+func parsePart(list string, previous *Part) (Part, error) {
+	attributes, err := parseAttributes(list)
+	if err != nil {
+		return Part{}, err
+	}
+
+	var part Part
+	hasDuration, hasOffset := false, true
+	for _, attribute := range attributes {
+		switch attribute.Key {
+		case "URI":
+			part.URI = attribute.Value
+		case "DURATION":
+			if part.Duration, err = parseSeconds(attribute.Value); err != nil {
+				return Part{}, err
+			}
+
+			hasDuration = true
+		case "INDEPENDENT":
+			if part.Independent, err = yes(attribute); err != nil {
+				return Part{}, err
+			}
+		case "GAP":
+			if part.Gap, err = yes(attribute); err != nil {
+				return Part{}, err
+			}
+		case "BYTERANGE":
+			if !attribute.Quoted {
+				return Part{}, ErrInvalidAttributes
+			}
+
+			if part.ByteRange, hasOffset, err = parseByteRange(attribute.Value); err != nil {
+				return Part{}, err
+			}
+		}
+	}
+
+	if part.URI == "" || !hasDuration {
+		return Part{}, ErrInvalidAttributes
+	}
+
+	if !hasOffset {
+		if previous == nil || previous.ByteRange.Length == 0 || previous.URI != part.URI || previous.ByteRange.End() > math.MaxUint64-part.ByteRange.Length {
+			return Part{}, ErrInvalidByteRange
+		}
+
+		part.ByteRange.Offset = previous.ByteRange.End()
+	}
+
+	return part, nil
+}
+
+// writePart writes the EXT-X-PART tag of the part following the previous one, leaving the offset out when its sub-range follows the one of the previous part.
+func writePart(w manifestWriter, previous *Part, part *Part) {
+	attributes := []Attribute{{Key: "DURATION", Value: formatSeconds(part.Duration)}, {Key: "URI", Value: part.URI, Quoted: true}}
+	if part.Independent {
+		attributes = append(attributes, Attribute{Key: "INDEPENDENT", Value: "YES"})
+	}
+
+	if part.ByteRange.Length > 0 {
+		value := strconv.FormatUint(part.ByteRange.Length, 10)
+		if !part.follows(previous) {
+			value = part.ByteRange.String()
+		}
+
+		attributes = append(attributes, Attribute{Key: "BYTERANGE", Value: value, Quoted: true})
+	}
+
+	if part.Gap {
+		attributes = append(attributes, Attribute{Key: "GAP", Value: "YES"})
+	}
+
+	w.WriteString(PartField + ":" + formatAttributes(attributes) + "\n")
+}
+
+// writeParts writes the parts following the previous one, returning the last part written, or the previous one when there are none.
+func writeParts(w manifestWriter, previous *Part, parts []Part) *Part {
+	for i := range parts {
+		writePart(w, previous, &parts[i])
+		previous = &parts[i]
+	}
+
+	return previous
+}
+
+// parsePartInf parses the attribute list of an EXT-X-PART-INF tag, returning the part target duration.
+func parsePartInf(list string) (float32, error) {
+	attributes, err := parseAttributes(list)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, attribute := range attributes {
+		if attribute.Key == "PART-TARGET" {
+			return parseSeconds(attribute.Value)
+		}
+	}
+
+	return 0, ErrInvalidAttributes
+}
+
+// parseServerControl parses the attribute list of an EXT-X-SERVER-CONTROL tag.
+func parseServerControl(list string) (ServerControl, error) {
+	attributes, err := parseAttributes(list)
+	if err != nil {
+		return ServerControl{}, err
+	}
+
+	var control ServerControl
+	for _, attribute := range attributes {
+		switch attribute.Key {
+		case "CAN-SKIP-UNTIL":
+			control.CanSkipUntil, err = parseSeconds(attribute.Value)
+		case "CAN-SKIP-DATERANGES":
+			control.CanSkipDateRanges, err = yes(attribute)
+		case "HOLD-BACK":
+			control.HoldBack, err = parseSeconds(attribute.Value)
+		case "PART-HOLD-BACK":
+			control.PartHoldBack, err = parseSeconds(attribute.Value)
+		case "CAN-BLOCK-RELOAD":
+			control.CanBlockReload, err = yes(attribute)
+		}
+
+		if err != nil {
+			return ServerControl{}, err
+		}
+	}
+
+	return control, nil
+}
+
+// String returns the delivery directives as an EXT-X-SERVER-CONTROL tag.
+func (c *ServerControl) String() string {
+	var attributes []Attribute
+	if c.CanSkipUntil > 0 {
+		attributes = append(attributes, Attribute{Key: "CAN-SKIP-UNTIL", Value: formatSeconds(c.CanSkipUntil)})
+	}
+
+	if c.CanSkipDateRanges {
+		attributes = append(attributes, Attribute{Key: "CAN-SKIP-DATERANGES", Value: "YES"})
+	}
+
+	if c.HoldBack > 0 {
+		attributes = append(attributes, Attribute{Key: "HOLD-BACK", Value: formatSeconds(c.HoldBack)})
+	}
+
+	if c.PartHoldBack > 0 {
+		attributes = append(attributes, Attribute{Key: "PART-HOLD-BACK", Value: formatSeconds(c.PartHoldBack)})
+	}
+
+	if c.CanBlockReload {
+		attributes = append(attributes, Attribute{Key: "CAN-BLOCK-RELOAD", Value: "YES"})
+	}
+
+	return ServerControlField + ":" + formatAttributes(attributes)
+}
+
+// parsePreloadHint parses the attribute list of an EXT-X-PRELOAD-HINT tag.
+func parsePreloadHint(list string) (PreloadHint, error) {
+	attributes, err := parseAttributes(list)
+	if err != nil {
+		return PreloadHint{}, err
+	}
+
+	var hint PreloadHint
+	for _, attribute := range attributes {
+		switch attribute.Key {
+		case "TYPE":
+			var value string
+			value, err = enumerated(attribute)
+			hint.Type = HintType(value)
+		case "URI":
+			hint.URI = attribute.Value
+		case "BYTERANGE-START":
+			hint.ByteRangeStart, err = strconv.ParseUint(attribute.Value, 10, 64)
+		case "BYTERANGE-LENGTH":
+			hint.ByteRangeLength, err = strconv.ParseUint(attribute.Value, 10, 64)
+		}
+
+		if err != nil {
+			return PreloadHint{}, err
+		}
+	}
+
+	if (hint.Type != HintPart && hint.Type != HintMap) || hint.URI == "" {
+		return PreloadHint{}, ErrInvalidAttributes
+	}
+
+	return hint, nil
+}
+
+// String returns the preload hint as an EXT-X-PRELOAD-HINT tag.
+func (h *PreloadHint) String() string {
+	attributes := []Attribute{{Key: "TYPE", Value: string(h.Type)}, {Key: "URI", Value: h.URI, Quoted: true}}
+	if h.ByteRangeStart > 0 {
+		attributes = append(attributes, Attribute{Key: "BYTERANGE-START", Value: strconv.FormatUint(h.ByteRangeStart, 10)})
+	}
+
+	if h.ByteRangeLength > 0 {
+		attributes = append(attributes, Attribute{Key: "BYTERANGE-LENGTH", Value: strconv.FormatUint(h.ByteRangeLength, 10)})
+	}
+
+	return PreloadHintField + ":" + formatAttributes(attributes)
+}
+
+// parseSkip parses the attribute list of an EXT-X-SKIP tag, returning the number of segments skipped.
+func parseSkip(list string) (uint32, error) {
+	attributes, err := parseAttributes(list)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, attribute := range attributes {
+		if attribute.Key == "SKIPPED-SEGMENTS" {
+			skipped, err := strconv.ParseUint(attribute.Value, 10, 32)
+			return uint32(skipped), err
+		}
+	}
+
+	return 0, ErrInvalidAttributes
+}
@@ -0,0 +1,144 @@
+package hls
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// lowLatencyManifest is a Low-Latency HLS delta update, with the parts of its last segments and of the segment being produced
+const lowLatencyManifest = `#EXTM3U
+#EXT-X-VERSION:9
+#EXT-X-TARGETDURATION:4
+#EXT-X-MEDIA-SEQUENCE:100
+#EXT-X-DISCONTINUITY-SEQUENCE:0
+#EXT-X-SERVER-CONTROL:CAN-SKIP-UNTIL=24,HOLD-BACK=12,PART-HOLD-BACK=3,CAN-BLOCK-RELOAD=YES
+#EXT-X-PART-INF:PART-TARGET=1
+#EXT-X-SKIP:SKIPPED-SEGMENTS=6
+#EXTINF:4,
+106.ts
+#EXT-X-PART:DURATION=1,URI="107.part0.ts",INDEPENDENT=YES
+#EXT-X-PART:DURATION=1,URI="107.part1.ts"
+#EXT-X-PART:DURATION=1,URI="107.part2.ts",INDEPENDENT=YES
+#EXT-X-PART:DURATION=1,URI="107.part3.ts"
+#EXTINF:4,
+107.ts
+#EXT-X-PART:DURATION=0.5,URI="108.mp4",BYTERANGE="1000@0"
+#EXT-X-PART:DURATION=0.5,URI="108.mp4",BYTERANGE="1000",GAP=YES
+#EXT-X-PRELOAD-HINT:TYPE=PART,URI="108.mp4",BYTERANGE-START=2000
+`
+
+func TestParseLowLatency(t *testing.T) {
+	manifest, err := ParseHlsManifest(lowLatencyManifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := (ServerControl{CanSkipUntil: 24, HoldBack: 12, PartHoldBack: 3, CanBlockReload: true}); manifest.ServerControl != expected {
+		t.Errorf("expected the server control %+v, got %+v", expected, manifest.ServerControl)
+	}
+
+	if manifest.PartTarget != 1 || manifest.SkippedSegments != 6 {
+		t.Errorf("expected a part target of 1 second and 6 skipped segments, got %v and %d", manifest.PartTarget, manifest.SkippedSegments)
+	}
+
+	segments := manifest.SegmentGroups[0].Segments
+	if len(segments[0].Parts) != 0 || len(segments[1].Parts) != 4 || !segments[1].Parts[2].Independent || segments[1].Parts[3].URI != "107.part3.ts" {
+		t.Errorf("expected the parts of the second segment, got %+v", segments[1].Parts)
+	}
+
+	expected := []Part{{URI: "108.mp4", Duration: 0.5, ByteRange: ByteRange{1000, 0}}, {URI: "108.mp4", Duration: 0.5, Gap: true, ByteRange: ByteRange{1000, 1000}}}
+	if len(manifest.Parts) != 2 || manifest.Parts[0] != expected[0] || manifest.Parts[1] != expected[1] {
+		t.Errorf("expected the parts of the segment being produced %+v, got %+v", expected, manifest.Parts)
+	}
+
+	if len(manifest.PreloadHints) != 1 || manifest.PreloadHints[0] != (PreloadHint{Type: HintPart, URI: "108.mp4", ByteRangeStart: 2000}) {
+		t.Errorf("expected the preload hint, got %+v", manifest.PreloadHints)
+	}
+
+	if output := manifest.String(); output != lowLatencyManifest {
+		t.Errorf("expected the manifest to be written as parsed, got %s", output)
+	}
+
+	if issues := manifest.Validate(); len(issues) != 1 || !strings.Contains(issues[0].Message, "shorter than three target durations") {
+		t.Errorf("expected only the short live playlist issue, got %+v", issues)
+	}
+}
+
+func TestParseLowLatencyErrors(t *testing.T) {
+	for line, expected := range map[string]error{
+		`#EXT-X-PART:URI="a.ts"`:                                        ErrInvalidAttributes,
+		`#EXT-X-PART:DURATION=1`:                                        ErrInvalidAttributes,
+		`#EXT-X-PART:DURATION=-1,URI="a.ts"`:                            ErrInvalidDuration,
+		`#EXT-X-PART:DURATION=1,URI="a.ts",INDEPENDENT="YES"`:           ErrInvalidAttributes,
+		`#EXT-X-PART:DURATION=1,URI="a.ts",BYTERANGE="100"`:             ErrInvalidByteRange,
+		`#EXT-X-PART-INF:PART-TARGET=NaN`:                               ErrInvalidDuration,
+		`#EXT-X-PART-INF:`:                                              ErrInvalidAttributes,
+		`#EXT-X-SERVER-CONTROL:CAN-BLOCK-RELOAD="YES"`:                  ErrInvalidAttributes,
+		`#EXT-X-PRELOAD-HINT:TYPE=SEGMENT,URI="a.ts"`:                   ErrInvalidAttributes,
+		`#EXT-X-PRELOAD-HINT:TYPE=PART`:                                 ErrInvalidAttributes,
+		`#EXT-X-SKIP:RECENTLY-REMOVED-DATERANGES="a"`:                   ErrInvalidAttributes,
+		`#EXT-X-PRELOAD-HINT:TYPE=MAP,URI="init.mp4",BYTERANGE-START=a`: strconv.ErrSyntax,
+	} {
+		_, err := ParseHlsManifest("#EXTM3U\n" + line)
+		var parseErr *ParseError
+		if !errors.As(err, &parseErr) || parseErr.Field != line[:strings.IndexByte(line, ':')] || !errors.Is(err, expected) {
+			t.Errorf("expected %v parsing %s, got %v", expected, line, err)
+		}
+	}
+}
+
+func TestValidateLowLatency(t *testing.T) {
+	manifest, err := ParseHlsManifest(lowLatencyManifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	manifest.Version = 6
+	manifest.PartTarget = 0.75
+	manifest.ServerControl = ServerControl{}
+
+	var messages []string
+	for _, issue := range manifest.Validate() {
+		messages = append(messages, issue.Message)
+	}
+
+	joined := strings.Join(messages, "\n")
+	for _, expected := range []string{"partial segment duration 1s exceeds the part target of 0.75s", "part hold back lower than twice the part target", "skipped segments without the CAN-SKIP-UNTIL attribute", "required by the EXT-X-SKIP tag"} {
+		if !strings.Contains(joined, expected) {
+			t.Errorf("expected the issue %q, got %s", expected, joined)
+		}
+	}
+
+	manifest.PartTarget = 0
+	if issues := manifest.Validate(); issues[0].Segment != 1 || issues[0].Message != "partial segments without the EXT-X-PART-INF tag" {
+		t.Errorf("expected the parts of the second segment to miss their target, got %+v", issues[0])
+	}
+}
+
+func TestSliceLowLatency(t *testing.T) {
+	manifest, err := ParseHlsManifest(lowLatencyManifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clip, err := manifest.SliceByTime(0, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if clip.MediaSequence != 106 || clip.SkippedSegments != 0 || clip.Parts != nil || clip.PreloadHints != nil {
+		t.Errorf("expected the clip to start at segment 106 without the live edge, got %+v", clip)
+	}
+
+	edge, err := manifest.SliceByTime(4, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	edge.Parts[0].URI = "changed.mp4"
+	if edge.MediaSequence != 107 || len(edge.PreloadHints) != 1 || manifest.Parts[0].URI != "108.mp4" {
+		t.Errorf("expected a copy of the live edge, got %+v", edge)
+	}
+}
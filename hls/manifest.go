@@ -1,6 +1,9 @@
 package hls
 
-import "math"
+import (
+	"math"
+	"time"
+)
 
 // Manifest represents a HLS manifest.
 type Manifest struct {
@@ -10,6 +13,12 @@ type Manifest struct {
 	DiscontinuitySequence uint32         // Discontinuity sequence number
 	HasEndList            bool           // Indicates if the manifest has the #EXT-X-ENDLIST tag
 	SegmentGroups         []SegmentGroup // List of segment groups
+	UnknownTags           []string       // Unknown tags kept by ParseOptions.KeepUnknownTags applying to the playlist, like EXT-X-PLAYLIST-TYPE, written after the header, or following the last segment, written after it
+	ServerControl         ServerControl  // Delivery directives of a Low-Latency HLS playlist, zero when it has no EXT-X-SERVER-CONTROL tag
+	PartTarget            float32        // Target duration of the partial segments, zero when the playlist has none
+	SkippedSegments       uint32         // Segments skipped by a playlist delta update, replaced by an EXT-X-SKIP tag ahead of the first segment
+	Parts                 []Part         // Partial segments of the segment being produced, written after the last segment
+	PreloadHints          []PreloadHint  // Resources the players can request before they are available, written after the last segment
 }
 
 // SegmentCount returns the number of segments in the manifest, summing all segments from all segment groups.
@@ -80,10 +89,16 @@ func (m *Manifest) MaxDuration() float32 {
 	return maxDuration
 }
 
-// SegmentGroup represents a group of segments in a HLS manifest, usually separated by the #EXT-DISCONTINUITY tag.
+// SegmentGroup represents a group of segments in a HLS manifest, usually separated by the #EXT-DISCONTINUITY tag, or starting where the initialization section changes.
 type SegmentGroup struct {
 	// List of segments in the group
 	Segments []Segment
+
+	// Media initialization section of the segments, like the init segment of fragmented MP4, empty when they need none
+	Map Map
+
+	// Continuous indicates that the group continues the previous one without a discontinuity, only its initialization section changing, ignored for the first group
+	Continuous bool
 }
 
 // Duration returns the total duration of the group, summing all durations from all segments.
@@ -140,12 +155,30 @@ func (g *SegmentGroup) MaxDuration() float32 {
 
 // Segment represents a segment in a HLS manifest.
 type Segment struct {
-	Path     string  // Path to the segment
-	Duration float32 // Duration of the segment
-	Title    string  // Title of the segment
+	Path      string    // Path to the segment
+	Duration  float32   // Duration of the segment
+	Title     string    // Title of the segment
+	Keys      []Key     // Keys encrypting the segment, one per key format, empty when the segment is clear
+	ByteRange ByteRange // Sub-range of the resource at Path, zero when the segment is the whole resource
+	Gap       bool      // Indicates if the segment is unavailable, the players skipping it
+
+	// Unknown tags kept by ParseOptions.KeepUnknownTags ahead of the segment, like EXT-X-PROGRAM-DATE-TIME, written before its EXTINF tag
+	UnknownTags []string
+
+	// Partial segments of a Low-Latency HLS playlist making up the segment, written before its EXTINF tag
+	Parts []Part
 }
 
 // TargetDuration returns the target duration of the segment, which is the duration rounded to the nearest integer.
 func (s *Segment) TargetDuration() uint8 {
 	return uint8(math.Round(float64(s.Duration)))
 }
+
+// SegmentDuration returns the target duration of the segments cut by a packager, or the fallback when it is not positive.
+func SegmentDuration(target time.Duration, fallback time.Duration) time.Duration {
+	if target <= 0 {
+		return fallback
+	}
+
+	return target
+}
@@ -0,0 +1,60 @@
+package hls
+
+// MapField is the field that indicates the media initialization section of the next segments, like the init segment of fragmented MP4.
+const MapField = "#EXT-X-MAP"
+
+// Map represents the media initialization section of the segments of a group, as declared by an EXT-X-MAP tag.
+type Map struct {
+	URI       string    // URI of the initialization section, empty when the segments need none, like MPEG-TS segments
+	ByteRange ByteRange // Sub-range of the resource at URI, zero for the whole resource
+}
+
+// parseMap parses the attribute list of an EXT-X-MAP tag.
+func parseMap(list string) (Map, error) {
+	attributes, err := parseAttributes(list)
+	if err != nil {
+		return Map{}, err
+	}
+
+	var m Map
+	for _, attribute := range attributes {
+		switch attribute.Key {
+		case "URI":
+			m.URI = attribute.Value
+		case "BYTERANGE":
+			// The offset of the initialization section defaults to the start of the resource.
+			byteRange, _, err := parseByteRange(attribute.Value)
+			if err != nil || !attribute.Quoted {
+				return Map{}, ErrInvalidAttributes
+			}
+
+			m.ByteRange = byteRange
+		}
+	}
+
+	if m.URI == "" {
+		return Map{}, ErrInvalidAttributes
+	}
+
+	return m, nil
+}
+
+// String returns the initialization section as an EXT-X-MAP tag.
+func (m *Map) String() string {
+	attributes := []Attribute{{Key: "URI", Value: m.URI, Quoted: true}}
+	if m.ByteRange.Length > 0 {
+		attributes = append(attributes, Attribute{Key: "BYTERANGE", Value: m.ByteRange.String(), Quoted: true})
+	}
+
+	return MapField + ":" + formatAttributes(attributes)
+}
+
+// writeMap writes the EXT-X-MAP tag of a group when its initialization section differs from the one of the previous group, which applies until replaced.
+func writeMap(w manifestWriter, previous Map, m Map) {
+	if m.URI == "" || m == previous {
+		return
+	}
+
+	w.WriteString(m.String())
+	w.WriteByte('\n')
+}
@@ -0,0 +1,82 @@
+package hls
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// fmp4Manifest is a fragmented MP4 VOD switching its initialization section after a discontinuity
+const fmp4Manifest = `#EXTM3U
+#EXT-X-VERSION:6
+#EXT-X-TARGETDURATION:4
+#EXT-X-MEDIA-SEQUENCE:0
+#EXT-X-MAP:URI="main/init.mp4"
+#EXTINF:4,
+main/0.m4s
+#EXTINF:4,
+main/1.m4s
+#EXT-DISCONTINUITY
+#EXT-X-MAP:URI="single.mp4",BYTERANGE="720@0"
+#EXTINF:4,
+#EXT-X-BYTERANGE:1000@720
+single.mp4
+#EXT-DISCONTINUITY
+#EXTINF:4,
+#EXT-X-BYTERANGE:1000
+single.mp4
+#EXT-X-ENDLIST`
+
+func TestParseMap(t *testing.T) {
+	manifest, err := ParseHlsManifest(fmp4Manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Map{{URI: "main/init.mp4"}, {URI: "single.mp4", ByteRange: ByteRange{720, 0}}, {URI: "single.mp4", ByteRange: ByteRange{720, 0}}}
+	for i, group := range manifest.SegmentGroups {
+		if group.Map != expected[i] {
+			t.Errorf("expected the initialization section of group %d to be %+v, got %+v", i, expected[i], group.Map)
+		}
+	}
+
+	output := manifest.String()
+	if strings.Count(output, MapField) != 2 || !strings.Contains(output, `#EXT-X-MAP:URI="single.mp4",BYTERANGE="720@0"`) {
+		t.Errorf("expected a map tag where the initialization section changes, got %s", output)
+	}
+
+	reparsed, err := ParseHlsManifest(output)
+	if err != nil || reparsed.String() != output {
+		t.Errorf("expected the manifest to round-trip, got %v", err)
+	}
+
+	if issues := manifest.Validate(); len(issues) != 0 {
+		t.Errorf("expected no issues, got %+v", issues)
+	}
+}
+
+func TestParseMapErrors(t *testing.T) {
+	for data, expected := range map[string]error{
+		"#EXTM3U\n#EXT-X-MAP:BYTERANGE=\"10@0\"\n#EXTINF:4,\na.m4s":              ErrInvalidAttributes,
+		"#EXTM3U\n#EXT-X-MAP:URI=\"init.mp4\",BYTERANGE=10@0\n#EXTINF:4,\na.m4s": ErrInvalidAttributes,
+	} {
+		if _, err := ParseHlsManifest(data); !errors.Is(err, expected) {
+			t.Errorf("expected %v parsing %q, got %v", expected, data, err)
+		}
+	}
+
+	// A new initialization section without a discontinuity starts a group continuing the previous one.
+	manifest, err := ParseHlsManifest("#EXTM3U\n#EXT-X-MEDIA-SEQUENCE:10\n#EXT-X-MAP:URI=\"a.mp4\"\n#EXTINF:4,\na.m4s\n#EXT-X-MAP:URI=\"b.mp4\"\n#EXTINF:4,\nb.m4s\n#EXTINF:4,\nc.m4s")
+	if err != nil || len(manifest.SegmentGroups) != 2 || !manifest.SegmentGroups[1].Continuous || manifest.SegmentGroups[1].Map.URI != "b.mp4" {
+		t.Fatalf("expected the map change to start a continuous group, got %+v and %v", manifest.SegmentGroups, err)
+	}
+
+	if manifest.RemoveFromStart(1); manifest.DiscontinuitySequence != 0 || manifest.MediaSequence != 11 {
+		t.Errorf("expected no discontinuity to be removed with the first group, got %d", manifest.DiscontinuitySequence)
+	}
+
+	// A new initialization section may be declared before the discontinuity it follows.
+	if _, err := ParseHlsManifest("#EXTM3U\n#EXT-X-MAP:URI=\"a.mp4\"\n#EXTINF:4,\na.m4s\n#EXT-X-MAP:URI=\"b.mp4\"\n#EXT-DISCONTINUITY\n#EXTINF:4,\nb.m4s"); err != nil {
+		t.Errorf("expected the map before the discontinuity to be accepted, got %v", err)
+	}
+}
@@ -0,0 +1,415 @@
+package hls
+
+import (
+	"strconv"
+	"strings"
+)
+
+const (
+	// StreamInfField is the field that indicates a variant in a master manifest, followed by the URI of its media manifest.
+	StreamInfField = "#EXT-X-STREAM-INF"
+
+	// IFrameStreamInfField is the field that indicates a variant of a master manifest made of the I-frames of the video, used by the players to seek and scrub.
+	IFrameStreamInfField = "#EXT-X-I-FRAME-STREAM-INF"
+
+	// IndependentSegmentsField is the field that indicates that every segment of the media manifests can be decoded without the previous ones.
+	IndependentSegmentsField = "#EXT-X-INDEPENDENT-SEGMENTS"
+
+	// VideoLayoutAttribute is the variant attribute listing the video layout specifiers a client must support, like "CH-STEREO,PROJ-EQUI".
+	VideoLayoutAttribute = "REQ-VIDEO-LAYOUT"
+
+	// ProjectionAttribute is the vendor variant attribute carrying the projection of the video, including the projections REQ-VIDEO-LAYOUT cannot express.
+	ProjectionAttribute = "X-VRMIX-PROJECTION"
+
+	// StereoAttribute is the vendor variant attribute carrying the stereo layout of the video, including the frame packings REQ-VIDEO-LAYOUT cannot express.
+	StereoAttribute = "X-VRMIX-STEREO"
+
+	// VideoRangeAttribute is the variant attribute indicating the dynamic range of the video, like "SDR" or "PQ".
+	VideoRangeAttribute = "VIDEO-RANGE"
+
+	// projectionSpecifierPrefix is the prefix of the projection specifiers of REQ-VIDEO-LAYOUT.
+	projectionSpecifierPrefix = "PROJ-"
+
+	// channelSpecifierPrefix is the prefix of the channel specifiers of REQ-VIDEO-LAYOUT.
+	channelSpecifierPrefix = "CH-"
+)
+
+// Projection represents how the frames of a video are mapped onto the sphere around the viewer.
+type Projection string
+
+const (
+	// ProjectionRectilinear is a flat video, rendered on a screen.
+	ProjectionRectilinear Projection = "rectilinear"
+
+	// ProjectionEquirectangular is a 360 degrees video mapped with longitude and latitude.
+	ProjectionEquirectangular Projection = "equirectangular"
+
+	// ProjectionHalfEquirectangular is a 180 degrees video mapped with longitude and latitude.
+	ProjectionHalfEquirectangular Projection = "half-equirectangular"
+
+	// ProjectionCubemap is a 360 degrees video mapped onto the six faces of a cube.
+	ProjectionCubemap Projection = "cubemap"
+
+	// ProjectionEquiAngularCubemap is a 360 degrees video mapped onto the six faces of a cube with an equal angle per pixel, as streamed by YouTube.
+	ProjectionEquiAngularCubemap Projection = "equi-angular-cubemap"
+
+	// ProjectionFisheye is a video captured through a fisheye lens.
+	ProjectionFisheye Projection = "fisheye"
+)
+
+// projectionSpecifiers maps the projections to the specifiers of REQ-VIDEO-LAYOUT.
+var projectionSpecifiers = map[Projection]string{
+	ProjectionRectilinear:         "PROJ-RECT",
+	ProjectionEquirectangular:     "PROJ-EQUI",
+	ProjectionHalfEquirectangular: "PROJ-HEQU",
+	ProjectionFisheye:             "PROJ-PRIM",
+}
+
+// Specifier returns the REQ-VIDEO-LAYOUT specifier of the projection, or an empty string when it has none.
+func (p Projection) Specifier() string {
+	return projectionSpecifiers[p]
+}
+
+// projectionFromSpecifier returns the projection of a REQ-VIDEO-LAYOUT specifier, or an empty projection when it is unknown.
+func projectionFromSpecifier(specifier string) Projection {
+	for projection, s := range projectionSpecifiers {
+		if s == specifier {
+			return projection
+		}
+	}
+
+	return ""
+}
+
+// StereoLayout represents how the views of each eye are carried in a video.
+type StereoLayout string
+
+const (
+	// StereoMono is a single view shown to both eyes.
+	StereoMono StereoLayout = "mono"
+
+	// StereoSideBySide packs the left view on the left half of each frame and the right view on the right half.
+	StereoSideBySide StereoLayout = "side-by-side"
+
+	// StereoTopBottom packs the left view on the top half of each frame and the right view on the bottom half.
+	StereoTopBottom StereoLayout = "top-bottom"
+
+	// StereoMultiview carries each view as a layer of a MV-HEVC stream, as played by visionOS.
+	StereoMultiview StereoLayout = "mv-hevc"
+)
+
+// stereoSpecifiers maps the stereo layouts to the channel specifiers of REQ-VIDEO-LAYOUT.
+var stereoSpecifiers = map[StereoLayout]string{
+	StereoMono:      "CH-MONO",
+	StereoMultiview: "CH-STEREO",
+}
+
+// Specifier returns the REQ-VIDEO-LAYOUT specifier of the stereo layout, or an empty string when it has none.
+func (s StereoLayout) Specifier() string {
+	return stereoSpecifiers[s]
+}
+
+// stereoFromSpecifier returns the stereo layout of a REQ-VIDEO-LAYOUT specifier, or an empty layout when it is unknown.
+func stereoFromSpecifier(specifier string) StereoLayout {
+	for stereo, s := range stereoSpecifiers {
+		if s == specifier {
+			return stereo
+		}
+	}
+
+	return ""
+}
+
+// Layout represents how a video is rendered in a headset, each empty field being unknown.
+type Layout struct {
+	Projection Projection   // Projection of the video
+	Stereo     StereoLayout // Stereo layout of the video
+	Resolution string       // Resolution of the packed frames, like "7680x3840"
+}
+
+// Compatible returns true if the videos can follow each other in a stream without the player changing how it renders them, unknown fields being compatible with anything.
+func (l Layout) Compatible(other Layout) bool {
+	return l.Check(other) == nil
+}
+
+// Variant represents a variant of a master manifest.
+type Variant struct {
+	URI              string       // URI of the media manifest of the variant
+	Bandwidth        int          // Peak bits per second of the variant
+	AverageBandwidth int          // Average bits per second of the variant, zero if unknown
+	Resolution       string       // Resolution of the video, like "1920x1080", empty if unknown
+	Codecs           string       // Codecs of the variant, like "avc1.640028,mp4a.40.2", empty if unknown
+	VideoRange       string       // Dynamic range of the video, like "SDR", "HLG" or "PQ", empty if unknown
+	Projection       Projection   // Projection of the video, empty if unknown
+	Stereo           StereoLayout // Stereo layout of the video, empty if unknown
+	VideoLayout      []string     // Specifiers of REQ-VIDEO-LAYOUT other than the projection and the channels
+	Audio            string       // Group of the audio renditions played with the variant, empty when the audio is muxed
+	Subtitles        string       // Group of the subtitles renditions offered with the variant, empty if none
+	Attributes       []Attribute  // Other attributes of the variant, kept in order
+}
+
+// MasterManifest represents a HLS master manifest, listing the variants of a media.
+type MasterManifest struct {
+	Version             uint8       // Version of the manifest, zero to omit it
+	IndependentSegments bool        // Indicates if the manifest has the #EXT-X-INDEPENDENT-SEGMENTS tag
+	Renditions          []Rendition // List of alternative renditions, like the audio tracks
+	Variants            []Variant   // List of variants
+	IFrameVariants      []Variant   // List of I-frame variants, whose URI is the one of their I-frame media manifest
+
+	// SessionKeys lists the keys of the media playlists declared ahead, one per key format and key, so players acquire the DRM licenses before loading the variants.
+	SessionKeys []Key
+}
+
+// parseVariant parses the attributes of a variant or I-frame variant, whose tag is the field.
+func parseVariant(field string, list string, lineNumber int) (Variant, error) {
+	attributes, err := parseAttributes(list)
+	if err != nil {
+		return Variant{}, fieldError(field, lineNumber, err)
+	}
+
+	variant := Variant{}
+	for _, attribute := range attributes {
+		switch attribute.Key {
+		case "BANDWIDTH":
+			if variant.Bandwidth, err = strconv.Atoi(attribute.Value); err != nil {
+				return Variant{}, fieldError(field, lineNumber, err)
+			}
+		case "AVERAGE-BANDWIDTH":
+			if variant.AverageBandwidth, err = strconv.Atoi(attribute.Value); err != nil {
+				return Variant{}, fieldError(field, lineNumber, err)
+			}
+		case "URI":
+			if field == IFrameStreamInfField {
+				variant.URI = attribute.Value
+			} else {
+				variant.Attributes = append(variant.Attributes, attribute)
+			}
+		case "RESOLUTION":
+			if variant.Resolution, err = enumerated(attribute); err != nil {
+				return Variant{}, fieldError(field, lineNumber, err)
+			}
+		case "CODECS":
+			variant.Codecs = attribute.Value
+		case "AUDIO":
+			variant.Audio = attribute.Value
+		case "SUBTITLES":
+			variant.Subtitles = attribute.Value
+		case VideoRangeAttribute:
+			if variant.VideoRange, err = enumerated(attribute); err != nil {
+				return Variant{}, fieldError(field, lineNumber, err)
+			}
+		case VideoLayoutAttribute:
+			for specifier := range strings.SplitSeq(attribute.Value, ",") {
+				switch {
+				case strings.HasPrefix(specifier, projectionSpecifierPrefix):
+					if variant.Projection == "" {
+						variant.Projection = projectionFromSpecifier(specifier)
+					}
+				case strings.HasPrefix(specifier, channelSpecifierPrefix):
+					if variant.Stereo == "" {
+						variant.Stereo = stereoFromSpecifier(specifier)
+					}
+				default:
+					variant.VideoLayout = append(variant.VideoLayout, specifier)
+				}
+			}
+		case ProjectionAttribute:
+			variant.Projection = Projection(attribute.Value)
+		case StereoAttribute:
+			variant.Stereo = StereoLayout(attribute.Value)
+		default:
+			variant.Attributes = append(variant.Attributes, attribute)
+		}
+	}
+
+	return variant, nil
+}
+
+// ParseMasterManifest parses a HLS master manifest from a string, ignoring the tags it does not model.
+func ParseMasterManifest(data string) (MasterManifest, error) {
+	lines := strings.Split(strings.TrimRight(data, "\n"), "\n")
+	manifest := MasterManifest{}
+
+	if strings.TrimSpace(lines[0]) != DeclarationField {
+		return manifest, declarationError()
+	}
+
+	var pending *Variant
+	for i, line := range lines[1:] {
+		lineNumber := i + 2
+		line = strings.TrimSpace(line)
+
+		if line == IndependentSegmentsField {
+			manifest.IndependentSegments = true
+		} else if strings.HasPrefix(line, VersionField) {
+			version, err := parseUintValue(VersionField, line, lineNumber, 8)
+			if err != nil {
+				return manifest, err
+			}
+
+			manifest.Version = uint8(version)
+		} else if list, found := strings.CutPrefix(line, MediaField+":"); found {
+			rendition, err := parseRendition(list, lineNumber)
+			if err != nil {
+				return manifest, err
+			}
+
+			manifest.Renditions = append(manifest.Renditions, rendition)
+		} else if list, found := strings.CutPrefix(line, SessionKeyField+":"); found {
+			key, err := parseKey(list)
+			if err == nil && key.Method == MethodNone {
+				err = ErrInvalidAttributes
+			}
+
+			if err != nil {
+				return manifest, fieldError(SessionKeyField, lineNumber, err)
+			}
+
+			manifest.SessionKeys = append(manifest.SessionKeys, key)
+		} else if list, found := strings.CutPrefix(line, StreamInfField+":"); found {
+			if pending != nil {
+				return manifest, fieldError(StreamInfField, lineNumber, ErrSegmentPathMissing)
+			}
+
+			variant, err := parseVariant(StreamInfField, list, lineNumber)
+			if err != nil {
+				return manifest, err
+			}
+
+			pending = &variant
+		} else if list, found := strings.CutPrefix(line, IFrameStreamInfField+":"); found {
+			variant, err := parseVariant(IFrameStreamInfField, list, lineNumber)
+			if err != nil {
+				return manifest, err
+			}
+
+			if variant.URI == "" {
+				return manifest, fieldError(IFrameStreamInfField, lineNumber, ErrRequiredFieldMissing)
+			}
+
+			manifest.IFrameVariants = append(manifest.IFrameVariants, variant)
+		} else if line != "" && !strings.HasPrefix(line, "#") {
+			if pending == nil {
+				return manifest, invalidFieldError(line, lineNumber)
+			}
+
+			pending.URI = line
+			manifest.Variants = append(manifest.Variants, *pending)
+			pending = nil
+		}
+	}
+
+	if pending != nil {
+		return manifest, fieldError(StreamInfField, len(lines)+1, ErrSegmentPathMissing)
+	}
+
+	return manifest, nil
+}
+
+// Layout returns the projection, stereo layout and resolution of the variant.
+func (v *Variant) Layout() Layout {
+	return Layout{Projection: v.Projection, Stereo: v.Stereo, Resolution: v.Resolution}
+}
+
+// attributes returns the attributes of the variant, signaling the projection and stereo layout through REQ-VIDEO-LAYOUT when it can express them and through the vendor attributes as well.
+func (v *Variant) attributes() []Attribute {
+	attributes := []Attribute{{Key: "BANDWIDTH", Value: strconv.Itoa(v.Bandwidth)}}
+	if v.AverageBandwidth > 0 {
+		attributes = append(attributes, Attribute{Key: "AVERAGE-BANDWIDTH", Value: strconv.Itoa(v.AverageBandwidth)})
+	}
+
+	if v.Resolution != "" {
+		attributes = append(attributes, Attribute{Key: "RESOLUTION", Value: v.Resolution})
+	}
+
+	if v.Codecs != "" {
+		attributes = append(attributes, Attribute{Key: "CODECS", Value: v.Codecs, Quoted: true})
+	}
+
+	if v.Audio != "" {
+		attributes = append(attributes, Attribute{Key: "AUDIO", Value: v.Audio, Quoted: true})
+	}
+
+	if v.Subtitles != "" {
+		attributes = append(attributes, Attribute{Key: "SUBTITLES", Value: v.Subtitles, Quoted: true})
+	}
+
+	if v.VideoRange != "" {
+		attributes = append(attributes, Attribute{Key: VideoRangeAttribute, Value: v.VideoRange})
+	}
+
+	var layout []string
+	if specifier := v.Stereo.Specifier(); specifier != "" {
+		layout = append(layout, specifier)
+	}
+
+	layout = append(layout, v.VideoLayout...)
+	if specifier := v.Projection.Specifier(); specifier != "" {
+		layout = append(layout, specifier)
+	}
+
+	if len(layout) > 0 {
+		attributes = append(attributes, Attribute{Key: VideoLayoutAttribute, Value: strings.Join(layout, ","), Quoted: true})
+	}
+
+	if v.Projection != "" {
+		attributes = append(attributes, Attribute{Key: ProjectionAttribute, Value: string(v.Projection), Quoted: true})
+	}
+
+	if v.Stereo != "" {
+		attributes = append(attributes, Attribute{Key: StereoAttribute, Value: string(v.Stereo), Quoted: true})
+	}
+
+	return append(attributes, v.Attributes...)
+}
+
+// String returns the master manifest as a string.
+func (m *MasterManifest) String() string {
+	var builder strings.Builder
+
+	builder.WriteString(DeclarationField + "\n")
+	if m.Version > 0 {
+		builder.WriteString(VersionField + ":" + strconv.FormatUint(uint64(m.Version), 10) + "\n")
+	}
+
+	if m.IndependentSegments {
+		builder.WriteString(IndependentSegmentsField + "\n")
+	}
+
+	for _, key := range m.SessionKeys {
+		builder.WriteString(SessionKeyField + strings.TrimPrefix(key.String(), KeyField) + "\n")
+	}
+
+	for _, rendition := range m.Renditions {
+		builder.WriteString(MediaField + ":" + formatAttributes(rendition.attributes()) + "\n")
+	}
+
+	for _, variant := range m.Variants {
+		builder.WriteString(StreamInfField + ":" + formatAttributes(variant.attributes()) + "\n")
+		builder.WriteString(variant.URI + "\n")
+	}
+
+	for _, variant := range m.IFrameVariants {
+		attributes := append(variant.attributes(), Attribute{Key: "URI", Value: variant.URI, Quoted: true})
+		builder.WriteString(IFrameStreamInfField + ":" + formatAttributes(attributes) + "\n")
+	}
+
+	return builder.String()
+}
+
+// RewriteURIs replaces the URIs of the media manifests of the variants, I-frame variants and renditions by the ones returned by rewrite, like to serve them from another origin.
+func (m *MasterManifest) RewriteURIs(rewrite func(uri string) string) {
+	for i := range m.Variants {
+		m.Variants[i].URI = rewrite(m.Variants[i].URI)
+	}
+
+	for i := range m.IFrameVariants {
+		m.IFrameVariants[i].URI = rewrite(m.IFrameVariants[i].URI)
+	}
+
+	for i := range m.Renditions {
+		if m.Renditions[i].URI != "" {
+			m.Renditions[i].URI = rewrite(m.Renditions[i].URI)
+		}
+	}
+}
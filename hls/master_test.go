@@ -0,0 +1,192 @@
+package hls
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestMasterManifest(t *testing.T) {
+	data, err := os.ReadFile("../testdata/master.m3u8")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := ParseMasterManifest(string(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if m.Version != 12 {
+		t.Errorf("expected version 12, got %d", m.Version)
+	}
+
+	projections := []Projection{ProjectionEquirectangular, ProjectionHalfEquirectangular, ProjectionCubemap, ""}
+	if len(m.Variants) != len(projections) {
+		t.Fatalf("expected %d variants, got %d", len(projections), len(m.Variants))
+	}
+
+	for i, variant := range m.Variants {
+		if variant.Projection != projections[i] {
+			t.Errorf("expected projection %q for %s, got %q", projections[i], variant.URI, variant.Projection)
+		}
+	}
+
+	vr180 := m.Variants[1]
+	if vr180.Bandwidth != 12000000 || vr180.Codecs != "hvc1.2.4.L153.B0" || vr180.VideoRange != "PQ" || vr180.Stereo != StereoMultiview || len(vr180.VideoLayout) != 0 {
+		t.Errorf("expected the attributes of the VR180 variant, got %+v", vr180)
+	}
+
+	if m.Variants[2].Stereo != StereoTopBottom {
+		t.Errorf("expected the frame packing of the cubemap variant, got %q", m.Variants[2].Stereo)
+	}
+
+	if len(vr180.Attributes) != 1 || vr180.Attributes[0].String() != "FRAME-RATE=60.000" {
+		t.Errorf("expected the frame rate to be kept, got %v", vr180.Attributes)
+	}
+
+	other, err := ParseMasterManifest(m.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if other.String() != m.String() {
+		t.Errorf("expected manifest to be the same, got different")
+	}
+}
+
+func TestVariantProjectionSignaling(t *testing.T) {
+	m := MasterManifest{Variants: []Variant{
+		{URI: "equirect.m3u8", Bandwidth: 1000, Projection: ProjectionEquirectangular, Stereo: StereoMultiview},
+		{URI: "cubemap.m3u8", Bandwidth: 1000, Projection: ProjectionCubemap},
+	}}
+
+	expected := "#EXTM3U\n" +
+		"#EXT-X-STREAM-INF:BANDWIDTH=1000,REQ-VIDEO-LAYOUT=\"CH-STEREO,PROJ-EQUI\",X-VRMIX-PROJECTION=\"equirectangular\",X-VRMIX-STEREO=\"mv-hevc\"\nequirect.m3u8\n" +
+		"#EXT-X-STREAM-INF:BANDWIDTH=1000,X-VRMIX-PROJECTION=\"cubemap\"\ncubemap.m3u8\n"
+
+	if m.String() != expected {
+		t.Errorf("expected %q, got %q", expected, m.String())
+	}
+}
+
+func TestLayoutCompatible(t *testing.T) {
+	cases := []struct {
+		a, b       Layout
+		compatible bool
+	}{
+		{Layout{}, Layout{Projection: ProjectionEquirectangular, Stereo: StereoTopBottom}, true},
+		{Layout{Projection: ProjectionEquirectangular}, Layout{Projection: ProjectionEquirectangular, Stereo: StereoMono}, true},
+		{Layout{Projection: ProjectionEquirectangular}, Layout{Projection: ProjectionRectilinear}, false},
+		{Layout{Stereo: StereoSideBySide}, Layout{Stereo: StereoMultiview}, false},
+		{Layout{Stereo: StereoTopBottom, Resolution: "4096x4096"}, Layout{Stereo: StereoTopBottom, Resolution: "2048x2048"}, true},
+		{Layout{Stereo: StereoTopBottom, Resolution: "4096x4096"}, Layout{Stereo: StereoTopBottom, Resolution: "4096x2048"}, false},
+	}
+
+	for _, c := range cases {
+		if c.a.Compatible(c.b) != c.compatible || c.b.Compatible(c.a) != c.compatible {
+			t.Errorf("expected %+v and %+v compatible %t", c.a, c.b, c.compatible)
+		}
+	}
+}
+
+func TestMasterManifestErrors(t *testing.T) {
+	cases := []struct {
+		data string
+		err  error
+	}{
+		{"#EXT-X-VERSION:3\n", ErrRequiredFieldMissing},
+		{"#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=1\n", ErrSegmentPathMissing},
+		{"#EXTM3U\n#EXT-X-STREAM-INF:CODECS=\"avc1\n", ErrInvalidAttributes},
+		{"#EXTM3U\nstray.m3u8\n", ErrInvalidField},
+		{"#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=1,RESOLUTION=\"1920x1080\"\nvideo.m3u8\n", ErrInvalidAttributes},
+		{"#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=1 ,RESOLUTION=1920x1080\nvideo.m3u8\n", ErrInvalidAttributes},
+		{"", ErrRequiredFieldMissing},
+	}
+
+	for _, c := range cases {
+		if _, err := ParseMasterManifest(c.data); !errors.Is(err, c.err) {
+			t.Errorf("expected %v for %q, got %v", c.err, c.data, err)
+		}
+	}
+}
+
+func FuzzParseMasterManifest(f *testing.F) {
+	data, err := os.ReadFile("../testdata/master.m3u8")
+	if err != nil {
+		f.Fatal(err)
+	}
+
+	f.Add(string(data))
+	f.Add("")
+	f.Add("#EXTM3U\r\n#EXT-X-SESSION-KEY:METHOD=SAMPLE-AES,URI=\"skd://key\"\r\n#EXT-X-STREAM-INF:BANDWIDTH=1,REQ-VIDEO-LAYOUT=\"CH-STEREO,PROJ-EQUI\"\r\nvideo.m3u8\r\n")
+
+	f.Fuzz(func(t *testing.T, data string) {
+		manifest, err := ParseMasterManifest(data)
+		if err != nil {
+			var parseErr *ParseError
+			if !errors.As(err, &parseErr) {
+				t.Fatalf("expected a ParseError, got %v", err)
+			}
+
+			return
+		}
+
+		// A parsed manifest must survive being written and parsed again unchanged.
+		written := manifest.String()
+		reparsed, err := ParseMasterManifest(written)
+		if err != nil {
+			t.Fatalf("expected the written manifest to parse, got %v parsing %q", err, written)
+		}
+
+		if rewritten := reparsed.String(); rewritten != written {
+			t.Fatalf("expected the manifest to be written the same, got %q and %q", written, rewritten)
+		}
+	})
+}
+
+func TestMasterManifestGroups(t *testing.T) {
+	data := `#EXTM3U
+#EXT-X-VERSION:6
+#EXT-X-INDEPENDENT-SEGMENTS
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="aac",NAME="English",LANGUAGE="en",DEFAULT=YES,AUTOSELECT=YES,URI="audio/en.m3u8"
+#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID="subs",NAME="English",LANGUAGE="en",DEFAULT=NO,AUTOSELECT=YES,URI="subs/en.m3u8"
+#EXT-X-STREAM-INF:BANDWIDTH=5000000,AVERAGE-BANDWIDTH=4000000,RESOLUTION=1920x1080,CODECS="avc1.640028,mp4a.40.2",AUDIO="aac",SUBTITLES="subs"
+video/1080.m3u8
+#EXT-X-I-FRAME-STREAM-INF:BANDWIDTH=500000,RESOLUTION=1920x1080,CODECS="avc1.640028",URI="video/1080-iframes.m3u8"
+`
+
+	m, err := ParseMasterManifest(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !m.IndependentSegments || len(m.Renditions) != 2 || m.Renditions[1].Type != MediaSubtitles {
+		t.Errorf("expected the independent segments and both renditions, got %+v", m)
+	}
+
+	if variant := m.Variants[0]; variant.AverageBandwidth != 4000000 || variant.Audio != "aac" || variant.Subtitles != "subs" || len(variant.Attributes) != 0 {
+		t.Errorf("expected the groups of the variant, got %+v", variant)
+	}
+
+	if len(m.IFrameVariants) != 1 || m.IFrameVariants[0].URI != "video/1080-iframes.m3u8" || m.IFrameVariants[0].Bandwidth != 500000 {
+		t.Errorf("expected the I-frame variant, got %+v", m.IFrameVariants)
+	}
+
+	if m.String() != data {
+		t.Errorf("expected the manifest to be written as parsed, got %s", m.String())
+	}
+
+	m.RewriteURIs(func(uri string) string { return "/streams/" + uri })
+	written := m.String()
+	for _, uri := range []string{`URI="/streams/audio/en.m3u8"`, `URI="/streams/subs/en.m3u8"`, "\n/streams/video/1080.m3u8\n", `URI="/streams/video/1080-iframes.m3u8"`} {
+		if !strings.Contains(written, uri) {
+			t.Errorf("expected %s to be rewritten, got %s", uri, written)
+		}
+	}
+
+	if _, err := ParseMasterManifest("#EXTM3U\n#EXT-X-I-FRAME-STREAM-INF:BANDWIDTH=1\n"); !errors.Is(err, ErrRequiredFieldMissing) {
+		t.Errorf("expected the I-frame variant to require its URI, got %v", err)
+	}
+}
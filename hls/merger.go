@@ -1,7 +1,88 @@
 package hls
 
-// Merge merges two manifests.
+import (
+	"errors"
+	"slices"
+)
+
+// ErrGroupIndex indicates that a group index is outside of the groups of the manifest.
+var ErrGroupIndex = errors.New("group index out of range")
+
+// Merge merges two manifests, pinning the IVs of the encrypted segments of the second manifest when they change media sequence numbers, without modifying it.
 func (m *Manifest) Merge(m2 Manifest) bool {
+	hasBreakingChange := m.raise(&m2)
+	groups := slices.Clone(m2.SegmentGroups)
+	if sequence := uint64(m.MediaSequence) + uint64(m.SegmentCount()); sequence != uint64(m2.MediaSequence) {
+		pinIVs(groups, uint64(m2.MediaSequence), true)
+	}
+
+	if len(groups) > 0 {
+		groups[0].Continuous = false
+	}
+
+	m.SegmentGroups = append(m.SegmentGroups, groups...)
+	return hasBreakingChange
+}
+
+// Append appends the segments of the second manifest, continuing the last group when withDiscontinuity is false, returning true on a breaking change as Merge does.
+func (m *Manifest) Append(m2 Manifest, withDiscontinuity bool) (bool, error) {
+	return m.InsertAt(len(m.SegmentGroups), m2, withDiscontinuity)
+}
+
+// InsertAt inserts the segments of the second manifest before the group at the index, like an ad, intro or bumper spliced into a stream, returning true on a breaking change as Merge does, pinning the IVs of the segments changing media sequence numbers without modifying the second manifest.
+//
+// With withDiscontinuity, the inserted groups are separated from the ones around them; otherwise the first inserted group continues the previous group and the last one is continued by the group at the index, as groups of their own when their initialization sections differ.
+func (m *Manifest) InsertAt(index int, m2 Manifest, withDiscontinuity bool) (bool, error) {
+	if index < 0 || index > len(m.SegmentGroups) {
+		return false, ErrGroupIndex
+	}
+
+	if len(m2.SegmentGroups) == 0 {
+		return false, nil
+	}
+
+	hasBreakingChange := m.raise(&m2)
+
+	// The groups are copied so joining them never modifies the groups of either manifest.
+	groups := slices.Clone(m2.SegmentGroups)
+	before, after := m.SegmentGroups[:index], slices.Clone(m.SegmentGroups[index:])
+
+	// Only the segments changing media sequence numbers have their IVs pinned: the inserted ones unless they keep their numbers, and the ones after them.
+	sequence := uint64(m.MediaSequence)
+	for _, group := range before {
+		sequence += uint64(len(group.Segments))
+	}
+
+	if sequence != uint64(m2.MediaSequence) {
+		pinIVs(groups, uint64(m2.MediaSequence), true)
+	}
+
+	if m2.SegmentCount() > 0 {
+		pinIVs(after, sequence, true)
+	}
+
+	groups[0].Continuous = !withDiscontinuity && index > 0
+	if len(after) > 0 {
+		after[0].Continuous = !withDiscontinuity
+	}
+
+	if last := &groups[len(groups)-1]; len(after) > 0 && after[0].Continuous && last.Map == after[0].Map {
+		last.Segments = slices.Concat(last.Segments, after[0].Segments)
+		after = after[1:]
+	}
+
+	if groups[0].Continuous && before[index-1].Map == groups[0].Map {
+		groups[0].Segments = slices.Concat(before[index-1].Segments, groups[0].Segments)
+		groups[0].Continuous = before[index-1].Continuous
+		before = before[:index-1]
+	}
+
+	m.SegmentGroups = slices.Concat(before, groups, after)
+	return hasBreakingChange, nil
+}
+
+// raise raises the target duration and version of the manifest to the ones of the second manifest, returning true if any was raised.
+func (m *Manifest) raise(m2 *Manifest) bool {
 	hasBreakingChange := false
 	if m.TargetDuration < m2.TargetDuration {
 		m.TargetDuration = m2.TargetDuration
@@ -13,7 +94,6 @@ func (m *Manifest) Merge(m2 Manifest) bool {
 		hasBreakingChange = true
 	}
 
-	m.SegmentGroups = append(m.SegmentGroups, m2.SegmentGroups...)
 	return hasBreakingChange
 }
 
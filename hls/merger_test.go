@@ -1,6 +1,44 @@
 package hls
 
-import "testing"
+import (
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// groupPaths returns the paths of the segments of each group
+func groupPaths(m *Manifest) [][]string {
+	var groups [][]string
+	for _, group := range m.SegmentGroups {
+		var paths []string
+		for _, segment := range group.Segments {
+			paths = append(paths, segment.Path)
+		}
+
+		groups = append(groups, paths)
+	}
+
+	return groups
+}
+
+// splice parses the stream and ad manifests spliced by the insertion tests
+func splice(t *testing.T) (Manifest, Manifest) {
+	t.Helper()
+
+	stream, err := ParseHlsManifest("#EXTM3U\n#EXT-X-TARGETDURATION:4\n#EXTINF:4,\na.ts\n#EXT-DISCONTINUITY\n#EXTINF:4,\nb.ts\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ad, err := ParseHlsManifest("#EXTM3U\n#EXT-X-VERSION:4\n#EXT-X-TARGETDURATION:6\n#EXTINF:6,\nad.ts\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return stream, ad
+}
 
 func TestMerger(t *testing.T) {
 	manifest0 := readManifest(t, "../testdata/stream0.m3u8")
@@ -56,3 +94,135 @@ func TestMerger(t *testing.T) {
 		t.Errorf("expected manifest to be the same, got different")
 	}
 }
+
+func TestInsertAt(t *testing.T) {
+	for _, test := range []struct {
+		name              string
+		index             int
+		withDiscontinuity bool
+		expected          [][]string
+	}{
+		{"start", 0, true, [][]string{{"ad.ts"}, {"a.ts"}, {"b.ts"}}},
+		{"start joined", 0, false, [][]string{{"ad.ts", "a.ts"}, {"b.ts"}}},
+		{"middle", 1, true, [][]string{{"a.ts"}, {"ad.ts"}, {"b.ts"}}},
+		{"middle joined", 1, false, [][]string{{"a.ts", "ad.ts", "b.ts"}}},
+		{"end", 2, true, [][]string{{"a.ts"}, {"b.ts"}, {"ad.ts"}}},
+		{"end joined", 2, false, [][]string{{"a.ts"}, {"b.ts", "ad.ts"}}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			stream, ad := splice(t)
+			hasBreakingChange, err := stream.InsertAt(test.index, ad, test.withDiscontinuity)
+			if err != nil || !hasBreakingChange {
+				t.Fatalf("expected a breaking change, got %v and %v", hasBreakingChange, err)
+			}
+
+			if groups := groupPaths(&stream); !slices.EqualFunc(groups, test.expected, slices.Equal) {
+				t.Errorf("expected the groups %v, got %v", test.expected, groups)
+			}
+
+			if stream.TargetDuration != 6 || stream.Version != 4 || len(ad.SegmentGroups[0].Segments) != 1 {
+				t.Errorf("expected the target duration and version of the ad without modifying it, got %+v", stream)
+			}
+		})
+	}
+}
+
+func TestAppend(t *testing.T) {
+	stream, ad := splice(t)
+	if _, err := stream.Append(ad, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if groups := groupPaths(&stream); len(groups) != 2 || !slices.Equal(groups[1], []string{"b.ts", "ad.ts"}) {
+		t.Errorf("expected the ad to continue the last group, got %v", groups)
+	}
+
+	if _, err := stream.Append(Manifest{}, true); err != nil || stream.SegmentCount() != 3 {
+		t.Errorf("expected an empty manifest to append nothing, got %v", err)
+	}
+}
+
+func TestInsertAtErrors(t *testing.T) {
+	stream, ad := splice(t)
+	for _, index := range []int{-1, 3} {
+		if _, err := stream.InsertAt(index, ad, true); !errors.Is(err, ErrGroupIndex) {
+			t.Errorf("expected %v inserting at %d, got %v", ErrGroupIndex, index, err)
+		}
+	}
+
+	if stream.SegmentCount() != 2 || stream.TargetDuration != 4 {
+		t.Errorf("expected the failed insertions to leave the manifest, got %+v", stream)
+	}
+}
+
+func TestInsertAtMapChange(t *testing.T) {
+	stream, ad := splice(t)
+	stream.SegmentGroups[0].Map, stream.SegmentGroups[1].Map = Map{URI: "main.mp4"}, Map{URI: "main.mp4"}
+	ad.SegmentGroups[0].Map = Map{URI: "ad.mp4"}
+	if _, err := stream.InsertAt(1, ad, false); err != nil {
+		t.Fatal(err)
+	}
+
+	// The inserted group has its own initialization section, continuing the groups around it without a discontinuity.
+	if groups := groupPaths(&stream); !slices.EqualFunc(groups, [][]string{{"a.ts"}, {"ad.ts"}, {"b.ts"}}, slices.Equal) || !stream.SegmentGroups[1].Continuous || !stream.SegmentGroups[2].Continuous {
+		t.Errorf("expected the ad as a group continuing the previous one, got %+v", stream.SegmentGroups)
+	}
+
+	output := stream.String()
+	if strings.Contains(output, DiscontinuityField) || strings.Count(output, MapField) != 3 {
+		t.Errorf("expected the initialization sections to change without a discontinuity, got %s", output)
+	}
+
+	if reparsed, err := ParseHlsManifest(output); err != nil || reparsed.String() != output {
+		t.Errorf("expected the manifest to round-trip, got %v", err)
+	}
+}
+
+func BenchmarkMerge(b *testing.B) {
+	for _, size := range benchmarkSizes {
+		manifest := benchmarkManifest(b, size)
+		b.Run(strconv.Itoa(size), func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				b.StopTimer()
+				m := manifest.Clone()
+				b.StartTimer()
+
+				m.Merge(manifest)
+			}
+		})
+	}
+}
+
+func TestInsertAtIVs(t *testing.T) {
+	stream, err := ParseHlsManifest("#EXTM3U\n#EXT-X-TARGETDURATION:4\n#EXT-X-MEDIA-SEQUENCE:10\n#EXT-X-KEY:METHOD=AES-128,URI=\"k\"\n#EXTINF:4,\na.ts\n#EXTINF:4,\nb.ts\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ad, err := ParseHlsManifest("#EXTM3U\n#EXT-X-TARGETDURATION:4\n#EXT-X-MEDIA-SEQUENCE:50\n#EXT-X-KEY:METHOD=AES-128,URI=\"ad\"\n#EXTINF:4,\nad.ts\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stream.SegmentGroups = append(stream.SegmentGroups, SegmentGroup{Segments: stream.SegmentGroups[0].Segments[1:]})
+	stream.SegmentGroups[0].Segments = stream.SegmentGroups[0].Segments[:1]
+	if _, err := stream.InsertAt(1, ad, true); err != nil {
+		t.Fatal(err)
+	}
+
+	// The segments before the insertion keep their media sequence numbers, so their IVs are left implicit.
+	for _, test := range []struct{ got, expected string }{
+		{stream.SegmentGroups[0].Segments[0].Keys[0].IV, ""},
+		{stream.SegmentGroups[1].Segments[0].Keys[0].IV, FormatIV(SequenceIV(50))},
+		{stream.SegmentGroups[2].Segments[0].Keys[0].IV, FormatIV(SequenceIV(11))},
+	} {
+		if test.got != test.expected {
+			t.Errorf("expected the IV %q, got %q", test.expected, test.got)
+		}
+	}
+
+	if iv := ad.SegmentGroups[0].Segments[0].Keys[0].IV; iv != "" {
+		t.Errorf("expected the inserted manifest to be left untouched, got the IV %s", iv)
+	}
+}
@@ -0,0 +1,51 @@
+package hls
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+)
+
+// MaxLineLength is the length of the longest line ParseHlsManifestReader accepts.
+const MaxLineLength = 1 << 20
+
+// ParseHlsManifestReader parses a HLS manifest line by line from a reader, like the body of a HTTP response, without reading it whole in memory.
+//
+// The manifest is parsed as ParseHlsManifest does, stopping to read at the EXT-X-ENDLIST tag, and the errors of the reader, like bufio.ErrTooLong for lines longer than MaxLineLength, are returned as they are.
+func ParseHlsManifestReader(r io.Reader) (Manifest, error) {
+	return parseManifestReader(r, ParseOptions{})
+}
+
+// parseManifestReader parses a HLS manifest line by line from a reader as the options tell.
+func parseManifestReader(r io.Reader, options ParseOptions) (Manifest, error) {
+	parser := newManifestParser(0, 0)
+	parser.options = options
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, MaxLineLength)
+	scanner.Split(scanLines)
+	for !parser.ended && scanner.Scan() {
+		if err := parser.parseLine(scanner.Text()); err != nil {
+			return parser.manifest, err
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return parser.manifest, err
+	}
+
+	return parser.finish()
+}
+
+// scanLines splits the data at each line break as strings.Cut does, returning an empty last line when the data ends with a line break.
+func scanLines(data []byte, atEOF bool) (int, []byte, error) {
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+
+	if atEOF {
+		return len(data), data, bufio.ErrFinalToken
+	}
+
+	return 0, nil, nil
+}
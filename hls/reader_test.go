@@ -0,0 +1,89 @@
+package hls
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestParseHlsManifestReader(t *testing.T) {
+	for _, path := range []string{"../testdata/stream0.m3u8", "../testdata/stream1.m3u8", "../testdata/stream2.m3u8"} {
+		file, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer file.Close()
+
+		manifest, err := ParseHlsManifestReader(iotest.OneByteReader(file))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := readManifest(t, path)
+		if manifest.String() != expected.String() {
+			t.Errorf("expected %s to be parsed as from a string, got %s", path, manifest.String())
+		}
+	}
+
+	manifest, err := ParseHlsManifestReader(strings.NewReader(generateManifest(5000)))
+	if err != nil || manifest.SegmentCount() != 5000 || len(manifest.SegmentGroups) != 5 {
+		t.Errorf("expected 5000 segments in 5 groups, got %d and %v", manifest.SegmentCount(), err)
+	}
+}
+
+func TestParseHlsManifestReaderErrors(t *testing.T) {
+	for _, data := range []string{"", "EXTM3U\n", "#EXTM3U\n#EXTINF:4,\n", "#EXTM3U\n#EXTINF:4,\n#EXT-X-ENDLIST\nignored", "#EXTM3U\n#EXTINF:4\na.ts", "#EXTM3U\r\n#EXTINF:4,\r\n#EXT-X-UNKNOWN\r\n"} {
+		_, expected := ParseHlsManifest(data)
+		if _, err := ParseHlsManifestReader(strings.NewReader(data)); err == nil || err.Error() != expected.Error() {
+			t.Errorf("expected %v parsing %q, got %v", expected, data, err)
+		}
+	}
+
+	failure := errors.New("connection reset")
+	reader := io.MultiReader(strings.NewReader("#EXTM3U\n#EXTINF:4,\n"), iotest.ErrReader(failure))
+	if _, err := ParseHlsManifestReader(reader); !errors.Is(err, failure) {
+		t.Errorf("expected the error of the reader, got %v", err)
+	}
+
+	long := "#EXTM3U\n#EXTINF:4,\n" + strings.Repeat("a", MaxLineLength+1) + "\n"
+	if _, err := ParseHlsManifestReader(strings.NewReader(long)); !errors.Is(err, bufio.ErrTooLong) {
+		t.Errorf("expected the long line to be rejected, got %v", err)
+	}
+}
+
+func FuzzParseHlsManifestReader(f *testing.F) {
+	f.Add("#EXTM3U\n#EXTINF:4,\na.ts\n")
+	f.Add("#EXTM3U\r\n#EXT-X-KEY:METHOD=AES-128,URI=\"key\"\r\n#EXTINF:4,\r\na.ts\r\n#EXT-DISCONTINUITY\r\n#EXTINF:4,\r\nb.ts\r\n#EXT-X-ENDLIST\r\n")
+
+	f.Fuzz(func(t *testing.T, data string) {
+		expected, expectedErr := ParseHlsManifest(data)
+		manifest, err := ParseHlsManifestReader(strings.NewReader(data))
+		if (err == nil) != (expectedErr == nil) || (err != nil && err.Error() != expectedErr.Error() && !errors.Is(err, bufio.ErrTooLong)) {
+			t.Fatalf("expected %v, got %v", expectedErr, err)
+		}
+
+		if err == nil && manifest.String() != expected.String() {
+			t.Fatalf("expected the manifest to be parsed as from a string, got %q and %q", expected.String(), manifest.String())
+		}
+	})
+}
+
+func BenchmarkParseHlsManifestReader(b *testing.B) {
+	for _, size := range benchmarkSizes {
+		data := generateManifest(size)
+		b.Run(strconv.Itoa(size), func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(data)))
+			for b.Loop() {
+				if _, err := ParseHlsManifestReader(strings.NewReader(data)); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,201 @@
+package hls
+
+import (
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// MediaField is the field that indicates an alternative rendition in a master manifest, like an audio track.
+const MediaField = "#EXT-X-MEDIA"
+
+// ErrInvalidChannels indicates that a CHANNELS attribute is malformed.
+var ErrInvalidChannels = errors.New("invalid channels")
+
+// Spatial audio identifiers of the CHANNELS attribute.
+const (
+	// AudioCodingJOC is the coding identifier of Dolby Atmos carried as joint object coding in Dolby Digital Plus.
+	AudioCodingJOC = "JOC"
+
+	// SpatialImmersive indicates that the audio is rendered around the listener, like ambisonics.
+	SpatialImmersive = "IMMERSIVE"
+
+	// SpatialBinaural indicates that the audio is binaural, rendered for headphones.
+	SpatialBinaural = "BINAURAL"
+
+	// SpatialDownmix indicates that the audio is a downmix of a spatial track.
+	SpatialDownmix = "DOWNMIX"
+)
+
+// Channels represents the CHANNELS attribute of an audio rendition, like "2", "16/JOC" or "4/-/IMMERSIVE".
+type Channels struct {
+	Count   int      // Maximum independent audio channels, or objects for object based audio
+	Coding  []string // Audio coding identifiers, like "JOC"
+	Spatial []string // Spatial audio identifiers, like "IMMERSIVE" or "BINAURAL"
+}
+
+// ParseChannels parses the value of a CHANNELS attribute.
+func ParseChannels(value string) (Channels, error) {
+	parts := strings.Split(value, "/")
+
+	count, err := strconv.Atoi(parts[0])
+	if err != nil || count <= 0 || len(parts) > 3 {
+		return Channels{}, ErrInvalidChannels
+	}
+
+	channels := Channels{Count: count}
+	if len(parts) > 1 && parts[1] != "-" {
+		channels.Coding = strings.Split(parts[1], ",")
+	}
+
+	if len(parts) > 2 && parts[2] != "-" {
+		channels.Spatial = strings.Split(parts[2], ",")
+	}
+
+	return channels, nil
+}
+
+// String returns the value of the CHANNELS attribute, empty when the count is unknown.
+func (c Channels) String() string {
+	if c.Count <= 0 {
+		return ""
+	}
+
+	value := strconv.Itoa(c.Count)
+	if len(c.Coding) == 0 && len(c.Spatial) == 0 {
+		return value
+	}
+
+	coding := "-"
+	if len(c.Coding) > 0 {
+		coding = strings.Join(c.Coding, ",")
+	}
+
+	value += "/" + coding
+	if len(c.Spatial) > 0 {
+		value += "/" + strings.Join(c.Spatial, ",")
+	}
+
+	return value
+}
+
+// IsSpatial returns true if the audio is rendered around the listener, like Dolby Atmos or ambisonics, rather than a downmix.
+func (c Channels) IsSpatial() bool {
+	return slices.Contains(c.Coding, AudioCodingJOC) || slices.Contains(c.Spatial, SpatialImmersive) || slices.Contains(c.Spatial, SpatialBinaural)
+}
+
+// MediaType represents the type of an alternative rendition.
+type MediaType string
+
+const (
+	// MediaAudio is an audio rendition.
+	MediaAudio MediaType = "AUDIO"
+
+	// MediaVideo is a video rendition.
+	MediaVideo MediaType = "VIDEO"
+
+	// MediaSubtitles is a subtitles rendition.
+	MediaSubtitles MediaType = "SUBTITLES"
+
+	// MediaClosedCaptions is a closed captions rendition.
+	MediaClosedCaptions MediaType = "CLOSED-CAPTIONS"
+)
+
+// Rendition represents an alternative rendition of a master manifest, like an audio track selected by the player.
+type Rendition struct {
+	Type       MediaType   // Type of the rendition
+	GroupID    string      // Group the rendition belongs to, referenced by the variants
+	Name       string      // Human readable name of the rendition
+	Language   string      // Language of the rendition, like "en", empty if unknown
+	URI        string      // URI of the media manifest of the rendition, empty when it is muxed in the variants
+	Default    bool        // Indicates if the player plays the rendition without a user choice
+	AutoSelect bool        // Indicates if the player may choose the rendition from the user preferences
+	Forced     bool        // Indicates if a subtitles rendition only translates the foreign dialogues, shown without a user choice
+	Channels   Channels    // Channels of an audio rendition
+	Attributes []Attribute // Other attributes of the rendition, kept in order
+}
+
+// yesNo returns the enumerated string of a boolean attribute.
+func yesNo(value bool) string {
+	if value {
+		return "YES"
+	}
+
+	return "NO"
+}
+
+// parseRendition parses the attributes of an alternative rendition.
+func parseRendition(list string, lineNumber int) (Rendition, error) {
+	attributes, err := parseAttributes(list)
+	if err != nil {
+		return Rendition{}, fieldError(MediaField, lineNumber, err)
+	}
+
+	rendition := Rendition{}
+	for _, attribute := range attributes {
+		switch attribute.Key {
+		case "TYPE":
+			value, err := enumerated(attribute)
+			if err != nil {
+				return Rendition{}, fieldError(MediaField, lineNumber, err)
+			}
+
+			rendition.Type = MediaType(value)
+		case "GROUP-ID":
+			rendition.GroupID = attribute.Value
+		case "NAME":
+			rendition.Name = attribute.Value
+		case "LANGUAGE":
+			rendition.Language = attribute.Value
+		case "URI":
+			rendition.URI = attribute.Value
+		case "DEFAULT":
+			rendition.Default = attribute.Value == "YES"
+		case "AUTOSELECT":
+			rendition.AutoSelect = attribute.Value == "YES"
+		case "FORCED":
+			rendition.Forced = attribute.Value == "YES"
+		case "CHANNELS":
+			if rendition.Channels, err = ParseChannels(attribute.Value); err != nil {
+				return Rendition{}, fieldError(MediaField, lineNumber, err)
+			}
+		default:
+			rendition.Attributes = append(rendition.Attributes, attribute)
+		}
+	}
+
+	if rendition.Type == "" || rendition.GroupID == "" || rendition.Name == "" {
+		return Rendition{}, fieldError(MediaField, lineNumber, ErrRequiredFieldMissing)
+	}
+
+	return rendition, nil
+}
+
+// attributes returns the attributes of the rendition.
+func (r *Rendition) attributes() []Attribute {
+	attributes := []Attribute{
+		{Key: "TYPE", Value: string(r.Type)},
+		{Key: "GROUP-ID", Value: r.GroupID, Quoted: true},
+		{Key: "NAME", Value: r.Name, Quoted: true},
+	}
+
+	if r.Language != "" {
+		attributes = append(attributes, Attribute{Key: "LANGUAGE", Value: r.Language, Quoted: true})
+	}
+
+	attributes = append(attributes, Attribute{Key: "DEFAULT", Value: yesNo(r.Default)}, Attribute{Key: "AUTOSELECT", Value: yesNo(r.AutoSelect)})
+	if r.Forced {
+		attributes = append(attributes, Attribute{Key: "FORCED", Value: "YES"})
+	}
+
+	if channels := r.Channels.String(); channels != "" {
+		attributes = append(attributes, Attribute{Key: "CHANNELS", Value: channels, Quoted: true})
+	}
+
+	if r.URI != "" {
+		attributes = append(attributes, Attribute{Key: "URI", Value: r.URI, Quoted: true})
+	}
+
+	return append(attributes, r.Attributes...)
+}
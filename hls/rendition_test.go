@@ -0,0 +1,70 @@
+package hls
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestChannels(t *testing.T) {
+	cases := []struct {
+		value   string
+		spatial bool
+	}{
+		{"2", false},
+		{"16/JOC", true},
+		{"4/-/IMMERSIVE", true},
+		{"2/-/BINAURAL", true},
+		{"6/-/DOWNMIX", false},
+	}
+
+	for _, c := range cases {
+		channels, err := ParseChannels(c.value)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if channels.String() != c.value {
+			t.Errorf("expected %s, got %s", c.value, channels)
+		}
+
+		if channels.IsSpatial() != c.spatial {
+			t.Errorf("expected %s spatial %t", c.value, c.spatial)
+		}
+	}
+
+	for _, value := range []string{"", "stereo", "0", "2/a/b/c"} {
+		if _, err := ParseChannels(value); !errors.Is(err, ErrInvalidChannels) {
+			t.Errorf("expected invalid channels for %q, got %v", value, err)
+		}
+	}
+}
+
+func TestMasterManifestRenditions(t *testing.T) {
+	data := "#EXTM3U\n" +
+		"#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID=\"audio\",NAME=\"Atmos\",LANGUAGE=\"en\",DEFAULT=YES,AUTOSELECT=YES,CHANNELS=\"16/JOC\",URI=\"atmos.m3u8\"\n" +
+		"#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID=\"audio\",NAME=\"Stereo\",LANGUAGE=\"en\",DEFAULT=NO,AUTOSELECT=YES,CHANNELS=\"2\",URI=\"stereo.m3u8\"\n" +
+		"#EXT-X-STREAM-INF:BANDWIDTH=20000000,AUDIO=\"audio\"\n" +
+		"video.m3u8\n"
+
+	m, err := ParseMasterManifest(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(m.Renditions) != 2 || m.Variants[0].Audio != "audio" {
+		t.Fatalf("expected 2 audio renditions referenced by the variant, got %+v", m)
+	}
+
+	atmos := m.Renditions[0]
+	if atmos.Type != MediaAudio || !atmos.Default || !atmos.Channels.IsSpatial() || atmos.URI != "atmos.m3u8" {
+		t.Errorf("expected the default Atmos rendition, got %+v", atmos)
+	}
+
+	if m.String() != data {
+		t.Errorf("expected %q, got %q", data, m.String())
+	}
+
+	if _, err := ParseMasterManifest("#EXTM3U\n#EXT-X-MEDIA:TYPE=AUDIO,NAME=\"Stereo\"\n"); !errors.Is(err, ErrRequiredFieldMissing) {
+		t.Errorf("expected missing group ID, got %v", err)
+	}
+}
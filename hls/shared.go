@@ -0,0 +1,111 @@
+package hls
+
+import (
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// Clone returns a deep copy of the manifest, so it can be modified without affecting the original.
+func (m *Manifest) Clone() Manifest {
+	clone := *m
+	clone.SegmentGroups = slices.Clone(m.SegmentGroups)
+	clone.UnknownTags = slices.Clone(m.UnknownTags)
+	clone.Parts = slices.Clone(m.Parts)
+	clone.PreloadHints = slices.Clone(m.PreloadHints)
+	for i := range clone.SegmentGroups {
+		segments := slices.Clone(clone.SegmentGroups[i].Segments)
+		for j := range segments {
+			segments[j].Keys = slices.Clone(segments[j].Keys)
+			segments[j].UnknownTags = slices.Clone(segments[j].UnknownTags)
+			segments[j].Parts = slices.Clone(segments[j].Parts)
+		}
+
+		clone.SegmentGroups[i].Segments = segments
+	}
+
+	return clone
+}
+
+// Snapshot is an immutable version of a shared manifest, safe to read from any goroutine.
+type Snapshot struct {
+	manifest Manifest
+	version  uint64
+	once     sync.Once
+	text     string
+}
+
+// Version returns the number of the snapshot, incremented by each update of the shared manifest.
+func (s *Snapshot) Version() uint64 {
+	return s.version
+}
+
+// Manifest returns a copy of the manifest of the snapshot, free to be modified.
+func (s *Snapshot) Manifest() Manifest {
+	return s.manifest.Clone()
+}
+
+// SegmentCount returns the number of segments of the snapshot.
+func (s *Snapshot) SegmentCount() int {
+	return s.manifest.SegmentCount()
+}
+
+// Duration returns the total duration of the snapshot.
+func (s *Snapshot) Duration() float64 {
+	return s.manifest.Duration()
+}
+
+// String returns the snapshot as a manifest, rendered once and shared by every caller.
+func (s *Snapshot) String() string {
+	s.once.Do(func() { s.text = s.manifest.String() })
+	return s.text
+}
+
+// SharedManifest is a manifest updated by a poller and read by many goroutines, copying it on write so readers never lock nor observe a partial update.
+type SharedManifest struct {
+	mutex   sync.Mutex // Serializes the updates
+	current atomic.Pointer[Snapshot]
+}
+
+// NewSharedManifest creates a new SharedManifest holding a copy of the manifest.
+func NewSharedManifest(m Manifest) *SharedManifest {
+	s := &SharedManifest{}
+	s.current.Store(&Snapshot{manifest: m.Clone()})
+
+	return s
+}
+
+// Load returns the current snapshot.
+func (s *SharedManifest) Load() *Snapshot {
+	if snapshot := s.current.Load(); snapshot != nil {
+		return snapshot
+	}
+
+	return &Snapshot{}
+}
+
+// Store replaces the manifest with a copy of the manifest, returning the new snapshot.
+func (s *SharedManifest) Store(m Manifest) *Snapshot {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.publish(m.Clone())
+}
+
+// Update modifies a copy of the current manifest with the function and publishes it, returning the new snapshot, so concurrent updates are never lost.
+func (s *SharedManifest) Update(update func(m *Manifest)) *Snapshot {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	m := s.Load().manifest.Clone()
+	update(&m)
+	return s.publish(m)
+}
+
+// publish stores the manifest as the snapshot following the current one.
+func (s *SharedManifest) publish(m Manifest) *Snapshot {
+	snapshot := &Snapshot{manifest: m, version: s.Load().version + 1}
+	s.current.Store(snapshot)
+
+	return snapshot
+}
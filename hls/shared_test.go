@@ -0,0 +1,84 @@
+package hls
+
+import (
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestClone(t *testing.T) {
+	m := Manifest{SegmentGroups: []SegmentGroup{{Segments: []Segment{{Path: "0.ts", Keys: []Key{{Method: MethodAES128, URI: "key"}}}}}}}
+
+	clone := m.Clone()
+	clone.SegmentGroups[0].Segments[0].Path = "changed.ts"
+	clone.SegmentGroups[0].Segments[0].Keys[0].URI = "changed"
+	clone.SegmentGroups = append(clone.SegmentGroups, SegmentGroup{})
+
+	if m.SegmentGroups[0].Segments[0].Path != "0.ts" || m.SegmentGroups[0].Segments[0].Keys[0].URI != "key" || len(m.SegmentGroups) != 1 {
+		t.Errorf("expected the original to be untouched, got %+v", m)
+	}
+}
+
+func TestSharedManifest(t *testing.T) {
+	original := Manifest{Version: 3, TargetDuration: 4, SegmentGroups: []SegmentGroup{{Segments: []Segment{{Path: "0.ts", Duration: 4}}}}}
+	s := NewSharedManifest(original)
+	original.SegmentGroups[0].Segments[0].Path = "changed.ts"
+
+	first := s.Load()
+	if !strings.Contains(first.String(), "\n0.ts\n") || first.Version() != 0 {
+		t.Errorf("expected a copy of the manifest, got %s", first.String())
+	}
+
+	second := s.Update(func(m *Manifest) {
+		m.SegmentGroups[0].Segments = append(m.SegmentGroups[0].Segments, Segment{Path: "1.ts", Duration: 4})
+	})
+
+	if first.SegmentCount() != 1 || second.SegmentCount() != 2 || second.Version() != 1 || s.Load() != second {
+		t.Errorf("expected the update to publish a new snapshot, got %d and %d", first.SegmentCount(), second.SegmentCount())
+	}
+
+	m := second.Manifest()
+	m.SegmentGroups[0].Segments[0].Path = "changed.ts"
+	if !strings.Contains(second.String(), "\n0.ts\n") {
+		t.Errorf("expected the snapshot to be immutable, got %s", second.String())
+	}
+}
+
+func TestSharedManifestConcurrent(t *testing.T) {
+	s := NewSharedManifest(Manifest{TargetDuration: 4})
+
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				s.Update(func(m *Manifest) {
+					if len(m.SegmentGroups) == 0 {
+						m.SegmentGroups = []SegmentGroup{{}}
+					}
+					m.SegmentGroups[0].Segments = append(m.SegmentGroups[0].Segments, Segment{Path: "s.ts", Duration: 4})
+				})
+			}
+		}()
+	}
+
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				snapshot := s.Load()
+				if strings.Count(snapshot.String(), "s.ts") != snapshot.SegmentCount() {
+					t.Errorf("expected a consistent snapshot")
+					return
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+	if count := s.Load().SegmentCount(); count != 400 {
+		t.Errorf("expected every update to be kept, got %d segments", count)
+	}
+}
@@ -0,0 +1,82 @@
+package hls
+
+import (
+	"errors"
+	"math"
+	"slices"
+)
+
+// ErrInvalidTimeRange indicates that a time range is empty, negative or not covered by any segment.
+var ErrInvalidTimeRange = errors.New("invalid time range")
+
+// SliceByTime returns a copy of the manifest with only the segments playing between start and end, in seconds since the start of the manifest, like a clip.
+//
+// The groups keep their boundaries, the media sequence and the discontinuity sequence accounting for the segments and groups left out before the first one, so the IVs derived from the media sequence numbers are unchanged. The end list, the partial segments of the segment being produced and the preload hints are kept only when the slice reaches the end of the manifest.
+func (m *Manifest) SliceByTime(start float64, end float64) (Manifest, error) {
+	if math.IsNaN(start) || math.IsNaN(end) || start < 0 || end <= start {
+		return Manifest{}, ErrInvalidTimeRange
+	}
+
+	slice := *m
+	slice.SegmentGroups = nil
+	slice.UnknownTags = slices.Clone(m.UnknownTags)
+	slice.HasEndList = false
+	slice.Parts, slice.PreloadHints = nil, nil
+
+	var skipped, skippedGroups int
+	time := 0.0
+	for i, group := range m.SegmentGroups {
+		if time >= end {
+			break
+		}
+
+		first, last := len(group.Segments), 0
+		for j, segment := range group.Segments {
+			segmentStart := time
+			time += float64(segment.Duration)
+
+			if time <= start {
+				skipped++
+				continue
+			}
+
+			if segmentStart >= end {
+				break
+			}
+
+			first, last = min(first, j), j+1
+		}
+
+		if first >= last {
+			if slice.SegmentGroups == nil {
+				skippedGroups = i + 1
+			}
+
+			continue
+		}
+
+		segments := slices.Clone(group.Segments[first:last])
+		for j := range segments {
+			segments[j].Keys = slices.Clone(segments[j].Keys)
+			segments[j].UnknownTags = slices.Clone(segments[j].UnknownTags)
+			segments[j].Parts = slices.Clone(segments[j].Parts)
+		}
+
+		// The first group kept follows nothing, so it cannot continue a previous one.
+		slice.SegmentGroups = append(slice.SegmentGroups, SegmentGroup{Segments: segments, Map: group.Map, Continuous: group.Continuous && slice.SegmentGroups != nil})
+		if last == len(group.Segments) && i == len(m.SegmentGroups)-1 {
+			slice.HasEndList = m.HasEndList
+			slice.Parts, slice.PreloadHints = slices.Clone(m.Parts), slices.Clone(m.PreloadHints)
+		}
+	}
+
+	if slice.SegmentGroups == nil {
+		return Manifest{}, ErrInvalidTimeRange
+	}
+
+	// The segments skipped by a delta update are left out too, so they only move the media sequence.
+	slice.MediaSequence += slice.SkippedSegments + uint32(skipped)
+	slice.SkippedSegments = 0
+	slice.DiscontinuitySequence += m.discontinuitiesBefore(skippedGroups)
+	return slice, nil
+}
@@ -0,0 +1,109 @@
+package hls
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+)
+
+// sliceManifest is a manifest of 3 groups of 4 seconds segments, the first group encrypted
+func sliceManifest() Manifest {
+	return Manifest{
+		Version:               3,
+		TargetDuration:        4,
+		MediaSequence:         10,
+		DiscontinuitySequence: 2,
+		HasEndList:            true,
+		SegmentGroups: []SegmentGroup{
+			{Segments: []Segment{{Path: "a0.ts", Duration: 4, Keys: []Key{{Method: MethodAES128, URI: "key"}}}, {Path: "a1.ts", Duration: 4, Keys: []Key{{Method: MethodAES128, URI: "key"}}}}},
+			{Segments: []Segment{{Path: "b0.ts", Duration: 4}, {Path: "b1.ts", Duration: 4}, {Path: "b2.ts", Duration: 4}}},
+			{Segments: []Segment{{Path: "c0.ts", Duration: 4}}},
+		},
+	}
+}
+
+// slicePaths returns the paths of the segments of each group
+func slicePaths(m Manifest) [][]string {
+	var paths [][]string
+	for _, group := range m.SegmentGroups {
+		var groupPaths []string
+		for _, segment := range group.Segments {
+			groupPaths = append(groupPaths, segment.Path)
+		}
+
+		paths = append(paths, groupPaths)
+	}
+
+	return paths
+}
+
+func TestSliceByTime(t *testing.T) {
+	m := sliceManifest()
+
+	slice, err := m.SliceByTime(6, 13)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if paths := slicePaths(slice); len(paths) != 2 || len(paths[0]) != 1 || paths[0][0] != "a1.ts" || len(paths[1]) != 2 || paths[1][1] != "b1.ts" {
+		t.Errorf("expected a1.ts then b0.ts and b1.ts after a discontinuity, got %v", paths)
+	}
+
+	if slice.MediaSequence != 11 || slice.DiscontinuitySequence != 2 || slice.HasEndList {
+		t.Errorf("expected the media sequence of a1.ts and no end list, got %d, %d and %t", slice.MediaSequence, slice.DiscontinuitySequence, slice.HasEndList)
+	}
+
+	slice.SegmentGroups[0].Segments[0].Keys[0].URI = "changed"
+	if m.SegmentGroups[0].Segments[1].Keys[0].URI != "key" {
+		t.Errorf("expected the slice not to share its segments with the manifest")
+	}
+
+	slice, err = m.SliceByTime(16, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if paths := slicePaths(slice); len(paths) != 2 || paths[0][0] != "b2.ts" || paths[1][0] != "c0.ts" {
+		t.Errorf("expected b2.ts then c0.ts, got %v", paths)
+	}
+
+	if slice.MediaSequence != 14 || slice.DiscontinuitySequence != 3 || !slice.HasEndList {
+		t.Errorf("expected the sequences after the first group and the end list, got %d, %d and %t", slice.MediaSequence, slice.DiscontinuitySequence, slice.HasEndList)
+	}
+}
+
+func TestSliceByTimeMapChange(t *testing.T) {
+	m, err := ParseHlsManifest("#EXTM3U\n#EXT-X-VERSION:6\n#EXT-X-TARGETDURATION:4\n#EXT-X-MAP:URI=\"a.mp4\"\n#EXTINF:4,\na.m4s\n#EXT-X-MAP:URI=\"b.mp4\"\n#EXTINF:4,\nb0.m4s\n#EXTINF:4,\nb1.m4s\n#EXT-X-ENDLIST")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	slice, err := m.SliceByTime(0, 12)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if data := slice.String(); data != m.String() || strings.Contains(data, DiscontinuityField) {
+		t.Errorf("expected the map change to be written without a discontinuity, got %q", data)
+	}
+
+	if slice, err = m.SliceByTime(4, 12); err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := ParseHlsManifest(strings.TrimRight(slice.String(), "\n"))
+	if err != nil || slice.SegmentGroups[0].Continuous || parsed.String() != slice.String() {
+		t.Errorf("expected the slice starting at the map change to round-trip, got %q and %v", slice.String(), err)
+	}
+}
+
+func TestSliceByTimeInvalid(t *testing.T) {
+	m := sliceManifest()
+
+	for _, timeRange := range [][2]float64{{-1, 4}, {8, 8}, {8, 4}, {24, 30}, {math.NaN(), 4}} {
+		if _, err := m.SliceByTime(timeRange[0], timeRange[1]); !errors.Is(err, ErrInvalidTimeRange) {
+			t.Errorf("expected %v to be invalid, got %v", timeRange, err)
+		}
+	}
+}
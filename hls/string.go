@@ -1,7 +1,10 @@
 package hls
 
 import (
+	"bufio"
 	"errors"
+	"io"
+	"math"
 	"strconv"
 	"strings"
 )
@@ -44,6 +47,9 @@ var (
 
 	// ErrInvalidField indicates that a field is invalid.
 	ErrInvalidField = errors.New("invalid field")
+
+	// ErrInvalidDuration indicates that the duration of a segment is negative or not a finite number.
+	ErrInvalidDuration = errors.New("invalid segment duration")
 )
 
 // ParseError records a parsing error in a HLS manifest.
@@ -105,127 +111,423 @@ func parseUintValue(field string, line string, lineNumber int, bitSize int) (uin
 }
 
 // ParseHlsManifest parses a HLS manifest from a string and returns a Manifest object.
+//
+// The data is scanned once without splitting it into lines, the segments sharing a backing array sized by counting their tags, and the paths and titles referencing the data instead of copying it.
+// Any input, including data from untrusted origins, is either parsed or rejected with a ParseError, never causing a panic; blank lines and CRLF line endings are accepted and anything after EXT-X-ENDLIST is ignored.
+//
+// Unknown tags are rejected with ErrInvalidField, ParseOptions.Parse keeping them instead.
 func ParseHlsManifest(data string) (Manifest, error) {
-	lines := strings.Split(data, "\n")
-	manifest := Manifest{}
+	return parseManifest(data, ParseOptions{})
+}
 
-	declaration, lines := lines[0], lines[1:]
-	if declaration != DeclarationField {
-		return manifest, declarationError()
+// parseManifest parses a HLS manifest from a string as the options tell.
+func parseManifest(data string, options ParseOptions) (Manifest, error) {
+	parser := newManifestParser(strings.Count(data, SegmentField), strings.Count(data, DiscontinuityField)+1)
+	parser.options = options
+
+	for more := true; more && !parser.ended; {
+		var line string
+		line, data, more = strings.Cut(data, "\n")
+		if err := parser.parseLine(line); err != nil {
+			return parser.manifest, err
+		}
 	}
 
-	var tempSegmentGroup *SegmentGroup = nil
-	var tempSegment *Segment = nil
+	return parser.finish()
+}
 
-	for i, line := range lines {
-		lineNumber := i + 2
+// manifestParser parses a HLS manifest line by line.
+type manifestParser struct {
+	manifest   Manifest
+	segments   []Segment // segments of every group, sharing a backing array
+	groups     int       // capacity of the groups, allocated with the first one
+	pending    Segment   // segment whose path is the next line
+	hasPending bool      // whether a segment is waiting for its path
+	groupStart int       // index of the first segment of the open group, -1 when no group is open
+	keys       []Key     // keys applying to the next segments
+	byteRange  ByteRange // byte range of the next segment, zero when it is the whole resource
+	hasOffset  bool      // whether the byte range of the next segment has its offset
+	nextMap    Map       // initialization section applying to the next segments
+	groupMap   Map       // initialization section of the open group
+	continuous bool      // whether the open group continues the previous one, its initialization section changing without a discontinuity
+	unknown    []string  // unknown tags applying to the next segment, kept with ParseOptions.KeepUnknownTags
+	parts      []Part    // partial segments of the next segment
+	lastPart   *Part     // last partial segment parsed, nil before the first one
+	gap        bool      // whether the next segment is unavailable
+	options    ParseOptions
+	lineNumber int  // number of the last line parsed
+	ended      bool // whether the EXT-X-ENDLIST tag was parsed, ignoring the next lines
+}
 
-		if strings.HasPrefix(line, VersionField) {
-			version, err := parseUintValue(VersionField, line, lineNumber, 8)
-			if err != nil {
-				return manifest, err
-			}
+// newManifestParser returns a parser preallocating the segments and groups expected.
+func newManifestParser(segments int, groups int) *manifestParser {
+	return &manifestParser{segments: make([]Segment, 0, segments), groups: groups, groupStart: -1}
+}
 
-			manifest.Version = uint8(version)
-		} else if strings.HasPrefix(line, TargetDurationField) {
-			duration, err := parseUintValue(TargetDurationField, line, lineNumber, 8)
-			if err != nil {
-				return manifest, err
-			}
+// parseLine parses the next line of the manifest, without its line break.
+func (p *manifestParser) parseLine(line string) error {
+	p.lineNumber++
+	line = strings.TrimRight(line, "\r")
+	if p.lineNumber == 1 {
+		if line != DeclarationField {
+			return declarationError()
+		}
 
-			manifest.TargetDuration = uint8(duration)
-		} else if strings.HasPrefix(line, MediaSequenceField) {
-			mediaSequence, err := parseUintValue(MediaSequenceField, line, lineNumber, 32)
-			if err != nil {
-				return manifest, err
-			}
+		return nil
+	}
 
-			manifest.MediaSequence = uint32(mediaSequence)
-		} else if strings.HasPrefix(line, DiscontinuitySequenceField) {
-			discontinuitySequence, err := parseUintValue(DiscontinuitySequenceField, line, lineNumber, 32)
-			if err != nil {
-				return manifest, err
-			}
+	if line == "" {
+		return nil
+	}
 
-			manifest.DiscontinuitySequence = uint32(discontinuitySequence)
-		} else if strings.HasPrefix(line, SegmentField) {
-			if tempSegmentGroup == nil {
-				tempSegmentGroup = &SegmentGroup{}
-			}
+	// The tags are identified by their name, which ends at the colon separating the value, if any.
+	name := line
+	if strings.HasPrefix(line, "#EXT") {
+		if i := strings.IndexByte(line, ':'); i >= 0 {
+			name = line[:i]
+		}
+	}
 
-			if tempSegment != nil {
-				return manifest, segmentPathError(lineNumber)
-			}
-			tempSegment = &Segment{}
+	switch name {
+	case VersionField:
+		version, err := parseUintValue(VersionField, line, p.lineNumber, 8)
+		if err != nil {
+			return err
+		}
 
-			value := getValue(line)
-			durationValue, title, found := strings.Cut(value, ",")
-			if !found {
-				return manifest, valueError(SegmentField, lineNumber)
-			}
+		p.manifest.Version = uint8(version)
+	case TargetDurationField:
+		duration, err := parseUintValue(TargetDurationField, line, p.lineNumber, 8)
+		if err != nil {
+			return err
+		}
 
-			duration, err := strconv.ParseFloat(durationValue, 32)
-			if err != nil {
-				return manifest, fieldError(SegmentField, lineNumber, err)
-			}
+		p.manifest.TargetDuration = uint8(duration)
+	case MediaSequenceField:
+		mediaSequence, err := parseUintValue(MediaSequenceField, line, p.lineNumber, 32)
+		if err != nil {
+			return err
+		}
 
-			tempSegment.Duration = float32(duration)
-			tempSegment.Title = title
-		} else if strings.HasPrefix(line, DiscontinuityField) {
-			if tempSegmentGroup != nil {
-				manifest.SegmentGroups = append(manifest.SegmentGroups, *tempSegmentGroup)
-			}
+		p.manifest.MediaSequence = uint32(mediaSequence)
+	case DiscontinuitySequenceField:
+		discontinuitySequence, err := parseUintValue(DiscontinuitySequenceField, line, p.lineNumber, 32)
+		if err != nil {
+			return err
+		}
+
+		p.manifest.DiscontinuitySequence = uint32(discontinuitySequence)
+	case SegmentField:
+		if p.groupStart >= 0 && p.nextMap != p.groupMap {
+			// A group has a single initialization section, so a new one without a discontinuity starts a group continuing the open one.
+			p.closeGroup()
+			p.continuous = true
+		}
+
+		if p.groupStart < 0 {
+			p.groupStart, p.groupMap = len(p.segments), p.nextMap
+		}
+
+		if p.hasPending {
+			return segmentPathError(p.lineNumber)
+		}
+
+		durationValue, title, found := strings.Cut(getValue(line), ",")
+		if !found {
+			return valueError(SegmentField, p.lineNumber)
+		}
+
+		duration, err := strconv.ParseFloat(durationValue, 32)
+		if err != nil {
+			return fieldError(SegmentField, p.lineNumber, err)
+		}
+
+		if duration < 0 || math.IsNaN(duration) || math.IsInf(duration, 0) {
+			return fieldError(SegmentField, p.lineNumber, ErrInvalidDuration)
+		}
+
+		p.pending, p.hasPending = Segment{Duration: float32(duration), Title: title}, true
+	case DiscontinuityField:
+		p.closeGroup()
+	case KeyField:
+		key, err := parseKey(getValue(line))
+		if err != nil {
+			return fieldError(KeyField, p.lineNumber, err)
+		}
+
+		p.keys = applyKey(p.keys, key)
+	case MapField:
+		m, err := parseMap(getValue(line))
+		if err != nil {
+			return fieldError(MapField, p.lineNumber, err)
+		}
+
+		p.nextMap = m
+	case ByteRangeField:
+		byteRange, hasOffset, err := parseByteRange(getValue(line))
+		if err != nil {
+			return fieldError(ByteRangeField, p.lineNumber, err)
+		}
+
+		p.byteRange, p.hasOffset = byteRange, hasOffset
+	case PartField:
+		part, err := parsePart(getValue(line), p.lastPart)
+		if err != nil {
+			return fieldError(PartField, p.lineNumber, err)
+		}
+
+		p.parts = append(p.parts, part)
+		p.lastPart = &p.parts[len(p.parts)-1]
+	case PartInfField:
+		target, err := parsePartInf(getValue(line))
+		if err != nil {
+			return fieldError(PartInfField, p.lineNumber, err)
+		}
+
+		p.manifest.PartTarget = target
+	case ServerControlField:
+		control, err := parseServerControl(getValue(line))
+		if err != nil {
+			return fieldError(ServerControlField, p.lineNumber, err)
+		}
 
-			tempSegmentGroup = nil
-		} else if strings.HasPrefix(line, EndListField) {
-			manifest.HasEndList = true
-			break
-		} else {
-			if tempSegment != nil && tempSegmentGroup != nil {
-				tempSegment.Path = line
-				tempSegmentGroup.Segments = append(tempSegmentGroup.Segments, *tempSegment)
-				tempSegment = nil
+		p.manifest.ServerControl = control
+	case PreloadHintField:
+		hint, err := parsePreloadHint(getValue(line))
+		if err != nil {
+			return fieldError(PreloadHintField, p.lineNumber, err)
+		}
+
+		p.manifest.PreloadHints = append(p.manifest.PreloadHints, hint)
+	case SkipField:
+		skipped, err := parseSkip(getValue(line))
+		if err != nil {
+			return fieldError(SkipField, p.lineNumber, err)
+		}
+
+		p.manifest.SkippedSegments = skipped
+	case GapField:
+		p.gap = true
+	case EndListField:
+		p.manifest.HasEndList = true
+		p.ended = true
+	default:
+		if p.options.KeepUnknownTags && strings.HasPrefix(line, unknownTagPrefix) {
+			// The tags of the playlist, like EXT-X-PLAYLIST-TYPE, are kept with the manifest, the other ones applying to the next segment, like EXT-X-PROGRAM-DATE-TIME.
+			if isPlaylistTag(name) {
+				p.manifest.UnknownTags = append(p.manifest.UnknownTags, line)
 			} else {
-				return manifest, invalidFieldError(line, lineNumber)
+				p.unknown = append(p.unknown, line)
+			}
+
+			return nil
+		}
+
+		// Unknown tags and comments are never taken as the path of a segment.
+		if !p.hasPending || p.groupStart < 0 || line[0] == '#' {
+			return invalidFieldError(line, p.lineNumber)
+		}
+
+		p.pending.Path, p.pending.Keys, p.pending.UnknownTags, p.pending.Parts, p.pending.Gap = line, p.keys, p.unknown, p.parts, p.gap
+		p.unknown, p.parts, p.gap = nil, nil, false
+		if p.byteRange.Length > 0 {
+			if err := p.resolveByteRange(); err != nil {
+				return err
 			}
 		}
+
+		p.segments = append(p.segments, p.pending)
+		p.hasPending = false
+	}
+
+	return nil
+}
+
+// resolveByteRange sets the byte range of the pending segment, its offset defaulting to the end of the sub-range of the previous segment of the same resource.
+func (p *manifestParser) resolveByteRange() error {
+	p.pending.ByteRange = p.byteRange
+	if !p.hasOffset {
+		if len(p.segments) == 0 {
+			return fieldError(ByteRangeField, p.lineNumber, ErrInvalidByteRange)
+		}
+
+		previous := &p.segments[len(p.segments)-1]
+		if previous.ByteRange.Length == 0 || previous.Path != p.pending.Path {
+			return fieldError(ByteRangeField, p.lineNumber, ErrInvalidByteRange)
+		}
+
+		if previous.ByteRange.End() > math.MaxUint64-p.byteRange.Length {
+			return fieldError(ByteRangeField, p.lineNumber, ErrInvalidByteRange)
+		}
+
+		p.pending.ByteRange.Offset = previous.ByteRange.End()
 	}
 
-	if tempSegment != nil {
-		return manifest, segmentPathError(len(lines) + 1)
+	p.byteRange, p.hasOffset = ByteRange{}, false
+	return nil
+}
+
+// finish returns the manifest once every line is parsed, failing if a segment misses its path.
+func (p *manifestParser) finish() (Manifest, error) {
+	if p.lineNumber == 0 {
+		return p.manifest, declarationError()
+	}
+
+	if p.hasPending {
+		return p.manifest, segmentPathError(p.lineNumber + 1)
+	}
+
+	// The unknown tags following the last segment have no segment to apply to, so they are kept with the manifest.
+	p.manifest.UnknownTags = append(p.manifest.UnknownTags, p.unknown...)
+	p.manifest.Parts = p.parts
+	p.closeGroup()
+	return p.manifest, nil
+}
+
+// closeGroup closes the open group, if any, allocating the groups expected with the first one.
+func (p *manifestParser) closeGroup() {
+	if p.groupStart < 0 {
+		return
 	}
 
-	if tempSegmentGroup != nil {
-		manifest.SegmentGroups = append(manifest.SegmentGroups, *tempSegmentGroup)
+	if p.manifest.SegmentGroups == nil {
+		p.manifest.SegmentGroups = make([]SegmentGroup, 0, p.groups)
 	}
 
-	return manifest, nil
+	// The capacity of each group ends with it, so appending to a group never overwrites the next one.
+	end := len(p.segments)
+	p.manifest.SegmentGroups = append(p.manifest.SegmentGroups, SegmentGroup{Segments: p.segments[p.groupStart:end:end], Map: p.groupMap, Continuous: p.continuous})
+	p.groupStart, p.continuous = -1, false
 }
 
 // ToString returns the manifest as a string.
 func (m *Manifest) String() string {
 	var builder strings.Builder
+	builder.Grow(m.stringSize())
+	m.write(&builder)
+
+	return builder.String()
+}
+
+// WriteTo writes the manifest to the writer through a buffer, without building it whole in memory like String does, returning the number of bytes written.
+func (m *Manifest) WriteTo(w io.Writer) (int64, error) {
+	counter := &countingWriter{w: w}
+	buffered := bufio.NewWriterSize(counter, writeBufferSize)
+	m.write(buffered)
+
+	err := buffered.Flush()
+	return counter.n, err
+}
 
-	builder.WriteString(DeclarationField + "\n")
-	builder.WriteString(VersionField + ":" + strconv.FormatUint(uint64(m.Version), 10) + "\n")
-	builder.WriteString(TargetDurationField + ":" + strconv.FormatFloat(float64(m.TargetDuration), 'f', -1, 32) + "\n")
-	builder.WriteString(MediaSequenceField + ":" + strconv.FormatUint(uint64(m.MediaSequence), 10) + "\n")
-	builder.WriteString(DiscontinuitySequenceField + ":" + strconv.FormatUint(uint64(m.DiscontinuitySequence), 10) + "\n")
+// write writes the manifest as a string to the writer.
+func (m *Manifest) write(w manifestWriter) {
+	w.WriteString(DeclarationField + "\n")
+	w.WriteString(VersionField + ":" + strconv.FormatUint(uint64(m.Version), 10) + "\n")
+	w.WriteString(TargetDurationField + ":" + strconv.FormatFloat(float64(m.TargetDuration), 'f', -1, 32) + "\n")
+	w.WriteString(MediaSequenceField + ":" + strconv.FormatUint(uint64(m.MediaSequence), 10) + "\n")
+	w.WriteString(DiscontinuitySequenceField + ":" + strconv.FormatUint(uint64(m.DiscontinuitySequence), 10) + "\n")
+	if m.ServerControl != (ServerControl{}) {
+		w.WriteString(m.ServerControl.String() + "\n")
+	}
+
+	if m.PartTarget > 0 {
+		w.WriteString(PartInfField + ":PART-TARGET=" + formatSeconds(m.PartTarget) + "\n")
+	}
 
+	writeManifestTags(w, m.UnknownTags, true)
+	if m.SkippedSegments > 0 {
+		w.WriteString(SkipField + ":SKIPPED-SEGMENTS=" + strconv.FormatUint(uint64(m.SkippedSegments), 10) + "\n")
+	}
+
+	// Segments mostly share the same duration, so the last one formatted is kept and reused.
+	var duration []byte
+	lastDuration := float32(-1)
+
+	var keys []Key
+	var previous *Segment
+	var initialization Map
+	var part *Part
 	for i, segmentGroup := range m.SegmentGroups {
-		for _, segment := range segmentGroup.Segments {
-			builder.WriteString(SegmentField + ":" + strconv.FormatFloat(float64(segment.Duration), 'f', -1, 32) + "," + segment.Title + "\n")
-			builder.WriteString(segment.Path + "\n")
+		if i > 0 && !segmentGroup.Continuous {
+			w.WriteString(DiscontinuityField + "\n")
 		}
 
-		if i < len(m.SegmentGroups)-1 {
-			builder.WriteString(DiscontinuityField + "\n")
+		writeMap(w, initialization, segmentGroup.Map)
+		if segmentGroup.Map.URI != "" {
+			initialization = segmentGroup.Map
 		}
+
+		for j := range segmentGroup.Segments {
+			segment := &segmentGroup.Segments[j]
+			writeKeys(w, keys, segment.Keys)
+			keys = segment.Keys
+			writeUnknownTags(w, segment.UnknownTags)
+			part = writeParts(w, part, segment.Parts)
+			if segment.Gap {
+				w.WriteString(GapField + "\n")
+			}
+
+			if segment.Duration != lastDuration {
+				duration = strconv.AppendFloat(duration[:0], float64(segment.Duration), 'f', -1, 32)
+				lastDuration = segment.Duration
+			}
+
+			w.WriteString(SegmentField + ":")
+			w.Write(duration)
+			w.WriteByte(',')
+			w.WriteString(segment.Title)
+			w.WriteByte('\n')
+			writeByteRange(w, previous, segment)
+			w.WriteString(segment.Path)
+			w.WriteByte('\n')
+			previous = segment
+		}
+	}
+
+	writeParts(w, part, m.Parts)
+	for i := range m.PreloadHints {
+		w.WriteString(m.PreloadHints[i].String() + "\n")
 	}
 
+	writeManifestTags(w, m.UnknownTags, false)
 	if m.HasEndList {
-		builder.WriteString(EndListField + "\n")
+		w.WriteString(EndListField + "\n")
 	}
+}
 
-	return builder.String()
+// manifestWriter is the writer of the manifests, a strings.Builder for String and a bufio.Writer for WriteTo.
+type manifestWriter interface {
+	io.Writer
+	io.StringWriter
+	io.ByteWriter
+}
+
+// writeBufferSize is the size of the buffer of WriteTo, holding about a hundred segments.
+const writeBufferSize = 8 * 1024
+
+// countingWriter counts the bytes written to the underlying writer.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// stringSize estimates the length of the manifest as a string, leaving the keys, maps, byte ranges, unknown tags and low-latency tags out as they are rarely written.
+func (m *Manifest) stringSize() int {
+	// The header, written with the largest numbers, and the end list.
+	size := 128
+	for _, segmentGroup := range m.SegmentGroups {
+		size += len(DiscontinuityField) + 1
+		for _, segment := range segmentGroup.Segments {
+			// The tag, a duration of up to 12 characters, the separators and both line breaks.
+			size += len(SegmentField) + 15 + len(segment.Title) + len(segment.Path)
+		}
+	}
+
+	return size
 }
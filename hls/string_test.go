@@ -1,8 +1,12 @@
 package hls
 
 import (
+	"bytes"
+	"errors"
+	"io"
 	"os"
 	"strconv"
+	"strings"
 	"testing"
 )
 
@@ -124,3 +128,246 @@ func TestStream2(t *testing.T) {
 
 	testToString(t, m)
 }
+
+func TestParseDiscontinuities(t *testing.T) {
+	m, err := ParseHlsManifest("#EXTM3U\n#EXT-X-TARGETDURATION:4\n#EXTINF:4,first\na.ts\n#EXT-DISCONTINUITY\n#EXTINF:4,\nb.ts\n#EXTINF:2,\nc.ts")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(m.SegmentGroups) != 2 || len(m.SegmentGroups[0].Segments) != 1 || len(m.SegmentGroups[1].Segments) != 2 || m.SegmentGroups[0].Segments[0].Title != "first" {
+		t.Fatalf("expected two groups of 1 and 2 segments, got %+v", m.SegmentGroups)
+	}
+
+	m.SegmentGroups[0].Segments = append(m.SegmentGroups[0].Segments, Segment{Path: "x.ts"})
+	if m.SegmentGroups[1].Segments[0].Path != "b.ts" {
+		t.Errorf("expected appending to a group to keep the next one, got %s", m.SegmentGroups[1].Segments[0].Path)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for data, expected := range map[string]ParseError{
+		"#EXTM3U\n#EXTINF:4,\n#EXTINF:4,\na.ts":         {Field: SegmentField, Line: 3, Err: ErrSegmentPathMissing},
+		"#EXTM3U\n#EXTINF:4,\n#EXT-X-ENDLIST\n":         {Field: SegmentField, Line: 4, Err: ErrSegmentPathMissing},
+		"#EXTM3U\n#EXT-X-VERSION:\n":                    {Field: VersionField, Line: 2, Err: ErrFieldRequireValue},
+		"#EXTM3U\n#EXTINF:4\na.ts":                      {Field: SegmentField, Line: 2, Err: ErrFieldRequireValue},
+		"#EXTM3U\na.ts":                                 {Field: "a.ts", Line: 2, Err: ErrInvalidField},
+		"#EXTM3U\n#EXTINF:4,\n#EXT-DISCONTINUITY\na.ts": {Field: "a.ts", Line: 4, Err: ErrInvalidField},
+		"EXTM3U\n": {Field: DeclarationField, Line: 1, Err: ErrRequiredFieldMissing},
+	} {
+		_, err := ParseHlsManifest(data)
+		var parseErr *ParseError
+		if !errors.As(err, &parseErr) || *parseErr != expected {
+			t.Errorf("expected %v parsing %q, got %v", &expected, data, err)
+		}
+	}
+}
+
+func TestParseUntrusted(t *testing.T) {
+	for data, expected := range map[string]int{
+		"#EXTM3U":                           0,
+		"#EXTM3U\n":                         0,
+		"#EXTM3U\r\n#EXTINF:4,\r\na.ts\r\n": 1,
+		"#EXTM3U\n#EXTINF:4,\na.ts\n":       1,
+		"#EXTM3U\n\n#EXTINF:4,\n\na.ts\n\n": 1,
+		"#EXTM3U\n#EXTINF:4,\na.ts\n#EXT-X-ENDLIST\ngarbage\x00": 1,
+	} {
+		manifest, err := ParseHlsManifest(data)
+		if err != nil || manifest.SegmentCount() != expected {
+			t.Errorf("expected %d segments parsing %q, got %d and %v", expected, data, manifest.SegmentCount(), err)
+		}
+	}
+
+	for data, expected := range map[string]ParseError{
+		"":                                    {Field: DeclarationField, Line: 1, Err: ErrRequiredFieldMissing},
+		"\n#EXTM3U":                           {Field: DeclarationField, Line: 1, Err: ErrRequiredFieldMissing},
+		"#EXTM3U\n#EXTINF:-4,\na.ts":          {Field: SegmentField, Line: 2, Err: ErrInvalidDuration},
+		"#EXTM3U\n#EXTINF:NaN,\na.ts":         {Field: SegmentField, Line: 2, Err: ErrInvalidDuration},
+		"#EXTM3U\n#EXTINF:+Inf,\na.ts":        {Field: SegmentField, Line: 2, Err: ErrInvalidDuration},
+		"#EXTM3U\n#EXTINF:4,\n#EXT-X-UNKNOWN": {Field: "#EXT-X-UNKNOWN", Line: 3, Err: ErrInvalidField},
+		"#EXTM3U\n#EXT-X-KEY:METHOD=AES-128,URI=key\"": {Field: KeyField, Line: 2, Err: ErrInvalidAttributes},
+	} {
+		_, err := ParseHlsManifest(data)
+		var parseErr *ParseError
+		if !errors.As(err, &parseErr) || *parseErr != expected {
+			t.Errorf("expected %v parsing %q, got %v", &expected, data, err)
+		}
+	}
+}
+
+func FuzzParseHlsManifest(f *testing.F) {
+	for _, path := range []string{"../testdata/stream0.m3u8", "../testdata/stream1.m3u8", "../testdata/stream2.m3u8"} {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			f.Fatal(err)
+		}
+
+		f.Add(string(data))
+	}
+
+	f.Add("")
+	f.Add("#EXTM3U\r\n#EXT-X-MEDIA-SEQUENCE:4\r\n#EXTINF:4,title\r\na.ts\r\n")
+	f.Add("#EXTM3U\n#EXT-X-MAP:URI=\"init.mp4\",BYTERANGE=\"10@0\"\n#EXTINF:4,\na.m4s\n#EXT-DISCONTINUITY\n#EXT-X-MAP:URI=\"b.mp4\"\n#EXTINF:4,\nb.m4s\n")
+	f.Add("#EXTM3U\n#EXTINF:4,\n#EXT-X-BYTERANGE:100@0\nall.ts\n#EXT-X-BYTERANGE:50\n#EXTINF:4,\nall.ts\n")
+	f.Add("#EXTM3U\n#EXT-X-KEY:METHOD=AES-128,URI=\"key\",IV=0x1\n#EXTINF:4,\na.ts\n#EXT-DISCONTINUITY\n#EXT-X-KEY:METHOD=NONE\n#EXTINF:4,\nb.ts\n")
+	f.Add("#EXTM3U\n#EXT-X-SERVER-CONTROL:CAN-BLOCK-RELOAD=YES,PART-HOLD-BACK=3\n#EXT-X-PART-INF:PART-TARGET=1\n#EXT-X-SKIP:SKIPPED-SEGMENTS=2\n#EXT-X-PART:DURATION=1,URI=\"a.mp4\",BYTERANGE=\"10@0\"\n#EXTINF:4,\na.ts\n#EXT-X-PART:DURATION=1,URI=\"a.mp4\",BYTERANGE=\"10\",INDEPENDENT=YES\n#EXT-X-PRELOAD-HINT:TYPE=PART,URI=\"b.mp4\"\n")
+	f.Add("#EXTM3U\n#EXT-X-GAP\n#EXTINF:4,\na.ts\n#EXTINF:4,\n#EXT-X-GAP\nb.ts\n#EXT-X-GAP\n")
+	f.Add("#EXTM3U\n#EXT-X-PLAYLIST-TYPE:VOD\n#EXTINF:4,\na.ts\n#EXT-X-PROGRAM-DATE-TIME:2026-01-01T00:00:04Z\n#EXTINF:4,\n#EXT-X-BITRATE:800\nb.ts\n#EXT-X-CUSTOM\n")
+
+	f.Fuzz(func(t *testing.T, data string) {
+		for _, options := range []ParseOptions{{}, {KeepUnknownTags: true}} {
+			manifest, err := options.Parse(data)
+			if err != nil {
+				var parseErr *ParseError
+				if !errors.As(err, &parseErr) {
+					t.Fatalf("expected a ParseError, got %v", err)
+				}
+
+				continue
+			}
+
+			// A parsed manifest must survive being written and parsed again unchanged.
+			written := manifest.String()
+			reparsed, err := options.Parse(written)
+			if err != nil {
+				t.Fatalf("expected the written manifest to parse, got %v parsing %q", err, written)
+			}
+
+			if rewritten := reparsed.String(); rewritten != written {
+				t.Fatalf("expected the manifest to be written the same, got %q and %q", written, rewritten)
+			}
+		}
+	})
+}
+
+func TestWriteTo(t *testing.T) {
+	// The manifest is larger than the buffer, so it is written in several chunks.
+	manifest, err := ParseHlsManifest(generateManifest(3000))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buffer bytes.Buffer
+	n, err := manifest.WriteTo(&buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := manifest.String(); buffer.String() != expected || n != int64(len(expected)) {
+		t.Errorf("expected %d bytes written as String returns them, got %d", len(expected), n)
+	}
+}
+
+// failingWriter accepts limit bytes before failing
+type failingWriter struct {
+	limit int
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if len(p) > w.limit {
+		n := w.limit
+		w.limit = 0
+		return n, io.ErrShortWrite
+	}
+
+	w.limit -= len(p)
+	return len(p), nil
+}
+
+func TestWriteToError(t *testing.T) {
+	manifest, err := ParseHlsManifest(generateManifest(3000))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := manifest.WriteTo(&failingWriter{limit: 10000})
+	if !errors.Is(err, io.ErrShortWrite) || n != 10000 {
+		t.Errorf("expected %v after 10000 bytes, got %v after %d", io.ErrShortWrite, err, n)
+	}
+}
+
+func TestStringSize(t *testing.T) {
+	manifest, err := ParseHlsManifest(generateManifest(3000))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	manifest.Version, manifest.MediaSequence, manifest.DiscontinuitySequence = 7, 1<<32-1, 1<<32-1
+	if length, size := len(manifest.String()), manifest.stringSize(); length > size {
+		t.Errorf("expected the estimate to cover the %d bytes written, got %d", length, size)
+	}
+}
+
+// benchmarkSizes are the segment counts of the small, medium and huge playlists benchmarked
+var benchmarkSizes = []int{10, 1000, 50000}
+
+// benchmarkManifest parses a generated playlist of the given size
+func benchmarkManifest(b *testing.B, segments int) Manifest {
+	b.Helper()
+
+	manifest, err := ParseHlsManifest(generateManifest(segments))
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	return manifest
+}
+
+// generateManifest returns a VOD manifest with the number of segments, with a discontinuity every thousand segments
+func generateManifest(segments int) string {
+	var builder strings.Builder
+	builder.WriteString("#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-TARGETDURATION:4\n#EXT-X-MEDIA-SEQUENCE:0\n")
+	for i := range segments {
+		if i > 0 && i%1000 == 0 {
+			builder.WriteString(DiscontinuityField + "\n")
+		}
+
+		builder.WriteString("#EXTINF:3.966667,\nsegments/" + strconv.Itoa(i) + ".ts\n")
+	}
+	builder.WriteString(EndListField + "\n")
+
+	return builder.String()
+}
+
+func BenchmarkParseHlsManifest(b *testing.B) {
+	for _, size := range benchmarkSizes {
+		data := generateManifest(size)
+		b.Run(strconv.Itoa(size), func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(data)))
+			for b.Loop() {
+				if _, err := ParseHlsManifest(data); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkString(b *testing.B) {
+	for _, size := range benchmarkSizes {
+		manifest := benchmarkManifest(b, size)
+		b.Run(strconv.Itoa(size), func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(manifest.String())))
+			for b.Loop() {
+				_ = manifest.String()
+			}
+		})
+	}
+}
+
+func BenchmarkWriteTo(b *testing.B) {
+	for _, size := range benchmarkSizes {
+		manifest := benchmarkManifest(b, size)
+		b.Run(strconv.Itoa(size), func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(manifest.String())))
+			for b.Loop() {
+				if _, err := manifest.WriteTo(io.Discard); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package hls
+
+import (
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+)
+
+// subtitlesExtensions are the extensions of the WebVTT segments of the subtitles media manifests.
+var subtitlesExtensions = []string{".vtt", ".webvtt"}
+
+// IsSubtitles returns true if every segment of the manifest is a WebVTT file, like the media manifest of a subtitles rendition.
+func (m *Manifest) IsSubtitles() bool {
+	if m.SegmentCount() == 0 {
+		return false
+	}
+
+	for _, group := range m.SegmentGroups {
+		for _, segment := range group.Segments {
+			name := segment.Path
+			if u, err := url.Parse(segment.Path); err == nil {
+				name = u.Path
+			}
+
+			extension := strings.ToLower(path.Ext(name))
+			if !slices.Contains(subtitlesExtensions, extension) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// AddSubtitles appends the rendition as a subtitles rendition of the manifest, offering its group with every variant which offers no other group, so the players list it with the captions.
+func (m *MasterManifest) AddSubtitles(rendition Rendition) {
+	rendition.Type = MediaSubtitles
+	m.Renditions = append(m.Renditions, rendition)
+
+	for i := range m.Variants {
+		if m.Variants[i].Subtitles == "" {
+			m.Variants[i].Subtitles = rendition.GroupID
+		}
+	}
+}
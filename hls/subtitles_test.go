@@ -0,0 +1,43 @@
+package hls
+
+import "testing"
+
+func TestIsSubtitles(t *testing.T) {
+	data := "#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-TARGETDURATION:4\n#EXT-X-MEDIA-SEQUENCE:0\n#EXTINF:4.000000,\nsegment0.vtt\n#EXTINF:4.000000,\nhttps://cdn.example.com/segment1.WEBVTT?token=a\n#EXT-X-ENDLIST\n"
+
+	m, err := ParseHlsManifest(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !m.IsSubtitles() {
+		t.Errorf("expected the WebVTT segments to be subtitles")
+	}
+
+	m.SegmentGroups[0].Segments[1].Path = "segment1.ts"
+	if m.IsSubtitles() {
+		t.Errorf("expected the media segment not to be subtitles")
+	}
+
+	if empty := (Manifest{}); empty.IsSubtitles() {
+		t.Errorf("expected the empty manifest not to be subtitles")
+	}
+}
+
+func TestAddSubtitles(t *testing.T) {
+	m := MasterManifest{Variants: []Variant{{URI: "1080p.m3u8", Bandwidth: 5000000}, {URI: "720p.m3u8", Bandwidth: 3000000, Subtitles: "other"}}}
+	m.AddSubtitles(Rendition{GroupID: "subs", Name: "Japanese", Language: "ja", URI: "subs/ja.m3u8", AutoSelect: true, Forced: true})
+
+	expected := "#EXTM3U\n" +
+		"#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID=\"subs\",NAME=\"Japanese\",LANGUAGE=\"ja\",DEFAULT=NO,AUTOSELECT=YES,FORCED=YES,URI=\"subs/ja.m3u8\"\n" +
+		"#EXT-X-STREAM-INF:BANDWIDTH=5000000,SUBTITLES=\"subs\"\n1080p.m3u8\n" +
+		"#EXT-X-STREAM-INF:BANDWIDTH=3000000,SUBTITLES=\"other\"\n720p.m3u8\n"
+	if m.String() != expected {
+		t.Errorf("expected %q, got %q", expected, m.String())
+	}
+
+	parsed, err := ParseMasterManifest(expected)
+	if err != nil || parsed.Renditions[0].Type != MediaSubtitles || !parsed.Renditions[0].Forced || len(parsed.Renditions[0].Attributes) != 0 {
+		t.Errorf("expected the forced subtitles rendition, got %+v and %v", parsed.Renditions, err)
+	}
+}
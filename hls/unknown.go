@@ -0,0 +1,62 @@
+package hls
+
+import (
+	"io"
+	"strings"
+)
+
+// unknownTagPrefix is the prefix of the tags kept by ParseOptions.KeepUnknownTags, the other unknown lines being rejected as before.
+const unknownTagPrefix = "#EXT-X-"
+
+// playlistTags are the unknown tags which apply to the whole playlist, kept with the manifest wherever they appear, the other unknown tags applying to the segment following them.
+var playlistTags = map[string]bool{
+	"#EXT-X-PLAYLIST-TYPE":        true,
+	"#EXT-X-I-FRAMES-ONLY":        true,
+	"#EXT-X-INDEPENDENT-SEGMENTS": true,
+	"#EXT-X-START":                true,
+	"#EXT-X-ALLOW-CACHE":          true,
+	"#EXT-X-DEFINE":               true,
+	"#EXT-X-CONTENT-STEERING":     true,
+	"#EXT-X-SESSION-DATA":         true,
+	"#EXT-X-SESSION-KEY":          true,
+}
+
+// isPlaylistTag returns true if the tag, named without its value, applies to the whole playlist.
+func isPlaylistTag(name string) bool {
+	return playlistTags[name]
+}
+
+// ParseOptions represents how a HLS manifest is parsed, the zero value parsing it as ParseHlsManifest does.
+type ParseOptions struct {
+	// KeepUnknownTags keeps the unknown EXT-X tags in the UnknownTags of the manifest or of the segment following them, written back by String, instead of failing with ErrInvalidField.
+	KeepUnknownTags bool
+}
+
+// Parse parses a HLS manifest from a string as ParseHlsManifest does, following the options.
+func (o ParseOptions) Parse(data string) (Manifest, error) {
+	return parseManifest(data, o)
+}
+
+// ParseReader parses a HLS manifest line by line from a reader as ParseHlsManifestReader does, following the options.
+func (o ParseOptions) ParseReader(r io.Reader) (Manifest, error) {
+	return parseManifestReader(r, o)
+}
+
+// writeUnknownTags writes the unknown tags, one per line, as they were parsed.
+func writeUnknownTags(w manifestWriter, tags []string) {
+	for _, tag := range tags {
+		w.WriteString(tag)
+		w.WriteByte('\n')
+	}
+}
+
+// writeManifestTags writes the unknown tags of the manifest which apply to the whole playlist, written after the header, or the other ones, which followed the last segment and are written after it.
+func writeManifestTags(w manifestWriter, tags []string, header bool) {
+	for _, tag := range tags {
+		name, _, _ := strings.Cut(tag, ":")
+		if isPlaylistTag(name) == header {
+			w.WriteString(tag)
+			w.WriteByte('\n')
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package hls
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// unknownManifest is a VOD with tags the parser does not model, ahead of the manifest, of its segments and after them
+const unknownManifest = `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-TARGETDURATION:4
+#EXT-X-MEDIA-SEQUENCE:0
+#EXT-X-DISCONTINUITY-SEQUENCE:0
+#EXT-X-PLAYLIST-TYPE:VOD
+#EXT-X-INDEPENDENT-SEGMENTS
+#EXTINF:4,
+a.ts
+#EXT-X-PROGRAM-DATE-TIME:2026-01-01T00:00:04Z
+#EXTINF:4,
+#EXT-X-BITRATE:800
+b.ts
+#EXT-X-CUSTOM
+#EXT-X-ENDLIST
+`
+
+func TestParseUnknownTags(t *testing.T) {
+	manifest, err := ParseOptions{KeepUnknownTags: true}.Parse(unknownManifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := []string{"#EXT-X-PLAYLIST-TYPE:VOD", "#EXT-X-INDEPENDENT-SEGMENTS", "#EXT-X-CUSTOM"}; !slices.Equal(manifest.UnknownTags, expected) {
+		t.Errorf("expected the unknown tags of the manifest to be %q, got %q", expected, manifest.UnknownTags)
+	}
+
+	segments := manifest.SegmentGroups[0].Segments
+	if len(segments[0].UnknownTags) != 0 {
+		t.Errorf("expected the first segment to have no unknown tags, got %q", segments[0].UnknownTags)
+	}
+
+	if expected := []string{"#EXT-X-PROGRAM-DATE-TIME:2026-01-01T00:00:04Z", "#EXT-X-BITRATE:800"}; !slices.Equal(segments[1].UnknownTags, expected) {
+		t.Errorf("expected the unknown tags of the second segment to be %q, got %q", expected, segments[1].UnknownTags)
+	}
+
+	output := manifest.String()
+	if !strings.Contains(output, "#EXT-X-PROGRAM-DATE-TIME:2026-01-01T00:00:04Z\n#EXT-X-BITRATE:800\n#EXTINF:4,\nb.ts\n") {
+		t.Errorf("expected the unknown tags to be written before the segment, got %s", output)
+	}
+
+	reparsed, err := ParseOptions{KeepUnknownTags: true}.ParseReader(strings.NewReader(output))
+	if err != nil || reparsed.String() != output {
+		t.Errorf("expected the manifest to round-trip, got %v", err)
+	}
+}
+
+func TestParseUnknownTagsStrict(t *testing.T) {
+	if _, err := ParseHlsManifest(unknownManifest); !errors.Is(err, ErrInvalidField) {
+		t.Errorf("expected %v, got %v", ErrInvalidField, err)
+	}
+
+	// Only the EXT-X tags are kept, the other unknown lines still being rejected.
+	for _, data := range []string{"#EXTM3U\n#EXTINF:4,\na.ts\n# comment\n", "#EXTM3U\n#EXT-CUSTOM\n#EXTINF:4,\na.ts\n"} {
+		if _, err := (ParseOptions{KeepUnknownTags: true}).Parse(data); !errors.Is(err, ErrInvalidField) {
+			t.Errorf("expected %v parsing %q, got %v", ErrInvalidField, data, err)
+		}
+	}
+}
+
+func TestCloneUnknownTags(t *testing.T) {
+	manifest, err := ParseOptions{KeepUnknownTags: true}.Parse(unknownManifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clone := manifest.Clone()
+	clone.UnknownTags[0] = "#EXT-X-PLAYLIST-TYPE:EVENT"
+	clone.SegmentGroups[0].Segments[1].UnknownTags[0] = "#EXT-X-GAP"
+	if manifest.UnknownTags[0] != "#EXT-X-PLAYLIST-TYPE:VOD" || manifest.SegmentGroups[0].Segments[1].UnknownTags[0] == "#EXT-X-GAP" {
+		t.Error("expected the clone to not share the unknown tags")
+	}
+}
+
+func TestParseUnknownTagsFirstSegment(t *testing.T) {
+	data := "#EXTM3U\n#EXT-X-PLAYLIST-TYPE:EVENT\n#EXT-X-PROGRAM-DATE-TIME:2026-01-01T00:00:00Z\n#EXTINF:4,\na.ts\n#EXT-X-PROGRAM-DATE-TIME:2026-01-01T00:00:04Z\n#EXTINF:4,\nb.ts\n"
+	manifest, err := ParseOptions{KeepUnknownTags: true}.Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := []string{"#EXT-X-PLAYLIST-TYPE:EVENT"}; !slices.Equal(manifest.UnknownTags, expected) {
+		t.Errorf("expected only the tags of the playlist in the manifest, got %q", manifest.UnknownTags)
+	}
+
+	// The date of the first segment leaves with it instead of dating the next one.
+	manifest.RemoveFromStart(1)
+	if output := manifest.String(); strings.Count(output, "PROGRAM-DATE-TIME") != 1 || !strings.Contains(output, "#EXT-X-PROGRAM-DATE-TIME:2026-01-01T00:00:04Z\n#EXTINF:4,\nb.ts\n") {
+		t.Errorf("expected the second segment with its own date, got %s", output)
+	}
+}
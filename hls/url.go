@@ -0,0 +1,44 @@
+package hls
+
+import "net/url"
+
+// RewriteSegmentURLs replaces the paths of the segments, the URIs of their initialization sections and partial segments, and the URIs of the preload hints, like to serve them from the routes of the local cache.
+func (m *Manifest) RewriteSegmentURLs(rewrite func(uri string) string) {
+	rewriteParts := func(parts []Part) {
+		for i := range parts {
+			parts[i].URI = rewrite(parts[i].URI)
+		}
+	}
+
+	for i := range m.SegmentGroups {
+		group := &m.SegmentGroups[i]
+		if group.Map.URI != "" {
+			group.Map.URI = rewrite(group.Map.URI)
+		}
+
+		for j := range group.Segments {
+			group.Segments[j].Path = rewrite(group.Segments[j].Path)
+			rewriteParts(group.Segments[j].Parts)
+		}
+	}
+
+	rewriteParts(m.Parts)
+	for i := range m.PreloadHints {
+		m.PreloadHints[i].URI = rewrite(m.PreloadHints[i].URI)
+	}
+}
+
+// ResolveAgainst resolves the relative paths of the segments and URIs of the initialization sections, partial segments, preload hints and keys against the base, like the URL of the playlist, leaving the ones that are not valid references as they are.
+func (m *Manifest) ResolveAgainst(base *url.URL) {
+	resolve := func(ref string) string {
+		u, err := base.Parse(ref)
+		if err != nil {
+			return ref
+		}
+
+		return u.String()
+	}
+
+	m.RewriteSegmentURLs(resolve)
+	m.RewriteKeyURIs(resolve)
+}
@@ -0,0 +1,97 @@
+package hls
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// remoteManifest is a playlist with relative, absolute and DRM references
+const remoteManifest = `#EXTM3U
+#EXT-X-VERSION:6
+#EXT-X-TARGETDURATION:4
+#EXT-X-MAP:URI="init.mp4"
+#EXT-X-KEY:METHOD=AES-128,URI="../keys/1"
+#EXTINF:4,
+0.m4s
+#EXTINF:4,
+https://cdn.example/1.m4s
+#EXT-DISCONTINUITY
+#EXT-X-KEY:METHOD=SAMPLE-AES,URI="skd://asset",KEYFORMAT="com.apple.streamingkeydelivery",KEYFORMATVERSIONS="1"
+#EXTINF:4,
+/absolute/2.m4s
+#EXT-X-ENDLIST`
+
+func TestRewriteSegmentURLs(t *testing.T) {
+	manifest, err := ParseHlsManifest(remoteManifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	manifest.RewriteSegmentURLs(func(uri string) string {
+		return "/streams/channel/" + url.PathEscape(uri)
+	})
+
+	group := manifest.SegmentGroups[0]
+	if group.Map.URI != "/streams/channel/init.mp4" || group.Segments[0].Path != "/streams/channel/0.m4s" || group.Segments[1].Path != "/streams/channel/https:%2F%2Fcdn.example%2F1.m4s" {
+		t.Errorf("expected the segments and initialization section to be rewritten, got %+v", group)
+	}
+
+	if group.Segments[0].Keys[0].URI != "../keys/1" {
+		t.Errorf("expected the keys to be left as they are, got %+v", group.Segments[0].Keys)
+	}
+}
+
+func TestResolveAgainst(t *testing.T) {
+	manifest, err := ParseHlsManifest(remoteManifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	base, _ := url.Parse("https://origin.example/media/video/index.m3u8")
+	manifest.ResolveAgainst(base)
+
+	first, second := manifest.SegmentGroups[0], manifest.SegmentGroups[1]
+	for _, test := range []struct{ got, expected string }{
+		{first.Map.URI, "https://origin.example/media/video/init.mp4"},
+		{first.Segments[0].Path, "https://origin.example/media/video/0.m4s"},
+		{first.Segments[1].Path, "https://cdn.example/1.m4s"},
+		{first.Segments[0].Keys[0].URI, "https://origin.example/media/keys/1"},
+		{second.Map.URI, "https://origin.example/media/video/init.mp4"},
+		{second.Segments[0].Path, "https://origin.example/absolute/2.m4s"},
+		{second.Segments[0].Keys[1].URI, "skd://asset"},
+	} {
+		if test.got != test.expected {
+			t.Errorf("expected %s, got %s", test.expected, test.got)
+		}
+	}
+
+	if output := manifest.String(); strings.Count(output, MapField) != 1 {
+		t.Errorf("expected the resolved initialization sections to be written once, got %s", output)
+	}
+}
+
+func TestResolveAgainstParts(t *testing.T) {
+	manifest, err := ParseHlsManifest(lowLatencyManifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	base, _ := url.Parse("https://origin.example/live/index.m3u8")
+	manifest.ResolveAgainst(base)
+
+	segments := manifest.SegmentGroups[0].Segments
+	if len(segments) != 2 || len(segments[1].Parts) != 4 || len(manifest.Parts) != 2 || len(manifest.PreloadHints) != 1 {
+		t.Fatalf("expected the parts of the last segment, the pending parts and a preload hint, got %+v", manifest)
+	}
+
+	for _, test := range []struct{ got, expected string }{
+		{segments[1].Parts[3].URI, "https://origin.example/live/107.part3.ts"},
+		{manifest.Parts[1].URI, "https://origin.example/live/108.mp4"},
+		{manifest.PreloadHints[0].URI, "https://origin.example/live/108.mp4"},
+	} {
+		if test.got != test.expected {
+			t.Errorf("expected %s, got %s", test.expected, test.got)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package hls
+
+import (
+	"math"
+	"strconv"
+)
+
+// Severity represents how serious a validation issue is.
+type Severity string
+
+const (
+	// SeverityError indicates that the manifest breaks a requirement of the HLS specification, players being allowed to reject it.
+	SeverityError Severity = "error"
+
+	// SeverityWarning indicates that the manifest follows the specification but is likely to play badly.
+	SeverityWarning Severity = "warning"
+)
+
+// Issue represents a problem found in a manifest by Validate.
+type Issue struct {
+	Severity Severity // How serious the issue is
+	Segment  int      // Index of the segment the issue is about, counted across the segment groups, negative for the whole manifest
+	Message  string   // Description of the issue
+}
+
+// Validate checks the manifest against the requirements of the HLS specification, like the target duration and the version required by the tags used, returning the issues found in order.
+func (m *Manifest) Validate() []Issue {
+	var issues []Issue
+	manifestIssue := func(severity Severity, message string) {
+		issues = append(issues, Issue{Severity: severity, Segment: -1, Message: message})
+	}
+
+	if m.TargetDuration == 0 {
+		manifestIssue(SeverityError, "missing target duration")
+	}
+
+	if m.SegmentCount() == 0 {
+		manifestIssue(SeverityWarning, "no segments")
+	}
+
+	required, requiredBy := uint8(1), ""
+	require := func(version uint8, feature string) {
+		if version > required {
+			required, requiredBy = version, feature
+		}
+	}
+
+	index := 0
+	for i, group := range m.SegmentGroups {
+		if len(group.Segments) == 0 && i > 0 {
+			manifestIssue(SeverityWarning, "empty discontinuity "+strconv.Itoa(i))
+		}
+
+		if group.Map.URI != "" {
+			require(6, "the EXT-X-MAP tag")
+		}
+
+		for _, segment := range group.Segments {
+			if segment.Duration <= 0 {
+				issues = append(issues, Issue{Severity: SeverityError, Segment: index, Message: "non-positive duration"})
+			} else if m.TargetDuration > 0 && segment.TargetDuration() > m.TargetDuration {
+				issues = append(issues, Issue{Severity: SeverityError, Segment: index, Message: "duration " + strconv.FormatFloat(float64(segment.Duration), 'f', -1, 32) + "s exceeds the target duration of " + strconv.Itoa(int(m.TargetDuration)) + "s"})
+			}
+
+			if float64(segment.Duration) != math.Trunc(float64(segment.Duration)) {
+				require(3, "decimal segment durations")
+			}
+
+			if segment.ByteRange.Length > 0 {
+				require(4, "the EXT-X-BYTERANGE tag")
+			}
+
+			partIssues(&issues, m.PartTarget, index, segment.Parts)
+			for _, key := range segment.Keys {
+				if key.IV != "" {
+					require(2, "the IV attribute")
+				}
+
+				if key.KeyFormat != "" || key.KeyFormatVersions != "" {
+					require(5, "the KEYFORMAT attribute")
+				}
+
+				if key.Method == MethodSampleAES {
+					require(5, "the SAMPLE-AES method")
+				}
+			}
+
+			index++
+		}
+	}
+
+	partIssues(&issues, m.PartTarget, -1, m.Parts)
+	if m.PartTarget > 0 && m.ServerControl.PartHoldBack < 2*m.PartTarget {
+		manifestIssue(SeverityError, "part hold back lower than twice the part target")
+	}
+
+	if m.SkippedSegments > 0 {
+		require(9, "the EXT-X-SKIP tag")
+		if m.ServerControl.CanSkipUntil <= 0 {
+			manifestIssue(SeverityError, "skipped segments without the CAN-SKIP-UNTIL attribute")
+		}
+	}
+
+	if version := max(m.Version, 1); version < required {
+		manifestIssue(SeverityError, "version "+strconv.Itoa(int(version))+" is lower than the version "+strconv.Itoa(int(required))+" required by "+requiredBy)
+	}
+
+	if !m.HasEndList && m.TargetDuration > 0 && m.Duration() < 3*float64(m.TargetDuration) {
+		manifestIssue(SeverityWarning, "live playlist shorter than three target durations, players may stall")
+	}
+
+	return issues
+}
+
+// partIssues appends the issues of the partial segments of the segment at the index, negative for the segment being produced, to the issues.
+func partIssues(issues *[]Issue, partTarget float32, index int, parts []Part) {
+	for _, part := range parts {
+		if partTarget <= 0 {
+			*issues = append(*issues, Issue{Severity: SeverityError, Segment: index, Message: "partial segments without the EXT-X-PART-INF tag"})
+			return
+		}
+
+		if part.Duration > partTarget {
+			*issues = append(*issues, Issue{Severity: SeverityError, Segment: index, Message: "partial segment duration " + formatSeconds(part.Duration) + "s exceeds the part target of " + formatSeconds(partTarget) + "s"})
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package hls
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	data, err := os.ReadFile("../testdata/stream0.m3u8")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	manifest, err := ParseHlsManifest(strings.TrimRight(string(data), "\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if issues := manifest.Validate(); len(issues) != 0 {
+		t.Errorf("expected no issues, got %+v", issues)
+	}
+
+	broken := Manifest{TargetDuration: 4, SegmentGroups: []SegmentGroup{
+		{Segments: []Segment{{Path: "0.ts", Duration: 6.5}, {Path: "1.ts", Duration: 0}}},
+		{},
+		{Segments: []Segment{{Path: "2.ts", Duration: 4, Keys: []Key{{Method: MethodAES128, URI: "key", IV: "0x01"}}}}},
+	}}
+
+	issues := broken.Validate()
+	var messages []string
+	for _, issue := range issues {
+		messages = append(messages, string(issue.Severity)+" "+strings.Fields(issue.Message)[0])
+	}
+
+	expected := "error duration|error non-positive|warning empty|error version|warning live"
+	if strings.Join(messages, "|") != expected {
+		t.Errorf("expected %s, got %s", expected, strings.Join(messages, "|"))
+	}
+
+	if issues[0].Segment != 0 || issues[1].Segment != 1 || issues[2].Segment >= 0 {
+		t.Errorf("expected the segments of the issues, got %+v", issues)
+	}
+
+	if !strings.Contains(issues[3].Message, "version 3 required by decimal segment durations") {
+		t.Errorf("expected the highest required version, got %s", issues[3].Message)
+	}
+}
@@ -0,0 +1,204 @@
+package ingest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"slices"
+)
+
+// AMF0 type markers used by the RTMP commands.
+const (
+	amfNumber      = 0x00
+	amfBoolean     = 0x01
+	amfString      = 0x02
+	amfObject      = 0x03
+	amfNull        = 0x05
+	amfUndefined   = 0x06
+	amfECMAArray   = 0x08
+	amfObjectEnd   = 0x09
+	amfStrictArray = 0x0a
+	amfDate        = 0x0b
+	amfLongString  = 0x0c
+)
+
+// errAMFType indicates that an AMF0 value has a type not supported by the decoder.
+var errAMFType = errors.New("unsupported AMF0 type")
+
+// amfDecodeAll decodes every AMF0 value in the data, as float64, bool, string, map[string]any, []any or nil.
+func amfDecodeAll(data []byte) ([]any, error) {
+	reader := bytes.NewReader(data)
+
+	var values []any
+	for reader.Len() > 0 {
+		value, err := amfDecode(reader)
+		if err != nil {
+			return values, err
+		}
+
+		values = append(values, value)
+	}
+
+	return values, nil
+}
+
+// amfDecode decodes a single AMF0 value.
+func amfDecode(reader *bytes.Reader) (any, error) {
+	marker, err := reader.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	switch marker {
+	case amfNumber:
+		var bits uint64
+		err := binary.Read(reader, binary.BigEndian, &bits)
+		return math.Float64frombits(bits), err
+	case amfBoolean:
+		value, err := reader.ReadByte()
+		return value != 0, err
+	case amfString:
+		return amfDecodeString(reader, 2)
+	case amfLongString:
+		return amfDecodeString(reader, 4)
+	case amfObject:
+		return amfDecodeProperties(reader)
+	case amfECMAArray:
+		if _, err := reader.Seek(4, io.SeekCurrent); err != nil {
+			return nil, err
+		}
+		return amfDecodeProperties(reader)
+	case amfStrictArray:
+		var count uint32
+		if err := binary.Read(reader, binary.BigEndian, &count); err != nil {
+			return nil, err
+		}
+
+		values := []any{}
+		for range count {
+			value, err := amfDecode(reader)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+		}
+		return values, nil
+	case amfDate:
+		var bits uint64
+		if err := binary.Read(reader, binary.BigEndian, &bits); err != nil {
+			return nil, err
+		}
+		_, err := reader.Seek(2, io.SeekCurrent)
+		return math.Float64frombits(bits), err
+	case amfNull, amfUndefined:
+		return nil, nil
+	default:
+		return nil, errAMFType
+	}
+}
+
+// amfDecodeString decodes a string prefixed by its length of the given size in bytes.
+func amfDecodeString(reader *bytes.Reader, lengthSize int) (string, error) {
+	var length uint32
+	if lengthSize == 2 {
+		var short uint16
+		if err := binary.Read(reader, binary.BigEndian, &short); err != nil {
+			return "", err
+		}
+		length = uint32(short)
+	} else if err := binary.Read(reader, binary.BigEndian, &length); err != nil {
+		return "", err
+	}
+
+	if int64(length) > int64(reader.Len()) {
+		return "", io.ErrUnexpectedEOF
+	}
+
+	data := make([]byte, length)
+	_, err := io.ReadFull(reader, data)
+	return string(data), err
+}
+
+// amfDecodeProperties decodes the properties of an object until the object end marker.
+func amfDecodeProperties(reader *bytes.Reader) (map[string]any, error) {
+	properties := map[string]any{}
+
+	for {
+		key, err := amfDecodeString(reader, 2)
+		if err != nil {
+			return nil, err
+		}
+
+		if key == "" {
+			marker, err := reader.ReadByte()
+			if err != nil {
+				return nil, err
+			}
+
+			if marker == amfObjectEnd {
+				return properties, nil
+			}
+
+			reader.UnreadByte()
+		}
+
+		value, err := amfDecode(reader)
+		if err != nil {
+			return nil, err
+		}
+
+		properties[key] = value
+	}
+}
+
+// amfEncode encodes the values, which must be float64, int, bool, string, map[string]any, []any or nil.
+func amfEncode(buffer *bytes.Buffer, values ...any) {
+	for _, value := range values {
+		switch value := value.(type) {
+		case float64:
+			buffer.WriteByte(amfNumber)
+			binary.Write(buffer, binary.BigEndian, math.Float64bits(value))
+		case int:
+			amfEncode(buffer, float64(value))
+		case bool:
+			buffer.WriteByte(amfBoolean)
+			if value {
+				buffer.WriteByte(1)
+			} else {
+				buffer.WriteByte(0)
+			}
+		case string:
+			if len(value) > math.MaxUint16 {
+				buffer.WriteByte(amfLongString)
+				binary.Write(buffer, binary.BigEndian, uint32(len(value)))
+			} else {
+				buffer.WriteByte(amfString)
+				binary.Write(buffer, binary.BigEndian, uint16(len(value)))
+			}
+			buffer.WriteString(value)
+		case map[string]any:
+			buffer.WriteByte(amfObject)
+
+			keys := make([]string, 0, len(value))
+			for key := range value {
+				keys = append(keys, key)
+			}
+			slices.Sort(keys)
+
+			for _, key := range keys {
+				binary.Write(buffer, binary.BigEndian, uint16(len(key)))
+				buffer.WriteString(key)
+				amfEncode(buffer, value[key])
+			}
+			buffer.Write([]byte{0, 0, amfObjectEnd})
+		case []any:
+			buffer.WriteByte(amfStrictArray)
+			binary.Write(buffer, binary.BigEndian, uint32(len(value)))
+			amfEncode(buffer, value...)
+		default:
+			buffer.WriteByte(amfNull)
+		}
+	}
+}
@@ -0,0 +1,262 @@
+package ingest
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// RTMP message types handled by the ingest.
+const (
+	msgSetChunkSize     = 1
+	msgAbort            = 2
+	msgAcknowledgement  = 3
+	msgUserControl      = 4
+	msgWindowAckSize    = 5
+	msgSetPeerBandwidth = 6
+	msgAudio            = 8
+	msgVideo            = 9
+	msgDataAMF3         = 15
+	msgCommandAMF3      = 17
+	msgDataAMF0         = 18
+	msgCommandAMF0      = 20
+)
+
+// maxMessageSize is the largest RTMP message accepted, protecting against huge allocations.
+const maxMessageSize = 16 << 20
+
+// maxChunkStreams is the most chunk streams a connection may open.
+const maxChunkStreams = 64
+
+// maxBufferedSize is the most payload bytes buffered across the partial messages of a connection.
+const maxBufferedSize = 2 * maxMessageSize
+
+var (
+	// errMessageTooLarge indicates that a peer announced a message larger than maxMessageSize.
+	errMessageTooLarge = errors.New("RTMP message too large")
+	// errTooManyChunkStreams indicates that a peer opened more than maxChunkStreams chunk streams.
+	errTooManyChunkStreams = errors.New("too many RTMP chunk streams")
+	// errBufferFull indicates that a peer interleaved partial messages larger than maxBufferedSize.
+	errBufferFull = errors.New("RTMP partial messages too large")
+)
+
+// rtmpMessage is a complete RTMP message.
+type rtmpMessage struct {
+	typeID    uint8
+	streamID  uint32
+	timestamp uint32
+	payload   []byte
+}
+
+// chunkStream is the state of a chunk stream, used to decode the compressed headers.
+type chunkStream struct {
+	timestamp uint32
+	delta     uint32
+	length    uint32
+	typeID    uint8
+	streamID  uint32
+	extended  bool
+	payload   []byte
+}
+
+// chunkReader reassembles the RTMP messages from the chunks read from a connection.
+type chunkReader struct {
+	reader    *bufio.Reader
+	chunkSize uint32
+	streams   map[uint32]*chunkStream
+	read      uint64
+	buffered  uint64 // payload bytes of the partial messages
+}
+
+// newChunkReader returns a chunk reader using the default chunk size.
+func newChunkReader(reader io.Reader) *chunkReader {
+	return &chunkReader{reader: bufio.NewReader(reader), chunkSize: 128, streams: map[uint32]*chunkStream{}}
+}
+
+// full reads exactly len(data) bytes, counting them for the acknowledgements.
+func (r *chunkReader) full(data []byte) error {
+	n, err := io.ReadFull(r.reader, data)
+	r.read += uint64(n)
+	return err
+}
+
+// uint24 reads a big-endian 24 bit integer.
+func (r *chunkReader) uint24() (uint32, error) {
+	var data [3]byte
+	if err := r.full(data[:]); err != nil {
+		return 0, err
+	}
+
+	return uint32(data[0])<<16 | uint32(data[1])<<8 | uint32(data[2]), nil
+}
+
+// readMessage reads chunks until a message is complete.
+func (r *chunkReader) readMessage() (rtmpMessage, error) {
+	for {
+		var basic [1]byte
+		if err := r.full(basic[:]); err != nil {
+			return rtmpMessage{}, err
+		}
+
+		format := basic[0] >> 6
+		id := uint32(basic[0] & 0x3f)
+		switch id {
+		case 0:
+			var extra [1]byte
+			if err := r.full(extra[:]); err != nil {
+				return rtmpMessage{}, err
+			}
+			id = 64 + uint32(extra[0])
+		case 1:
+			var extra [2]byte
+			if err := r.full(extra[:]); err != nil {
+				return rtmpMessage{}, err
+			}
+			id = 64 + uint32(extra[0]) + uint32(extra[1])<<8
+		}
+
+		stream, ok := r.streams[id]
+		if !ok {
+			if len(r.streams) >= maxChunkStreams {
+				return rtmpMessage{}, errTooManyChunkStreams
+			}
+
+			stream = &chunkStream{}
+			r.streams[id] = stream
+		}
+
+		var timestamp uint32
+		if format <= 2 {
+			var err error
+			if timestamp, err = r.uint24(); err != nil {
+				return rtmpMessage{}, err
+			}
+		}
+
+		if format <= 1 {
+			length, err := r.uint24()
+			if err != nil {
+				return rtmpMessage{}, err
+			}
+
+			if length > maxMessageSize {
+				return rtmpMessage{}, errMessageTooLarge
+			}
+
+			var typeID [1]byte
+			if err := r.full(typeID[:]); err != nil {
+				return rtmpMessage{}, err
+			}
+
+			stream.length = length
+			stream.typeID = typeID[0]
+		}
+
+		if format == 0 {
+			var streamID [4]byte
+			if err := r.full(streamID[:]); err != nil {
+				return rtmpMessage{}, err
+			}
+
+			stream.streamID = binary.LittleEndian.Uint32(streamID[:])
+		}
+
+		if format <= 2 {
+			stream.extended = timestamp == 0xffffff
+		}
+
+		if stream.extended {
+			var extended [4]byte
+			if err := r.full(extended[:]); err != nil {
+				return rtmpMessage{}, err
+			}
+
+			if format <= 2 {
+				timestamp = binary.BigEndian.Uint32(extended[:])
+			}
+		}
+
+		if len(stream.payload) == 0 {
+			switch format {
+			case 0:
+				stream.timestamp = timestamp
+				stream.delta = 0
+			case 1, 2:
+				stream.delta = timestamp
+				stream.timestamp += timestamp
+			case 3:
+				stream.timestamp += stream.delta
+			}
+		}
+
+		size := min(r.chunkSize, stream.length-uint32(len(stream.payload)))
+		if r.buffered+uint64(size) > maxBufferedSize {
+			return rtmpMessage{}, errBufferFull
+		}
+
+		chunk := make([]byte, size)
+		if err := r.full(chunk); err != nil {
+			return rtmpMessage{}, err
+		}
+		stream.payload = append(stream.payload, chunk...)
+		r.buffered += uint64(size)
+
+		if uint32(len(stream.payload)) >= stream.length {
+			message := rtmpMessage{typeID: stream.typeID, streamID: stream.streamID, timestamp: stream.timestamp, payload: stream.payload}
+			r.buffered -= uint64(len(stream.payload))
+			stream.payload = nil
+			return message, nil
+		}
+	}
+}
+
+// chunkWriter splits RTMP messages into chunks written to a connection.
+type chunkWriter struct {
+	writer    *bufio.Writer
+	chunkSize uint32
+}
+
+// newChunkWriter returns a chunk writer using the default chunk size.
+func newChunkWriter(writer io.Writer) *chunkWriter {
+	return &chunkWriter{writer: bufio.NewWriter(writer), chunkSize: 128}
+}
+
+// writeMessage writes the message on the chunk stream, flushing it to the connection.
+func (w *chunkWriter) writeMessage(chunkStreamID uint8, message rtmpMessage) error {
+	timestamp := min(message.timestamp, 0xffffff)
+	length := len(message.payload)
+
+	header := []byte{
+		chunkStreamID & 0x3f,
+		byte(timestamp >> 16), byte(timestamp >> 8), byte(timestamp),
+		byte(length >> 16), byte(length >> 8), byte(length),
+		message.typeID,
+		0, 0, 0, 0,
+	}
+	binary.LittleEndian.PutUint32(header[8:], message.streamID)
+
+	var extended []byte
+	if timestamp == 0xffffff {
+		extended = binary.BigEndian.AppendUint32(nil, message.timestamp)
+	}
+
+	w.writer.Write(header)
+	w.writer.Write(extended)
+
+	payload := message.payload
+	for {
+		size := min(int(w.chunkSize), len(payload))
+		w.writer.Write(payload[:size])
+		payload = payload[size:]
+
+		if len(payload) == 0 {
+			break
+		}
+
+		w.writer.WriteByte(0xc0 | chunkStreamID&0x3f)
+		w.writer.Write(extended)
+	}
+
+	return w.writer.Flush()
+}
@@ -0,0 +1,2 @@
+// Package ingest receives live streams pushed or pulled into VRMix, packaging them into HLS that channels can play like any other source.
+package ingest
@@ -0,0 +1,583 @@
+package ingest
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"vrmix/hls"
+	"vrmix/logging"
+	"vrmix/source"
+)
+
+// RelayURIPrefix is the prefix of the URIs of the relayed audio streams, followed by the relay ID and the file inside it.
+const RelayURIPrefix = "relay:///"
+
+// timestampOwner is the owner of the ID3 PRIV frame carrying the timestamp of packed audio segments.
+const timestampOwner = "com.apple.streaming.transportStreamTimestamp"
+
+// maxResync is how many bytes are skipped looking for a frame before the stream is considered broken.
+const maxResync = 64 << 10
+
+// ErrAudioFormat indicates that the stream is not MP3 or AAC in ADTS.
+var ErrAudioFormat = errors.New("unsupported audio stream format")
+
+// IcecastSource is a Source relaying continuous Icecast or HTTP audio streams, referenced as "icy://" or "icys://" URLs, as live HLS packed audio playlists.
+type IcecastSource struct {
+	Client          *http.Client  // Client used to reach the streams, defaults to http.DefaultClient
+	SegmentDuration time.Duration // Target duration of the segments, defaults to 4 seconds
+	ListSize        int           // Segments kept in the playlist, defaults to 6
+	IdleTimeout     time.Duration // Time without playlist or segment reads before a relay stops, defaults to one minute
+	RetryInterval   time.Duration // Time waited before reconnecting after the stream drops, defaults to 2 seconds
+	Logger          *slog.Logger  // Logger receiving the relay events, defaults to slog.Default
+
+	mutex  sync.Mutex
+	relays map[string]*audioRelay
+}
+
+// relaySegment is a packed audio segment of a relay.
+type relaySegment struct {
+	sequence      uint32
+	duration      float64
+	title         string
+	data          []byte
+	discontinuity bool // first segment after a reconnect
+}
+
+// audioRelay relays a single audio stream, keeping a sliding window of segments.
+type audioRelay struct {
+	id     string
+	url    string
+	source *IcecastSource
+	cancel context.CancelFunc
+	ready  chan struct{}
+	once   sync.Once
+
+	mutex     sync.Mutex
+	err       error
+	name      string
+	bitrate   int
+	codecs    string
+	extension string
+	title     string
+	segments  []relaySegment
+	sequence  uint32
+	lastRead  time.Time
+
+	discontinuity         bool   // next segment is the first after a reconnect
+	discontinuitySequence uint32 // discontinuities which left the window
+}
+
+// relay returns the relay of the reference, starting it if needed.
+func (s *IcecastSource) relay(ref string) (*audioRelay, error) {
+	u, err := url.Parse(ref)
+	if err != nil {
+		return nil, source.ErrUnsupported
+	}
+
+	switch u.Scheme {
+	case "icy":
+		u.Scheme = "http"
+	case "icys":
+		u.Scheme = "https"
+	default:
+		return nil, source.ErrUnsupported
+	}
+
+	id := source.EntryID(ref)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if relay, ok := s.relays[id]; ok {
+		return relay, nil
+	}
+
+	if s.relays == nil {
+		s.relays = make(map[string]*audioRelay)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	relay := &audioRelay{id: id, url: u.String(), source: s, cancel: cancel, ready: make(chan struct{}), lastRead: time.Now()}
+	s.relays[id] = relay
+	go relay.run(ctx)
+
+	return relay, nil
+}
+
+// Resolve starts relaying the stream, waiting until it is first reached.
+func (s *IcecastSource) Resolve(ctx context.Context, ref string) (source.Item, error) {
+	relay, err := s.relay(ref)
+	if err != nil {
+		return source.Item{}, err
+	}
+
+	if err := relay.wait(ctx); err != nil {
+		return source.Item{}, err
+	}
+
+	relay.mutex.Lock()
+	defer relay.mutex.Unlock()
+
+	return source.Item{Ref: ref, Title: relay.name, Live: true}, nil
+}
+
+// ListRenditions returns the audio playlist of the relay.
+func (s *IcecastSource) ListRenditions(ctx context.Context, item source.Item) ([]source.Rendition, error) {
+	relay, err := s.relay(item.Ref)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := relay.wait(ctx); err != nil {
+		return nil, err
+	}
+
+	relay.mutex.Lock()
+	defer relay.mutex.Unlock()
+
+	return []source.Rendition{{URI: RelayURIPrefix + relay.id + "/" + PlaylistName, Bandwidth: relay.bitrate * 1000, Codecs: relay.codecs}}, nil
+}
+
+// OpenSegment opens the playlist or a segment of a relay by its "relay:///" URI.
+func (s *IcecastSource) OpenSegment(ctx context.Context, uri string) (io.ReadCloser, error) {
+	relative, found := strings.CutPrefix(uri, RelayURIPrefix)
+	if !found {
+		return nil, source.ErrUnsupported
+	}
+
+	id, name, _ := strings.Cut(relative, "/")
+
+	s.mutex.Lock()
+	relay, ok := s.relays[id]
+	s.mutex.Unlock()
+
+	if !ok {
+		return nil, source.ErrNotFound
+	}
+
+	data, err := relay.file(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+// Close stops every relay.
+func (s *IcecastSource) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for id, relay := range s.relays {
+		relay.cancel()
+		delete(s.relays, id)
+	}
+
+	return nil
+}
+
+// wait waits until the relay first reaches the stream, returning the error if it could not.
+func (r *audioRelay) wait(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-r.ready:
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.err
+}
+
+// file returns the playlist or a segment of the relay, keeping it alive.
+func (r *audioRelay) file(name string) ([]byte, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.lastRead = time.Now()
+
+	if name == PlaylistName {
+		return []byte(r.playlist()), nil
+	}
+
+	for _, segment := range r.segments {
+		if name == "segment"+strconv.FormatUint(uint64(segment.sequence), 10)+r.extension {
+			return segment.data, nil
+		}
+	}
+
+	return nil, source.ErrNotFound
+}
+
+// playlist returns the live playlist of the segments in the window, with the now-playing title of each segment.
+func (r *audioRelay) playlist() string {
+	manifest := hls.Manifest{Version: 3, TargetDuration: 1, DiscontinuitySequence: r.discontinuitySequence, SegmentGroups: []hls.SegmentGroup{{}}}
+	if len(r.segments) > 0 {
+		manifest.MediaSequence = r.segments[0].sequence
+	}
+
+	for i, segment := range r.segments {
+		if segment.discontinuity && i > 0 {
+			manifest.SegmentGroups = append(manifest.SegmentGroups, hls.SegmentGroup{})
+		}
+
+		group := &manifest.SegmentGroups[len(manifest.SegmentGroups)-1]
+		manifest.TargetDuration = max(manifest.TargetDuration, uint8(math.Ceil(segment.duration)))
+		group.Segments = append(group.Segments, hls.Segment{
+			Path:     "segment" + strconv.FormatUint(uint64(segment.sequence), 10) + r.extension,
+			Duration: float32(segment.duration),
+			Title:    segment.title,
+		})
+	}
+
+	return manifest.String()
+}
+
+// idle checks if nothing was read from the relay for the idle timeout.
+func (r *audioRelay) idle() bool {
+	timeout := r.source.IdleTimeout
+	if timeout <= 0 {
+		timeout = time.Minute
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return time.Since(r.lastRead) > timeout
+}
+
+// run relays the stream, reconnecting when it drops, until the relay is idle or the context is canceled.
+func (r *audioRelay) run(ctx context.Context) {
+	defer r.cancel()
+
+	go func() {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if r.idle() {
+					r.cancel()
+					return
+				}
+			}
+		}
+	}()
+
+	retryInterval := r.source.RetryInterval
+	if retryInterval <= 0 {
+		retryInterval = 2 * time.Second
+	}
+
+	logger := logging.Or(r.source.Logger).With(slog.String(logging.RefKey, r.url))
+
+	for ctx.Err() == nil {
+		err := r.stream(ctx)
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		} else {
+			logger.Warn("relay dropped", slog.Duration("retry", retryInterval), logging.Err(err))
+		}
+
+		r.once.Do(func() {
+			r.mutex.Lock()
+			r.err = err
+			r.mutex.Unlock()
+			close(r.ready)
+		})
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(retryInterval):
+		}
+	}
+
+	r.source.mutex.Lock()
+	if r.source.relays[r.id] == r {
+		delete(r.source.relays, r.id)
+	}
+	r.source.mutex.Unlock()
+}
+
+// stream reads the stream until it drops, cutting it into segments.
+func (r *audioRelay) stream(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Icy-MetaData", "1")
+
+	client := r.source.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return &source.StatusError{StatusCode: resp.StatusCode}
+	}
+
+	aac := false
+	switch strings.ToLower(strings.TrimSpace(strings.Split(resp.Header.Get("Content-Type"), ";")[0])) {
+	case "audio/mpeg", "audio/mp3":
+	case "audio/aac", "audio/aacp", "audio/x-aac":
+		aac = true
+	default:
+		return ErrAudioFormat
+	}
+
+	metaint, _ := strconv.Atoi(resp.Header.Get("Icy-Metaint"))
+	bitrate, _ := strconv.Atoi(strings.Split(resp.Header.Get("Icy-Br"), ",")[0])
+
+	r.mutex.Lock()
+	r.name = resp.Header.Get("Icy-Name")
+	r.bitrate = bitrate
+	r.discontinuity = r.sequence > 0
+	r.codecs, r.extension = "mp4a.40.34", ".mp3"
+	if aac {
+		r.codecs, r.extension = "mp4a.40.2", ".aac"
+	}
+	r.mutex.Unlock()
+
+	r.once.Do(func() { close(r.ready) })
+
+	metadata := &icyReader{reader: resp.Body, metaint: metaint, remaining: metaint, onTitle: func(title string) {
+		r.mutex.Lock()
+		r.title = title
+		r.mutex.Unlock()
+	}}
+	reader := bufio.NewReaderSize(metadata, 8192)
+
+	target := r.source.SegmentDuration
+	if target <= 0 {
+		target = 4 * time.Second
+	}
+
+	var pts uint64
+	var frames bytes.Buffer
+	var duration float64
+	var startPTS uint64
+
+	for {
+		frame, samples, sampleRate, err := readAudioFrame(reader, aac)
+		if err != nil {
+			return err
+		}
+
+		if duration >= target.Seconds() {
+			r.cut(startPTS, duration, frames.Bytes())
+			frames.Reset()
+			duration = 0
+		}
+
+		if frames.Len() == 0 {
+			startPTS = pts
+		}
+
+		frames.Write(frame)
+		duration += float64(samples) / float64(sampleRate)
+		pts += uint64(samples) * 90000 / uint64(sampleRate)
+	}
+}
+
+// cut adds a segment with the frames to the window, tagging it with its timestamp and the now-playing title.
+func (r *audioRelay) cut(pts uint64, duration float64, frames []byte) {
+	listSize := r.source.ListSize
+	if listSize <= 0 {
+		listSize = 6
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	title := strings.NewReplacer("\r", " ", "\n", " ").Replace(r.title)
+	data := append(id3Tag(pts&(1<<33-1), title), frames...)
+
+	r.segments = append(r.segments, relaySegment{sequence: r.sequence, duration: duration, title: title, data: data, discontinuity: r.discontinuity})
+	r.sequence += 1
+	r.discontinuity = false
+
+	if len(r.segments) > listSize {
+		// The discontinuities of the segments becoming the first of the window are no longer written.
+		dropped := len(r.segments) - listSize
+		for _, segment := range r.segments[1 : dropped+1] {
+			if segment.discontinuity {
+				r.discontinuitySequence += 1
+			}
+		}
+
+		r.segments = r.segments[dropped:]
+	}
+}
+
+// icyReader strips the metadata blocks interleaved in an Icecast stream, reporting the changes of the stream title.
+type icyReader struct {
+	reader    io.Reader
+	metaint   int
+	remaining int
+	onTitle   func(title string)
+}
+
+func (r *icyReader) Read(p []byte) (int, error) {
+	if r.metaint <= 0 {
+		return r.reader.Read(p)
+	}
+
+	if r.remaining == 0 {
+		var length [1]byte
+		if _, err := io.ReadFull(r.reader, length[:]); err != nil {
+			return 0, err
+		}
+
+		metadata := make([]byte, int(length[0])*16)
+		if _, err := io.ReadFull(r.reader, metadata); err != nil {
+			return 0, err
+		}
+
+		if title, ok := streamTitle(string(bytes.TrimRight(metadata, "\x00"))); ok {
+			r.onTitle(title)
+		}
+
+		r.remaining = r.metaint
+	}
+
+	n, err := r.reader.Read(p[:min(len(p), r.remaining)])
+	r.remaining -= n
+	return n, err
+}
+
+// streamTitle extracts the StreamTitle of an Icecast metadata block.
+func streamTitle(metadata string) (string, bool) {
+	_, rest, found := strings.Cut(metadata, "StreamTitle='")
+	if !found {
+		return "", false
+	}
+
+	title, _, _ := strings.Cut(rest, "';")
+	return title, true
+}
+
+// mp3Bitrates are the bitrates in kbps of MPEG-1 and MPEG-2 Layer III by bitrate index.
+var mp3Bitrates = [2][15]int{
+	{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320},
+	{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+}
+
+// adtsSampleRates are the sample rates of AAC by sampling frequency index.
+var adtsSampleRates = []int{96000, 88200, 64000, 48000, 44100, 32000, 24000, 22050, 16000, 12000, 11025, 8000, 7350}
+
+// mp3Frame parses the header of an MP3 frame, returning its length, samples and sample rate.
+func mp3Frame(header []byte) (int, int, int, bool) {
+	if header[0] != 0xff || header[1]&0xe0 != 0xe0 || (header[1]>>1)&3 != 1 {
+		return 0, 0, 0, false
+	}
+
+	version := (header[1] >> 3) & 3
+	bitrateIndex := header[2] >> 4
+	sampleRateIndex := (header[2] >> 2) & 3
+	if version == 1 || bitrateIndex == 0 || bitrateIndex == 15 || sampleRateIndex == 3 {
+		return 0, 0, 0, false
+	}
+
+	sampleRate := []int{44100, 48000, 32000}[sampleRateIndex]
+	samples, table := 1152, 0
+	switch version {
+	case 2:
+		sampleRate, samples, table = sampleRate/2, 576, 1
+	case 0:
+		sampleRate, samples, table = sampleRate/4, 576, 1
+	}
+
+	padding := int(header[2]>>1) & 1
+	length := samples/8*mp3Bitrates[table][bitrateIndex]*1000/sampleRate + padding
+	return length, samples, sampleRate, true
+}
+
+// adtsFrame parses the header of an AAC ADTS frame, returning its length, samples and sample rate.
+func adtsFrame(header []byte) (int, int, int, bool) {
+	if header[0] != 0xff || header[1]&0xf6 != 0xf0 {
+		return 0, 0, 0, false
+	}
+
+	sampleRateIndex := int(header[2]>>2) & 0xf
+	length := int(header[3]&3)<<11 | int(header[4])<<3 | int(header[5]>>5)
+	if sampleRateIndex >= len(adtsSampleRates) || length < 7 {
+		return 0, 0, 0, false
+	}
+
+	return length, 1024 * (int(header[6]&3) + 1), adtsSampleRates[sampleRateIndex], true
+}
+
+// readAudioFrame reads the next MP3 or ADTS frame, skipping garbage until a frame header is found.
+func readAudioFrame(reader *bufio.Reader, aac bool) ([]byte, int, int, error) {
+	headerSize, parse := 4, mp3Frame
+	if aac {
+		headerSize, parse = 7, adtsFrame
+	}
+
+	for skipped := 0; skipped < maxResync; skipped++ {
+		header, err := reader.Peek(headerSize)
+		if err != nil {
+			return nil, 0, 0, err
+		}
+
+		length, samples, sampleRate, ok := parse(header)
+		if !ok {
+			reader.Discard(1)
+			continue
+		}
+
+		frame := make([]byte, length)
+		if _, err := io.ReadFull(reader, frame); err != nil {
+			return nil, 0, 0, err
+		}
+
+		return frame, samples, sampleRate, nil
+	}
+
+	return nil, 0, 0, ErrAudioFormat
+}
+
+// syncsafe encodes the size as an ID3 syncsafe integer.
+func syncsafe(size int) []byte {
+	return []byte{byte(size>>21) & 0x7f, byte(size>>14) & 0x7f, byte(size>>7) & 0x7f, byte(size) & 0x7f}
+}
+
+// id3Frame encodes an ID3v2.4 frame.
+func id3Frame(id string, data []byte) []byte {
+	frame := append([]byte(id), syncsafe(len(data))...)
+	frame = append(frame, 0, 0)
+	return append(frame, data...)
+}
+
+// id3Tag encodes the ID3v2.4 tag starting a packed audio segment, with the timestamp and the title when known.
+func id3Tag(pts uint64, title string) []byte {
+	frames := id3Frame("PRIV", binary.BigEndian.AppendUint64([]byte(timestampOwner+"\x00"), pts))
+	if title != "" {
+		frames = append(frames, id3Frame("TIT2", append([]byte{3}, title...))...)
+	}
+
+	tag := append([]byte{'I', 'D', '3', 4, 0, 0}, syncsafe(len(frames))...)
+	return append(tag, frames...)
+}
@@ -0,0 +1,170 @@
+package ingest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"vrmix/hls"
+	"vrmix/source"
+)
+
+// adtsFrameData returns an ADTS frame of 1024 samples at 44.1 kHz with the given payload size
+func adtsFrameData(payload int) []byte {
+	length := 7 + payload
+	frame := []byte{0xff, 0xf1, 0x50, 0x80 | byte(length>>11)&3, byte(length >> 3), byte(length<<5) | 0x1f, 0xfc}
+	return append(frame, bytes.Repeat([]byte{0xaa}, payload)...)
+}
+
+// newRadio starts an Icecast server sending ADTS frames with the stream title interleaved every 64 bytes
+func newRadio(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Icy-MetaData") != "1" {
+			t.Errorf("expected metadata to be requested")
+		}
+
+		w.Header().Set("Content-Type", "audio/aacp")
+		w.Header().Set("Icy-Name", "Night Radio")
+		w.Header().Set("Icy-Br", "64")
+		w.Header().Set("Icy-Metaint", "64")
+
+		metadata := []byte("StreamTitle='Artist - Song';")
+		block := append([]byte{byte((len(metadata) + 15) / 16)}, metadata...)
+		block = append(block, make([]byte, int(block[0])*16-len(metadata))...)
+
+		var stream []byte
+		for range 200 {
+			stream = append(stream, adtsFrameData(20)...)
+		}
+
+		for len(stream) > 0 {
+			n := min(64, len(stream))
+			if _, err := w.Write(stream[:n]); err != nil {
+				return
+			}
+			stream = stream[n:]
+
+			if n == 64 {
+				w.Write(block)
+			}
+		}
+		w.(http.Flusher).Flush()
+
+		<-r.Context().Done()
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestIcecastSource(t *testing.T) {
+	server := newRadio(t)
+	s := &IcecastSource{SegmentDuration: 100 * time.Millisecond, ListSize: 3}
+	defer s.Close()
+
+	ref := strings.Replace(server.URL, "http://", "icy://", 1) + "/stream"
+	item, err := s.Resolve(context.Background(), ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if item.Title != "Night Radio" || !item.Live {
+		t.Errorf("expected the live station, got %+v", item)
+	}
+
+	renditions, err := s.ListRenditions(context.Background(), item)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(renditions) != 1 || renditions[0].Codecs != "mp4a.40.2" || renditions[0].Bandwidth != 64000 {
+		t.Fatalf("expected an AAC rendition, got %v", renditions)
+	}
+
+	var manifest hls.Manifest
+	for deadline := time.Now().Add(5 * time.Second); manifest.SegmentCount() < 3; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the playlist to fill")
+		}
+
+		body, err := s.OpenSegment(context.Background(), renditions[0].URI)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		data, _ := io.ReadAll(body)
+		if manifest, err = hls.ParseHlsManifest(strings.TrimRight(string(data), "\n")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	segment := manifest.SegmentGroups[0].Segments[0]
+	if segment.Title != "Artist - Song" || segment.Duration < 0.1 {
+		t.Errorf("expected a segment of at least 100ms playing the song, got %+v", segment)
+	}
+
+	body, err := s.OpenSegment(context.Background(), strings.TrimSuffix(renditions[0].URI, PlaylistName)+segment.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := io.ReadAll(body)
+	if !bytes.HasPrefix(data, []byte("ID3")) || !bytes.Contains(data, []byte(timestampOwner)) || !bytes.Contains(data, []byte("Artist - Song")) {
+		t.Errorf("expected an ID3 tag with the timestamp and title")
+	}
+
+	tagSize := 10 + (int(data[6])<<21 | int(data[7])<<14 | int(data[8])<<7 | int(data[9]))
+	if !bytes.HasPrefix(data[tagSize:], adtsFrameData(20)) {
+		t.Errorf("expected the ADTS frames after the tag, without metadata")
+	}
+
+	if _, err := s.OpenSegment(context.Background(), RelayURIPrefix+"unknown/"+PlaylistName); !errors.Is(err, source.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for unknown relays, got %v", err)
+	}
+}
+
+func TestRelayReconnect(t *testing.T) {
+	relay := &audioRelay{source: &IcecastSource{ListSize: 2}, extension: ".aac"}
+	relay.cut(0, 4, nil)
+	relay.cut(360000, 4, nil)
+	relay.discontinuity = true
+	relay.cut(0, 4, nil)
+
+	manifest, err := hls.ParseHlsManifest(strings.TrimRight(relay.playlist(), "\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(manifest.SegmentGroups) != 2 || manifest.DiscontinuitySequence != 0 || manifest.SegmentGroups[1].Segments[0].Path != "segment2.aac" {
+		t.Fatalf("expected a discontinuity before the segment after the reconnect, got %+v", manifest)
+	}
+
+	relay.cut(360000, 4, nil)
+	if manifest, err = hls.ParseHlsManifest(strings.TrimRight(relay.playlist(), "\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(manifest.SegmentGroups) != 1 || manifest.DiscontinuitySequence != 1 || manifest.MediaSequence != 2 {
+		t.Errorf("expected the discontinuity to leave the window, got %+v", manifest)
+	}
+}
+
+func TestIcecastSourceUnsupported(t *testing.T) {
+	s := &IcecastSource{}
+	if _, err := s.Resolve(context.Background(), "https://radio.example/stream"); !errors.Is(err, source.ErrUnsupported) {
+		t.Errorf("expected ErrUnsupported, got %v", err)
+	}
+}
+
+func TestMP3Frame(t *testing.T) {
+	length, samples, sampleRate, ok := mp3Frame([]byte{0xff, 0xfb, 0x90, 0x64})
+	if !ok || length != 417 || samples != 1152 || sampleRate != 44100 {
+		t.Errorf("expected a 128 kbps 44.1 kHz frame of 417 bytes, got %d, %d, %d and %t", length, samples, sampleRate, ok)
+	}
+}
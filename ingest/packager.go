@@ -0,0 +1,152 @@
+package ingest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"vrmix/hls"
+)
+
+// TracerName is the instrumentation scope of the spans started by the ingest, registered with otel.SetTracerProvider.
+const TracerName = "vrmix/ingest"
+
+// PlaylistName is the name of the media playlist written by the packager in the directory of each stream.
+const PlaylistName = "index.m3u8"
+
+// Input describes the media fed to a packager, either as a stream of bytes or as a URL the packager reads itself.
+type Input struct {
+	Reader io.Reader // Stream of the media, nil to read from the URL
+	Format string    // Container of the stream, like "flv" or "mpegts", empty to let the packager detect it
+	URL    string    // URL read by the packager when there is no reader, like "srt://0.0.0.0:9000?mode=listener"
+	Args   []string  // Extra input arguments of the packager, like "-rtsp_transport tcp"
+
+	// CodecArgs overrides the codec arguments of the packager for this input, like transcoding a camera that sends H.265.
+	CodecArgs []string
+
+	// Remap converts the projection of the video while packaging it, transcoding with RemapCodecArgs unless codec arguments are given, nil to keep it.
+	Remap *Remap
+
+	// Audio describes the channels of the audio, copying spatial audio like Dolby Atmos or ambisonics as is so transcoding never downmixes it.
+	Audio hls.Channels
+}
+
+// RemapCodecArgs are the codec arguments of the inputs whose projection is converted when neither the packager nor the input set them.
+var RemapCodecArgs = []string{"-c:v", "libx264", "-preset", "veryfast", "-c:a", "copy"}
+
+// Packager packages live media into a sliding HLS playlist named PlaylistName inside the directory, blocking until the input ends.
+type Packager interface {
+	Package(ctx context.Context, dir string, input Input) error
+}
+
+// FFmpegPackager is a Packager running ffmpeg, remuxing the input unless transcoding arguments are given.
+type FFmpegPackager struct {
+	Binary          string        // Path to the ffmpeg binary, defaults to "ffmpeg"
+	SegmentDuration time.Duration // Target duration of the segments, defaults to 4 seconds
+	ListSize        int           // Segments kept in the playlist, defaults to 6
+	CodecArgs       []string      // Codec arguments, defaults to "-c copy"
+
+	prefixArgs []string
+}
+
+// args returns the arguments of ffmpeg packaging the input into the directory.
+func (p *FFmpegPackager) args(dir string, input Input) []string {
+	segmentDuration := hls.SegmentDuration(p.SegmentDuration, 4*time.Second)
+
+	listSize := p.ListSize
+	if listSize <= 0 {
+		listSize = 6
+	}
+
+	codecArgs := p.CodecArgs
+	if input.CodecArgs != nil {
+		codecArgs = input.CodecArgs
+	} else if codecArgs == nil && input.Remap != nil {
+		codecArgs = RemapCodecArgs
+	} else if codecArgs == nil {
+		codecArgs = []string{"-c", "copy"}
+	}
+
+	args := append([]string{}, p.prefixArgs...)
+	args = append(args, "-hide_banner", "-loglevel", "error", "-nostdin")
+	args = append(args, input.Args...)
+	if input.Format != "" {
+		args = append(args, "-f", input.Format)
+	}
+
+	if input.Reader != nil {
+		args = append(args, "-i", "pipe:0")
+	} else {
+		args = append(args, "-i", input.URL)
+	}
+
+	if input.Remap != nil {
+		if filter, err := input.Remap.Filter(); err == nil {
+			args = append(args, "-vf", filter)
+		}
+	}
+
+	args = append(args, codecArgs...)
+	if input.Audio.IsSpatial() {
+		args = append(args, "-c:a", "copy")
+	}
+
+	args = append(args,
+		"-f", "hls",
+		"-hls_time", strconv.FormatFloat(segmentDuration.Seconds(), 'f', -1, 64),
+		"-hls_list_size", strconv.Itoa(listSize),
+		"-hls_flags", "delete_segments+omit_endlist+temp_file",
+		"-hls_segment_filename", filepath.Join(dir, "segment%d.ts"),
+		filepath.Join(dir, PlaylistName),
+	)
+
+	return args
+}
+
+// Package runs ffmpeg until the input ends or the context is canceled, tracing the conversion as a span.
+func (p *FFmpegPackager) Package(ctx context.Context, dir string, input Input) (err error) {
+	binary := p.Binary
+	if binary == "" {
+		binary = "ffmpeg"
+	}
+
+	if input.Remap != nil {
+		if _, err := input.Remap.Filter(); err != nil {
+			return err
+		}
+	}
+
+	_, span := otel.Tracer(TracerName).Start(ctx, "conversion", trace.WithAttributes(attribute.String("vrmix.ingest.format", input.Format), attribute.String("vrmix.ingest.dir", dir)))
+	defer func() {
+		if err != nil && !errors.Is(err, context.Canceled) {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, binary, p.args(dir, input)...)
+	cmd.Stdin = input.Reader
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		return commandError(err, &stderr)
+	}
+
+	return nil
+}
@@ -0,0 +1,59 @@
+package ingest
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"vrmix/hls"
+)
+
+func TestFFmpegPackagerArgs(t *testing.T) {
+	p := &FFmpegPackager{SegmentDuration: 2 * time.Second, ListSize: 5}
+
+	args := strings.Join(p.args("/tmp/live/cam", Input{Reader: strings.NewReader(""), Format: "flv"}), " ")
+	for _, expected := range []string{"-f flv -i pipe:0", "-c copy", "-hls_time 2", "-hls_list_size 5", "/tmp/live/cam/index.m3u8"} {
+		if !strings.Contains(args, expected) {
+			t.Errorf("expected %q in %q", expected, args)
+		}
+	}
+
+	p.CodecArgs = []string{"-c:v", "libx264"}
+	urlArgs := p.args("/tmp/live/cam", Input{URL: "rtsp://camera/stream", Args: []string{"-rtsp_transport", "tcp"}})
+	if !slices.Contains(urlArgs, "rtsp://camera/stream") || !slices.Contains(urlArgs, "libx264") || slices.Contains(urlArgs, "pipe:0") {
+		t.Errorf("expected to read the URL and transcode, got %v", urlArgs)
+	}
+
+	if slices.Index(urlArgs, "-rtsp_transport") > slices.Index(urlArgs, "-i") {
+		t.Errorf("expected input arguments before the input, got %v", urlArgs)
+	}
+
+	p.CodecArgs = []string{"-c:v", "libx264", "-c:a", "aac"}
+	spatialArgs := strings.Join(p.args("/tmp/live/cam", Input{URL: "rtsp://camera/stream", Audio: hls.Channels{Count: 16, Coding: []string{hls.AudioCodingJOC}}}), " ")
+	if !strings.Contains(spatialArgs, "-c:a aac -c:a copy") {
+		t.Errorf("expected the spatial audio to be copied, got %q", spatialArgs)
+	}
+}
+
+func TestFFmpegPackagerRemapArgs(t *testing.T) {
+	p := &FFmpegPackager{}
+	remap := &Remap{From: hls.Layout{Projection: hls.ProjectionEquirectangular}, To: hls.ProjectionCubemap}
+
+	args := strings.Join(p.args("/tmp/live/cam", Input{URL: "rtmp://camera/live", Remap: remap}), " ")
+	for _, expected := range []string{"-vf v360=input=e:output=c3x2", "-c:v libx264"} {
+		if !strings.Contains(args, expected) {
+			t.Errorf("expected %q in %q", expected, args)
+		}
+	}
+
+	if strings.Contains(args, "-c copy") {
+		t.Errorf("expected the converted video to be transcoded, got %q", args)
+	}
+
+	err := p.Package(t.Context(), t.TempDir(), Input{URL: "rtmp://camera/live", Remap: &Remap{From: hls.Layout{Projection: hls.ProjectionEquirectangular, Stereo: hls.StereoMultiview}, To: hls.ProjectionCubemap}})
+	if !errors.Is(err, ErrUnsupportedRemap) {
+		t.Errorf("expected ErrUnsupportedRemap, got %v", err)
+	}
+}
@@ -0,0 +1,133 @@
+package ingest
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+
+	"vrmix/hls"
+)
+
+// ErrUnsupportedRemap indicates that the v360 filter of ffmpeg cannot convert between the projections or stereo layouts.
+var ErrUnsupportedRemap = errors.New("unsupported projection conversion")
+
+// v360Formats maps the projections to the formats of the ffmpeg v360 filter.
+var v360Formats = map[hls.Projection]string{
+	hls.ProjectionRectilinear:         "flat",
+	hls.ProjectionEquirectangular:     "e",
+	hls.ProjectionHalfEquirectangular: "hequirect",
+	hls.ProjectionCubemap:             "c3x2",
+	hls.ProjectionEquiAngularCubemap:  "eac",
+	hls.ProjectionFisheye:             "fisheye",
+}
+
+// v360Stereo maps the stereo layouts to the stereo formats of the ffmpeg v360 filter.
+var v360Stereo = map[hls.StereoLayout]string{
+	"":                   "2d",
+	hls.StereoMono:       "2d",
+	hls.StereoSideBySide: "sbs",
+	hls.StereoTopBottom:  "tb",
+}
+
+// Remap converts the projection of a video with the ffmpeg v360 filter, like equirectangular to cubemap, fisheye to equirectangular or a flat window extracted from a 360 degrees video, so sources with different projections can be normalized into one channel.
+type Remap struct {
+	From hls.Layout     // Projection and stereo layout of the input
+	To   hls.Projection // Projection of the output, keeping the stereo layout of the input unless it is rectilinear
+
+	// Yaw, Pitch and Roll rotate the view in degrees, like pointing the window extracted into a flat video.
+	Yaw, Pitch, Roll float64
+
+	// InputFOV is the field of view in degrees of a fisheye input, defaults to 180.
+	InputFOV float64
+
+	// HFOV and VFOV are the fields of view in degrees of a rectilinear output, defaulting to 90 and to the field matching the aspect ratio of the output resolution.
+	HFOV, VFOV float64
+
+	// Width and Height are the resolution of the output, zero to let ffmpeg derive it from the input.
+	Width, Height int
+}
+
+// Layout returns the projection and stereo layout of the output.
+func (r *Remap) Layout() hls.Layout {
+	layout := hls.Layout{Projection: r.To, Stereo: r.From.Stereo}
+	if r.To == hls.ProjectionRectilinear {
+		layout.Stereo = hls.StereoMono
+	}
+
+	if r.Width > 0 && r.Height > 0 {
+		layout.Resolution = strconv.Itoa(r.Width) + "x" + strconv.Itoa(r.Height)
+	}
+
+	return layout
+}
+
+// formatDegrees formats an angle in degrees for a filter option.
+func formatDegrees(degrees float64) string {
+	return strconv.FormatFloat(degrees, 'f', -1, 64)
+}
+
+// Filter returns the v360 filter converting the projection, or ErrUnsupportedRemap when the projections or the stereo layout cannot be converted, like a MV-HEVC input.
+func (r *Remap) Filter() (string, error) {
+	input, ok := v360Formats[r.From.Projection]
+	if !ok {
+		return "", ErrUnsupportedRemap
+	}
+
+	output, ok := v360Formats[r.To]
+	if !ok || r.From.Projection == r.To {
+		return "", ErrUnsupportedRemap
+	}
+
+	stereo, ok := v360Stereo[r.From.Stereo]
+	if !ok {
+		return "", ErrUnsupportedRemap
+	}
+
+	outputStereo := stereo
+	if r.To == hls.ProjectionRectilinear {
+		outputStereo = "2d"
+	}
+
+	options := []string{"input=" + input, "output=" + output, "in_stereo=" + stereo, "out_stereo=" + outputStereo}
+	if r.From.Projection == hls.ProjectionFisheye {
+		fov := r.InputFOV
+		if fov <= 0 {
+			fov = 180
+		}
+
+		options = append(options, "ih_fov="+formatDegrees(fov), "iv_fov="+formatDegrees(fov))
+	}
+
+	if r.To == hls.ProjectionRectilinear {
+		hfov := r.HFOV
+		if hfov <= 0 {
+			hfov = 90
+		}
+
+		vfov := r.VFOV
+		if vfov <= 0 && r.Width > 0 && r.Height > 0 {
+			vfov = 2 * math.Atan(math.Tan(hfov*math.Pi/360)*float64(r.Height)/float64(r.Width)) * 180 / math.Pi
+		}
+
+		options = append(options, "h_fov="+formatDegrees(hfov))
+		if vfov > 0 {
+			options = append(options, "v_fov="+strconv.FormatFloat(vfov, 'f', 2, 64))
+		}
+	}
+
+	for _, rotation := range []struct {
+		name    string
+		degrees float64
+	}{{"yaw", r.Yaw}, {"pitch", r.Pitch}, {"roll", r.Roll}} {
+		if rotation.degrees != 0 {
+			options = append(options, rotation.name+"="+formatDegrees(rotation.degrees))
+		}
+	}
+
+	if r.Width > 0 && r.Height > 0 {
+		options = append(options, "w="+strconv.Itoa(r.Width), "h="+strconv.Itoa(r.Height))
+	}
+
+	return "v360=" + strings.Join(options, ":"), nil
+}
@@ -0,0 +1,47 @@
+package ingest
+
+import (
+	"errors"
+	"testing"
+
+	"vrmix/hls"
+)
+
+func TestRemapFilter(t *testing.T) {
+	cases := []struct {
+		remap  Remap
+		filter string
+	}{
+		{Remap{From: hls.Layout{Projection: hls.ProjectionEquirectangular, Stereo: hls.StereoTopBottom}, To: hls.ProjectionEquiAngularCubemap}, "v360=input=e:output=eac:in_stereo=tb:out_stereo=tb"},
+		{Remap{From: hls.Layout{Projection: hls.ProjectionFisheye, Stereo: hls.StereoSideBySide}, To: hls.ProjectionHalfEquirectangular, InputFOV: 190}, "v360=input=fisheye:output=hequirect:in_stereo=sbs:out_stereo=sbs:ih_fov=190:iv_fov=190"},
+		{Remap{From: hls.Layout{Projection: hls.ProjectionEquirectangular, Stereo: hls.StereoTopBottom}, To: hls.ProjectionRectilinear, Yaw: -30, Width: 1920, Height: 1080}, "v360=input=e:output=flat:in_stereo=tb:out_stereo=2d:h_fov=90:v_fov=58.72:yaw=-30:w=1920:h=1080"},
+	}
+
+	for _, c := range cases {
+		filter, err := c.remap.Filter()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if filter != c.filter {
+			t.Errorf("expected %s, got %s", c.filter, filter)
+		}
+	}
+
+	for _, remap := range []Remap{
+		{From: hls.Layout{Projection: hls.ProjectionEquirectangular}, To: hls.ProjectionEquirectangular},
+		{From: hls.Layout{}, To: hls.ProjectionCubemap},
+		{From: hls.Layout{Projection: hls.ProjectionEquirectangular, Stereo: hls.StereoMultiview}, To: hls.ProjectionCubemap},
+	} {
+		if _, err := remap.Filter(); !errors.Is(err, ErrUnsupportedRemap) {
+			t.Errorf("expected ErrUnsupportedRemap for %+v, got %v", remap, err)
+		}
+	}
+}
+
+func TestRemapLayout(t *testing.T) {
+	remap := Remap{From: hls.Layout{Projection: hls.ProjectionEquirectangular, Stereo: hls.StereoTopBottom, Resolution: "3840x3840"}, To: hls.ProjectionRectilinear, Width: 1920, Height: 1080}
+	if layout := remap.Layout(); layout != (hls.Layout{Projection: hls.ProjectionRectilinear, Stereo: hls.StereoMono, Resolution: "1920x1080"}) {
+		t.Errorf("expected a flat mono window, got %+v", layout)
+	}
+}
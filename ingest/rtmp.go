@@ -0,0 +1,366 @@
+package ingest
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"vrmix/logging"
+)
+
+// handshakeSize is the size of the C1/S1 and C2/S2 handshake packets.
+const handshakeSize = 1536
+
+// rtmpStreamID is the message stream ID given to the publisher by createStream.
+const rtmpStreamID = 1
+
+// flvHeader starts the FLV stream fed to the packager, announcing audio and video.
+var flvHeader = []byte{'F', 'L', 'V', 1, 5, 0, 0, 0, 9, 0, 0, 0, 0}
+
+// ErrRTMPVersion indicates that a client asked for an RTMP version other than 3.
+var ErrRTMPVersion = errors.New("unsupported RTMP version")
+
+// RTMPServer receives live pushes over RTMP, like from OBS, publishing them as streams packaged into HLS.
+type RTMPServer struct {
+	Addr    string        // Address to listen on, defaults to ":1935"
+	Streams *Streams      // Streams the pushes are published to
+	Timeout time.Duration // Time without receiving anything before a client is disconnected, defaults to 30 seconds
+
+	// Authorize maps the application and stream key of a publish to the stream name, returning false to reject it, nil publishes the key as the name.
+	Authorize func(app string, key string) (string, bool)
+}
+
+// ListenAndServe listens on the address, serving clients until the context is canceled.
+func (s *RTMPServer) ListenAndServe(ctx context.Context) error {
+	addr := s.Addr
+	if addr == "" {
+		addr = ":1935"
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	return s.Serve(ctx, listener)
+}
+
+// Serve serves clients accepted from the listener until the context is canceled, closing the listener.
+func (s *RTMPServer) Serve(ctx context.Context, listener net.Listener) error {
+	stop := context.AfterFunc(ctx, func() { listener.Close() })
+	defer stop()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+
+			return err
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.serveConn(ctx, conn)
+		}()
+	}
+}
+
+// timeout returns the time without receiving anything before a client is disconnected.
+func (s *RTMPServer) timeout() time.Duration {
+	if s.Timeout <= 0 {
+		return 30 * time.Second
+	}
+
+	return s.Timeout
+}
+
+// serveConn serves a client until it disconnects or the context is canceled.
+func (s *RTMPServer) serveConn(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	logger := s.Streams.logger().With(slog.String("remote", conn.RemoteAddr().String()))
+
+	conn.SetDeadline(time.Now().Add(s.timeout()))
+	if err := serverHandshake(conn); err != nil {
+		logger.Debug("rtmp handshake failed", logging.Err(err))
+		return
+	}
+
+	c := &rtmpConn{server: s, ctx: ctx, conn: conn, reader: newChunkReader(conn), writer: newChunkWriter(conn)}
+	defer c.unpublish()
+
+	for {
+		conn.SetDeadline(time.Now().Add(s.timeout()))
+
+		message, err := c.reader.readMessage()
+		if err != nil {
+			if !errors.Is(err, io.EOF) && ctx.Err() == nil {
+				logger.Debug("rtmp connection closed", logging.Err(err))
+			}
+			return
+		}
+
+		if err := c.handle(message); err != nil {
+			logger.Warn("rtmp connection failed", logging.Err(err))
+			return
+		}
+	}
+}
+
+// serverHandshake performs the plain RTMP handshake, echoing the packet of the client.
+func serverHandshake(conn io.ReadWriter) error {
+	c0c1 := make([]byte, 1+handshakeSize)
+	if _, err := io.ReadFull(conn, c0c1); err != nil {
+		return err
+	}
+
+	if c0c1[0] != 3 {
+		return ErrRTMPVersion
+	}
+
+	s0s1s2 := make([]byte, 1+2*handshakeSize)
+	s0s1s2[0] = 3
+	binary.BigEndian.PutUint32(s0s1s2[1:], uint32(time.Now().Unix()))
+	rand.Read(s0s1s2[9 : 1+handshakeSize])
+	copy(s0s1s2[1+handshakeSize:], c0c1[1:])
+
+	if _, err := conn.Write(s0s1s2); err != nil {
+		return err
+	}
+
+	c2 := make([]byte, handshakeSize)
+	_, err := io.ReadFull(conn, c2)
+	return err
+}
+
+// rtmpConn is the state of a client connection.
+type rtmpConn struct {
+	server *RTMPServer
+	ctx    context.Context
+	conn   net.Conn
+	reader *chunkReader
+	writer *chunkWriter
+
+	app         string
+	windowSize  uint32
+	acknowledge uint64
+
+	pipe      *io.PipeWriter
+	published chan struct{}
+}
+
+// handle handles a message from the client.
+func (c *rtmpConn) handle(message rtmpMessage) error {
+	if c.windowSize > 0 && c.reader.read-c.acknowledge >= uint64(c.windowSize) {
+		c.acknowledge = c.reader.read
+		if err := c.control(msgAcknowledgement, binary.BigEndian.AppendUint32(nil, uint32(c.reader.read))); err != nil {
+			return err
+		}
+	}
+
+	switch message.typeID {
+	case msgSetChunkSize:
+		if len(message.payload) < 4 {
+			return io.ErrUnexpectedEOF
+		}
+
+		size := binary.BigEndian.Uint32(message.payload) & 0x7fffffff
+		c.reader.chunkSize = max(min(size, maxMessageSize), 1)
+	case msgWindowAckSize:
+		if len(message.payload) < 4 {
+			return io.ErrUnexpectedEOF
+		}
+
+		c.windowSize = binary.BigEndian.Uint32(message.payload)
+	case msgCommandAMF3:
+		if len(message.payload) > 0 {
+			return c.command(message.payload[1:])
+		}
+	case msgCommandAMF0:
+		return c.command(message.payload)
+	case msgDataAMF0, msgDataAMF3:
+		payload := message.payload
+		if message.typeID == msgDataAMF3 && len(payload) > 0 {
+			payload = payload[1:]
+		}
+
+		if values, _ := amfDecodeAll(payload); len(values) > 0 && values[0] == "@setDataFrame" {
+			var buffer bytes.Buffer
+			amfEncode(&buffer, "@setDataFrame")
+			payload = payload[buffer.Len():]
+		}
+
+		return c.writeTag(msgDataAMF0, message.timestamp, payload)
+	case msgAudio, msgVideo:
+		return c.writeTag(message.typeID, message.timestamp, message.payload)
+	}
+
+	return nil
+}
+
+// control sends a protocol control message.
+func (c *rtmpConn) control(typeID uint8, payload []byte) error {
+	return c.writer.writeMessage(2, rtmpMessage{typeID: typeID, payload: payload})
+}
+
+// reply sends a command message on the message stream.
+func (c *rtmpConn) reply(streamID uint32, values ...any) error {
+	var buffer bytes.Buffer
+	amfEncode(&buffer, values...)
+
+	chunkStreamID := uint8(3)
+	if streamID != 0 {
+		chunkStreamID = 5
+	}
+
+	return c.writer.writeMessage(chunkStreamID, rtmpMessage{typeID: msgCommandAMF0, streamID: streamID, payload: buffer.Bytes()})
+}
+
+// status sends an onStatus command to the publisher.
+func (c *rtmpConn) status(level string, code string, description string) error {
+	return c.reply(rtmpStreamID, "onStatus", 0, nil, map[string]any{"level": level, "code": code, "description": description})
+}
+
+// command handles an AMF0 command from the client.
+func (c *rtmpConn) command(payload []byte) error {
+	values, err := amfDecodeAll(payload)
+	if err != nil {
+		return err
+	}
+
+	if len(values) < 2 {
+		return nil
+	}
+
+	name, _ := values[0].(string)
+	transaction := values[1]
+
+	switch name {
+	case "connect":
+		if len(values) > 2 {
+			if object, ok := values[2].(map[string]any); ok {
+				c.app, _ = object["app"].(string)
+			}
+		}
+
+		if err := c.control(msgWindowAckSize, binary.BigEndian.AppendUint32(nil, 2500000)); err != nil {
+			return err
+		}
+
+		if err := c.control(msgSetPeerBandwidth, append(binary.BigEndian.AppendUint32(nil, 2500000), 2)); err != nil {
+			return err
+		}
+
+		if err := c.control(msgSetChunkSize, binary.BigEndian.AppendUint32(nil, 4096)); err != nil {
+			return err
+		}
+		c.writer.chunkSize = 4096
+
+		return c.reply(0, "_result", transaction,
+			map[string]any{"fmsVer": "FMS/3,0,1,123", "capabilities": 31},
+			map[string]any{"level": "status", "code": "NetConnection.Connect.Success", "description": "Connection succeeded.", "objectEncoding": 0},
+		)
+	case "releaseStream", "FCPublish":
+		return c.reply(0, "_result", transaction, nil)
+	case "createStream":
+		return c.reply(0, "_result", transaction, nil, rtmpStreamID)
+	case "publish":
+		key := ""
+		if len(values) > 3 {
+			key, _ = values[3].(string)
+		}
+
+		return c.publish(key)
+	case "FCUnpublish", "deleteStream", "closeStream":
+		c.unpublish()
+	}
+
+	return nil
+}
+
+// publish starts publishing the stream of the key.
+func (c *rtmpConn) publish(key string) error {
+	if c.pipe != nil {
+		return c.status("error", "NetStream.Publish.BadName", "Already publishing.")
+	}
+
+	name, ok := strings.TrimPrefix(key, "/"), true
+	if c.server.Authorize != nil {
+		name, ok = c.server.Authorize(c.app, key)
+	}
+
+	if !ok || !validName(name) || c.server.Streams.isActive(name) {
+		c.status("error", "NetStream.Publish.BadName", "Stream key rejected.")
+		return ErrInvalidName
+	}
+
+	reader, writer := io.Pipe()
+	c.pipe = writer
+	c.published = make(chan struct{})
+
+	go func() {
+		defer close(c.published)
+
+		err := c.server.Streams.Publish(c.ctx, name, Input{Reader: reader, Format: "flv"})
+		if err == nil {
+			err = io.ErrClosedPipe
+		}
+		reader.CloseWithError(err)
+	}()
+
+	if err := c.control(msgUserControl, []byte{0, 0, 0, 0, 0, rtmpStreamID}); err != nil {
+		return err
+	}
+
+	if err := c.status("status", "NetStream.Publish.Start", "Publishing "+name+"."); err != nil {
+		return err
+	}
+
+	_, err := c.pipe.Write(flvHeader)
+	return err
+}
+
+// unpublish ends the stream being published, waiting for the packager to finish.
+func (c *rtmpConn) unpublish() {
+	if c.pipe == nil {
+		return
+	}
+
+	c.pipe.Close()
+	<-c.published
+	c.pipe = nil
+}
+
+// writeTag writes an FLV tag with the media to the packager, ignoring media sent before publishing.
+func (c *rtmpConn) writeTag(typeID uint8, timestamp uint32, data []byte) error {
+	if c.pipe == nil {
+		return nil
+	}
+
+	size := len(data)
+	tag := make([]byte, 11, 11+size+4)
+	tag[0] = typeID
+	tag[1], tag[2], tag[3] = byte(size>>16), byte(size>>8), byte(size)
+	tag[4], tag[5], tag[6], tag[7] = byte(timestamp>>16), byte(timestamp>>8), byte(timestamp), byte(timestamp>>24)
+	tag = append(tag, data...)
+	tag = binary.BigEndian.AppendUint32(tag, uint32(11+size))
+
+	_, err := c.pipe.Write(tag)
+	return err
+}
@@ -0,0 +1,227 @@
+package ingest
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// rtmpClient is a minimal publishing client speaking to the server under test
+type rtmpClient struct {
+	t      *testing.T
+	conn   net.Conn
+	reader *chunkReader
+	writer *chunkWriter
+}
+
+// dialRTMP connects and performs the handshake
+func dialRTMP(t *testing.T, addr string) *rtmpClient {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	c0c1 := make([]byte, 1+handshakeSize)
+	c0c1[0] = 3
+	copy(c0c1[9:], "client random")
+	conn.Write(c0c1)
+
+	s0s1s2 := make([]byte, 1+2*handshakeSize)
+	if _, err := io.ReadFull(conn, s0s1s2); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(s0s1s2[1+handshakeSize:], c0c1[1:]) {
+		t.Fatal("expected S2 to echo C1")
+	}
+	conn.Write(s0s1s2[1 : 1+handshakeSize])
+
+	return &rtmpClient{t: t, conn: conn, reader: newChunkReader(conn), writer: newChunkWriter(conn)}
+}
+
+// command sends an AMF0 command
+func (c *rtmpClient) command(streamID uint32, values ...any) {
+	var buffer bytes.Buffer
+	amfEncode(&buffer, values...)
+
+	if err := c.writer.writeMessage(3, rtmpMessage{typeID: msgCommandAMF0, streamID: streamID, payload: buffer.Bytes()}); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// expect reads messages until a command with the name, returning its values
+func (c *rtmpClient) expect(name string) []any {
+	for {
+		message, err := c.reader.readMessage()
+		if err != nil {
+			c.t.Fatalf("expected %s, got %v", name, err)
+		}
+
+		switch message.typeID {
+		case msgSetChunkSize:
+			c.reader.chunkSize = binary.BigEndian.Uint32(message.payload)
+		case msgCommandAMF0:
+			values, err := amfDecodeAll(message.payload)
+			if err != nil {
+				c.t.Fatal(err)
+			}
+
+			if values[0] == name {
+				return values
+			}
+		}
+	}
+}
+
+func TestRTMPServerPublish(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	packager := newFakePackager()
+	streams := &Streams{Dir: t.TempDir(), Packager: packager}
+	server := &RTMPServer{Streams: streams, Authorize: func(app string, key string) (string, bool) {
+		return "cam", app == "live" && key == "secret-key"
+	}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error)
+	go func() { served <- server.Serve(ctx, listener) }()
+
+	client := dialRTMP(t, listener.Addr().String())
+
+	// A long tcUrl makes the connect command span several chunks
+	client.command(0, "connect", 1, map[string]any{"app": "live", "tcUrl": "rtmp://127.0.0.1/live/" + string(bytes.Repeat([]byte("x"), 300))})
+	if result := client.expect("_result"); result[3].(map[string]any)["code"] != "NetConnection.Connect.Success" {
+		t.Errorf("expected a successful connect, got %v", result)
+	}
+
+	client.command(0, "createStream", 2, nil)
+	if result := client.expect("_result"); result[3] != float64(rtmpStreamID) {
+		t.Errorf("expected stream %d, got %v", rtmpStreamID, result)
+	}
+
+	client.command(rtmpStreamID, "publish", 3, nil, "secret-key", "live")
+	if status := client.expect("onStatus"); status[3].(map[string]any)["code"] != "NetStream.Publish.Start" {
+		t.Errorf("expected the publish to start, got %v", status)
+	}
+
+	<-packager.started
+	if names := streams.Active(); len(names) != 1 || names[0] != "cam" {
+		t.Errorf("expected cam to be published, got %v", names)
+	}
+
+	video := []byte{0x17, 0x01, 0, 0, 0, 0xde, 0xad}
+	client.writer.writeMessage(6, rtmpMessage{typeID: msgVideo, streamID: rtmpStreamID, timestamp: 40, payload: video})
+	client.command(0, "deleteStream", 4, nil, rtmpStreamID)
+
+	for deadline := time.Now().Add(5 * time.Second); len(streams.Active()) > 0; {
+		if time.Now().After(deadline) {
+			t.Fatal("expected deleteStream to end the publish")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	cancel()
+	if err := <-served; err != nil {
+		t.Errorf("expected the server to stop cleanly, got %v", err)
+	}
+
+	data := packager.recorded()
+	if !bytes.HasPrefix(data, flvHeader) {
+		t.Fatalf("expected an FLV header, got %x", data)
+	}
+
+	tag := data[len(flvHeader):]
+	if len(tag) != 11+len(video)+4 || tag[0] != msgVideo || tag[6] != 40 || !bytes.Equal(tag[11:11+len(video)], video) {
+		t.Errorf("expected the video tag, got %x", tag)
+	}
+}
+
+func TestRTMPServerRejectsKey(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := &RTMPServer{Streams: &Streams{Dir: t.TempDir(), Packager: newFakePackager()}, Authorize: func(app string, key string) (string, bool) {
+		return "", false
+	}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go server.Serve(ctx, listener)
+
+	client := dialRTMP(t, listener.Addr().String())
+	client.command(0, "connect", 1, map[string]any{"app": "live"})
+	client.expect("_result")
+	client.command(rtmpStreamID, "publish", 2, nil, "wrong", "live")
+
+	if status := client.expect("onStatus"); status[3].(map[string]any)["code"] != "NetStream.Publish.BadName" {
+		t.Errorf("expected the key to be rejected, got %v", status)
+	}
+}
+
+func TestChunkReaderExtendedTimestamp(t *testing.T) {
+	var buffer bytes.Buffer
+	writer := newChunkWriter(&buffer)
+	writer.chunkSize = 4
+	writer.writeMessage(4, rtmpMessage{typeID: msgAudio, streamID: 1, timestamp: 0x1000000, payload: []byte("audio data")})
+
+	reader := newChunkReader(&buffer)
+	reader.chunkSize = 4
+
+	message, err := reader.readMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if message.timestamp != 0x1000000 || string(message.payload) != "audio data" || message.streamID != 1 {
+		t.Errorf("expected the audio message, got %+v", message)
+	}
+}
+
+func TestAMFRoundTrip(t *testing.T) {
+	var buffer bytes.Buffer
+	amfEncode(&buffer, "connect", 1, true, nil, map[string]any{"app": "live", "nested": map[string]any{"n": 2.5}}, []any{"a"})
+
+	values, err := amfDecodeAll(buffer.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(values) != 6 || values[0] != "connect" || values[1] != 1.0 || values[2] != true || values[3] != nil {
+		t.Fatalf("expected the encoded values, got %v", values)
+	}
+
+	if object := values[4].(map[string]any); object["app"] != "live" || object["nested"].(map[string]any)["n"] != 2.5 {
+		t.Errorf("expected the encoded object, got %v", object)
+	}
+}
+
+func TestChunkReaderLimits(t *testing.T) {
+	var data []byte
+	for i := range maxChunkStreams + 1 {
+		// Each chunk stream starts a message of two chunks, left partial.
+		data = append(data, 0, byte(i), 0, 0, 0, 0, 1, 0, msgVideo, 0, 0, 0, 0)
+		data = append(data, make([]byte, 128)...)
+	}
+
+	if _, err := newChunkReader(bytes.NewReader(data)).readMessage(); err != errTooManyChunkStreams {
+		t.Errorf("expected errTooManyChunkStreams, got %v", err)
+	}
+
+	reader := newChunkReader(bytes.NewReader(data))
+	reader.chunkSize = maxMessageSize
+	reader.buffered = maxBufferedSize - 1
+	if _, err := reader.readMessage(); err != errBufferFull {
+		t.Errorf("expected errBufferFull, got %v", err)
+	}
+}
@@ -0,0 +1,280 @@
+package ingest
+
+import (
+	"bufio"
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrRTSPUnauthorized indicates that the camera rejected the credentials.
+	ErrRTSPUnauthorized = errors.New("RTSP credentials rejected")
+
+	// ErrRTSPResponse indicates that the camera answered with a malformed response.
+	ErrRTSPResponse = errors.New("malformed RTSP response")
+)
+
+// RTSPStatusError records an unexpected status answered by a camera.
+type RTSPStatusError struct {
+	StatusCode int    // Status code answered by the camera
+	Status     string // Reason phrase answered by the camera
+}
+
+func (e *RTSPStatusError) Error() string {
+	return "unexpected RTSP status " + strconv.Itoa(e.StatusCode) + " " + e.Status
+}
+
+// RTSPIngest pulls a live stream from an IP camera over RTSP interleaved in TCP, publishing it as a stream and pulling it again whenever it drops.
+type RTSPIngest struct {
+	Name          string        // Name of the stream published
+	URL           string        // URL of the camera stream, like "rtsp://camera.local/stream1"
+	User          string        // User for basic or digest authentication, empty to use the user of the URL
+	Password      string        // Password of the user
+	CodecArgs     []string      // Codec arguments transcoding the camera, nil to use the packager defaults
+	Timeout       time.Duration // Timeout of the requests to the camera, defaults to 10 seconds
+	Streams       *Streams      // Streams the camera is published to
+	RetryInterval time.Duration // Time waited before pulling again after the stream drops, defaults to 2 seconds
+}
+
+// credentials returns the URL without user info, and the user and password to authenticate with.
+func (s *RTSPIngest) credentials() (*url.URL, string, string, error) {
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	if u.Scheme != "rtsp" && u.Scheme != "rtsps" {
+		return nil, "", "", ErrRTSPResponse
+	}
+
+	user, password := s.User, s.Password
+	if user == "" && u.User != nil {
+		user = u.User.Username()
+		password, _ = u.User.Password()
+	}
+
+	u.User = nil
+	return u, user, password, nil
+}
+
+// Describe asks the camera for the session description of the stream, authenticating with basic or digest authentication when challenged.
+func (s *RTSPIngest) Describe(ctx context.Context) (string, error) {
+	u, user, password, err := s.credentials()
+	if err != nil {
+		return "", err
+	}
+
+	timeout := s.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "554")
+	}
+
+	dialer := net.Dialer{Timeout: timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", host)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	c := &rtspConn{conn: conn, reader: textproto.NewReader(bufio.NewReader(conn))}
+
+	uri := u.String()
+	code, status, header, body, err := c.request("DESCRIBE", uri, "")
+	if err != nil {
+		return "", err
+	}
+
+	if code == 401 {
+		if user == "" {
+			return "", ErrRTSPUnauthorized
+		}
+
+		authorization, err := rtspAuthorization(header.Values("Www-Authenticate"), "DESCRIBE", uri, user, password)
+		if err != nil {
+			return "", err
+		}
+
+		if code, status, _, body, err = c.request("DESCRIBE", uri, authorization); err != nil {
+			return "", err
+		}
+
+		if code == 401 {
+			return "", ErrRTSPUnauthorized
+		}
+	}
+
+	if code != 200 {
+		return "", &RTSPStatusError{StatusCode: code, Status: status}
+	}
+
+	return body, nil
+}
+
+// Run pulls the stream until the context is canceled, pulling it again after it drops, and stopping when the camera rejects the credentials.
+func (s *RTSPIngest) Run(ctx context.Context) error {
+	if _, err := s.Describe(ctx); errors.Is(err, ErrRTSPUnauthorized) {
+		return err
+	}
+
+	u, user, password, err := s.credentials()
+	if err != nil {
+		return err
+	}
+
+	if user != "" {
+		u.User = url.UserPassword(user, password)
+	}
+
+	input := Input{URL: u.String(), Args: []string{"-rtsp_transport", "tcp"}, CodecArgs: s.CodecArgs}
+	return republish(ctx, s.Streams, s.Name, input, s.RetryInterval)
+}
+
+// rtspConn is a connection to a camera, sending requests one at a time.
+type rtspConn struct {
+	conn   net.Conn
+	reader *textproto.Reader
+	cseq   int
+}
+
+// request sends a request without body, returning the status and headers answered and the body, if any.
+func (c *rtspConn) request(method string, uri string, authorization string) (int, string, textproto.MIMEHeader, string, error) {
+	c.cseq += 1
+
+	request := fmt.Sprintf("%s %s RTSP/1.0\r\nCSeq: %d\r\nUser-Agent: VRMix\r\nAccept: application/sdp\r\n", method, uri, c.cseq)
+	if authorization != "" {
+		request += "Authorization: " + authorization + "\r\n"
+	}
+
+	if _, err := io.WriteString(c.conn, request+"\r\n"); err != nil {
+		return 0, "", nil, "", err
+	}
+
+	line, err := c.reader.ReadLine()
+	if err != nil {
+		return 0, "", nil, "", err
+	}
+
+	version, rest, _ := strings.Cut(line, " ")
+	codeText, status, _ := strings.Cut(rest, " ")
+	code, err := strconv.Atoi(codeText)
+	if !strings.HasPrefix(version, "RTSP/") || err != nil {
+		return 0, "", nil, "", ErrRTSPResponse
+	}
+
+	header, err := c.reader.ReadMIMEHeader()
+	if err != nil {
+		return 0, "", nil, "", err
+	}
+
+	length, _ := strconv.Atoi(header.Get("Content-Length"))
+	if length < 0 || length > 1<<20 {
+		return 0, "", nil, "", ErrRTSPResponse
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(c.reader.R, body); err != nil {
+		return 0, "", nil, "", err
+	}
+
+	return code, status, header, string(body), nil
+}
+
+// rtspAuthorization answers the strongest challenge, preferring digest over basic authentication.
+func rtspAuthorization(challenges []string, method string, uri string, user string, password string) (string, error) {
+	basic := false
+
+	for _, challenge := range challenges {
+		scheme, params, _ := strings.Cut(challenge, " ")
+
+		switch strings.ToLower(scheme) {
+		case "digest":
+			return digestAuthorization(parseAuthParams(params), method, uri, user, password), nil
+		case "basic":
+			basic = true
+		}
+	}
+
+	if !basic {
+		return "", ErrRTSPUnauthorized
+	}
+
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+password)), nil
+}
+
+// parseAuthParams parses the comma separated parameters of a challenge, which may be quoted.
+func parseAuthParams(params string) map[string]string {
+	values := map[string]string{}
+
+	for params = strings.TrimSpace(params); params != ""; params = strings.TrimSpace(params) {
+		var key, value string
+		key, params, _ = strings.Cut(params, "=")
+
+		if strings.HasPrefix(params, `"`) {
+			value, params, _ = strings.Cut(params[1:], `"`)
+			_, params, _ = strings.Cut(params, ",")
+		} else {
+			value, params, _ = strings.Cut(params, ",")
+		}
+
+		values[strings.ToLower(strings.TrimSpace(key))] = strings.TrimSpace(value)
+	}
+
+	return values
+}
+
+// md5Hex returns the hexadecimal MD5 of the text.
+func md5Hex(text string) string {
+	sum := md5.Sum([]byte(text))
+	return hex.EncodeToString(sum[:])
+}
+
+// digestAuthorization answers a digest challenge, with "auth" quality of protection when the camera offers it.
+func digestAuthorization(challenge map[string]string, method string, uri string, user string, password string) string {
+	realm, nonce := challenge["realm"], challenge["nonce"]
+	ha1 := md5Hex(user + ":" + realm + ":" + password)
+	ha2 := md5Hex(method + ":" + uri)
+
+	authorization := fmt.Sprintf(`Digest username="%s", realm="%s", nonce="%s", uri="%s"`, user, realm, nonce, uri)
+
+	qop := ""
+	for option := range strings.SplitSeq(challenge["qop"], ",") {
+		if strings.TrimSpace(option) == "auth" {
+			qop = "auth"
+		}
+	}
+
+	if qop == "" {
+		authorization += fmt.Sprintf(`, response="%s"`, md5Hex(ha1+":"+nonce+":"+ha2))
+	} else {
+		random := make([]byte, 8)
+		rand.Read(random)
+		cnonce := hex.EncodeToString(random)
+
+		response := md5Hex(ha1 + ":" + nonce + ":00000001:" + cnonce + ":auth:" + ha2)
+		authorization += fmt.Sprintf(`, response="%s", qop=auth, nc=00000001, cnonce="%s"`, response, cnonce)
+	}
+
+	if opaque, ok := challenge["opaque"]; ok {
+		authorization += fmt.Sprintf(`, opaque="%s"`, opaque)
+	}
+
+	return authorization
+}
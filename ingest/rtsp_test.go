@@ -0,0 +1,122 @@
+package ingest
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"net/textproto"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sdp is the session description answered by the fake camera
+const sdp = "v=0\r\nm=video 0 RTP/AVP 96\r\na=rtpmap:96 H264/90000\r\n"
+
+// newCamera starts a fake camera requiring digest authentication as "admin" with password "hunter22"
+func newCamera(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer conn.Close()
+				reader := textproto.NewReader(bufio.NewReader(conn))
+
+				for {
+					line, err := reader.ReadLine()
+					if err != nil {
+						return
+					}
+
+					header, _ := reader.ReadMIMEHeader()
+					method, rest, _ := strings.Cut(line, " ")
+					uri, _, _ := strings.Cut(rest, " ")
+
+					challenge := map[string]string{"realm": "camera", "nonce": "abc123"}
+					expected := digestAuthorization(challenge, method, uri, "admin", "hunter22")
+					params := parseAuthParams(strings.TrimPrefix(header.Get("Authorization"), "Digest "))
+
+					if params["response"] == "" || params["response"] != parseAuthParams(strings.TrimPrefix(expected, "Digest "))["response"] {
+						fmt.Fprintf(conn, "RTSP/1.0 401 Unauthorized\r\nCSeq: %s\r\nWWW-Authenticate: Basic realm=\"camera\"\r\nWWW-Authenticate: Digest realm=\"camera\", nonce=\"abc123\"\r\n\r\n", header.Get("CSeq"))
+						continue
+					}
+
+					fmt.Fprintf(conn, "RTSP/1.0 200 OK\r\nCSeq: %s\r\nContent-Type: application/sdp\r\nContent-Length: %d\r\n\r\n%s", header.Get("CSeq"), len(sdp), sdp)
+				}
+			}()
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+func TestRTSPIngestDescribe(t *testing.T) {
+	addr := newCamera(t)
+
+	s := &RTSPIngest{URL: "rtsp://admin:hunter22@" + addr + "/stream1"}
+	description, err := s.Describe(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if description != sdp {
+		t.Errorf("expected the session description, got %q", description)
+	}
+
+	s = &RTSPIngest{URL: "rtsp://" + addr + "/stream1", User: "admin", Password: "wrong"}
+	if _, err := s.Describe(context.Background()); err != ErrRTSPUnauthorized {
+		t.Errorf("expected ErrRTSPUnauthorized, got %v", err)
+	}
+
+	if err := s.Run(context.Background()); err != ErrRTSPUnauthorized {
+		t.Errorf("expected Run to stop on rejected credentials, got %v", err)
+	}
+}
+
+func TestRTSPIngestRun(t *testing.T) {
+	addr := newCamera(t)
+	packager := &endingPackager{published: make(chan Input, 1)}
+
+	s := &RTSPIngest{Name: "door", URL: "rtsp://" + addr + "/stream1", User: "admin", Password: "hunter22", CodecArgs: []string{"-c:v", "libx264"}, Streams: &Streams{Dir: t.TempDir(), Packager: packager}, RetryInterval: time.Millisecond}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- s.Run(ctx) }()
+
+	select {
+	case input := <-packager.published:
+		if input.URL != "rtsp://admin:hunter22@"+addr+"/stream1" || !slices.Equal(input.Args, []string{"-rtsp_transport", "tcp"}) || input.CodecArgs[1] != "libx264" {
+			t.Errorf("expected the camera pulled over TCP with credentials, got %+v", input)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the camera to be published")
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("expected a clean stop, got %v", err)
+	}
+}
+
+func TestRTSPAuthorizationBasic(t *testing.T) {
+	authorization, err := rtspAuthorization([]string{`Basic realm="camera"`}, "DESCRIBE", "rtsp://camera/", "admin", "secret")
+	if err != nil || authorization != "Basic YWRtaW46c2VjcmV0" {
+		t.Errorf("expected basic credentials, got %q and %v", authorization, err)
+	}
+
+	if _, err := rtspAuthorization([]string{`Bearer realm="camera"`}, "DESCRIBE", "rtsp://camera/", "admin", "secret"); err != ErrRTSPUnauthorized {
+		t.Errorf("expected ErrRTSPUnauthorized for unknown schemes, got %v", err)
+	}
+}
@@ -0,0 +1,192 @@
+package ingest
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net"
+	"net/url"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"vrmix/encrypt"
+	"vrmix/hls"
+)
+
+// SampleAESPackager is a Packager encrypting the samples of the media with SAMPLE-AES, as some Apple device policies require for protected streams, remuxing the input with ffmpeg into Shaka Packager, which writes a master playlist named PlaylistName.
+type SampleAESPackager struct {
+	FFmpeg          string        // Path to the ffmpeg binary, defaults to "ffmpeg"
+	Packager        string        // Path to the Shaka Packager binary, defaults to "packager"
+	SegmentDuration time.Duration // Target duration of the segments, defaults to 4 seconds
+	ListSize        int           // Segments kept in the playlists, defaults to 6
+	Keys            encrypt.Keys  // Keys of the streams, the name of the directory of each stream being its channel
+
+	// KeyURL returns the URI of a key signaled in the playlists, defaults to "/keys/{channel}/{id}" served by an encrypt.KeyHandler.
+	KeyURL func(channel string, id string) string
+
+	prefixArgs []string
+}
+
+// keyURL returns the URI of the key of the channel.
+func (p *SampleAESPackager) keyURL(channel string, id string) string {
+	if p.KeyURL != nil {
+		return p.KeyURL(channel, id)
+	}
+
+	return "/keys/" + url.PathEscape(channel) + "/" + url.PathEscape(id)
+}
+
+// packagerArgs returns the arguments of Shaka Packager reading the remuxed input from the UDP address and encrypting it with the key.
+func (p *SampleAESPackager) packagerArgs(dir string, address string, channel string, key encrypt.ContentKey) []string {
+	listSize := p.ListSize
+	if listSize <= 0 {
+		listSize = 6
+	}
+
+	segmentDuration := hls.SegmentDuration(p.SegmentDuration, 4*time.Second)
+
+	// Shaka Packager identifies the keys with 16 bytes, derived from the ID of the content key.
+	keyID := sha256.Sum256([]byte(key.ID))
+	input := "in=udp://" + address
+
+	args := append([]string{}, p.prefixArgs...)
+	return append(args,
+		input+",stream=video,segment_template="+filepath.Join(dir, "video$Number$.ts")+",playlist_name=video.m3u8",
+		input+",stream=audio,segment_template="+filepath.Join(dir, "audio$Number$.ts")+",playlist_name=audio.m3u8,hls_group_id=audio",
+		"--protection_scheme", "cbcs",
+		"--enable_raw_key_encryption",
+		"--keys", "label=:key_id="+hex.EncodeToString(keyID[:16])+":key="+hex.EncodeToString(key.Key),
+		"--clear_lead", "0",
+		"--hls_key_uri", p.keyURL(channel, key.ID),
+		"--segment_duration", strconv.FormatFloat(segmentDuration.Seconds(), 'f', -1, 64),
+		"--time_shift_buffer_depth", strconv.FormatFloat(segmentDuration.Seconds()*float64(listSize), 'f', -1, 64),
+		"--hls_playlist_type", "LIVE",
+		"--hls_master_playlist_output", filepath.Join(dir, PlaylistName),
+	)
+}
+
+// ffmpegArgs returns the arguments of ffmpeg remuxing the input into MPEG-TS sent to the UDP address.
+func (p *SampleAESPackager) ffmpegArgs(address string, input Input) []string {
+	codecArgs := input.CodecArgs
+	if codecArgs == nil {
+		codecArgs = []string{"-c", "copy"}
+	}
+
+	args := append([]string{}, p.prefixArgs...)
+	args = append(args, "-hide_banner", "-loglevel", "error", "-nostdin")
+	args = append(args, input.Args...)
+	if input.Format != "" {
+		args = append(args, "-f", input.Format)
+	}
+
+	if input.Reader != nil {
+		args = append(args, "-i", "pipe:0")
+	} else {
+		args = append(args, "-i", input.URL)
+	}
+
+	args = append(args, codecArgs...)
+	return append(args, "-f", "mpegts", "udp://"+address+"?pkt_size=1316")
+}
+
+// freeUDPAddress returns a loopback UDP address nothing listens on.
+func freeUDPAddress() (string, error) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	return conn.LocalAddr().String(), nil
+}
+
+// commandError returns the error of a command, joined with its standard error.
+func commandError(err error, stderr *bytes.Buffer) error {
+	if message := strings.TrimSpace(stderr.String()); message != "" {
+		return errors.Join(err, errors.New(message))
+	}
+
+	return err
+}
+
+// Package encrypts the input with the current key of the stream until the input ends or the context is canceled.
+func (p *SampleAESPackager) Package(ctx context.Context, dir string, input Input) error {
+	ffmpeg, packager := p.FFmpeg, p.Packager
+	if ffmpeg == "" {
+		ffmpeg = "ffmpeg"
+	}
+
+	if packager == "" {
+		packager = "packager"
+	}
+
+	channel := filepath.Base(dir)
+	key, err := p.Keys.Current(ctx, channel)
+	if err != nil {
+		return err
+	}
+
+	address, err := freeUDPAddress()
+	if err != nil {
+		return err
+	}
+
+	packagerCtx, stopPackager := context.WithCancel(ctx)
+	defer stopPackager()
+
+	var packagerStderr bytes.Buffer
+	packagerCmd := exec.CommandContext(packagerCtx, packager, p.packagerArgs(dir, address, channel, key)...)
+	packagerCmd.Stderr = &packagerStderr
+	if err := packagerCmd.Start(); err != nil {
+		return err
+	}
+
+	packagerDone := make(chan error, 1)
+	go func() { packagerDone <- packagerCmd.Wait() }()
+
+	ffmpegCtx, stopFFmpeg := context.WithCancel(ctx)
+	defer stopFFmpeg()
+
+	var ffmpegStderr bytes.Buffer
+	ffmpegCmd := exec.CommandContext(ffmpegCtx, ffmpeg, p.ffmpegArgs(address, input)...)
+	ffmpegCmd.Stdin = input.Reader
+	ffmpegCmd.Stderr = &ffmpegStderr
+
+	ffmpegDone := make(chan error, 1)
+	go func() { ffmpegDone <- ffmpegCmd.Run() }()
+
+	select {
+	case err := <-packagerDone:
+		// Shaka Packager never ends on its own while reading UDP, so it failed.
+		stopFFmpeg()
+		<-ffmpegDone
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if err == nil {
+			err = errors.New("packager exited")
+		}
+
+		return commandError(err, &packagerStderr)
+	case err := <-ffmpegDone:
+		stopPackager()
+		<-packagerDone
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if err != nil {
+			return commandError(err, &ffmpegStderr)
+		}
+
+		return nil
+	}
+}
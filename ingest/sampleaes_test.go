@@ -0,0 +1,71 @@
+package ingest
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"vrmix/encrypt"
+)
+
+// TestHelperSampleAES is not a real test, it acts as ffmpeg and Shaka Packager when run by TestSampleAESPackager
+func TestHelperSampleAES(t *testing.T) {
+	if os.Getenv("VRMIX_HELPER_SAMPLE_AES") == "" {
+		return
+	}
+
+	failing := os.Getenv("VRMIX_HELPER_SAMPLE_AES") == "fail"
+	if !slices.Contains(os.Args, "--enable_raw_key_encryption") {
+		if failing {
+			time.Sleep(time.Minute)
+		}
+		os.Exit(0)
+	}
+
+	if failing {
+		fmt.Fprint(os.Stderr, "invalid key")
+		os.Exit(1)
+	}
+
+	time.Sleep(time.Minute)
+	os.Exit(0)
+}
+
+// newHelperSampleAES returns a SAMPLE-AES packager running TestHelperSampleAES as ffmpeg and Shaka Packager
+func newHelperSampleAES() *SampleAESPackager {
+	return &SampleAESPackager{FFmpeg: os.Args[0], Packager: os.Args[0], Keys: &encrypt.MemoryKeys{}, prefixArgs: []string{"-test.run=TestHelperSampleAES", "--"}}
+}
+
+func TestSampleAESPackagerArgs(t *testing.T) {
+	p := &SampleAESPackager{SegmentDuration: 2 * time.Second, ListSize: 5}
+	key := encrypt.ContentKey{ID: "k1", Key: []byte("0123456789abcdef")}
+
+	args := strings.Join(p.packagerArgs("/tmp/live/cam", "127.0.0.1:9000", "cam", key), " ")
+	for _, expected := range []string{"in=udp://127.0.0.1:9000,stream=video,segment_template=/tmp/live/cam/video$Number$.ts", "--protection_scheme cbcs", ":key=30313233343536373839616263646566", "--hls_key_uri /keys/cam/k1", "--time_shift_buffer_depth 10", "--hls_master_playlist_output /tmp/live/cam/index.m3u8"} {
+		if !strings.Contains(args, expected) {
+			t.Errorf("expected %q in %q", expected, args)
+		}
+	}
+
+	ffmpegArgs := strings.Join(p.ffmpegArgs("127.0.0.1:9000", Input{Reader: strings.NewReader(""), Format: "flv"}), " ")
+	if !strings.Contains(ffmpegArgs, "-f flv -i pipe:0 -c copy -f mpegts udp://127.0.0.1:9000") {
+		t.Errorf("expected the input remuxed to the packager, got %q", ffmpegArgs)
+	}
+}
+
+func TestSampleAESPackager(t *testing.T) {
+	t.Setenv("VRMIX_HELPER_SAMPLE_AES", "1")
+	if err := newHelperSampleAES().Package(context.Background(), t.TempDir(), Input{URL: "rtmp://camera/live"}); err != nil {
+		t.Errorf("expected the packager to stop when the input ends, got %v", err)
+	}
+
+	t.Setenv("VRMIX_HELPER_SAMPLE_AES", "fail")
+	err := newHelperSampleAES().Package(context.Background(), t.TempDir(), Input{URL: "rtmp://camera/live"})
+	if err == nil || !strings.Contains(err.Error(), "invalid key") {
+		t.Errorf("expected the packager failure, got %v", err)
+	}
+}
@@ -0,0 +1,115 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/url"
+	"strconv"
+	"time"
+
+	"vrmix/logging"
+)
+
+// SRTMode is the connection mode of an SRT ingest.
+type SRTMode string
+
+const (
+	// SRTListener waits for the sender to connect to the address.
+	SRTListener SRTMode = "listener"
+
+	// SRTCaller connects to the sender listening on the address.
+	SRTCaller SRTMode = "caller"
+)
+
+var (
+	// ErrSRTPassphrase indicates that the passphrase is not between 10 and 79 characters, as required by SRT.
+	ErrSRTPassphrase = errors.New("SRT passphrase must have between 10 and 79 characters")
+
+	// ErrSRTMode indicates that the mode is neither listener nor caller.
+	ErrSRTMode = errors.New("invalid SRT mode")
+)
+
+// SRTIngest receives a live MPEG-TS stream over SRT, publishing it as a stream and republishing it whenever the link drops.
+type SRTIngest struct {
+	Name          string        // Name of the stream published
+	Addr          string        // Address to listen on or to call, like "0.0.0.0:9000"
+	Mode          SRTMode       // Connection mode, defaults to SRTListener
+	Passphrase    string        // Passphrase encrypting the link, empty for none
+	KeyLength     int           // Length of the encryption key in bytes, 16, 24 or 32, defaults to the SRT default
+	Latency       time.Duration // Receiver latency absorbing retransmissions, defaults to the SRT default
+	StreamID      string        // Stream ID sent or expected by the link, empty for none
+	Streams       *Streams      // Streams the ingest is published to
+	RetryInterval time.Duration // Time waited before republishing after the link drops, defaults to 2 seconds
+}
+
+// URL returns the SRT URL read by the packager.
+func (s *SRTIngest) URL() (string, error) {
+	mode := s.Mode
+	if mode == "" {
+		mode = SRTListener
+	}
+
+	if mode != SRTListener && mode != SRTCaller {
+		return "", ErrSRTMode
+	}
+
+	query := url.Values{"mode": {string(mode)}}
+	if s.Passphrase != "" {
+		if len(s.Passphrase) < 10 || len(s.Passphrase) > 79 {
+			return "", ErrSRTPassphrase
+		}
+
+		query.Set("passphrase", s.Passphrase)
+		if s.KeyLength > 0 {
+			query.Set("pbkeylen", strconv.Itoa(s.KeyLength))
+		}
+	}
+
+	if s.Latency > 0 {
+		query.Set("latency", strconv.FormatInt(s.Latency.Microseconds(), 10))
+	}
+
+	if s.StreamID != "" {
+		query.Set("streamid", s.StreamID)
+	}
+
+	u := url.URL{Scheme: "srt", Host: s.Addr, RawQuery: query.Encode()}
+	return u.String(), nil
+}
+
+// Run publishes the stream until the context is canceled, republishing it after the link drops.
+func (s *SRTIngest) Run(ctx context.Context) error {
+	uri, err := s.URL()
+	if err != nil {
+		return err
+	}
+
+	return republish(ctx, s.Streams, s.Name, Input{URL: uri, Format: "mpegts"}, s.RetryInterval)
+}
+
+// republish publishes the input until the context is canceled, waiting the retry interval, 2 seconds by default, each time it ends.
+func republish(ctx context.Context, streams *Streams, name string, input Input, retryInterval time.Duration) error {
+	if retryInterval <= 0 {
+		retryInterval = 2 * time.Second
+	}
+
+	for {
+		err := streams.Publish(ctx, name, input)
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		if errors.Is(err, ErrInvalidName) || errors.Is(err, ErrStreamActive) {
+			return err
+		}
+
+		streams.logger().Warn("republishing stream", slog.String(logging.StreamKey, name), slog.Duration("retry", retryInterval), logging.Err(err))
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(retryInterval):
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package ingest
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestSRTIngestURL(t *testing.T) {
+	s := &SRTIngest{Addr: "0.0.0.0:9000", Passphrase: "correct horse", KeyLength: 32, Latency: 200 * time.Millisecond, StreamID: "cam"}
+
+	uri, err := s.URL()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u, err := url.Parse(uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	query := u.Query()
+	if u.Scheme != "srt" || u.Host != "0.0.0.0:9000" || query.Get("mode") != "listener" || query.Get("passphrase") != "correct horse" || query.Get("pbkeylen") != "32" || query.Get("latency") != "200000" || query.Get("streamid") != "cam" {
+		t.Errorf("expected a listener URL with every option, got %s", uri)
+	}
+
+	s.Passphrase = "short"
+	if _, err := s.URL(); err != ErrSRTPassphrase {
+		t.Errorf("expected ErrSRTPassphrase, got %v", err)
+	}
+
+	s.Passphrase, s.Mode = "", "rendezvous"
+	if _, err := s.URL(); err != ErrSRTMode {
+		t.Errorf("expected ErrSRTMode, got %v", err)
+	}
+}
+
+func TestSRTIngestRepublishes(t *testing.T) {
+	packager := &endingPackager{published: make(chan Input, 3)}
+	s := &SRTIngest{Name: "field", Addr: "sender:9000", Mode: SRTCaller, Streams: &Streams{Dir: t.TempDir(), Packager: packager}, RetryInterval: time.Millisecond}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- s.Run(ctx) }()
+
+	for range 2 {
+		select {
+		case input := <-packager.published:
+			if input.Format != "mpegts" || input.URL != "srt://sender:9000?mode=caller" {
+				t.Errorf("expected the SRT URL as MPEG-TS, got %+v", input)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("expected the stream to be republished")
+		}
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("expected a clean stop, got %v", err)
+	}
+}
+
+// endingPackager records the inputs, ending each one immediately as if the link dropped
+type endingPackager struct {
+	published chan Input
+}
+
+func (p *endingPackager) Package(ctx context.Context, dir string, input Input) error {
+	select {
+	case p.published <- input:
+	default:
+	}
+
+	return nil
+}
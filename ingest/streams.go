@@ -0,0 +1,242 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"vrmix/events"
+	"vrmix/logging"
+	"vrmix/report"
+	"vrmix/source"
+)
+
+// LiveURIPrefix is the prefix of the URIs of the live streams, followed by the stream name and the file inside its directory.
+const LiveURIPrefix = "live:///"
+
+var (
+	// ErrStreamActive indicates that a stream with the same name is already being published.
+	ErrStreamActive = errors.New("stream already active")
+
+	// ErrInvalidName indicates that the stream name is empty or is not a single path element.
+	ErrInvalidName = errors.New("invalid stream name")
+)
+
+// Streams keeps the live streams being packaged, serving them as a Source so channels can queue them by name.
+type Streams struct {
+	Dir      string   // Directory holding a subdirectory with the HLS output of each stream
+	Packager Packager // Packager of the streams, defaults to an FFmpegPackager
+
+	// OnChange is called when a stream starts or stops being published.
+	OnChange func(name string, active bool)
+
+	// Logger receives the events of the streams and of the ingests publishing to them, defaults to slog.Default.
+	Logger *slog.Logger
+
+	// Bus receives the StreamStarted and StreamStopped events, nil to not publish them.
+	Bus *events.Bus
+
+	// Reporter receives the failures of the streams and the panics of the packager, nil to not report them.
+	Reporter report.Reporter
+
+	mutex  sync.Mutex
+	active map[string]context.CancelFunc
+}
+
+// validName checks that the name is a single path element that is not hidden.
+func validName(name string) bool {
+	return name != "" && fs.ValidPath(name) && !strings.Contains(name, "/") && !strings.HasPrefix(name, ".")
+}
+
+// packager returns the packager of the streams.
+func (s *Streams) packager() Packager {
+	if s.Packager == nil {
+		return &FFmpegPackager{}
+	}
+
+	return s.Packager
+}
+
+// logger returns the logger of the streams.
+func (s *Streams) logger() *slog.Logger {
+	return logging.Or(s.Logger)
+}
+
+// Publish packages the input as the named stream until it ends, the context is canceled or the stream is stopped.
+func (s *Streams) Publish(ctx context.Context, name string, input Input) error {
+	if !validName(name) {
+		return ErrInvalidName
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	s.mutex.Lock()
+	if _, ok := s.active[name]; ok {
+		s.mutex.Unlock()
+		return ErrStreamActive
+	}
+
+	if s.active == nil {
+		s.active = make(map[string]context.CancelFunc)
+	}
+	s.active[name] = cancel
+	s.mutex.Unlock()
+
+	dir := filepath.Join(s.Dir, name)
+	defer func() {
+		s.mutex.Lock()
+		delete(s.active, name)
+		s.mutex.Unlock()
+
+		os.RemoveAll(dir)
+
+		if s.OnChange != nil {
+			s.OnChange(name, false)
+		}
+
+		if s.Bus != nil {
+			s.Bus.Publish(events.Event{Type: events.StreamStopped, Data: map[string]any{logging.StreamKey: name}})
+		}
+	}()
+
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	if s.OnChange != nil {
+		s.OnChange(name, true)
+	}
+
+	if s.Bus != nil {
+		s.Bus.Publish(events.Event{Type: events.StreamStarted, Data: map[string]any{logging.StreamKey: name}})
+	}
+
+	logger := s.logger().With(slog.String(logging.StreamKey, name))
+	logger.Info("stream started", slog.String("format", input.Format))
+
+	defer report.Recover(ctx, s.Reporter, "ingest", map[string]string{logging.StreamKey: name})
+
+	err := s.packager().Package(ctx, dir, input)
+	if errors.Is(err, context.Canceled) {
+		err = nil
+	}
+
+	if err != nil {
+		logger.Warn("stream failed", logging.Err(err))
+		report.Error(ctx, s.Reporter, "ingest", err, map[string]string{logging.StreamKey: name, "format": input.Format})
+	} else {
+		logger.Info("stream stopped")
+	}
+
+	return err
+}
+
+// Stop stops publishing the named stream, returning false when it is not active.
+func (s *Streams) Stop(name string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	cancel, ok := s.active[name]
+	if ok {
+		cancel()
+	}
+
+	return ok
+}
+
+// Active returns the sorted names of the streams being published.
+func (s *Streams) Active() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	names := make([]string, 0, len(s.active))
+	for name := range s.active {
+		names = append(names, name)
+	}
+
+	slices.Sort(names)
+	return names
+}
+
+// isActive checks if the named stream is being published.
+func (s *Streams) isActive(name string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	_, ok := s.active[name]
+	return ok
+}
+
+// Resolve resolves the stream referenced by name or by "live:///<name>" URI, returning ErrNotFound when it is not being published.
+func (s *Streams) Resolve(ctx context.Context, ref string) (source.Item, error) {
+	name := ref
+	if strings.Contains(ref, "://") {
+		relative, found := strings.CutPrefix(ref, LiveURIPrefix)
+		if !found {
+			return source.Item{}, source.ErrUnsupported
+		}
+
+		name = strings.TrimSuffix(strings.TrimSuffix(relative, "/"+PlaylistName), "/")
+	}
+
+	if !validName(name) {
+		return source.Item{}, source.ErrUnsupported
+	}
+
+	if !s.isActive(name) {
+		return source.Item{}, source.ErrNotFound
+	}
+
+	return source.Item{Ref: LiveURIPrefix + name + "/" + PlaylistName, Title: name, Live: true}, nil
+}
+
+// ListRenditions returns the playlist of the stream.
+func (s *Streams) ListRenditions(ctx context.Context, item source.Item) ([]source.Rendition, error) {
+	if !strings.HasPrefix(item.Ref, LiveURIPrefix) {
+		return nil, source.ErrUnsupported
+	}
+
+	return []source.Rendition{{URI: item.Ref}}, nil
+}
+
+// OpenSegment opens the playlist or a segment of a stream by its "live:///" URI.
+func (s *Streams) OpenSegment(ctx context.Context, uri string) (io.ReadCloser, error) {
+	relative, found := strings.CutPrefix(uri, LiveURIPrefix)
+	if !found {
+		return nil, source.ErrUnsupported
+	}
+
+	relative = path.Clean(relative)
+	name, _, _ := strings.Cut(relative, "/")
+	if !validName(name) || !fs.ValidPath(relative) || !s.isActive(name) {
+		return nil, source.ErrNotFound
+	}
+
+	root, err := os.OpenRoot(s.Dir)
+	if err != nil {
+		return nil, err
+	}
+	defer root.Close()
+
+	file, err := root.Open(relative)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, source.ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	return file, nil
+}
@@ -0,0 +1,119 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"vrmix/source"
+)
+
+// fakePackager writes a playlist and records the input until it ends or the context is canceled
+type fakePackager struct {
+	mutex   sync.Mutex
+	started chan struct{}
+	data    []byte
+}
+
+func newFakePackager() *fakePackager {
+	return &fakePackager{started: make(chan struct{}, 1)}
+}
+
+func (p *fakePackager) Package(ctx context.Context, dir string, input Input) error {
+	if err := os.WriteFile(filepath.Join(dir, PlaylistName), []byte("#EXTM3U\n"), 0o644); err != nil {
+		return err
+	}
+	p.started <- struct{}{}
+
+	if input.Reader == nil {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	data, err := io.ReadAll(input.Reader)
+	p.mutex.Lock()
+	p.data = data
+	p.mutex.Unlock()
+	return err
+}
+
+// recorded returns the input read by the packager
+func (p *fakePackager) recorded() []byte {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	return p.data
+}
+
+func TestStreams(t *testing.T) {
+	packager := newFakePackager()
+	changes := make(chan bool, 2)
+	streams := &Streams{Dir: t.TempDir(), Packager: packager, OnChange: func(name string, active bool) { changes <- active }}
+
+	done := make(chan error)
+	go func() { done <- streams.Publish(context.Background(), "cam", Input{URL: "srt://camera"}) }()
+
+	select {
+	case <-packager.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the packager to start")
+	}
+
+	if names := streams.Active(); len(names) != 1 || names[0] != "cam" {
+		t.Errorf("expected cam to be active, got %v", names)
+	}
+
+	if err := streams.Publish(context.Background(), "cam", Input{}); err != ErrStreamActive {
+		t.Errorf("expected ErrStreamActive, got %v", err)
+	}
+
+	item, err := streams.Resolve(context.Background(), "cam")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if item.Ref != "live:///cam/index.m3u8" || !item.Live {
+		t.Errorf("expected a live item, got %+v", item)
+	}
+
+	body, err := streams.OpenSegment(context.Background(), item.Ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body.Close()
+
+	if _, err := streams.OpenSegment(context.Background(), "live:///cam/../../etc/passwd"); err == nil {
+		t.Errorf("expected paths outside the stream to be rejected")
+	}
+
+	if !streams.Stop("cam") {
+		t.Errorf("expected cam to be stopped")
+	}
+
+	if err := <-done; err != nil {
+		t.Errorf("expected stopping to end the publish cleanly, got %v", err)
+	}
+
+	if _, err := streams.Resolve(context.Background(), "live:///cam"); !errors.Is(err, source.ErrNotFound) {
+		t.Errorf("expected ErrNotFound after stopping, got %v", err)
+	}
+
+	if !<-changes || <-changes {
+		t.Errorf("expected the stream to start then stop")
+	}
+}
+
+func TestStreamsInvalidName(t *testing.T) {
+	streams := &Streams{Dir: t.TempDir(), Packager: newFakePackager()}
+
+	for _, name := range []string{"", "../escape", "a/b", ".hidden"} {
+		if err := streams.Publish(context.Background(), name, Input{}); err != ErrInvalidName {
+			t.Errorf("expected ErrInvalidName for %q, got %v", name, err)
+		}
+	}
+}
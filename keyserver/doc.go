@@ -0,0 +1,2 @@
+// Package keyserver issues the content keys of the channels for each key period, keeping them in a pluggable store and delivering them over an authenticated endpoint.
+package keyserver
@@ -0,0 +1,226 @@
+package keyserver
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"vrmix/encrypt"
+	"vrmix/logging"
+	"vrmix/server"
+	"vrmix/signer"
+)
+
+// Server issues a content key for each period of each channel, implementing encrypt.RotatingKeys for the output encryption and delivering the keys over an authenticated endpoint, with routes relative to the mount point:
+//
+//	GET /{channel}/{id}
+type Server struct {
+	Store    Store         // Store keeping the keys, defaults to a MemoryStore
+	Period   time.Duration // Time each key is used for, zero to use a single key per channel
+	Segments int           // Segments encrypted with each key, taking precedence over Period, zero to rotate by time
+	History  int           // Keys kept for each channel, covering the DVR window, older keys being deleted, zero to keep every key
+
+	// Authorize checks the credentials of a key request, like VerifySigned, nil rejects every request.
+	Authorize func(r *http.Request) bool
+
+	// Logger receives the keys issued, defaults to slog.Default.
+	Logger *slog.Logger
+
+	once      sync.Once
+	mutex     sync.Mutex
+	handler   *encrypt.KeyHandler
+	rotations map[string]*rotation
+	now       func() time.Time
+}
+
+// rotation is the state of the keys issued for a channel.
+type rotation struct {
+	issued     []int64    // Periods issued, oldest first
+	boundaries []boundary // First media sequences of the periods when rotating by time, oldest first
+	highest    int64      // Highest media sequence seen, negative before the first one
+}
+
+// boundary is the first media sequence encrypted with the key of a period.
+type boundary struct {
+	sequence int64
+	period   int64
+}
+
+// rotation returns the state of the channel, the lock being held.
+func (s *Server) rotation(channel string) *rotation {
+	if s.rotations == nil {
+		s.rotations = make(map[string]*rotation)
+	}
+
+	state, ok := s.rotations[channel]
+	if !ok {
+		state = &rotation{highest: -1}
+		s.rotations[channel] = state
+	}
+
+	return state
+}
+
+// store returns the store keeping the keys.
+func (s *Server) store() Store {
+	s.once.Do(func() {
+		if s.Store == nil {
+			s.Store = &MemoryStore{}
+		}
+
+		if s.now == nil {
+			s.now = time.Now
+		}
+
+		s.handler = &encrypt.KeyHandler{Keys: s, Authorize: func(r *http.Request) bool { return s.Authorize != nil && s.Authorize(r) }}
+	})
+
+	return s.Store
+}
+
+// period returns the period holding the time.
+func (s *Server) period(t time.Time) int64 {
+	if s.Period <= 0 {
+		return 0
+	}
+
+	return t.UnixNano() / int64(s.Period)
+}
+
+// Current returns the key of the channel for the current period, or the newest key when rotating by segments, issuing it when the period starts.
+func (s *Server) Current(ctx context.Context, channel string) (encrypt.ContentKey, error) {
+	s.store()
+
+	// The lock keeps concurrent requests at the start of a period from issuing different keys.
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	period := s.period(s.now())
+	if state := s.rotation(channel); s.Segments > 0 {
+		period = 0
+		if len(state.issued) > 0 {
+			period = state.issued[len(state.issued)-1]
+		}
+	}
+
+	return s.issue(ctx, channel, period)
+}
+
+// ForSequence returns the key of the segment of the channel with the media sequence, the period of a segment being its media sequence divided by Segments, or the period it was first seen in when rotating by time.
+func (s *Server) ForSequence(ctx context.Context, channel string, sequence int64) (encrypt.ContentKey, error) {
+	s.store()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.Segments > 0 {
+		return s.issue(ctx, channel, sequence/int64(s.Segments))
+	}
+
+	state := s.rotation(channel)
+	if current := s.period(s.now()); sequence > state.highest {
+		if len(state.boundaries) == 0 || state.boundaries[len(state.boundaries)-1].period != current {
+			state.boundaries = append(state.boundaries, boundary{sequence: sequence, period: current})
+		}
+
+		state.highest = sequence
+	}
+
+	// The segments older than the boundaries kept use the oldest key kept, their own key being deleted.
+	period := state.boundaries[0].period
+	for _, b := range state.boundaries {
+		if b.sequence <= sequence {
+			period = b.period
+		}
+	}
+
+	return s.issue(ctx, channel, period)
+}
+
+// issue returns the key of the channel for the period, issuing it when it is missing and deleting the keys out of the history, the lock being held.
+func (s *Server) issue(ctx context.Context, channel string, period int64) (encrypt.ContentKey, error) {
+	key, err := s.Store.Get(ctx, channel, period)
+	if !errors.Is(err, encrypt.ErrKeyNotFound) {
+		return key, err
+	}
+
+	// The keys deleted out of the history are never issued again.
+	state := s.rotation(channel)
+	if s.History > 0 && len(state.issued) >= s.History && period < state.issued[0] {
+		return encrypt.ContentKey{}, encrypt.ErrKeyNotFound
+	}
+
+	key = encrypt.ContentKey{ID: strconv.FormatInt(period, 10), Key: make([]byte, encrypt.KeySize)}
+	rand.Read(key.Key)
+
+	if err := s.Store.Put(ctx, channel, period, key); err != nil {
+		return encrypt.ContentKey{}, err
+	}
+
+	logging.Or(s.Logger).Info("content key issued", slog.String(logging.ChannelKey, channel), slog.String("key", key.ID))
+
+	state.issued = append(state.issued, period)
+	if s.History <= 0 || len(state.issued) <= s.History {
+		return key, nil
+	}
+
+	expired := state.issued[:len(state.issued)-s.History]
+	state.issued = state.issued[len(expired):]
+	for _, old := range expired {
+		if err := s.Store.Delete(ctx, channel, old); err != nil {
+			logging.Or(s.Logger).Warn("content key deletion failed", slog.String(logging.ChannelKey, channel), slog.Int64("period", old), logging.Err(err))
+		}
+	}
+
+	for len(state.boundaries) > s.History {
+		state.boundaries = state.boundaries[1:]
+	}
+
+	return key, nil
+}
+
+// Lookup returns the key of the channel with the ID, which is its period, never issuing keys, so the keys of periods that have not started or out of the history are not found.
+func (s *Server) Lookup(ctx context.Context, channel string, id string) (encrypt.ContentKey, error) {
+	store := s.store()
+
+	period, err := strconv.ParseInt(id, 10, 64)
+	if err != nil || (s.Segments <= 0 && period > s.period(s.now())) {
+		return encrypt.ContentKey{}, encrypt.ErrKeyNotFound
+	}
+
+	return store.Get(ctx, channel, period)
+}
+
+// ServeHTTP checks the credentials, delivering the requested key.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.store()
+	s.handler.ServeHTTP(w, r)
+}
+
+// SignedKeyURL returns a function signing the key URLs signaled in the playlists, like encrypt.Encrypter.KeyURL, bound to the channel and valid for the TTL, the signature covering the path relative to the base URL the server is mounted on.
+func SignedKeyURL(s *signer.Signer, base string, ttl time.Duration) func(channel string, id string) string {
+	return func(channel string, id string) string {
+		u, err := url.Parse(base)
+		if err != nil {
+			return base
+		}
+
+		signed := s.Sign(&url.URL{Path: "/" + channel + "/" + id}, signer.Params{Expires: time.Now().Add(ttl), Channel: channel})
+		u = u.JoinPath(channel, id)
+		u.RawQuery = signed.RawQuery
+		return u.String()
+	}
+}
+
+// VerifySigned returns an Authorize function accepting the key requests signed by SignedKeyURL for the channel of the key.
+func VerifySigned(s *signer.Signer) func(r *http.Request) bool {
+	return func(r *http.Request) bool {
+		return s.Verify(r.URL, r.PathValue("channel"), server.ClientIP(r), time.Now()) == nil
+	}
+}
@@ -0,0 +1,154 @@
+package keyserver
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"vrmix/encrypt"
+	"vrmix/hls"
+	"vrmix/signer"
+)
+
+func TestServerPeriods(t *testing.T) {
+	now := time.Unix(3600, 0)
+	s := &Server{Period: time.Hour, now: func() time.Time { return now }}
+	ctx := context.Background()
+
+	first, err := s.Current(ctx, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if again, _ := s.Current(ctx, "main"); again.ID != first.ID || string(again.Key) != string(first.Key) {
+		t.Errorf("expected the same key during the period, got %s and %s", first.ID, again.ID)
+	}
+
+	if other, _ := s.Current(ctx, "other"); string(other.Key) == string(first.Key) {
+		t.Error("expected a key per channel")
+	}
+
+	now = now.Add(time.Hour)
+	second, _ := s.Current(ctx, "main")
+	if second.ID == first.ID {
+		t.Errorf("expected a new key for the next period, got %s", second.ID)
+	}
+
+	if previous, err := s.Lookup(ctx, "main", first.ID); err != nil || string(previous.Key) != string(first.Key) {
+		t.Errorf("expected the key of the previous period, got %v", err)
+	}
+
+	if _, err := s.Lookup(ctx, "main", "3"); err == nil {
+		t.Error("expected the keys of future periods not to be issued")
+	}
+}
+
+func TestServerSignedURLs(t *testing.T) {
+	sign := signer.New([]byte("secret"))
+	s := &Server{Authorize: VerifySigned(sign)}
+	key, _ := s.Current(context.Background(), "main")
+
+	mux := http.NewServeMux()
+	mux.Handle("/keys/", http.StripPrefix("/keys", s))
+
+	keyURL, _ := url.Parse(SignedKeyURL(sign, "https://vrmix.example/keys", time.Minute)("main", key.ID))
+	if keyURL.Path != "/keys/main/"+key.ID {
+		t.Fatalf("expected the key path under the base URL, got %s", keyURL)
+	}
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, keyURL.RequestURI(), nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != string(key.Key) {
+		t.Errorf("expected the key with a signed URL, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/keys/other/"+key.ID+"?"+keyURL.RawQuery, nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected the signature to be bound to the channel, got %d", rec.Code)
+	}
+}
+
+func TestServerRotateSegments(t *testing.T) {
+	s := &Server{Segments: 2, History: 2}
+	ctx := context.Background()
+
+	var ids []string
+	for sequence := range int64(6) {
+		key, err := s.ForSequence(ctx, "main", sequence)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ids = append(ids, key.ID)
+	}
+
+	if strings.Join(ids, " ") != "0 0 1 1 2 2" {
+		t.Errorf("expected a key every 2 segments, got %v", ids)
+	}
+
+	if current, _ := s.Current(ctx, "main"); current.ID != "2" {
+		t.Errorf("expected the newest key to be current, got %s", current.ID)
+	}
+
+	if _, err := s.Lookup(ctx, "main", "0"); !errors.Is(err, encrypt.ErrKeyNotFound) {
+		t.Errorf("expected the keys out of the history to be deleted, got %v", err)
+	}
+
+	if _, err := s.ForSequence(ctx, "main", 0); !errors.Is(err, encrypt.ErrKeyNotFound) {
+		t.Errorf("expected the keys out of the history not to be issued again, got %v", err)
+	}
+
+	if _, err := s.Lookup(ctx, "main", "1"); err != nil {
+		t.Errorf("expected the keys of the history to be kept, got %v", err)
+	}
+}
+
+func TestServerRotateTime(t *testing.T) {
+	now := time.Unix(0, 0)
+	s := &Server{Period: time.Minute, now: func() time.Time { return now }}
+	ctx := context.Background()
+
+	first, _ := s.ForSequence(ctx, "main", 10)
+	s.ForSequence(ctx, "main", 11)
+
+	now = now.Add(time.Minute)
+	if key, _ := s.ForSequence(ctx, "main", 11); key.ID != first.ID {
+		t.Errorf("expected the segments to keep their key, got %s", key.ID)
+	}
+
+	second, _ := s.ForSequence(ctx, "main", 12)
+	if second.ID == first.ID {
+		t.Error("expected the new segments to use the key of the new period")
+	}
+
+	if key, _ := s.ForSequence(ctx, "main", 9); key.ID != first.ID {
+		t.Errorf("expected older segments to use the oldest key, got %s", key.ID)
+	}
+}
+
+func TestEncrypterRotation(t *testing.T) {
+	e := &encrypt.Encrypter{Keys: &Server{Segments: 2}}
+	manifest, err := hls.ParseHlsManifest("#EXTM3U\n#EXT-X-TARGETDURATION:4\n#EXT-X-MEDIA-SEQUENCE:1\n#EXTINF:4,\n1.ts\n#EXTINF:4,\n2.ts\n#EXTINF:4,\n3.ts")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := e.Playlist(context.Background(), "main", &manifest); err != nil {
+		t.Fatal(err)
+	}
+
+	playlist := manifest.String()
+	if count := strings.Count(playlist, "#EXT-X-KEY"); count != 3 {
+		t.Errorf("expected a key tag per segment IV, got %d in %s", count, playlist)
+	}
+
+	if !strings.Contains(playlist, "1.ts?kid=0") || !strings.Contains(playlist, "2.ts?kid=1") || !strings.Contains(playlist, "3.ts?kid=1") {
+		t.Errorf("expected the keys to rotate at sequence 2, got %s", playlist)
+	}
+}
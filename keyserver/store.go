@@ -0,0 +1,164 @@
+package keyserver
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"vrmix/encrypt"
+)
+
+// Store keeps the content keys issued for each period of the channels.
+type Store interface {
+	// Get returns the key of the channel for the period, or encrypt.ErrKeyNotFound.
+	Get(ctx context.Context, channel string, period int64) (encrypt.ContentKey, error)
+
+	// Put stores the key of the channel for the period.
+	Put(ctx context.Context, channel string, period int64, key encrypt.ContentKey) error
+
+	// Delete removes the key of the channel for the period, doing nothing when there is none.
+	Delete(ctx context.Context, channel string, period int64) error
+}
+
+// storedKey identifies a key of a channel.
+type storedKey struct {
+	channel string
+	period  int64
+}
+
+// MemoryStore is a Store keeping the keys in memory, lost when the process exits.
+type MemoryStore struct {
+	mutex sync.Mutex
+	keys  map[storedKey]encrypt.ContentKey
+}
+
+// Get returns the key of the channel for the period.
+func (s *MemoryStore) Get(ctx context.Context, channel string, period int64) (encrypt.ContentKey, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	key, ok := s.keys[storedKey{channel, period}]
+	if !ok {
+		return encrypt.ContentKey{}, encrypt.ErrKeyNotFound
+	}
+
+	return key, nil
+}
+
+// Put stores the key of the channel for the period.
+func (s *MemoryStore) Put(ctx context.Context, channel string, period int64, key encrypt.ContentKey) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.keys == nil {
+		s.keys = make(map[storedKey]encrypt.ContentKey)
+	}
+
+	s.keys[storedKey{channel, period}] = key
+	return nil
+}
+
+// Delete removes the key of the channel for the period.
+func (s *MemoryStore) Delete(ctx context.Context, channel string, period int64) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.keys, storedKey{channel, period})
+	return nil
+}
+
+// FileStore is a Store keeping each key in its own file readable only by the owner, under a directory per channel.
+type FileStore struct {
+	Dir string // Directory holding the keys
+}
+
+// path returns the path of the file of the key, escaping the channel so it is a single path element.
+func (s *FileStore) path(channel string, period int64) string {
+	return filepath.Join(s.Dir, url.PathEscape(channel), strconv.FormatInt(period, 10)+".key")
+}
+
+// Get reads the key of the channel for the period.
+func (s *FileStore) Get(ctx context.Context, channel string, period int64) (encrypt.ContentKey, error) {
+	data, err := os.ReadFile(s.path(channel, period))
+	if errors.Is(err, fs.ErrNotExist) {
+		return encrypt.ContentKey{}, encrypt.ErrKeyNotFound
+	} else if err != nil {
+		return encrypt.ContentKey{}, err
+	}
+
+	return encrypt.ContentKey{ID: strconv.FormatInt(period, 10), Key: data}, nil
+}
+
+// Put writes the key of the channel for the period, replacing the file atomically.
+func (s *FileStore) Put(ctx context.Context, channel string, period int64, key encrypt.ContentKey) error {
+	target := s.path(channel, period)
+	if err := os.MkdirAll(filepath.Dir(target), 0o700); err != nil {
+		return err
+	}
+
+	temp := target + ".tmp"
+	if err := os.WriteFile(temp, key.Key, 0o600); err != nil {
+		return err
+	}
+
+	return os.Rename(temp, target)
+}
+
+// Delete removes the file of the key of the channel for the period.
+func (s *FileStore) Delete(ctx context.Context, channel string, period int64) error {
+	if err := os.Remove(s.path(channel, period)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+// KeyEncrypter encrypts the content keys at rest, like a key management service or the transit engine of Vault.
+type KeyEncrypter interface {
+	// Encrypt encrypts the plaintext.
+	Encrypt(ctx context.Context, plaintext []byte) ([]byte, error)
+
+	// Decrypt decrypts a ciphertext returned by Encrypt.
+	Decrypt(ctx context.Context, ciphertext []byte) ([]byte, error)
+}
+
+// KMSStore is a Store encrypting the keys with a key management service before keeping them in another store, so the keys at rest are useless without access to the service.
+type KMSStore struct {
+	Store Store        // Store keeping the encrypted keys
+	KMS   KeyEncrypter // Service encrypting the keys
+}
+
+// Get decrypts the key of the channel for the period.
+func (s *KMSStore) Get(ctx context.Context, channel string, period int64) (encrypt.ContentKey, error) {
+	key, err := s.Store.Get(ctx, channel, period)
+	if err != nil {
+		return encrypt.ContentKey{}, err
+	}
+
+	if key.Key, err = s.KMS.Decrypt(ctx, key.Key); err != nil {
+		return encrypt.ContentKey{}, err
+	}
+
+	return key, nil
+}
+
+// Put encrypts the key of the channel for the period.
+func (s *KMSStore) Put(ctx context.Context, channel string, period int64, key encrypt.ContentKey) error {
+	encrypted, err := s.KMS.Encrypt(ctx, key.Key)
+	if err != nil {
+		return err
+	}
+
+	key.Key = encrypted
+	return s.Store.Put(ctx, channel, period, key)
+}
+
+// Delete removes the key of the channel for the period.
+func (s *KMSStore) Delete(ctx context.Context, channel string, period int64) error {
+	return s.Store.Delete(ctx, channel, period)
+}
@@ -0,0 +1,73 @@
+package keyserver
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"vrmix/encrypt"
+)
+
+// xorKMS is a KeyEncrypter flipping the bits of the keys
+type xorKMS struct{}
+
+func (xorKMS) Encrypt(ctx context.Context, plaintext []byte) ([]byte, error) {
+	out := bytes.Clone(plaintext)
+	for i := range out {
+		out[i] ^= 0xff
+	}
+
+	return out, nil
+}
+
+func (k xorKMS) Decrypt(ctx context.Context, ciphertext []byte) ([]byte, error) {
+	return k.Encrypt(ctx, ciphertext)
+}
+
+func TestStores(t *testing.T) {
+	dir := t.TempDir()
+	stores := map[string]Store{
+		"memory": &MemoryStore{},
+		"file":   &FileStore{Dir: dir},
+		"kms":    &KMSStore{Store: &MemoryStore{}, KMS: xorKMS{}},
+	}
+
+	ctx := context.Background()
+	key := encrypt.ContentKey{ID: "7", Key: bytes.Repeat([]byte{1}, encrypt.KeySize)}
+
+	for name, store := range stores {
+		if _, err := store.Get(ctx, "main", 7); !errors.Is(err, encrypt.ErrKeyNotFound) {
+			t.Errorf("%s: expected ErrKeyNotFound, got %v", name, err)
+		}
+
+		if err := store.Put(ctx, "main", 7, key); err != nil {
+			t.Fatal(err)
+		}
+
+		stored, err := store.Get(ctx, "main", 7)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if stored.ID != "7" || !bytes.Equal(stored.Key, key.Key) {
+			t.Errorf("%s: expected the stored key, got %+v", name, stored)
+		}
+	}
+
+	info, err := os.Stat(filepath.Join(dir, "main", "7.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("expected the key file to be readable only by the owner, got %v", info.Mode().Perm())
+	}
+
+	kms := stores["kms"].(*KMSStore)
+	if atRest, _ := kms.Store.Get(ctx, "main", 7); bytes.Equal(atRest.Key, key.Key) {
+		t.Error("expected the key to be encrypted at rest")
+	}
+}
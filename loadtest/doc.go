@@ -0,0 +1,2 @@
+// Package loadtest simulates concurrent HLS players against a stream, measuring the latencies and errors to size a deployment.
+package loadtest
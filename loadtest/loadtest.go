@@ -0,0 +1,363 @@
+package loadtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"vrmix/hls"
+)
+
+// ErrStatus indicates that the server answered a request with a non 2xx status.
+var ErrStatus = errors.New("unexpected status")
+
+// Config represents the players simulated against a stream.
+type Config struct {
+	URL      string        // URL of the master or media playlist played
+	Clients  int           // Players simulated at once, defaults to 1
+	Duration time.Duration // Time the players keep playing, defaults to one minute
+	RampUp   time.Duration // Time over which the players start evenly, zero to start them at once
+	Seek     float64       // Probability of each player to seek to a random segment of a VOD after each segment
+	Speed    float64       // Playback speed pacing the requests, like 2 to poll and fetch twice as fast, defaults to 1
+	Client   *http.Client  // Client used to make the requests, defaults to http.DefaultClient
+}
+
+// Stats represents the requests of a kind made by the players.
+type Stats struct {
+	Requests int           // Requests made
+	Errors   int           // Requests failed, on the network or with a non 2xx status
+	Bytes    int64         // Bytes received in the bodies
+	P50      time.Duration // Median latency of the requests
+	P90      time.Duration // 90th percentile latency of the requests
+	P99      time.Duration // 99th percentile latency of the requests
+	Max      time.Duration // Highest latency of the requests
+}
+
+// ErrorRate returns the fraction of the requests that failed.
+func (s Stats) ErrorRate() float64 {
+	if s.Requests == 0 {
+		return 0
+	}
+
+	return float64(s.Errors) / float64(s.Requests)
+}
+
+// Report represents the outcome of a load test.
+type Report struct {
+	Clients   int           // Players simulated
+	Elapsed   time.Duration // Time the test ran
+	Playlists Stats         // Playlist requests
+	Segments  Stats         // Segment requests
+	Seeks     int           // Seeks made by the players
+	Stalls    int           // Segments taking longer to fetch than to play, which stall the players
+}
+
+// String formats the report as a table.
+func (r Report) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "clients %d, elapsed %s, seeks %d, stalls %d\n", r.Clients, r.Elapsed.Round(time.Millisecond), r.Seeks, r.Stalls)
+	fmt.Fprintf(&b, "%-9s %8s %7s %10s %9s %9s %9s %9s\n", "kind", "requests", "errors", "MB", "p50", "p90", "p99", "max")
+	for _, row := range []struct {
+		kind  string
+		stats Stats
+	}{{"playlist", r.Playlists}, {"segment", r.Segments}} {
+		fmt.Fprintf(&b, "%-9s %8d %6.2f%% %10.1f %9s %9s %9s %9s\n", row.kind, row.stats.Requests, 100*row.stats.ErrorRate(), float64(row.stats.Bytes)/1e6,
+			row.stats.P50.Round(time.Millisecond), row.stats.P90.Round(time.Millisecond), row.stats.P99.Round(time.Millisecond), row.stats.Max.Round(time.Millisecond))
+	}
+
+	return b.String()
+}
+
+// recorder collects the requests of every player.
+type recorder struct {
+	mutex     sync.Mutex
+	playlists []time.Duration
+	segments  []time.Duration
+	report    Report
+}
+
+// record records a request of a kind, its latency, its body size and whether it failed.
+func (r *recorder) record(playlist bool, latency time.Duration, bytes int64, failed bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	stats := &r.report.Segments
+	if playlist {
+		stats = &r.report.Playlists
+		r.playlists = append(r.playlists, latency)
+	} else {
+		r.segments = append(r.segments, latency)
+	}
+
+	stats.Requests++
+	stats.Bytes += bytes
+	if failed {
+		stats.Errors++
+	}
+}
+
+// count increments a counter of the report.
+func (r *recorder) count(counter *int) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	*counter++
+}
+
+// percentiles sets the latency percentiles of the stats.
+func percentiles(stats *Stats, latencies []time.Duration) {
+	if len(latencies) == 0 {
+		return
+	}
+
+	slices.Sort(latencies)
+	at := func(p float64) time.Duration {
+		return latencies[min(int(p*float64(len(latencies))), len(latencies)-1)]
+	}
+
+	stats.P50, stats.P90, stats.P99, stats.Max = at(0.5), at(0.9), at(0.99), latencies[len(latencies)-1]
+}
+
+// Run simulates the players until the duration passes or the context is done, returning the report of their requests.
+func Run(ctx context.Context, config Config) (Report, error) {
+	if _, err := url.Parse(config.URL); err != nil {
+		return Report{}, err
+	}
+
+	if config.Clients <= 0 {
+		config.Clients = 1
+	}
+
+	if config.Duration <= 0 {
+		config.Duration = time.Minute
+	}
+
+	if config.Speed <= 0 {
+		config.Speed = 1
+	}
+
+	if config.Client == nil {
+		config.Client = http.DefaultClient
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, config.Duration)
+	defer cancel()
+
+	r := &recorder{report: Report{Clients: config.Clients}}
+	started := time.Now()
+
+	var wg sync.WaitGroup
+	for i := range config.Clients {
+		delay := time.Duration(0)
+		if config.Clients > 1 {
+			delay = config.RampUp * time.Duration(i) / time.Duration(config.Clients)
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if sleep(ctx, delay) {
+				p := &player{config: config, recorder: r, index: i, random: rand.New(rand.NewPCG(uint64(i), uint64(started.UnixNano())))}
+				p.play(ctx)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	r.report.Elapsed = time.Since(started)
+	percentiles(&r.report.Playlists, r.playlists)
+	percentiles(&r.report.Segments, r.segments)
+	return r.report, nil
+}
+
+// sleep waits for the duration, returning false if the context is done first.
+func sleep(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// player is a simulated player.
+type player struct {
+	config   Config
+	recorder *recorder
+	index    int
+	random   *rand.Rand
+}
+
+// get fetches the URL, recording its latency, and returns its body.
+func (p *player) get(ctx context.Context, uri string, playlist bool) ([]byte, time.Duration, error) {
+	started := time.Now()
+	body, err := p.fetch(ctx, uri)
+	latency := time.Since(started)
+
+	// Requests interrupted by the end of the test are not failures of the server.
+	if ctx.Err() != nil {
+		return nil, latency, ctx.Err()
+	}
+
+	p.recorder.record(playlist, latency, int64(len(body)), err != nil)
+	return body, latency, err
+}
+
+// fetch fetches the URL, failing on a non 2xx status.
+func (p *player) fetch(ctx context.Context, uri string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := p.config.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return body, fmt.Errorf("%w: %s", ErrStatus, resp.Status)
+	}
+
+	return body, err
+}
+
+// play plays the stream until the context is done, picking a variant of a master playlist by the index of the player.
+func (p *player) play(ctx context.Context) {
+	uri := p.config.URL
+	for ctx.Err() == nil {
+		body, _, err := p.get(ctx, uri, true)
+		if err != nil {
+			sleep(ctx, time.Second)
+			continue
+		}
+
+		text := strings.TrimRight(strings.ReplaceAll(string(body), "\r\n", "\n"), "\n")
+		if !strings.Contains(text, hls.StreamInfField) {
+			p.playMedia(ctx, uri, text)
+			return
+		}
+
+		master, err := hls.ParseMasterManifest(text)
+		if err != nil || len(master.Variants) == 0 {
+			sleep(ctx, time.Second)
+			continue
+		}
+
+		uri = resolve(uri, master.Variants[p.index%len(master.Variants)].URI)
+	}
+}
+
+// playMedia plays the media playlist, polling it when live and seeking through it when it is a VOD.
+func (p *player) playMedia(ctx context.Context, uri string, text string) {
+	var next uint64
+	started := false
+	for ctx.Err() == nil {
+		manifest, err := hls.ParseHlsManifest(text)
+		if err != nil {
+			sleep(ctx, time.Duration(float64(time.Second)/p.config.Speed))
+		} else {
+			var segments []hls.Segment
+			for _, group := range manifest.SegmentGroups {
+				segments = append(segments, group.Segments...)
+			}
+
+			if manifest.HasEndList {
+				p.playVOD(ctx, uri, segments)
+				return
+			}
+
+			// A live player starts three segments from the end of the playlist, then follows the new segments.
+			first := uint64(manifest.MediaSequence)
+			if !started {
+				next, started = first+uint64(max(len(segments)-3, 0)), true
+			}
+
+			fetched := false
+			for next < first+uint64(len(segments)) && ctx.Err() == nil {
+				if next >= first {
+					p.fetchSegment(ctx, uri, segments[next-first])
+					fetched = true
+				}
+				next++
+			}
+
+			// Players poll at the target duration, or at half of it when the playlist did not change.
+			interval := time.Duration(manifest.TargetDuration) * time.Second
+			if !fetched {
+				interval /= 2
+			}
+
+			sleep(ctx, time.Duration(float64(max(interval, time.Second/2))/p.config.Speed))
+		}
+
+		body, _, err := p.get(ctx, uri, true)
+		if err != nil {
+			sleep(ctx, time.Duration(float64(time.Second)/p.config.Speed))
+			continue
+		}
+
+		text = strings.TrimRight(strings.ReplaceAll(string(body), "\r\n", "\n"), "\n")
+	}
+}
+
+// playVOD plays the segments in order, paced by their durations, seeking to random segments and starting over at the end.
+func (p *player) playVOD(ctx context.Context, uri string, segments []hls.Segment) {
+	if len(segments) == 0 {
+		return
+	}
+
+	for i := 0; ctx.Err() == nil; i = (i + 1) % len(segments) {
+		latency := p.fetchSegment(ctx, uri, segments[i])
+		pace := time.Duration(float64(segments[i].Duration) * float64(time.Second) / p.config.Speed)
+		sleep(ctx, pace-latency)
+
+		if p.config.Seek > 0 && p.random.Float64() < p.config.Seek {
+			p.recorder.count(&p.recorder.report.Seeks)
+			i = p.random.IntN(len(segments)) - 1
+		}
+	}
+}
+
+// fetchSegment fetches the segment, recording a stall when it takes longer than its duration, and returns the latency.
+func (p *player) fetchSegment(ctx context.Context, uri string, segment hls.Segment) time.Duration {
+	_, latency, err := p.get(ctx, resolve(uri, segment.Path), false)
+	if err == nil && latency > time.Duration(float64(segment.Duration)*float64(time.Second)/p.config.Speed) {
+		p.recorder.count(&p.recorder.report.Stalls)
+	}
+
+	return latency
+}
+
+// resolve resolves the reference against the URL of the playlist.
+func resolve(base string, ref string) string {
+	baseURL, err := url.Parse(base)
+	if err != nil {
+		return ref
+	}
+
+	u, err := baseURL.Parse(ref)
+	if err != nil {
+		return ref
+	}
+
+	return u.String()
+}
@@ -0,0 +1,78 @@
+package loadtest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRunVOD(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/master.m3u8":
+			w.Write([]byte("#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=1000000\nlow.m3u8\n#EXT-X-STREAM-INF:BANDWIDTH=5000000\nhigh.m3u8\n"))
+		case "/low.m3u8", "/high.m3u8":
+			w.Write([]byte("#EXTM3U\n#EXT-X-TARGETDURATION:4\n#EXTINF:4,\n0.ts\n#EXTINF:4,\n1.ts\n#EXTINF:4,\nmissing.ts\n#EXT-X-ENDLIST\n"))
+		case "/0.ts", "/1.ts":
+			w.Write([]byte("segment"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	report, err := Run(context.Background(), Config{URL: server.URL + "/master.m3u8", Clients: 3, Duration: 300 * time.Millisecond, Speed: 200, Seek: 0.5})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Playlists.Requests != 6 || report.Playlists.Errors != 0 {
+		t.Errorf("expected each client to fetch the master and the media playlist once, got %+v", report.Playlists)
+	}
+
+	if report.Segments.Requests < 10 || report.Segments.Errors == 0 || report.Segments.Errors == report.Segments.Requests {
+		t.Errorf("expected the missing segment to fail, got %+v", report.Segments)
+	}
+
+	if report.Segments.P50 <= 0 || report.Segments.Max < report.Segments.P99 || report.Seeks == 0 {
+		t.Errorf("expected the latencies and seeks to be reported, got %+v", report)
+	}
+
+	if s := report.String(); !strings.Contains(s, "clients 3") || !strings.Contains(s, "segment") {
+		t.Errorf("expected the report table, got %s", s)
+	}
+}
+
+func TestRunLive(t *testing.T) {
+	var polls atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/live.m3u8" {
+			w.Write([]byte("segment"))
+			return
+		}
+
+		sequence := polls.Add(1)
+		var b strings.Builder
+		b.WriteString("#EXTM3U\n#EXT-X-TARGETDURATION:2\n#EXT-X-MEDIA-SEQUENCE:" + strconv.FormatInt(sequence, 10) + "\n")
+		for i := range 5 {
+			b.WriteString("#EXTINF:2,\n" + strconv.FormatInt(sequence+int64(i), 10) + ".ts\n")
+		}
+		w.Write([]byte(b.String()))
+	}))
+	defer server.Close()
+
+	report, err := Run(context.Background(), Config{URL: server.URL + "/live.m3u8", Duration: 200 * time.Millisecond, Speed: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The player starts with the last three segments, then fetches the segment added by each poll, the last one being cut by the end of the test.
+	if report.Playlists.Requests < 3 || report.Segments.Requests < report.Playlists.Requests || report.Segments.Requests > report.Playlists.Requests+2 {
+		t.Errorf("expected the player to follow the live playlist, got %+v and %+v", report.Playlists, report.Segments)
+	}
+}
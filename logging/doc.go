@@ -0,0 +1,2 @@
+// Package logging defines the structured logging conventions shared by the VRMix subsystems, which log through the *slog.Logger supplied by the embedder.
+package logging
@@ -0,0 +1,37 @@
+package logging
+
+import "log/slog"
+
+const (
+	// ChannelKey is the key of the channel ID.
+	ChannelKey = "channel"
+
+	// SessionKey is the key of the session ID.
+	SessionKey = "session"
+
+	// JobKey is the key of the ID of a scheduler or conversion job.
+	JobKey = "job"
+
+	// StreamKey is the key of the name of an ingested stream.
+	StreamKey = "stream"
+
+	// RefKey is the key of a media reference, like an URL or a path.
+	RefKey = "ref"
+
+	// ErrorKey is the key of an error.
+	ErrorKey = "error"
+)
+
+// Or returns the logger, or slog.Default when it is nil, so subsystems without a logger follow the default handler of the process.
+func Or(logger *slog.Logger) *slog.Logger {
+	if logger == nil {
+		return slog.Default()
+	}
+
+	return logger
+}
+
+// Err returns the attribute of an error.
+func Err(err error) slog.Attr {
+	return slog.Any(ErrorKey, err)
+}
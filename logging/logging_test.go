@@ -0,0 +1,23 @@
+package logging
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestOr(t *testing.T) {
+	if Or(nil) != slog.Default() {
+		t.Errorf("expected the default logger for nil")
+	}
+
+	var buffer bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buffer, nil))
+	Or(logger).Error("failed", slog.String(ChannelKey, "main"), Err(errors.New("broken")))
+
+	if output := buffer.String(); !strings.Contains(output, "channel=main") || !strings.Contains(output, "error=broken") {
+		t.Errorf("expected the channel and error attributes, got %s", output)
+	}
+}
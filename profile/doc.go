@@ -0,0 +1,2 @@
+// Package profile provides the encoding ladders of flat and VR videos, selecting for each item the ladder suited to its projection and stereo layout.
+package profile
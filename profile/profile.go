@@ -0,0 +1,210 @@
+package profile
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"vrmix/hls"
+)
+
+// ErrUnknownProfile indicates that no profile has the requested name.
+var ErrUnknownProfile = errors.New("unknown encoding profile")
+
+// Rung represents an encoding of a ladder, sized by the view of each eye so the same ladder fits every stereo layout.
+type Rung struct {
+	Name       string // Name of the rung, like "2880p"
+	Width      int    // Width of the view of each eye in pixels
+	Height     int    // Height of the view of each eye in pixels
+	Bitrate    int    // Target video bits per second of a single view
+	MinBitrate int    // Floor of the video bits per second of a single view, keeping detail the headset magnifies, zero for no floor
+}
+
+// Profile represents an encoding ladder and the encoder settings shared by its rungs.
+type Profile struct {
+	Name    string        // Name of the profile, selectable per item
+	Codec   string        // Video encoder of ffmpeg, like "libx265"
+	GOP     time.Duration // Interval between keyframes, short for fast viewport and quality switching
+	Rungs   []Rung        // Rungs of the ladder, from the highest to the lowest quality
+	Preset  string        // Preset of the encoder, like "medium"
+	MaxRate float64       // Peak bitrate as a multiple of the target bitrate
+}
+
+var (
+	// Flat is the ladder of rectilinear videos watched on a screen.
+	Flat = Profile{
+		Name:    "flat",
+		Codec:   "libx264",
+		GOP:     4 * time.Second,
+		Preset:  "veryfast",
+		MaxRate: 1.5,
+		Rungs: []Rung{
+			{Name: "1080p", Width: 1920, Height: 1080, Bitrate: 5_000_000},
+			{Name: "720p", Width: 1280, Height: 720, Bitrate: 3_000_000},
+			{Name: "480p", Width: 854, Height: 480, Bitrate: 1_200_000},
+		},
+	}
+
+	// VR360 is the ladder of equirectangular 360 degrees videos, whose viewport only shows a fraction of each frame.
+	VR360 = Profile{
+		Name:    "vr360",
+		Codec:   "libx265",
+		GOP:     time.Second,
+		Preset:  "medium",
+		MaxRate: 1.2,
+		Rungs: []Rung{
+			{Name: "3840p", Width: 7680, Height: 3840, Bitrate: 50_000_000, MinBitrate: 35_000_000},
+			{Name: "2880p", Width: 5760, Height: 2880, Bitrate: 30_000_000, MinBitrate: 20_000_000},
+			{Name: "1920p", Width: 3840, Height: 1920, Bitrate: 15_000_000, MinBitrate: 10_000_000},
+			{Name: "1440p", Width: 2880, Height: 1440, Bitrate: 8_000_000, MinBitrate: 5_000_000},
+		},
+	}
+
+	// VR180 is the ladder of half equirectangular and fisheye 180 degrees videos, with square views.
+	VR180 = Profile{
+		Name:    "vr180",
+		Codec:   "libx265",
+		GOP:     time.Second,
+		Preset:  "medium",
+		MaxRate: 1.2,
+		Rungs: []Rung{
+			{Name: "4096p", Width: 4096, Height: 4096, Bitrate: 40_000_000, MinBitrate: 25_000_000},
+			{Name: "2880p", Width: 2880, Height: 2880, Bitrate: 25_000_000, MinBitrate: 15_000_000},
+			{Name: "2048p", Width: 2048, Height: 2048, Bitrate: 12_000_000, MinBitrate: 8_000_000},
+			{Name: "1440p", Width: 1440, Height: 1440, Bitrate: 6_000_000, MinBitrate: 4_000_000},
+		},
+	}
+
+	// Cubemap is the ladder of cubemap and equi-angular cubemap videos, with the faces laid out in 3x2.
+	Cubemap = Profile{
+		Name:    "cubemap",
+		Codec:   "libx265",
+		GOP:     time.Second,
+		Preset:  "medium",
+		MaxRate: 1.2,
+		Rungs: []Rung{
+			{Name: "2048p", Width: 6144, Height: 4096, Bitrate: 40_000_000, MinBitrate: 25_000_000},
+			{Name: "1536p", Width: 4608, Height: 3072, Bitrate: 24_000_000, MinBitrate: 15_000_000},
+			{Name: "1024p", Width: 3072, Height: 2048, Bitrate: 12_000_000, MinBitrate: 8_000_000},
+		},
+	}
+)
+
+// Presets are the profiles selectable by name.
+var Presets = []Profile{Flat, VR360, VR180, Cubemap}
+
+// Lookup returns the preset with the name.
+func Lookup(name string) (Profile, error) {
+	for _, preset := range Presets {
+		if preset.Name == name {
+			return preset, nil
+		}
+	}
+
+	return Profile{}, fmt.Errorf("%w: %s", ErrUnknownProfile, name)
+}
+
+// ForLayout returns the preset suited to the projection of the video, Flat when it is rectilinear or unknown.
+func ForLayout(layout hls.Layout) Profile {
+	switch layout.Projection {
+	case hls.ProjectionEquirectangular:
+		return VR360
+	case hls.ProjectionHalfEquirectangular, hls.ProjectionFisheye:
+		return VR180
+	case hls.ProjectionCubemap, hls.ProjectionEquiAngularCubemap:
+		return Cubemap
+	default:
+		return Flat
+	}
+}
+
+// Select returns the preset named by the item when it names one, or the preset suited to the layout detected from its metadata.
+func Select(layout hls.Layout, name string) (Profile, error) {
+	if name != "" {
+		return Lookup(name)
+	}
+
+	return ForLayout(layout), nil
+}
+
+// Resolution returns the size of the frames of the rung, packing the view of each eye side by side or top and bottom.
+func (r Rung) Resolution(stereo hls.StereoLayout) (int, int) {
+	switch stereo {
+	case hls.StereoSideBySide:
+		return r.Width * 2, r.Height
+	case hls.StereoTopBottom:
+		return r.Width, r.Height * 2
+	default:
+		return r.Width, r.Height
+	}
+}
+
+// scale returns the bitrate of the rung for the stereo layout, frame packing doubling the pixels and MV-HEVC predicting the second view from the first.
+func scale(bitrate int, stereo hls.StereoLayout) int {
+	switch stereo {
+	case hls.StereoSideBySide, hls.StereoTopBottom:
+		return bitrate * 2
+	case hls.StereoMultiview:
+		return bitrate * 3 / 2
+	default:
+		return bitrate
+	}
+}
+
+// Ladder returns the rungs no larger than the source frames, so a video is never upscaled, keeping the lowest rung when the source is smaller.
+func (p Profile) Ladder(layout hls.Layout) []Rung {
+	width, height, err := layout.EyeResolution()
+	if err != nil {
+		return p.Rungs
+	}
+
+	var rungs []Rung
+	for _, rung := range p.Rungs {
+		if rung.Width <= width && rung.Height <= height {
+			rungs = append(rungs, rung)
+		}
+	}
+
+	if len(rungs) == 0 && len(p.Rungs) > 0 {
+		rungs = p.Rungs[len(p.Rungs)-1:]
+	}
+
+	return rungs
+}
+
+// CodecArgs returns the ffmpeg video arguments encoding the rung for the stereo layout, like the codec arguments of an ingest input.
+func (p Profile) CodecArgs(rung Rung, stereo hls.StereoLayout) []string {
+	width, height := rung.Resolution(stereo)
+	bitrate := scale(rung.Bitrate, stereo)
+	maxRate := p.MaxRate
+	if maxRate < 1 {
+		maxRate = 1
+	}
+
+	args := []string{"-c:v", p.Codec}
+	if p.Preset != "" {
+		args = append(args, "-preset", p.Preset)
+	}
+
+	args = append(args, "-s", strconv.Itoa(width)+"x"+strconv.Itoa(height), "-b:v", strconv.Itoa(bitrate))
+	if rung.MinBitrate > 0 {
+		args = append(args, "-minrate", strconv.Itoa(scale(rung.MinBitrate, stereo)))
+	}
+
+	maxBitrate := int(float64(bitrate) * maxRate)
+	args = append(args, "-maxrate", strconv.Itoa(maxBitrate), "-bufsize", strconv.Itoa(maxBitrate*2))
+
+	if p.GOP > 0 {
+		// Keyframes at a fixed interval, without scene cut keyframes, align the segments of every rung so players switch cleanly.
+		keyframes := "expr:gte(t,n_forced*" + strconv.FormatFloat(p.GOP.Seconds(), 'f', -1, 64) + ")"
+		args = append(args, "-force_key_frames", keyframes, "-sc_threshold", "0")
+		if p.Codec == "libx265" {
+			args = append(args, "-x265-params", "scenecut=0:open-gop=0")
+		} else {
+			args = append(args, "-flags", "+cgop")
+		}
+	}
+
+	return append(args, "-c:a", "copy")
+}
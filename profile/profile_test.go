@@ -0,0 +1,74 @@
+package profile
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"vrmix/hls"
+)
+
+func TestSelect(t *testing.T) {
+	cases := []struct {
+		layout  hls.Layout
+		name    string
+		profile string
+	}{
+		{hls.Layout{}, "", "flat"},
+		{hls.Layout{Projection: hls.ProjectionRectilinear}, "", "flat"},
+		{hls.Layout{Projection: hls.ProjectionEquirectangular, Stereo: hls.StereoTopBottom}, "", "vr360"},
+		{hls.Layout{Projection: hls.ProjectionFisheye, Stereo: hls.StereoSideBySide}, "", "vr180"},
+		{hls.Layout{Projection: hls.ProjectionEquiAngularCubemap}, "", "cubemap"},
+		{hls.Layout{Projection: hls.ProjectionEquirectangular}, "flat", "flat"},
+	}
+
+	for _, c := range cases {
+		profile, err := Select(c.layout, c.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if profile.Name != c.profile {
+			t.Errorf("expected %s for %+v, got %s", c.profile, c.layout, profile.Name)
+		}
+	}
+
+	if _, err := Select(hls.Layout{}, "missing"); !errors.Is(err, ErrUnknownProfile) {
+		t.Errorf("expected ErrUnknownProfile, got %v", err)
+	}
+}
+
+func TestLadder(t *testing.T) {
+	rungs := VR180.Ladder(hls.Layout{Projection: hls.ProjectionHalfEquirectangular, Stereo: hls.StereoSideBySide, Resolution: "5760x2880"})
+	if len(rungs) != 3 || rungs[0].Name != "2880p" {
+		t.Errorf("expected the rungs up to 2880p, got %+v", rungs)
+	}
+
+	if rungs := VR180.Ladder(hls.Layout{Resolution: "640x640"}); len(rungs) != 1 || rungs[0].Name != "1440p" {
+		t.Errorf("expected the lowest rung for a small source, got %+v", rungs)
+	}
+
+	if rungs := VR360.Ladder(hls.Layout{}); len(rungs) != len(VR360.Rungs) {
+		t.Errorf("expected every rung when the resolution is unknown, got %+v", rungs)
+	}
+}
+
+func TestCodecArgs(t *testing.T) {
+	rung := VR180.Rungs[1]
+	args := strings.Join(VR180.CodecArgs(rung, hls.StereoSideBySide), " ")
+	for _, expected := range []string{"-c:v libx265", "-s 5760x2880", "-b:v 50000000", "-minrate 30000000", "-maxrate 60000000", "-force_key_frames expr:gte(t,n_forced*1)", "-x265-params scenecut=0:open-gop=0"} {
+		if !strings.Contains(args, expected) {
+			t.Errorf("expected %q in %q", expected, args)
+		}
+	}
+
+	flat := Flat.CodecArgs(Flat.Rungs[0], "")
+	if slices.Contains(flat, "-minrate") || !slices.Contains(flat, "+cgop") {
+		t.Errorf("expected a flat rung without bitrate floor, got %v", flat)
+	}
+
+	if width, height := rung.Resolution(hls.StereoTopBottom); width != 2880 || height != 5760 {
+		t.Errorf("expected 2880x5760, got %dx%d", width, height)
+	}
+}
@@ -0,0 +1,2 @@
+// Package report defines the hook receiving the panics and non-retryable errors of the subsystems, so deployments can plug any error tracker.
+package report
@@ -0,0 +1,73 @@
+package report
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"time"
+)
+
+// Report represents a panic or a non-retryable error of a subsystem, with its structured context.
+type Report struct {
+	Time      time.Time         // Time the error happened
+	Subsystem string            // Subsystem reporting the error, like "webhook" or "ingest"
+	Err       error             // Error reported, a PanicError for panics
+	Fields    map[string]string // Structured context, like the channel, session or stream, keyed by the logging keys
+	Stack     []byte            // Stack trace of the panicking goroutine, nil for errors
+}
+
+// Reporter receives the reports, like an adapter to an error tracker; implementations must be safe for concurrent use and should not block.
+type Reporter interface {
+	// Report sends the report.
+	Report(ctx context.Context, report Report)
+}
+
+// Func adapts a function to a Reporter.
+type Func func(ctx context.Context, report Report)
+
+// Report calls the function with the report.
+func (f Func) Report(ctx context.Context, report Report) {
+	f(ctx, report)
+}
+
+// PanicError represents a recovered panic.
+type PanicError struct {
+	Value any // Value the goroutine panicked with
+}
+
+// Error returns the panic value as a string.
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// Unwrap returns the panic value when it is an error.
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// Error reports a non-retryable error of the subsystem, doing nothing when the reporter or the error is nil.
+func Error(ctx context.Context, reporter Reporter, subsystem string, err error, fields map[string]string) {
+	if reporter == nil || err == nil {
+		return
+	}
+
+	reporter.Report(ctx, Report{Time: time.Now(), Subsystem: subsystem, Err: err, Fields: fields})
+}
+
+// Panic reports the recovered value with the stack of the current goroutine, doing nothing when the reporter is nil.
+func Panic(ctx context.Context, reporter Reporter, subsystem string, value any, fields map[string]string) {
+	if reporter == nil {
+		return
+	}
+
+	reporter.Report(ctx, Report{Time: time.Now(), Subsystem: subsystem, Err: &PanicError{Value: value}, Fields: fields, Stack: debug.Stack()})
+}
+
+// Recover reports a panic of the goroutine and panics again, so the process behaves as without a reporter; it must be deferred directly.
+func Recover(ctx context.Context, reporter Reporter, subsystem string, fields map[string]string) {
+	if value := recover(); value != nil {
+		Panic(ctx, reporter, subsystem, value, fields)
+		panic(value)
+	}
+}
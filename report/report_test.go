@@ -0,0 +1,51 @@
+package report
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestError(t *testing.T) {
+	var reports []Report
+	reporter := Func(func(ctx context.Context, report Report) { reports = append(reports, report) })
+
+	Error(context.Background(), nil, "test", errors.New("ignored"), nil)
+	Error(context.Background(), reporter, "test", nil, nil)
+	Error(context.Background(), reporter, "webhook", errors.New("delivery failed"), map[string]string{"channel": "main"})
+
+	if len(reports) != 1 {
+		t.Fatalf("expected 1 report, got %d", len(reports))
+	}
+
+	if reports[0].Subsystem != "webhook" || reports[0].Fields["channel"] != "main" || reports[0].Stack != nil {
+		t.Errorf("expected the webhook error, got %+v", reports[0])
+	}
+}
+
+func TestRecover(t *testing.T) {
+	var reported Report
+	reporter := Func(func(ctx context.Context, report Report) { reported = report })
+	cause := errors.New("boom")
+
+	func() {
+		defer func() {
+			if value := recover(); value != cause {
+				t.Errorf("expected the panic to continue, got %v", value)
+			}
+		}()
+
+		defer Recover(context.Background(), reporter, "scheduler", nil)
+		panic(cause)
+	}()
+
+	var panicErr *PanicError
+	if !errors.As(reported.Err, &panicErr) || !errors.Is(reported.Err, cause) {
+		t.Errorf("expected a panic error wrapping the cause, got %v", reported.Err)
+	}
+
+	if !strings.Contains(string(reported.Stack), "TestRecover") {
+		t.Errorf("expected the stack of the panic, got %s", reported.Stack)
+	}
+}
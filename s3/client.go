@@ -0,0 +1,289 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNotFound indicates that the object does not exist.
+var ErrNotFound = errors.New("object not found")
+
+// metadataPrefix prefixes the headers of the user-defined metadata of the objects.
+const metadataPrefix = "X-Amz-Meta-"
+
+// StatusError records an unexpected status answered by the object storage.
+type StatusError struct {
+	StatusCode int    // Status code of the response
+	Code       string // S3 error code, like "AccessDenied"
+	Message    string // S3 error message
+}
+
+func (e *StatusError) Error() string {
+	return "s3: " + strconv.Itoa(e.StatusCode) + " " + e.Code + ": " + e.Message
+}
+
+// Client calls an S3-compatible object storage, like AWS S3 or MinIO.
+type Client struct {
+	Endpoint     string       // Base URL of the storage, like "https://s3.us-east-1.amazonaws.com" or "http://localhost:9000"
+	Region       string       // Region of the bucket, like "us-east-1"
+	Bucket       string       // Bucket holding the objects
+	AccessKey    string       // Access key ID
+	SecretKey    string       // Secret access key
+	SessionToken string       // Session token of temporary credentials, empty for none
+	PathStyle    bool         // Indicates if the bucket is addressed in the path instead of the hostname, as required by MinIO
+	HTTP         *http.Client // Client used for the requests, defaults to http.DefaultClient
+
+	now func() time.Time
+}
+
+// Object represents an object in the bucket.
+type Object struct {
+	Key          string    // Key of the object
+	Size         int64     // Size of the object in bytes
+	LastModified time.Time // Last modification time of the object
+
+	// Metadata is the user-defined metadata of the object by canonical header name without its prefix, like "Expires", only returned by HeadObject.
+	Metadata map[string]string
+}
+
+// objectURL returns the URL of the object key.
+func (c *Client) objectURL(key string) (*url.URL, error) {
+	u, err := url.Parse(strings.TrimSuffix(c.Endpoint, "/"))
+	if err != nil {
+		return nil, err
+	}
+
+	path := "/" + strings.TrimPrefix(key, "/")
+	if c.PathStyle {
+		path = "/" + c.Bucket + path
+	} else {
+		u.Host = c.Bucket + "." + u.Host
+	}
+
+	u.Path = path
+	u.RawPath = escape(path, true)
+	return u, nil
+}
+
+// time returns the current time used to sign the requests.
+func (c *Client) time() time.Time {
+	if c.now != nil {
+		return c.now().UTC()
+	}
+
+	return time.Now().UTC()
+}
+
+// do signs and sends the request, converting error responses to errors.
+func (c *Client) do(req *http.Request, payloadHash string) (*http.Response, error) {
+	c.signer().Sign(req, payloadHash, c.time())
+
+	client := c.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+
+	statusError := &StatusError{StatusCode: resp.StatusCode}
+	xml.NewDecoder(resp.Body).Decode(&struct {
+		Code    *string `xml:"Code"`
+		Message *string `xml:"Message"`
+	}{&statusError.Code, &statusError.Message})
+
+	return nil, statusError
+}
+
+// GetObject opens the object, or the byte range of it when rangeHeader is not empty, like "bytes=100-199".
+func (c *Client) GetObject(ctx context.Context, key string, rangeHeader string) (io.ReadCloser, error) {
+	u, err := c.objectURL(key)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if rangeHeader != "" {
+		req.Header.Set("Range", rangeHeader)
+	}
+
+	resp, err := c.do(req, emptyPayload)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Body, nil
+}
+
+// HeadObject returns the metadata of the object.
+func (c *Client) HeadObject(ctx context.Context, key string) (Object, error) {
+	u, err := c.objectURL(key)
+	if err != nil {
+		return Object{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, u.String(), nil)
+	if err != nil {
+		return Object{}, err
+	}
+
+	resp, err := c.do(req, emptyPayload)
+	if err != nil {
+		return Object{}, err
+	}
+	resp.Body.Close()
+
+	object := Object{Key: key, Size: resp.ContentLength}
+	object.LastModified, _ = http.ParseTime(resp.Header.Get("Last-Modified"))
+	for name, values := range resp.Header {
+		if name, ok := strings.CutPrefix(name, metadataPrefix); ok && len(values) > 0 {
+			if object.Metadata == nil {
+				object.Metadata = map[string]string{}
+			}
+
+			object.Metadata[name] = values[0]
+		}
+	}
+
+	return object, nil
+}
+
+// PutObject stores the object, replacing the one with the same key, with the content type, empty for the default one, and the user-defined metadata.
+func (c *Client) PutObject(ctx context.Context, key string, body []byte, contentType string, metadata map[string]string) error {
+	u, err := c.objectURL(key)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	for name, value := range metadata {
+		req.Header.Set(metadataPrefix+name, value)
+	}
+
+	resp, err := c.do(req, PayloadHash(body))
+	if err != nil {
+		return err
+	}
+
+	return resp.Body.Close()
+}
+
+// DeleteObject removes the object, succeeding when it does not exist.
+func (c *Client) DeleteObject(ctx context.Context, key string) error {
+	u, err := c.objectURL(key)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, u.String(), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.do(req, emptyPayload)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	return resp.Body.Close()
+}
+
+// listResult is the response of ListObjectsV2.
+type listResult struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		Size         int64     `xml:"Size"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// ListObjects returns every object with the key prefix, following the pagination.
+func (c *Client) ListObjects(ctx context.Context, prefix string) ([]Object, error) {
+	var objects []Object
+	token := ""
+
+	for {
+		u, err := c.objectURL("")
+		if err != nil {
+			return nil, err
+		}
+
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		u.RawQuery = canonicalQuery(query)
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := c.do(req, emptyPayload)
+		if err != nil {
+			return nil, err
+		}
+
+		var result listResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, content := range result.Contents {
+			objects = append(objects, Object{Key: content.Key, Size: content.Size, LastModified: content.LastModified})
+		}
+
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return objects, nil
+		}
+
+		token = result.NextContinuationToken
+	}
+}
+
+// PresignGet returns a URL allowing anyone to download the object until it expires.
+func (c *Client) PresignGet(key string, expires time.Duration) (string, error) {
+	u, err := c.objectURL(key)
+	if err != nil {
+		return "", err
+	}
+
+	return c.presign(http.MethodGet, u, expires, c.time()), nil
+}
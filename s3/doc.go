@@ -0,0 +1,2 @@
+// Package s3 contains a minimal client for S3-compatible object storages, signing requests with AWS Signature Version 4.
+package s3
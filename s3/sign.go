@@ -0,0 +1,166 @@
+package s3
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// algorithm is the signing algorithm of AWS Signature Version 4.
+	algorithm = "AWS4-HMAC-SHA256"
+
+	// unsignedPayload is the payload hash used when the body is not signed.
+	unsignedPayload = "UNSIGNED-PAYLOAD"
+
+	// emptyPayload is the SHA-256 of an empty body.
+	emptyPayload = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+	// amzDateFormat is the format of the X-Amz-Date header.
+	amzDateFormat = "20060102T150405Z"
+)
+
+// hmacSHA256 returns the HMAC-SHA256 of the data.
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// hashHex returns the hex encoded SHA-256 of the data.
+func hashHex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// escape encodes the string as required by the canonical request, keeping slashes when encoding paths.
+func escape(s string, keepSlash bool) string {
+	var builder strings.Builder
+
+	for _, b := range []byte(s) {
+		if ('A' <= b && b <= 'Z') || ('a' <= b && b <= 'z') || ('0' <= b && b <= '9') || b == '-' || b == '_' || b == '.' || b == '~' || (keepSlash && b == '/') {
+			builder.WriteByte(b)
+		} else {
+			builder.WriteString("%" + strings.ToUpper(hex.EncodeToString([]byte{b})))
+		}
+	}
+
+	return builder.String()
+}
+
+// canonicalQuery returns the query sorted and encoded as required by the canonical request.
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	var parts []string
+	for _, key := range keys {
+		values := slices.Clone(query[key])
+		slices.Sort(values)
+
+		for _, value := range values {
+			parts = append(parts, escape(key, false)+"="+escape(value, false))
+		}
+	}
+
+	return strings.Join(parts, "&")
+}
+
+// PayloadHash returns the payload hash of a request body, as passed to Signer.Sign.
+func PayloadHash(body []byte) string {
+	return hashHex(body)
+}
+
+// Signer signs the requests of an AWS service with Signature Version 4, like the requests of S3 or KMS.
+type Signer struct {
+	Service      string // Name of the service in the credential scope, like "s3" or "kms"
+	Region       string // Region of the service, like "us-east-1"
+	AccessKey    string // Access key ID
+	SecretKey    string // Secret access key
+	SessionToken string // Session token of temporary credentials, empty for none
+}
+
+// signer returns the signer of the requests of the client.
+func (c *Client) signer() Signer {
+	return Signer{Service: "s3", Region: c.Region, AccessKey: c.AccessKey, SecretKey: c.SecretKey, SessionToken: c.SessionToken}
+}
+
+// scope returns the credential scope of the date.
+func (s Signer) scope(date time.Time) string {
+	return date.Format("20060102") + "/" + s.Region + "/" + s.Service + "/aws4_request"
+}
+
+// signature signs the canonical request.
+func (s Signer) signature(date time.Time, canonicalRequest string) string {
+	stringToSign := algorithm + "\n" + date.Format(amzDateFormat) + "\n" + s.scope(date) + "\n" + hashHex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+s.SecretKey), date.Format("20060102"))
+	key = hmacSHA256(key, s.Region)
+	key = hmacSHA256(key, s.Service)
+	key = hmacSHA256(key, "aws4_request")
+
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+// Sign adds the authorization headers to the request, using the payload hash of its body.
+func (s Signer) Sign(req *http.Request, payloadHash string, date time.Time) {
+	req.Header.Set("X-Amz-Date", date.Format(amzDateFormat))
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if s.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.SessionToken)
+	}
+
+	names := []string{"host"}
+	for name := range req.Header {
+		lower := strings.ToLower(name)
+		if strings.HasPrefix(lower, "x-amz-") || lower == "range" || lower == "content-type" {
+			names = append(names, lower)
+		}
+	}
+	slices.Sort(names)
+
+	var headers strings.Builder
+	for _, name := range names {
+		value := req.Host
+		if name != "host" {
+			value = strings.TrimSpace(req.Header.Get(name))
+		}
+
+		headers.WriteString(name + ":" + value + "\n")
+	}
+
+	signedHeaders := strings.Join(names, ";")
+	canonicalRequest := req.Method + "\n" + escape(req.URL.Path, true) + "\n" + canonicalQuery(req.URL.Query()) + "\n" + headers.String() + "\n" + signedHeaders + "\n" + payloadHash
+
+	req.Header.Set("Authorization", algorithm+" Credential="+s.AccessKey+"/"+s.scope(date)+", SignedHeaders="+signedHeaders+", Signature="+s.signature(date, canonicalRequest))
+}
+
+// presign returns the URL with the query authorization valid for the duration.
+func (c *Client) presign(method string, u *url.URL, expires time.Duration, date time.Time) string {
+	s := c.signer()
+	query := u.Query()
+	query.Set("X-Amz-Algorithm", algorithm)
+	query.Set("X-Amz-Credential", c.AccessKey+"/"+s.scope(date))
+	query.Set("X-Amz-Date", date.Format(amzDateFormat))
+	query.Set("X-Amz-Expires", strconv.FormatInt(int64(expires/time.Second), 10))
+	query.Set("X-Amz-SignedHeaders", "host")
+	if c.SessionToken != "" {
+		query.Set("X-Amz-Security-Token", c.SessionToken)
+	}
+
+	canonicalRequest := method + "\n" + escape(u.Path, true) + "\n" + canonicalQuery(query) + "\nhost:" + u.Host + "\n\nhost\n" + unsignedPayload
+	query.Set("X-Amz-Signature", s.signature(date, canonicalRequest))
+
+	presigned := *u
+	presigned.RawQuery = canonicalQuery(query)
+	return presigned.String()
+}
@@ -0,0 +1,2 @@
+// Package scheduler runs the segment download and conversion jobs in a bounded worker pool, running the segments requested by players before the prefetched ones.
+package scheduler
@@ -0,0 +1,63 @@
+package scheduler
+
+import "time"
+
+// Candidate represents a segment following the requested one, which a Policy may prefetch.
+type Candidate struct {
+	Job      Job           // Job producing the segment, submitted with PriorityPrefetch
+	Duration time.Duration // Media duration of the segment
+	Bitrate  int64         // Bits per second of the segment, like the bandwidth of its variant, zero if unknown
+}
+
+// size returns the estimated bytes of the segment, zero if its bitrate is unknown.
+func (c *Candidate) size() int64 {
+	return int64(c.Duration.Seconds() * float64(c.Bitrate) / 8)
+}
+
+// Policy represents how far ahead of the players the segments are produced, consulted after each segment request so the players never wait for a just-in-time conversion.
+//
+// The segments are prefetched up to the farther of Segments and Lookahead, within MaxBytes.
+type Policy struct {
+	Segments  int           // Segments prefetched after the requested one, defaults to 3 when Lookahead is zero
+	Lookahead time.Duration // Media time prefetched after the requested segment, zero to only count the segments
+	MaxBytes  int64         // Bytes prefetched ahead, estimated from the bitrate of the segments, so fewer high bitrate segments are prefetched, zero for no limit
+}
+
+// Select returns the candidates to prefetch, in order, from the segments following the requested one, always selecting the next one so the player never stalls on it.
+func (p Policy) Select(candidates []Candidate) []Candidate {
+	segments := p.Segments
+	if segments <= 0 && p.Lookahead <= 0 {
+		segments = 3
+	}
+
+	var duration time.Duration
+	var bytes int64
+	for i := range candidates {
+		if i >= segments && duration >= p.Lookahead {
+			return candidates[:i]
+		}
+
+		bytes += candidates[i].size()
+		if i > 0 && p.MaxBytes > 0 && bytes > p.MaxBytes {
+			return candidates[:i]
+		}
+
+		duration += candidates[i].Duration
+	}
+
+	return candidates
+}
+
+// Prefetch submits the jobs of the candidates selected to the scheduler, returning how many were submitted before an error, like ErrQueueFull.
+func (p Policy) Prefetch(s *Scheduler, candidates []Candidate) (int, error) {
+	selected := p.Select(candidates)
+	for i := range selected {
+		j := selected[i].Job
+		j.Priority = PriorityPrefetch
+		if err := s.Submit(j); err != nil {
+			return i, err
+		}
+	}
+
+	return len(selected), nil
+}
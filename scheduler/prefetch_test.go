@@ -0,0 +1,72 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// candidates returns segments of the duration and bitrate, their jobs doing nothing
+func candidates(count int, duration time.Duration, bitrate int64) []Candidate {
+	result := make([]Candidate, count)
+	for i := range result {
+		result[i] = Candidate{Job: Job{ID: "segment" + strconv.Itoa(i), Kind: KindConversion, Run: func(ctx context.Context) error { return nil }}, Duration: duration, Bitrate: bitrate}
+	}
+
+	return result
+}
+
+func TestPolicySelect(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		policy   Policy
+		next     []Candidate
+		expected int
+	}{
+		{"default", Policy{}, candidates(10, 4*time.Second, 0), 3},
+		{"segments", Policy{Segments: 5}, candidates(10, 4*time.Second, 0), 5},
+		{"fewer candidates", Policy{Segments: 5}, candidates(2, 4*time.Second, 0), 2},
+		{"lookahead", Policy{Lookahead: 10 * time.Second}, candidates(10, 4*time.Second, 0), 3},
+		{"farther lookahead", Policy{Segments: 2, Lookahead: 20 * time.Second}, candidates(10, 4*time.Second, 0), 5},
+		{"farther segments", Policy{Segments: 6, Lookahead: 8 * time.Second}, candidates(10, 4*time.Second, 0), 6},
+		// 4 seconds at 8 Mbps are 4 MB per segment.
+		{"max bytes", Policy{Segments: 5, MaxBytes: 9_000_000}, candidates(10, 4*time.Second, 8_000_000), 2},
+		{"unknown bitrate", Policy{Segments: 5, MaxBytes: 9_000_000}, candidates(10, 4*time.Second, 0), 5},
+		{"next segment", Policy{Segments: 5, MaxBytes: 1}, candidates(10, 4*time.Second, 8_000_000), 1},
+		{"no candidates", Policy{}, nil, 0},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if selected := test.policy.Select(test.next); len(selected) != test.expected {
+				t.Errorf("expected %d segments, got %d", test.expected, len(selected))
+			}
+		})
+	}
+}
+
+func TestPolicyPrefetch(t *testing.T) {
+	s := New(Config{Workers: 1, MaxPending: 2})
+	defer s.Close(context.Background())
+
+	hold, release := blocker("hold")
+	s.Submit(hold)
+	running(t, s, 1)
+
+	next := candidates(3, 4*time.Second, 0)
+	count, err := Policy{}.Prefetch(s, next)
+	if !errors.Is(err, ErrQueueFull) || count != 2 {
+		t.Errorf("expected 2 jobs submitted before %v, got %d and %v", ErrQueueFull, count, err)
+	}
+
+	if pending, _ := s.Stats(); pending != 2 {
+		t.Errorf("expected 2 pending jobs, got %d", pending)
+	}
+
+	release()
+	for _, candidate := range next[:2] {
+		if err := s.Await(context.Background(), candidate.Job.ID); err != nil {
+			t.Errorf("expected the prefetched segment to be produced, got %v", err)
+		}
+	}
+}
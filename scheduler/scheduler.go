@@ -0,0 +1,328 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"vrmix/logging"
+	"vrmix/report"
+)
+
+var (
+	// ErrQueueFull indicates that the job was rejected because too many jobs are pending.
+	ErrQueueFull = errors.New("scheduler queue is full")
+
+	// ErrClosed indicates that the scheduler no longer accepts jobs.
+	ErrClosed = errors.New("scheduler closed")
+
+	// ErrUnknownJob indicates that no job produces the awaited segment.
+	ErrUnknownJob = errors.New("unknown job")
+
+	// ErrCanceled indicates that the job was canceled before it ran.
+	ErrCanceled = errors.New("job canceled")
+)
+
+// Kind represents what a job does.
+type Kind string
+
+const (
+	// KindDownload is a job downloading a segment from its source.
+	KindDownload Kind = "download"
+
+	// KindConversion is a job converting a segment to the profile of its channel.
+	KindConversion Kind = "conversion"
+)
+
+// Priority represents how urgently a job runs.
+type Priority int
+
+const (
+	// PriorityPrefetch is a job producing a segment ahead of the players.
+	PriorityPrefetch Priority = iota
+
+	// PriorityNow is a job producing a segment a player is waiting for, run before every prefetch.
+	PriorityNow
+)
+
+// Job represents a download or conversion producing a segment.
+type Job struct {
+	ID       string   // Identifier of the segment produced, deduplicating the jobs producing the same segment
+	Kind     Kind     // What the job does, used in the logs
+	Priority Priority // How urgently the job runs
+
+	// Run produces the segment, its context being canceled when the scheduler is closed without waiting for it.
+	Run func(ctx context.Context) error
+}
+
+// state represents where a job is in its life.
+type state int
+
+const (
+	statePending state = iota
+	stateRunning
+	stateDone
+)
+
+// job is a submitted job with its outcome.
+type job struct {
+	Job
+	state    state
+	done     chan struct{} // closed once the job ends
+	err      error         // error returned by the job, valid once done is closed
+	finished time.Time     // time the job ended
+}
+
+// Config represents the configuration of a Scheduler.
+type Config struct {
+	Workers    int           // Concurrent jobs, defaults to 4
+	MaxPending int           // Jobs waiting to run before new jobs are rejected, defaults to 1024
+	Retain     time.Duration // Time the ended jobs are remembered, so awaiting them returns at once and a successful one is not run again, defaults to one minute
+
+	// Logger receives the failed jobs, defaults to slog.Default.
+	Logger *slog.Logger
+
+	// Reporter receives the failed jobs, nil to not report them.
+	Reporter report.Reporter
+}
+
+// Scheduler runs jobs in a bounded worker pool, running the jobs a player waits for before the prefetched ones and each segment once.
+type Scheduler struct {
+	config   Config
+	ctx      context.Context
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+	mutex    sync.Mutex
+	cond     *sync.Cond
+	jobs     map[string]*job
+	queues   [PriorityNow + 1][]*job // pending jobs of each priority, skipping the ones promoted or canceled
+	pending  int
+	running  int
+	finished []*job // ended jobs in the order they ended, forgotten after Retain
+	closed   bool
+	now      func() time.Time
+}
+
+// New creates a new Scheduler and starts its workers.
+func New(config Config) *Scheduler {
+	if config.Workers <= 0 {
+		config.Workers = 4
+	}
+
+	if config.MaxPending <= 0 {
+		config.MaxPending = 1024
+	}
+
+	if config.Retain <= 0 {
+		config.Retain = time.Minute
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Scheduler{config: config, ctx: ctx, cancel: cancel, jobs: map[string]*job{}, now: time.Now}
+	s.cond = sync.NewCond(&s.mutex)
+	for range config.Workers {
+		s.wg.Add(1)
+		go s.work()
+	}
+
+	return s
+}
+
+// Submit queues the job unless a job producing the same segment is pending, running or succeeded recently, raising the priority of a pending one if needed; a failed or canceled job is queued again.
+func (s *Scheduler) Submit(j Job) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.closed {
+		return ErrClosed
+	}
+
+	s.forget()
+	if existing := s.jobs[j.ID]; existing != nil && (existing.state != stateDone || existing.err == nil) {
+		if existing.state == statePending && j.Priority > existing.Priority {
+			existing.Priority = j.Priority
+			s.queues[j.Priority] = append(s.queues[j.Priority], existing)
+		}
+
+		return nil
+	}
+
+	if s.pending >= s.config.MaxPending {
+		return ErrQueueFull
+	}
+
+	queued := &job{Job: j, done: make(chan struct{})}
+	s.jobs[j.ID] = queued
+	s.queues[j.Priority] = append(s.queues[j.Priority], queued)
+	s.pending++
+	s.cond.Signal()
+
+	return nil
+}
+
+// Await waits until the job producing the segment ends, returning its error.
+func (s *Scheduler) Await(ctx context.Context, id string) error {
+	s.mutex.Lock()
+	j := s.jobs[id]
+	s.mutex.Unlock()
+
+	if j == nil {
+		return ErrUnknownJob
+	}
+
+	select {
+	case <-j.done:
+		return j.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Do submits the job as one a player waits for and awaits it.
+func (s *Scheduler) Do(ctx context.Context, j Job) error {
+	j.Priority = PriorityNow
+	if err := s.Submit(j); err != nil {
+		return err
+	}
+
+	return s.Await(ctx, j.ID)
+}
+
+// Cancel drops the pending job producing the segment, like a prefetch no longer needed, returning false if it is not pending.
+func (s *Scheduler) Cancel(id string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	j := s.jobs[id]
+	if j == nil || j.state != statePending {
+		return false
+	}
+
+	s.pending--
+	s.end(j, ErrCanceled)
+	return true
+}
+
+// Stats returns the number of pending and running jobs.
+func (s *Scheduler) Stats() (int, int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.pending, s.running
+}
+
+// Close stops accepting jobs and waits until the pending ones ran, canceling the running jobs once the context is done.
+func (s *Scheduler) Close(ctx context.Context) error {
+	s.mutex.Lock()
+	s.closed = true
+	s.cond.Broadcast()
+	s.mutex.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		s.cancel()
+		return nil
+	case <-ctx.Done():
+		s.cancel()
+		return ctx.Err()
+	}
+}
+
+// work runs the pending jobs until the scheduler is closed.
+func (s *Scheduler) work() {
+	defer s.wg.Done()
+
+	for {
+		s.mutex.Lock()
+		j := s.next()
+		for j == nil && !s.closed {
+			s.cond.Wait()
+			j = s.next()
+		}
+
+		if j == nil {
+			s.mutex.Unlock()
+			return
+		}
+
+		j.state = stateRunning
+		s.pending--
+		s.running++
+		s.mutex.Unlock()
+
+		err := s.run(j)
+		if err != nil {
+			logging.Or(s.config.Logger).Warn("job failed", slog.String("job", j.ID), slog.String("kind", string(j.Kind)), logging.Err(err))
+
+			// A panic was already reported with its stack.
+			var panicked *report.PanicError
+			if !errors.As(err, &panicked) {
+				report.Error(s.ctx, s.config.Reporter, "scheduler", err, map[string]string{"job": j.ID, "kind": string(j.Kind)})
+			}
+		}
+
+		s.mutex.Lock()
+		s.running--
+		s.end(j, err)
+		s.mutex.Unlock()
+	}
+}
+
+// run runs the job, returning a panic of the job as a report.PanicError so the worker survives and its waiters are released.
+func (s *Scheduler) run(j *job) (err error) {
+	defer func() {
+		if value := recover(); value != nil {
+			report.Panic(s.ctx, s.config.Reporter, "scheduler", value, map[string]string{"job": j.ID, "kind": string(j.Kind)})
+			err = &report.PanicError{Value: value}
+		}
+	}()
+
+	return j.Run(s.ctx)
+}
+
+// next pops the next pending job, the ones a player waits for first, called with the mutex held.
+func (s *Scheduler) next() *job {
+	for priority := PriorityNow; priority >= PriorityPrefetch; priority-- {
+		for len(s.queues[priority]) > 0 {
+			j := s.queues[priority][0]
+			s.queues[priority][0] = nil
+			s.queues[priority] = s.queues[priority][1:]
+
+			// Promoted jobs are queued again with their new priority, and canceled ones are done.
+			if j.state == statePending && j.Priority == priority {
+				return j
+			}
+		}
+	}
+
+	return nil
+}
+
+// end records the outcome of the job and wakes its waiters, called with the mutex held.
+func (s *Scheduler) end(j *job, err error) {
+	j.state, j.err, j.finished = stateDone, err, s.now()
+	close(j.done)
+	s.finished = append(s.finished, j)
+}
+
+// forget drops the jobs which ended more than Retain ago, called with the mutex held.
+func (s *Scheduler) forget() {
+	deadline := s.now().Add(-s.config.Retain)
+	for len(s.finished) > 0 && s.finished[0].finished.Before(deadline) {
+		j := s.finished[0]
+		s.finished[0] = nil
+		s.finished = s.finished[1:]
+
+		if s.jobs[j.ID] == j {
+			delete(s.jobs, j.ID)
+		}
+	}
+}
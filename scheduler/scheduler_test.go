@@ -0,0 +1,269 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"vrmix/report"
+)
+
+// running waits until the scheduler runs the number of jobs
+func running(t *testing.T, s *Scheduler, count int) {
+	t.Helper()
+
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		if _, running := s.Stats(); running == count {
+			return
+		}
+	}
+
+	t.Fatalf("expected %d running jobs", count)
+}
+
+// blocker returns a job holding its worker until released
+func blocker(id string) (Job, func()) {
+	release := make(chan struct{})
+	return Job{ID: id, Kind: KindDownload, Run: func(ctx context.Context) error {
+		<-release
+		return nil
+	}}, func() { close(release) }
+}
+
+// recorder returns the jobs appending their ID to the order they ran in
+func recorder(order *[]string, mutex *sync.Mutex) func(id string, priority Priority) Job {
+	return func(id string, priority Priority) Job {
+		return Job{ID: id, Kind: KindConversion, Priority: priority, Run: func(ctx context.Context) error {
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			*order = append(*order, id)
+			return nil
+		}}
+	}
+}
+
+func TestSchedulerPriority(t *testing.T) {
+	s := New(Config{Workers: 1})
+	defer s.Close(context.Background())
+
+	hold, release := blocker("hold")
+	s.Submit(hold)
+	running(t, s, 1)
+
+	var order []string
+	var mutex sync.Mutex
+	job := recorder(&order, &mutex)
+
+	s.Submit(job("prefetch-1", PriorityPrefetch))
+	s.Submit(job("prefetch-2", PriorityPrefetch))
+	s.Submit(job("prefetch-3", PriorityPrefetch))
+	s.Submit(job("now", PriorityNow))
+	s.Submit(job("prefetch-3", PriorityNow))
+
+	if pending, running := s.Stats(); pending != 4 || running != 1 {
+		t.Errorf("expected 4 pending and 1 running jobs, got %d and %d", pending, running)
+	}
+
+	release()
+	if err := s.Await(context.Background(), "prefetch-2"); err != nil {
+		t.Fatal(err)
+	}
+
+	s.Await(context.Background(), "prefetch-1")
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if !slices.Equal(order, []string{"now", "prefetch-3", "prefetch-1", "prefetch-2"}) {
+		t.Errorf("expected the requested and promoted jobs first, got %v", order)
+	}
+}
+
+func TestSchedulerDeduplication(t *testing.T) {
+	s := New(Config{Workers: 4})
+	defer s.Close(context.Background())
+
+	var runs atomic.Int32
+	started := make(chan struct{})
+	release := make(chan struct{})
+	job := Job{ID: "segment-1", Run: func(ctx context.Context) error {
+		if runs.Add(1) == 1 {
+			close(started)
+		}
+
+		<-release
+		return nil
+	}}
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := s.Do(context.Background(), job); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+
+	<-started
+	close(release)
+	wg.Wait()
+
+	if err := s.Do(context.Background(), job); err != nil || runs.Load() != 1 {
+		t.Errorf("expected the segment to be produced once, got %d runs and %v", runs.Load(), err)
+	}
+}
+
+func TestSchedulerFailure(t *testing.T) {
+	s := New(Config{Workers: 1})
+	defer s.Close(context.Background())
+
+	failure := errors.New("origin unavailable")
+	var runs atomic.Int32
+	job := Job{ID: "segment-1", Run: func(ctx context.Context) error {
+		if runs.Add(1) == 1 {
+			return failure
+		}
+
+		return nil
+	}}
+
+	if err := s.Do(context.Background(), job); !errors.Is(err, failure) {
+		t.Errorf("expected the error of the job, got %v", err)
+	}
+
+	if err := s.Do(context.Background(), job); err != nil || runs.Load() != 2 {
+		t.Errorf("expected the failed job to run again, got %d runs and %v", runs.Load(), err)
+	}
+
+	if err := s.Await(context.Background(), "segment-2"); !errors.Is(err, ErrUnknownJob) {
+		t.Errorf("expected an unknown job, got %v", err)
+	}
+}
+
+func TestSchedulerPanic(t *testing.T) {
+	var reports []report.Report
+	s := New(Config{Workers: 1, Reporter: report.Func(func(ctx context.Context, r report.Report) { reports = append(reports, r) })})
+	defer s.Close(context.Background())
+
+	job := Job{ID: "segment-1", Run: func(ctx context.Context) error { panic("corrupt segment") }}
+	var panicked *report.PanicError
+	if err := s.Do(context.Background(), job); !errors.As(err, &panicked) || panicked.Value != "corrupt segment" {
+		t.Errorf("expected the panic as an error, got %v", err)
+	}
+
+	if err := s.Do(context.Background(), Job{ID: "segment-2", Run: func(ctx context.Context) error { return nil }}); err != nil {
+		t.Errorf("expected the worker to survive the panic, got %v", err)
+	}
+
+	if len(reports) != 1 || reports[0].Stack == nil {
+		t.Errorf("expected the panic to be reported once with its stack, got %v", reports)
+	}
+}
+
+func TestSchedulerCancel(t *testing.T) {
+	s := New(Config{Workers: 1, MaxPending: 1})
+	defer s.Close(context.Background())
+
+	hold, release := blocker("hold")
+	defer release()
+	s.Submit(hold)
+	running(t, s, 1)
+
+	var ran atomic.Bool
+	s.Submit(Job{ID: "prefetch", Run: func(ctx context.Context) error {
+		ran.Store(true)
+		return nil
+	}})
+
+	if err := s.Submit(Job{ID: "other"}); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("expected the queue to be full, got %v", err)
+	}
+
+	if !s.Cancel("prefetch") || s.Cancel("prefetch") || s.Cancel("hold") {
+		t.Errorf("expected only the pending job to be canceled")
+	}
+
+	if err := s.Await(context.Background(), "prefetch"); !errors.Is(err, ErrCanceled) {
+		t.Errorf("expected the job to be canceled, got %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if err := s.Await(ctx, "hold"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the wait to end with the context, got %v", err)
+	}
+
+	if ran.Load() {
+		t.Errorf("expected the canceled job not to run")
+	}
+}
+
+func TestSchedulerRetain(t *testing.T) {
+	s := New(Config{Workers: 1, Retain: time.Minute})
+	defer s.Close(context.Background())
+
+	now := time.Now()
+	s.mutex.Lock()
+	s.now = func() time.Time { return now }
+	s.mutex.Unlock()
+
+	var runs atomic.Int32
+	job := Job{ID: "segment-1", Run: func(ctx context.Context) error {
+		runs.Add(1)
+		return nil
+	}}
+
+	s.Do(context.Background(), job)
+	s.mutex.Lock()
+	now = now.Add(2 * time.Minute)
+	s.mutex.Unlock()
+	s.Do(context.Background(), job)
+
+	if runs.Load() != 2 {
+		t.Errorf("expected the job to be forgotten after the retention, got %d runs", runs.Load())
+	}
+}
+
+func TestSchedulerClose(t *testing.T) {
+	s := New(Config{Workers: 1})
+
+	var runs atomic.Int32
+	for _, id := range []string{"a", "b", "c"} {
+		s.Submit(Job{ID: id, Run: func(ctx context.Context) error {
+			runs.Add(1)
+			return nil
+		}})
+	}
+
+	if err := s.Close(context.Background()); err != nil || runs.Load() != 3 {
+		t.Errorf("expected the pending jobs to run before closing, got %d runs and %v", runs.Load(), err)
+	}
+
+	if err := s.Submit(Job{ID: "d"}); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected the closed scheduler to reject jobs, got %v", err)
+	}
+
+	s = New(Config{Workers: 1})
+	s.Submit(Job{ID: "stuck", Run: func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if err := s.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the close to end with the context, got %v", err)
+	}
+
+	if err := s.Await(context.Background(), "stuck"); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the running job to be canceled, got %v", err)
+	}
+}
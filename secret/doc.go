@@ -0,0 +1,2 @@
+// Package secret provides the secrets of VRMix, like content keys, signing secrets and source credentials, from pluggable stores.
+package secret
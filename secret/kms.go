@@ -0,0 +1,134 @@
+package secret
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"vrmix/s3"
+)
+
+// maxErrorSize is the size of the error responses of KMS read to report their type and message.
+const maxErrorSize = 64 * 1024
+
+// KMS encrypts and decrypts with a key of AWS Key Management Service, implementing keyserver.KeyEncrypter to encrypt the content keys at rest.
+type KMS struct {
+	Endpoint     string       // Base URL of the service, defaults to "https://kms.{region}.amazonaws.com"
+	Region       string       // Region of the key, like "us-east-1"
+	KeyID        string       // ID, ARN or alias of the key, like "alias/vrmix"
+	AccessKey    string       // Access key ID
+	SecretKey    string       // Secret access key
+	SessionToken string       // Session token of temporary credentials, empty for none
+	HTTP         *http.Client // Client used for the requests, defaults to http.DefaultClient
+
+	now func() time.Time
+}
+
+// call calls the action of the service, decoding the JSON response into the output.
+func (k *KMS) call(ctx context.Context, action string, input any, output any) error {
+	endpoint := k.Endpoint
+	if endpoint == "" {
+		endpoint = "https://kms." + k.Region + ".amazonaws.com"
+	}
+
+	body, err := json.Marshal(input)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(endpoint, "/")+"/", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "TrentService."+action)
+
+	now := time.Now
+	if k.now != nil {
+		now = k.now
+	}
+
+	signer := s3.Signer{Service: "kms", Region: k.Region, AccessKey: k.AccessKey, SecretKey: k.SecretKey, SessionToken: k.SessionToken}
+	signer.Sign(req, s3.PayloadHash(body), now().UTC())
+
+	client := k.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		// The errors name their type after the namespace of the service, like "com.amazonaws.kms#NotFoundException".
+		var failure struct {
+			Type    string `json:"__type"`
+			Message string `json:"message"`
+		}
+		json.NewDecoder(io.LimitReader(resp.Body, maxErrorSize)).Decode(&failure)
+
+		_, code, _ := strings.Cut(failure.Type, "#")
+		if code == "" {
+			code = failure.Type
+		}
+
+		return &StatusError{StatusCode: resp.StatusCode, Code: code, Message: failure.Message}
+	}
+
+	return json.NewDecoder(resp.Body).Decode(output)
+}
+
+// Encrypt encrypts the plaintext with the key.
+func (k *KMS) Encrypt(ctx context.Context, plaintext []byte) ([]byte, error) {
+	var output struct {
+		CiphertextBlob []byte `json:"CiphertextBlob"`
+	}
+
+	if err := k.call(ctx, "Encrypt", map[string]any{"KeyId": k.KeyID, "Plaintext": plaintext}, &output); err != nil {
+		return nil, err
+	}
+
+	return output.CiphertextBlob, nil
+}
+
+// Decrypt decrypts a ciphertext returned by Encrypt.
+func (k *KMS) Decrypt(ctx context.Context, ciphertext []byte) ([]byte, error) {
+	var output struct {
+		Plaintext []byte `json:"Plaintext"`
+	}
+
+	if err := k.call(ctx, "Decrypt", map[string]any{"KeyId": k.KeyID, "CiphertextBlob": ciphertext}, &output); err != nil {
+		return nil, err
+	}
+
+	return output.Plaintext, nil
+}
+
+// KMSStore is a Store decrypting with KMS the secrets kept encrypted and base64 encoded in another store, so the configuration and the environment never hold the secrets in clear.
+type KMSStore struct {
+	Store Store // Store keeping the encrypted secrets
+	KMS   *KMS  // Service decrypting the secrets
+}
+
+// Get decrypts the secret with the name, ignoring the whitespace around its encoding, like the line break ending a file.
+func (s *KMSStore) Get(ctx context.Context, name string) ([]byte, error) {
+	encoded, err := s.Store.Get(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	ciphertext, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(encoded)))
+	if err != nil {
+		return nil, err
+	}
+
+	return s.KMS.Decrypt(ctx, ciphertext)
+}
@@ -0,0 +1,82 @@
+package secret
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"vrmix/keyserver"
+)
+
+// KMS encrypts the content keys of the key server at rest
+var _ keyserver.KeyEncrypter = (*KMS)(nil)
+
+func TestKMS(t *testing.T) {
+	service := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.Header.Get("Authorization"), "/20260101/eu-west-1/kms/aws4_request") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		if r.Header.Get("X-Amz-Target") == "TrentService.Decrypt" && strings.Contains(r.Header.Get("Authorization"), "Credential=revoked/") {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"__type":"com.amazonaws.kms#AccessDeniedException","message":"access denied to alias/vrmix"}`))
+			return
+		}
+
+		var input map[string][]byte
+		json.NewDecoder(r.Body).Decode(&input)
+
+		// The fake service reverses the bytes, so encrypting twice decrypts.
+		switch r.Header.Get("X-Amz-Target") {
+		case "TrentService.Encrypt":
+			json.NewEncoder(w).Encode(map[string][]byte{"CiphertextBlob": reverse(input["Plaintext"])})
+		case "TrentService.Decrypt":
+			json.NewEncoder(w).Encode(map[string][]byte{"Plaintext": reverse(input["CiphertextBlob"])})
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer service.Close()
+
+	kms := &KMS{Endpoint: service.URL, Region: "eu-west-1", KeyID: "alias/vrmix", AccessKey: "access", SecretKey: "secret", now: func() time.Time { return time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC) }}
+	ctx := context.Background()
+
+	ciphertext, err := kms.Encrypt(ctx, []byte("content key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bytes.Equal(ciphertext, []byte("content key")) {
+		t.Error("expected the key to be encrypted")
+	}
+
+	t.Setenv("VRMIX_SOURCE_PASSWORD", " "+base64.StdEncoding.EncodeToString(ciphertext)+"\n")
+	s := &KMSStore{Store: &EnvStore{Prefix: "VRMIX_"}, KMS: kms}
+	if secret, err := s.Get(ctx, "source-password"); err != nil || string(secret) != "content key" {
+		t.Errorf("expected the decrypted secret, got %q and %v", secret, err)
+	}
+
+	kms.AccessKey = "revoked"
+	var statusError *StatusError
+	if _, err := s.Get(ctx, "source-password"); !errors.As(err, &statusError) || statusError.Code != "AccessDeniedException" || !strings.Contains(err.Error(), "access denied to alias/vrmix") {
+		t.Errorf("expected the error reported by the service, got %v", err)
+	}
+}
+
+// reverse returns the bytes in reverse order
+func reverse(data []byte) []byte {
+	out := bytes.Clone(data)
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+
+	return out
+}
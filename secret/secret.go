@@ -0,0 +1,91 @@
+package secret
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	// ErrNotFound indicates that the store has no secret with the name.
+	ErrNotFound = errors.New("secret not found")
+
+	// ErrInvalidName indicates that a secret name cannot be used by the store, like a name with a path separator in a FileStore.
+	ErrInvalidName = errors.New("invalid secret name")
+)
+
+// RefPrefix is the prefix of the configuration values referencing a secret by name, like "secret:webhook-secret", replaced by Expand.
+const RefPrefix = "secret:"
+
+// Store provides the secrets by name.
+type Store interface {
+	// Get returns the secret with the name, or ErrNotFound.
+	Get(ctx context.Context, name string) ([]byte, error)
+}
+
+// FileStore is a Store reading each secret from its own file in a directory, like the secrets mounted by Docker or Kubernetes.
+type FileStore struct {
+	Dir string // Directory holding the secrets, like "/run/secrets"
+}
+
+// Get reads the file of the secret, without its trailing newline.
+func (s *FileStore) Get(ctx context.Context, name string) ([]byte, error) {
+	if name == "" || name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+		return nil, ErrInvalidName
+	}
+
+	data, err := os.ReadFile(filepath.Join(s.Dir, name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+
+	return bytes.TrimRight(data, "\r\n"), err
+}
+
+// EnvStore is a Store reading the secrets from the environment, the variable of "webhook-secret" being "WEBHOOK_SECRET" after the prefix.
+type EnvStore struct {
+	Prefix string // Prefix of the variables, like "VRMIX_"
+}
+
+// Get returns the variable of the secret.
+func (s *EnvStore) Get(ctx context.Context, name string) ([]byte, error) {
+	variable := strings.Map(func(r rune) rune {
+		if ('A' <= r && r <= 'Z') || ('0' <= r && r <= '9') {
+			return r
+		} else if 'a' <= r && r <= 'z' {
+			return r - 'a' + 'A'
+		}
+
+		return '_'
+	}, name)
+
+	value, ok := os.LookupEnv(s.Prefix + variable)
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return []byte(value), nil
+}
+
+// Expand replaces each value referencing a secret with RefPrefix by the secret, leaving the other values as is, so the configuration holds references instead of the secrets.
+func Expand(ctx context.Context, store Store, values ...*string) error {
+	for _, value := range values {
+		name, found := strings.CutPrefix(*value, RefPrefix)
+		if !found {
+			continue
+		}
+
+		secret, err := store.Get(ctx, name)
+		if err != nil {
+			return errors.Join(errors.New("secret "+name), err)
+		}
+
+		*value = string(secret)
+	}
+
+	return nil
+}
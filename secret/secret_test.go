@@ -0,0 +1,50 @@
+package secret
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileStore(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "webhook-secret"), []byte("hunter2\n"), 0o600)
+
+	s := &FileStore{Dir: dir}
+	ctx := context.Background()
+
+	if secret, err := s.Get(ctx, "webhook-secret"); err != nil || string(secret) != "hunter2" {
+		t.Errorf("expected the secret without its newline, got %q and %v", secret, err)
+	}
+
+	if _, err := s.Get(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	for _, name := range []string{"../etc/passwd", "..", ""} {
+		if _, err := s.Get(ctx, name); !errors.Is(err, ErrInvalidName) {
+			t.Errorf("expected %q to be rejected, got %v", name, err)
+		}
+	}
+}
+
+func TestEnvStoreExpand(t *testing.T) {
+	t.Setenv("VRMIX_SFTP_PASSWORD", "hunter2")
+	s := &EnvStore{Prefix: "VRMIX_"}
+
+	password, user := RefPrefix+"sftp-password", "vrmix"
+	if err := Expand(context.Background(), s, &password, &user); err != nil {
+		t.Fatal(err)
+	}
+
+	if password != "hunter2" || user != "vrmix" {
+		t.Errorf("expected only the reference to be expanded, got %q and %q", password, user)
+	}
+
+	missing := RefPrefix + "missing"
+	if err := Expand(context.Background(), s, &missing); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
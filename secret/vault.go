@@ -0,0 +1,158 @@
+package secret
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// StatusError records an unexpected status answered by a secret service.
+type StatusError struct {
+	StatusCode int    // Status code of the response
+	Code       string // Error code reported by the service, like "AccessDeniedException", empty if unknown
+	Message    string // Error message reported by the service, empty if unknown
+}
+
+func (e *StatusError) Error() string {
+	message := "unexpected secret service status " + strconv.Itoa(e.StatusCode)
+	if e.Code != "" {
+		message += ": " + e.Code
+	}
+
+	if e.Message != "" {
+		message += ": " + e.Message
+	}
+
+	return message
+}
+
+// VaultStore is a Store reading the secrets from the fields of a secret of the KV version 2 engine of HashiCorp Vault.
+type VaultStore struct {
+	Address string       // Address of Vault, like "https://vault.example:8200"
+	Token   string       // Token authenticating to Vault
+	Mount   string       // Mount path of the KV engine, defaults to "secret"
+	Path    string       // Path of the secret holding the fields, like "vrmix"
+	HTTP    *http.Client // Client used for the requests, defaults to http.DefaultClient
+}
+
+// do sends a request to Vault, decoding the JSON response into the output.
+func (s *VaultStore) do(ctx context.Context, method string, path string, body any, output any) error {
+	u, err := url.JoinPath(s.Address, "v1", path)
+	if err != nil {
+		return err
+	}
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Vault-Token", s.Token)
+
+	client := s.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return &StatusError{StatusCode: resp.StatusCode}
+	}
+
+	return json.NewDecoder(resp.Body).Decode(output)
+}
+
+// Get returns the field of the secret with the name.
+func (s *VaultStore) Get(ctx context.Context, name string) ([]byte, error) {
+	mount := s.Mount
+	if mount == "" {
+		mount = "secret"
+	}
+
+	var response struct {
+		Data struct {
+			Data map[string]string `json:"data"`
+		} `json:"data"`
+	}
+
+	if err := s.do(ctx, http.MethodGet, mount+"/data/"+s.Path, nil, &response); err != nil {
+		return nil, err
+	}
+
+	value, ok := response.Data.Data[name]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return []byte(value), nil
+}
+
+// VaultTransit encrypts the content keys at rest with a key of the transit engine of HashiCorp Vault, implementing keyserver.KeyEncrypter.
+type VaultTransit struct {
+	Vault *VaultStore // Connection to Vault, its mount being the mount of the transit engine, defaults to "transit"
+	Key   string      // Name of the transit key
+}
+
+// mount returns the mount path of the transit engine.
+func (t *VaultTransit) mount() string {
+	if t.Vault.Mount == "" {
+		return "transit"
+	}
+
+	return t.Vault.Mount
+}
+
+// Encrypt encrypts the plaintext with the transit key.
+func (t *VaultTransit) Encrypt(ctx context.Context, plaintext []byte) ([]byte, error) {
+	var response struct {
+		Data struct {
+			Ciphertext string `json:"ciphertext"`
+		} `json:"data"`
+	}
+
+	request := map[string]string{"plaintext": base64.StdEncoding.EncodeToString(plaintext)}
+	if err := t.Vault.do(ctx, http.MethodPost, t.mount()+"/encrypt/"+t.Key, request, &response); err != nil {
+		return nil, err
+	}
+
+	return []byte(response.Data.Ciphertext), nil
+}
+
+// Decrypt decrypts a ciphertext returned by Encrypt.
+func (t *VaultTransit) Decrypt(ctx context.Context, ciphertext []byte) ([]byte, error) {
+	var response struct {
+		Data struct {
+			Plaintext string `json:"plaintext"`
+		} `json:"data"`
+	}
+
+	request := map[string]string{"ciphertext": string(ciphertext)}
+	if err := t.Vault.do(ctx, http.MethodPost, t.mount()+"/decrypt/"+t.Key, request, &response); err != nil {
+		return nil, err
+	}
+
+	return base64.StdEncoding.DecodeString(response.Data.Plaintext)
+}
@@ -0,0 +1,60 @@
+package secret
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestVault(t *testing.T) {
+	vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		var request map[string]string
+		json.NewDecoder(r.Body).Decode(&request)
+
+		switch r.URL.Path {
+		case "/v1/secret/data/vrmix":
+			w.Write([]byte(`{"data":{"data":{"signing-secret":"hunter2"}}}`))
+		case "/v1/transit/encrypt/content":
+			json.NewEncoder(w).Encode(map[string]any{"data": map[string]string{"ciphertext": "vault:v1:" + request["plaintext"]}})
+		case "/v1/transit/decrypt/content":
+			json.NewEncoder(w).Encode(map[string]any{"data": map[string]string{"plaintext": request["ciphertext"][len("vault:v1:"):]}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer vault.Close()
+
+	s := &VaultStore{Address: vault.URL, Token: "token", Path: "vrmix"}
+	ctx := context.Background()
+
+	if secret, err := s.Get(ctx, "signing-secret"); err != nil || string(secret) != "hunter2" {
+		t.Errorf("expected the field of the secret, got %q and %v", secret, err)
+	}
+
+	if _, err := s.Get(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	var statusError *StatusError
+	if _, err := (&VaultStore{Address: vault.URL, Path: "vrmix"}).Get(ctx, "signing-secret"); !errors.As(err, &statusError) || statusError.StatusCode != http.StatusForbidden {
+		t.Errorf("expected status error 403, got %v", err)
+	}
+
+	transit := &VaultTransit{Vault: &VaultStore{Address: vault.URL, Token: "token"}, Key: "content"}
+	ciphertext, err := transit.Encrypt(ctx, []byte("content key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if plaintext, err := transit.Decrypt(ctx, ciphertext); err != nil || string(plaintext) != "content key" {
+		t.Errorf("expected the content key, got %q and %v", plaintext, err)
+	}
+}
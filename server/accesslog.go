@@ -0,0 +1,129 @@
+package server
+
+import (
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"time"
+
+	"vrmix/logging"
+	"vrmix/signer"
+)
+
+// RedactedValue is the value replacing redacted query parameters in access logs.
+const RedactedValue = "REDACTED"
+
+// AccessLogConfig represents the configuration of the access logging middleware.
+type AccessLogConfig struct {
+	Logger       *slog.Logger // Logger receiving the access logs, defaults to slog.Default
+	SampleRate   float64      // Fraction of successful requests logged, between 0 and 1, zero logging every request
+	RedactParams []string     // Query parameters redacted from the logged URL, defaults to the signed URL signature
+
+	// Session returns the session ID of the request, nil to omit it.
+	Session func(r *http.Request) string
+
+	// Channel returns the channel of the request, nil to omit it.
+	Channel func(r *http.Request) string
+}
+
+// statusRecorder records the status and the bytes written to a response.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(data []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+
+	n, err := r.ResponseWriter.Write(data)
+	r.bytes += int64(n)
+	return n, err
+}
+
+func (r *statusRecorder) Flush() {
+	http.NewResponseController(r.ResponseWriter).Flush()
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// redactURL returns the path and query of the URL with the parameters redacted.
+func redactURL(u *url.URL, params []string) string {
+	if u.RawQuery == "" {
+		return u.Path
+	}
+
+	query := u.Query()
+	for _, param := range params {
+		if query.Has(param) {
+			query.Set(param, RedactedValue)
+		}
+	}
+
+	return u.Path + "?" + query.Encode()
+}
+
+// AccessLog returns a middleware logging each request with its method, path, status, bytes, duration, session and channel, always logging failed requests regardless of the sampling.
+func AccessLog(config AccessLogConfig) Middleware {
+	config.Logger = logging.Or(config.Logger)
+
+	if config.RedactParams == nil {
+		config.RedactParams = []string{signer.SignatureParam}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			recorder := &statusRecorder{ResponseWriter: w}
+
+			next.ServeHTTP(recorder, r)
+
+			if recorder.status == 0 {
+				recorder.status = http.StatusOK
+			}
+
+			if recorder.status < 400 && config.SampleRate > 0 && config.SampleRate < 1 && rand.Float64() >= config.SampleRate {
+				return
+			}
+
+			attrs := []slog.Attr{
+				slog.String("method", r.Method),
+				slog.String("path", redactURL(r.URL, config.RedactParams)),
+				slog.Int("status", recorder.status),
+				slog.Int64("bytes", recorder.bytes),
+				slog.Duration("duration", time.Since(start)),
+				slog.String("remote", ClientIP(r)),
+			}
+
+			if config.Session != nil {
+				attrs = append(attrs, slog.String(logging.SessionKey, config.Session(r)))
+			}
+
+			if config.Channel != nil {
+				attrs = append(attrs, slog.String(logging.ChannelKey, config.Channel(r)))
+			}
+
+			level := slog.LevelInfo
+			if recorder.status >= 500 {
+				level = slog.LevelError
+			} else if recorder.status >= 400 {
+				level = slog.LevelWarn
+			}
+
+			config.Logger.LogAttrs(r.Context(), level, "request", attrs...)
+		})
+	}
+}
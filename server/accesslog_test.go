@@ -0,0 +1,74 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAccessLog(t *testing.T) {
+	var buffer bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buffer, nil))
+
+	handler := AccessLog(AccessLogConfig{
+		Logger:  logger,
+		Session: func(r *http.Request) string { return "s1" },
+		Channel: func(r *http.Request) string { return "main" },
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("segment"))
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/streams/main/0.ts?exp=1&sig=secret", nil))
+
+	var entry map[string]any
+	if err := json.Unmarshal(buffer.Bytes(), &entry); err != nil {
+		t.Fatal(err)
+	}
+
+	if entry["status"] != float64(http.StatusOK) {
+		t.Errorf("expected status 200, got %v", entry["status"])
+	}
+
+	if entry["bytes"] != float64(7) {
+		t.Errorf("expected 7 bytes, got %v", entry["bytes"])
+	}
+
+	if entry["session"] != "s1" || entry["channel"] != "main" {
+		t.Errorf("expected session s1 and channel main, got %v and %v", entry["session"], entry["channel"])
+	}
+
+	path, _ := entry["path"].(string)
+	if strings.Contains(path, "secret") || !strings.Contains(path, "sig="+RedactedValue) {
+		t.Errorf("expected signature to be redacted, got %s", path)
+	}
+}
+
+func TestAccessLogSampling(t *testing.T) {
+	var buffer bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buffer, nil))
+
+	handler := AccessLog(AccessLogConfig{Logger: logger, SampleRate: 0.0000001})(http.NotFoundHandler())
+
+	for i := 0; i < 3; i++ {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/missing.ts", nil))
+	}
+
+	if lines := strings.Count(buffer.String(), "\n"); lines != 3 {
+		t.Errorf("expected failed requests to always be logged, got %d lines", lines)
+	}
+
+	buffer.Reset()
+	handler = AccessLog(AccessLogConfig{Logger: logger, SampleRate: 0.0000001})(okHandler)
+
+	for i := 0; i < 3; i++ {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/0.ts", nil))
+	}
+
+	if buffer.Len() != 0 {
+		t.Errorf("expected successful requests to be sampled out, got %s", buffer.String())
+	}
+}
@@ -0,0 +1,202 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// PlaybackConfig represents the configuration of the playback analytics.
+type PlaybackConfig struct {
+	StallGap    time.Duration // Gap between playlist polls considered a likely stall, defaults to 12 seconds
+	IdleTimeout time.Duration // Time after which an idle session is discarded, defaults to one minute
+
+	// Session returns the session ID of the request.
+	Session func(r *http.Request) string
+
+	// Channel returns the channel of the request.
+	Channel func(r *http.Request) string
+
+	// SegmentDuration returns the duration in seconds of the requested segment, nil or zero when unknown.
+	SegmentDuration func(r *http.Request) float64
+}
+
+// playbackSession is the playback behavior inferred for a session.
+type playbackSession struct {
+	channel       string
+	firstRequest  time.Time
+	firstSegment  time.Time
+	lastPlaylist  time.Time
+	lastSeen      time.Time
+	stalls        int
+	bytes         int64
+	mediaDuration float64
+}
+
+// ChannelQoE represents the quality of experience inferred for the sessions of a channel.
+type ChannelQoE struct {
+	Sessions     int     `json:"sessions"`      // Sessions tracked
+	StartupDelay float64 `json:"startup_delay"` // Average seconds between the first playlist and the first segment request
+	StallRate    float64 `json:"stall_rate"`    // Fraction of sessions with at least one likely stall
+	Stalls       int     `json:"stalls"`        // Likely stalls detected across the sessions
+	Bitrate      float64 `json:"bitrate"`       // Average bits per second of media delivered, zero if unknown
+}
+
+// PlaybackAnalytics infers the playback behavior of each session from its request pattern, since headsets rarely report it themselves.
+type PlaybackAnalytics struct {
+	config   PlaybackConfig
+	mutex    sync.Mutex
+	sessions map[string]*playbackSession
+	now      func() time.Time
+}
+
+// NewPlaybackAnalytics creates a new PlaybackAnalytics with the specified configuration.
+func NewPlaybackAnalytics(config PlaybackConfig) *PlaybackAnalytics {
+	if config.StallGap <= 0 {
+		config.StallGap = 12 * time.Second
+	}
+
+	if config.IdleTimeout <= 0 {
+		config.IdleTimeout = time.Minute
+	}
+
+	return &PlaybackAnalytics{config: config, sessions: make(map[string]*playbackSession), now: time.Now}
+}
+
+// session returns the state of the session, creating it if needed.
+func (a *PlaybackAnalytics) session(id string, channel string, now time.Time) *playbackSession {
+	s, ok := a.sessions[id]
+	if !ok || now.Sub(s.lastSeen) > a.config.IdleTimeout {
+		s = &playbackSession{channel: channel, firstRequest: now}
+		a.sessions[id] = s
+	}
+
+	s.lastSeen = now
+	return s
+}
+
+// RecordPlaylist records a playlist request of the session, detecting polling gaps long enough to indicate a stall.
+func (a *PlaybackAnalytics) RecordPlaylist(session string, channel string) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	now := a.now()
+	s := a.session(session, channel, now)
+
+	if !s.lastPlaylist.IsZero() && now.Sub(s.lastPlaylist) > a.config.StallGap {
+		s.stalls += 1
+	}
+
+	s.lastPlaylist = now
+}
+
+// RecordSegment records a segment request of the session with the bytes delivered and the media duration in seconds, zero if unknown.
+func (a *PlaybackAnalytics) RecordSegment(session string, channel string, bytes int64, duration float64) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	now := a.now()
+	s := a.session(session, channel, now)
+
+	if s.firstSegment.IsZero() {
+		s.firstSegment = now
+	}
+
+	if duration > 0 {
+		s.bytes += bytes
+		s.mediaDuration += duration
+	}
+}
+
+// Channels returns the quality of experience of every channel, discarding idle sessions.
+func (a *PlaybackAnalytics) Channels() map[string]ChannelQoE {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	now := a.now()
+	channels := map[string]ChannelQoE{}
+	startups := map[string]int{}
+	stalled := map[string]int{}
+	bytes := map[string]int64{}
+	durations := map[string]float64{}
+
+	for id, s := range a.sessions {
+		if now.Sub(s.lastSeen) > a.config.IdleTimeout {
+			delete(a.sessions, id)
+			continue
+		}
+
+		qoe := channels[s.channel]
+		qoe.Sessions += 1
+		qoe.Stalls += s.stalls
+
+		if !s.firstSegment.IsZero() {
+			qoe.StartupDelay += s.firstSegment.Sub(s.firstRequest).Seconds()
+			startups[s.channel] += 1
+		}
+
+		if s.stalls > 0 {
+			stalled[s.channel] += 1
+		}
+
+		bytes[s.channel] += s.bytes
+		durations[s.channel] += s.mediaDuration
+		channels[s.channel] = qoe
+	}
+
+	for channel, qoe := range channels {
+		if startups[channel] > 0 {
+			qoe.StartupDelay /= float64(startups[channel])
+		}
+
+		qoe.StallRate = float64(stalled[channel]) / float64(qoe.Sessions)
+
+		if durations[channel] > 0 {
+			qoe.Bitrate = float64(bytes[channel]*8) / durations[channel]
+		}
+
+		channels[channel] = qoe
+	}
+
+	return channels
+}
+
+// Middleware returns a middleware recording the playlist and segment requests.
+func (a *PlaybackAnalytics) Middleware() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			recorder := &statusRecorder{ResponseWriter: w}
+			next.ServeHTTP(recorder, r)
+
+			if recorder.status >= 400 || a.config.Session == nil || a.config.Channel == nil {
+				return
+			}
+
+			session := a.config.Session(r)
+			if session == "" {
+				return
+			}
+
+			channel := a.config.Channel(r)
+			if isPlaylistRequest(r) {
+				a.RecordPlaylist(session, channel)
+				return
+			}
+
+			var duration float64
+			if a.config.SegmentDuration != nil {
+				duration = a.config.SegmentDuration(r)
+			}
+
+			a.RecordSegment(session, channel, recorder.bytes, duration)
+		})
+	}
+}
+
+// ServeHTTP writes the quality of experience of every channel as JSON.
+func (a *PlaybackAnalytics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(a.Channels())
+}
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPlaybackAnalytics(t *testing.T) {
+	now := time.Unix(1000, 0)
+	a := NewPlaybackAnalytics(PlaybackConfig{StallGap: 10 * time.Second})
+	a.now = func() time.Time { return now }
+
+	a.RecordPlaylist("s1", "main")
+	now = now.Add(2 * time.Second)
+	a.RecordSegment("s1", "main", 1000, 4)
+	now = now.Add(20 * time.Second)
+	a.RecordPlaylist("s1", "main")
+
+	a.RecordPlaylist("s2", "main")
+	now = now.Add(time.Second)
+	a.RecordSegment("s2", "main", 3000, 4)
+
+	qoe := a.Channels()["main"]
+
+	if qoe.Sessions != 2 {
+		t.Errorf("expected 2 sessions, got %d", qoe.Sessions)
+	}
+
+	if qoe.StartupDelay != 1.5 {
+		t.Errorf("expected startup delay 1.5, got %f", qoe.StartupDelay)
+	}
+
+	if qoe.Stalls != 1 || qoe.StallRate != 0.5 {
+		t.Errorf("expected 1 stall and stall rate 0.5, got %d and %f", qoe.Stalls, qoe.StallRate)
+	}
+
+	if qoe.Bitrate != 4000 {
+		t.Errorf("expected bitrate 4000, got %f", qoe.Bitrate)
+	}
+
+	now = now.Add(2 * time.Minute)
+
+	if channels := a.Channels(); len(channels) != 0 {
+		t.Errorf("expected idle sessions to be discarded, got %v", channels)
+	}
+}
+
+func TestPlaybackAnalyticsMiddleware(t *testing.T) {
+	a := NewPlaybackAnalytics(PlaybackConfig{
+		Session:         func(r *http.Request) string { return r.URL.Query().Get("session") },
+		Channel:         func(r *http.Request) string { return "main" },
+		SegmentDuration: func(r *http.Request) float64 { return 1 },
+	})
+
+	handler := a.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("0123456789"))
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/streams/main/playlist.m3u8?session=s1", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/streams/main/0.ts?session=s1", nil))
+
+	qoe := a.Channels()["main"]
+	if qoe.Sessions != 1 || qoe.Bitrate != 80 {
+		t.Errorf("expected 1 session at 80 bps, got %d at %f", qoe.Sessions, qoe.Bitrate)
+	}
+}
@@ -0,0 +1,110 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"vrmix/report"
+)
+
+// call is an in-flight fetch shared by every request for the same key.
+type call[T any] struct {
+	done  chan struct{}
+	value T
+	err   error
+}
+
+// Coalescer merges concurrent fetches for the same key into a single call, so a thundering herd results in exactly one origin request.
+type Coalescer[T any] struct {
+	mutex sync.Mutex
+	calls map[string]*call[T]
+}
+
+// Do calls fetch for the key unless a call for the same key is in-flight, in which case it waits for its result, returning true when the result was shared.
+//
+// The fetch runs detached from the cancellation of the caller context so canceling one request does not fail the others waiting for the same key.
+func (c *Coalescer[T]) Do(ctx context.Context, key string, fetch func(ctx context.Context) (T, error)) (T, bool, error) {
+	c.mutex.Lock()
+	if c.calls == nil {
+		c.calls = make(map[string]*call[T])
+	}
+
+	if existing, ok := c.calls[key]; ok {
+		c.mutex.Unlock()
+
+		_, span := startSpan(ctx, "coalesced wait", attribute.String("vrmix.coalesce.key", key))
+		defer span.End()
+		return c.wait(ctx, existing, true)
+	}
+
+	current := &call[T]{done: make(chan struct{})}
+	c.calls[key] = current
+	c.mutex.Unlock()
+
+	go func() {
+		// A panicking fetch is stored as a report.PanicError, so the waiters are released and the key can be fetched again.
+		defer func() {
+			if value := recover(); value != nil {
+				current.err = &report.PanicError{Value: value}
+			}
+
+			c.mutex.Lock()
+			delete(c.calls, key)
+			c.mutex.Unlock()
+
+			close(current.done)
+		}()
+
+		current.value, current.err = fetch(context.WithoutCancel(ctx))
+	}()
+
+	return c.wait(ctx, current, false)
+}
+
+// wait waits for the call result or for the context to be done.
+func (c *Coalescer[T]) wait(ctx context.Context, current *call[T], shared bool) (T, bool, error) {
+	select {
+	case <-current.done:
+		return current.value, shared, current.err
+	case <-ctx.Done():
+		var zero T
+		return zero, shared, ctx.Err()
+	}
+}
+
+// ReadThrough serves segments from the cache, fetching missing segments from the origin through a Coalescer.
+type ReadThrough struct {
+	// Key returns the cache key of the segment requested.
+	Key func(r *http.Request) string
+
+	// Lookup returns the cached segment with the key, if any.
+	Lookup func(key string) (SegmentContent, bool)
+
+	// Fetch downloads the segment with the key from the origin and stores it in the cache.
+	Fetch func(ctx context.Context, key string) (SegmentContent, error)
+
+	coalescer Coalescer[SegmentContent]
+}
+
+// ServeHTTP serves the requested segment, answering 502 Bad Gateway when the origin fetch fails.
+func (rt *ReadThrough) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key := rt.Key(r)
+
+	segment, ok := lookupSpan(r.Context(), key, func() (SegmentContent, bool) { return rt.Lookup(key) })
+	if !ok {
+		var err error
+		segment, _, err = rt.coalescer.Do(r.Context(), key, func(ctx context.Context) (SegmentContent, error) {
+			return rt.Fetch(ctx, key)
+		})
+
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+			return
+		}
+	}
+
+	ServeSegment(w, r, key, segment.ModTime, segment.Content, segment.Size)
+}
@@ -0,0 +1,145 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"vrmix/report"
+)
+
+func TestCoalescer(t *testing.T) {
+	var c Coalescer[int]
+	var calls atomic.Int32
+	release := make(chan struct{})
+
+	var wg sync.WaitGroup
+	results := make([]int, 10)
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			value, _, err := c.Do(context.Background(), "0.ts", func(ctx context.Context) (int, error) {
+				calls.Add(1)
+				<-release
+				return 42, nil
+			})
+			if err != nil {
+				t.Error(err)
+			}
+
+			results[i] = value
+		}()
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if calls.Load() != 1 {
+		t.Errorf("expected 1 fetch, got %d", calls.Load())
+	}
+
+	for i, value := range results {
+		if value != 42 {
+			t.Errorf("expected result 42 on request %d, got %d", i, value)
+		}
+	}
+}
+
+func TestCoalescerCanceled(t *testing.T) {
+	var c Coalescer[int]
+	release := make(chan struct{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, _, err := c.Do(ctx, "0.ts", func(ctx context.Context) (int, error) {
+		<-release
+		return 42, ctx.Err()
+	}); err == nil {
+		t.Errorf("expected canceled request to fail")
+	}
+
+	done := make(chan int)
+	go func() {
+		value, shared, _ := c.Do(context.Background(), "0.ts", func(ctx context.Context) (int, error) {
+			return 0, nil
+		})
+		if !shared {
+			t.Errorf("expected result to be shared")
+		}
+		done <- value
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+
+	if value := <-done; value != 42 {
+		t.Errorf("expected detached fetch result 42, got %d", value)
+	}
+}
+
+func TestCoalescerPanic(t *testing.T) {
+	var c Coalescer[int]
+	_, _, err := c.Do(context.Background(), "0.ts", func(ctx context.Context) (int, error) {
+		panic("corrupt segment")
+	})
+
+	var panicked *report.PanicError
+	if !errors.As(err, &panicked) || panicked.Value != "corrupt segment" {
+		t.Errorf("expected the panic as an error, got %v", err)
+	}
+
+	if value, shared, err := c.Do(context.Background(), "0.ts", func(ctx context.Context) (int, error) { return 42, nil }); value != 42 || shared || err != nil {
+		t.Errorf("expected the key to be fetched again, got %d, %v and %v", value, shared, err)
+	}
+}
+
+func TestReadThrough(t *testing.T) {
+	var fetches atomic.Int32
+	var mutex sync.Mutex
+	cached := map[string]SegmentContent{}
+
+	rt := &ReadThrough{
+		Key: func(r *http.Request) string { return r.URL.Path },
+		Lookup: func(key string) (SegmentContent, bool) {
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			segment, ok := cached[key]
+			return segment, ok
+		},
+		Fetch: func(ctx context.Context, key string) (SegmentContent, error) {
+			fetches.Add(1)
+			content := strings.NewReader("segment")
+			segment := SegmentContent{Content: content, Size: content.Size()}
+
+			mutex.Lock()
+			cached[key] = segment
+			mutex.Unlock()
+
+			return segment, nil
+		},
+	}
+
+	for i := 0; i < 3; i++ {
+		w := httptest.NewRecorder()
+		rt.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/0.ts", nil))
+
+		if w.Body.String() != "segment" {
+			t.Errorf("expected body segment, got %s", w.Body.String())
+		}
+	}
+
+	if fetches.Load() != 1 {
+		t.Errorf("expected 1 fetch, got %d", fetches.Load())
+	}
+}
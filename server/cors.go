@@ -0,0 +1,98 @@
+package server
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// CORSConfig represents the Cross-Origin Resource Sharing policy of a deployment.
+type CORSConfig struct {
+	AllowedOrigins   []string // Origins allowed to access the resources, "*" allows any origin
+	AllowedMethods   []string // Methods allowed on preflight requests, defaults to GET, HEAD and OPTIONS
+	AllowedHeaders   []string // Request headers allowed on preflight requests, defaults to Range
+	ExposedHeaders   []string // Response headers exposed to the browser, defaults to the headers used by players
+	AllowCredentials bool     // Indicates if credentials are allowed on cross-origin requests from the listed origins, never from the ones only allowed by "*"
+	MaxAge           int      // Seconds a preflight response can be cached, zero to omit
+}
+
+// DefaultCORSConfig returns a policy allowing any origin to play the streams.
+func DefaultCORSConfig() CORSConfig {
+	return CORSConfig{AllowedOrigins: []string{"*"}}
+}
+
+// isOriginAllowed returns true if the origin is in the allow-list.
+func (c *CORSConfig) isOriginAllowed(origin string) bool {
+	return slices.Contains(c.AllowedOrigins, "*") || slices.Contains(c.AllowedOrigins, origin)
+}
+
+// CORS returns a middleware applying the policy to the responses and answering preflight requests.
+func CORS(config CORSConfig) Middleware {
+	methods := config.AllowedMethods
+	if len(methods) == 0 {
+		methods = []string{http.MethodGet, http.MethodHead, http.MethodOptions}
+	}
+
+	headers := config.AllowedHeaders
+	if len(headers) == 0 {
+		headers = []string{"Range"}
+	}
+
+	exposed := config.ExposedHeaders
+	if len(exposed) == 0 {
+		exposed = []string{"Content-Length", "Content-Range", "Accept-Ranges", "ETag"}
+	}
+
+	allowMethods := strings.Join(methods, ", ")
+	allowHeaders := strings.Join(headers, ", ")
+	exposeHeaders := strings.Join(exposed, ", ")
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			if origin == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			header := w.Header()
+			header.Add("Vary", "Origin")
+
+			if !config.isOriginAllowed(origin) {
+				if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+					w.WriteHeader(http.StatusForbidden)
+					return
+				}
+
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// An origin only allowed by "*" gets a literal "*" without credentials, so credentials are never sent to any origin.
+			if slices.Contains(config.AllowedOrigins, origin) {
+				header.Set("Access-Control-Allow-Origin", origin)
+				if config.AllowCredentials {
+					header.Set("Access-Control-Allow-Credentials", "true")
+				}
+			} else {
+				header.Set("Access-Control-Allow-Origin", "*")
+			}
+
+			if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+				header.Set("Access-Control-Allow-Methods", allowMethods)
+				header.Set("Access-Control-Allow-Headers", allowHeaders)
+
+				if config.MaxAge > 0 {
+					header.Set("Access-Control-Max-Age", strconv.Itoa(config.MaxAge))
+				}
+
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+
+			header.Set("Access-Control-Expose-Headers", exposeHeaders)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// okHandler is a handler that always answers with 200 OK
+var okHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+})
+
+func TestCORSAllowed(t *testing.T) {
+	handler := CORS(CORSConfig{AllowedOrigins: []string{"https://player.example"}, MaxAge: 60})(okHandler)
+
+	r := httptest.NewRequest(http.MethodGet, "/streams/main/playlist.m3u8", nil)
+	r.Header.Set("Origin", "https://player.example")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	if origin := w.Header().Get("Access-Control-Allow-Origin"); origin != "https://player.example" {
+		t.Errorf("expected allowed origin https://player.example, got %s", origin)
+	}
+
+	if w.Header().Get("Access-Control-Expose-Headers") == "" {
+		t.Errorf("expected exposed headers to be set")
+	}
+
+	r = httptest.NewRequest(http.MethodOptions, "/streams/main/playlist.m3u8", nil)
+	r.Header.Set("Origin", "https://player.example")
+	r.Header.Set("Access-Control-Request-Method", http.MethodGet)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	if w.Code != http.StatusNoContent {
+		t.Errorf("expected status %d, got %d", http.StatusNoContent, w.Code)
+	}
+
+	if maxAge := w.Header().Get("Access-Control-Max-Age"); maxAge != "60" {
+		t.Errorf("expected max age 60, got %s", maxAge)
+	}
+}
+
+func TestCORSDenied(t *testing.T) {
+	handler := CORS(CORSConfig{AllowedOrigins: []string{"https://player.example"}})(okHandler)
+
+	r := httptest.NewRequest(http.MethodGet, "/streams/main/playlist.m3u8", nil)
+	r.Header.Set("Origin", "https://evil.example")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	if origin := w.Header().Get("Access-Control-Allow-Origin"); origin != "" {
+		t.Errorf("expected no allowed origin, got %s", origin)
+	}
+
+	r = httptest.NewRequest(http.MethodOptions, "/streams/main/playlist.m3u8", nil)
+	r.Header.Set("Origin", "https://evil.example")
+	r.Header.Set("Access-Control-Request-Method", http.MethodGet)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected status %d, got %d", http.StatusForbidden, w.Code)
+	}
+}
+
+func TestCORSWildcard(t *testing.T) {
+	handler := CORS(DefaultCORSConfig())(okHandler)
+
+	r := httptest.NewRequest(http.MethodGet, "/streams/main/0.ts", nil)
+	r.Header.Set("Origin", "https://any.example")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	if origin := w.Header().Get("Access-Control-Allow-Origin"); origin != "*" {
+		t.Errorf("expected allowed origin *, got %s", origin)
+	}
+}
+
+func TestCORSWildcardCredentials(t *testing.T) {
+	handler := CORS(CORSConfig{AllowedOrigins: []string{"*", "https://player.example"}, AllowCredentials: true})(okHandler)
+
+	for origin, expected := range map[string]string{"https://any.example": "*", "https://player.example": "https://player.example"} {
+		r := httptest.NewRequest(http.MethodGet, "/streams/main/0.ts", nil)
+		r.Header.Set("Origin", origin)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		if allowed := w.Header().Get("Access-Control-Allow-Origin"); allowed != expected {
+			t.Errorf("expected allowed origin %s for %s, got %s", expected, origin, allowed)
+		}
+
+		if credentials := w.Header().Get("Access-Control-Allow-Credentials"); (credentials == "true") != (expected == origin) {
+			t.Errorf("expected credentials only for the listed origin, got %q for %s", credentials, origin)
+		}
+	}
+}
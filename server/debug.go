@@ -0,0 +1,147 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"time"
+
+	"vrmix/control"
+)
+
+// RuntimeStats represents the state of the Go runtime.
+type RuntimeStats struct {
+	GoVersion  string `json:"go_version"`  // Version of Go the binary was built with
+	Goroutines int    `json:"goroutines"`  // Goroutines running
+	HeapAlloc  uint64 `json:"heap_alloc"`  // Bytes of allocated heap objects
+	HeapSys    uint64 `json:"heap_sys"`    // Bytes of heap memory obtained from the system
+	NumGC      uint32 `json:"num_gc"`      // Completed garbage collection cycles
+	PauseTotal string `json:"pause_total"` // Total time spent in garbage collection pauses
+}
+
+// StateDump represents the state of every subsystem, serialized for support diagnostics.
+type StateDump struct {
+	Time     time.Time                      `json:"time"`               // Time the dump was taken
+	Runtime  RuntimeStats                   `json:"runtime"`            // State of the Go runtime
+	Channels []control.Channel              `json:"channels,omitempty"` // Every channel
+	Queues   map[string][]control.QueueItem `json:"queues,omitempty"`   // Queue of each channel by ID
+	Sessions []control.Session              `json:"sessions,omitempty"` // Every session
+	Stats    *Stats                         `json:"stats,omitempty"`    // Cache, scheduler and bandwidth statistics
+	Sections map[string]any                 `json:"sections,omitempty"` // Extra state by name, like the scheduler queues
+	Errors   map[string]string              `json:"errors,omitempty"`   // Parts that could not be dumped, with the reason
+}
+
+// Debug dumps the state of the subsystems for support diagnostics, every subsystem being optional.
+type Debug struct {
+	Service control.Service // Service listing the channels, queues and sessions
+	Stats   *StatsCollector // Collector of the cache, scheduler and bandwidth statistics
+	Pprof   bool            // Indicates if the pprof profiles are served under /debug/pprof/
+
+	// Sections returns extra state by name, like the scheduler queues.
+	Sections map[string]func(ctx context.Context) any
+
+	// Authorize checks the credentials of a request, nil rejects every request.
+	Authorize func(r *http.Request) bool
+}
+
+// runtimeStats returns the state of the Go runtime.
+func runtimeStats() RuntimeStats {
+	var memory runtime.MemStats
+	runtime.ReadMemStats(&memory)
+
+	return RuntimeStats{
+		GoVersion:  runtime.Version(),
+		Goroutines: runtime.NumGoroutine(),
+		HeapAlloc:  memory.HeapAlloc,
+		HeapSys:    memory.HeapSys,
+		NumGC:      memory.NumGC,
+		PauseTotal: time.Duration(memory.PauseTotalNs).String(),
+	}
+}
+
+// DumpState returns the state of every subsystem, recording the parts that failed instead of failing the whole dump.
+func (d *Debug) DumpState(ctx context.Context) StateDump {
+	dump := StateDump{Time: time.Now(), Runtime: runtimeStats(), Errors: map[string]string{}}
+
+	if d.Service != nil {
+		d.dumpService(ctx, &dump)
+	}
+
+	if d.Stats != nil {
+		stats := d.Stats.Collect()
+		dump.Stats = &stats
+	}
+
+	if len(d.Sections) > 0 {
+		dump.Sections = make(map[string]any, len(d.Sections))
+		for name, section := range d.Sections {
+			dump.Sections[name] = section(ctx)
+		}
+	}
+
+	if len(dump.Errors) == 0 {
+		dump.Errors = nil
+	}
+
+	return dump
+}
+
+// dumpService adds the channels, queues and sessions of the service to the dump.
+func (d *Debug) dumpService(ctx context.Context, dump *StateDump) {
+	channels, err := d.Service.ListChannels(ctx)
+	if err != nil {
+		dump.Errors["channels"] = err.Error()
+	}
+	dump.Channels = channels
+
+	dump.Queues = make(map[string][]control.QueueItem, len(channels))
+	for _, channel := range channels {
+		queue, err := d.Service.ListQueue(ctx, channel.ID)
+		if err != nil {
+			dump.Errors["queue:"+channel.ID] = err.Error()
+			continue
+		}
+
+		dump.Queues[channel.ID] = queue
+	}
+
+	if dump.Sessions, err = d.Service.ListSessions(ctx, ""); err != nil {
+		dump.Errors["sessions"] = err.Error()
+	}
+}
+
+// ServeHTTP checks the credentials, writing the state dump as JSON on /debug and the pprof profiles under /debug/pprof/ when enabled, so the handler is mounted on /debug without stripping the prefix.
+func (d *Debug) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if d.Authorize == nil || !d.Authorize(r) {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	switch r.URL.Path {
+	case "/debug", "/debug/":
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		json.NewEncoder(w).Encode(d.DumpState(r.Context()))
+		return
+	}
+
+	if !d.Pprof {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch r.URL.Path {
+	case "/debug/pprof/cmdline":
+		pprof.Cmdline(w, r)
+	case "/debug/pprof/profile":
+		pprof.Profile(w, r)
+	case "/debug/pprof/symbol":
+		pprof.Symbol(w, r)
+	case "/debug/pprof/trace":
+		pprof.Trace(w, r)
+	default:
+		pprof.Index(w, r)
+	}
+}
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"vrmix/control"
+)
+
+// debugService is a control.Service with a single channel whose sessions cannot be listed
+type debugService struct {
+	control.Service
+}
+
+func (debugService) ListChannels(ctx context.Context) ([]control.Channel, error) {
+	return []control.Channel{{ID: "main", State: control.ChannelPlaying}}, nil
+}
+
+func (debugService) ListQueue(ctx context.Context, channel string) ([]control.QueueItem, error) {
+	return []control.QueueItem{{ID: "1", Source: "https://origin.example/a.m3u8"}}, nil
+}
+
+func (debugService) ListSessions(ctx context.Context, channel string) ([]control.Session, error) {
+	return nil, errors.New("sessions unavailable")
+}
+
+func TestDebug(t *testing.T) {
+	d := &Debug{
+		Service:   debugService{},
+		Stats:     NewStatsCollector(),
+		Sections:  map[string]func(ctx context.Context) any{"scheduler": func(ctx context.Context) any { return []string{"job-1"} }},
+		Authorize: func(r *http.Request) bool { return r.Header.Get("Authorization") == "Bearer secret" },
+	}
+
+	w := httptest.NewRecorder()
+	d.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/debug", nil))
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected status 401 without credentials, got %d", w.Code)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/debug", nil)
+	r.Header.Set("Authorization", "Bearer secret")
+	w = httptest.NewRecorder()
+	d.ServeHTTP(w, r)
+
+	var dump StateDump
+	if err := json.NewDecoder(w.Body).Decode(&dump); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(dump.Channels) != 1 || len(dump.Queues["main"]) != 1 || dump.Stats == nil || dump.Runtime.Goroutines == 0 {
+		t.Errorf("expected the channel, its queue, the stats and the runtime, got %+v", dump)
+	}
+
+	if dump.Errors["sessions"] != "sessions unavailable" || dump.Sections["scheduler"] == nil {
+		t.Errorf("expected the session error and the scheduler section, got %v and %v", dump.Errors, dump.Sections)
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil)
+	r.Header.Set("Authorization", "Bearer secret")
+	w = httptest.NewRecorder()
+	d.ServeHTTP(w, r)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected pprof to be disabled, got status %d", w.Code)
+	}
+
+	d.Pprof = true
+	w = httptest.NewRecorder()
+	d.ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected the pprof index, got status %d", w.Code)
+	}
+}
@@ -0,0 +1,2 @@
+// Package server contains the HTTP handlers and middlewares used to deliver playlists and segments to players.
+package server
@@ -0,0 +1,180 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// Check reports the status of a subsystem, returning nil when it is healthy.
+type Check func(ctx context.Context) error
+
+// CheckStatus represents the result of a check.
+type CheckStatus struct {
+	Healthy  bool   `json:"healthy"`         // Indicates if the check passed
+	Error    string `json:"error,omitempty"` // Reason of the failure
+	Duration string `json:"duration"`        // Time spent running the check
+}
+
+// HealthReport represents the status of all subsystems.
+type HealthReport struct {
+	Status string                 `json:"status"` // "ok" when every check passed, "degraded" otherwise
+	Checks map[string]CheckStatus `json:"checks"` // Status of each check by name
+}
+
+// namedCheck is a check registered with its name.
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// Health runs the registered checks for the health and readiness endpoints.
+type Health struct {
+	mutex   sync.RWMutex
+	checks  []namedCheck
+	timeout time.Duration
+}
+
+// NewHealth creates a new Health running each check with the specified timeout, defaulting to 5 seconds when not positive.
+func NewHealth(timeout time.Duration) *Health {
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+
+	return &Health{timeout: timeout}
+}
+
+// AddCheck registers a check with the specified name, like "cache", "ffmpeg" or "origin:main".
+func (h *Health) AddCheck(name string, check Check) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.checks = append(h.checks, namedCheck{name: name, check: check})
+}
+
+// Report runs every check concurrently and returns their status.
+func (h *Health) Report(ctx context.Context) HealthReport {
+	h.mutex.RLock()
+	checks := h.checks
+	h.mutex.RUnlock()
+
+	report := HealthReport{Status: "ok", Checks: make(map[string]CheckStatus, len(checks))}
+
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	for _, c := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			checkCtx, cancel := context.WithTimeout(ctx, h.timeout)
+			defer cancel()
+
+			start := time.Now()
+			err := c.check(checkCtx)
+			status := CheckStatus{Healthy: err == nil, Duration: time.Since(start).String()}
+			if err != nil {
+				status.Error = err.Error()
+			}
+
+			mutex.Lock()
+			report.Checks[c.name] = status
+			if err != nil {
+				report.Status = "degraded"
+			}
+			mutex.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	return report
+}
+
+// writeReport writes the report as JSON with the specified status code.
+func writeReport(w http.ResponseWriter, r *http.Request, code int, report HealthReport) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+
+	if r.Method != http.MethodHead {
+		json.NewEncoder(w).Encode(report)
+	}
+}
+
+// LivenessHandler returns a handler for /healthz, reporting every check but always answering 200 OK while the process is serving requests.
+func (h *Health) LivenessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeReport(w, r, http.StatusOK, h.Report(r.Context()))
+	})
+}
+
+// ReadinessHandler returns a handler for /readyz, answering 503 Service Unavailable when any check fails.
+func (h *Health) ReadinessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := h.Report(r.Context())
+
+		code := http.StatusOK
+		if report.Status != "ok" {
+			code = http.StatusServiceUnavailable
+		}
+
+		writeReport(w, r, code, report)
+	})
+}
+
+// Register mounts the liveness and readiness handlers on /healthz and /readyz.
+func (h *Health) Register(mux *http.ServeMux) {
+	mux.Handle("/healthz", h.LivenessHandler())
+	mux.Handle("/readyz", h.ReadinessHandler())
+}
+
+// DirWritableCheck returns a check verifying that a file can be created in the directory, like the cache directory.
+func DirWritableCheck(dir string) Check {
+	return func(ctx context.Context) error {
+		file, err := os.CreateTemp(dir, ".healthcheck-*")
+		if err != nil {
+			return err
+		}
+
+		file.Close()
+		return os.Remove(file.Name())
+	}
+}
+
+// CommandAvailableCheck returns a check verifying that the command, like ffmpeg, can be found in the PATH.
+func CommandAvailableCheck(name string) Check {
+	return func(ctx context.Context) error {
+		_, err := exec.LookPath(name)
+		return err
+	}
+}
+
+// ErrOriginUnhealthy indicates that the origin answered with a server error.
+var ErrOriginUnhealthy = errors.New("origin answered with a server error")
+
+// OriginReachableCheck returns a check verifying that the origin URL of a channel answers without a server error.
+func OriginReachableCheck(client *http.Client, url string) Check {
+	return func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+		if err != nil {
+			return err
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode >= 500 {
+			return ErrOriginUnhealthy
+		}
+
+		return nil
+	}
+}
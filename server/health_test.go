@@ -0,0 +1,80 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// getReport sends a request to the handler and decodes the report
+func getReport(t *testing.T, handler http.Handler) (int, HealthReport) {
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	var report HealthReport
+	if err := json.NewDecoder(w.Body).Decode(&report); err != nil {
+		t.Fatal(err)
+	}
+
+	return w.Code, report
+}
+
+func TestHealth(t *testing.T) {
+	h := NewHealth(time.Second)
+	h.AddCheck("cache", DirWritableCheck(t.TempDir()))
+
+	code, report := getReport(t, h.ReadinessHandler())
+	if code != http.StatusOK || report.Status != "ok" {
+		t.Errorf("expected ready, got %d and %s", code, report.Status)
+	}
+
+	h.AddCheck("scheduler", func(ctx context.Context) error { return errors.New("stopped") })
+
+	code, report = getReport(t, h.ReadinessHandler())
+	if code != http.StatusServiceUnavailable || report.Status != "degraded" {
+		t.Errorf("expected not ready, got %d and %s", code, report.Status)
+	}
+
+	if report.Checks["scheduler"].Error != "stopped" {
+		t.Errorf("expected scheduler error stopped, got %s", report.Checks["scheduler"].Error)
+	}
+
+	if !report.Checks["cache"].Healthy {
+		t.Errorf("expected cache to be healthy")
+	}
+
+	code, _ = getReport(t, h.LivenessHandler())
+	if code != http.StatusOK {
+		t.Errorf("expected liveness status %d, got %d", http.StatusOK, code)
+	}
+}
+
+func TestHealthDefaultTimeout(t *testing.T) {
+	h := NewHealth(0)
+	h.AddCheck("context", func(ctx context.Context) error { return ctx.Err() })
+
+	if report := h.Report(context.Background()); report.Status != "ok" {
+		t.Errorf("expected the checks to run with the default timeout, got %+v", report)
+	}
+}
+
+func TestOriginReachableCheck(t *testing.T) {
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/down" {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer origin.Close()
+
+	if err := OriginReachableCheck(origin.Client(), origin.URL+"/up")(context.Background()); err != nil {
+		t.Errorf("expected origin to be reachable, got %v", err)
+	}
+
+	if err := OriginReachableCheck(origin.Client(), origin.URL+"/down")(context.Background()); !errors.Is(err, ErrOriginUnhealthy) {
+		t.Errorf("expected origin to be unhealthy, got %v", err)
+	}
+}
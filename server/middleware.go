@@ -0,0 +1,15 @@
+package server
+
+import "net/http"
+
+// Middleware wraps a handler adding behavior before or after it.
+type Middleware func(http.Handler) http.Handler
+
+// Chain wraps the handler with the middlewares, the first middleware being the outermost.
+func Chain(handler http.Handler, middlewares ...Middleware) http.Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+
+	return handler
+}
@@ -0,0 +1,135 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// MinCompressSize is the minimum size in bytes of a playlist to be compressed, smaller playlists are sent as is.
+const MinCompressSize = 1024
+
+// acceptsGzip returns true if the request Accept-Encoding header allows gzip.
+func acceptsGzip(r *http.Request) bool {
+	for _, header := range r.Header.Values("Accept-Encoding") {
+		for _, encoding := range strings.Split(header, ",") {
+			name, params, _ := strings.Cut(strings.TrimSpace(encoding), ";")
+			name = strings.TrimSpace(name)
+			if name != "gzip" && name != "*" {
+				continue
+			}
+
+			key, value, found := strings.Cut(strings.TrimSpace(params), "=")
+			if found && strings.TrimSpace(key) == "q" {
+				q, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+				if err != nil || q == 0 {
+					return false
+				}
+			}
+
+			return true
+		}
+	}
+
+	return false
+}
+
+// gzipBytes compresses the data with gzip.
+func gzipBytes(data []byte) []byte {
+	var buffer bytes.Buffer
+	writer, _ := gzip.NewWriterLevel(&buffer, gzip.BestCompression)
+	writer.Write(data)
+	writer.Close()
+
+	return buffer.Bytes()
+}
+
+// ETag returns a strong entity tag for the playlist, derived from the hash of its contents so the same window always produces the same tag.
+func ETag(data []byte) string {
+	sum := sha256.Sum256(data)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// gzipETag returns the entity tag of the gzip representation of a playlist with the specified entity tag.
+func gzipETag(etag string) string {
+	return strings.TrimSuffix(etag, `"`) + `-gzip"`
+}
+
+// etagMatches returns true if the If-None-Match header matches the entity tag, using the weak comparison and ignoring the content encoding.
+func etagMatches(ifNoneMatch string, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag || candidate == gzipETag(etag) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// writePlaylist writes the playlist to the response, choosing the compressed body when it is available and accepted by the client, or answering 304 Not Modified when the client already has it.
+func writePlaylist(w http.ResponseWriter, r *http.Request, data []byte, compressed []byte, etag string) {
+	header := w.Header()
+	header.Set("Content-Type", contentTypes[".m3u8"])
+	header.Add("Vary", "Accept-Encoding")
+
+	body := data
+	if compressed != nil && acceptsGzip(r) {
+		header.Set("Content-Encoding", "gzip")
+		body = compressed
+		header.Set("ETag", gzipETag(etag))
+	} else {
+		header.Set("ETag", etag)
+	}
+
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" && etagMatches(ifNoneMatch, etag) {
+		header.Del("Content-Encoding")
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	header.Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(http.StatusOK)
+
+	if r.Method != http.MethodHead {
+		w.Write(body)
+	}
+}
+
+// ServePlaylist writes the playlist to the response, compressing it with gzip when accepted by the client and large enough, and answering conditional requests using its ETag.
+func ServePlaylist(w http.ResponseWriter, r *http.Request, data []byte) {
+	etag := ETag(data)
+
+	var compressed []byte
+	if len(data) >= MinCompressSize && acceptsGzip(r) && !etagMatches(r.Header.Get("If-None-Match"), etag) {
+		compressed = gzipBytes(data)
+	}
+
+	writePlaylist(w, r, data, compressed, etag)
+}
+
+// CompressedPlaylist holds a stable playlist, like a VOD playlist, alongside its pre-compressed body so it is compressed only once.
+type CompressedPlaylist struct {
+	data       []byte
+	compressed []byte
+	etag       string
+}
+
+// NewCompressedPlaylist creates a new CompressedPlaylist compressing the data if it is large enough.
+func NewCompressedPlaylist(data []byte) *CompressedPlaylist {
+	p := &CompressedPlaylist{data: data, etag: ETag(data)}
+	if len(data) >= MinCompressSize {
+		p.compressed = gzipBytes(data)
+	}
+
+	return p
+}
+
+// ServeHTTP writes the playlist to the response, using the pre-compressed body when accepted by the client.
+func (p *CompressedPlaylist) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	writePlaylist(w, r, p.data, p.compressed, p.etag)
+}
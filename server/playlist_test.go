@@ -0,0 +1,146 @@
+package server
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+// largePlaylist returns a playlist big enough to be compressed
+func largePlaylist(t *testing.T) []byte {
+	data, err := os.ReadFile("../testdata/stream2.m3u8")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return []byte(strings.Repeat(string(data), 4))
+}
+
+// playlistRequest creates a playlist request with the specified Accept-Encoding header
+func playlistRequest(acceptEncoding string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/streams/main/playlist.m3u8", nil)
+	if acceptEncoding != "" {
+		r.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+
+	return r
+}
+
+func TestServePlaylistGzip(t *testing.T) {
+	data := largePlaylist(t)
+
+	w := httptest.NewRecorder()
+	ServePlaylist(w, playlistRequest("br, gzip"), data)
+
+	if encoding := w.Header().Get("Content-Encoding"); encoding != "gzip" {
+		t.Fatalf("expected content encoding gzip, got %s", encoding)
+	}
+
+	reader, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(body) != string(data) {
+		t.Errorf("expected decompressed body to be the same, got different")
+	}
+}
+
+func TestServePlaylistIdentity(t *testing.T) {
+	data := largePlaylist(t)
+
+	for _, acceptEncoding := range []string{"", "identity", "gzip;q=0"} {
+		w := httptest.NewRecorder()
+		ServePlaylist(w, playlistRequest(acceptEncoding), data)
+
+		if encoding := w.Header().Get("Content-Encoding"); encoding != "" {
+			t.Errorf("expected no content encoding for %q, got %s", acceptEncoding, encoding)
+		}
+
+		if w.Body.String() != string(data) {
+			t.Errorf("expected body to be the same for %q, got different", acceptEncoding)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	ServePlaylist(w, playlistRequest("gzip"), []byte("#EXTM3U\n"))
+
+	if encoding := w.Header().Get("Content-Encoding"); encoding != "" {
+		t.Errorf("expected small playlist to not be compressed, got %s", encoding)
+	}
+}
+
+func TestCompressedPlaylist(t *testing.T) {
+	data := largePlaylist(t)
+	playlist := NewCompressedPlaylist(data)
+
+	w := httptest.NewRecorder()
+	playlist.ServeHTTP(w, playlistRequest("gzip"))
+
+	if encoding := w.Header().Get("Content-Encoding"); encoding != "gzip" {
+		t.Errorf("expected content encoding gzip, got %s", encoding)
+	}
+
+	if w.Body.Len() >= len(data) {
+		t.Errorf("expected compressed body to be smaller than %d, got %d", len(data), w.Body.Len())
+	}
+}
+
+func TestServePlaylistETag(t *testing.T) {
+	data := largePlaylist(t)
+
+	w := httptest.NewRecorder()
+	ServePlaylist(w, playlistRequest(""), data)
+
+	etag := w.Header().Get("ETag")
+	if etag != ETag(data) {
+		t.Fatalf("expected etag %s, got %s", ETag(data), etag)
+	}
+
+	r := playlistRequest("gzip")
+	r.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	ServePlaylist(w, r, data)
+
+	if w.Code != http.StatusNotModified {
+		t.Errorf("expected status %d, got %d", http.StatusNotModified, w.Code)
+	}
+
+	if w.Body.Len() != 0 {
+		t.Errorf("expected empty body, got %d bytes", w.Body.Len())
+	}
+
+	r = playlistRequest("")
+	r.Header.Set("If-None-Match", ETag([]byte("#EXTM3U\n")))
+	w = httptest.NewRecorder()
+	ServePlaylist(w, r, data)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+}
+
+func TestCompressedPlaylistETag(t *testing.T) {
+	playlist := NewCompressedPlaylist(largePlaylist(t))
+
+	w := httptest.NewRecorder()
+	playlist.ServeHTTP(w, playlistRequest("gzip"))
+
+	r := playlistRequest("gzip")
+	r.Header.Set("If-None-Match", w.Header().Get("ETag"))
+	w = httptest.NewRecorder()
+	playlist.ServeHTTP(w, r)
+
+	if w.Code != http.StatusNotModified {
+		t.Errorf("expected status %d, got %d", http.StatusNotModified, w.Code)
+	}
+}
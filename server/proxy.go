@@ -0,0 +1,237 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"vrmix/hls"
+)
+
+var (
+	// ErrUpstreamStatus indicates that the upstream origin answered with an unexpected status.
+	ErrUpstreamStatus = errors.New("unexpected upstream status")
+
+	// ErrForeignUpstream indicates that the requested path resolves to another origin than the upstream one, like "//other.host/x", or outside its base path, like "../x".
+	ErrForeignUpstream = errors.New("path resolves outside the upstream origin")
+)
+
+// uriAttribute matches the URI attribute of tags like #EXT-X-KEY, #EXT-X-MAP and #EXT-X-MEDIA.
+var uriAttribute = regexp.MustCompile(`URI="([^"]*)"`)
+
+// keyFormatAttribute matches the KEYFORMAT attribute of the #EXT-X-KEY and #EXT-X-SESSION-KEY tags.
+var keyFormatAttribute = regexp.MustCompile(`KEYFORMAT="([^"]*)"`)
+
+// isDRMKeyTag returns true if the line is a key tag of a DRM system, whose URI is passed to a license server.
+func isDRMKeyTag(line string) bool {
+	if !strings.HasPrefix(line, hls.KeyField+":") && !strings.HasPrefix(line, hls.SessionKeyField+":") {
+		return false
+	}
+
+	match := keyFormatAttribute.FindStringSubmatch(line)
+	return match != nil && match[1] != hls.IdentityKeyFormat
+}
+
+// RewritePlaylist rewrites every URI of the playlist, both URI lines and URI attributes, resolving them against the playlist URL before calling rewrite, except the URIs of the DRM keys which are only resolved, so the license acquisition still reaches the origin.
+func RewritePlaylist(data []byte, playlistURL *url.URL, rewrite func(u *url.URL) string) []byte {
+	resolve := func(reference string, drm bool) string {
+		u, err := playlistURL.Parse(reference)
+		if err != nil {
+			return reference
+		}
+
+		if drm {
+			return u.String()
+		}
+
+		return rewrite(u)
+	}
+
+	lines := strings.Split(string(data), "\n")
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+
+		if trimmed == "" {
+			continue
+		}
+
+		if strings.HasPrefix(trimmed, "#") {
+			drm := isDRMKeyTag(trimmed)
+			lines[i] = uriAttribute.ReplaceAllStringFunc(line, func(match string) string {
+				return `URI="` + resolve(uriAttribute.FindStringSubmatch(match)[1], drm) + `"`
+			})
+			continue
+		}
+
+		lines[i] = resolve(trimmed, false)
+	}
+
+	return []byte(strings.Join(lines, "\n"))
+}
+
+// ProxyCache stores the segments fetched by the Proxy.
+type ProxyCache interface {
+	// Get returns the cached segment with the key, if any.
+	Get(key string) (SegmentContent, bool)
+
+	// Put stores the segment data with the key, returning the stored segment.
+	Put(key string, data []byte) (SegmentContent, error)
+}
+
+// Proxy fronts an upstream HLS origin as is, rewriting only the playlist URIs so segments under the origin are delivered through VRMix and its cache.
+type Proxy struct {
+	Upstream   *url.URL     // Base URL of the origin, requests are resolved relative to it
+	Prefix     string       // Path prefix the proxy is mounted on, like "/proxy"
+	Client     *http.Client // Client used to fetch from the origin, defaults to http.DefaultClient
+	Cache      ProxyCache   // Cache storing the segments, nil to deliver segments without caching
+	Variations *Variations  // Variations adapting the media playlists to the capabilities of each client, nil to serve them as is
+
+	coalescer Coalescer[SegmentContent]
+}
+
+// client returns the client used to fetch from the origin.
+func (p *Proxy) client() *http.Client {
+	if p.Client == nil {
+		return http.DefaultClient
+	}
+
+	return p.Client
+}
+
+// upstreamURL returns the origin URL of the request, or ErrForeignUpstream when its path would reach another origin or leave the folder of the upstream URL.
+func (p *Proxy) upstreamURL(r *http.Request) (*url.URL, error) {
+	relative := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, p.Prefix), "/")
+
+	u, err := p.Upstream.Parse(relative)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.Scheme != p.Upstream.Scheme || u.Host != p.Upstream.Host || u.User.String() != p.Upstream.User.String() {
+		return nil, ErrForeignUpstream
+	}
+
+	cleaned := path.Clean("/" + u.Path)
+	if strings.HasSuffix(u.Path, "/") && cleaned != "/" {
+		cleaned += "/"
+	}
+
+	if cleaned != u.Path {
+		u.Path, u.RawPath = cleaned, ""
+	}
+
+	if base := p.Upstream.Path[:strings.LastIndex(p.Upstream.Path, "/")+1]; !strings.HasPrefix(u.Path, base) {
+		return nil, ErrForeignUpstream
+	}
+
+	u.RawQuery = r.URL.RawQuery
+	return u, nil
+}
+
+// localPath returns the path serving the origin URL through the proxy, or the URL itself if it is not under the origin.
+func (p *Proxy) localPath(u *url.URL) string {
+	base := p.Upstream.String()
+	base = base[:strings.LastIndex(base, "/")+1]
+
+	relative, found := strings.CutPrefix(u.String(), base)
+	if !found {
+		return u.String()
+	}
+
+	return strings.TrimSuffix(p.Prefix, "/") + "/" + relative
+}
+
+// fetch downloads the origin URL, tracing it as a client span.
+func (p *Proxy) fetch(ctx context.Context, u *url.URL) (data []byte, header http.Header, err error) {
+	ctx, span := otel.Tracer(TracerName).Start(ctx, "origin fetch", trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(originAttributes(u)...))
+	defer func() { endSpan(span, err) }()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	injectTrace(ctx, req)
+
+	resp, err := p.client().Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, ErrUpstreamStatus
+	}
+
+	data, err = io.ReadAll(resp.Body)
+	return data, resp.Header, err
+}
+
+// ServeHTTP proxies the request to the origin, rewriting playlists and delivering segments through the cache.
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	upstream, err := p.upstreamURL(r)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	if isPlaylistRequest(r) {
+		data, _, err := p.fetch(r.Context(), upstream)
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+			return
+		}
+
+		if p.Variations != nil {
+			if data, err = p.Variations.Adapt(r, data); errors.Is(err, ErrUnsupportedPlaylist) {
+				http.Error(w, err.Error(), http.StatusNotAcceptable)
+				return
+			} else if err != nil {
+				http.Error(w, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+				return
+			}
+		}
+
+		ServePlaylist(w, r, RewritePlaylist(data, upstream, p.localPath))
+		return
+	}
+
+	key := upstream.String()
+	if p.Cache != nil {
+		if segment, ok := lookupSpan(r.Context(), key, func() (SegmentContent, bool) { return p.Cache.Get(key) }); ok {
+			ServeSegment(w, r, upstream.Path, segment.ModTime, segment.Content, segment.Size)
+			return
+		}
+	}
+
+	segment, _, err := p.coalescer.Do(r.Context(), key, func(ctx context.Context) (SegmentContent, error) {
+		data, _, err := p.fetch(ctx, upstream)
+		if err != nil {
+			return SegmentContent{}, err
+		}
+
+		if p.Cache != nil {
+			return p.Cache.Put(key, data)
+		}
+
+		return SegmentContent{Content: bytes.NewReader(data), Size: int64(len(data)), ModTime: time.Now()}, nil
+	})
+
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+		return
+	}
+
+	ServeSegment(w, r, upstream.Path, segment.ModTime, segment.Content, segment.Size)
+}
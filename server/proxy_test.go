@@ -0,0 +1,132 @@
+package server
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// memoryProxyCache is a ProxyCache keeping segments in memory
+type memoryProxyCache struct {
+	mutex    sync.Mutex
+	segments map[string][]byte
+}
+
+func (c *memoryProxyCache) Get(key string) (SegmentContent, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	data, ok := c.segments[key]
+	return SegmentContent{Content: bytes.NewReader(data), Size: int64(len(data))}, ok
+}
+
+func (c *memoryProxyCache) Put(key string, data []byte) (SegmentContent, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.segments[key] = data
+	return SegmentContent{Content: bytes.NewReader(data), Size: int64(len(data)), ModTime: time.Now()}, nil
+}
+
+func TestRewritePlaylist(t *testing.T) {
+	playlist := "#EXTM3U\n#EXT-X-KEY:METHOD=AES-128,URI=\"key.bin\"\n#EXTINF:4,\nsegments/0.ts\n#EXTINF:4,\nhttps://cdn.example/1.ts\n"
+	base, _ := url.Parse("https://origin.example/live/index.m3u8")
+
+	rewritten := string(RewritePlaylist([]byte(playlist), base, func(u *url.URL) string { return "[" + u.String() + "]" }))
+
+	for _, expected := range []string{
+		`URI="[https://origin.example/live/key.bin]"`,
+		"\n[https://origin.example/live/segments/0.ts]\n",
+		"\n[https://cdn.example/1.ts]\n",
+	} {
+		if !strings.Contains(rewritten, expected) {
+			t.Errorf("expected rewritten playlist to contain %q, got %s", expected, rewritten)
+		}
+	}
+}
+
+func TestRewritePlaylistDRM(t *testing.T) {
+	playlist := "#EXTM3U\n#EXT-X-SESSION-KEY:METHOD=SAMPLE-AES,URI=\"skd://asset\",KEYFORMAT=\"com.apple.streamingkeydelivery\"\n" +
+		"#EXT-X-KEY:METHOD=SAMPLE-AES-CTR,URI=\"data:text/plain;base64,cHNzaA==\",KEYFORMAT=\"urn:uuid:edef8ba9-79d6-4ace-a3c8-27dcd51d21ed\"\n" +
+		"#EXT-X-KEY:METHOD=SAMPLE-AES,URI=\"license\",KEYFORMAT=\"com.microsoft.playready\"\n" +
+		"#EXT-X-KEY:METHOD=AES-128,URI=\"key.bin\",KEYFORMAT=\"identity\"\n#EXTINF:4,\n0.ts\n"
+	base, _ := url.Parse("https://origin.example/live/index.m3u8")
+
+	rewritten := string(RewritePlaylist([]byte(playlist), base, func(u *url.URL) string { return "[" + u.String() + "]" }))
+
+	for _, expected := range []string{
+		`URI="skd://asset"`,
+		`URI="data:text/plain;base64,cHNzaA=="`,
+		`URI="https://origin.example/live/license"`,
+		`URI="[https://origin.example/live/key.bin]"`,
+		"\n[https://origin.example/live/0.ts]\n",
+	} {
+		if !strings.Contains(rewritten, expected) {
+			t.Errorf("expected rewritten playlist to contain %q, got %s", expected, rewritten)
+		}
+	}
+}
+
+func TestProxy(t *testing.T) {
+	var segmentFetches atomic.Int32
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/live/index.m3u8":
+			w.Write([]byte("#EXTM3U\n#EXTINF:4,\n0.ts\n#EXTINF:4,\nhttps://cdn.example/1.ts\n"))
+		case "/live/0.ts":
+			segmentFetches.Add(1)
+			w.Write([]byte("segment"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer origin.Close()
+
+	upstream, _ := url.Parse(origin.URL + "/live/")
+	proxy := &Proxy{Upstream: upstream, Prefix: "/proxy", Cache: &memoryProxyCache{segments: map[string][]byte{}}}
+
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/proxy/index.m3u8", nil))
+
+	body := w.Body.String()
+	if !strings.Contains(body, "\n/proxy/0.ts\n") || !strings.Contains(body, "\nhttps://cdn.example/1.ts\n") {
+		t.Errorf("expected local segment and untouched external segment, got %s", body)
+	}
+
+	for i := 0; i < 2; i++ {
+		w = httptest.NewRecorder()
+		proxy.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/proxy/0.ts", nil))
+
+		data, _ := io.ReadAll(w.Body)
+		if string(data) != "segment" {
+			t.Errorf("expected segment body, got %s", data)
+		}
+	}
+
+	if segmentFetches.Load() != 1 {
+		t.Errorf("expected 1 segment fetch, got %d", segmentFetches.Load())
+	}
+
+	w = httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/proxy/missing.ts", nil))
+
+	if w.Code != http.StatusBadGateway {
+		t.Errorf("expected status %d, got %d", http.StatusBadGateway, w.Code)
+	}
+
+	for _, path := range []string{"/proxy///evil.example/x.ts", "/proxy/http:%2F%2Fevil.example/x.ts", "/proxy/../private/x.ts", "/proxy/%2e%2e/private/x.ts", "/proxy/a/../../private/x.ts"} {
+		w = httptest.NewRecorder()
+		proxy.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected %s reaching another origin or leaving the upstream folder to be rejected, got %d", path, w.Code)
+		}
+	}
+}
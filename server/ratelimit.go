@@ -0,0 +1,200 @@
+package server
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimitConfig represents the limits applied to each client.
+type RateLimitConfig struct {
+	PlaylistRate  float64       // Playlist requests per second allowed for each client
+	PlaylistBurst int           // Playlist requests allowed above the rate at once
+	SegmentRate   float64       // Segment requests per second allowed for each client
+	SegmentBurst  int           // Segment requests allowed above the rate at once
+	BanThreshold  int           // Rejected requests within BanWindow before the client is banned, zero disables bans
+	BanWindow     time.Duration // Window used to count rejected requests
+	BanDuration   time.Duration // Time a client stays banned
+	IdleTimeout   time.Duration // Time after which the state of an idle client is discarded, defaults to ten minutes
+
+	// Key returns the key identifying the client of a request, defaults to the client IP.
+	Key func(r *http.Request) string
+}
+
+// DefaultRateLimitConfig returns limits suited to players polling live playlists and fetching segments from a small deployment.
+func DefaultRateLimitConfig() RateLimitConfig {
+	return RateLimitConfig{
+		PlaylistRate:  2,
+		PlaylistBurst: 10,
+		SegmentRate:   10,
+		SegmentBurst:  30,
+		BanThreshold:  100,
+		BanWindow:     time.Minute,
+		BanDuration:   10 * time.Minute,
+	}
+}
+
+// bucket is a token bucket refilled at a constant rate.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// take refills the bucket and consumes a token, returning false if the bucket is empty.
+func (b *bucket) take(rate float64, burst int, now time.Time) bool {
+	if b.last.IsZero() {
+		b.tokens = float64(burst)
+	} else {
+		b.tokens = math.Min(float64(burst), b.tokens+now.Sub(b.last).Seconds()*rate)
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+
+	b.tokens -= 1
+	return true
+}
+
+// client is the rate limiting state of a client.
+type client struct {
+	playlist    bucket
+	segment     bucket
+	rejections  int
+	windowStart time.Time
+	bannedUntil time.Time
+	lastSeen    time.Time
+}
+
+// RateLimiter limits the requests of each client, temporarily banning clients that keep exceeding the limits.
+type RateLimiter struct {
+	config    RateLimitConfig
+	mutex     sync.Mutex
+	clients   map[string]*client
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewRateLimiter creates a new RateLimiter with the specified limits.
+func NewRateLimiter(config RateLimitConfig) *RateLimiter {
+	if config.Key == nil {
+		config.Key = ClientIP
+	}
+
+	if config.IdleTimeout <= 0 {
+		config.IdleTimeout = 10 * time.Minute
+	}
+
+	return &RateLimiter{config: config, clients: make(map[string]*client), now: time.Now}
+}
+
+// Allow returns true if the client identified by the key can make a playlist or segment request, otherwise returning the time to wait before retrying.
+func (l *RateLimiter) Allow(key string, playlist bool) (bool, time.Duration) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	c, ok := l.clients[key]
+	if !ok {
+		c = &client{}
+		l.clients[key] = c
+	}
+	c.lastSeen = now
+
+	if now.Before(c.bannedUntil) {
+		return false, c.bannedUntil.Sub(now)
+	}
+
+	var allowed bool
+	var rate float64
+	if playlist {
+		allowed = c.playlist.take(l.config.PlaylistRate, l.config.PlaylistBurst, now)
+		rate = l.config.PlaylistRate
+	} else {
+		allowed = c.segment.take(l.config.SegmentRate, l.config.SegmentBurst, now)
+		rate = l.config.SegmentRate
+	}
+
+	if allowed {
+		return true, 0
+	}
+
+	if l.config.BanThreshold > 0 {
+		if now.Sub(c.windowStart) > l.config.BanWindow {
+			c.windowStart = now
+			c.rejections = 0
+		}
+
+		c.rejections += 1
+		if c.rejections >= l.config.BanThreshold {
+			c.bannedUntil = now.Add(l.config.BanDuration)
+			c.rejections = 0
+			return false, l.config.BanDuration
+		}
+	}
+
+	if rate <= 0 {
+		return false, time.Second
+	}
+
+	return false, time.Duration(float64(time.Second) / rate)
+}
+
+// SetConfig replaces the limits, keeping the state of the clients and the key identifying them, so the limits are adjusted without a restart.
+func (l *RateLimiter) SetConfig(config RateLimitConfig) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	config.Key = l.config.Key
+	if config.IdleTimeout <= 0 {
+		config.IdleTimeout = l.config.IdleTimeout
+	}
+
+	l.config = config
+}
+
+// IsBanned returns true if the client identified by the key is currently banned.
+func (l *RateLimiter) IsBanned(key string) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	c, ok := l.clients[key]
+	return ok && l.now().Before(c.bannedUntil)
+}
+
+// sweep discards the state of idle clients that are not banned, at most once per idle timeout.
+func (l *RateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.config.IdleTimeout {
+		return
+	}
+	l.lastSweep = now
+
+	for key, c := range l.clients {
+		if now.Sub(c.lastSeen) > l.config.IdleTimeout && now.After(c.bannedUntil) {
+			delete(l.clients, key)
+		}
+	}
+}
+
+// Middleware returns a middleware answering 429 Too Many Requests to clients exceeding the limits.
+func (l *RateLimiter) Middleware() Middleware {
+	key := l.config.Key
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			allowed, retryAfter := l.Allow(key(r), isPlaylistRequest(r))
+			if !allowed {
+				seconds := int(math.Ceil(retryAfter.Seconds()))
+				w.Header().Set("Retry-After", strconv.Itoa(max(seconds, 1)))
+				http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
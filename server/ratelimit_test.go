@@ -0,0 +1,107 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newTestRateLimiter creates a rate limiter with a controllable clock
+func newTestRateLimiter(config RateLimitConfig) (*RateLimiter, *time.Time) {
+	now := time.Unix(1000, 0)
+	l := NewRateLimiter(config)
+	l.now = func() time.Time { return now }
+
+	return l, &now
+}
+
+func TestRateLimiterBurst(t *testing.T) {
+	l, now := newTestRateLimiter(RateLimitConfig{PlaylistRate: 1, PlaylistBurst: 2, SegmentRate: 1, SegmentBurst: 1})
+
+	for i := 0; i < 2; i++ {
+		if allowed, _ := l.Allow("a", true); !allowed {
+			t.Errorf("expected request %d to be allowed", i)
+		}
+	}
+
+	if allowed, _ := l.Allow("a", true); allowed {
+		t.Errorf("expected request above burst to be rejected")
+	}
+
+	if allowed, _ := l.Allow("a", false); !allowed {
+		t.Errorf("expected segment request to use its own bucket")
+	}
+
+	if allowed, _ := l.Allow("b", true); !allowed {
+		t.Errorf("expected other client to be allowed")
+	}
+
+	*now = now.Add(time.Second)
+
+	if allowed, _ := l.Allow("a", true); !allowed {
+		t.Errorf("expected request after refill to be allowed")
+	}
+}
+
+func TestRateLimiterBan(t *testing.T) {
+	l, now := newTestRateLimiter(RateLimitConfig{PlaylistRate: 1, PlaylistBurst: 1, BanThreshold: 3, BanWindow: time.Minute, BanDuration: time.Hour})
+
+	l.Allow("a", true)
+	for i := 0; i < 3; i++ {
+		l.Allow("a", true)
+	}
+
+	if !l.IsBanned("a") {
+		t.Fatalf("expected client to be banned")
+	}
+
+	*now = now.Add(time.Minute)
+
+	if allowed, retryAfter := l.Allow("a", true); allowed || retryAfter != 59*time.Minute {
+		t.Errorf("expected banned client to wait 59m, got allowed %t and %s", allowed, retryAfter)
+	}
+
+	*now = now.Add(time.Hour)
+
+	if allowed, _ := l.Allow("a", true); !allowed {
+		t.Errorf("expected client to be allowed after the ban")
+	}
+}
+
+func TestRateLimiterSetConfig(t *testing.T) {
+	l, _ := newTestRateLimiter(RateLimitConfig{PlaylistRate: 1, PlaylistBurst: 1})
+
+	if allowed, _ := l.Allow("a", true); !allowed {
+		t.Errorf("expected the first request to be allowed")
+	}
+
+	l.SetConfig(RateLimitConfig{PlaylistRate: 1, PlaylistBurst: 3})
+	if allowed, _ := l.Allow("a", true); allowed {
+		t.Errorf("expected the state of the client to be kept")
+	}
+
+	for i := range 3 {
+		if allowed, _ := l.Allow("b", true); !allowed {
+			t.Errorf("expected request %d to be allowed by the raised burst", i)
+		}
+	}
+
+	if l.config.Key == nil || l.config.IdleTimeout != 10*time.Minute {
+		t.Errorf("expected the key and idle timeout to be kept, got %+v", l.config)
+	}
+}
+
+func TestRateLimiterMiddleware(t *testing.T) {
+	l, _ := newTestRateLimiter(RateLimitConfig{PlaylistRate: 1, PlaylistBurst: 1})
+	handler := l.Middleware()(okHandler)
+
+	for i, code := range []int{http.StatusOK, http.StatusTooManyRequests} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/streams/main/playlist.m3u8", nil))
+
+		if w.Code != code {
+			t.Errorf("expected status %d on request %d, got %d", code, i, w.Code)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package server
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"vrmix/logging"
+	"vrmix/report"
+)
+
+// Recovery returns a middleware recovering the panics of the handlers, reporting them to the reporter and answering with an internal server error.
+func Recovery(reporter report.Reporter, logger *slog.Logger) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			recorder := &statusRecorder{ResponseWriter: w}
+
+			defer func() {
+				value := recover()
+				if value == nil {
+					return
+				}
+
+				if err, ok := value.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+					panic(value)
+				}
+
+				logging.Or(logger).Error("handler panicked", slog.String("method", r.Method), slog.String("path", r.URL.Path), slog.Any("panic", value))
+				report.Panic(r.Context(), reporter, "server", value, map[string]string{"method": r.Method, "path": r.URL.Path, "client": ClientIP(r)})
+
+				if recorder.status == 0 {
+					http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				}
+			}()
+
+			next.ServeHTTP(recorder, r)
+		})
+	}
+}
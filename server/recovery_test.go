@@ -0,0 +1,32 @@
+package server
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"vrmix/report"
+)
+
+func TestRecovery(t *testing.T) {
+	var reported []report.Report
+	reporter := report.Func(func(ctx context.Context, r report.Report) { reported = append(reported, r) })
+
+	handler := Recovery(reporter, slog.New(slog.NewTextHandler(io.Discard, nil)))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("broken handler")
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/main/index.m3u8", nil))
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected status %d, got %d", http.StatusInternalServerError, w.Code)
+	}
+
+	if len(reported) != 1 || reported[0].Subsystem != "server" || reported[0].Fields["path"] != "/main/index.m3u8" || reported[0].Stack == nil {
+		t.Errorf("expected the panic to be reported with its request, got %+v", reported)
+	}
+}
@@ -0,0 +1,22 @@
+package server
+
+import (
+	"net"
+	"net/http"
+	"path"
+)
+
+// ClientIP returns the IP address of the client that sent the request, without the port.
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// isPlaylistRequest returns true if the request targets a playlist instead of a segment.
+func isPlaylistRequest(r *http.Request) bool {
+	return path.Ext(r.URL.Path) == ".m3u8"
+}
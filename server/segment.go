@@ -0,0 +1,41 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"path"
+	"time"
+)
+
+// contentTypes maps the extensions of the files delivered to players to their content type.
+var contentTypes = map[string]string{
+	".m3u8": "application/vnd.apple.mpegurl",
+	".ts":   "video/mp2t",
+	".mp3":  "audio/mpeg",
+	".m4s":  "video/iso.segment",
+	".mp4":  "video/mp4",
+	".aac":  "audio/aac",
+	".vtt":  "text/vtt",
+}
+
+// SegmentContent represents the content of a segment ready to be delivered.
+type SegmentContent struct {
+	Content io.ReaderAt // Reader of the segment bytes
+	Size    int64       // Size of the segment in bytes
+	ModTime time.Time   // Time the segment was stored
+}
+
+// ContentType returns the content type of a file delivered to players based on its extension, defaulting to application/octet-stream.
+func ContentType(name string) string {
+	if contentType, ok := contentTypes[path.Ext(name)]; ok {
+		return contentType
+	}
+
+	return "application/octet-stream"
+}
+
+// ServeSegment writes the segment content to the response, answering Range requests with 206 Partial Content.
+func ServeSegment(w http.ResponseWriter, r *http.Request, name string, modTime time.Time, content io.ReaderAt, size int64) {
+	w.Header().Set("Content-Type", ContentType(name))
+	http.ServeContent(w, r, name, modTime, io.NewSectionReader(content, 0, size))
+}
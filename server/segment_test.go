@@ -0,0 +1,64 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// serveSegment serves a fixed segment with the specified Range header
+func serveSegment(rangeHeader string) *httptest.ResponseRecorder {
+	content := strings.NewReader("0123456789")
+
+	r := httptest.NewRequest(http.MethodGet, "/streams/main/0.ts", nil)
+	if rangeHeader != "" {
+		r.Header.Set("Range", rangeHeader)
+	}
+
+	w := httptest.NewRecorder()
+	ServeSegment(w, r, "0.ts", time.Unix(0, 0), content, content.Size())
+
+	return w
+}
+
+func TestServeSegment(t *testing.T) {
+	w := serveSegment("")
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	if contentType := w.Header().Get("Content-Type"); contentType != "video/mp2t" {
+		t.Errorf("expected content type video/mp2t, got %s", contentType)
+	}
+
+	if acceptRanges := w.Header().Get("Accept-Ranges"); acceptRanges != "bytes" {
+		t.Errorf("expected accept ranges bytes, got %s", acceptRanges)
+	}
+}
+
+func TestServeSegmentRange(t *testing.T) {
+	w := serveSegment("bytes=2-5")
+
+	if w.Code != http.StatusPartialContent {
+		t.Errorf("expected status %d, got %d", http.StatusPartialContent, w.Code)
+	}
+
+	if contentRange := w.Header().Get("Content-Range"); contentRange != "bytes 2-5/10" {
+		t.Errorf("expected content range bytes 2-5/10, got %s", contentRange)
+	}
+
+	body, _ := io.ReadAll(w.Body)
+	if string(body) != "2345" {
+		t.Errorf("expected body 2345, got %s", body)
+	}
+
+	w = serveSegment("bytes=20-30")
+
+	if w.Code != http.StatusRequestedRangeNotSatisfiable {
+		t.Errorf("expected status %d, got %d", http.StatusRequestedRangeNotSatisfiable, w.Code)
+	}
+}
@@ -0,0 +1,191 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// ErrDraining indicates that the server is shutting down and not accepting new sessions.
+var ErrDraining = errors.New("server is draining")
+
+// SessionTracker reports the playback sessions known to the server, so existing sessions keep being served while draining.
+type SessionTracker interface {
+	// IsActive returns true if the request belongs to an existing session.
+	IsActive(r *http.Request) bool
+
+	// ActiveCount returns the number of sessions that still need to be served.
+	ActiveCount() int
+}
+
+// Drainer is a subsystem that must finish its work before the process exits, like the scheduler finishing in-flight jobs or the cache flushing to disk.
+type Drainer interface {
+	Drain(ctx context.Context) error
+}
+
+// DrainerFunc adapts a function to the Drainer interface.
+type DrainerFunc func(ctx context.Context) error
+
+// Drain calls the function.
+func (f DrainerFunc) Drain(ctx context.Context) error {
+	return f(ctx)
+}
+
+// Config represents the configuration of a Server.
+type Config struct {
+	Addr         string         // Address to listen on
+	Handler      http.Handler   // Handler serving the requests
+	TLSConfig    *tls.Config    // TLS configuration used to serve HTTPS, nil to serve plain HTTP
+	DrainPeriod  time.Duration  // Maximum time existing sessions keep being served after the shutdown starts
+	PollInterval time.Duration  // Interval used to check if every session finished while draining, defaults to one second
+	Sessions     SessionTracker // Sessions served while draining, nil to stop serving right away
+	Drainers     []Drainer      // Subsystems drained in order after the HTTP server stops
+}
+
+// Server serves the HTTP handler and shuts it down gracefully, draining the existing sessions and the subsystems before exiting.
+type Server struct {
+	config     Config
+	httpServer *http.Server
+	draining   atomic.Bool
+}
+
+// New creates a new Server with the specified configuration.
+func New(config Config) *Server {
+	if config.PollInterval <= 0 {
+		config.PollInterval = time.Second
+	}
+
+	s := &Server{config: config}
+	s.httpServer = &http.Server{Addr: config.Addr, Handler: http.HandlerFunc(s.serveHTTP), TLSConfig: config.TLSConfig}
+
+	return s
+}
+
+// serveHTTP rejects requests of new sessions with 503 Service Unavailable while draining.
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.draining.Load() && (s.config.Sessions == nil || !s.config.Sessions.IsActive(r)) {
+		w.Header().Set("Connection", "close")
+		http.Error(w, ErrDraining.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	s.config.Handler.ServeHTTP(w, r)
+}
+
+// Draining returns true if the server started shutting down.
+func (s *Server) Draining() bool {
+	return s.draining.Load()
+}
+
+// ReadinessCheck returns a check failing while the server is draining, so orchestrators stop routing new clients to it.
+func (s *Server) ReadinessCheck() Check {
+	return func(ctx context.Context) error {
+		if s.draining.Load() {
+			return ErrDraining
+		}
+
+		return nil
+	}
+}
+
+// ListenAndServe listens on the configured address and serves requests until the server is shut down, using HTTPS when a TLS configuration is set.
+func (s *Server) ListenAndServe() error {
+	var err error
+	if s.config.TLSConfig != nil {
+		err = s.httpServer.ListenAndServeTLS("", "")
+	} else {
+		err = s.httpServer.ListenAndServe()
+	}
+
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+
+	return err
+}
+
+// Serve serves requests from the listener until the server is shut down, using HTTPS when a TLS configuration is set.
+func (s *Server) Serve(listener net.Listener) error {
+	var err error
+	if s.config.TLSConfig != nil {
+		err = s.httpServer.ServeTLS(listener, "", "")
+	} else {
+		err = s.httpServer.Serve(listener)
+	}
+
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+
+	return err
+}
+
+// Run serves requests until the context is done, like when a SIGTERM is received using signal.NotifyContext, then shuts down gracefully within the shutdown timeout.
+func (s *Server) Run(ctx context.Context, shutdownTimeout time.Duration) error {
+	return s.run(ctx, s.ListenAndServe, shutdownTimeout)
+}
+
+// RunListener serves requests from the listener until the context is done, then shuts down gracefully within the shutdown timeout.
+func (s *Server) RunListener(ctx context.Context, listener net.Listener, shutdownTimeout time.Duration) error {
+	return s.run(ctx, func() error { return s.Serve(listener) }, shutdownTimeout)
+}
+
+// run serves requests with the function until the context is done, then shuts down gracefully within the shutdown timeout.
+func (s *Server) run(ctx context.Context, serve func() error, shutdownTimeout time.Duration) error {
+	errs := make(chan error, 1)
+	go func() {
+		errs <- serve()
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	shutdownErr := s.Shutdown(shutdownCtx)
+	return errors.Join(<-errs, shutdownErr)
+}
+
+// Shutdown stops accepting new sessions, serves the existing ones until they finish or the drain period ends, stops the HTTP server and drains every subsystem.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.draining.Store(true)
+	s.waitSessions(ctx)
+
+	errs := []error{s.httpServer.Shutdown(ctx)}
+	for _, drainer := range s.config.Drainers {
+		errs = append(errs, drainer.Drain(ctx))
+	}
+
+	return errors.Join(errs...)
+}
+
+// waitSessions waits until every session finished, the drain period ends or the context is done.
+func (s *Server) waitSessions(ctx context.Context) {
+	if s.config.Sessions == nil || s.config.DrainPeriod <= 0 {
+		return
+	}
+
+	deadline := time.NewTimer(s.config.DrainPeriod)
+	defer deadline.Stop()
+
+	ticker := time.NewTicker(s.config.PollInterval)
+	defer ticker.Stop()
+
+	for s.config.Sessions.ActiveCount() > 0 {
+		select {
+		case <-ctx.Done():
+			return
+		case <-deadline.C:
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testSessions is a session tracker where only requests with the X-Session header are active
+type testSessions struct {
+	active atomic.Int32
+}
+
+func (s *testSessions) IsActive(r *http.Request) bool {
+	return r.Header.Get("X-Session") != ""
+}
+
+func (s *testSessions) ActiveCount() int {
+	return int(s.active.Load())
+}
+
+// getStatus sends a request to the server, with a session header if requested
+func getStatus(t *testing.T, url string, session bool) int {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if session {
+		req.Header.Set("X-Session", "1")
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	return resp.StatusCode
+}
+
+func TestServerShutdown(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sessions := &testSessions{}
+	sessions.active.Store(1)
+
+	var drained atomic.Bool
+	s := New(Config{
+		Handler:      okHandler,
+		DrainPeriod:  time.Second,
+		PollInterval: 10 * time.Millisecond,
+		Sessions:     sessions,
+		Drainers: []Drainer{DrainerFunc(func(ctx context.Context) error {
+			drained.Store(true)
+			return nil
+		})},
+	})
+
+	go s.Serve(listener)
+	url := "http://" + listener.Addr().String() + "/"
+
+	done := make(chan error, 1)
+	go func() {
+		done <- s.Shutdown(context.Background())
+	}()
+
+	for !s.Draining() {
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := s.ReadinessCheck()(context.Background()); err == nil {
+		t.Errorf("expected readiness check to fail while draining")
+	}
+
+	if code := getStatus(t, url, false); code != http.StatusServiceUnavailable {
+		t.Errorf("expected new session status %d, got %d", http.StatusServiceUnavailable, code)
+	}
+
+	if code := getStatus(t, url, true); code != http.StatusOK {
+		t.Errorf("expected existing session status %d, got %d", http.StatusOK, code)
+	}
+
+	sessions.active.Store(0)
+
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	if !drained.Load() {
+		t.Errorf("expected drainer to be called")
+	}
+}
@@ -0,0 +1,149 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"vrmix/events"
+)
+
+// ProgressEvent represents the progress of a download or conversion job.
+type ProgressEvent struct {
+	Job      string  `json:"job"`             // ID of the job
+	Channel  string  `json:"channel"`         // Channel the job belongs to
+	Kind     string  `json:"kind"`            // Kind of the job, like "download" or "conversion"
+	Progress float64 `json:"progress"`        // Completed fraction of the job, between 0 and 1
+	Done     bool    `json:"done"`            // Indicates if the job finished
+	Error    string  `json:"error,omitempty"` // Reason of the failure, if the job failed
+}
+
+// progressSubscriber is a client listening to the progress events.
+type progressSubscriber struct {
+	job     string
+	channel string
+	events  chan ProgressEvent
+}
+
+// ProgressHub broadcasts the progress events of the jobs to the clients of its server-sent events endpoint, dropping events for clients that cannot keep up.
+type ProgressHub struct {
+	mutex       sync.Mutex
+	subscribers map[*progressSubscriber]struct{}
+
+	// Heartbeat is the interval of the comments keeping idle connections open, defaults to 15 seconds.
+	Heartbeat time.Duration
+}
+
+// NewProgressHub creates a new ProgressHub without subscribers.
+func NewProgressHub() *ProgressHub {
+	return &ProgressHub{subscribers: make(map[*progressSubscriber]struct{}), Heartbeat: 15 * time.Second}
+}
+
+// Publish sends the event to the subscribers interested in its job or channel.
+func (h *ProgressHub) Publish(event ProgressEvent) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	for subscriber := range h.subscribers {
+		if subscriber.job != "" && subscriber.job != event.Job {
+			continue
+		}
+
+		if subscriber.channel != "" && subscriber.channel != event.Channel {
+			continue
+		}
+
+		select {
+		case subscriber.events <- event:
+		default:
+		}
+	}
+}
+
+// subscribe registers a subscriber filtering by job and channel, empty to receive every event.
+func (h *ProgressHub) subscribe(job string, channel string) *progressSubscriber {
+	subscriber := &progressSubscriber{job: job, channel: channel, events: make(chan ProgressEvent, 64)}
+
+	h.mutex.Lock()
+	h.subscribers[subscriber] = struct{}{}
+	h.mutex.Unlock()
+
+	return subscriber
+}
+
+// unsubscribe removes the subscriber.
+func (h *ProgressHub) unsubscribe(subscriber *progressSubscriber) {
+	h.mutex.Lock()
+	delete(h.subscribers, subscriber)
+	h.mutex.Unlock()
+}
+
+// ServeHTTP streams the progress events as server-sent events, filtered by the "job" and "channel" query parameters.
+func (h *ProgressHub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	controller := http.NewResponseController(w)
+
+	query := r.URL.Query()
+	subscriber := h.subscribe(query.Get("job"), query.Get("channel"))
+	defer h.unsubscribe(subscriber)
+
+	header := w.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	if err := controller.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(h.Heartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			if _, err := w.Write([]byte(": heartbeat\n\n")); err != nil {
+				return
+			}
+		case event := <-subscriber.events:
+			data, err := json.Marshal(event)
+			if err != nil {
+				continue
+			}
+
+			if _, err := w.Write([]byte("event: progress\ndata: " + string(data) + "\n\n")); err != nil {
+				return
+			}
+		}
+
+		if err := controller.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// Forward publishes the job events of the bus as progress events until the context is done.
+func (h *ProgressHub) Forward(ctx context.Context, bus *events.Bus) *events.Subscription {
+	options := events.Options{Types: []events.Type{events.JobProgress, events.JobFinished, events.ConversionFailed}, Policy: events.DropOldest}
+
+	return bus.Handle(ctx, options, func(event events.Event) {
+		progress := ProgressEvent{Job: event.Job, Channel: event.Channel, Done: event.Type != events.JobProgress}
+		progress.Kind, _ = event.Data["kind"].(string)
+		progress.Progress, _ = event.Data["progress"].(float64)
+
+		if event.Type == events.ConversionFailed {
+			progress.Error, _ = event.Data["error"].(string)
+			if progress.Kind == "" {
+				progress.Kind = "conversion"
+			}
+		} else if event.Type == events.JobFinished {
+			progress.Progress = 1
+		}
+
+		h.Publish(progress)
+	})
+}
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProgressHub(t *testing.T) {
+	hub := NewProgressHub()
+	server := httptest.NewServer(hub)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "?channel=main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if contentType := resp.Header.Get("Content-Type"); contentType != "text/event-stream" {
+		t.Errorf("expected content type text/event-stream, got %s", contentType)
+	}
+
+	for {
+		hub.mutex.Lock()
+		subscribers := len(hub.subscribers)
+		hub.mutex.Unlock()
+
+		if subscribers > 0 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	hub.Publish(ProgressEvent{Job: "a", Channel: "other", Progress: 0.1})
+	hub.Publish(ProgressEvent{Job: "b", Channel: "main", Kind: "conversion", Progress: 0.5})
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		data, found := strings.CutPrefix(scanner.Text(), "data: ")
+		if !found {
+			continue
+		}
+
+		var event ProgressEvent
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			t.Fatal(err)
+		}
+
+		if event.Job != "b" || event.Progress != 0.5 {
+			t.Errorf("expected job b at 0.5, got %s at %f", event.Job, event.Progress)
+		}
+
+		break
+	}
+}
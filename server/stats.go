@@ -0,0 +1,110 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// CacheStats represents the statistics of the segment cache.
+type CacheStats struct {
+	Hits     uint64  `json:"hits"`      // Lookups served from the cache
+	Misses   uint64  `json:"misses"`    // Lookups not found in the cache
+	HitRatio float64 `json:"hit_ratio"` // Fraction of lookups served from the cache
+	Entries  int     `json:"entries"`   // Segments stored
+	Size     int64   `json:"size"`      // Bytes stored
+	MaxSize  int64   `json:"max_size"`  // Maximum bytes stored before evicting
+}
+
+// SchedulerStats represents the statistics of the download and conversion scheduler.
+type SchedulerStats struct {
+	QueueDepth        int `json:"queue_depth"`        // Jobs waiting for a worker
+	Running           int `json:"running"`            // Jobs being run
+	ConversionBacklog int `json:"conversion_backlog"` // Conversion jobs waiting or running
+}
+
+// BandwidthStats represents the bytes transferred since the server started.
+type BandwidthStats struct {
+	BytesIn  uint64 `json:"bytes_in"`  // Bytes downloaded from origins
+	BytesOut uint64 `json:"bytes_out"` // Bytes delivered to players
+}
+
+// Stats represents the aggregated statistics returned by the admin API.
+type Stats struct {
+	Uptime    string         `json:"uptime"`    // Time since the collector was created
+	Sessions  map[string]int `json:"sessions"`  // Active sessions per channel
+	Cache     CacheStats     `json:"cache"`     // Segment cache statistics
+	Scheduler SchedulerStats `json:"scheduler"` // Scheduler statistics
+	Bandwidth BandwidthStats `json:"bandwidth"` // Bandwidth statistics
+}
+
+// StatsCollector aggregates the statistics of every subsystem, each one being optional.
+type StatsCollector struct {
+	Sessions  func() map[string]int // Returns the active sessions per channel
+	Cache     func() CacheStats     // Returns the cache statistics
+	Scheduler func() SchedulerStats // Returns the scheduler statistics
+
+	start    time.Time
+	bytesIn  atomic.Uint64
+	bytesOut atomic.Uint64
+}
+
+// NewStatsCollector creates a new StatsCollector without subsystems.
+func NewStatsCollector() *StatsCollector {
+	return &StatsCollector{start: time.Now()}
+}
+
+// AddBytesIn records bytes downloaded from an origin.
+func (c *StatsCollector) AddBytesIn(n int64) {
+	c.bytesIn.Add(uint64(n))
+}
+
+// AddBytesOut records bytes delivered to a player.
+func (c *StatsCollector) AddBytesOut(n int64) {
+	c.bytesOut.Add(uint64(n))
+}
+
+// Collect returns the current statistics of every subsystem.
+func (c *StatsCollector) Collect() Stats {
+	stats := Stats{
+		Uptime:    time.Since(c.start).Round(time.Second).String(),
+		Sessions:  map[string]int{},
+		Bandwidth: BandwidthStats{BytesIn: c.bytesIn.Load(), BytesOut: c.bytesOut.Load()},
+	}
+
+	if c.Sessions != nil {
+		stats.Sessions = c.Sessions()
+	}
+
+	if c.Cache != nil {
+		stats.Cache = c.Cache()
+		if lookups := stats.Cache.Hits + stats.Cache.Misses; lookups > 0 {
+			stats.Cache.HitRatio = float64(stats.Cache.Hits) / float64(lookups)
+		}
+	}
+
+	if c.Scheduler != nil {
+		stats.Scheduler = c.Scheduler()
+	}
+
+	return stats
+}
+
+// Middleware returns a middleware recording the bytes delivered to players.
+func (c *StatsCollector) Middleware() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			recorder := &statusRecorder{ResponseWriter: w}
+			next.ServeHTTP(recorder, r)
+			c.AddBytesOut(recorder.bytes)
+		})
+	}
+}
+
+// ServeHTTP writes the statistics as JSON.
+func (c *StatsCollector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(c.Collect())
+}
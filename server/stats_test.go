@@ -0,0 +1,45 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStatsCollector(t *testing.T) {
+	c := NewStatsCollector()
+	c.Sessions = func() map[string]int { return map[string]int{"main": 2} }
+	c.Cache = func() CacheStats { return CacheStats{Hits: 3, Misses: 1} }
+	c.Scheduler = func() SchedulerStats { return SchedulerStats{QueueDepth: 4} }
+	c.AddBytesIn(100)
+
+	handler := c.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("segment"))
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/streams/main/0.ts", nil))
+
+	w := httptest.NewRecorder()
+	c.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/stats", nil))
+
+	var stats Stats
+	if err := json.NewDecoder(w.Body).Decode(&stats); err != nil {
+		t.Fatal(err)
+	}
+
+	if stats.Sessions["main"] != 2 {
+		t.Errorf("expected 2 sessions on main, got %d", stats.Sessions["main"])
+	}
+
+	if stats.Cache.HitRatio != 0.75 {
+		t.Errorf("expected hit ratio 0.75, got %f", stats.Cache.HitRatio)
+	}
+
+	if stats.Scheduler.QueueDepth != 4 {
+		t.Errorf("expected queue depth 4, got %d", stats.Scheduler.QueueDepth)
+	}
+
+	if stats.Bandwidth.BytesIn != 100 || stats.Bandwidth.BytesOut != 7 {
+		t.Errorf("expected 100 bytes in and 7 bytes out, got %d and %d", stats.Bandwidth.BytesIn, stats.Bandwidth.BytesOut)
+	}
+}
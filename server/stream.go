@@ -0,0 +1,169 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"vrmix/cache"
+	"vrmix/logging"
+	"vrmix/report"
+)
+
+var (
+	// ErrStreamNotFound indicates that no stream has the requested ID.
+	ErrStreamNotFound = errors.New("stream not found")
+
+	// ErrSegmentNotFound indicates that the segment is not part of the stream.
+	ErrSegmentNotFound = errors.New("segment not found")
+)
+
+// Streams produces the playlists and segments of the streams, like the stream controller.
+type Streams interface {
+	// Playlist returns the current media playlist of the stream, or ErrStreamNotFound.
+	Playlist(ctx context.Context, id string) ([]byte, error)
+
+	// Segment produces the segment of the stream, or returns ErrStreamNotFound or ErrSegmentNotFound.
+	Segment(ctx context.Context, id string, name string) ([]byte, error)
+}
+
+// StreamHandler delivers the playlists and segments of the streams, serving the segments from the cache, or redirecting to them when the cache is a cache.Locator, and producing the missing ones once, with the routes:
+//
+//	GET /streams/{id}/playlist.m3u8
+//	GET /streams/{id}/{segment}
+type StreamHandler struct {
+	Streams    Streams         // Streams delivered
+	Cache      cache.Backend   // Cache storing the segments produced, nil to produce every requested segment
+	TTL        time.Duration   // Time the segments produced are cached, zero for the TTL of the cache
+	Cached     bool            // Whether Streams writes the segments it produces into the cache itself, like the stream controller, so the handler only reads them
+	Redirect   time.Duration   // Time the URLs the players are redirected to for the cache hits of a cache.Locator are valid, zero to serve the segments
+	Logger     *slog.Logger    // Logger receiving the failures to produce playlists and segments, defaults to slog.Default
+	Reporter   report.Reporter // Reporter receiving the failures to produce playlists and segments, nil to not report them
+	Variations *Variations     // Variations adapting the playlists to the capabilities of each client, nil to serve them as is
+
+	once      sync.Once
+	mux       *http.ServeMux
+	coalescer Coalescer[[]byte]
+}
+
+// ServeHTTP delivers the requested playlist or segment.
+func (h *StreamHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.once.Do(func() {
+		h.mux = http.NewServeMux()
+		h.mux.HandleFunc("GET /streams/{id}/playlist.m3u8", h.playlist)
+		h.mux.HandleFunc("GET /streams/{id}/{segment}", h.segment)
+	})
+
+	h.mux.ServeHTTP(w, r)
+}
+
+// playlist writes the current playlist of the stream, never cached by the players as it changes with each segment.
+func (h *StreamHandler) playlist(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	data, err := h.Streams.Playlist(r.Context(), id)
+	if err != nil {
+		h.fail(w, r, err, map[string]string{"stream": id})
+		return
+	}
+
+	if h.Variations != nil {
+		if data, err = h.Variations.Adapt(r, data); errors.Is(err, ErrUnsupportedPlaylist) {
+			http.Error(w, err.Error(), http.StatusNotAcceptable)
+			return
+		} else if err != nil {
+			h.fail(w, r, err, map[string]string{"stream": id})
+			return
+		}
+	}
+
+	w.Header().Set("Cache-Control", "no-cache")
+	ServePlaylist(w, r, data)
+}
+
+// segment writes the segment of the stream from the cache, or redirects to it, producing it once when missing.
+func (h *StreamHandler) segment(w http.ResponseWriter, r *http.Request) {
+	id, name := r.PathValue("id"), r.PathValue("segment")
+	key := id + "/" + name
+
+	var data []byte
+	var err error
+	if locator, ok := h.Cache.(cache.Locator); ok && h.Redirect > 0 {
+		var location string
+		if location, err = h.locate(r.Context(), locator, key); err == nil {
+			w.Header().Set("Cache-Control", "no-store")
+			http.Redirect(w, r, location, http.StatusFound)
+			return
+		}
+	} else {
+		data, err = h.cached(r.Context(), key)
+	}
+
+	if err != nil {
+		data, _, err = h.coalescer.Do(r.Context(), key, func(ctx context.Context) ([]byte, error) {
+			data, err := h.Streams.Segment(ctx, id, name)
+			if err == nil && h.Cache != nil && !h.Cached {
+				if err := h.Cache.Put(ctx, key, data, h.TTL); err != nil {
+					logging.Or(h.Logger).Warn("failed to cache segment", slog.String("segment", key), logging.Err(err))
+				}
+			}
+
+			return data, err
+		})
+	}
+
+	if err != nil {
+		h.fail(w, r, err, map[string]string{"stream": id, "segment": name})
+		return
+	}
+
+	ServeSegment(w, r, name, time.Time{}, bytes.NewReader(data), int64(len(data)))
+}
+
+// locate returns the URL of the segment with the key in the cache, logging the failures other than a miss as the segment is produced instead.
+func (h *StreamHandler) locate(ctx context.Context, locator cache.Locator, key string) (string, error) {
+	var location string
+	var err error
+	lookupSpan(ctx, key, func() (SegmentContent, bool) {
+		location, err = locator.URL(ctx, key, h.Redirect)
+		return SegmentContent{}, err == nil
+	})
+
+	if err != nil && !errors.Is(err, cache.ErrNotFound) {
+		logging.Or(h.Logger).Warn("failed to locate cached segment", slog.String("segment", key), logging.Err(err))
+	}
+
+	return location, err
+}
+
+// cached returns the segment with the key from the cache, or cache.ErrNotFound without a cache.
+func (h *StreamHandler) cached(ctx context.Context, key string) ([]byte, error) {
+	if h.Cache == nil {
+		return nil, cache.ErrNotFound
+	}
+
+	var data []byte
+	var err error
+	lookupSpan(ctx, key, func() (SegmentContent, bool) {
+		data, err = h.Cache.Get(ctx, key)
+		return SegmentContent{}, err == nil
+	})
+
+	return data, err
+}
+
+// fail answers 404 Not Found for the unknown streams and segments, and 500 Internal Server Error after logging and reporting the other errors.
+func (h *StreamHandler) fail(w http.ResponseWriter, r *http.Request, err error, tags map[string]string) {
+	if errors.Is(err, ErrStreamNotFound) || errors.Is(err, ErrSegmentNotFound) {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+
+	logging.Or(h.Logger).Warn("failed to produce stream content", slog.String("path", r.URL.Path), logging.Err(err))
+	report.Error(r.Context(), h.Reporter, "server", err, tags)
+	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+}
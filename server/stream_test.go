@@ -0,0 +1,180 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"vrmix/cache"
+)
+
+// fakeStreams serves a single stream counting the segments produced
+type fakeStreams struct {
+	mutex    sync.Mutex
+	produced map[string]int
+	err      error
+}
+
+func (f *fakeStreams) Playlist(ctx context.Context, id string) ([]byte, error) {
+	if id != "main" {
+		return nil, ErrStreamNotFound
+	}
+
+	return []byte("#EXTM3U\n#EXTINF:2,\n0.ts\n"), nil
+}
+
+func (f *fakeStreams) Segment(ctx context.Context, id string, name string) ([]byte, error) {
+	if id != "main" {
+		return nil, ErrStreamNotFound
+	}
+
+	if name != "0.ts" {
+		return nil, ErrSegmentNotFound
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.produced[name]++
+	return []byte("0123456789"), f.err
+}
+
+// serveStream serves the request through the handler
+func serveStream(h http.Handler, target string, rangeHeader string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, target, nil)
+	if rangeHeader != "" {
+		r.Header.Set("Range", rangeHeader)
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestStreamHandler(t *testing.T) {
+	segments, err := cache.Open(cache.Config{Dir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	streams := &fakeStreams{produced: map[string]int{}}
+	h := &StreamHandler{Streams: streams, Cache: segments.Backend()}
+
+	w := serveStream(h, "/streams/main/playlist.m3u8", "")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/vnd.apple.mpegurl" || w.Header().Get("Cache-Control") != "no-cache" {
+		t.Errorf("expected the playlist, got %d and %v", w.Code, w.Header())
+	}
+
+	w = serveStream(h, "/streams/main/0.ts", "bytes=2-5")
+	if w.Code != http.StatusPartialContent || w.Body.String() != "2345" || w.Header().Get("Content-Type") != "video/mp2t" {
+		t.Errorf("expected the range of the segment, got %d and %q", w.Code, w.Body.String())
+	}
+
+	w = serveStream(h, "/streams/main/0.ts", "")
+	if w.Code != http.StatusOK || w.Body.String() != "0123456789" {
+		t.Errorf("expected the segment, got %d and %q", w.Code, w.Body.String())
+	}
+
+	if streams.produced["0.ts"] != 1 {
+		t.Errorf("expected the segment to be produced once, got %d", streams.produced["0.ts"])
+	}
+
+	for _, target := range []string{"/streams/other/playlist.m3u8", "/streams/other/0.ts", "/streams/main/1.ts", "/streams/main"} {
+		if w := serveStream(h, target, ""); w.Code != http.StatusNotFound {
+			t.Errorf("expected %s to be missing, got %d", target, w.Code)
+		}
+	}
+}
+
+func TestStreamHandlerCached(t *testing.T) {
+	segments, err := cache.Open(cache.Config{Dir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	streams := &fakeStreams{produced: map[string]int{}}
+	h := &StreamHandler{Streams: streams, Cache: segments.Backend(), Cached: true}
+
+	if w := serveStream(h, "/streams/main/0.ts", ""); w.Code != http.StatusOK || w.Body.String() != "0123456789" {
+		t.Fatalf("expected the segment, got %d and %q", w.Code, w.Body.String())
+	}
+
+	if _, err := segments.Get("main/0.ts"); !errors.Is(err, cache.ErrNotFound) {
+		t.Errorf("expected the handler to leave the cache write to the streams, got %v", err)
+	}
+}
+
+func TestStreamHandlerFailure(t *testing.T) {
+	streams := &fakeStreams{produced: map[string]int{}, err: errors.New("conversion failed")}
+	h := &StreamHandler{Streams: streams}
+
+	if w := serveStream(h, "/streams/main/0.ts", ""); w.Code != http.StatusInternalServerError {
+		t.Errorf("expected status %d, got %d", http.StatusInternalServerError, w.Code)
+	}
+
+	streams.err = nil
+	serveStream(h, "/streams/main/0.ts", "")
+	serveStream(h, "/streams/main/0.ts", "")
+
+	if streams.produced["0.ts"] != 3 {
+		t.Errorf("expected the segments to be produced on each request without a cache, got %d", streams.produced["0.ts"])
+	}
+}
+
+// fakeLocator is a cache locating the segments at a fixed origin
+type fakeLocator struct {
+	segments map[string][]byte
+}
+
+func (f *fakeLocator) Get(ctx context.Context, key string) ([]byte, error) {
+	if data, ok := f.segments[key]; ok {
+		return data, nil
+	}
+
+	return nil, cache.ErrNotFound
+}
+
+func (f *fakeLocator) Put(ctx context.Context, key string, data []byte, ttl time.Duration) error {
+	f.segments[key] = data
+	return nil
+}
+
+func (f *fakeLocator) Remove(ctx context.Context, key string) error {
+	delete(f.segments, key)
+	return nil
+}
+
+func (f *fakeLocator) URL(ctx context.Context, key string, expires time.Duration) (string, error) {
+	if _, ok := f.segments[key]; !ok {
+		return "", cache.ErrNotFound
+	}
+
+	return "https://storage.example/" + key + "?expires=" + expires.String(), nil
+}
+
+func TestStreamHandlerRedirect(t *testing.T) {
+	streams := &fakeStreams{produced: map[string]int{}}
+	h := &StreamHandler{Streams: streams, Cache: &fakeLocator{segments: map[string][]byte{}}, Redirect: time.Minute}
+
+	if w := serveStream(h, "/streams/main/0.ts", ""); w.Code != http.StatusOK || w.Body.String() != "0123456789" {
+		t.Errorf("expected the segment produced on the miss, got %d and %q", w.Code, w.Body.String())
+	}
+
+	w := serveStream(h, "/streams/main/0.ts", "")
+	if w.Code != http.StatusFound || w.Header().Get("Location") != "https://storage.example/main/0.ts?expires=1m0s" || w.Header().Get("Cache-Control") != "no-store" {
+		t.Errorf("expected a redirect to the cached segment, got %d and %v", w.Code, w.Header())
+	}
+
+	h = &StreamHandler{Streams: streams, Cache: h.Cache}
+	if w := serveStream(h, "/streams/main/0.ts", ""); w.Code != http.StatusOK || w.Body.String() != "0123456789" {
+		t.Errorf("expected the cached segment to be served without Redirect, got %d and %q", w.Code, w.Body.String())
+	}
+
+	if streams.produced["0.ts"] != 1 {
+		t.Errorf("expected the segment to be produced once, got %d", streams.produced["0.ts"])
+	}
+}
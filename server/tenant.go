@@ -0,0 +1,170 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+var (
+	// ErrTenantNameMissing indicates that a tenant has no name.
+	ErrTenantNameMissing = errors.New("missing tenant name")
+
+	// ErrTenantRouteMissing indicates that a tenant has neither hosts nor a path prefix.
+	ErrTenantRouteMissing = errors.New("tenant requires a host or path prefix")
+
+	// ErrTenantConflict indicates that a tenant name, host or path prefix is already in use by another tenant.
+	ErrTenantConflict = errors.New("tenant conflicts with an existing tenant")
+)
+
+// Tenant represents an isolated configuration served by the same process, with its own channels, caches, auth and quotas wired into its handler.
+type Tenant struct {
+	Name       string       // Unique name of the tenant
+	Hosts      []string     // Hostnames routed to the tenant
+	PathPrefix string       // Path prefix routed to the tenant, like "/alice", stripped before calling the handler
+	Handler    http.Handler // Handler serving the tenant requests
+}
+
+// tenantContextKey is the context key holding the tenant of a request.
+type tenantContextKey struct{}
+
+// TenantFromContext returns the name of the tenant serving the request, or an empty string if it was not routed by a TenantRouter.
+func TenantFromContext(ctx context.Context) string {
+	name, _ := ctx.Value(tenantContextKey{}).(string)
+	return name
+}
+
+// TenantRouter routes requests to tenants by hostname or path prefix, hostnames taking precedence over prefixes.
+type TenantRouter struct {
+	mutex    sync.RWMutex
+	tenants  map[string]*Tenant
+	hosts    map[string]*Tenant
+	prefixes map[string]*Tenant
+}
+
+// NewTenantRouter creates a new TenantRouter without tenants.
+func NewTenantRouter() *TenantRouter {
+	return &TenantRouter{
+		tenants:  make(map[string]*Tenant),
+		hosts:    make(map[string]*Tenant),
+		prefixes: make(map[string]*Tenant),
+	}
+}
+
+// normalizeHost returns the hostname in lower case without the port.
+func normalizeHost(host string) string {
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		host = hostname
+	}
+
+	return strings.ToLower(strings.TrimSuffix(host, "."))
+}
+
+// Add registers a tenant, failing if its name, hosts or path prefix are already in use.
+func (tr *TenantRouter) Add(t Tenant) error {
+	if t.Name == "" {
+		return ErrTenantNameMissing
+	}
+
+	prefix := strings.TrimSuffix(t.PathPrefix, "/")
+	if len(t.Hosts) == 0 && prefix == "" {
+		return ErrTenantRouteMissing
+	}
+
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+
+	if _, ok := tr.tenants[t.Name]; ok {
+		return ErrTenantConflict
+	}
+
+	for _, host := range t.Hosts {
+		if _, ok := tr.hosts[normalizeHost(host)]; ok {
+			return ErrTenantConflict
+		}
+	}
+
+	if _, ok := tr.prefixes[prefix]; ok && prefix != "" {
+		return ErrTenantConflict
+	}
+
+	t.PathPrefix = prefix
+	tenant := &t
+	tr.tenants[t.Name] = tenant
+
+	for _, host := range t.Hosts {
+		tr.hosts[normalizeHost(host)] = tenant
+	}
+
+	if prefix != "" {
+		tr.prefixes[prefix] = tenant
+	}
+
+	return nil
+}
+
+// Remove unregisters the tenant with the specified name, returning false if it does not exist.
+func (tr *TenantRouter) Remove(name string) bool {
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+
+	tenant, ok := tr.tenants[name]
+	if !ok {
+		return false
+	}
+
+	delete(tr.tenants, name)
+	for _, host := range tenant.Hosts {
+		delete(tr.hosts, normalizeHost(host))
+	}
+	delete(tr.prefixes, tenant.PathPrefix)
+
+	return true
+}
+
+// match returns the tenant of the request and the path prefix to strip from it.
+func (tr *TenantRouter) match(r *http.Request) (*Tenant, string) {
+	tr.mutex.RLock()
+	defer tr.mutex.RUnlock()
+
+	if tenant, ok := tr.hosts[normalizeHost(r.Host)]; ok {
+		return tenant, ""
+	}
+
+	var best *Tenant
+	for prefix, tenant := range tr.prefixes {
+		if r.URL.Path != prefix && !strings.HasPrefix(r.URL.Path, prefix+"/") {
+			continue
+		}
+
+		if best == nil || len(prefix) > len(best.PathPrefix) {
+			best = tenant
+		}
+	}
+
+	if best == nil {
+		return nil, ""
+	}
+
+	return best, best.PathPrefix
+}
+
+// ServeHTTP routes the request to its tenant, answering 404 Not Found when no tenant matches.
+func (tr *TenantRouter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	tenant, prefix := tr.match(r)
+	if tenant == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	r = r.WithContext(context.WithValue(r.Context(), tenantContextKey{}, tenant.Name))
+	if prefix == "" {
+		tenant.Handler.ServeHTTP(w, r)
+		return
+	}
+
+	http.StripPrefix(prefix, tenant.Handler).ServeHTTP(w, r)
+}
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// tenantHandler answers with the tenant name and the path it received
+func tenantHandler(name string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(name + ":" + TenantFromContext(r.Context()) + ":" + r.URL.Path))
+	})
+}
+
+// route sends a request to the router and returns the response body
+func route(router http.Handler, host string, path string) string {
+	r := httptest.NewRequest(http.MethodGet, path, nil)
+	r.Host = host
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK {
+		return ""
+	}
+
+	return w.Body.String()
+}
+
+func TestTenantRouter(t *testing.T) {
+	router := NewTenantRouter()
+
+	if err := router.Add(Tenant{Name: "alice", Hosts: []string{"alice.example"}, Handler: tenantHandler("alice")}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := router.Add(Tenant{Name: "bob", PathPrefix: "/bob/", Handler: tenantHandler("bob")}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := router.Add(Tenant{Name: "bobby", PathPrefix: "/bob/by", Handler: tenantHandler("bobby")}); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[[2]string]string{
+		{"ALICE.example:8080", "/bob/streams/main/playlist.m3u8"}: "alice:alice:/bob/streams/main/playlist.m3u8",
+		{"vrmix.example", "/bob/streams/main/playlist.m3u8"}:      "bob:bob:/streams/main/playlist.m3u8",
+		{"vrmix.example", "/bob/by/streams/main/0.ts"}:            "bobby:bobby:/streams/main/0.ts",
+		{"vrmix.example", "/bobby/streams/main/0.ts"}:             "",
+	}
+
+	for request, expected := range cases {
+		if body := route(router, request[0], request[1]); body != expected {
+			t.Errorf("expected %q for %s%s, got %q", expected, request[0], request[1], body)
+		}
+	}
+
+	if !router.Remove("bob") {
+		t.Errorf("expected bob to be removed")
+	}
+
+	if body := route(router, "vrmix.example", "/bob/streams/main/playlist.m3u8"); body != "" {
+		t.Errorf("expected removed tenant to not be routed, got %q", body)
+	}
+}
+
+func TestTenantRouterConflict(t *testing.T) {
+	router := NewTenantRouter()
+
+	if err := router.Add(Tenant{Name: "alice", Hosts: []string{"alice.example"}, Handler: okHandler}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := router.Add(Tenant{Name: "other", Hosts: []string{"Alice.example"}, Handler: okHandler}); !errors.Is(err, ErrTenantConflict) {
+		t.Errorf("expected tenant conflict, got %v", err)
+	}
+
+	if err := router.Add(Tenant{Name: "empty", Handler: okHandler}); !errors.Is(err, ErrTenantRouteMissing) {
+		t.Errorf("expected missing route, got %v", err)
+	}
+}
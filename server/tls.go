@@ -0,0 +1,142 @@
+package server
+
+import (
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// ErrTLSConfigMissing indicates that neither a certificate pair nor ACME hosts were configured.
+var ErrTLSConfigMissing = errors.New("missing certificate files or ACME hosts")
+
+// TLSConfig represents the HTTPS configuration of a deployment, either a certificate pair reloaded when changed on disk or certificates managed through ACME.
+type TLSConfig struct {
+	CertFile string // Path to the PEM certificate chain
+	KeyFile  string // Path to the PEM private key
+
+	ACMEHosts        []string // Hostnames allowed to request certificates through ACME
+	ACMEEmail        string   // Contact email of the ACME account
+	ACMECacheDir     string   // Directory storing the account key and certificates between restarts
+	ACMEDirectoryURL string   // ACME directory, defaults to Let's Encrypt production
+}
+
+// CertificateManager provides certificates for the TLS handshakes and answers the HTTP-01 challenges.
+type CertificateManager interface {
+	GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error)
+	HTTPHandler(fallback http.Handler) http.Handler
+}
+
+// NewCertificateManager creates the certificate manager for the configuration, preferring the certificate pair when both are configured.
+func NewCertificateManager(config TLSConfig) (CertificateManager, error) {
+	if config.CertFile != "" && config.KeyFile != "" {
+		return NewCertReloader(config.CertFile, config.KeyFile)
+	}
+
+	if len(config.ACMEHosts) == 0 {
+		return nil, ErrTLSConfigMissing
+	}
+
+	manager := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(config.ACMEHosts...),
+		Email:      config.ACMEEmail,
+	}
+
+	if config.ACMECacheDir != "" {
+		manager.Cache = autocert.DirCache(config.ACMECacheDir)
+	}
+
+	if config.ACMEDirectoryURL != "" {
+		manager.Client = &acme.Client{DirectoryURL: config.ACMEDirectoryURL}
+	}
+
+	return manager, nil
+}
+
+// NewTLSConfig returns a TLS configuration using the certificate manager, accepting TLS-ALPN-01 challenges alongside HTTP/2 and HTTP/1.1.
+func NewTLSConfig(manager CertificateManager) *tls.Config {
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: manager.GetCertificate,
+		NextProtos:     []string{"h2", "http/1.1", acme.ALPNProto},
+	}
+}
+
+// CertReloader serves a certificate pair from disk, reloading it when the files change so renewed certificates are used without restarting.
+type CertReloader struct {
+	certFile string
+	keyFile  string
+
+	mutex       sync.Mutex
+	certificate *tls.Certificate
+	modTime     time.Time
+	lastCheck   time.Time
+	interval    time.Duration
+}
+
+// NewCertReloader creates a new CertReloader, failing if the certificate pair cannot be loaded.
+func NewCertReloader(certFile string, keyFile string) (*CertReloader, error) {
+	r := &CertReloader{certFile: certFile, keyFile: keyFile, interval: time.Minute}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// Reload loads the certificate pair from disk, keeping the current certificate if it fails.
+func (r *CertReloader) Reload() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.reload()
+}
+
+// reload loads the certificate pair from disk with the mutex held.
+func (r *CertReloader) reload() error {
+	info, err := os.Stat(r.certFile)
+	if err != nil {
+		return err
+	}
+
+	certificate, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+
+	r.certificate = &certificate
+	r.modTime = info.ModTime()
+	r.lastCheck = time.Now()
+	return nil
+}
+
+// GetCertificate returns the current certificate, reloading it if the certificate file changed since the last check.
+func (r *CertReloader) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if time.Since(r.lastCheck) >= r.interval {
+		r.lastCheck = time.Now()
+
+		if info, err := os.Stat(r.certFile); err == nil && !info.ModTime().Equal(r.modTime) {
+			r.reload()
+		}
+	}
+
+	return r.certificate, nil
+}
+
+// HTTPHandler returns the fallback handler, since certificate pairs do not need HTTP-01 challenges.
+func (r *CertReloader) HTTPHandler(fallback http.Handler) http.Handler {
+	if fallback == nil {
+		return http.NotFoundHandler()
+	}
+
+	return fallback
+}
@@ -0,0 +1,108 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeCertificate writes a self-signed certificate pair with the specified common name
+func writeCertificate(t *testing.T, certFile string, keyFile string, commonName string, modTime time.Time) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Chtimes(certFile, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// commonName returns the common name of the certificate served by the reloader
+func commonName(t *testing.T, r *CertReloader) string {
+	certificate, err := r.GetCertificate(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	leaf, err := x509.ParseCertificate(certificate.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return leaf.Subject.CommonName
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+
+	writeCertificate(t, certFile, keyFile, "first", time.Unix(1000, 0))
+
+	r, err := NewCertReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.interval = 0
+
+	if name := commonName(t, r); name != "first" {
+		t.Errorf("expected certificate first, got %s", name)
+	}
+
+	writeCertificate(t, certFile, keyFile, "second", time.Unix(2000, 0))
+
+	if name := commonName(t, r); name != "second" {
+		t.Errorf("expected reloaded certificate second, got %s", name)
+	}
+}
+
+func TestNewCertificateManager(t *testing.T) {
+	if _, err := NewCertificateManager(TLSConfig{}); !errors.Is(err, ErrTLSConfigMissing) {
+		t.Errorf("expected missing configuration, got %v", err)
+	}
+
+	manager, err := NewCertificateManager(TLSConfig{ACMEHosts: []string{"vrmix.example"}, ACMECacheDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := NewTLSConfig(manager)
+	if config.GetCertificate == nil {
+		t.Errorf("expected certificate callback to be set")
+	}
+}
@@ -0,0 +1,114 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+
+	"vrmix/signer"
+)
+
+// TracerName is the instrumentation scope of the spans started by the server, registered with otel.SetTracerProvider.
+const TracerName = "vrmix/server"
+
+// TracingConfig represents the configuration of the tracing middleware.
+type TracingConfig struct {
+	RedactParams []string // Query parameters redacted from the traced URL, defaults to the signed URL signature
+
+	// Session returns the session ID of the request, nil to omit it.
+	Session func(r *http.Request) string
+
+	// Channel returns the channel of the request, nil to omit it.
+	Channel func(r *http.Request) string
+}
+
+// startSpan starts an internal span of the server with the global tracer provider.
+func startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(TracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// lookupSpan records a cache lookup of the key as a span.
+func lookupSpan(ctx context.Context, key string, lookup func() (SegmentContent, bool)) (SegmentContent, bool) {
+	_, span := startSpan(ctx, "cache lookup", attribute.String("vrmix.cache.key", key))
+	segment, ok := lookup()
+	span.SetAttributes(attribute.Bool("vrmix.cache.hit", ok))
+	span.End()
+
+	return segment, ok
+}
+
+// endSpan records the error on the span, if any, and ends it.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
+
+// Tracing returns a middleware starting a server span for each request, continuing the trace propagated by the client with the global propagator, so the spans of the cache, the scheduler and the origin fetches are children of the request.
+func Tracing(config TracingConfig) Middleware {
+	if config.RedactParams == nil {
+		config.RedactParams = []string{signer.SignatureParam}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+
+			name := r.Method + " segment"
+			if isPlaylistRequest(r) {
+				name = r.Method + " playlist"
+			}
+
+			attrs := []attribute.KeyValue{
+				attribute.String("http.request.method", r.Method),
+				attribute.String("url.path", redactURL(r.URL, config.RedactParams)),
+				attribute.String("client.address", ClientIP(r)),
+			}
+
+			if config.Session != nil {
+				attrs = append(attrs, attribute.String("vrmix.session", config.Session(r)))
+			}
+
+			if config.Channel != nil {
+				attrs = append(attrs, attribute.String("vrmix.channel", config.Channel(r)))
+			}
+
+			ctx, span := otel.Tracer(TracerName).Start(ctx, name, trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(attrs...))
+			defer span.End()
+
+			recorder := &statusRecorder{ResponseWriter: w}
+			next.ServeHTTP(recorder, r.WithContext(ctx))
+
+			if recorder.status == 0 {
+				recorder.status = http.StatusOK
+			}
+
+			span.SetAttributes(attribute.Int("http.response.status_code", recorder.status), attribute.Int64("http.response.body.size", recorder.bytes))
+			if recorder.status >= 500 {
+				span.SetStatus(codes.Error, http.StatusText(recorder.status))
+			}
+		})
+	}
+}
+
+// injectTrace propagates the trace of the context to an outgoing request with the global propagator.
+func injectTrace(ctx context.Context, req *http.Request) {
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+}
+
+// originAttributes returns the attributes of an origin fetch of the URL.
+func originAttributes(u *url.URL) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("url.full", u.Scheme+"://"+u.Host+redactURL(u, []string{signer.SignatureParam})),
+		attribute.String("server.address", u.Host),
+	}
+}
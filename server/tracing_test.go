@@ -0,0 +1,67 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// recordSpans registers a global tracer provider recording the spans until the end of the test
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	provider, propagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(provider)
+		otel.SetTextMapPropagator(propagator)
+	})
+
+	return recorder
+}
+
+func TestTracingProxy(t *testing.T) {
+	recorder := recordSpans(t)
+
+	var traceparent string
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("Traceparent")
+		w.Write([]byte("segment"))
+	}))
+	defer origin.Close()
+
+	upstream, _ := url.Parse(origin.URL + "/live/index.m3u8")
+	proxy := &Proxy{Upstream: upstream, Prefix: "/proxy", Cache: &memoryProxyCache{segments: map[string][]byte{}}}
+	handler := Chain(proxy, Tracing(TracingConfig{Channel: func(r *http.Request) string { return "main" }}))
+
+	r := httptest.NewRequest(http.MethodGet, "/proxy/0.ts", nil)
+	r.Header.Set("Traceparent", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	spans := recorder.Ended()
+	names := map[string]bool{}
+	for _, span := range spans {
+		names[span.Name()] = true
+
+		if span.SpanContext().TraceID().String() != "0af7651916cd43dd8448eb211c80319c" {
+			t.Errorf("expected span %s to continue the client trace, got %s", span.Name(), span.SpanContext().TraceID())
+		}
+	}
+
+	for _, name := range []string{"GET segment", "cache lookup", "origin fetch"} {
+		if !names[name] {
+			t.Errorf("expected a %q span, got %v", name, names)
+		}
+	}
+
+	if len(traceparent) != 55 || traceparent[3:35] != "0af7651916cd43dd8448eb211c80319c" {
+		t.Errorf("expected the trace to be propagated to the origin, got %q", traceparent)
+	}
+}
@@ -0,0 +1,232 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// UsageConfig represents the configuration of the usage analytics.
+type UsageConfig struct {
+	Resolution  time.Duration // Width of the time buckets the usage is aggregated in, defaults to one minute
+	Retention   time.Duration // Age after which buckets are discarded, defaults to one day
+	IdleTimeout time.Duration // Time without requests after which a session stops being a viewer, defaults to 30 seconds
+
+	// Session returns the session ID of the request.
+	Session func(r *http.Request) string
+
+	// Channel returns the channel of the request.
+	Channel func(r *http.Request) string
+}
+
+// usageBucket is the usage of a channel during a bucket of time.
+type usageBucket struct {
+	start    time.Time
+	sessions map[string]struct{}
+	bytes    int64
+	watch    time.Duration
+	peak     int
+}
+
+// channelUsage is the usage of a channel, bucketed by time.
+type channelUsage struct {
+	buckets  []*usageBucket
+	lastSeen map[string]time.Time
+}
+
+// UsageWindow represents the usage of a channel during a window of time.
+type UsageWindow struct {
+	Start          time.Time `json:"start"`           // Start of the window
+	End            time.Time `json:"end"`             // End of the window
+	PeakViewers    int       `json:"peak_viewers"`    // Most concurrent viewers during the window
+	UniqueSessions int       `json:"unique_sessions"` // Distinct sessions seen during the window
+	EgressBytes    int64     `json:"egress_bytes"`    // Bytes delivered during the window
+	WatchTime      float64   `json:"watch_time"`      // Seconds watched by every session during the window
+}
+
+// ChannelUsage represents the usage of a channel over a period, split into windows.
+type ChannelUsage struct {
+	Viewers int           `json:"viewers"` // Concurrent viewers right now
+	Total   UsageWindow   `json:"total"`   // Usage over the whole period
+	Windows []UsageWindow `json:"windows"` // Usage of each window of the period, oldest first
+}
+
+// UsageAnalytics tracks the viewers, sessions, egress bytes and watch time of each channel over time, so operators know which channels are actually used.
+type UsageAnalytics struct {
+	config   UsageConfig
+	mutex    sync.Mutex
+	channels map[string]*channelUsage
+	now      func() time.Time
+}
+
+// NewUsageAnalytics creates a new UsageAnalytics with the specified configuration.
+func NewUsageAnalytics(config UsageConfig) *UsageAnalytics {
+	if config.Resolution <= 0 {
+		config.Resolution = time.Minute
+	}
+
+	if config.Retention <= 0 {
+		config.Retention = 24 * time.Hour
+	}
+
+	if config.IdleTimeout <= 0 {
+		config.IdleTimeout = 30 * time.Second
+	}
+
+	return &UsageAnalytics{config: config, channels: make(map[string]*channelUsage), now: time.Now}
+}
+
+// bucket returns the bucket of the channel holding the time, creating it and discarding expired buckets if needed.
+func (a *UsageAnalytics) bucket(usage *channelUsage, now time.Time) *usageBucket {
+	start := now.Truncate(a.config.Resolution)
+	if n := len(usage.buckets); n > 0 && usage.buckets[n-1].start.Equal(start) {
+		return usage.buckets[n-1]
+	}
+
+	expired := 0
+	for expired < len(usage.buckets) && now.Sub(usage.buckets[expired].start) > a.config.Retention {
+		expired++
+	}
+
+	bucket := &usageBucket{start: start, sessions: make(map[string]struct{})}
+	usage.buckets = append(usage.buckets[expired:], bucket)
+	return bucket
+}
+
+// viewers returns the sessions of the channel seen within the idle timeout, forgetting the others.
+func (a *UsageAnalytics) viewers(usage *channelUsage, now time.Time) int {
+	for session, lastSeen := range usage.lastSeen {
+		if now.Sub(lastSeen) > a.config.IdleTimeout {
+			delete(usage.lastSeen, session)
+		}
+	}
+
+	return len(usage.lastSeen)
+}
+
+// Record records a request of the session with the bytes delivered, counting the time since its previous request as watch time when it is still a viewer.
+func (a *UsageAnalytics) Record(session string, channel string, bytes int64) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	now := a.now()
+	usage, ok := a.channels[channel]
+	if !ok {
+		usage = &channelUsage{lastSeen: make(map[string]time.Time)}
+		a.channels[channel] = usage
+	}
+
+	bucket := a.bucket(usage, now)
+	bucket.sessions[session] = struct{}{}
+	bucket.bytes += bytes
+
+	if lastSeen, ok := usage.lastSeen[session]; ok && now.Sub(lastSeen) <= a.config.IdleTimeout {
+		bucket.watch += now.Sub(lastSeen)
+	}
+	usage.lastSeen[session] = now
+
+	bucket.peak = max(bucket.peak, a.viewers(usage, now))
+}
+
+// Usage returns the usage of every channel seen during the period ending now, split into windows of the step rounded to the resolution.
+func (a *UsageAnalytics) Usage(period time.Duration, step time.Duration) map[string]ChannelUsage {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	step = max(step.Truncate(a.config.Resolution), a.config.Resolution)
+	now := a.now()
+	end := now.Truncate(a.config.Resolution).Add(a.config.Resolution)
+	start := end.Add(-(period + step - 1) / step * step)
+
+	result := map[string]ChannelUsage{}
+	for channel, usage := range a.channels {
+		channelResult := ChannelUsage{Viewers: a.viewers(usage, now), Total: UsageWindow{Start: start, End: end}}
+		total := map[string]struct{}{}
+
+		for windowStart := start; windowStart.Before(end); windowStart = windowStart.Add(step) {
+			window := UsageWindow{Start: windowStart, End: windowStart.Add(step)}
+			sessions := map[string]struct{}{}
+
+			for _, bucket := range usage.buckets {
+				if bucket.start.Before(window.Start) || !bucket.start.Before(window.End) {
+					continue
+				}
+
+				for session := range bucket.sessions {
+					sessions[session] = struct{}{}
+					total[session] = struct{}{}
+				}
+
+				window.PeakViewers = max(window.PeakViewers, bucket.peak)
+				window.EgressBytes += bucket.bytes
+				window.WatchTime += bucket.watch.Seconds()
+			}
+
+			window.UniqueSessions = len(sessions)
+			channelResult.Windows = append(channelResult.Windows, window)
+
+			channelResult.Total.PeakViewers = max(channelResult.Total.PeakViewers, window.PeakViewers)
+			channelResult.Total.EgressBytes += window.EgressBytes
+			channelResult.Total.WatchTime += window.WatchTime
+		}
+
+		channelResult.Total.UniqueSessions = len(total)
+		if channelResult.Total.UniqueSessions > 0 || channelResult.Viewers > 0 {
+			result[channel] = channelResult
+		}
+	}
+
+	return result
+}
+
+// Middleware returns a middleware recording the successful requests of the sessions.
+func (a *UsageAnalytics) Middleware() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			recorder := &statusRecorder{ResponseWriter: w}
+			next.ServeHTTP(recorder, r)
+
+			if recorder.status >= 400 || a.config.Session == nil || a.config.Channel == nil {
+				return
+			}
+
+			if session := a.config.Session(r); session != "" {
+				a.Record(session, a.config.Channel(r), recorder.bytes)
+			}
+		})
+	}
+}
+
+// ServeHTTP writes the usage of every channel as JSON, over the "period" query parameter defaulting to one hour, split into windows of the "step" query parameter defaulting to the whole period, filtered by the "channel" query parameter.
+func (a *UsageAnalytics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	period, err := time.ParseDuration(query.Get("period"))
+	if query.Get("period") == "" {
+		period, err = time.Hour, nil
+	}
+
+	step, stepErr := time.ParseDuration(query.Get("step"))
+	if query.Get("step") == "" {
+		step, stepErr = period, nil
+	}
+
+	if err != nil || stepErr != nil || period <= 0 || step <= 0 || period > a.config.Retention || period/step > 1440 {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	usage := a.Usage(period, step)
+	if channel := query.Get("channel"); channel != "" {
+		filtered := map[string]ChannelUsage{}
+		if channelUsage, ok := usage[channel]; ok {
+			filtered[channel] = channelUsage
+		}
+		usage = filtered
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(usage)
+}
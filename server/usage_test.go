@@ -0,0 +1,81 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestUsageAnalytics(t *testing.T) {
+	now := time.Unix(3600, 0)
+	a := NewUsageAnalytics(UsageConfig{IdleTimeout: 10 * time.Second})
+	a.now = func() time.Time { return now }
+
+	a.Record("s1", "main", 1000)
+	a.Record("s2", "main", 500)
+	now = now.Add(4 * time.Second)
+	a.Record("s1", "main", 1000)
+
+	now = now.Add(2 * time.Minute)
+	a.Record("s1", "main", 1000)
+	a.Record("s3", "other", 200)
+
+	usage := a.Usage(5*time.Minute, time.Minute)
+	main := usage["main"]
+
+	if main.Viewers != 1 {
+		t.Errorf("expected 1 current viewer, got %d", main.Viewers)
+	}
+
+	if main.Total.PeakViewers != 2 || main.Total.UniqueSessions != 2 || main.Total.EgressBytes != 3500 || main.Total.WatchTime != 4 {
+		t.Errorf("expected peak 2, 2 sessions, 3500 bytes and 4 seconds, got %+v", main.Total)
+	}
+
+	if len(main.Windows) != 5 || main.Windows[2].UniqueSessions != 2 || main.Windows[4].UniqueSessions != 1 {
+		t.Errorf("expected 5 windows with the sessions in the third and last, got %+v", main.Windows)
+	}
+
+	if usage["other"].Total.EgressBytes != 200 {
+		t.Errorf("expected 200 bytes on the other channel, got %d", usage["other"].Total.EgressBytes)
+	}
+
+	now = now.Add(25 * time.Hour)
+	a.Record("s4", "main", 10)
+
+	if total := a.Usage(48*time.Hour, 48*time.Hour)["main"].Total; total.UniqueSessions != 1 {
+		t.Errorf("expected buckets past the retention to be discarded, got %d sessions", total.UniqueSessions)
+	}
+}
+
+func TestUsageAnalyticsHandler(t *testing.T) {
+	a := NewUsageAnalytics(UsageConfig{
+		Session: func(r *http.Request) string { return r.URL.Query().Get("session") },
+		Channel: func(r *http.Request) string { return "main" },
+	})
+
+	handler := a.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("0123456789"))
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/streams/main/0.ts?session=s1", nil))
+
+	w := httptest.NewRecorder()
+	a.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/usage?channel=main&period=10m&step=5m", nil))
+
+	var usage map[string]ChannelUsage
+	if err := json.NewDecoder(w.Body).Decode(&usage); err != nil {
+		t.Fatal(err)
+	}
+
+	if main := usage["main"]; main.Total.EgressBytes != 10 || len(main.Windows) != 2 {
+		t.Errorf("expected 10 bytes over 2 windows, got %+v", main)
+	}
+
+	w = httptest.NewRecorder()
+	a.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/usage?period=forever", nil))
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for an invalid period, got %d", w.Code)
+	}
+}
@@ -0,0 +1,175 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"vrmix/hls"
+)
+
+const (
+	// VersionParam is the query parameter a client can use to declare the maximum playlist version it supports.
+	VersionParam = "hls_version"
+
+	// FormatParam is the query parameter a client can use to declare the segment format it prefers, "ts" or "fmp4".
+	FormatParam = "hls_format"
+)
+
+const (
+	// byteRangeVersion is the playlist version introducing the EXT-X-BYTERANGE tag.
+	byteRangeVersion = 4
+
+	// mapVersion is the playlist version introducing the EXT-X-MAP tag outside of the I-frame playlists.
+	mapVersion = 6
+
+	// lowLatencyVersion is the playlist version of the delta updates introduced with Low-Latency HLS, the clients supporting a lower one predating its partial segments and gaps.
+	lowLatencyVersion = 9
+)
+
+// ErrUnsupportedPlaylist indicates that the playlist needs features the client does not support.
+var ErrUnsupportedPlaylist = errors.New("playlist unsupported by the client")
+
+// Capabilities represents the playlist features supported by a client.
+type Capabilities struct {
+	MaxVersion uint8 // Maximum playlist version supported, zero for no limit
+	FMP4       bool  // Indicates if the client plays fragmented MP4 segments, otherwise MPEG-TS is served
+}
+
+// VariationRule represents the capabilities of the clients matched by a rule.
+type VariationRule struct {
+	Name         string            // Name of the rule, used for diagnostics
+	UserAgent    *regexp.Regexp    // Pattern matched against the User-Agent header, nil to match any client
+	Query        map[string]string // Query parameters and values that must be present, nil to match any query
+	Capabilities Capabilities      // Capabilities of the matched clients
+}
+
+// matches returns true if the request matches the rule.
+func (rule *VariationRule) matches(r *http.Request) bool {
+	if rule.UserAgent != nil && !rule.UserAgent.MatchString(r.UserAgent()) {
+		return false
+	}
+
+	query := r.URL.Query()
+	for key, value := range rule.Query {
+		if query.Get(key) != value {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Variations selects the capabilities of each client from its User-Agent or explicit capability query parameters.
+type Variations struct {
+	Rules   []VariationRule // Rules evaluated in order, the first match wins
+	Default Capabilities    // Capabilities used when no rule matches
+}
+
+// DefaultVariations returns rules serving fragmented MP4 to Apple players, including Safari and visionOS, and MPEG-TS to everyone else.
+func DefaultVariations() Variations {
+	return Variations{
+		Rules: []VariationRule{
+			{
+				Name:         "apple",
+				UserAgent:    regexp.MustCompile(`AppleCoreMedia|visionOS|Version/[0-9.]+ (Mobile/\w+ )?Safari/`),
+				Capabilities: Capabilities{FMP4: true},
+			},
+		},
+	}
+}
+
+// Resolve returns the capabilities of the client, explicit query parameters taking precedence over the matched rule.
+func (v *Variations) Resolve(r *http.Request) Capabilities {
+	capabilities := v.Default
+	for _, rule := range v.Rules {
+		if rule.matches(r) {
+			capabilities = rule.Capabilities
+			break
+		}
+	}
+
+	query := r.URL.Query()
+
+	if version, err := strconv.ParseUint(query.Get(VersionParam), 10, 8); err == nil {
+		capabilities.MaxVersion = uint8(version)
+	}
+
+	switch query.Get(FormatParam) {
+	case "fmp4":
+		capabilities.FMP4 = true
+	case "ts":
+		capabilities.FMP4 = false
+	}
+
+	return capabilities
+}
+
+// Apply adapts the manifest to the capabilities, capping its version, or returns ErrUnsupportedPlaylist when the segments cannot be played by the client.
+//
+// The partial segments, preload hints and gaps of Low-Latency HLS are stripped below lowLatencyVersion, as the full segments play without them, while the initialization sections and byte ranges are rejected as the segments need them.
+func (c *Capabilities) Apply(m *hls.Manifest) error {
+	for _, group := range m.SegmentGroups {
+		if group.Map.URI == "" {
+			continue
+		}
+
+		if !c.FMP4 {
+			return fmt.Errorf("%w: fragmented MP4 segments", ErrUnsupportedPlaylist)
+		}
+
+		if c.MaxVersion > 0 && c.MaxVersion < mapVersion {
+			return fmt.Errorf("%w: the EXT-X-MAP tag", ErrUnsupportedPlaylist)
+		}
+	}
+
+	if c.MaxVersion > 0 && c.MaxVersion < byteRangeVersion {
+		for _, group := range m.SegmentGroups {
+			for _, segment := range group.Segments {
+				if segment.ByteRange.Length > 0 {
+					return fmt.Errorf("%w: the EXT-X-BYTERANGE tag", ErrUnsupportedPlaylist)
+				}
+			}
+		}
+	}
+
+	if c.MaxVersion > 0 && c.MaxVersion < lowLatencyVersion {
+		m.Parts, m.PreloadHints, m.PartTarget = nil, nil, 0
+		m.ServerControl.PartHoldBack = 0
+		for i := range m.SegmentGroups {
+			segments := m.SegmentGroups[i].Segments
+			for j := range segments {
+				segments[j].Parts, segments[j].Gap = nil, false
+			}
+		}
+	}
+
+	if c.MaxVersion > 0 && m.Version > c.MaxVersion {
+		m.Version = c.MaxVersion
+	}
+
+	return nil
+}
+
+// Adapt returns the media playlist adapted to the capabilities of the client, master playlists being returned as is, or ErrUnsupportedPlaylist.
+func (v *Variations) Adapt(r *http.Request, data []byte) ([]byte, error) {
+	text := string(data)
+	if strings.Contains(text, hls.StreamInfField) || strings.Contains(text, hls.IFrameStreamInfField) {
+		return data, nil
+	}
+
+	manifest, err := hls.ParseHlsManifest(strings.TrimRight(text, "\n"))
+	if err != nil {
+		return nil, err
+	}
+
+	capabilities := v.Resolve(r)
+	if err := capabilities.Apply(&manifest); err != nil {
+		return nil, err
+	}
+
+	return []byte(manifest.String()), nil
+}
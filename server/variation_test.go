@@ -0,0 +1,136 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"vrmix/hls"
+)
+
+// variationRequest creates a playlist request with the specified User-Agent and query
+func variationRequest(userAgent string, query string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/streams/main/playlist.m3u8"+query, nil)
+	r.Header.Set("User-Agent", userAgent)
+
+	return r
+}
+
+func TestVariationsResolve(t *testing.T) {
+	v := DefaultVariations()
+
+	if c := v.Resolve(variationRequest("AppleCoreMedia/1.0.0.21N305 (Apple Vision Pro; U; CPU OS 1_0 like Mac OS X; en_us)", "")); !c.FMP4 {
+		t.Errorf("expected fmp4 for visionOS")
+	}
+
+	if c := v.Resolve(variationRequest("Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Safari/605.1.15", "")); !c.FMP4 {
+		t.Errorf("expected fmp4 for Safari")
+	}
+
+	if c := v.Resolve(variationRequest("Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36", "")); c.FMP4 {
+		t.Errorf("expected ts for Chrome")
+	}
+
+	if c := v.Resolve(variationRequest("AppleCoreMedia/1.0.0", "?hls_format=ts&hls_version=3")); c.FMP4 || c.MaxVersion != 3 {
+		t.Errorf("expected query to override capabilities, got fmp4 %t and version %d", c.FMP4, c.MaxVersion)
+	}
+}
+
+func TestVariationsQueryRule(t *testing.T) {
+	v := Variations{
+		Rules: []VariationRule{
+			{Name: "legacy", Query: map[string]string{"player": "legacy"}, Capabilities: Capabilities{MaxVersion: 3}},
+			{Name: "vrchat", UserAgent: regexp.MustCompile(`UnityPlayer`), Capabilities: Capabilities{MaxVersion: 4}},
+		},
+		Default: Capabilities{MaxVersion: 6},
+	}
+
+	cases := map[*http.Request]uint8{
+		variationRequest("UnityPlayer/2022.3", "?player=legacy"): 3,
+		variationRequest("UnityPlayer/2022.3", ""):               4,
+		variationRequest("curl/8.0", ""):                         6,
+	}
+
+	for r, version := range cases {
+		c := v.Resolve(r)
+
+		m := hls.Manifest{Version: 7}
+		if err := c.Apply(&m); err != nil {
+			t.Fatal(err)
+		}
+
+		if m.Version != version {
+			t.Errorf("expected version %d for %s, got %d", version, r.URL, m.Version)
+		}
+	}
+}
+
+func TestCapabilitiesApply(t *testing.T) {
+	fmp4 := hls.Manifest{Version: 7, SegmentGroups: []hls.SegmentGroup{{Map: hls.Map{URI: "init.mp4"}, Segments: []hls.Segment{{Path: "0.m4s", Duration: 2}}}}}
+	for _, c := range []Capabilities{{}, {FMP4: true, MaxVersion: 5}} {
+		m := fmp4.Clone()
+		if err := c.Apply(&m); !errors.Is(err, ErrUnsupportedPlaylist) {
+			t.Errorf("expected the initialization section to be rejected for %+v, got %v", c, err)
+		}
+	}
+
+	m := fmp4.Clone()
+	if err := (&Capabilities{FMP4: true, MaxVersion: 6}).Apply(&m); err != nil || m.Version != 6 {
+		t.Errorf("expected fmp4 to play at version 6, got version %d and %v", m.Version, err)
+	}
+
+	ranged := hls.Manifest{Version: 4, SegmentGroups: []hls.SegmentGroup{{Segments: []hls.Segment{{Path: "all.ts", Duration: 2, ByteRange: hls.ByteRange{Length: 100}}}}}}
+	if err := (&Capabilities{MaxVersion: 3}).Apply(&ranged); !errors.Is(err, ErrUnsupportedPlaylist) {
+		t.Errorf("expected the byte ranges to be rejected, got %v", err)
+	}
+
+	lowLatency := hls.Manifest{
+		Version:       9,
+		PartTarget:    0.5,
+		ServerControl: hls.ServerControl{PartHoldBack: 1.5, HoldBack: 6},
+		SegmentGroups: []hls.SegmentGroup{{Segments: []hls.Segment{{Path: "0.ts", Duration: 2, Gap: true, Parts: []hls.Part{{URI: "0.0.ts", Duration: 0.5}}}}}},
+		Parts:         []hls.Part{{URI: "1.0.ts", Duration: 0.5}},
+		PreloadHints:  []hls.PreloadHint{{Type: "PART", URI: "1.1.ts"}},
+	}
+	if err := (&Capabilities{MaxVersion: 7}).Apply(&lowLatency); err != nil {
+		t.Fatal(err)
+	}
+
+	segment := lowLatency.SegmentGroups[0].Segments[0]
+	if lowLatency.Parts != nil || lowLatency.PreloadHints != nil || lowLatency.PartTarget != 0 || lowLatency.ServerControl.PartHoldBack != 0 || segment.Parts != nil || segment.Gap || lowLatency.Version != 7 {
+		t.Errorf("expected the low-latency features to be stripped, got %+v", lowLatency)
+	}
+
+	if lowLatency.ServerControl.HoldBack != 6 {
+		t.Errorf("expected the hold back to be kept, got %v", lowLatency.ServerControl.HoldBack)
+	}
+}
+
+// mapStreams serves a single stream of fragmented MP4 segments
+type mapStreams struct{}
+
+func (mapStreams) Playlist(ctx context.Context, id string) ([]byte, error) {
+	return []byte("#EXTM3U\n#EXT-X-VERSION:7\n#EXT-X-TARGETDURATION:2\n#EXT-X-MAP:URI=\"init.mp4\"\n#EXTINF:2,\n0.m4s\n"), nil
+}
+
+func (mapStreams) Segment(ctx context.Context, id string, name string) ([]byte, error) {
+	return nil, ErrSegmentNotFound
+}
+
+func TestStreamHandlerVariations(t *testing.T) {
+	variations := DefaultVariations()
+	h := &StreamHandler{Streams: mapStreams{}, Variations: &variations}
+
+	if w := serveStream(h, "/streams/main/playlist.m3u8?hls_format=ts", ""); w.Code != http.StatusNotAcceptable {
+		t.Errorf("expected the fmp4 playlist to be refused to a ts client, got %d", w.Code)
+	}
+
+	w := serveStream(h, "/streams/main/playlist.m3u8?hls_format=fmp4&hls_version=6", "")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "#EXT-X-VERSION:6\n") || !strings.Contains(w.Body.String(), "init.mp4") {
+		t.Errorf("expected the playlist capped to version 6, got %d %q", w.Code, w.Body.String())
+	}
+}
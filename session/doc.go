@@ -0,0 +1,2 @@
+// Package session tracks the playback of each client of the streams, its position and the segments it will request next, expiring the idle clients.
+package session
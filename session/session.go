@@ -0,0 +1,227 @@
+package session
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Config represents the configuration of a Manager.
+type Config struct {
+	IdleTimeout time.Duration // Time without requests after which a session expires, defaults to one minute
+	Ahead       int           // Segments after the last requested one in the playback window, defaults to 3
+}
+
+// Window represents the segments a client is playing or about to request, by media sequence number.
+type Window struct {
+	First int // Sequence number of the last requested segment
+	Last  int // Sequence number of the last segment expected next, equal to First plus Config.Ahead
+}
+
+// Contains returns true if the segment with the sequence number is in the window.
+func (w Window) Contains(sequence int) bool {
+	return sequence >= w.First && sequence <= w.Last
+}
+
+// Session represents the playback state of a client of a stream.
+type Session struct {
+	ID          string        // ID of the client, like the session query parameter or cookie
+	Stream      string        // ID of the stream played
+	Position    time.Duration // Media time at the start of the last requested segment
+	LastSegment int           // Sequence number of the last requested segment, -1 before the first one
+	Window      Window        // Playback window, zero before the first segment
+	Started     time.Time     // Time of the first request
+	LastSeen    time.Time     // Time of the last request
+}
+
+// HasSegment returns true if the client requested a segment.
+func (s *Session) HasSegment() bool {
+	return s.LastSegment >= 0
+}
+
+// Manager tracks the sessions of the clients, expiring them after the idle timeout.
+type Manager struct {
+	config      Config
+	mutex       sync.Mutex
+	sessions    map[string]*Session
+	subscribers map[int]func(Session)
+	nextID      int
+	now         func() time.Time
+}
+
+// New creates a new Manager.
+func New(config Config) *Manager {
+	if config.IdleTimeout <= 0 {
+		config.IdleTimeout = time.Minute
+	}
+
+	if config.Ahead <= 0 {
+		config.Ahead = 3
+	}
+
+	return &Manager{config: config, sessions: map[string]*Session{}, subscribers: map[int]func(Session){}, now: time.Now}
+}
+
+// Touch records a playlist request of the client, starting its session if needed, and returns the session.
+func (m *Manager) Touch(id string, stream string) Session {
+	m.mutex.Lock()
+	s, expired := m.session(id, stream)
+	session := *s
+	m.mutex.Unlock()
+
+	m.notify(expired)
+	return session
+}
+
+// RecordSegment records the request of the segment with the sequence number, starting at the position of the stream, moving the playback window of the session, and returns the session.
+func (m *Manager) RecordSegment(id string, stream string, sequence int, position time.Duration) Session {
+	m.mutex.Lock()
+	s, expired := m.session(id, stream)
+	s.LastSegment, s.Position = sequence, position
+	s.Window = Window{First: sequence, Last: sequence + m.config.Ahead}
+	session := *s
+	m.mutex.Unlock()
+
+	m.notify(expired)
+	return session
+}
+
+// session returns the live session of the client, replacing the one expired or of another stream, which is returned to be notified.
+func (m *Manager) session(id string, stream string) (*Session, []Session) {
+	now := m.now()
+
+	var expired []Session
+	s, ok := m.sessions[id]
+	if ok && (s.Stream != stream || m.idle(s, now)) {
+		expired = append(expired, *s)
+		ok = false
+	}
+
+	if !ok {
+		s = &Session{ID: id, Stream: stream, LastSegment: -1, Started: now}
+		m.sessions[id] = s
+	}
+
+	s.LastSeen = now
+	return s, expired
+}
+
+// idle returns true if the session received no request within the idle timeout.
+func (m *Manager) idle(s *Session, now time.Time) bool {
+	return now.Sub(s.LastSeen) > m.config.IdleTimeout
+}
+
+// Get returns the live session of the client.
+func (m *Manager) Get(id string) (Session, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	s, ok := m.sessions[id]
+	if !ok || m.idle(s, m.now()) {
+		return Session{}, false
+	}
+
+	return *s, true
+}
+
+// Sessions returns the live sessions of the stream sorted by ID, like the viewers whose windows are prefetched.
+func (m *Manager) Sessions(stream string) []Session {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	now := m.now()
+	var sessions []Session
+	for _, s := range m.sessions {
+		if s.Stream == stream && !m.idle(s, now) {
+			sessions = append(sessions, *s)
+		}
+	}
+
+	slices.SortFunc(sessions, func(a, b Session) int { return cmp.Compare(a.ID, b.ID) })
+	return sessions
+}
+
+// End ends the session of the client, like when it stops the playback, returning false if it has none.
+func (m *Manager) End(id string) bool {
+	m.mutex.Lock()
+	s, ok := m.sessions[id]
+	delete(m.sessions, id)
+	m.mutex.Unlock()
+
+	if ok {
+		m.notify([]Session{*s})
+	}
+
+	return ok
+}
+
+// Expire ends the idle sessions, returning how many expired.
+func (m *Manager) Expire() int {
+	m.mutex.Lock()
+	now := m.now()
+	var expired []Session
+	for id, s := range m.sessions {
+		if m.idle(s, now) {
+			expired = append(expired, *s)
+			delete(m.sessions, id)
+		}
+	}
+	m.mutex.Unlock()
+
+	m.notify(expired)
+	return len(expired)
+}
+
+// Run expires the idle sessions every half idle timeout until the context is done.
+func (m *Manager) Run(ctx context.Context) error {
+	ticker := time.NewTicker(m.config.IdleTimeout / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			m.Expire()
+		}
+	}
+}
+
+// Subscribe calls the function with each session ending, expired or ended, so the segments pinned in the cache for it can be released, returning the function unsubscribing it.
+func (m *Manager) Subscribe(subscriber func(Session)) func() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	id := m.nextID
+	m.nextID++
+	m.subscribers[id] = subscriber
+
+	return func() {
+		m.mutex.Lock()
+		defer m.mutex.Unlock()
+
+		delete(m.subscribers, id)
+	}
+}
+
+// notify calls the subscribers with the ended sessions, without holding the lock so they can use the manager.
+func (m *Manager) notify(ended []Session) {
+	if len(ended) == 0 {
+		return
+	}
+
+	m.mutex.Lock()
+	subscribers := make([]func(Session), 0, len(m.subscribers))
+	for _, subscriber := range m.subscribers {
+		subscribers = append(subscribers, subscriber)
+	}
+	m.mutex.Unlock()
+
+	for _, session := range ended {
+		for _, subscriber := range subscribers {
+			subscriber(session)
+		}
+	}
+}
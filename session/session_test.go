@@ -0,0 +1,123 @@
+package session
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+// newManager creates a manager with a controlled clock
+func newManager(config Config) (*Manager, *time.Time) {
+	m := New(config)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	m.now = func() time.Time { return now }
+	return m, &now
+}
+
+// subscribe records the IDs of the sessions ending
+func subscribe(m *Manager) *[]string {
+	var ended []string
+	m.Subscribe(func(s Session) {
+		ended = append(ended, s.ID)
+	})
+
+	return &ended
+}
+
+func TestRecordSegment(t *testing.T) {
+	m, now := newManager(Config{})
+
+	s := m.Touch("viewer", "channel")
+	if s.HasSegment() || s.Started != *now {
+		t.Errorf("expected a new session without segments, got %+v", s)
+	}
+
+	*now = now.Add(4 * time.Second)
+	s = m.RecordSegment("viewer", "channel", 10, 40*time.Second)
+	if s.LastSegment != 10 || s.Position != 40*time.Second || s.Window != (Window{10, 13}) || s.LastSeen != *now {
+		t.Errorf("expected the session to move to the segment, got %+v", s)
+	}
+
+	if !s.Window.Contains(12) || s.Window.Contains(14) || s.Window.Contains(9) {
+		t.Errorf("expected the window to hold the next segments, got %+v", s.Window)
+	}
+
+	if got, ok := m.Get("viewer"); !ok || got != s {
+		t.Errorf("expected the session, got %+v", got)
+	}
+
+	if _, ok := m.Get("other"); ok {
+		t.Error("expected no session for an unknown client")
+	}
+}
+
+func TestSessionsOfStream(t *testing.T) {
+	m, _ := newManager(Config{Ahead: 1})
+
+	m.RecordSegment("b", "channel", 5, 0)
+	m.RecordSegment("a", "channel", 2, 0)
+	m.Touch("c", "other")
+
+	sessions := m.Sessions("channel")
+	if len(sessions) != 2 || sessions[0].ID != "a" || sessions[1].ID != "b" || sessions[0].Window != (Window{2, 3}) {
+		t.Errorf("expected the sessions of the stream sorted by ID, got %+v", sessions)
+	}
+}
+
+func TestExpire(t *testing.T) {
+	m, now := newManager(Config{IdleTimeout: time.Minute})
+	ended := subscribe(m)
+
+	m.Touch("idle", "channel")
+	*now = now.Add(50 * time.Second)
+	m.Touch("active", "channel")
+
+	*now = now.Add(20 * time.Second)
+	if _, ok := m.Get("idle"); ok {
+		t.Error("expected the idle session to not be returned")
+	}
+
+	if sessions := m.Sessions("channel"); len(sessions) != 1 || sessions[0].ID != "active" {
+		t.Errorf("expected only the active session, got %+v", sessions)
+	}
+
+	if count := m.Expire(); count != 1 || !slices.Equal(*ended, []string{"idle"}) {
+		t.Errorf("expected the idle session to expire, got %d and %v", count, *ended)
+	}
+
+	if !m.End("active") || m.End("active") || !slices.Equal(*ended, []string{"idle", "active"}) {
+		t.Errorf("expected the ended session to be notified once, got %v", *ended)
+	}
+}
+
+func TestSessionReplaced(t *testing.T) {
+	m, now := newManager(Config{IdleTimeout: time.Minute})
+	ended := subscribe(m)
+
+	m.RecordSegment("viewer", "channel", 3, 0)
+	s := m.Touch("viewer", "other")
+	if s.Stream != "other" || s.HasSegment() || !slices.Equal(*ended, []string{"viewer"}) {
+		t.Errorf("expected the session of the previous stream to end, got %+v and %v", s, *ended)
+	}
+
+	// A client returning after the idle timeout starts a new session even if the expiration did not run.
+	*now = now.Add(2 * time.Minute)
+	s = m.Touch("viewer", "other")
+	if s.Started != *now || len(*ended) != 2 {
+		t.Errorf("expected a new session, got %+v and %v", s, *ended)
+	}
+}
+
+func TestUnsubscribe(t *testing.T) {
+	m, _ := newManager(Config{})
+
+	var ended int
+	unsubscribe := m.Subscribe(func(Session) { ended++ })
+	m.Touch("viewer", "channel")
+	unsubscribe()
+	m.End("viewer")
+
+	if ended != 0 {
+		t.Errorf("expected no notification after unsubscribing, got %d", ended)
+	}
+}
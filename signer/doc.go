@@ -0,0 +1,2 @@
+// Package signer contains types and functions to sign and verify expiring URLs handed to players.
+package signer
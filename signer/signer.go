@@ -0,0 +1,126 @@
+package signer
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	// ExpiresParam is the query parameter holding the expiration time of a signed URL as a Unix timestamp.
+	ExpiresParam = "exp"
+
+	// ChannelParam is the query parameter holding the channel a signed URL is bound to.
+	ChannelParam = "ch"
+
+	// IPBoundParam is the query parameter indicating that a signed URL is bound to the client IP.
+	IPBoundParam = "ipb"
+
+	// SignatureParam is the query parameter holding the signature of a signed URL.
+	SignatureParam = "sig"
+)
+
+var (
+	// ErrSignatureMissing indicates that the URL has no signature or expiration.
+	ErrSignatureMissing = errors.New("missing signature")
+
+	// ErrSignatureInvalid indicates that the signature does not match the URL.
+	ErrSignatureInvalid = errors.New("invalid signature")
+
+	// ErrSignatureExpired indicates that the signed URL has expired.
+	ErrSignatureExpired = errors.New("signature expired")
+
+	// ErrChannelMismatch indicates that the signed URL is bound to another channel.
+	ErrChannelMismatch = errors.New("signature bound to another channel")
+)
+
+// Params represents the restrictions carried by a signed URL.
+type Params struct {
+	Expires time.Time // Time after which the URL is no longer valid
+	Channel string    // Channel the URL is bound to, empty for any channel
+	IP      string    // Client IP the URL is bound to, empty for any client
+}
+
+// Signer signs and verifies URLs using HMAC-SHA256.
+type Signer struct {
+	secret []byte
+}
+
+// New creates a new Signer using the specified secret.
+func New(secret []byte) *Signer {
+	return &Signer{secret: secret}
+}
+
+// Sign returns a copy of the URL with the expiration, channel binding and signature added to its query.
+func (s *Signer) Sign(u *url.URL, p Params) *url.URL {
+	signed := *u
+	query := signed.Query()
+	query.Del(SignatureParam)
+
+	expires := strconv.FormatInt(p.Expires.Unix(), 10)
+	query.Set(ExpiresParam, expires)
+
+	query.Del(ChannelParam)
+	if p.Channel != "" {
+		query.Set(ChannelParam, p.Channel)
+	}
+
+	query.Del(IPBoundParam)
+	if p.IP != "" {
+		query.Set(IPBoundParam, "1")
+	}
+
+	query.Set(SignatureParam, s.signature(u.Path, expires, p.Channel, p.IP))
+	signed.RawQuery = query.Encode()
+
+	return &signed
+}
+
+// Verify checks that the URL has a valid signature, has not expired at now, is bound to the channel (if any) and was issued to the client IP (if bound).
+func (s *Signer) Verify(u *url.URL, channel string, ip string, now time.Time) error {
+	query := u.Query()
+
+	signature := query.Get(SignatureParam)
+	expires := query.Get(ExpiresParam)
+	if signature == "" || expires == "" {
+		return ErrSignatureMissing
+	}
+
+	boundChannel := query.Get(ChannelParam)
+	boundIP := ""
+	if query.Get(IPBoundParam) == "1" {
+		boundIP = ip
+	}
+
+	expected := s.signature(u.Path, expires, boundChannel, boundIP)
+	if !hmac.Equal([]byte(signature), []byte(expected)) {
+		return ErrSignatureInvalid
+	}
+
+	expiresUnix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return ErrSignatureInvalid
+	}
+
+	if now.Unix() > expiresUnix {
+		return ErrSignatureExpired
+	}
+
+	if boundChannel != "" && boundChannel != channel {
+		return ErrChannelMismatch
+	}
+
+	return nil
+}
+
+// signature computes the base64 encoded HMAC of the signed fields.
+func (s *Signer) signature(path string, expires string, channel string, ip string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(path + "\n" + expires + "\n" + channel + "\n" + ip))
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
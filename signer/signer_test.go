@@ -0,0 +1,78 @@
+package signer
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// signedURL signs a fixed segment URL with the specified params
+func signedURL(t *testing.T, s *Signer, p Params) *url.URL {
+	u, err := url.Parse("/streams/main/0.ts")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return s.Sign(u, p)
+}
+
+func TestSignVerify(t *testing.T) {
+	s := New([]byte("secret"))
+	now := time.Unix(1000, 0)
+
+	u := signedURL(t, s, Params{Expires: now.Add(time.Minute), Channel: "main"})
+
+	if err := s.Verify(u, "main", "10.0.0.1", now); err != nil {
+		t.Errorf("expected valid signature, got %v", err)
+	}
+
+	if err := s.Verify(u, "other", "10.0.0.1", now); !errors.Is(err, ErrChannelMismatch) {
+		t.Errorf("expected channel mismatch, got %v", err)
+	}
+
+	if err := s.Verify(u, "main", "10.0.0.1", now.Add(2*time.Minute)); !errors.Is(err, ErrSignatureExpired) {
+		t.Errorf("expected expired signature, got %v", err)
+	}
+
+	if err := New([]byte("other")).Verify(u, "main", "10.0.0.1", now); !errors.Is(err, ErrSignatureInvalid) {
+		t.Errorf("expected invalid signature, got %v", err)
+	}
+}
+
+func TestSignTampered(t *testing.T) {
+	s := New([]byte("secret"))
+	now := time.Unix(1000, 0)
+
+	u := signedURL(t, s, Params{Expires: now.Add(time.Minute)})
+
+	query := u.Query()
+	query.Set(ExpiresParam, "99999999999")
+	u.RawQuery = query.Encode()
+
+	if err := s.Verify(u, "main", "", now); !errors.Is(err, ErrSignatureInvalid) {
+		t.Errorf("expected invalid signature, got %v", err)
+	}
+
+	u.Path = "/streams/main/1.ts"
+	u.RawQuery = ""
+
+	if err := s.Verify(u, "main", "", now); !errors.Is(err, ErrSignatureMissing) {
+		t.Errorf("expected missing signature, got %v", err)
+	}
+}
+
+func TestSignIP(t *testing.T) {
+	s := New([]byte("secret"))
+	now := time.Unix(1000, 0)
+
+	u := signedURL(t, s, Params{Expires: now.Add(time.Minute), IP: "10.0.0.1"})
+
+	if err := s.Verify(u, "main", "10.0.0.1", now); err != nil {
+		t.Errorf("expected valid signature, got %v", err)
+	}
+
+	if err := s.Verify(u, "main", "10.0.0.2", now); !errors.Is(err, ErrSignatureInvalid) {
+		t.Errorf("expected invalid signature, got %v", err)
+	}
+}
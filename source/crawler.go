@@ -0,0 +1,387 @@
+package source
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"math"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"vrmix/logging"
+
+	"golang.org/x/net/html"
+)
+
+// listingDate matches the modification dates of nginx ("02-Jan-2024 10:00") and Apache ("2024-01-02 10:00") listings.
+var listingDate = regexp.MustCompile(`\d{2}-[A-Za-z]{3}-\d{4} \d{2}:\d{2}|\d{4}-\d{2}-\d{2} \d{2}:\d{2}`)
+
+// listingSize matches the sizes of the listings, in bytes or human readable like "12M".
+var listingSize = regexp.MustCompile(`(?:^|\s)(\d+(?:\.\d+)?)([KMGT]?)\s*$`)
+
+// listingLink is a link found in a directory listing.
+type listingLink struct {
+	href    string
+	size    int64
+	modTime time.Time
+}
+
+// jsonListingEntry is an entry of the nginx JSON listing format.
+type jsonListingEntry struct {
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	MTime string `json:"mtime"`
+	Size  int64  `json:"size"`
+}
+
+// CrawlerSource is a Source cataloging the media of HTTP directory listings, like Apache or nginx autoindex, packaging them lazily when queued.
+type CrawlerSource struct {
+	BaseURL    string       // URL of the root listing, like "https://files.example.com/media/"
+	Client     *http.Client // Client used to crawl and fetch, defaults to http.DefaultClient
+	Extensions []string     // File extensions cataloged, defaults to MediaExtensions
+	MaxDepth   int          // Levels of subdirectories crawled, defaults to 3
+	Packager   Packager     // Packager converting media URLs into HLS on first use, returning the playlist URI, nil to hand the media URL to the converter
+
+	// OnChange is called for each entry added, modified or removed when the catalog is refreshed.
+	OnChange func(kind ChangeKind, entry Entry)
+
+	// Logger receives the subdirectories skipped by the crawl, defaults to slog.Default.
+	Logger *slog.Logger
+
+	mutex    sync.RWMutex
+	catalog  map[string]Entry
+	packaged map[string]string
+}
+
+// base returns the base URL, ending with a slash.
+func (s *CrawlerSource) base() (*url.URL, error) {
+	return url.Parse(strings.TrimSuffix(s.BaseURL, "/") + "/")
+}
+
+// http returns the source fetching the listings and media.
+func (s *CrawlerSource) http() *HTTPSource {
+	return &HTTPSource{Client: s.Client}
+}
+
+// Refresh crawls the listings, updating the catalog and reporting the changes to OnChange, skipping subdirectories that fail to list.
+func (s *CrawlerSource) Refresh(ctx context.Context) error {
+	base, err := s.base()
+	if err != nil {
+		return err
+	}
+
+	maxDepth := s.MaxDepth
+	if maxDepth == 0 {
+		maxDepth = 3
+	}
+
+	extensions := s.Extensions
+	if extensions == nil {
+		extensions = MediaExtensions
+	}
+
+	catalog := map[string]Entry{}
+	visited := map[string]bool{}
+
+	var crawl func(dir *url.URL, depth int) error
+	crawl = func(dir *url.URL, depth int) error {
+		if visited[dir.String()] {
+			return nil
+		}
+		visited[dir.String()] = true
+
+		links, err := s.list(ctx, dir)
+		if err != nil {
+			return err
+		}
+
+		for _, link := range links {
+			target, err := dir.Parse(link.href)
+			if err != nil || target.RawQuery != "" || target.Host != base.Host || !strings.HasPrefix(target.Path, base.Path) || len(target.Path) <= len(dir.Path) {
+				continue
+			}
+			target.Fragment = ""
+
+			relative := strings.TrimPrefix(target.Path, base.Path)
+			if strings.HasPrefix(path.Base(relative), ".") {
+				continue
+			}
+
+			if strings.HasSuffix(target.Path, "/") {
+				if depth < maxDepth {
+					if err := crawl(target, depth+1); err != nil {
+						if ctx.Err() != nil {
+							return err
+						}
+
+						logging.Or(s.Logger).Warn("skipping directory", slog.String(logging.RefKey, target.String()), logging.Err(err))
+					}
+				}
+				continue
+			}
+
+			if !slices.Contains(extensions, strings.ToLower(path.Ext(relative))) {
+				continue
+			}
+
+			entry := Entry{ID: EntryID(relative), Path: relative, Size: link.size, ModTime: link.modTime}
+			catalog[entry.ID] = entry
+		}
+
+		return nil
+	}
+
+	if err := crawl(base, 0); err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	previous := s.catalog
+	s.catalog = catalog
+	s.mutex.Unlock()
+
+	diffCatalog(previous, catalog, s.OnChange)
+	return nil
+}
+
+// list fetches a listing, parsing it as nginx JSON or as HTML.
+func (s *CrawlerSource) list(ctx context.Context, dir *url.URL) ([]listingLink, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, dir.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.http().client().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &StatusError{StatusCode: resp.StatusCode}
+	}
+
+	body := io.LimitReader(resp.Body, 16<<20)
+
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType == "application/json" {
+		var entries []jsonListingEntry
+		if err := json.NewDecoder(body).Decode(&entries); err != nil {
+			return nil, err
+		}
+
+		links := make([]listingLink, 0, len(entries))
+		for _, entry := range entries {
+			href := url.PathEscape(entry.Name)
+			if entry.Type == "directory" {
+				href += "/"
+			}
+
+			modTime, _ := http.ParseTime(entry.MTime)
+			links = append(links, listingLink{href: href, size: entry.Size, modTime: modTime})
+		}
+
+		return links, nil
+	}
+
+	return parseListing(body)
+}
+
+// parseListing extracts the links of an HTML listing, with the size and date written after each of them.
+func parseListing(body io.Reader) ([]listingLink, error) {
+	var links []listingLink
+	var details strings.Builder
+
+	finish := func() {
+		if len(links) == 0 {
+			return
+		}
+
+		link := &links[len(links)-1]
+		text := strings.TrimSpace(details.String())
+		details.Reset()
+
+		if date := listingDate.FindString(text); date != "" {
+			for _, layout := range []string{"02-Jan-2006 15:04", "2006-01-02 15:04"} {
+				if modTime, err := time.Parse(layout, date); err == nil {
+					link.modTime = modTime
+				}
+			}
+		}
+
+		if match := listingSize.FindStringSubmatch(text); match != nil {
+			size, _ := strconv.ParseFloat(match[1], 64)
+			if match[2] != "" {
+				size *= math.Pow(1024, float64(strings.Index("KMGT", match[2])+1))
+			}
+			link.size = int64(size)
+		}
+	}
+
+	tokenizer := html.NewTokenizer(body)
+	inAnchor := false
+
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			finish()
+			if tokenizer.Err() == io.EOF {
+				return links, nil
+			}
+			return nil, tokenizer.Err()
+		case html.StartTagToken:
+			name, hasAttributes := tokenizer.TagName()
+			switch string(name) {
+			case "a":
+				finish()
+				inAnchor = true
+
+				for hasAttributes {
+					var key, value []byte
+					key, value, hasAttributes = tokenizer.TagAttr()
+					if string(key) == "href" {
+						links = append(links, listingLink{href: string(value)})
+					}
+				}
+			case "td":
+				details.WriteString(" ")
+			}
+		case html.EndTagToken:
+			name, _ := tokenizer.TagName()
+			switch string(name) {
+			case "a":
+				inAnchor = false
+			case "tr":
+				finish()
+			}
+		case html.TextToken:
+			if !inAnchor {
+				details.Write(tokenizer.Text())
+			}
+		}
+	}
+}
+
+// Entries returns the catalog sorted by path.
+func (s *CrawlerSource) Entries() []Entry {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return sortedEntries(s.catalog)
+}
+
+// url returns the URL of the reference, which may be a catalog ID, a path relative to the base URL or a URL under it.
+func (s *CrawlerSource) url(ref string) (string, error) {
+	base, err := s.base()
+	if err != nil {
+		return "", err
+	}
+
+	s.mutex.RLock()
+	entry, ok := s.catalog[ref]
+	s.mutex.RUnlock()
+
+	if ok {
+		return base.JoinPath(entry.Path).String(), nil
+	}
+
+	if !strings.Contains(ref, "://") {
+		return base.JoinPath(ref).String(), nil
+	}
+
+	if !strings.HasPrefix(ref, base.String()) {
+		return "", ErrUnsupported
+	}
+
+	return ref, nil
+}
+
+// Resolve resolves a catalog ID, relative path or URL, computing the duration of HLS playlists.
+func (s *CrawlerSource) Resolve(ctx context.Context, ref string) (Item, error) {
+	uri, err := s.url(ref)
+	if err != nil {
+		return Item{}, err
+	}
+
+	if strings.HasSuffix(strings.ToLower(uri), ".m3u8") {
+		return s.http().Resolve(ctx, uri)
+	}
+
+	u, err := url.Parse(uri)
+	if err != nil {
+		return Item{}, err
+	}
+
+	name := path.Base(u.Path)
+	return Item{Ref: uri, Title: strings.TrimSuffix(name, path.Ext(name))}, nil
+}
+
+// ListRenditions returns the renditions of HLS playlists, packaging other media on first use when a Packager is set.
+func (s *CrawlerSource) ListRenditions(ctx context.Context, item Item) ([]Rendition, error) {
+	uri, err := s.url(item.Ref)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.HasSuffix(strings.ToLower(uri), ".m3u8") {
+		return s.http().ListRenditions(ctx, Item{Ref: uri})
+	}
+
+	if s.Packager == nil {
+		return []Rendition{{URI: uri}}, nil
+	}
+
+	s.mutex.RLock()
+	playlist, ok := s.packaged[uri]
+	s.mutex.RUnlock()
+
+	if !ok {
+		if playlist, err = s.Packager.Package(ctx, uri); err != nil {
+			return nil, err
+		}
+
+		s.mutex.Lock()
+		if s.packaged == nil {
+			s.packaged = make(map[string]string)
+		}
+		s.packaged[uri] = playlist
+		s.mutex.Unlock()
+	}
+
+	return []Rendition{{URI: playlist}}, nil
+}
+
+// OpenSegment fetches a URL under the base URL or next to a playlist returned by the packager.
+func (s *CrawlerSource) OpenSegment(ctx context.Context, uri string) (io.ReadCloser, error) {
+	if _, err := s.url(uri); err != nil && !s.isPackaged(uri) {
+		return nil, err
+	}
+
+	return s.http().OpenSegment(ctx, uri)
+}
+
+// isPackaged checks if the HTTP URL is in the directory of a playlist returned by the packager.
+func (s *CrawlerSource) isPackaged(uri string) bool {
+	if !strings.HasPrefix(uri, "http://") && !strings.HasPrefix(uri, "https://") {
+		return false
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	for _, playlist := range s.packaged {
+		if strings.HasPrefix(uri, playlist[:strings.LastIndex(playlist, "/")+1]) {
+			return true
+		}
+	}
+
+	return false
+}
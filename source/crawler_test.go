@@ -0,0 +1,132 @@
+package source
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// nginxListing is an nginx autoindex page
+const nginxListing = `<html><head><title>Index of /media/</title></head><body><h1>Index of /media/</h1><hr><pre><a href="../">../</a>
+<a href="shows/">shows/</a>                                             02-Jan-2024 10:00                   -
+<a href="intro%20clip.mp4">intro clip.mp4</a>                                     02-Jan-2024 10:00             1048576
+<a href="notes.txt">notes.txt</a>                                          02-Jan-2024 10:00                  12
+<a href="https://elsewhere.example/x.mp4">elsewhere</a>
+</pre><hr></body></html>`
+
+// apacheListing is an Apache fancy index page
+const apacheListing = `<table><tr><th><a href="?C=N;O=D">Name</a></th><th><a href="?C=M;O=A">Last modified</a></th><th><a href="?C=S;O=A">Size</a></th></tr>
+<tr><td><a href="/media/">Parent Directory</a></td><td>&nbsp;</td><td align="right">  - </td></tr>
+<tr><td><a href="pilot.m3u8">pilot.m3u8</a></td><td align="right">2024-03-04 05:06  </td><td align="right">1.5K</td></tr>
+<tr><td><a href="pilot.mkv">pilot.mkv</a></td><td align="right">2024-03-04 05:06  </td><td align="right">2.0M</td></tr>
+</table>`
+
+// newListingServer starts a server with an nginx listing at /media/, an Apache listing at /media/shows/ and a JSON listing at /media/shows/extras/
+func newListingServer(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/media/":
+			io.WriteString(w, nginxListing)
+		case "/media/shows/":
+			io.WriteString(w, strings.Replace(apacheListing, "</table>", `<tr><td><a href="extras/">extras/</a></td></tr></table>`, 1))
+		case "/media/shows/extras/":
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, `[{"name":"bonus.webm","type":"file","mtime":"Tue, 02 Jan 2024 10:00:00 GMT","size":42},{"name":"deeper","type":"directory"}]`)
+		case "/media/shows/pilot.m3u8":
+			io.WriteString(w, "#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-TARGETDURATION:4\n#EXTINF:4.0,\nseg0.ts\n#EXT-X-ENDLIST\n")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestCrawlerSourceRefresh(t *testing.T) {
+	server := newListingServer(t)
+
+	changes := 0
+	s := &CrawlerSource{BaseURL: server.URL + "/media", MaxDepth: 2, OnChange: func(kind ChangeKind, entry Entry) { changes += 1 }}
+	if err := s.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := s.Entries()
+	paths := []string{}
+	for _, entry := range entries {
+		paths = append(paths, entry.Path)
+	}
+
+	if strings.Join(paths, ",") != "intro clip.mp4,shows/extras/bonus.webm,shows/pilot.m3u8,shows/pilot.mkv" {
+		t.Fatalf("expected the media of every listing, got %v", paths)
+	}
+
+	if entries[0].Size != 1048576 || entries[0].ModTime.Day() != 2 {
+		t.Errorf("expected the nginx size and date, got %+v", entries[0])
+	}
+
+	if entries[1].Size != 42 || entries[1].ModTime.Year() != 2024 {
+		t.Errorf("expected the JSON size and date, got %+v", entries[1])
+	}
+
+	if entries[3].Size != 2<<20 || entries[3].ModTime.Month() != 3 {
+		t.Errorf("expected the Apache size and date, got %+v", entries[3])
+	}
+
+	if changes != 4 {
+		t.Errorf("expected 4 added entries, got %d", changes)
+	}
+}
+
+// urlPackager pretends to package media next to them
+type urlPackager struct {
+	calls int
+}
+
+func (p *urlPackager) Package(ctx context.Context, uri string) (string, error) {
+	p.calls += 1
+	return uri + ".hls/index.m3u8", nil
+}
+
+func TestCrawlerSourceResolve(t *testing.T) {
+	server := newListingServer(t)
+	packager := &urlPackager{}
+	s := &CrawlerSource{BaseURL: server.URL + "/media/", Packager: packager}
+
+	if err := s.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	item, err := s.Resolve(context.Background(), EntryID("shows/pilot.m3u8"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if item.Duration != 4 {
+		t.Errorf("expected the playlist duration, got %+v", item)
+	}
+
+	item, err = s.Resolve(context.Background(), "shows/pilot.mkv")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for range 2 {
+		renditions, err := s.ListRenditions(context.Background(), item)
+		if err != nil || len(renditions) != 1 || renditions[0].URI != server.URL+"/media/shows/pilot.mkv.hls/index.m3u8" {
+			t.Errorf("expected the packaged playlist, got %v and %v", renditions, err)
+		}
+	}
+
+	if packager.calls != 1 {
+		t.Errorf("expected the media to be packaged once, got %d", packager.calls)
+	}
+
+	if _, err := s.Resolve(context.Background(), "https://elsewhere.example/x.mp4"); err != ErrUnsupported {
+		t.Errorf("expected ErrUnsupported outside the base URL, got %v", err)
+	}
+}
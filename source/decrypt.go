@@ -0,0 +1,204 @@
+package source
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+
+	"vrmix/encrypt"
+	"vrmix/hls"
+)
+
+// Decrypting is a Source decrypting the segments of the media protected with AES-128, so they are cached and served clear, or encrypted again with the keys of VRMix by the output encryption.
+type Decrypting struct {
+	Source Source // Source of the protected media, its credentials giving access to the keys
+
+	// Keys returns the key with the URI when it is known ahead, like a key given by the user, nil to fetch every key through the source.
+	Keys func(uri string) ([]byte, bool)
+
+	mutex     sync.Mutex
+	playlists map[string]*protectedPlaylist
+}
+
+// protectedPlaylist is the state of a media playlist opened through a Decrypting.
+type protectedPlaylist struct {
+	segments map[string]segmentKey // Key of the protected segments, by URI
+	keys     map[string][]byte     // Keys fetched, by URI
+}
+
+// segmentKey is the key and the IV decrypting a segment.
+type segmentKey struct {
+	uri string
+	iv  []byte
+}
+
+// Resolve resolves the reference, reporting AES-128 media as clear.
+func (d *Decrypting) Resolve(ctx context.Context, ref string) (Item, error) {
+	item, err := d.Source.Resolve(ctx, ref)
+	if item.Encryption == hls.MethodAES128 {
+		item.Encryption = hls.MethodNone
+	}
+
+	return item, err
+}
+
+// ListRenditions lists the renditions of the media.
+func (d *Decrypting) ListRenditions(ctx context.Context, item Item) ([]Rendition, error) {
+	return d.Source.ListRenditions(ctx, item)
+}
+
+// OpenSegment opens the resource, removing the AES-128 keys from the media playlists and decrypting the segments they protected.
+func (d *Decrypting) OpenSegment(ctx context.Context, uri string) (io.ReadCloser, error) {
+	body, err := d.Source.OpenSegment(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+
+	if u, err := url.Parse(uri); err == nil && path.Ext(u.Path) == ".m3u8" {
+		return d.playlist(uri, body)
+	}
+
+	d.mutex.Lock()
+	var key segmentKey
+	var found bool
+	var playlist *protectedPlaylist
+	for _, p := range d.playlists {
+		if key, found = p.segments[uri]; found {
+			playlist = p
+			break
+		}
+	}
+	d.mutex.Unlock()
+
+	if !found {
+		return body, nil
+	}
+
+	data, err := io.ReadAll(body)
+	body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	contentKey, err := d.key(ctx, playlist, key.uri)
+	if err != nil {
+		return nil, err
+	}
+
+	decrypted, err := encrypt.Decrypt(contentKey, key.iv, data)
+	if err != nil {
+		return nil, err
+	}
+
+	return io.NopCloser(bytes.NewReader(decrypted)), nil
+}
+
+// playlist records the keys of the segments of the media playlist, returning it without its AES-128 keys.
+func (d *Decrypting) playlist(uri string, body io.ReadCloser) (io.ReadCloser, error) {
+	data, err := io.ReadAll(body)
+	body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	base, err := url.Parse(uri)
+	if err != nil || bytes.Contains(data, []byte(hls.StreamInfField)) {
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+
+	manifest, err := hls.ParseHlsManifest(strings.TrimRight(string(data), "\n"))
+	if err != nil {
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+
+	manifest.PinIVs()
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	// The keys still referenced are kept, so a live playlist does not fetch its key on every refresh.
+	previous := d.playlists[uri]
+	current := &protectedPlaylist{segments: make(map[string]segmentKey), keys: make(map[string][]byte)}
+	for i := range manifest.SegmentGroups {
+		segments := manifest.SegmentGroups[i].Segments
+		for j := range segments {
+			k := slices.IndexFunc(segments[j].Keys, func(k hls.Key) bool { return k.Method == hls.MethodAES128 })
+			if k < 0 {
+				continue
+			}
+
+			key := segments[j].Keys[k]
+			segmentURI, err := base.Parse(segments[j].Path)
+			if err != nil {
+				continue
+			}
+
+			keyURI, err := base.Parse(key.URI)
+			if err != nil {
+				continue
+			}
+
+			iv, err := hls.ParseIV(key.IV)
+			if err != nil {
+				continue
+			}
+
+			current.segments[segmentURI.String()] = segmentKey{uri: keyURI.String(), iv: iv}
+			if previous != nil && previous.keys[keyURI.String()] != nil {
+				current.keys[keyURI.String()] = previous.keys[keyURI.String()]
+			}
+
+			segments[j].Keys = slices.Delete(slices.Clone(segments[j].Keys), k, k+1)
+		}
+	}
+
+	if d.playlists == nil {
+		d.playlists = make(map[string]*protectedPlaylist)
+	}
+
+	d.playlists[uri] = current
+	return io.NopCloser(strings.NewReader(manifest.String())), nil
+}
+
+// key returns the key with the URI, fetching it through the source when it is not known ahead.
+func (d *Decrypting) key(ctx context.Context, playlist *protectedPlaylist, uri string) ([]byte, error) {
+	if d.Keys != nil {
+		if key, ok := d.Keys(uri); ok {
+			return key, nil
+		}
+	}
+
+	d.mutex.Lock()
+	key := playlist.keys[uri]
+	d.mutex.Unlock()
+
+	if key != nil {
+		return key, nil
+	}
+
+	body, err := d.Source.OpenSegment(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	key, err = io.ReadAll(io.LimitReader(body, encrypt.KeySize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(key) != encrypt.KeySize {
+		return nil, encrypt.ErrInvalidKey
+	}
+
+	d.mutex.Lock()
+	playlist.keys[uri] = key
+	d.mutex.Unlock()
+
+	return key, nil
+}
@@ -0,0 +1,79 @@
+package source
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"vrmix/encrypt"
+	"vrmix/hls"
+)
+
+func TestDecrypting(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, encrypt.KeySize)
+	segment, _ := encrypt.Encrypt(key, hls.SequenceIV(5), []byte("clear segment"))
+
+	var keyFetches atomic.Int32
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/protected.m3u8":
+			w.Write([]byte("#EXTM3U\n#EXT-X-TARGETDURATION:4\n#EXT-X-MEDIA-SEQUENCE:5\n#EXT-X-KEY:METHOD=AES-128,URI=\"key.bin\"\n#EXTINF:4,\n5.ts\n#EXT-X-ENDLIST\n"))
+		case "/key.bin":
+			keyFetches.Add(1)
+			w.Write(key)
+		case "/5.ts":
+			w.Write(segment)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer origin.Close()
+
+	d := &Decrypting{Source: &HTTPSource{Client: origin.Client()}}
+	ctx := context.Background()
+
+	item, err := d.Resolve(ctx, origin.URL+"/protected.m3u8")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if item.Encryption != hls.MethodNone {
+		t.Errorf("expected the media to be reported clear, got %s", item.Encryption)
+	}
+
+	body, err := d.OpenSegment(ctx, origin.URL+"/protected.m3u8")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	playlist, _ := io.ReadAll(body)
+	if strings.Contains(string(playlist), hls.KeyField) {
+		t.Errorf("expected the AES-128 key to be removed, got %s", playlist)
+	}
+
+	for range 2 {
+		body, err = d.OpenSegment(ctx, origin.URL+"/5.ts")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if data, _ := io.ReadAll(body); string(data) != "clear segment" {
+			t.Errorf("expected the decrypted segment, got %q", data)
+		}
+	}
+
+	if keyFetches.Load() != 1 {
+		t.Errorf("expected the key to be fetched once, got %d", keyFetches.Load())
+	}
+
+	known := &Decrypting{Source: &HTTPSource{Client: origin.Client()}, Keys: func(uri string) ([]byte, bool) { return bytes.Repeat([]byte{1}, encrypt.KeySize), true }}
+	known.OpenSegment(ctx, origin.URL+"/protected.m3u8")
+	if _, err := known.OpenSegment(ctx, origin.URL+"/5.ts"); err == nil {
+		t.Error("expected the key given ahead to be used")
+	}
+}
@@ -0,0 +1,2 @@
+// Package source contains the abstraction over the origins VRMix mixes media from, with HTTP HLS origins as the default implementation.
+package source
@@ -0,0 +1,271 @@
+package source
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"vrmix/hls"
+	"vrmix/logging"
+	"vrmix/report"
+)
+
+var (
+	// ErrChainExhausted indicates that every reference of a failover chain failed.
+	ErrChainExhausted = errors.New("every reference of the chain failed")
+
+	// ErrStalled indicates that a live playlist stopped receiving new segments.
+	ErrStalled = errors.New("playlist stalled")
+)
+
+// Failover is a live media playlist following the first healthy reference of a chain, switching to the next one with a discontinuity when it fails mid-stream.
+type Failover struct {
+	Source        Source        // Source resolving the references and opening their segments, like a Multi
+	Refs          []string      // References tried in order, like a primary HLS URL, a backup URL and a local file
+	CheckInterval time.Duration // Interval between the health checks of the active playlist, defaults to its target duration
+	MaxFailures   int           // Consecutive failed health checks before failing over, defaults to 3
+	StallTimeout  time.Duration // Time a live playlist may go without new segments before failing over, defaults to 3 target durations
+	ListSize      int           // Segments kept in the playlist, defaults to 6
+
+	// OnFailover is called when a reference fails, with the next reference tried or empty when the chain is exhausted.
+	OnFailover func(from string, to string, err error)
+
+	// Logger receives the failovers, defaults to slog.Default.
+	Logger *slog.Logger
+
+	// Reporter receives the exhaustion of the chain, nil to not report it.
+	Reporter report.Reporter
+
+	mutex    sync.RWMutex
+	manifest hls.Manifest
+	active   string
+	split    bool
+}
+
+// chainLink is the state of the reference being followed.
+type chainLink struct {
+	ref      string    // Reference being followed
+	playlist *url.URL  // URI of its media playlist
+	next     int64     // Media sequence of the next segment to append, negative before the first check
+	started  time.Time // Time the reference started being followed
+	released float64   // Duration of the segments appended, used to pace VOD playlists
+	lastNew  time.Time // Time of the last segment appended
+	failures int       // Consecutive failed health checks
+}
+
+// Playlist returns the live media playlist, with segment URIs opened through OpenSegment.
+func (f *Failover) Playlist() string {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+
+	return f.manifest.String()
+}
+
+// Active returns the reference being followed, empty when none is.
+func (f *Failover) Active() string {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+
+	return f.active
+}
+
+// OpenSegment opens a segment of the playlist through the source.
+func (f *Failover) OpenSegment(ctx context.Context, uri string) (io.ReadCloser, error) {
+	return f.Source.OpenSegment(ctx, uri)
+}
+
+// Run follows the chain until the media ends or the context is done, returning ErrChainExhausted when every reference failed.
+func (f *Failover) Run(ctx context.Context) error {
+	defer f.finish()
+
+	for i, ref := range f.Refs {
+		f.mutex.Lock()
+		f.active = ref
+		f.mutex.Unlock()
+
+		link, err := f.open(ctx, ref)
+		if err == nil {
+			err = f.follow(ctx, link)
+		}
+
+		if err == nil || ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		next := ""
+		if i+1 < len(f.Refs) {
+			next = f.Refs[i+1]
+		}
+
+		logging.Or(f.Logger).Warn("failing over", slog.String(logging.RefKey, ref), slog.String("next", next), logging.Err(err))
+		if f.OnFailover != nil {
+			f.OnFailover(ref, next, err)
+		}
+
+		f.mutex.Lock()
+		f.split = f.manifest.SegmentCount() > 0
+		f.mutex.Unlock()
+	}
+
+	report.Error(ctx, f.Reporter, "source", ErrChainExhausted, map[string]string{"refs": strings.Join(f.Refs, " ")})
+	return ErrChainExhausted
+}
+
+// finish ends the playlist, as nothing else will be appended.
+func (f *Failover) finish() {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.active = ""
+	f.manifest.HasEndList = true
+}
+
+// open resolves the reference, returning the link to its first rendition.
+func (f *Failover) open(ctx context.Context, ref string) (*chainLink, error) {
+	item, err := f.Source.Resolve(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	renditions, err := f.Source.ListRenditions(ctx, item)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(renditions) == 0 {
+		return nil, ErrNotFound
+	}
+
+	playlist, err := url.Parse(renditions[0].URI)
+	if err != nil {
+		return nil, err
+	}
+
+	return &chainLink{ref: ref, playlist: playlist, next: -1, started: time.Now(), lastNew: time.Now()}, nil
+}
+
+// fetch downloads and parses the media playlist of the link.
+func (f *Failover) fetch(ctx context.Context, link *chainLink) (hls.Manifest, error) {
+	body, err := f.Source.OpenSegment(ctx, link.playlist.String())
+	if err != nil {
+		return hls.Manifest{}, err
+	}
+	defer body.Close()
+
+	return hls.ParseHlsManifestReader(body)
+}
+
+// follow appends the segments of the link until its media ends, returning an error when it fails its health checks or stalls.
+func (f *Failover) follow(ctx context.Context, link *chainLink) error {
+	maxFailures := f.MaxFailures
+	if maxFailures <= 0 {
+		maxFailures = 3
+	}
+
+	for {
+		manifest, err := f.fetch(ctx, link)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			if link.failures++; link.failures >= maxFailures {
+				return err
+			}
+		} else {
+			link.failures = 0
+
+			if ended := f.append(link, manifest); ended {
+				return nil
+			}
+
+			stallTimeout := f.StallTimeout
+			if stallTimeout <= 0 {
+				stallTimeout = 3 * time.Duration(max(manifest.TargetDuration, 1)) * time.Second
+			}
+
+			if !manifest.HasEndList && time.Since(link.lastNew) > stallTimeout {
+				return ErrStalled
+			}
+		}
+
+		interval := f.CheckInterval
+		if interval <= 0 {
+			interval = time.Duration(max(manifest.TargetDuration, 1)) * time.Second
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+// append appends the new segments of the manifest, pacing VOD playlists in real time, returning true when the media ended.
+func (f *Failover) append(link *chainLink, manifest hls.Manifest) bool {
+	manifest.PinIVs()
+	manifest.ResolveAgainst(link.playlist)
+
+	sequence := int64(manifest.MediaSequence)
+	var segments []hls.Segment
+	var maps []hls.Map // initialization section of each segment
+	for _, group := range manifest.SegmentGroups {
+		segments = append(segments, group.Segments...)
+		for range group.Segments {
+			maps = append(maps, group.Map)
+		}
+	}
+
+	if link.next < 0 {
+		link.next = sequence
+		if !manifest.HasEndList && len(segments) > 0 {
+			link.next = sequence + int64(len(segments)) - 1
+		}
+	}
+
+	listSize := f.ListSize
+	if listSize <= 0 {
+		listSize = 6
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	for i, segment := range segments {
+		if sequence+int64(i) < link.next {
+			continue
+		}
+
+		if manifest.HasEndList && link.released > time.Since(link.started).Seconds() {
+			return false
+		}
+
+		// The segments of another reference start a group after a discontinuity, and the ones of another initialization section a continuous group.
+		groups := f.manifest.SegmentGroups
+		if f.split || len(groups) == 0 || groups[len(groups)-1].Map != maps[i] {
+			f.manifest.SegmentGroups = append(f.manifest.SegmentGroups, hls.SegmentGroup{Map: maps[i], Continuous: !f.split && len(groups) > 0})
+			f.split = false
+		}
+
+		last := &f.manifest.SegmentGroups[len(f.manifest.SegmentGroups)-1]
+		last.Segments = append(last.Segments, segment)
+
+		f.manifest.Version = max(f.manifest.Version, manifest.Version, 3)
+		f.manifest.TargetDuration = max(f.manifest.TargetDuration, segment.TargetDuration())
+		if count := f.manifest.SegmentCount(); count > listSize {
+			f.manifest.RemoveFromStart(count - listSize)
+		}
+
+		link.next = sequence + int64(i) + 1
+		link.released += float64(segment.Duration)
+		link.lastNew = time.Now()
+	}
+
+	return manifest.HasEndList && link.next >= sequence+int64(len(segments))
+}
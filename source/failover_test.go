@@ -0,0 +1,143 @@
+package source
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"vrmix/hls"
+)
+
+// newLiveOrigin starts an origin serving a live playlist advancing one segment per request, failing once dead is set
+func newLiveOrigin(t *testing.T, dead *atomic.Bool) *httptest.Server {
+	var sequence atomic.Int64
+
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if dead.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		n := sequence.Add(1)
+		fmt.Fprintf(w, "#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-TARGETDURATION:1\n#EXT-X-MEDIA-SEQUENCE:%d\n#EXTINF:1,\nlive%d.ts\n#EXTINF:1,\nlive%d.ts\n", n, n, n+1)
+	}))
+	t.Cleanup(origin.Close)
+
+	return origin
+}
+
+// newVODOrigin starts an origin serving a short VOD playlist
+func newVODOrigin(t *testing.T) *httptest.Server {
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-TARGETDURATION:1\n#EXTINF:0.01,\nvod0.ts\n#EXTINF:0.01,\nvod1.ts\n#EXT-X-ENDLIST\n"))
+	}))
+	t.Cleanup(origin.Close)
+
+	return origin
+}
+
+func TestFailover(t *testing.T) {
+	var dead atomic.Bool
+	primary := newLiveOrigin(t, &dead)
+	backup := newVODOrigin(t)
+
+	var failovers []string
+	f := &Failover{
+		Source:        Multi{&HTTPSource{}},
+		Refs:          []string{primary.URL + "/live.m3u8", backup.URL + "/vod.m3u8"},
+		CheckInterval: 10 * time.Millisecond,
+		MaxFailures:   2,
+		OnFailover: func(from string, to string, err error) {
+			failovers = append(failovers, from+" -> "+to)
+		},
+	}
+
+	done := make(chan error)
+	go func() { done <- f.Run(context.Background()) }()
+
+	for !strings.Contains(f.Playlist(), "live6.ts") {
+		time.Sleep(5 * time.Millisecond)
+	}
+	dead.Store(true)
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the chain to end with the backup")
+	}
+
+	playlist := f.Playlist()
+	if !strings.Contains(playlist, "#EXT-DISCONTINUITY\n#EXTINF:0.01,\n"+backup.URL+"/vod0.ts\n") || !strings.HasSuffix(playlist, backup.URL+"/vod1.ts\n#EXT-X-ENDLIST\n") {
+		t.Errorf("expected the backup after a discontinuity, got %s", playlist)
+	}
+
+	if len(failovers) != 1 || failovers[0] != primary.URL+"/live.m3u8 -> "+backup.URL+"/vod.m3u8" {
+		t.Errorf("expected a single failover to the backup, got %v", failovers)
+	}
+}
+
+func TestFailoverExhausted(t *testing.T) {
+	var dead atomic.Bool
+	dead.Store(true)
+	primary := newLiveOrigin(t, &dead)
+
+	f := &Failover{
+		Source:        Multi{&FileSource{Root: t.TempDir()}, &HTTPSource{}},
+		Refs:          []string{primary.URL + "/live.m3u8", "missing.mp4"},
+		CheckInterval: time.Millisecond,
+	}
+
+	if err := f.Run(context.Background()); !errors.Is(err, ErrChainExhausted) {
+		t.Errorf("expected ErrChainExhausted, got %v", err)
+	}
+
+	if f.Active() != "" {
+		t.Errorf("expected no active reference, got %s", f.Active())
+	}
+}
+
+func TestFailoverEncrypted(t *testing.T) {
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-TARGETDURATION:1\n#EXT-X-MEDIA-SEQUENCE:40\n#EXT-X-KEY:METHOD=SAMPLE-AES,URI=\"keys/1\"\n#EXTINF:0.01,\nvod0.ts\n#EXT-X-ENDLIST\n"))
+	}))
+	t.Cleanup(origin.Close)
+
+	f := &Failover{Source: Multi{&HTTPSource{}}, Refs: []string{origin.URL + "/vod.m3u8"}, CheckInterval: 10 * time.Millisecond}
+	if err := f.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `#EXT-X-KEY:METHOD=SAMPLE-AES,URI="` + origin.URL + `/keys/1",IV=0x00000000000000000000000000000028`
+	if !strings.Contains(f.Playlist(), expected) {
+		t.Errorf("expected the key resolved against the origin with its IV pinned, got %s", f.Playlist())
+	}
+}
+
+func TestFailoverMap(t *testing.T) {
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("#EXTM3U\n#EXT-X-VERSION:6\n#EXT-X-TARGETDURATION:1\n#EXT-X-MAP:URI=\"init.mp4\"\n#EXTINF:0.01,\nvod0.m4s\n#EXT-X-MAP:URI=\"init2.mp4\"\n#EXTINF:0.01,\nvod1.m4s\n#EXT-X-ENDLIST\n"))
+	}))
+	t.Cleanup(origin.Close)
+
+	f := &Failover{Source: Multi{&HTTPSource{}}, Refs: []string{origin.URL + "/vod.m3u8"}, CheckInterval: 10 * time.Millisecond}
+	if err := f.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := `#EXT-X-MAP:URI="` + origin.URL + `/init.mp4"`; !strings.Contains(f.Playlist(), expected) {
+		t.Errorf("expected the initialization section resolved against the origin, got %s", f.Playlist())
+	}
+
+	if playlist := f.Playlist(); !strings.Contains(playlist, `/init2.mp4"`) || strings.Contains(playlist, hls.DiscontinuityField) {
+		t.Errorf("expected the change of initialization section without a discontinuity, got %s", playlist)
+	}
+}
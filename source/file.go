@@ -0,0 +1,300 @@
+package source
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FileURIPrefix is the prefix of the URIs of the resources opened by a FileSource, followed by the path relative to its root so relative playlist URIs resolve against them.
+const FileURIPrefix = "file:///"
+
+// MediaExtensions are the file extensions cataloged by a FileSource by default.
+var MediaExtensions = []string{".m3u8", ".mp4", ".m4v", ".mkv", ".webm", ".mov", ".ts", ".mp3", ".m4a", ".aac", ".flac", ".ogg", ".opus"}
+
+// FileURI returns the URI of the path relative to the root of a FileSource, escaping the characters URIs cannot hold, like spaces.
+func FileURI(relative string) string {
+	return (&url.URL{Scheme: "file", Path: "/" + strings.TrimPrefix(relative, "/")}).String()
+}
+
+// filePath returns the path relative to the root of a FileSource of the URI, or false if it is not a file URI.
+func filePath(uri string) (string, bool) {
+	if !strings.HasPrefix(uri, FileURIPrefix) {
+		return "", false
+	}
+
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", false
+	}
+
+	return strings.TrimPrefix(u.Path, "/"), true
+}
+
+// Prober extracts the metadata of a media file, like ffprobe.
+type Prober interface {
+	Probe(ctx context.Context, path string) (Item, error)
+}
+
+// Packager packages a media file into HLS, returning the path of the media playlist relative to the source root.
+type Packager interface {
+	Package(ctx context.Context, path string) (string, error)
+}
+
+// Entry represents a media file in the catalog of a FileSource.
+type Entry struct {
+	ID      string    // Stable ID of the file, derived from its path
+	Path    string    // Path of the file relative to the root, using forward slashes
+	Size    int64     // Size of the file in bytes
+	ModTime time.Time // Last modification time of the file
+}
+
+// ChangeKind represents how a catalog entry changed.
+type ChangeKind int
+
+const (
+	// EntryAdded indicates that a file was added to the directory.
+	EntryAdded ChangeKind = iota
+
+	// EntryModified indicates that a file was modified.
+	EntryModified
+
+	// EntryRemoved indicates that a file was removed from the directory.
+	EntryRemoved
+)
+
+// FileSource is a Source serving a local media directory, keeping a catalog of its files that can be referenced by path or ID.
+type FileSource struct {
+	Root       string   // Directory holding the media
+	Extensions []string // File extensions cataloged, defaults to MediaExtensions
+	Prober     Prober   // Prober extracting the metadata of the files, nil to resolve files without metadata
+	Packager   Packager // Packager converting non-HLS files on demand, nil to only serve HLS playlists
+
+	// OnChange is called for each entry added, modified or removed when the catalog is refreshed.
+	OnChange func(kind ChangeKind, entry Entry)
+
+	mutex   sync.RWMutex
+	catalog map[string]Entry
+}
+
+// EntryID returns the stable ID of a file path relative to the root.
+func EntryID(relativePath string) string {
+	sum := sha1.Sum([]byte(relativePath))
+	return hex.EncodeToString(sum[:8])
+}
+
+// extensions returns the file extensions cataloged.
+func (s *FileSource) extensions() []string {
+	if s.Extensions == nil {
+		return MediaExtensions
+	}
+
+	return s.Extensions
+}
+
+// Refresh scans the directory, updating the catalog and reporting the changes to OnChange.
+func (s *FileSource) Refresh() error {
+	catalog := map[string]Entry{}
+	extensions := s.extensions()
+
+	err := filepath.WalkDir(s.Root, func(filePath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			if filePath != s.Root && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if !slices.Contains(extensions, strings.ToLower(filepath.Ext(filePath))) {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+
+		relative, err := filepath.Rel(s.Root, filePath)
+		if err != nil {
+			return err
+		}
+
+		relative = filepath.ToSlash(relative)
+		entry := Entry{ID: EntryID(relative), Path: relative, Size: info.Size(), ModTime: info.ModTime()}
+		catalog[entry.ID] = entry
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	previous := s.catalog
+	s.catalog = catalog
+	s.mutex.Unlock()
+
+	diffCatalog(previous, catalog, s.OnChange)
+	return nil
+}
+
+// diffCatalog reports the entries added, modified or removed between two catalogs to the callback, if any.
+func diffCatalog(previous map[string]Entry, catalog map[string]Entry, onChange func(kind ChangeKind, entry Entry)) {
+	if onChange == nil {
+		return
+	}
+
+	for id, entry := range catalog {
+		old, ok := previous[id]
+		if !ok {
+			onChange(EntryAdded, entry)
+		} else if old.Size != entry.Size || !old.ModTime.Equal(entry.ModTime) {
+			onChange(EntryModified, entry)
+		}
+	}
+
+	for id, entry := range previous {
+		if _, ok := catalog[id]; !ok {
+			onChange(EntryRemoved, entry)
+		}
+	}
+}
+
+// Watch refreshes the catalog at every interval until the context is done, returning the first refresh error.
+func (s *FileSource) Watch(ctx context.Context, interval time.Duration) error {
+	if err := s.Refresh(); err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := s.Refresh(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Entries returns the catalog sorted by path.
+func (s *FileSource) Entries() []Entry {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return sortedEntries(s.catalog)
+}
+
+// sortedEntries returns the entries of the catalog sorted by path.
+func sortedEntries(catalog map[string]Entry) []Entry {
+	entries := make([]Entry, 0, len(catalog))
+	for _, entry := range catalog {
+		entries = append(entries, entry)
+	}
+
+	slices.SortFunc(entries, func(a, b Entry) int { return strings.Compare(a.Path, b.Path) })
+	return entries
+}
+
+// Lookup returns the catalog entry referenced by ID or by path relative to the root.
+func (s *FileSource) Lookup(ref string) (Entry, bool) {
+	if relative, ok := filePath(ref); ok {
+		ref = relative
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if entry, ok := s.catalog[ref]; ok {
+		return entry, true
+	}
+
+	entry, ok := s.catalog[EntryID(path.Clean(strings.TrimPrefix(ref, "/")))]
+	return entry, ok
+}
+
+// Resolve resolves a catalog ID or path into a media, probing it when a Prober is set.
+func (s *FileSource) Resolve(ctx context.Context, ref string) (Item, error) {
+	entry, ok := s.Lookup(ref)
+	if !ok {
+		return Item{}, ErrNotFound
+	}
+
+	item := Item{Ref: FileURI(entry.Path), Title: strings.TrimSuffix(path.Base(entry.Path), path.Ext(entry.Path))}
+	if s.Prober == nil {
+		return item, nil
+	}
+
+	probed, err := s.Prober.Probe(ctx, filepath.Join(s.Root, filepath.FromSlash(entry.Path)))
+	if err != nil {
+		return Item{}, err
+	}
+
+	probed.Ref = item.Ref
+	if probed.Title == "" {
+		probed.Title = item.Title
+	}
+
+	return probed, nil
+}
+
+// ListRenditions returns the playlist of the media, packaging it to HLS on demand when it is not a playlist.
+func (s *FileSource) ListRenditions(ctx context.Context, item Item) ([]Rendition, error) {
+	relative, found := filePath(item.Ref)
+	if !found {
+		return nil, ErrUnsupported
+	}
+
+	if path.Ext(relative) == ".m3u8" {
+		return []Rendition{{URI: item.Ref}}, nil
+	}
+
+	if s.Packager == nil {
+		return nil, ErrUnsupported
+	}
+
+	playlist, err := s.Packager.Package(ctx, filepath.Join(s.Root, filepath.FromSlash(relative)))
+	if err != nil {
+		return nil, err
+	}
+
+	return []Rendition{{URI: FileURI(filepath.ToSlash(playlist))}}, nil
+}
+
+// OpenSegment opens a file under the root, rejecting paths escaping it.
+func (s *FileSource) OpenSegment(ctx context.Context, uri string) (io.ReadCloser, error) {
+	relative, found := filePath(uri)
+	if !found {
+		return nil, ErrUnsupported
+	}
+
+	root, err := os.OpenRoot(s.Root)
+	if err != nil {
+		return nil, err
+	}
+	defer root.Close()
+
+	file, err := root.Open(filepath.FromSlash(relative))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+
+	return file, err
+}
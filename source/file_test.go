@@ -0,0 +1,210 @@
+package source
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeFile writes a file under the directory, creating its parents
+func writeFile(t *testing.T, dir string, name string, data string) {
+	filePath := filepath.Join(dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(filePath), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filePath, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// fakePackager packages files by pretending a playlist was written next to them
+type fakePackager struct {
+	root string
+}
+
+func (p *fakePackager) Package(ctx context.Context, path string) (string, error) {
+	relative, err := filepath.Rel(p.root, path)
+	if err != nil {
+		return "", err
+	}
+
+	return relative + ".m3u8", nil
+}
+
+func TestFileSourceCatalog(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "movies/intro.mp4", "mp4")
+	writeFile(t, dir, "notes.txt", "ignored")
+	writeFile(t, dir, ".hidden/secret.mp4", "ignored")
+
+	changes := map[ChangeKind]int{}
+	s := &FileSource{Root: dir, OnChange: func(kind ChangeKind, entry Entry) { changes[kind] += 1 }}
+
+	if err := s.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := s.Entries()
+	if len(entries) != 1 || entries[0].Path != "movies/intro.mp4" {
+		t.Fatalf("expected only movies/intro.mp4, got %v", entries)
+	}
+
+	if _, ok := s.Lookup(entries[0].ID); !ok {
+		t.Errorf("expected lookup by ID to succeed")
+	}
+
+	if _, ok := s.Lookup("file:///movies/intro.mp4"); !ok {
+		t.Errorf("expected lookup by path to succeed")
+	}
+
+	writeFile(t, dir, "movies/outro.webm", "webm")
+	os.Chtimes(filepath.Join(dir, "movies/intro.mp4"), time.Unix(0, 0), time.Unix(0, 0))
+
+	if err := s.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+
+	os.Remove(filepath.Join(dir, "movies/outro.webm"))
+
+	if err := s.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+
+	if changes[EntryAdded] != 2 || changes[EntryModified] != 1 || changes[EntryRemoved] != 1 {
+		t.Errorf("expected 2 added, 1 modified and 1 removed, got %v", changes)
+	}
+}
+
+func TestFileSourceResolve(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "movie.mp4", "mp4")
+	writeFile(t, dir, "movie.mp4.m3u8", "#EXTM3U")
+	writeFile(t, dir, "hls/index.m3u8", "#EXTM3U")
+
+	s := &FileSource{Root: dir, Packager: &fakePackager{root: dir}}
+	if err := s.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+
+	item, err := s.Resolve(ctx, "movie.mp4")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if item.Title != "movie" {
+		t.Errorf("expected title movie, got %s", item.Title)
+	}
+
+	renditions, err := s.ListRenditions(ctx, item)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, err := s.OpenSegment(ctx, renditions[0].URI)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := io.ReadAll(body)
+	body.Close()
+
+	if string(data) != "#EXTM3U" {
+		t.Errorf("expected packaged playlist, got %s", data)
+	}
+
+	if _, err := s.OpenSegment(ctx, "file:///../escape.ts"); err == nil {
+		t.Errorf("expected path escaping the root to fail")
+	}
+
+	if _, err := s.OpenSegment(ctx, "file:///missing.ts"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected not found, got %v", err)
+	}
+
+	if _, err := s.Resolve(ctx, "missing.mp4"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected not found, got %v", err)
+	}
+}
+
+func TestFileSourceRelativeURI(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "hls/index.m3u8", "#EXTM3U")
+	writeFile(t, dir, "hls/0.ts", "segment")
+
+	s := &FileSource{Root: dir}
+	if err := s.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+
+	item, err := s.Resolve(context.Background(), "hls/index.m3u8")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	playlist, err := url.Parse(item.Ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	segment, err := playlist.Parse("0.ts")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, err := s.OpenSegment(context.Background(), segment.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	body.Close()
+}
+
+func TestFileSourceEscapedURI(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "my movies/a b.m3u8", "#EXTM3U")
+	writeFile(t, dir, "my movies/seg 0.ts", "segment")
+
+	s := &FileSource{Root: dir}
+	if err := s.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+
+	item, err := s.Resolve(context.Background(), "my movies/a b.m3u8")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if item.Ref != "file:///my%20movies/a%20b.m3u8" {
+		t.Errorf("expected the spaces to be escaped, got %s", item.Ref)
+	}
+
+	if _, ok := s.Lookup(item.Ref); !ok {
+		t.Errorf("expected lookup by the escaped URI to succeed")
+	}
+
+	playlist, err := url.Parse(item.Ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	segment, err := playlist.Parse("seg%200.ts")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, err := s.OpenSegment(context.Background(), segment.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer body.Close()
+
+	if data, _ := io.ReadAll(body); string(data) != "segment" {
+		t.Errorf("expected the segment, got %q", data)
+	}
+}
@@ -0,0 +1,258 @@
+package source
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrFTPPassive indicates that the server answered the passive mode request with an unexpected address.
+	ErrFTPPassive = errors.New("invalid passive mode response")
+
+	// ErrFTPLineBreak indicates that an argument of a command holds a line break, which would end the command and inject the rest as another one.
+	ErrFTPLineBreak = errors.New("line break in ftp command argument")
+)
+
+// ftpConn is a control connection to an FTP server.
+type ftpConn struct {
+	conn    net.Conn
+	text    *textproto.Conn
+	timeout time.Duration
+}
+
+// dialFTP connects and logs in to the FTP server, switching to binary mode.
+func dialFTP(ctx context.Context, addr string, user string, password string, timeout time.Duration) (*ftpConn, error) {
+	dialer := net.Dialer{Timeout: timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &ftpConn{conn: conn, text: textproto.NewConn(conn), timeout: timeout}
+	if err := c.login(user, password); err != nil {
+		c.Close()
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// command sends a command and reads its response, expecting the code class, rejecting the arguments holding line breaks with ErrFTPLineBreak.
+func (c *ftpConn) command(expectCode int, format string, args ...any) (int, string, error) {
+	for _, arg := range args {
+		if s, ok := arg.(string); ok && strings.ContainsAny(s, "\r\n") {
+			return 0, "", ErrFTPLineBreak
+		}
+	}
+
+	if c.timeout > 0 {
+		c.conn.SetDeadline(time.Now().Add(c.timeout))
+	}
+
+	if _, err := c.text.Cmd(format, args...); err != nil {
+		return 0, "", err
+	}
+
+	return c.text.ReadResponse(expectCode)
+}
+
+// login reads the greeting and authenticates, anonymously when the user is empty.
+func (c *ftpConn) login(user string, password string) error {
+	if _, _, err := c.text.ReadResponse(2); err != nil {
+		return err
+	}
+
+	if user == "" {
+		user, password = "anonymous", "anonymous"
+	}
+
+	code, _, err := c.command(0, "USER %s", user)
+	if err != nil {
+		return err
+	}
+
+	if code == 331 {
+		if _, _, err := c.command(2, "PASS %s", password); err != nil {
+			return err
+		}
+	} else if code/100 != 2 {
+		return &textproto.Error{Code: code, Msg: "login failed"}
+	}
+
+	_, _, err = c.command(2, "TYPE I")
+	return err
+}
+
+// passive opens a data connection in extended passive mode.
+func (c *ftpConn) passive() (net.Conn, error) {
+	_, message, err := c.command(229, "EPSV")
+	if err != nil {
+		return nil, err
+	}
+
+	start := strings.Index(message, "(|||")
+	end := strings.LastIndex(message, "|)")
+	if start < 0 || end <= start+4 {
+		return nil, ErrFTPPassive
+	}
+
+	port, err := strconv.Atoi(message[start+4 : end])
+	if err != nil {
+		return nil, ErrFTPPassive
+	}
+
+	host, _, err := net.SplitHostPort(c.conn.RemoteAddr().String())
+	if err != nil {
+		return nil, err
+	}
+
+	return net.DialTimeout("tcp", net.JoinHostPort(host, strconv.Itoa(port)), c.timeout)
+}
+
+func (c *ftpConn) size(path string) (int64, error) {
+	_, message, err := c.command(213, "SIZE %s", path)
+	if err != nil {
+		var protocolError *textproto.Error
+		if errors.As(err, &protocolError) && protocolError.Code == 550 {
+			return 0, ErrNotFound
+		}
+
+		return 0, err
+	}
+
+	return strconv.ParseInt(strings.TrimSpace(message), 10, 64)
+}
+
+func (c *ftpConn) open(path string, offset int64) (io.ReadCloser, error) {
+	data, err := c.passive()
+	if err != nil {
+		return nil, err
+	}
+
+	if offset > 0 {
+		if _, _, err := c.command(350, "REST %d", offset); err != nil {
+			data.Close()
+			return nil, err
+		}
+	}
+
+	if _, _, err := c.command(1, "RETR %s", path); err != nil {
+		data.Close()
+
+		var protocolError *textproto.Error
+		if errors.As(err, &protocolError) && protocolError.Code == 550 {
+			return nil, ErrNotFound
+		}
+
+		return nil, err
+	}
+
+	c.conn.SetDeadline(time.Time{})
+	return &ftpTransfer{conn: c, data: data}, nil
+}
+
+func (c *ftpConn) Close() error {
+	c.command(0, "QUIT")
+	return c.text.Close()
+}
+
+// ftpTransfer reads a file from the data connection, reading the transfer result when closed.
+type ftpTransfer struct {
+	conn *ftpConn
+	data net.Conn
+	eof  bool
+}
+
+func (t *ftpTransfer) Read(p []byte) (int, error) {
+	if t.conn.timeout > 0 {
+		t.data.SetReadDeadline(time.Now().Add(t.conn.timeout))
+	}
+
+	n, err := t.data.Read(p)
+	if err == io.EOF {
+		t.eof = true
+	}
+
+	return n, err
+}
+
+func (t *ftpTransfer) Close() error {
+	t.data.Close()
+
+	if !t.eof {
+		return errTransferAborted
+	}
+
+	if t.conn.timeout > 0 {
+		t.conn.conn.SetDeadline(time.Now().Add(t.conn.timeout))
+	}
+
+	_, _, err := t.conn.text.ReadResponse(2)
+	return err
+}
+
+// FTPSource is a Source reading media or HLS from an FTP server through pooled connections, resuming interrupted transfers.
+type FTPSource struct {
+	Addr     string        // Address of the server, like "nas.local:21"
+	User     string        // User to log in as, empty to log in anonymously
+	Password string        // Password of the user
+	MaxIdle  int           // Idle connections kept for reuse, defaults to 4
+	Retries  int           // Times an interrupted transfer is resumed, defaults to 3
+	Timeout  time.Duration // Timeout of the network operations, defaults to 30 seconds
+
+	once   sync.Once
+	remote *remoteSource
+}
+
+// source returns the remote source backing the FTP source, creating it on first use.
+func (s *FTPSource) source() *remoteSource {
+	s.once.Do(func() {
+		timeout := s.Timeout
+		if timeout <= 0 {
+			timeout = 30 * time.Second
+		}
+
+		pool := &connPool{maxIdle: max(s.MaxIdle, 0), dial: func(ctx context.Context) (remoteConn, error) {
+			return dialFTP(ctx, s.Addr, s.User, s.Password, timeout)
+		}}
+		if s.MaxIdle == 0 {
+			pool.maxIdle = 4
+		}
+
+		retries := s.Retries
+		if retries == 0 {
+			retries = 3
+		}
+
+		s.remote = &remoteSource{scheme: "ftp", addr: s.Addr, retries: retries, pool: pool}
+	})
+
+	return s.remote
+}
+
+// Resolve checks that the file referenced by the "ftp://" URI or path exists, computing the duration of HLS playlists.
+func (s *FTPSource) Resolve(ctx context.Context, ref string) (Item, error) {
+	return s.source().Resolve(ctx, ref)
+}
+
+// ListRenditions returns the playlist of HLS media, or the URI of the media itself for the converter.
+func (s *FTPSource) ListRenditions(ctx context.Context, item Item) ([]Rendition, error) {
+	return s.source().ListRenditions(ctx, item)
+}
+
+// OpenSegment opens the file referenced by the URI, resuming the transfer when the connection fails.
+func (s *FTPSource) OpenSegment(ctx context.Context, uri string) (io.ReadCloser, error) {
+	return s.source().OpenSegment(ctx, uri)
+}
+
+// Close closes the idle connections.
+func (s *FTPSource) Close() error {
+	return s.source().pool.close()
+}
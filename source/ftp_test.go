@@ -0,0 +1,219 @@
+package source
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// ftpServer is a minimal FTP server serving files from memory
+type ftpServer struct {
+	listener net.Listener
+	files    map[string]string
+	mutex    sync.Mutex
+	logins   int
+	dropOnce int // Bytes sent before the first transfer is cut, zero to never cut
+}
+
+// newFTPServer starts an FTP server serving the files
+func newFTPServer(t *testing.T, files map[string]string) *ftpServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	s := &ftpServer{listener: listener, files: files}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go s.serve(conn)
+		}
+	}()
+
+	return s
+}
+
+func (s *ftpServer) serve(conn net.Conn) {
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	reply := func(format string, args ...any) { fmt.Fprintf(conn, format+"\r\n", args...) }
+
+	var data net.Listener
+	var offset int64
+	reply("220 ready")
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+
+		command, argument, _ := strings.Cut(strings.TrimRight(line, "\r\n"), " ")
+		switch command {
+		case "USER":
+			reply("331 password required")
+		case "PASS":
+			s.mutex.Lock()
+			s.logins += 1
+			s.mutex.Unlock()
+			reply("230 logged in")
+		case "TYPE":
+			reply("200 binary")
+		case "EPSV":
+			data, _ = net.Listen("tcp", "127.0.0.1:0")
+			reply("229 Entering Extended Passive Mode (|||%d|)", data.Addr().(*net.TCPAddr).Port)
+		case "SIZE":
+			file, ok := s.files[argument]
+			if !ok {
+				reply("550 not found")
+				continue
+			}
+			reply("213 %d", len(file))
+		case "REST":
+			offset, _ = strconv.ParseInt(argument, 10, 64)
+			reply("350 restarting")
+		case "RETR":
+			file, ok := s.files[argument]
+			if !ok {
+				data.Close()
+				reply("550 not found")
+				continue
+			}
+
+			reply("150 opening")
+			dataConn, err := data.Accept()
+			data.Close()
+			if err != nil {
+				return
+			}
+
+			content := file[offset:]
+			offset = 0
+
+			s.mutex.Lock()
+			drop := s.dropOnce
+			s.dropOnce = 0
+			s.mutex.Unlock()
+
+			if drop > 0 {
+				io.WriteString(dataConn, content[:drop])
+				dataConn.(*net.TCPConn).SetLinger(0)
+				dataConn.Close()
+				return
+			}
+
+			io.WriteString(dataConn, content)
+			dataConn.Close()
+			reply("226 done")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 not implemented")
+		}
+	}
+}
+
+func TestFTPSourceResolve(t *testing.T) {
+	server := newFTPServer(t, map[string]string{
+		"/shows/pilot.m3u8": "#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-TARGETDURATION:4\n#EXTINF:4.0,\nseg0.ts\n#EXTINF:2.0,\nseg1.ts\n#EXT-X-ENDLIST\n",
+		"/movie.mp4":        "mp4",
+	})
+
+	s := &FTPSource{Addr: server.listener.Addr().String(), User: "user", Password: "secret"}
+	defer s.Close()
+
+	item, err := s.Resolve(context.Background(), "/shows/pilot.m3u8")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if item.Ref != "ftp://"+s.Addr+"/shows/pilot.m3u8" || item.Duration != 6 || item.Live || item.Title != "pilot" {
+		t.Errorf("expected a 6 second VOD item, got %+v", item)
+	}
+
+	item, err = s.Resolve(context.Background(), "ftp://"+s.Addr+"/movie.mp4")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	renditions, err := s.ListRenditions(context.Background(), item)
+	if err != nil || len(renditions) != 1 || renditions[0].URI != item.Ref {
+		t.Errorf("expected the media itself as rendition, got %v and %v", renditions, err)
+	}
+
+	if _, err := s.Resolve(context.Background(), "/missing.mp4"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	if _, err := s.Resolve(context.Background(), "ftp://other:21/movie.mp4"); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("expected ErrUnsupported for another host, got %v", err)
+	}
+
+	if _, err := s.OpenSegment(context.Background(), "ftp://"+s.Addr+"/movie.mp4%0D%0ADELE%20/movie.mp4"); !errors.Is(err, ErrFTPLineBreak) {
+		t.Errorf("expected the injected command to be rejected, got %v", err)
+	}
+}
+
+func TestFTPSourcePoolsConnections(t *testing.T) {
+	server := newFTPServer(t, map[string]string{"/a.ts": "aaaa", "/b.ts": "bbbb"})
+	s := &FTPSource{Addr: server.listener.Addr().String()}
+	defer s.Close()
+
+	for _, name := range []string{"/a.ts", "/b.ts", "/a.ts"} {
+		body, err := s.OpenSegment(context.Background(), name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		data, err := io.ReadAll(body)
+		body.Close()
+		if err != nil || string(data) != strings.Repeat(name[1:2], 4) {
+			t.Errorf("expected contents of %s, got %q and %v", name, data, err)
+		}
+	}
+
+	if server.logins != 1 {
+		t.Errorf("expected a single pooled connection, got %d logins", server.logins)
+	}
+}
+
+func TestFTPSourceResumesTransfer(t *testing.T) {
+	server := newFTPServer(t, map[string]string{"/long.ts": "0123456789"})
+	server.dropOnce = 4
+
+	s := &FTPSource{Addr: server.listener.Addr().String()}
+	defer s.Close()
+
+	body, err := s.OpenSegment(context.Background(), "/long.ts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(data) != "0123456789" {
+		t.Errorf("expected the whole file after resuming, got %q", data)
+	}
+
+	if server.logins != 2 {
+		t.Errorf("expected a reconnection, got %d logins", server.logins)
+	}
+}
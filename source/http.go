@@ -0,0 +1,154 @@
+package source
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+
+	"vrmix/hls"
+)
+
+// HTTPSource is the default Source, resolving HTTP URLs of HLS media and master playlists.
+type HTTPSource struct {
+	Client *http.Client // Client used to fetch from the origins, defaults to http.DefaultClient
+}
+
+// client returns the client used to fetch from the origins.
+func (s *HTTPSource) client() *http.Client {
+	if s.Client == nil {
+		return http.DefaultClient
+	}
+
+	return s.Client
+}
+
+// fetch downloads the URL as a string.
+func (s *HTTPSource) fetch(ctx context.Context, uri string) (string, error) {
+	body, err := s.OpenSegment(ctx, uri)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(body)
+	return string(data), err
+}
+
+// Resolve fetches the playlist at the URL, computing its duration when it is a media playlist.
+func (s *HTTPSource) Resolve(ctx context.Context, ref string) (Item, error) {
+	u, err := url.Parse(ref)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return Item{}, ErrUnsupported
+	}
+
+	data, err := s.fetch(ctx, ref)
+	if err != nil {
+		return Item{}, err
+	}
+
+	item := Item{Ref: ref}
+	if strings.Contains(data, hls.StreamInfField) {
+		return item, nil
+	}
+
+	manifest, err := hls.ParseHlsManifest(strings.TrimRight(data, "\n"))
+	if err != nil {
+		return Item{}, err
+	}
+
+	item.Duration = manifest.Duration()
+	item.Live = !manifest.HasEndList
+	item.Encryption = manifest.Encryption()
+	return item, nil
+}
+
+// ListRenditions returns the variants of a master playlist, or the playlist itself when it is a media playlist.
+func (s *HTTPSource) ListRenditions(ctx context.Context, item Item) ([]Rendition, error) {
+	data, err := s.fetch(ctx, item.Ref)
+	if err != nil {
+		return nil, err
+	}
+
+	return masterRenditions(item.Ref, data)
+}
+
+// masterRenditions returns the variants of the master playlist read from the URI, or the URI itself for a media playlist.
+func masterRenditions(uri string, data string) ([]Rendition, error) {
+	if !strings.Contains(data, hls.StreamInfField) {
+		return []Rendition{{URI: uri}}, nil
+	}
+
+	base, err := url.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	master, err := hls.ParseMasterManifest(data)
+	if err != nil {
+		return nil, err
+	}
+
+	renditions := make([]Rendition, 0, len(master.Variants))
+	for _, variant := range master.Variants {
+		uri, err := base.Parse(variant.URI)
+		if err != nil {
+			return nil, err
+		}
+
+		renditions = append(renditions, Rendition{
+			URI:        uri.String(),
+			Bandwidth:  variant.Bandwidth,
+			Resolution: variant.Resolution,
+			Codecs:     variant.Codecs,
+			Projection: variant.Projection,
+			Stereo:     variant.Stereo,
+		})
+	}
+
+	return renditions, nil
+}
+
+// OpenSegment fetches the URL propagating the trace of the context, returning ErrNotFound when the origin answers 404 Not Found.
+func (s *HTTPSource) OpenSegment(ctx context.Context, uri string) (io.ReadCloser, error) {
+	if !strings.HasPrefix(uri, "http://") && !strings.HasPrefix(uri, "https://") {
+		return nil, ErrUnsupported
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := s.client().Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, ErrNotFound
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, &StatusError{StatusCode: resp.StatusCode}
+	}
+
+	return resp.Body, nil
+}
+
+// StatusError records an unexpected status answered by an HTTP origin.
+type StatusError struct {
+	StatusCode int // Status code answered by the origin
+}
+
+func (e *StatusError) Error() string {
+	return "unexpected origin status " + strconv.Itoa(e.StatusCode)
+}
@@ -0,0 +1,136 @@
+package source
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"vrmix/hls"
+)
+
+// newOrigin starts an origin serving the test streams and a master playlist referencing them
+func newOrigin(t *testing.T) *httptest.Server {
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/master.m3u8":
+			w.Write([]byte("#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=800000,RESOLUTION=1280x720,CODECS=\"avc1.4d401f,mp4a.40.2\",REQ-VIDEO-LAYOUT=\"CH-STEREO,PROJ-EQUI\"\nstream0.m3u8\n#EXT-X-STREAM-INF:BANDWIDTH=200000\nlow/stream1.m3u8\n"))
+		case "/stream0.m3u8":
+			data, err := os.ReadFile("../testdata/stream0.m3u8")
+			if err != nil {
+				t.Error(err)
+			}
+			w.Write(data)
+		case "/protected.m3u8":
+			w.Write([]byte("#EXTM3U\n#EXT-X-TARGETDURATION:4\n#EXT-X-KEY:METHOD=SAMPLE-AES,URI=\"skd://asset\",KEYFORMAT=\"com.apple.streamingkeydelivery\"\n#EXTINF:4,\n0.ts\n#EXT-X-ENDLIST\n"))
+		case "/0.ts":
+			w.Write([]byte("segment"))
+		case "/broken.ts":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(origin.Close)
+
+	return origin
+}
+
+func TestHTTPSourceMedia(t *testing.T) {
+	origin := newOrigin(t)
+	s := &HTTPSource{Client: origin.Client()}
+	ctx := context.Background()
+
+	item, err := s.Resolve(ctx, origin.URL+"/stream0.m3u8")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if item.Live || item.Duration < 7.6 || item.Duration > 7.7 {
+		t.Errorf("expected VOD with duration 7.65, got live %t and %f", item.Live, item.Duration)
+	}
+
+	if item.Encryption != hls.MethodNone {
+		t.Errorf("expected clear segments, got %s", item.Encryption)
+	}
+
+	protected, err := s.Resolve(ctx, origin.URL+"/protected.m3u8")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if protected.Encryption != hls.MethodSampleAES {
+		t.Errorf("expected SAMPLE-AES segments, got %s", protected.Encryption)
+	}
+
+	renditions, err := s.ListRenditions(ctx, item)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(renditions) != 1 || renditions[0].URI != item.Ref {
+		t.Errorf("expected the media playlist as the only rendition, got %v", renditions)
+	}
+}
+
+func TestHTTPSourceMaster(t *testing.T) {
+	origin := newOrigin(t)
+	s := &HTTPSource{Client: origin.Client()}
+	ctx := context.Background()
+
+	item, err := s.Resolve(ctx, origin.URL+"/master.m3u8")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	renditions, err := s.ListRenditions(ctx, item)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(renditions) != 2 {
+		t.Fatalf("expected 2 renditions, got %d", len(renditions))
+	}
+
+	if renditions[0].Bandwidth != 800000 || renditions[0].Resolution != "1280x720" || renditions[0].Codecs != "avc1.4d401f,mp4a.40.2" || renditions[0].Projection != hls.ProjectionEquirectangular || renditions[0].Stereo != hls.StereoMultiview {
+		t.Errorf("expected first rendition attributes, got %v", renditions[0])
+	}
+
+	if renditions[1].URI != origin.URL+"/low/stream1.m3u8" {
+		t.Errorf("expected resolved rendition URI, got %s", renditions[1].URI)
+	}
+}
+
+func TestHTTPSourceOpenSegment(t *testing.T) {
+	origin := newOrigin(t)
+	s := &HTTPSource{Client: origin.Client()}
+	ctx := context.Background()
+
+	body, err := s.OpenSegment(ctx, origin.URL+"/0.ts")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := io.ReadAll(body)
+	body.Close()
+
+	if string(data) != "segment" {
+		t.Errorf("expected segment body, got %s", data)
+	}
+
+	if _, err := s.OpenSegment(ctx, origin.URL+"/missing.ts"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected not found, got %v", err)
+	}
+
+	var statusError *StatusError
+	if _, err := s.OpenSegment(ctx, origin.URL+"/broken.ts"); !errors.As(err, &statusError) || statusError.StatusCode != 500 {
+		t.Errorf("expected status error 500, got %v", err)
+	}
+
+	if _, err := s.Resolve(ctx, "file:///media/movie.mp4"); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("expected unsupported reference, got %v", err)
+	}
+}
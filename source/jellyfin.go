@@ -0,0 +1,273 @@
+package source
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// JellyfinPrefix is the prefix of the references to Jellyfin items, followed by the item ID.
+const JellyfinPrefix = "jellyfin:"
+
+// jellyfinTicksPerSecond is the resolution of the Jellyfin durations.
+const jellyfinTicksPerSecond = 10_000_000
+
+// ErrJellyfinCredentials indicates that the Jellyfin source has neither a user and password nor a token and user ID.
+var ErrJellyfinCredentials = errors.New("jellyfin credentials missing")
+
+// jellyfinItem is the subset of a Jellyfin item used by the source.
+type jellyfinItem struct {
+	ID             string `json:"Id"`
+	Name           string `json:"Name"`
+	Type           string `json:"Type"`
+	MediaType      string `json:"MediaType"`
+	CollectionType string `json:"CollectionType"`
+	RunTimeTicks   int64  `json:"RunTimeTicks"`
+}
+
+// JellyfinSource is a Source browsing the libraries of a Jellyfin server, resolving items to direct streams or HLS transcodes.
+type JellyfinSource struct {
+	BaseURL   string       // URL of the server, like "http://jellyfin.local:8096"
+	User      string       // User to authenticate as, empty to use the token
+	Password  string       // Password of the user
+	Token     string       // API key or access token used when no user is set
+	UserID    string       // ID of the user browsing with the token
+	Transcode bool         // Whether to ask the server for an HLS transcode instead of the original file
+	DeviceID  string       // ID identifying VRMix to the server, defaults to "vrmix"
+	Client    *http.Client // Client used to reach the server, defaults to http.DefaultClient
+
+	mutex       sync.Mutex
+	accessToken string
+	userID      string
+}
+
+// deviceID returns the ID identifying VRMix to the server.
+func (s *JellyfinSource) deviceID() string {
+	if s.DeviceID == "" {
+		return "vrmix"
+	}
+
+	return s.DeviceID
+}
+
+// authorization returns the authorization header, including the token when authenticated.
+func (s *JellyfinSource) authorization(token string) string {
+	header := `MediaBrowser Client="VRMix", Device="VRMix", DeviceId="` + s.deviceID() + `", Version="1.0.0"`
+	if token != "" {
+		header += `, Token="` + token + `"`
+	}
+
+	return header
+}
+
+// session returns the token and user ID, authenticating with the user and password on first use.
+func (s *JellyfinSource) session(ctx context.Context) (string, string, error) {
+	if s.User == "" {
+		if s.Token == "" || s.UserID == "" {
+			return "", "", ErrJellyfinCredentials
+		}
+
+		return s.Token, s.UserID, nil
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.accessToken != "" {
+		return s.accessToken, s.userID, nil
+	}
+
+	body, err := json.Marshal(map[string]string{"Username": s.User, "Pw": s.Password})
+	if err != nil {
+		return "", "", err
+	}
+
+	var result struct {
+		AccessToken string       `json:"AccessToken"`
+		User        jellyfinItem `json:"User"`
+	}
+
+	header := http.Header{"Authorization": {s.authorization("")}}
+	if err := mediaServerJSON(ctx, s.Client, http.MethodPost, s.url("/Users/AuthenticateByName", nil), header, bytes.NewReader(body), &result); err != nil {
+		return "", "", err
+	}
+
+	s.accessToken, s.userID = result.AccessToken, result.User.ID
+	return s.accessToken, s.userID, nil
+}
+
+// expire forgets the access token rejected by the server, so the next request authenticates again.
+func (s *JellyfinSource) expire(token string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.accessToken == token {
+		s.accessToken = ""
+	}
+}
+
+// authenticated calls the server with the token and user ID, authenticating again once when the access token of the user is rejected.
+func (s *JellyfinSource) authenticated(ctx context.Context, call func(token string, userID string) error) error {
+	for retried := false; ; retried = true {
+		token, userID, err := s.session(ctx)
+		if err != nil {
+			return err
+		}
+
+		err = call(token, userID)
+
+		var statusError *StatusError
+		if retried || s.User == "" || !errors.As(err, &statusError) || statusError.StatusCode != http.StatusUnauthorized {
+			return err
+		}
+
+		s.expire(token)
+	}
+}
+
+// url returns the URL of the API path with the query.
+func (s *JellyfinSource) url(apiPath string, query url.Values) string {
+	uri := strings.TrimSuffix(s.BaseURL, "/") + apiPath
+	if len(query) > 0 {
+		uri += "?" + query.Encode()
+	}
+
+	return uri
+}
+
+// get fetches an API path of the user as JSON.
+func (s *JellyfinSource) get(ctx context.Context, apiPath string, query url.Values, value any) error {
+	return s.authenticated(ctx, func(token string, userID string) error {
+		header := http.Header{"Authorization": {s.authorization(token)}}
+		return mediaServerJSON(ctx, s.Client, http.MethodGet, s.url("/Users/"+userID+apiPath, query), header, nil, value)
+	})
+}
+
+// Libraries returns the libraries of the user.
+func (s *JellyfinSource) Libraries(ctx context.Context) ([]Library, error) {
+	var result struct {
+		Items []jellyfinItem `json:"Items"`
+	}
+
+	if err := s.get(ctx, "/Views", nil, &result); err != nil {
+		return nil, err
+	}
+
+	libraries := make([]Library, 0, len(result.Items))
+	for _, item := range result.Items {
+		libraries = append(libraries, Library{ID: item.ID, Name: item.Name, Type: item.CollectionType})
+	}
+
+	return libraries, nil
+}
+
+// Browse returns the playable items of the library, including the ones inside series and albums.
+func (s *JellyfinSource) Browse(ctx context.Context, library Library) ([]LibraryItem, error) {
+	query := url.Values{
+		"ParentId":         {library.ID},
+		"Recursive":        {"true"},
+		"IncludeItemTypes": {"Movie,Episode,Video,MusicVideo,Audio"},
+		"SortBy":           {"SortName"},
+	}
+
+	var result struct {
+		Items []jellyfinItem `json:"Items"`
+	}
+
+	if err := s.get(ctx, "/Items", query, &result); err != nil {
+		return nil, err
+	}
+
+	items := make([]LibraryItem, 0, len(result.Items))
+	for _, item := range result.Items {
+		items = append(items, LibraryItem{Ref: JellyfinPrefix + item.ID, Title: item.Name, Duration: float64(item.RunTimeTicks) / jellyfinTicksPerSecond})
+	}
+
+	return items, nil
+}
+
+// item fetches the item referenced by "jellyfin:<id>" or by ID.
+func (s *JellyfinSource) item(ctx context.Context, ref string) (jellyfinItem, error) {
+	if strings.Contains(ref, "://") || (strings.Contains(ref, ":") && !strings.HasPrefix(ref, JellyfinPrefix)) {
+		return jellyfinItem{}, ErrUnsupported
+	}
+
+	var item jellyfinItem
+	err := s.get(ctx, "/Items/"+url.PathEscape(strings.TrimPrefix(ref, JellyfinPrefix)), nil, &item)
+	return item, err
+}
+
+// Resolve fetches the item, with its title and duration.
+func (s *JellyfinSource) Resolve(ctx context.Context, ref string) (Item, error) {
+	item, err := s.item(ctx, ref)
+	if err != nil {
+		return Item{}, err
+	}
+
+	return Item{Ref: JellyfinPrefix + item.ID, Title: item.Name, Duration: float64(item.RunTimeTicks) / jellyfinTicksPerSecond}, nil
+}
+
+// ListRenditions returns the variants of an HLS transcode when Transcode is set, or the direct stream of the original file.
+func (s *JellyfinSource) ListRenditions(ctx context.Context, item Item) ([]Rendition, error) {
+	resolved, err := s.item(ctx, item.Ref)
+	if err != nil {
+		return nil, err
+	}
+
+	kind := "/Videos/"
+	if resolved.MediaType == "Audio" {
+		kind = "/Audio/"
+	}
+
+	if !s.Transcode {
+		query := url.Values{"static": {"true"}}
+		return []Rendition{{URI: s.url(kind+resolved.ID+"/stream", query)}}, nil
+	}
+
+	query := url.Values{
+		"MediaSourceId":    {resolved.ID},
+		"DeviceId":         {s.deviceID()},
+		"VideoCodec":       {"h264"},
+		"AudioCodec":       {"aac"},
+		"SegmentContainer": {"ts"},
+	}
+
+	uri := s.url(kind+resolved.ID+"/master.m3u8", query)
+	body, err := s.OpenSegment(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+
+	return masterRenditions(uri, string(data))
+}
+
+// OpenSegment fetches a URL of the server, authenticating the request with the Authorization header.
+func (s *JellyfinSource) OpenSegment(ctx context.Context, uri string) (io.ReadCloser, error) {
+	if !underBase(s.BaseURL, uri) {
+		return nil, ErrUnsupported
+	}
+
+	var resp *http.Response
+	err := s.authenticated(ctx, func(token string, userID string) error {
+		var err error
+		resp, err = mediaServerRequest(ctx, s.Client, http.MethodGet, uri, http.Header{"Authorization": {s.authorization(token)}}, nil)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Body, nil
+}
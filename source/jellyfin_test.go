@@ -0,0 +1,155 @@
+package source
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// newJellyfin starts a fake Jellyfin server with the user "alice" and password "secret", accepting the token only in the Authorization header
+func newJellyfin(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	authorized := func(w http.ResponseWriter, r *http.Request) bool {
+		if !strings.Contains(r.Header.Get("Authorization"), `Token="token-1"`) || r.URL.Query().Has("api_key") {
+			w.WriteHeader(http.StatusUnauthorized)
+			return false
+		}
+		return true
+	}
+
+	mux.HandleFunc("POST /Users/AuthenticateByName", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+
+		if body["Username"] != "alice" || body["Pw"] != "secret" || !strings.Contains(r.Header.Get("Authorization"), `DeviceId="vrmix"`) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		io.WriteString(w, `{"AccessToken":"token-1","User":{"Id":"user-1"}}`)
+	})
+	mux.HandleFunc("GET /Users/user-1/Views", func(w http.ResponseWriter, r *http.Request) {
+		if authorized(w, r) {
+			io.WriteString(w, `{"Items":[{"Id":"lib-1","Name":"Movies","CollectionType":"movies"}]}`)
+		}
+	})
+	mux.HandleFunc("GET /Users/user-1/Items", func(w http.ResponseWriter, r *http.Request) {
+		if authorized(w, r) && r.URL.Query().Get("ParentId") == "lib-1" {
+			io.WriteString(w, `{"Items":[{"Id":"movie-1","Name":"Big Buck Bunny","RunTimeTicks":5960000000}]}`)
+		}
+	})
+	mux.HandleFunc("GET /Users/user-1/Items/movie-1", func(w http.ResponseWriter, r *http.Request) {
+		if authorized(w, r) {
+			io.WriteString(w, `{"Id":"movie-1","Name":"Big Buck Bunny","MediaType":"Video","RunTimeTicks":5960000000}`)
+		}
+	})
+	mux.HandleFunc("GET /Videos/movie-1/master.m3u8", func(w http.ResponseWriter, r *http.Request) {
+		if authorized(w, r) {
+			io.WriteString(w, "#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=4000000,RESOLUTION=1920x1080\nmain.m3u8\n")
+		}
+	})
+	mux.HandleFunc("GET /Videos/movie-1/main.m3u8", func(w http.ResponseWriter, r *http.Request) {
+		if authorized(w, r) {
+			io.WriteString(w, "#EXTM3U\n")
+		}
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestJellyfinSource(t *testing.T) {
+	server := newJellyfin(t)
+	s := &JellyfinSource{BaseURL: server.URL, User: "alice", Password: "secret"}
+	ctx := context.Background()
+
+	libraries, err := s.Libraries(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(libraries) != 1 || libraries[0].Name != "Movies" || libraries[0].Type != "movies" {
+		t.Fatalf("expected the movies library, got %v", libraries)
+	}
+
+	items, err := s.Browse(ctx, libraries[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(items) != 1 || items[0].Ref != "jellyfin:movie-1" || items[0].Duration != 596 {
+		t.Fatalf("expected the movie, got %v", items)
+	}
+
+	item, err := s.Resolve(ctx, items[0].Ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if item.Title != "Big Buck Bunny" || item.Duration != 596 {
+		t.Errorf("expected the movie metadata, got %+v", item)
+	}
+
+	renditions, err := s.ListRenditions(ctx, item)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	direct, _ := url.Parse(renditions[0].URI)
+	if len(renditions) != 1 || direct.Path != "/Videos/movie-1/stream" || direct.Query().Get("static") != "true" || direct.Query().Has("api_key") {
+		t.Errorf("expected the direct stream, got %v", renditions)
+	}
+
+	s.Transcode = true
+	renditions, err = s.ListRenditions(ctx, item)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(renditions) != 1 || renditions[0].Resolution != "1920x1080" {
+		t.Fatalf("expected the transcode variant, got %v", renditions)
+	}
+
+	body, err := s.OpenSegment(ctx, renditions[0].URI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body.Close()
+
+	if _, err := s.OpenSegment(ctx, "https://elsewhere.example/main.m3u8"); err != ErrUnsupported {
+		t.Errorf("expected ErrUnsupported outside the server, got %v", err)
+	}
+
+	s.accessToken = "expired"
+	if body, err := s.OpenSegment(ctx, renditions[0].URI); err != nil {
+		t.Errorf("expected to authenticate again after a 401, got %v", err)
+	} else {
+		body.Close()
+	}
+}
+
+func TestJellyfinSourceCredentials(t *testing.T) {
+	server := newJellyfin(t)
+
+	if _, err := (&JellyfinSource{BaseURL: server.URL, Token: "token-1"}).Libraries(context.Background()); err != ErrJellyfinCredentials {
+		t.Errorf("expected ErrJellyfinCredentials without user ID, got %v", err)
+	}
+
+	libraries, err := (&JellyfinSource{BaseURL: server.URL, Token: "token-1", UserID: "user-1"}).Libraries(context.Background())
+	if err != nil || len(libraries) != 1 {
+		t.Errorf("expected the libraries with the API key, got %v and %v", libraries, err)
+	}
+
+	var statusError *StatusError
+	if _, err := (&JellyfinSource{BaseURL: server.URL, User: "alice", Password: "wrong"}).Libraries(context.Background()); !errors.As(err, &statusError) || statusError.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected unauthorized, got %v", err)
+	}
+}
@@ -0,0 +1,78 @@
+package source
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Library represents a library of a media server, like movies or music.
+type Library struct {
+	ID   string // ID of the library in the media server
+	Name string // Name of the library
+	Type string // Kind of media of the library, as named by the media server, like "movies" or "show"
+}
+
+// LibraryItem represents a playable item of a media server library.
+type LibraryItem struct {
+	Ref      string  // Reference of the item, resolved by the source
+	Title    string  // Title of the item
+	Duration float64 // Duration of the item in seconds, zero if unknown
+}
+
+// mediaServerRequest sends a request to a media server with the authentication headers, returning ErrNotFound on 404 Not Found.
+func mediaServerRequest(ctx context.Context, client *http.Client, method string, uri string, header http.Header, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, uri, body)
+	if err != nil {
+		return nil, err
+	}
+
+	for key, values := range header {
+		req.Header[key] = values
+	}
+
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, ErrNotFound
+	}
+
+	if resp.StatusCode/100 != 2 {
+		resp.Body.Close()
+		return nil, &StatusError{StatusCode: resp.StatusCode}
+	}
+
+	return resp, nil
+}
+
+// mediaServerJSON sends a request to a media server, decoding the JSON answered into the value.
+func mediaServerJSON(ctx context.Context, client *http.Client, method string, uri string, header http.Header, body io.Reader, value any) error {
+	header = header.Clone()
+	header.Set("Accept", "application/json")
+	if body != nil {
+		header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := mediaServerRequest(ctx, client, method, uri, header, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return json.NewDecoder(resp.Body).Decode(value)
+}
+
+// underBase checks that the URI is under the base URL of a media server.
+func underBase(baseURL string, uri string) bool {
+	return strings.HasPrefix(uri, strings.TrimSuffix(baseURL, "/")+"/")
+}
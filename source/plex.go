@@ -0,0 +1,190 @@
+package source
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// PlexPrefix is the prefix of the references to Plex items, followed by the rating key of the item.
+const PlexPrefix = "plex:"
+
+// plexMetadata is the subset of a Plex metadata item used by the source.
+type plexMetadata struct {
+	RatingKey string `json:"ratingKey"`
+	Title     string `json:"title"`
+	Type      string `json:"type"`
+	Duration  int64  `json:"duration"`
+	Media     []struct {
+		Part []struct {
+			Key string `json:"key"`
+		} `json:"Part"`
+	} `json:"Media"`
+}
+
+// plexContainer is the envelope of the Plex API responses.
+type plexContainer struct {
+	MediaContainer struct {
+		Directory []struct {
+			Key   string `json:"key"`
+			Title string `json:"title"`
+			Type  string `json:"type"`
+		} `json:"Directory"`
+		Metadata []plexMetadata `json:"Metadata"`
+	} `json:"MediaContainer"`
+}
+
+// plexLeafTypes maps the types of the libraries whose items are containers to the type of their playable items.
+var plexLeafTypes = map[string]string{
+	"show":   "4",
+	"artist": "10",
+}
+
+// PlexSource is a Source browsing the libraries of a Plex Media Server, resolving items to direct streams or HLS transcodes.
+type PlexSource struct {
+	BaseURL          string       // URL of the server, like "http://plex.local:32400"
+	Token            string       // X-Plex-Token authenticating to the server
+	ClientIdentifier string       // ID identifying VRMix to the server, defaults to "vrmix"
+	Transcode        bool         // Whether to ask the server for an HLS transcode instead of the original file
+	Client           *http.Client // Client used to reach the server, defaults to http.DefaultClient
+}
+
+// header returns the headers identifying and authenticating VRMix.
+func (s *PlexSource) header() http.Header {
+	clientIdentifier := s.ClientIdentifier
+	if clientIdentifier == "" {
+		clientIdentifier = "vrmix"
+	}
+
+	return http.Header{
+		"X-Plex-Token":             {s.Token},
+		"X-Plex-Client-Identifier": {clientIdentifier},
+		"X-Plex-Product":           {"VRMix"},
+	}
+}
+
+// url returns the URL of the API path with the query.
+func (s *PlexSource) url(apiPath string, query url.Values) string {
+	uri := strings.TrimSuffix(s.BaseURL, "/") + apiPath
+	if len(query) > 0 {
+		uri += "?" + query.Encode()
+	}
+
+	return uri
+}
+
+// get fetches an API path as JSON.
+func (s *PlexSource) get(ctx context.Context, apiPath string, query url.Values) (plexContainer, error) {
+	var container plexContainer
+	err := mediaServerJSON(ctx, s.Client, http.MethodGet, s.url(apiPath, query), s.header(), nil, &container)
+	return container, err
+}
+
+// Libraries returns the library sections of the server.
+func (s *PlexSource) Libraries(ctx context.Context) ([]Library, error) {
+	container, err := s.get(ctx, "/library/sections", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	libraries := make([]Library, 0, len(container.MediaContainer.Directory))
+	for _, directory := range container.MediaContainer.Directory {
+		libraries = append(libraries, Library{ID: directory.Key, Name: directory.Title, Type: directory.Type})
+	}
+
+	return libraries, nil
+}
+
+// Browse returns the playable items of the library, listing episodes of shows and tracks of music.
+func (s *PlexSource) Browse(ctx context.Context, library Library) ([]LibraryItem, error) {
+	query := url.Values{}
+	if leafType, ok := plexLeafTypes[library.Type]; ok {
+		query.Set("type", leafType)
+	}
+
+	container, err := s.get(ctx, "/library/sections/"+url.PathEscape(library.ID)+"/all", query)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]LibraryItem, 0, len(container.MediaContainer.Metadata))
+	for _, metadata := range container.MediaContainer.Metadata {
+		items = append(items, LibraryItem{Ref: PlexPrefix + metadata.RatingKey, Title: metadata.Title, Duration: float64(metadata.Duration) / 1000})
+	}
+
+	return items, nil
+}
+
+// metadata fetches the item referenced by "plex:<rating key>" or by rating key.
+func (s *PlexSource) metadata(ctx context.Context, ref string) (plexMetadata, error) {
+	if strings.Contains(ref, "://") || (strings.Contains(ref, ":") && !strings.HasPrefix(ref, PlexPrefix)) {
+		return plexMetadata{}, ErrUnsupported
+	}
+
+	container, err := s.get(ctx, "/library/metadata/"+url.PathEscape(strings.TrimPrefix(ref, PlexPrefix)), nil)
+	if err != nil {
+		return plexMetadata{}, err
+	}
+
+	if len(container.MediaContainer.Metadata) == 0 {
+		return plexMetadata{}, ErrNotFound
+	}
+
+	return container.MediaContainer.Metadata[0], nil
+}
+
+// Resolve fetches the item, with its title and duration.
+func (s *PlexSource) Resolve(ctx context.Context, ref string) (Item, error) {
+	metadata, err := s.metadata(ctx, ref)
+	if err != nil {
+		return Item{}, err
+	}
+
+	return Item{Ref: PlexPrefix + metadata.RatingKey, Title: metadata.Title, Duration: float64(metadata.Duration) / 1000}, nil
+}
+
+// ListRenditions returns the variants of an HLS transcode when Transcode is set, or the direct stream of the first file of the item.
+func (s *PlexSource) ListRenditions(ctx context.Context, item Item) ([]Rendition, error) {
+	metadata, err := s.metadata(ctx, item.Ref)
+	if err != nil {
+		return nil, err
+	}
+
+	if !s.Transcode {
+		if len(metadata.Media) == 0 || len(metadata.Media[0].Part) == 0 {
+			return nil, ErrNotFound
+		}
+
+		return []Rendition{{URI: s.url(metadata.Media[0].Part[0].Key, url.Values{"X-Plex-Token": {s.Token}})}}, nil
+	}
+
+	header := s.header()
+	query := url.Values{
+		"path":                     {"/library/metadata/" + metadata.RatingKey},
+		"protocol":                 {"hls"},
+		"directPlay":               {"0"},
+		"directStream":             {"1"},
+		"session":                  {header.Get("X-Plex-Client-Identifier") + "-" + metadata.RatingKey},
+		"X-Plex-Client-Identifier": {header.Get("X-Plex-Client-Identifier")},
+		"X-Plex-Product":           {"VRMix"},
+		"X-Plex-Token":             {s.Token},
+	}
+
+	return (&HTTPSource{Client: s.Client}).ListRenditions(ctx, Item{Ref: s.url("/video/:/transcode/universal/start.m3u8", query)})
+}
+
+// OpenSegment fetches a URL of the server, authenticating the request.
+func (s *PlexSource) OpenSegment(ctx context.Context, uri string) (io.ReadCloser, error) {
+	if !underBase(s.BaseURL, uri) {
+		return nil, ErrUnsupported
+	}
+
+	resp, err := mediaServerRequest(ctx, s.Client, http.MethodGet, uri, s.header(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Body, nil
+}
@@ -0,0 +1,107 @@
+package source
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+// newPlex starts a fake Plex Media Server accepting the token "plex-token"
+func newPlex(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Plex-Token") != "plex-token" && r.URL.Query().Get("X-Plex-Token") != "plex-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/library/sections":
+			io.WriteString(w, `{"MediaContainer":{"Directory":[{"key":"2","title":"TV Shows","type":"show"}]}}`)
+		case "/library/sections/2/all":
+			if r.URL.Query().Get("type") != "4" {
+				t.Errorf("expected episodes to be listed, got type %q", r.URL.Query().Get("type"))
+			}
+			io.WriteString(w, `{"MediaContainer":{"Metadata":[{"ratingKey":"42","title":"Pilot","type":"episode","duration":1500000}]}}`)
+		case "/library/metadata/42":
+			io.WriteString(w, `{"MediaContainer":{"Metadata":[{"ratingKey":"42","title":"Pilot","type":"episode","duration":1500000,"Media":[{"Part":[{"key":"/library/parts/7/1700000000/file.mkv"}]}]}]}}`)
+		case "/video/:/transcode/universal/start.m3u8":
+			if r.URL.Query().Get("path") != "/library/metadata/42" {
+				t.Errorf("expected the item path, got %q", r.URL.Query().Get("path"))
+			}
+			io.WriteString(w, "#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=2000000\nsession/abc/base/index.m3u8\n")
+		case "/video/:/transcode/universal/session/abc/base/index.m3u8":
+			io.WriteString(w, "#EXTM3U\n")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestPlexSource(t *testing.T) {
+	server := newPlex(t)
+	s := &PlexSource{BaseURL: server.URL, Token: "plex-token"}
+	ctx := context.Background()
+
+	libraries, err := s.Libraries(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(libraries) != 1 || libraries[0].ID != "2" || libraries[0].Type != "show" {
+		t.Fatalf("expected the shows library, got %v", libraries)
+	}
+
+	items, err := s.Browse(ctx, libraries[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(items) != 1 || items[0].Ref != "plex:42" || items[0].Duration != 1500 {
+		t.Fatalf("expected the episode, got %v", items)
+	}
+
+	item, err := s.Resolve(ctx, "42")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	renditions, err := s.ListRenditions(ctx, item)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	direct, _ := url.Parse(renditions[0].URI)
+	if direct.Path != "/library/parts/7/1700000000/file.mkv" || direct.Query().Get("X-Plex-Token") != "plex-token" {
+		t.Errorf("expected the direct file with the token, got %v", renditions)
+	}
+
+	s.Transcode = true
+	renditions, err = s.ListRenditions(ctx, item)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(renditions) != 1 || renditions[0].Bandwidth != 2000000 {
+		t.Fatalf("expected the transcode variant, got %v", renditions)
+	}
+
+	body, err := s.OpenSegment(ctx, renditions[0].URI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body.Close()
+
+	if _, err := s.Resolve(ctx, "plex:404"); err != ErrNotFound {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	if _, err := s.Resolve(ctx, "jellyfin:42"); err != ErrUnsupported {
+		t.Errorf("expected ErrUnsupported for other references, got %v", err)
+	}
+}
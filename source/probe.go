@@ -0,0 +1,196 @@
+package source
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"vrmix/hls"
+)
+
+// AudioTrack represents an audio stream of a media.
+type AudioTrack struct {
+	Index    int          // Index of the stream in the media
+	Codec    string       // Codec of the stream, like "eac3" or "opus"
+	Language string       // Language of the stream, empty if unknown
+	Layout   string       // Channel layout reported by the prober, like "5.1(side)" or "ambisonic 1"
+	Channels hls.Channels // Channels of the stream, with the spatial audio detected
+}
+
+// SubtitleTrack represents a subtitles stream of a media.
+type SubtitleTrack struct {
+	Index    int    // Index of the stream in the media
+	Codec    string // Codec of the stream, like "mov_text" or "subrip"
+	Language string // Language of the stream, empty if unknown
+	Title    string // Title of the stream, like "English (SDH)", empty if unknown
+	Forced   bool   // Indicates if the stream only translates the foreign dialogues, shown without a user choice
+}
+
+// ffprobeOutput is the subset of the ffprobe JSON output used by the prober.
+type ffprobeOutput struct {
+	Format struct {
+		Duration string            `json:"duration"`
+		Tags     map[string]string `json:"tags"`
+	} `json:"format"`
+	Streams []struct {
+		Index         int               `json:"index"`
+		CodecType     string            `json:"codec_type"`
+		CodecName     string            `json:"codec_name"`
+		Profile       string            `json:"profile"`
+		Channels      int               `json:"channels"`
+		Width         int               `json:"width"`
+		Height        int               `json:"height"`
+		ChannelLayout string            `json:"channel_layout"`
+		Tags          map[string]string `json:"tags"`
+		Disposition   map[string]int    `json:"disposition"`
+		SideData      []struct {
+			Type       string `json:"side_data_type"`
+			Projection string `json:"projection"`
+			BoundLeft  uint32 `json:"bound_left"`
+			BoundRight uint32 `json:"bound_right"`
+			Stereo     string `json:"type"`
+		} `json:"side_data_list"`
+	} `json:"streams"`
+}
+
+// FFprobe is a Prober running ffprobe, detecting the VR layout of the video and the spatial audio tracks like Dolby Atmos and ambisonics.
+type FFprobe struct {
+	Binary string // Path to the ffprobe binary, defaults to "ffprobe"
+
+	prefixArgs []string
+}
+
+// audioChannels returns the channels of an audio stream, detecting Dolby Atmos from the codec profile and ambisonics from the channel layout.
+func audioChannels(codec string, profile string, channels int, layout string) hls.Channels {
+	if strings.Contains(profile, "Atmos") {
+		if codec == "eac3" {
+			return hls.Channels{Count: 16, Coding: []string{hls.AudioCodingJOC}}
+		}
+
+		return hls.Channels{Count: max(channels, 1), Spatial: []string{hls.SpatialImmersive}}
+	}
+
+	if order, found := strings.CutPrefix(layout, "ambisonic "); found {
+		if n, err := strconv.Atoi(strings.Fields(order)[0]); err == nil {
+			return hls.Channels{Count: (n + 1) * (n + 1), Spatial: []string{hls.SpatialImmersive}}
+		}
+	}
+
+	if layout == "binaural" {
+		return hls.Channels{Count: max(channels, 2), Spatial: []string{hls.SpatialBinaural}}
+	}
+
+	return hls.Channels{Count: channels}
+}
+
+// probedProjections maps the projections of the ffprobe spherical mapping to the projections.
+var probedProjections = map[string]hls.Projection{
+	"equirectangular":      hls.ProjectionEquirectangular,
+	"half equirectangular": hls.ProjectionHalfEquirectangular,
+	"cubemap":              hls.ProjectionCubemap,
+	"fisheye":              hls.ProjectionFisheye,
+	"rectilinear":          hls.ProjectionRectilinear,
+}
+
+// probedStereoLayouts maps the types of the ffprobe stereo 3D side data to the stereo layouts.
+var probedStereoLayouts = map[string]hls.StereoLayout{
+	"2D":             hls.StereoMono,
+	"side by side":   hls.StereoSideBySide,
+	"top and bottom": hls.StereoTopBottom,
+}
+
+// parseProbe returns the item described by the ffprobe JSON output.
+func parseProbe(data []byte) (Item, error) {
+	var output ffprobeOutput
+	if err := json.Unmarshal(data, &output); err != nil {
+		return Item{}, err
+	}
+
+	item := Item{Title: output.Format.Tags["title"]}
+	item.Duration, _ = strconv.ParseFloat(output.Format.Duration, 64)
+
+	for _, stream := range output.Streams {
+		if stream.CodecType == "video" && item.Layout == (hls.Layout{}) {
+			if stream.Width > 0 && stream.Height > 0 {
+				item.Layout.Resolution = strconv.Itoa(stream.Width) + "x" + strconv.Itoa(stream.Height)
+			}
+
+			for _, sideData := range stream.SideData {
+				switch sideData.Type {
+				case "Spherical Mapping":
+					item.Layout.Projection = probedProjections[sideData.Projection]
+					if item.Layout.Projection == hls.ProjectionEquirectangular && float64(sideData.BoundLeft)+float64(sideData.BoundRight) >= 0.45*(1<<32) {
+						item.Layout.Projection = hls.ProjectionHalfEquirectangular
+					}
+				case "Stereo 3D":
+					item.Layout.Stereo = probedStereoLayouts[sideData.Stereo]
+				}
+			}
+		}
+
+		if stream.CodecType == "subtitle" {
+			item.Subtitles = append(item.Subtitles, SubtitleTrack{
+				Index:    stream.Index,
+				Codec:    stream.CodecName,
+				Language: stream.Tags["language"],
+				Title:    stream.Tags["title"],
+				Forced:   stream.Disposition["forced"] == 1,
+			})
+		}
+
+		if stream.CodecType != "audio" {
+			continue
+		}
+
+		item.Audio = append(item.Audio, AudioTrack{
+			Index:    stream.Index,
+			Codec:    stream.CodecName,
+			Language: stream.Tags["language"],
+			Layout:   stream.ChannelLayout,
+			Channels: audioChannels(stream.CodecName, stream.Profile, stream.Channels, stream.ChannelLayout),
+		})
+	}
+
+	return item, nil
+}
+
+// Probe runs ffprobe on the file, returning its title, duration, VR layout, audio and subtitles tracks, reading the Google spatial media XML ffprobe ignores when it reports no layout.
+func (p *FFprobe) Probe(ctx context.Context, path string) (Item, error) {
+	binary := p.Binary
+	if binary == "" {
+		binary = "ffprobe"
+	}
+
+	args := append([]string{}, p.prefixArgs...)
+	args = append(args, "-v", "error", "-print_format", "json", "-show_format", "-show_streams", "file:"+path)
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, binary, args...)
+	cmd.Stderr = &stderr
+
+	output, err := cmd.Output()
+	if err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return Item{}, errors.Join(err, errors.New(message))
+		}
+
+		return Item{}, err
+	}
+
+	item, err := parseProbe(output)
+	if err != nil {
+		return Item{}, err
+	}
+
+	if item.Layout.Projection == "" && item.Layout.Stereo == "" {
+		if layout, err := ReadSphericalFile(path); err == nil {
+			item.Layout.Projection, item.Layout.Stereo = layout.Projection, layout.Stereo
+		}
+	}
+
+	return item, nil
+}
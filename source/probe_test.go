@@ -0,0 +1,92 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"vrmix/hls"
+)
+
+// probeOutput is the ffprobe output of a VR180 video with an Atmos, an ambisonic and a stereo track, and two subtitles tracks
+const probeOutput = `{
+	"format": {"duration": "125.500000", "tags": {"title": "Concert"}},
+	"streams": [
+		{"index": 0, "codec_type": "video", "codec_name": "hevc", "width": 5760, "height": 2880, "side_data_list": [
+			{"side_data_type": "Spherical Mapping", "projection": "equirectangular", "bound_left": 1073741824, "bound_right": 1073741824},
+			{"side_data_type": "Stereo 3D", "type": "side by side", "inverted": 0}
+		]},
+		{"index": 1, "codec_type": "audio", "codec_name": "eac3", "profile": "Dolby Digital Plus + Dolby Atmos", "channels": 6, "channel_layout": "5.1(side)", "tags": {"language": "eng"}},
+		{"index": 2, "codec_type": "audio", "codec_name": "opus", "channels": 4, "channel_layout": "ambisonic 1"},
+		{"index": 3, "codec_type": "audio", "codec_name": "aac", "channels": 2, "channel_layout": "stereo"},
+		{"index": 4, "codec_type": "subtitle", "codec_name": "mov_text", "tags": {"language": "eng", "title": "English"}, "disposition": {"default": 1, "forced": 0}},
+		{"index": 5, "codec_type": "subtitle", "codec_name": "subrip", "tags": {"language": "jpn"}, "disposition": {"default": 0, "forced": 1}}
+	]
+}`
+
+// flatProbeOutput is the ffprobe output of a video without spherical side data
+const flatProbeOutput = `{"format": {"duration": "10.0"}, "streams": [{"index": 0, "codec_type": "video", "codec_name": "h264"}]}`
+
+// TestHelperFFprobe is not a real test, it acts as ffprobe when run by TestFFprobe
+func TestHelperFFprobe(t *testing.T) {
+	if os.Getenv("VRMIX_HELPER_FFPROBE") == "" {
+		return
+	}
+
+	if !strings.HasPrefix(os.Args[len(os.Args)-1], "file:") {
+		fmt.Fprint(os.Stderr, "missing file protocol")
+		os.Exit(1)
+	}
+
+	if os.Getenv("VRMIX_HELPER_FFPROBE") == "flat" {
+		fmt.Print(flatProbeOutput)
+	} else {
+		fmt.Print(probeOutput)
+	}
+	os.Exit(0)
+}
+
+func TestFFprobe(t *testing.T) {
+	t.Setenv("VRMIX_HELPER_FFPROBE", "1")
+	p := &FFprobe{Binary: os.Args[0], prefixArgs: []string{"-test.run=TestHelperFFprobe", "--"}}
+
+	item, err := p.Probe(context.Background(), "/media/concert.mp4")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if item.Layout.Projection != hls.ProjectionHalfEquirectangular || item.Layout.Stereo != hls.StereoSideBySide || item.Layout.Resolution != "5760x2880" {
+		t.Errorf("expected a side by side VR180 video, got %+v", item.Layout)
+	}
+
+	if item.Title != "Concert" || item.Duration != 125.5 {
+		t.Errorf("expected Concert with duration 125.5, got %s and %f", item.Title, item.Duration)
+	}
+
+	if len(item.Audio) != 3 {
+		t.Fatalf("expected 3 audio tracks, got %d", len(item.Audio))
+	}
+
+	expected := []string{"16/JOC", "4/-/IMMERSIVE", "2"}
+	for i, track := range item.Audio {
+		if track.Channels.String() != expected[i] {
+			t.Errorf("expected channels %s for track %d, got %s", expected[i], track.Index, track.Channels)
+		}
+	}
+
+	if !item.Audio[0].Channels.IsSpatial() || !slices.Equal(item.Audio[1].Channels.Spatial, []string{hls.SpatialImmersive}) || item.Audio[2].Channels.IsSpatial() {
+		t.Errorf("expected the Atmos and ambisonic tracks to be spatial, got %+v", item.Audio)
+	}
+
+	if item.Audio[0].Language != "eng" {
+		t.Errorf("expected language eng, got %s", item.Audio[0].Language)
+	}
+
+	expectedSubtitles := []SubtitleTrack{{Index: 4, Codec: "mov_text", Language: "eng", Title: "English"}, {Index: 5, Codec: "subrip", Language: "jpn", Forced: true}}
+	if !slices.Equal(item.Subtitles, expectedSubtitles) {
+		t.Errorf("expected the subtitles tracks %+v, got %+v", expectedSubtitles, item.Subtitles)
+	}
+}
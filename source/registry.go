@@ -0,0 +1,151 @@
+package source
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+var (
+	// ErrInvalidName indicates that the name cannot prefix the logical IDs, being empty or containing a colon.
+	ErrInvalidName = errors.New("invalid source name")
+
+	// ErrDuplicateName indicates that a source is already registered under the name.
+	ErrDuplicateName = errors.New("source already registered")
+)
+
+// Registry is a Source resolving logical IDs of the form "name:ref" with the source registered under the name, like "nas:movies/a.mp4" or "youtube:dQw4w9WgXcQ", so the stream controller requests media by ID and new providers are plugged in by registering them.
+type Registry struct {
+	mutex   sync.RWMutex
+	names   []string // names in the order the sources were registered
+	sources map[string]Source
+	routes  map[string]string // names of the sources which listed renditions by the directory URI of their playlists
+}
+
+// NewRegistry creates an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{sources: map[string]Source{}, routes: map[string]string{}}
+}
+
+// Register registers the source under the name, returning ErrInvalidName or ErrDuplicateName.
+func (r *Registry) Register(name string, source Source) error {
+	if name == "" || strings.Contains(name, ":") {
+		return ErrInvalidName
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, ok := r.sources[name]; ok {
+		return ErrDuplicateName
+	}
+
+	r.names = append(r.names, name)
+	r.sources[name] = source
+	return nil
+}
+
+// Names returns the names of the registered sources in the order they were registered.
+func (r *Registry) Names() []string {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	return append([]string(nil), r.names...)
+}
+
+// lookup returns the source registered under the name, or ErrUnsupported when no source has the name.
+func (r *Registry) lookup(name string) (Source, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	source, ok := r.sources[name]
+	if !ok {
+		return nil, ErrUnsupported
+	}
+
+	return source, nil
+}
+
+// Resolve resolves the logical ID with the source registered under its name, recording the name in the Source of the item so its renditions are listed by the same source.
+func (r *Registry) Resolve(ctx context.Context, id string) (Item, error) {
+	name, ref, ok := strings.Cut(id, ":")
+	if !ok {
+		return Item{}, ErrUnsupported
+	}
+
+	source, err := r.lookup(name)
+	if err != nil {
+		return Item{}, err
+	}
+
+	item, err := source.Resolve(ctx, ref)
+	if err != nil {
+		return Item{}, err
+	}
+
+	item.Source = name
+	return item, nil
+}
+
+// ListRenditions lists the renditions of the media with the source which resolved it, routing the resources under the directories of their playlists to the source.
+func (r *Registry) ListRenditions(ctx context.Context, item Item) ([]Rendition, error) {
+	source, err := r.lookup(item.Source)
+	if err != nil {
+		return nil, err
+	}
+
+	renditions, err := source.ListRenditions(ctx, item)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, rendition := range renditions {
+		if dir, ok := uriDir(rendition.URI); ok {
+			r.routes[dir] = item.Source
+		}
+	}
+
+	return renditions, nil
+}
+
+// uriDir returns the URI up to the last slash of its path, without its query, returning false if it is not a valid URI.
+func uriDir(uri string) (string, bool) {
+	u, err := url.Parse(uri)
+	if err != nil || u.Opaque != "" {
+		return "", false
+	}
+
+	u.Path, u.RawPath, u.RawQuery, u.Fragment = u.Path[:strings.LastIndex(u.Path, "/")+1], "", "", ""
+	return u.String(), true
+}
+
+// OpenSegment opens the resource with the source which listed the rendition whose directory holds it, or with the first registered source supporting its URI otherwise, so a source accepting any URI does not request the resources of a source needing credentials.
+func (r *Registry) OpenSegment(ctx context.Context, uri string) (io.ReadCloser, error) {
+	dir, _ := uriDir(uri)
+
+	r.mutex.RLock()
+	route := ""
+	for prefix := range r.routes {
+		if len(prefix) > len(route) && strings.HasPrefix(dir, prefix) {
+			route = prefix
+		}
+	}
+
+	sources := make(Multi, 0, len(r.names))
+	if route != "" {
+		sources = append(sources, r.sources[r.routes[route]])
+	} else {
+		for _, name := range r.names {
+			sources = append(sources, r.sources[name])
+		}
+	}
+	r.mutex.RUnlock()
+
+	return sources.OpenSegment(ctx, uri)
+}
@@ -0,0 +1,147 @@
+package source
+
+import (
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "hls/index.m3u8", "#EXTM3U")
+	writeFile(t, dir, "hls/0.ts", "local")
+
+	files := &FileSource{Root: dir}
+	if err := files.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+
+	origin := newOrigin(t)
+	registry := NewRegistry()
+	for name, source := range map[string]Source{"nas": files, "web": &HTTPSource{}} {
+		if err := registry.Register(name, source); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx := context.Background()
+	item, err := registry.Resolve(ctx, "web:"+origin.URL+"/master.m3u8")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if item.Source != "web" || item.Ref != origin.URL+"/master.m3u8" {
+		t.Errorf("expected the item resolved by the web source, got %+v", item)
+	}
+
+	if renditions, err := registry.ListRenditions(ctx, item); err != nil || len(renditions) != 2 || renditions[0].URI != origin.URL+"/stream0.m3u8" {
+		t.Errorf("expected the renditions of the master playlist, got %+v and %v", renditions, err)
+	}
+
+	item, err = registry.Resolve(ctx, "nas:hls/index.m3u8")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	renditions, err := registry.ListRenditions(ctx, item)
+	if err != nil || len(renditions) != 1 {
+		t.Fatalf("expected the local playlist, got %+v and %v", renditions, err)
+	}
+
+	for uri, expected := range map[string]string{FileURIPrefix + "hls/0.ts": "local", origin.URL + "/0.ts": "segment"} {
+		body, err := registry.OpenSegment(ctx, uri)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		data, _ := io.ReadAll(body)
+		body.Close()
+
+		if string(data) != expected {
+			t.Errorf("expected %s to be opened by its source, got %q", uri, data)
+		}
+	}
+
+	for _, id := range []string{"hls/index.m3u8", "ytdlp:dQw4w9WgXcQ"} {
+		if _, err := registry.Resolve(ctx, id); !errors.Is(err, ErrUnsupported) {
+			t.Errorf("expected %s to be unsupported, got %v", id, err)
+		}
+	}
+
+	if _, err := registry.Resolve(ctx, "nas:missing.mp4"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected the missing media, got %v", err)
+	}
+}
+
+func TestRegistryRegister(t *testing.T) {
+	registry := NewRegistry()
+	registry.Register("web", &HTTPSource{})
+	registry.Register("nas", &FileSource{Root: t.TempDir()})
+
+	for name, expected := range map[string]error{"": ErrInvalidName, "a:b": ErrInvalidName, "web": ErrDuplicateName} {
+		if err := registry.Register(name, &HTTPSource{}); !errors.Is(err, expected) {
+			t.Errorf("expected %v registering %q, got %v", expected, name, err)
+		}
+	}
+
+	if names := registry.Names(); !slices.Equal(names, []string{"web", "nas"}) {
+		t.Errorf("expected the names in the order they were registered, got %v", names)
+	}
+
+	// The registry plugs into the other sources like any source.
+	if _, err := (Multi{registry, &HTTPSource{}}).Resolve(context.Background(), "ftp://host/a.m3u8"); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("expected the reference to be unsupported by both, got %v", err)
+	}
+}
+
+// namedSource supports any reference, listing a rendition under its base URL and opening every resource as its name
+type namedSource struct {
+	name string
+	base string
+}
+
+func (s *namedSource) Resolve(ctx context.Context, ref string) (Item, error) {
+	return Item{Ref: ref}, nil
+}
+
+func (s *namedSource) ListRenditions(ctx context.Context, item Item) ([]Rendition, error) {
+	return []Rendition{{URI: s.base + item.Ref + "/master.m3u8?token=secret"}}, nil
+}
+
+func (s *namedSource) OpenSegment(ctx context.Context, uri string) (io.ReadCloser, error) {
+	return io.NopCloser(strings.NewReader(s.name)), nil
+}
+
+func TestRegistryRoutes(t *testing.T) {
+	registry := NewRegistry()
+	registry.Register("web", &namedSource{name: "web", base: "https://web.example/"})
+	registry.Register("media", &namedSource{name: "media", base: "https://media.example/videos/"})
+
+	ctx := context.Background()
+	item, _ := registry.Resolve(ctx, "media:1")
+	if _, err := registry.ListRenditions(ctx, item); err != nil {
+		t.Fatal(err)
+	}
+
+	for uri, expected := range map[string]string{
+		"https://media.example/videos/1/master.m3u8": "media",
+		"https://media.example/videos/1/hls/0.ts":    "media",
+		"https://media.example/videos/2/hls/0.ts":    "web",
+		"https://cdn.example/videos/1/hls/0.ts":      "web",
+	} {
+		body, err := registry.OpenSegment(ctx, uri)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		data, _ := io.ReadAll(body)
+		body.Close()
+
+		if string(data) != expected {
+			t.Errorf("expected %s to be opened by the %s source, got %s", uri, expected, data)
+		}
+	}
+}
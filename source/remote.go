@@ -0,0 +1,277 @@
+package source
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+
+	"vrmix/hls"
+)
+
+// errTransferAborted indicates that a transfer was closed before it finished, leaving the connection unusable.
+var errTransferAborted = errors.New("transfer aborted")
+
+// remoteConn is a connection to a file server, like FTP or SFTP.
+type remoteConn interface {
+	// open opens the file starting at the offset, the connection is busy until the reader is closed.
+	open(path string, offset int64) (io.ReadCloser, error)
+
+	// size returns the size of the file.
+	size(path string) (int64, error)
+
+	// Close closes the connection.
+	Close() error
+}
+
+// connPool keeps idle connections to a file server for reuse.
+type connPool struct {
+	dial    func(ctx context.Context) (remoteConn, error)
+	mutex   sync.Mutex
+	idle    []remoteConn
+	maxIdle int
+}
+
+// get returns an idle connection or dials a new one.
+func (p *connPool) get(ctx context.Context) (remoteConn, error) {
+	p.mutex.Lock()
+	if n := len(p.idle); n > 0 {
+		conn := p.idle[n-1]
+		p.idle = p.idle[:n-1]
+		p.mutex.Unlock()
+		return conn, nil
+	}
+	p.mutex.Unlock()
+
+	return p.dial(ctx)
+}
+
+// put returns a healthy connection to the pool, closing it if the pool is full.
+func (p *connPool) put(conn remoteConn) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if len(p.idle) >= p.maxIdle {
+		conn.Close()
+		return
+	}
+
+	p.idle = append(p.idle, conn)
+}
+
+// close closes every idle connection.
+func (p *connPool) close() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	var errs []error
+	for _, conn := range p.idle {
+		errs = append(errs, conn.Close())
+	}
+	p.idle = nil
+
+	return errors.Join(errs...)
+}
+
+// pooledReader returns its connection to the pool when closed, discarding it if the transfer failed.
+type pooledReader struct {
+	io.ReadCloser
+	pool   *connPool
+	conn   remoteConn
+	failed bool
+}
+
+func (r *pooledReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if err != nil && err != io.EOF {
+		r.failed = true
+	}
+
+	return n, err
+}
+
+func (r *pooledReader) Close() error {
+	err := r.ReadCloser.Close()
+	if err != nil || r.failed {
+		r.conn.Close()
+	} else {
+		r.pool.put(r.conn)
+	}
+
+	if errors.Is(err, errTransferAborted) {
+		return nil
+	}
+
+	return err
+}
+
+// resumableReader reads a remote file, reopening it from the last offset when the transfer fails.
+type resumableReader struct {
+	ctx     context.Context
+	open    func(offset int64) (io.ReadCloser, error)
+	offset  int64
+	retries int
+	current io.ReadCloser
+}
+
+func (r *resumableReader) Read(p []byte) (int, error) {
+	for {
+		if r.current == nil {
+			current, err := r.open(r.offset)
+			if err != nil {
+				return 0, err
+			}
+
+			r.current = current
+		}
+
+		n, err := r.current.Read(p)
+		r.offset += int64(n)
+
+		if err == nil || err == io.EOF {
+			return n, err
+		}
+
+		r.current.Close()
+		r.current = nil
+
+		if r.retries <= 0 || r.ctx.Err() != nil {
+			return n, err
+		}
+		r.retries -= 1
+
+		if n > 0 {
+			return n, nil
+		}
+	}
+}
+
+func (r *resumableReader) Close() error {
+	if r.current == nil {
+		return nil
+	}
+
+	return r.current.Close()
+}
+
+// remoteSource implements a Source over a file server reached through pooled connections, with URIs like "<scheme>://<addr>/<path>".
+type remoteSource struct {
+	scheme  string
+	addr    string
+	retries int
+	pool    *connPool
+}
+
+// uri returns the URI of the remote path.
+func (s *remoteSource) uri(remotePath string) string {
+	return s.scheme + "://" + s.addr + path.Join("/", remotePath)
+}
+
+// path returns the remote path of the reference, which may be a URI or a path on the server.
+func (s *remoteSource) path(ref string) (string, error) {
+	if !strings.Contains(ref, "://") {
+		return path.Join("/", ref), nil
+	}
+
+	u, err := url.Parse(ref)
+	if err != nil || u.Scheme != s.scheme || u.Host != s.addr {
+		return "", ErrUnsupported
+	}
+
+	return path.Join("/", u.Path), nil
+}
+
+// release returns the connection to the pool after a failed operation, closing it unless the file was just missing.
+func (s *remoteSource) release(conn remoteConn, err error) {
+	if errors.Is(err, ErrNotFound) {
+		s.pool.put(conn)
+		return
+	}
+
+	conn.Close()
+}
+
+// open opens the file at the offset through a pooled connection.
+func (s *remoteSource) open(ctx context.Context, remotePath string, offset int64) (io.ReadCloser, error) {
+	conn, err := s.pool.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := conn.open(remotePath, offset)
+	if err != nil {
+		s.release(conn, err)
+		return nil, err
+	}
+
+	return &pooledReader{ReadCloser: reader, pool: s.pool, conn: conn}, nil
+}
+
+// Resolve checks that the file exists, computing the duration of HLS playlists.
+func (s *remoteSource) Resolve(ctx context.Context, ref string) (Item, error) {
+	remotePath, err := s.path(ref)
+	if err != nil {
+		return Item{}, err
+	}
+
+	item := Item{Ref: s.uri(remotePath), Title: strings.TrimSuffix(path.Base(remotePath), path.Ext(remotePath))}
+
+	if path.Ext(remotePath) != ".m3u8" {
+		conn, err := s.pool.get(ctx)
+		if err != nil {
+			return Item{}, err
+		}
+
+		if _, err := conn.size(remotePath); err != nil {
+			s.release(conn, err)
+			return Item{}, err
+		}
+
+		s.pool.put(conn)
+		return item, nil
+	}
+
+	body, err := s.OpenSegment(ctx, item.Ref)
+	if err != nil {
+		return Item{}, err
+	}
+	defer body.Close()
+
+	manifest, err := hls.ParseHlsManifestReader(body)
+	if err != nil {
+		return Item{}, err
+	}
+
+	item.Duration = manifest.Duration()
+	item.Live = !manifest.HasEndList
+	item.Encryption = manifest.Encryption()
+	return item, nil
+}
+
+// ListRenditions returns the playlist of HLS media, or the URI of the media itself for the converter.
+func (s *remoteSource) ListRenditions(ctx context.Context, item Item) ([]Rendition, error) {
+	if _, err := s.path(item.Ref); err != nil {
+		return nil, err
+	}
+
+	return []Rendition{{URI: item.Ref}}, nil
+}
+
+// OpenSegment opens the file referenced by the URI, resuming the transfer when the connection fails.
+func (s *remoteSource) OpenSegment(ctx context.Context, uri string) (io.ReadCloser, error) {
+	remotePath, err := s.path(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := s.open(ctx, remotePath, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	open := func(offset int64) (io.ReadCloser, error) { return s.open(ctx, remotePath, offset) }
+	return &resumableReader{ctx: ctx, open: open, retries: s.retries, current: current}, nil
+}
@@ -0,0 +1,117 @@
+package source
+
+import (
+	"context"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"vrmix/hls"
+	"vrmix/s3"
+)
+
+// S3Source is a Source reading media or pre-packaged HLS from an S3-compatible bucket, referenced as "s3://<bucket>/<key>" or by key relative to the prefix.
+type S3Source struct {
+	Client        *s3.Client    // Client of the bucket
+	Prefix        string        // Key prefix holding the media, like "library/"
+	PresignExpiry time.Duration // Validity of the presigned URLs handed to the converter for non-HLS media, defaults to one hour
+}
+
+// uri returns the URI of the object key.
+func (s *S3Source) uri(key string) string {
+	return "s3://" + s.Client.Bucket + "/" + key
+}
+
+// key returns the object key of the reference, which may be a URI or a key relative to the prefix.
+func (s *S3Source) key(ref string) (string, error) {
+	if !strings.Contains(ref, "://") {
+		return path.Join(s.Prefix, strings.TrimPrefix(ref, "/")), nil
+	}
+
+	u, err := url.Parse(ref)
+	if err != nil || u.Scheme != "s3" || u.Host != s.Client.Bucket {
+		return "", ErrUnsupported
+	}
+
+	return strings.TrimPrefix(u.Path, "/"), nil
+}
+
+// Resolve checks that the object exists, computing the duration of pre-packaged HLS playlists.
+func (s *S3Source) Resolve(ctx context.Context, ref string) (Item, error) {
+	key, err := s.key(ref)
+	if err != nil {
+		return Item{}, err
+	}
+
+	item := Item{Ref: s.uri(key), Title: strings.TrimSuffix(path.Base(key), path.Ext(key))}
+
+	if path.Ext(key) != ".m3u8" {
+		if _, err := s.Client.HeadObject(ctx, key); err != nil {
+			return Item{}, s.err(err)
+		}
+
+		return item, nil
+	}
+
+	body, err := s.Client.GetObject(ctx, key, "")
+	if err != nil {
+		return Item{}, s.err(err)
+	}
+	defer body.Close()
+
+	manifest, err := hls.ParseHlsManifestReader(body)
+	if err != nil {
+		return Item{}, err
+	}
+
+	item.Duration = manifest.Duration()
+	item.Live = !manifest.HasEndList
+	item.Encryption = manifest.Encryption()
+	return item, nil
+}
+
+// ListRenditions returns the playlist of pre-packaged HLS, or a presigned URL of the media so the converter can fetch it directly.
+func (s *S3Source) ListRenditions(ctx context.Context, item Item) ([]Rendition, error) {
+	key, err := s.key(item.Ref)
+	if err != nil {
+		return nil, err
+	}
+
+	if path.Ext(key) == ".m3u8" {
+		return []Rendition{{URI: s.uri(key)}}, nil
+	}
+
+	expiry := s.PresignExpiry
+	if expiry <= 0 {
+		expiry = time.Hour
+	}
+
+	presigned, err := s.Client.PresignGet(key, expiry)
+	if err != nil {
+		return nil, err
+	}
+
+	return []Rendition{{URI: presigned}}, nil
+}
+
+// OpenSegment opens the object referenced by the URI.
+func (s *S3Source) OpenSegment(ctx context.Context, uri string) (io.ReadCloser, error) {
+	key, err := s.key(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := s.Client.GetObject(ctx, key, "")
+	return body, s.err(err)
+}
+
+// err converts object storage errors to source errors.
+func (s *S3Source) err(err error) error {
+	if err == s3.ErrNotFound {
+		return ErrNotFound
+	}
+
+	return err
+}
@@ -0,0 +1,94 @@
+package source
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"vrmix/s3"
+)
+
+// newS3Source starts a fake bucket holding the test stream and a media file
+func newS3Source(t *testing.T) *S3Source {
+	storage := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/media/library/hls/index.m3u8":
+			data, err := os.ReadFile("../testdata/stream1.m3u8")
+			if err != nil {
+				t.Error(err)
+			}
+			w.Write(data)
+		case "/media/library/hls/0.ts", "/media/library/movie.mp4":
+			w.Write([]byte("segment"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(storage.Close)
+
+	client := &s3.Client{Endpoint: storage.URL, Region: "us-east-1", Bucket: "media", AccessKey: "key", SecretKey: "secret", PathStyle: true, HTTP: storage.Client()}
+	return &S3Source{Client: client, Prefix: "library"}
+}
+
+func TestS3SourceHLS(t *testing.T) {
+	s := newS3Source(t)
+	ctx := context.Background()
+
+	item, err := s.Resolve(ctx, "hls/index.m3u8")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if item.Ref != "s3://media/library/hls/index.m3u8" || item.Live || item.Duration < 21.8 || item.Duration > 21.95 {
+		t.Errorf("expected VOD playlist with duration 21.9, got %s, live %t and %f", item.Ref, item.Live, item.Duration)
+	}
+
+	renditions, err := s.ListRenditions(ctx, item)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, err := s.OpenSegment(ctx, strings.Replace(renditions[0].URI, "index.m3u8", "0.ts", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := io.ReadAll(body)
+	body.Close()
+
+	if string(data) != "segment" {
+		t.Errorf("expected segment body, got %s", data)
+	}
+}
+
+func TestS3SourceMedia(t *testing.T) {
+	s := newS3Source(t)
+	ctx := context.Background()
+
+	item, err := s.Resolve(ctx, "s3://media/library/movie.mp4")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	renditions, err := s.ListRenditions(ctx, item)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(renditions[0].URI, "X-Amz-Signature=") {
+		t.Errorf("expected presigned URL, got %s", renditions[0].URI)
+	}
+
+	if _, err := s.Resolve(ctx, "missing.mp4"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected not found, got %v", err)
+	}
+
+	if _, err := s.Resolve(ctx, "s3://other/movie.mp4"); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("expected unsupported reference, got %v", err)
+	}
+}
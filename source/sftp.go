@@ -0,0 +1,173 @@
+package source
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// ErrHostKeyCallbackMissing indicates that the SFTP source has no way to verify the host key of the server.
+var ErrHostKeyCallbackMissing = errors.New("host key callback missing")
+
+// sftpConn is an SFTP session over an SSH connection.
+type sftpConn struct {
+	client *sftp.Client
+	ssh    *ssh.Client
+}
+
+// dialSFTP connects to the SSH server, opening an SFTP session.
+func dialSFTP(ctx context.Context, addr string, config *ssh.ClientConfig) (*sftpConn, error) {
+	dialer := net.Dialer{Timeout: config.Timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	sshConn, channels, requests, err := ssh.NewClientConn(conn, addr, config)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	client := ssh.NewClient(sshConn, channels, requests)
+	sftpClient, err := sftp.NewClient(client)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	return &sftpConn{client: sftpClient, ssh: client}, nil
+}
+
+// sftpError maps missing files to ErrNotFound.
+func sftpError(err error) error {
+	if errors.Is(err, fs.ErrNotExist) {
+		return ErrNotFound
+	}
+
+	return err
+}
+
+func (c *sftpConn) size(path string) (int64, error) {
+	info, err := c.client.Stat(path)
+	if err != nil {
+		return 0, sftpError(err)
+	}
+
+	return info.Size(), nil
+}
+
+func (c *sftpConn) open(path string, offset int64) (io.ReadCloser, error) {
+	file, err := c.client.Open(path)
+	if err != nil {
+		return nil, sftpError(err)
+	}
+
+	if offset > 0 {
+		if _, err := file.Seek(offset, io.SeekStart); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+
+	return file, nil
+}
+
+func (c *sftpConn) Close() error {
+	if c.ssh != nil {
+		c.ssh.Close()
+	}
+
+	return c.client.Close()
+}
+
+// SFTPSource is a Source reading media or HLS from an SFTP server through pooled connections, resuming interrupted transfers.
+type SFTPSource struct {
+	Addr            string              // Address of the server, like "nas.local:22"
+	User            string              // User to log in as
+	Password        string              // Password of the user, empty to only use the signer
+	Signer          ssh.Signer          // Private key of the user, nil to only use the password
+	HostKeyCallback ssh.HostKeyCallback // Callback verifying the host key of the server, required
+	MaxIdle         int                 // Idle connections kept for reuse, defaults to 4
+	Retries         int                 // Times an interrupted transfer is resumed, defaults to 3
+	Timeout         time.Duration       // Timeout of the connection handshake, defaults to 30 seconds
+
+	dial   func(ctx context.Context) (remoteConn, error)
+	once   sync.Once
+	remote *remoteSource
+}
+
+// clientConfig returns the SSH configuration used to connect to the server.
+func (s *SFTPSource) clientConfig() *ssh.ClientConfig {
+	var auth []ssh.AuthMethod
+	if s.Signer != nil {
+		auth = append(auth, ssh.PublicKeys(s.Signer))
+	}
+	if s.Password != "" {
+		auth = append(auth, ssh.Password(s.Password))
+	}
+
+	timeout := s.Timeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+
+	return &ssh.ClientConfig{User: s.User, Auth: auth, HostKeyCallback: s.HostKeyCallback, Timeout: timeout}
+}
+
+// source returns the remote source backing the SFTP source, creating it on first use.
+func (s *SFTPSource) source() *remoteSource {
+	s.once.Do(func() {
+		dial := s.dial
+		if dial == nil {
+			dial = func(ctx context.Context) (remoteConn, error) {
+				if s.HostKeyCallback == nil {
+					return nil, ErrHostKeyCallbackMissing
+				}
+
+				return dialSFTP(ctx, s.Addr, s.clientConfig())
+			}
+		}
+
+		pool := &connPool{maxIdle: max(s.MaxIdle, 0), dial: dial}
+		if s.MaxIdle == 0 {
+			pool.maxIdle = 4
+		}
+
+		retries := s.Retries
+		if retries == 0 {
+			retries = 3
+		}
+
+		s.remote = &remoteSource{scheme: "sftp", addr: s.Addr, retries: retries, pool: pool}
+	})
+
+	return s.remote
+}
+
+// Resolve checks that the file referenced by the "sftp://" URI or path exists, computing the duration of HLS playlists.
+func (s *SFTPSource) Resolve(ctx context.Context, ref string) (Item, error) {
+	return s.source().Resolve(ctx, ref)
+}
+
+// ListRenditions returns the playlist of HLS media, or the URI of the media itself for the converter.
+func (s *SFTPSource) ListRenditions(ctx context.Context, item Item) ([]Rendition, error) {
+	return s.source().ListRenditions(ctx, item)
+}
+
+// OpenSegment opens the file referenced by the URI, resuming the transfer when the connection fails.
+func (s *SFTPSource) OpenSegment(ctx context.Context, uri string) (io.ReadCloser, error) {
+	return s.source().OpenSegment(ctx, uri)
+}
+
+// Close closes the idle connections.
+func (s *SFTPSource) Close() error {
+	return s.source().pool.close()
+}
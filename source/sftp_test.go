@@ -0,0 +1,105 @@
+package source
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/sftp"
+)
+
+// pipeSFTP dials an in-process SFTP server rooted at the directory
+func pipeSFTP(t *testing.T, dir string, dials *int) func(ctx context.Context) (remoteConn, error) {
+	return func(ctx context.Context) (remoteConn, error) {
+		*dials += 1
+
+		clientReader, serverWriter := io.Pipe()
+		serverReader, clientWriter := io.Pipe()
+
+		server, err := sftp.NewServer(struct {
+			io.Reader
+			io.WriteCloser
+		}{serverReader, serverWriter}, sftp.WithServerWorkingDirectory(dir))
+		if err != nil {
+			return nil, err
+		}
+		go func() {
+			server.Serve()
+			serverWriter.Close()
+		}()
+
+		client, err := sftp.NewClientPipe(clientReader, clientWriter)
+		if err != nil {
+			return nil, err
+		}
+
+		return &sftpConn{client: client}, nil
+	}
+}
+
+func TestSFTPSource(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "shows/pilot.m3u8", "#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-TARGETDURATION:4\n#EXTINF:4.0,\nseg0.ts\n")
+	writeFile(t, dir, "shows/seg0.ts", "segment")
+
+	dials := 0
+	s := &SFTPSource{Addr: "nas:22", dial: pipeSFTP(t, dir, &dials)}
+	defer s.Close()
+
+	item, err := s.Resolve(context.Background(), filepath.ToSlash(filepath.Join(dir, "shows/pilot.m3u8")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if item.Duration != 4 || !item.Live {
+		t.Errorf("expected a 4 second live item, got %+v", item)
+	}
+
+	body, err := s.OpenSegment(context.Background(), "sftp://nas:22"+filepath.ToSlash(filepath.Join(dir, "shows/seg0.ts")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := io.ReadAll(body)
+	body.Close()
+	if err != nil || string(data) != "segment" {
+		t.Errorf("expected the segment, got %q and %v", data, err)
+	}
+
+	if dials != 1 {
+		t.Errorf("expected a single pooled connection, got %d", dials)
+	}
+
+	if _, err := s.Resolve(context.Background(), filepath.Join(dir, "missing.mp4")); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestSFTPSourceResumesFromOffset(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "long.ts", "0123456789")
+
+	dials := 0
+	s := &SFTPSource{Addr: "nas:22", dial: pipeSFTP(t, dir, &dials)}
+	defer s.Close()
+
+	reader, err := s.source().open(context.Background(), filepath.ToSlash(filepath.Join(dir, "long.ts")), 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil || string(data) != "6789" {
+		t.Errorf("expected the file from offset 6, got %q and %v", data, err)
+	}
+}
+
+func TestSFTPSourceRequiresHostKeyCallback(t *testing.T) {
+	s := &SFTPSource{Addr: "127.0.0.1:1", User: "user"}
+	if _, err := s.Resolve(context.Background(), "/movie.mp4"); !errors.Is(err, ErrHostKeyCallbackMissing) {
+		t.Errorf("expected ErrHostKeyCallbackMissing, got %v", err)
+	}
+}
@@ -0,0 +1,96 @@
+package source
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"vrmix/hls"
+)
+
+var (
+	// ErrNotFound indicates that the media or segment does not exist in the source.
+	ErrNotFound = errors.New("media not found")
+
+	// ErrUnsupported indicates that the source cannot handle the reference.
+	ErrUnsupported = errors.New("unsupported reference")
+)
+
+// Item represents a media resolved by a source.
+type Item struct {
+	Ref      string  // Reference the item was resolved from, like an URL or a path
+	Title    string  // Human readable title of the media, if known
+	Duration float64 // Duration of the media in seconds, zero if unknown or live
+	Live     bool    // Indicates if the media is a live stream without an end
+	Source   string  // Name of the source of a Registry which resolved the media, empty otherwise
+
+	// Audio lists the audio tracks of the media when probed, empty if unknown.
+	Audio []AudioTrack
+
+	// Subtitles lists the subtitles tracks of the media when probed, empty if unknown.
+	Subtitles []SubtitleTrack
+
+	// Encryption is the encryption of the segments signaled by the HLS playlist, like SAMPLE-AES segments played as is, MethodNone when clear and empty if unknown.
+	Encryption hls.EncryptionMethod
+
+	// Layout is the projection and stereo layout of the video when probed, empty if unknown.
+	Layout hls.Layout
+}
+
+// Rendition represents a variant of a media, each one with its own media playlist.
+type Rendition struct {
+	URI        string           // URI of the media playlist of the rendition
+	Bandwidth  int              // Peak bits per second of the rendition, zero if unknown
+	Resolution string           // Resolution of the video, like "1920x1080", empty if unknown
+	Codecs     string           // Codecs of the rendition, like "avc1.640028,mp4a.40.2", empty if unknown
+	Projection hls.Projection   // Projection of the video signaled by the origin, empty if unknown
+	Stereo     hls.StereoLayout // Stereo layout of the video signaled by the origin, empty if unknown
+}
+
+// Source resolves references into media and opens their segments, so the scheduler and the stream controller do not assume every media is an HTTP URL.
+type Source interface {
+	// Resolve resolves the reference into a media.
+	Resolve(ctx context.Context, ref string) (Item, error)
+
+	// ListRenditions returns the renditions of the media, at least one for playable media.
+	ListRenditions(ctx context.Context, item Item) ([]Rendition, error)
+
+	// OpenSegment opens a resource of the media, like a segment, a playlist or a file, by its URI.
+	OpenSegment(ctx context.Context, uri string) (io.ReadCloser, error)
+}
+
+// Multi is a Source trying its sources in order, the first one not answering ErrUnsupported handling the reference, so the most specific sources go first.
+type Multi []Source
+
+// Resolve resolves the reference with the first source supporting it.
+func (m Multi) Resolve(ctx context.Context, ref string) (Item, error) {
+	for _, source := range m {
+		if item, err := source.Resolve(ctx, ref); !errors.Is(err, ErrUnsupported) {
+			return item, err
+		}
+	}
+
+	return Item{}, ErrUnsupported
+}
+
+// ListRenditions lists the renditions with the first source supporting the media.
+func (m Multi) ListRenditions(ctx context.Context, item Item) ([]Rendition, error) {
+	for _, source := range m {
+		if renditions, err := source.ListRenditions(ctx, item); !errors.Is(err, ErrUnsupported) {
+			return renditions, err
+		}
+	}
+
+	return nil, ErrUnsupported
+}
+
+// OpenSegment opens the resource with the first source supporting its URI.
+func (m Multi) OpenSegment(ctx context.Context, uri string) (io.ReadCloser, error) {
+	for _, source := range m {
+		if body, err := source.OpenSegment(ctx, uri); !errors.Is(err, ErrUnsupported) {
+			return body, err
+		}
+	}
+
+	return nil, ErrUnsupported
+}
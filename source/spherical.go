@@ -0,0 +1,309 @@
+package source
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"vrmix/hls"
+)
+
+// ErrInvalidBox indicates that a MP4 box is truncated or malformed.
+var ErrInvalidBox = errors.New("invalid mp4 box")
+
+// sphericalUUID is the UUID of the box holding the Google spatial media XML of the first version of the spherical metadata.
+var sphericalUUID = []byte{0xff, 0xcc, 0x82, 0x63, 0xf8, 0x55, 0x4a, 0x93, 0x88, 0x14, 0x58, 0x7a, 0x02, 0x52, 0x1f, 0xdd}
+
+// sphericalXMLTag matches the tags of the Google spatial media XML.
+var sphericalXMLTag = regexp.MustCompile(`<GSpherical:(ProjectionType|StereoMode)>\s*([^<]*?)\s*</GSpherical:`)
+
+// sampleEntryHeaders are the bytes between the header of a visual sample entry and its child boxes.
+const sampleEntryHeaders = 78
+
+// box is a MP4 box located in a file.
+type box struct {
+	kind   string
+	offset int64 // Offset of the content of the box
+	size   int64 // Size of the content of the box
+}
+
+// readBoxes returns the boxes found in the section of the file.
+func readBoxes(r io.ReaderAt, offset int64, size int64) ([]box, error) {
+	var boxes []box
+	header := make([]byte, 16)
+
+	for end := offset + size; offset+8 <= end; {
+		if _, err := r.ReadAt(header[:8], offset); err != nil {
+			return nil, err
+		}
+
+		boxSize, headerSize := int64(binary.BigEndian.Uint32(header)), int64(8)
+		switch boxSize {
+		case 0:
+			boxSize = end - offset
+		case 1:
+			if _, err := r.ReadAt(header[8:16], offset+8); err != nil {
+				return nil, err
+			}
+
+			boxSize, headerSize = int64(binary.BigEndian.Uint64(header[8:16])), 16
+		}
+
+		if boxSize < headerSize || offset+boxSize > end {
+			return nil, ErrInvalidBox
+		}
+
+		boxes = append(boxes, box{kind: string(header[4:8]), offset: offset + headerSize, size: boxSize - headerSize})
+		offset += boxSize
+	}
+
+	return boxes, nil
+}
+
+// child returns the first box of the kind in the section of the file.
+func child(r io.ReaderAt, parent box, kind string, skip int64) (box, bool, error) {
+	if parent.size < skip {
+		return box{}, false, ErrInvalidBox
+	}
+
+	boxes, err := readBoxes(r, parent.offset+skip, parent.size-skip)
+	if err != nil {
+		return box{}, false, err
+	}
+
+	for _, b := range boxes {
+		if b.kind == kind {
+			return b, true, nil
+		}
+	}
+
+	return box{}, false, nil
+}
+
+// readContent returns the content of the box, which must be small.
+func readContent(r io.ReaderAt, b box) ([]byte, error) {
+	if b.size > 1<<20 {
+		return nil, ErrInvalidBox
+	}
+
+	data := make([]byte, b.size)
+	_, err := r.ReadAt(data, b.offset)
+	return data, err
+}
+
+// stereoModes maps the stereo modes of the st3d box to the stereo layouts.
+var stereoModes = map[byte]hls.StereoLayout{0: hls.StereoMono, 1: hls.StereoTopBottom, 2: hls.StereoSideBySide}
+
+// readSampleEntry reads the frame size and the st3d and sv3d boxes of a visual sample entry.
+func readSampleEntry(r io.ReaderAt, entry box) (hls.Layout, error) {
+	var layout hls.Layout
+
+	// The width and height follow the reserved fields, the data reference index and the predefined fields of the sample entry.
+	size := make([]byte, 4)
+	if entry.size < sampleEntryHeaders {
+		return layout, ErrInvalidBox
+	} else if _, err := r.ReadAt(size, entry.offset+24); err != nil {
+		return layout, err
+	}
+
+	if width, height := binary.BigEndian.Uint16(size), binary.BigEndian.Uint16(size[2:]); width > 0 && height > 0 {
+		layout.Resolution = strconv.Itoa(int(width)) + "x" + strconv.Itoa(int(height))
+	}
+
+	if st3d, found, err := child(r, entry, "st3d", sampleEntryHeaders); err != nil {
+		return layout, err
+	} else if found {
+		data, err := readContent(r, st3d)
+		if err != nil || len(data) < 5 {
+			return layout, ErrInvalidBox
+		}
+
+		layout.Stereo = stereoModes[data[4]]
+	}
+
+	sv3d, found, err := child(r, entry, "sv3d", sampleEntryHeaders)
+	if err != nil || !found {
+		return layout, err
+	}
+
+	proj, found, err := child(r, sv3d, "proj", 0)
+	if err != nil || !found {
+		return layout, err
+	}
+
+	boxes, err := readBoxes(r, proj.offset, proj.size)
+	if err != nil {
+		return layout, err
+	}
+
+	for _, b := range boxes {
+		switch b.kind {
+		case "equi":
+			data, err := readContent(r, b)
+			if err != nil || len(data) < 20 {
+				return layout, ErrInvalidBox
+			}
+
+			// The bounds are the fractions of the sphere cropped on each side, 180 degrees videos cropping a quarter on the left and right.
+			left, right := binary.BigEndian.Uint32(data[12:16]), binary.BigEndian.Uint32(data[16:20])
+			layout.Projection = hls.ProjectionEquirectangular
+			if float64(left)+float64(right) >= 0.45*(1<<32) {
+				layout.Projection = hls.ProjectionHalfEquirectangular
+			}
+		case "cbmp":
+			layout.Projection = hls.ProjectionCubemap
+		}
+	}
+
+	return layout, nil
+}
+
+// parseSphericalXML returns the layout of the Google spatial media XML.
+func parseSphericalXML(data []byte) hls.Layout {
+	var layout hls.Layout
+	for _, match := range sphericalXMLTag.FindAllSubmatch(data, -1) {
+		value := strings.ToLower(string(match[2]))
+		switch string(match[1]) {
+		case "ProjectionType":
+			if value == "equirectangular" {
+				layout.Projection = hls.ProjectionEquirectangular
+			}
+		case "StereoMode":
+			switch value {
+			case "mono":
+				layout.Stereo = hls.StereoMono
+			case "top-bottom":
+				layout.Stereo = hls.StereoTopBottom
+			case "left-right":
+				layout.Stereo = hls.StereoSideBySide
+			}
+		}
+	}
+
+	return layout
+}
+
+// ReadSpherical reads the projection, stereo layout and frame size of the first video track of a MP4 file, from the spherical video boxes (st3d, sv3d and proj) or the Google spatial media XML, returning an empty layout when there is none.
+func ReadSpherical(r io.ReaderAt, size int64) (hls.Layout, error) {
+	moov, found, err := child(r, box{size: size}, "moov", 0)
+	if err != nil || !found {
+		return hls.Layout{}, err
+	}
+
+	traks, err := readBoxes(r, moov.offset, moov.size)
+	if err != nil {
+		return hls.Layout{}, err
+	}
+
+	for _, trak := range traks {
+		if trak.kind != "trak" {
+			continue
+		}
+
+		layout, video, err := readTrack(r, trak)
+		if err != nil || video {
+			return layout, err
+		}
+	}
+
+	return hls.Layout{}, nil
+}
+
+// readTrack reads the layout of a track, returning false when it is not a video track.
+func readTrack(r io.ReaderAt, trak box) (hls.Layout, bool, error) {
+	var layout hls.Layout
+
+	mdia, found, err := child(r, trak, "mdia", 0)
+	if err != nil || !found {
+		return layout, false, err
+	}
+
+	// The hdlr box is a full box followed by a predefined field before the handler type.
+	hdlr, found, err := child(r, mdia, "hdlr", 0)
+	if err != nil || !found {
+		return layout, false, err
+	}
+
+	handler, err := readContent(r, hdlr)
+	if err != nil || len(handler) < 12 || string(handler[8:12]) != "vide" {
+		return layout, false, err
+	}
+
+	current := mdia
+	for _, kind := range []string{"minf", "stbl", "stsd"} {
+		if current, found, err = child(r, current, kind, 0); err != nil || !found {
+			return layout, true, err
+		}
+	}
+
+	// The stsd box is a full box followed by the entry count before its sample entries.
+	if current.size < 8 {
+		return layout, true, ErrInvalidBox
+	}
+
+	entries, err := readBoxes(r, current.offset+8, current.size-8)
+	if err != nil || len(entries) == 0 {
+		return layout, true, err
+	}
+
+	if layout, err = readSampleEntry(r, entries[0]); err != nil {
+		return layout, true, err
+	}
+
+	if uuid, found, err := child(r, trak, "uuid", 0); err == nil && found {
+		data, err := readContent(r, uuid)
+		if err == nil && len(data) > 16 && bytes.Equal(data[:16], sphericalUUID) {
+			xml := parseSphericalXML(data[16:])
+			if layout.Projection == "" {
+				layout.Projection = xml.Projection
+			}
+
+			if layout.Stereo == "" {
+				layout.Stereo = xml.Stereo
+			}
+		}
+	}
+
+	return layout, true, nil
+}
+
+// ReadSphericalFile reads the projection, stereo layout and frame size of the first video track of the MP4 file at the path.
+func ReadSphericalFile(path string) (hls.Layout, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return hls.Layout{}, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return hls.Layout{}, err
+	}
+
+	return ReadSpherical(file, info.Size())
+}
+
+// ResolveLayout returns the projection and stereo layout of the media referenced, from the probed metadata or the signaling of its first rendition, empty when unknown, so queue items do not require manual flags.
+func ResolveLayout(ctx context.Context, source Source, ref string) (hls.Layout, error) {
+	item, err := source.Resolve(ctx, ref)
+	if err != nil {
+		return hls.Layout{}, err
+	}
+
+	if item.Layout != (hls.Layout{}) {
+		return item.Layout, nil
+	}
+
+	renditions, err := source.ListRenditions(ctx, item)
+	if err != nil || len(renditions) == 0 {
+		return hls.Layout{}, err
+	}
+
+	return hls.Layout{Projection: renditions[0].Projection, Stereo: renditions[0].Stereo, Resolution: renditions[0].Resolution}, nil
+}
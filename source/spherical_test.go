@@ -0,0 +1,98 @@
+package source
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"vrmix/hls"
+)
+
+// mp4Box returns a MP4 box of the kind holding the content
+func mp4Box(kind string, content ...[]byte) []byte {
+	data := bytes.Join(content, nil)
+	header := binary.BigEndian.AppendUint32(nil, uint32(8+len(data)))
+	return append(append(header, kind...), data...)
+}
+
+// videoTrack returns a video trak box whose sample entry holds the boxes, followed by the extra boxes of the track
+func videoTrack(entryBoxes [][]byte, trackBoxes ...[]byte) []byte {
+	hdlr := mp4Box("hdlr", make([]byte, 8), []byte("vide"), make([]byte, 12))
+	header := make([]byte, sampleEntryHeaders)
+	binary.BigEndian.PutUint16(header[24:], 5760)
+	binary.BigEndian.PutUint16(header[26:], 2880)
+
+	entry := mp4Box("hvc1", append([][]byte{header}, entryBoxes...)...)
+	stsd := mp4Box("stsd", make([]byte, 8), entry)
+	mdia := mp4Box("mdia", hdlr, mp4Box("minf", mp4Box("stbl", stsd)))
+
+	return mp4Box("trak", append([][]byte{mdia}, trackBoxes...)...)
+}
+
+// equiBox returns an equi box with the left and right bounds
+func equiBox(left uint32, right uint32) []byte {
+	data := make([]byte, 20)
+	binary.BigEndian.PutUint32(data[12:], left)
+	binary.BigEndian.PutUint32(data[16:], right)
+	return mp4Box("equi", data)
+}
+
+func TestReadSpherical(t *testing.T) {
+	audio := mp4Box("trak", mp4Box("mdia", mp4Box("hdlr", make([]byte, 8), []byte("soun"), make([]byte, 12))))
+	xml := append(bytes.Clone(sphericalUUID), []byte(`<rdf:SphericalVideo><GSpherical:Spherical>true</GSpherical:Spherical><GSpherical:ProjectionType>equirectangular</GSpherical:ProjectionType><GSpherical:StereoMode>left-right</GSpherical:StereoMode></rdf:SphericalVideo>`)...)
+
+	cases := []struct {
+		name   string
+		track  []byte
+		layout hls.Layout
+	}{
+		{"v2 vr180", videoTrack([][]byte{mp4Box("st3d", []byte{0, 0, 0, 0, 1}), mp4Box("sv3d", mp4Box("proj", mp4Box("prhd", make([]byte, 16)), equiBox(1<<30, 1<<30)))}), hls.Layout{Projection: hls.ProjectionHalfEquirectangular, Stereo: hls.StereoTopBottom, Resolution: "5760x2880"}},
+		{"v2 cubemap", videoTrack([][]byte{mp4Box("sv3d", mp4Box("proj", mp4Box("cbmp", make([]byte, 12))))}), hls.Layout{Projection: hls.ProjectionCubemap, Resolution: "5760x2880"}},
+		{"v1 xml", videoTrack(nil, mp4Box("uuid", xml)), hls.Layout{Projection: hls.ProjectionEquirectangular, Stereo: hls.StereoSideBySide, Resolution: "5760x2880"}},
+		{"flat", videoTrack(nil), hls.Layout{Resolution: "5760x2880"}},
+	}
+
+	for _, c := range cases {
+		file := append(mp4Box("ftyp", []byte("isom")), mp4Box("moov", audio, c.track)...)
+
+		layout, err := ReadSpherical(bytes.NewReader(file), int64(len(file)))
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+
+		if layout != c.layout {
+			t.Errorf("%s: expected %+v, got %+v", c.name, c.layout, layout)
+		}
+	}
+
+	truncated := mp4Box("moov", videoTrack(nil))[:40]
+	if _, err := ReadSpherical(bytes.NewReader(truncated), int64(len(truncated))); err == nil {
+		t.Error("expected truncated boxes to fail")
+	}
+}
+
+func TestResolveLayout(t *testing.T) {
+	root := t.TempDir()
+	file := append(mp4Box("ftyp", []byte("isom")), mp4Box("moov", videoTrack([][]byte{mp4Box("sv3d", mp4Box("proj", equiBox(0, 0)))}))...)
+	if err := os.WriteFile(filepath.Join(root, "360.mp4"), file, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("VRMIX_HELPER_FFPROBE", "flat")
+	s := &FileSource{Root: root, Prober: &FFprobe{Binary: os.Args[0], prefixArgs: []string{"-test.run=TestHelperFFprobe", "--"}}}
+	if err := s.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+
+	layout, err := ResolveLayout(context.Background(), s, "360.mp4")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if layout.Projection != hls.ProjectionEquirectangular {
+		t.Errorf("expected the layout read from the file, got %+v", layout)
+	}
+}
@@ -0,0 +1,94 @@
+package source
+
+import (
+	"context"
+	"io"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracerName is the instrumentation scope of the spans started by the sources, registered with otel.SetTracerProvider.
+const TracerName = "vrmix/source"
+
+// Traced is a Source tracing the calls to the wrapped source, so slow origin fetches show up in the trace of the request waiting for them.
+type Traced struct {
+	Source Source // Source being traced
+	Name   string // Name of the source recorded on the spans, like "http" or "s3"
+}
+
+// start starts a client span of the source.
+func (t *Traced) start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	attrs = append(attrs, attribute.String("vrmix.source", t.Name))
+	return otel.Tracer(TracerName).Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+}
+
+// end records the error on the span, if any, and ends it.
+func end(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
+
+// Resolve resolves the reference inside a span.
+func (t *Traced) Resolve(ctx context.Context, ref string) (Item, error) {
+	ctx, span := t.start(ctx, "source resolve", attribute.String("vrmix.source.ref", ref))
+
+	item, err := t.Source.Resolve(ctx, ref)
+	end(span, err)
+	return item, err
+}
+
+// ListRenditions lists the renditions inside a span.
+func (t *Traced) ListRenditions(ctx context.Context, item Item) ([]Rendition, error) {
+	ctx, span := t.start(ctx, "source list renditions", attribute.String("vrmix.source.ref", item.Ref))
+
+	renditions, err := t.Source.ListRenditions(ctx, item)
+	span.SetAttributes(attribute.Int("vrmix.source.renditions", len(renditions)))
+	end(span, err)
+	return renditions, err
+}
+
+// OpenSegment opens the resource inside a span lasting until it is closed, so the transfer time is included.
+func (t *Traced) OpenSegment(ctx context.Context, uri string) (io.ReadCloser, error) {
+	ctx, span := t.start(ctx, "source open segment", attribute.String("vrmix.source.uri", uri))
+
+	body, err := t.Source.OpenSegment(ctx, uri)
+	if err != nil {
+		end(span, err)
+		return nil, err
+	}
+
+	return &tracedBody{ReadCloser: body, span: span}, nil
+}
+
+// tracedBody ends its span when closed, recording the bytes read and the read error, if any.
+type tracedBody struct {
+	io.ReadCloser
+	span  trace.Span
+	bytes int64
+	err   error
+}
+
+func (b *tracedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.bytes += int64(n)
+	if err != nil && err != io.EOF {
+		b.err = err
+	}
+
+	return n, err
+}
+
+func (b *tracedBody) Close() error {
+	err := b.ReadCloser.Close()
+
+	b.span.SetAttributes(attribute.Int64("vrmix.source.bytes", b.bytes))
+	end(b.span, b.err)
+	return err
+}
@@ -0,0 +1,51 @@
+package source
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTraced(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(provider)
+
+	origin := newOrigin(t)
+	s := &Traced{Source: &HTTPSource{Client: origin.Client()}, Name: "http"}
+
+	body, err := s.OpenSegment(context.Background(), origin.URL+"/0.ts")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	io.ReadAll(body)
+	if len(recorder.Ended()) != 0 {
+		t.Errorf("expected the span to last until the body is closed, got %d ended", len(recorder.Ended()))
+	}
+	body.Close()
+
+	if _, err := s.OpenSegment(context.Background(), origin.URL+"/missing.ts"); err != ErrNotFound {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+
+	for _, attr := range spans[0].Attributes() {
+		if attr.Key == "vrmix.source.bytes" && attr.Value.AsInt64() != 7 {
+			t.Errorf("expected 7 bytes read, got %d", attr.Value.AsInt64())
+		}
+	}
+
+	if spans[1].Status().Description != ErrNotFound.Error() {
+		t.Errorf("expected the error to be recorded, got %q", spans[1].Status().Description)
+	}
+}
@@ -0,0 +1,262 @@
+package source
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"vrmix/hls"
+)
+
+// propfindBody requests the properties used to resolve a WebDAV resource.
+const propfindBody = `<?xml version="1.0" encoding="utf-8"?><d:propfind xmlns:d="DAV:"><d:prop><d:getcontentlength/><d:getlastmodified/><d:resourcetype/></d:prop></d:propfind>`
+
+// davMultistatus is the subset of a PROPFIND response used by the source.
+type davMultistatus struct {
+	Responses []struct {
+		Propstat []struct {
+			Prop struct {
+				ContentLength string `xml:"getcontentlength"`
+				LastModified  string `xml:"getlastmodified"`
+				ResourceType  struct {
+					Collection *struct{} `xml:"collection"`
+				} `xml:"resourcetype"`
+			} `xml:"prop"`
+			Status string `xml:"status"`
+		} `xml:"propstat"`
+	} `xml:"response"`
+}
+
+// DAVResource records the properties of a file on a WebDAV server.
+type DAVResource struct {
+	Size    int64     // Size of the file in bytes
+	ModTime time.Time // Last modification time of the file
+}
+
+// WebDAVSource is a Source reading media or HLS from a WebDAV server like Nextcloud, referenced by URL under the base URL or by path relative to it.
+type WebDAVSource struct {
+	BaseURL  string       // URL of the root folder, like "https://cloud.example.com/remote.php/dav/files/user/"
+	User     string       // User for basic authentication, empty to use the token or no authentication
+	Password string       // Password or app password of the user
+	Token    string       // Bearer token sent when no user is set, empty for none
+	Client   *http.Client // Client used to reach the server, defaults to http.DefaultClient
+	Retries  int          // Times an interrupted read is resumed with a ranged request, defaults to 3
+}
+
+// client returns the client used to reach the server.
+func (s *WebDAVSource) client() *http.Client {
+	if s.Client == nil {
+		return http.DefaultClient
+	}
+
+	return s.Client
+}
+
+// url returns the URL of the reference, which must be under the base URL once cleaned, whether it is a URL or a relative path.
+func (s *WebDAVSource) url(ref string) (string, error) {
+	base, err := url.Parse(strings.TrimSuffix(s.BaseURL, "/") + "/")
+	if err != nil {
+		return "", err
+	}
+
+	u := &url.URL{}
+	*u = *base
+	if strings.Contains(ref, "://") {
+		if u, err = url.Parse(ref); err != nil || u.Scheme != base.Scheme || u.Host != base.Host {
+			return "", ErrUnsupported
+		}
+	} else {
+		u.Path += ref
+	}
+
+	// The cleaned path is checked against the base, so ".." cannot escape it.
+	root := strings.TrimSuffix(base.Path, "/")
+	cleaned := path.Clean("/" + u.Path)
+	if strings.HasSuffix(u.Path, "/") && cleaned != "/" {
+		cleaned += "/"
+	}
+
+	u.Path, u.RawPath = cleaned, ""
+	if u.Path != root && !strings.HasPrefix(u.Path, root+"/") {
+		return "", ErrUnsupported
+	}
+
+	return u.String(), nil
+}
+
+// request sends an authenticated request to the server.
+func (s *WebDAVSource) request(ctx context.Context, method string, uri string, body io.Reader, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, uri, body)
+	if err != nil {
+		return nil, err
+	}
+
+	for key, values := range header {
+		req.Header[key] = values
+	}
+
+	if s.User != "" {
+		req.SetBasicAuth(s.User, s.Password)
+	} else if s.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.Token)
+	}
+
+	resp, err := s.client().Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, ErrNotFound
+	}
+
+	if resp.StatusCode/100 != 2 {
+		resp.Body.Close()
+		return nil, &StatusError{StatusCode: resp.StatusCode}
+	}
+
+	return resp, nil
+}
+
+// Stat returns the properties of the file referenced by the URL or path, returning ErrUnsupported for folders.
+func (s *WebDAVSource) Stat(ctx context.Context, ref string) (DAVResource, error) {
+	uri, err := s.url(ref)
+	if err != nil {
+		return DAVResource{}, err
+	}
+
+	header := http.Header{"Depth": {"0"}, "Content-Type": {"application/xml; charset=utf-8"}}
+	resp, err := s.request(ctx, "PROPFIND", uri, strings.NewReader(propfindBody), header)
+	if err != nil {
+		return DAVResource{}, err
+	}
+	defer resp.Body.Close()
+
+	var multistatus davMultistatus
+	if err := xml.NewDecoder(resp.Body).Decode(&multistatus); err != nil {
+		return DAVResource{}, err
+	}
+
+	for _, response := range multistatus.Responses {
+		for _, propstat := range response.Propstat {
+			if !strings.Contains(propstat.Status, " 200 ") {
+				continue
+			}
+
+			if propstat.Prop.ResourceType.Collection != nil {
+				return DAVResource{}, ErrUnsupported
+			}
+
+			var resource DAVResource
+			resource.Size, _ = strconv.ParseInt(propstat.Prop.ContentLength, 10, 64)
+			resource.ModTime, _ = http.ParseTime(propstat.Prop.LastModified)
+			return resource, nil
+		}
+	}
+
+	return DAVResource{}, ErrNotFound
+}
+
+// Resolve checks that the file exists, computing the duration of HLS playlists.
+func (s *WebDAVSource) Resolve(ctx context.Context, ref string) (Item, error) {
+	uri, err := s.url(ref)
+	if err != nil {
+		return Item{}, err
+	}
+
+	u, err := url.Parse(uri)
+	if err != nil {
+		return Item{}, err
+	}
+
+	name := path.Base(u.Path)
+	item := Item{Ref: uri, Title: strings.TrimSuffix(name, path.Ext(name))}
+
+	if path.Ext(name) != ".m3u8" {
+		if _, err := s.Stat(ctx, uri); err != nil {
+			return Item{}, err
+		}
+
+		return item, nil
+	}
+
+	body, err := s.OpenSegment(ctx, uri)
+	if err != nil {
+		return Item{}, err
+	}
+	defer body.Close()
+
+	manifest, err := hls.ParseHlsManifestReader(body)
+	if err != nil {
+		return Item{}, err
+	}
+
+	item.Duration = manifest.Duration()
+	item.Live = !manifest.HasEndList
+	item.Encryption = manifest.Encryption()
+	return item, nil
+}
+
+// ListRenditions returns the playlist of HLS media, or the URL of the media itself for the converter.
+func (s *WebDAVSource) ListRenditions(ctx context.Context, item Item) ([]Rendition, error) {
+	if _, err := s.url(item.Ref); err != nil {
+		return nil, err
+	}
+
+	return []Rendition{{URI: item.Ref}}, nil
+}
+
+// OpenRange opens the bytes of the file starting at the offset, up to the length or to the end when the length is not positive.
+func (s *WebDAVSource) OpenRange(ctx context.Context, uri string, offset int64, length int64) (io.ReadCloser, error) {
+	uri, err := s.url(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	header := http.Header{}
+	if length > 0 {
+		header.Set("Range", "bytes="+strconv.FormatInt(offset, 10)+"-"+strconv.FormatInt(offset+length-1, 10))
+	} else if offset > 0 {
+		header.Set("Range", "bytes="+strconv.FormatInt(offset, 10)+"-")
+	}
+
+	resp, err := s.request(ctx, http.MethodGet, uri, nil, header)
+	if err != nil {
+		return nil, err
+	}
+
+	if header.Get("Range") != "" && resp.StatusCode != http.StatusPartialContent {
+		resp.Body.Close()
+		return nil, &StatusError{StatusCode: resp.StatusCode}
+	}
+
+	return resp.Body, nil
+}
+
+// OpenSegment opens the file referenced by the URL or path, resuming with ranged requests when the connection fails.
+func (s *WebDAVSource) OpenSegment(ctx context.Context, uri string) (io.ReadCloser, error) {
+	uri, err := s.url(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := s.OpenRange(ctx, uri, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	retries := s.Retries
+	if retries == 0 {
+		retries = 3
+	}
+
+	open := func(offset int64) (io.ReadCloser, error) { return s.OpenRange(ctx, uri, offset, 0) }
+	return &resumableReader{ctx: ctx, open: open, retries: retries, current: current}, nil
+}
@@ -0,0 +1,149 @@
+package source
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newDAVServer starts a WebDAV server serving the files to the user "user" with password "secret"
+func newDAVServer(t *testing.T, files map[string]string, dropFirst *atomic.Bool) *httptest.Server {
+	modTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, password, ok := r.BasicAuth(); !ok || user != "user" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		name := strings.TrimPrefix(r.URL.Path, "/dav")
+		if name == "/folder/" && r.Method == "PROPFIND" {
+			w.WriteHeader(http.StatusMultiStatus)
+			io.WriteString(w, `<d:multistatus xmlns:d="DAV:"><d:response><d:propstat><d:prop><d:resourcetype><d:collection/></d:resourcetype></d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response></d:multistatus>`)
+			return
+		}
+
+		file, ok := files[name]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		switch r.Method {
+		case "PROPFIND":
+			if r.Header.Get("Depth") != "0" {
+				t.Errorf("expected depth 0, got %q", r.Header.Get("Depth"))
+			}
+
+			w.WriteHeader(http.StatusMultiStatus)
+			fmt.Fprintf(w, `<d:multistatus xmlns:d="DAV:"><d:response><d:propstat><d:prop><d:getcontentlength>%d</d:getcontentlength><d:getlastmodified>%s</d:getlastmodified><d:resourcetype/></d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response></d:multistatus>`, len(file), modTime.Format(http.TimeFormat))
+		case http.MethodGet:
+			if r.Header.Get("Range") == "" && dropFirst != nil && dropFirst.CompareAndSwap(true, false) {
+				w.Header().Set("Content-Length", fmt.Sprint(len(file)))
+				io.WriteString(w, file[:4])
+				w.(http.Flusher).Flush()
+				panic(http.ErrAbortHandler)
+			}
+
+			http.ServeContent(w, r, name, modTime, strings.NewReader(file))
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestWebDAVSourceResolve(t *testing.T) {
+	server := newDAVServer(t, map[string]string{
+		"/shows/pilot episode.m3u8": "#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-TARGETDURATION:4\n#EXTINF:4.0,\nseg0.ts\n#EXT-X-ENDLIST\n",
+		"/movie.mp4":                "mp4",
+	}, nil)
+
+	s := &WebDAVSource{BaseURL: server.URL + "/dav", User: "user", Password: "secret"}
+
+	item, err := s.Resolve(context.Background(), "shows/pilot episode.m3u8")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if item.Ref != server.URL+"/dav/shows/pilot%20episode.m3u8" || item.Title != "pilot episode" || item.Duration != 4 || item.Live {
+		t.Errorf("expected a 4 second VOD item, got %+v", item)
+	}
+
+	resource, err := s.Stat(context.Background(), "movie.mp4")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resource.Size != 3 || resource.ModTime.Year() != 2024 {
+		t.Errorf("expected the size and modification time, got %+v", resource)
+	}
+
+	if _, err := s.Resolve(context.Background(), "missing.mp4"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	if _, err := s.Stat(context.Background(), "folder/"); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("expected ErrUnsupported for folders, got %v", err)
+	}
+
+	if _, err := s.Resolve(context.Background(), "https://elsewhere.example.com/movie.mp4"); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("expected ErrUnsupported outside the base URL, got %v", err)
+	}
+
+	for _, ref := range []string{"../movie.mp4", "shows/../../dav2/movie.mp4", server.URL + "/dav/../movie.mp4", server.URL + "/dav/%2e%2e/movie.mp4", server.URL + "/dav2/movie.mp4"} {
+		if _, err := s.Resolve(context.Background(), ref); !errors.Is(err, ErrUnsupported) {
+			t.Errorf("expected ErrUnsupported for %s escaping the base URL, got %v", ref, err)
+		}
+	}
+
+	if item, err := s.Resolve(context.Background(), "shows/../movie.mp4"); err != nil || item.Ref != server.URL+"/dav/movie.mp4" {
+		t.Errorf("expected the cleaned reference, got %+v and %v", item, err)
+	}
+
+	unauthorized := &WebDAVSource{BaseURL: server.URL + "/dav"}
+	var statusError *StatusError
+	if _, err := unauthorized.Resolve(context.Background(), "movie.mp4"); !errors.As(err, &statusError) || statusError.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected unauthorized, got %v", err)
+	}
+}
+
+func TestWebDAVSourceRanges(t *testing.T) {
+	dropFirst := &atomic.Bool{}
+	server := newDAVServer(t, map[string]string{"/long.ts": "0123456789"}, dropFirst)
+	s := &WebDAVSource{BaseURL: server.URL + "/dav/", User: "user", Password: "secret"}
+
+	body, err := s.OpenRange(context.Background(), "long.ts", 2, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := io.ReadAll(body)
+	body.Close()
+	if string(data) != "234" {
+		t.Errorf("expected bytes 2 to 4, got %q", data)
+	}
+
+	dropFirst.Store(true)
+	body, err = s.OpenSegment(context.Background(), "long.ts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer body.Close()
+
+	data, err = io.ReadAll(body)
+	if err != nil || string(data) != "0123456789" {
+		t.Errorf("expected the whole file after resuming, got %q and %v", data, err)
+	}
+
+	if dropFirst.Load() {
+		t.Errorf("expected the first transfer to be cut")
+	}
+}
@@ -0,0 +1,142 @@
+package source
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/url"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// ErrNoFormat indicates that yt-dlp did not select any format for the media.
+var ErrNoFormat = errors.New("no format selected")
+
+// ytdlpInfo is the subset of the yt-dlp JSON output used by the source.
+type ytdlpInfo struct {
+	Title    string  `json:"title"`
+	Duration float64 `json:"duration"`
+	IsLive   bool    `json:"is_live"`
+	URL      string  `json:"url"`
+	Protocol string  `json:"protocol"`
+}
+
+// YTDLPSource is a Source resolving page URLs of video sites into direct media or HLS URLs through yt-dlp.
+type YTDLPSource struct {
+	Binary      string      // Path to the yt-dlp binary, defaults to "yt-dlp"
+	Format      string      // yt-dlp format selector, defaults to a single HLS or progressive format
+	CookiesFile string      // Netscape cookies file passed to yt-dlp, empty for none
+	ExtraArgs   []string    // Extra arguments passed to yt-dlp
+	HTTP        *HTTPSource // Source fetching the resolved URLs, defaults to an HTTPSource with the default client
+
+	prefixArgs []string
+	mutex      sync.Mutex
+	resolved   map[string]ytdlpInfo
+}
+
+// DefaultYTDLPFormat prefers a single HLS format, falling back to the best progressive format with audio and video.
+const DefaultYTDLPFormat = "best[protocol^=m3u8][vcodec!=none][acodec!=none]/best[vcodec!=none][acodec!=none]"
+
+// http returns the source fetching the resolved URLs.
+func (s *YTDLPSource) http() *HTTPSource {
+	if s.HTTP == nil {
+		return &HTTPSource{}
+	}
+
+	return s.HTTP
+}
+
+// run runs yt-dlp for the page URL, returning its JSON output.
+func (s *YTDLPSource) run(ctx context.Context, ref string) (ytdlpInfo, error) {
+	binary := s.Binary
+	if binary == "" {
+		binary = "yt-dlp"
+	}
+
+	format := s.Format
+	if format == "" {
+		format = DefaultYTDLPFormat
+	}
+
+	args := append([]string{}, s.prefixArgs...)
+	args = append(args, "--dump-single-json", "--no-playlist", "--no-warnings", "--format", format)
+	if s.CookiesFile != "" {
+		args = append(args, "--cookies", s.CookiesFile)
+	}
+	args = append(args, s.ExtraArgs...)
+	args = append(args, "--", ref)
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, binary, args...)
+	cmd.Stderr = &stderr
+
+	output, err := cmd.Output()
+	if err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return ytdlpInfo{}, errors.Join(err, errors.New(message))
+		}
+
+		return ytdlpInfo{}, err
+	}
+
+	var info ytdlpInfo
+	if err := json.Unmarshal(output, &info); err != nil {
+		return ytdlpInfo{}, err
+	}
+
+	if info.URL == "" {
+		return ytdlpInfo{}, ErrNoFormat
+	}
+
+	return info, nil
+}
+
+// Resolve runs yt-dlp to resolve the page URL, remembering the direct URL for ListRenditions.
+func (s *YTDLPSource) Resolve(ctx context.Context, ref string) (Item, error) {
+	u, err := url.Parse(ref)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return Item{}, ErrUnsupported
+	}
+
+	info, err := s.run(ctx, ref)
+	if err != nil {
+		return Item{}, err
+	}
+
+	s.mutex.Lock()
+	if s.resolved == nil {
+		s.resolved = make(map[string]ytdlpInfo)
+	}
+	s.resolved[ref] = info
+	s.mutex.Unlock()
+
+	return Item{Ref: ref, Title: info.Title, Duration: info.Duration, Live: info.IsLive}, nil
+}
+
+// ListRenditions returns the renditions of the resolved HLS URL, or the direct media URL as the single rendition, resolving the page again if needed.
+func (s *YTDLPSource) ListRenditions(ctx context.Context, item Item) ([]Rendition, error) {
+	s.mutex.Lock()
+	info, ok := s.resolved[item.Ref]
+	s.mutex.Unlock()
+
+	if !ok {
+		var err error
+		if info, err = s.run(ctx, item.Ref); err != nil {
+			return nil, err
+		}
+	}
+
+	if strings.HasPrefix(info.Protocol, "m3u8") {
+		return s.http().ListRenditions(ctx, Item{Ref: info.URL})
+	}
+
+	return []Rendition{{URI: info.URL}}, nil
+}
+
+// OpenSegment fetches a resolved URL.
+func (s *YTDLPSource) OpenSegment(ctx context.Context, uri string) (io.ReadCloser, error) {
+	return s.http().OpenSegment(ctx, uri)
+}
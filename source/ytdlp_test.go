@@ -0,0 +1,70 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"testing"
+)
+
+// newHelperYTDLP returns a source running this test binary as a fake yt-dlp
+func newHelperYTDLP(t *testing.T, output string) *YTDLPSource {
+	t.Setenv("VRMIX_HELPER_YTDLP", output)
+
+	return &YTDLPSource{Binary: os.Args[0], prefixArgs: []string{"-test.run=TestHelperYTDLP", "--"}, CookiesFile: "cookies.txt"}
+}
+
+// TestHelperYTDLP is not a real test, it acts as yt-dlp when run by newHelperYTDLP
+func TestHelperYTDLP(t *testing.T) {
+	output := os.Getenv("VRMIX_HELPER_YTDLP")
+	if output == "" {
+		return
+	}
+
+	if !slices.Contains(os.Args, "--cookies") {
+		fmt.Fprint(os.Stderr, "missing cookies")
+		os.Exit(1)
+	}
+
+	fmt.Print(output)
+	os.Exit(0)
+}
+
+func TestYTDLPSource(t *testing.T) {
+	origin := newOrigin(t)
+	s := newHelperYTDLP(t, `{"title":"Video","duration":7.65,"is_live":false,"url":"`+origin.URL+`/master.m3u8","protocol":"m3u8_native"}`)
+	s.HTTP = &HTTPSource{Client: origin.Client()}
+	ctx := context.Background()
+
+	item, err := s.Resolve(ctx, "https://video.example/watch?v=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if item.Title != "Video" || item.Duration != 7.65 {
+		t.Errorf("expected Video with duration 7.65, got %s and %f", item.Title, item.Duration)
+	}
+
+	renditions, err := s.ListRenditions(ctx, item)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(renditions) != 2 {
+		t.Errorf("expected the 2 renditions of the master playlist, got %d", len(renditions))
+	}
+}
+
+func TestYTDLPSourceProgressive(t *testing.T) {
+	s := newHelperYTDLP(t, `{"title":"Video","url":"https://cdn.example/video.mp4","protocol":"https"}`)
+
+	renditions, err := s.ListRenditions(context.Background(), Item{Ref: "https://video.example/watch?v=2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(renditions) != 1 || renditions[0].URI != "https://cdn.example/video.mp4" {
+		t.Errorf("expected the direct media URL, got %v", renditions)
+	}
+}
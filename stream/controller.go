@@ -0,0 +1,444 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"vrmix/cache"
+	"vrmix/hls"
+	"vrmix/logging"
+	"vrmix/scheduler"
+	"vrmix/server"
+)
+
+// maxCandidates bounds the upcoming segments offered to the Policy on each playlist request.
+const maxCandidates = 64
+
+// initPrefix prefixes the names of the initialization sections in the stream, followed by the number of the first segment using them.
+const initPrefix = "init"
+
+var (
+	// ErrStreamExists indicates that a stream already has the ID.
+	ErrStreamExists = errors.New("stream already exists")
+
+	// ErrEmptyMedia indicates that the media has no segments to play.
+	ErrEmptyMedia = errors.New("media without segments")
+
+	// ErrMediaNotQueued indicates that no media with the ID is left to play in the queue of the stream.
+	ErrMediaNotQueued = errors.New("media not queued")
+)
+
+// Media represents an item of the queue of a stream, played from its first to its last segment.
+type Media struct {
+	ID        string        // ID of the media, like the logical ID it was resolved from
+	Manifest  *hls.Manifest // Media playlist of the media, its segments being produced by Config.Produce
+	Bandwidth int64         // Peak bits per second of the media, zero if unknown
+}
+
+// Config represents the configuration of a Controller.
+type Config struct {
+	Window    int                  // Segments of the live playlists, defaults to 6
+	Scheduler *scheduler.Scheduler // Scheduler running the jobs producing the segments
+	Cache     cache.Backend        // Cache the jobs store the segments produced into
+	TTL       time.Duration        // Time the segments produced are cached, zero for the TTL of the cache
+	Policy    scheduler.Policy     // Upcoming segments produced ahead of the players
+
+	// Produce produces the segment of the media, like downloading and converting it.
+	Produce func(ctx context.Context, media *Media, segment hls.Segment) ([]byte, error)
+
+	// Logger receives the failures to prefetch the upcoming segments, defaults to slog.Default.
+	Logger *slog.Logger
+}
+
+// segment is a segment of a stream, published in the live window or upcoming.
+type segment struct {
+	number    uint32 // number of the segment in the stream, never reused, which is its media sequence number unless media were removed from the queue
+	sequence  uint32 // media sequence number of the segment once published
+	name      string // name of the segment in the stream, its number with the extension of its source
+	media     *Media
+	source    hls.Segment // segment of the media playlist, or the initialization section as a segment
+	init      *segment    // initialization section of the segment, nil when it needs none
+	users     int         // segments using the initialization section
+	first     bool        // whether the segment starts its media, after a discontinuity
+	gap       bool        // whether the segment failed to be produced, published as a gap so the players skip it
+	published bool        // whether the segment is in the live window
+}
+
+// stream is the live window of a stream and its upcoming segments.
+type stream struct {
+	started   time.Time
+	skew      time.Duration // time the queue was empty, which the timeline of the segments does not count
+	published time.Duration // timeline time at which the last published segment ends
+	window    *hls.LiveWindow
+	live      []*segment          // segments of the live window, in order
+	upcoming  []*segment          // segments not yet published, in order
+	segments  map[uint32]*segment // segments of the live window and upcoming, by number
+	inits     map[uint32]*segment // initialization sections of segments, by the number of the first segment using them
+	sequence  uint32              // media sequence number of the next segment published
+	next      uint32              // number of the next segment enqueued
+}
+
+// duration returns the seconds of a segment as a duration.
+func duration(seconds float32) time.Duration {
+	return time.Duration(float64(seconds) * float64(time.Second))
+}
+
+// Controller plays queues of media as live streams, publishing their segments in the live windows as the wallclock time elapses and producing them through the scheduler, ahead of the players with the Policy.
+//
+// A stream only advances when its playlist is requested, catching up with the elapsed time at once, so idle streams cost nothing.
+type Controller struct {
+	config  Config
+	mutex   sync.Mutex
+	streams map[string]*stream
+	now     func() time.Time
+}
+
+// New creates a new Controller.
+func New(config Config) *Controller {
+	if config.Window <= 0 {
+		config.Window = 6
+	}
+
+	return &Controller{config: config, streams: map[string]*stream{}, now: time.Now}
+}
+
+// Create starts an empty stream, its first media starting when enqueued.
+//
+// The media sequence numbers start at the Unix time of the creation, so a stream created again with the ID does not name its segments as the cached ones of the previous stream.
+func (c *Controller) Create(id string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if _, ok := c.streams[id]; ok {
+		return ErrStreamExists
+	}
+
+	now := c.now()
+	sequence := uint32(now.Unix())
+	c.streams[id] = &stream{
+		started:  now,
+		window:   hls.NewLiveWindow(hls.Manifest{Version: 3, MediaSequence: sequence}, c.config.Window),
+		segments: map[uint32]*segment{},
+		inits:    map[uint32]*segment{},
+		sequence: sequence,
+		next:     sequence,
+	}
+
+	return nil
+}
+
+// Delete removes the stream, returning false if it does not exist.
+func (c *Controller) Delete(id string) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	_, ok := c.streams[id]
+	delete(c.streams, id)
+	return ok
+}
+
+// Enqueue appends the media to the queue of the stream, starting it at once when the queue already played, or returns server.ErrStreamNotFound or ErrEmptyMedia.
+//
+// The keys of the segments are signaled as is, and their initialization sections are published under their own names, the byte ranges of both being read by Produce so the published resources are whole.
+func (c *Controller) Enqueue(id string, media Media) error {
+	if media.Manifest == nil || media.Manifest.SegmentCount() == 0 {
+		return ErrEmptyMedia
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	s := c.streams[id]
+	if s == nil {
+		return server.ErrStreamNotFound
+	}
+
+	s.advance(c.now())
+	if len(s.upcoming) == 0 {
+		// The time elapsed since the end of the queue is skipped, as nothing played then.
+		s.skew = c.now().Sub(s.started) - s.published
+	}
+
+	first := true
+	for _, group := range media.Manifest.SegmentGroups {
+		var init *segment
+		if group.Map.URI != "" {
+			ext := path.Ext(group.Map.URI)
+			if ext == "" {
+				ext = ".mp4"
+			}
+
+			init = &segment{number: s.next, name: initPrefix + strconv.FormatUint(uint64(s.next), 10) + ext, media: &media, source: hls.Segment{Path: group.Map.URI, ByteRange: group.Map.ByteRange}, users: len(group.Segments)}
+			s.inits[init.number] = init
+		}
+
+		for _, source := range group.Segments {
+			seg := &segment{number: s.next, name: strconv.FormatUint(uint64(s.next), 10) + path.Ext(source.Path), media: &media, source: source, init: init, first: first}
+			s.upcoming = append(s.upcoming, seg)
+			s.segments[seg.number] = seg
+			s.next++
+			first = false
+		}
+	}
+
+	return nil
+}
+
+// Remaining advances the stream to the current time and returns the duration of its segments not yet published, or server.ErrStreamNotFound, so a looping queue is enqueued again before it ends.
+func (c *Controller) Remaining(id string) (time.Duration, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	s := c.streams[id]
+	if s == nil {
+		return 0, server.ErrStreamNotFound
+	}
+
+	s.advance(c.now())
+	var remaining time.Duration
+	for _, seg := range s.upcoming {
+		remaining += duration(seg.source.Duration)
+	}
+
+	return remaining, nil
+}
+
+// advance publishes the upcoming segments which ended at the time, sliding the live window, called with the mutex held.
+func (s *stream) advance(now time.Time) {
+	position := now.Sub(s.started) - s.skew
+	for len(s.upcoming) > 0 {
+		seg := s.upcoming[0]
+		end := s.published + duration(seg.source.Duration)
+		if end > position {
+			break
+		}
+
+		if seg.first {
+			s.window.Discontinuity()
+		}
+
+		var init hls.Map
+		if seg.init != nil {
+			init.URI = seg.init.name
+		}
+
+		s.window.SetMap(init)
+		s.window.Append(hls.Segment{Path: seg.name, Duration: seg.source.Duration, Title: seg.source.Title, Keys: seg.source.Keys, Gap: seg.gap})
+		seg.sequence, seg.published = s.sequence, true
+		s.sequence++
+		s.published = end
+		s.live = append(s.live, seg)
+		s.upcoming[0] = nil
+		s.upcoming = s.upcoming[1:]
+	}
+
+	oldest := s.window.Manifest().MediaSequence
+	for len(s.live) > 0 && s.live[0].sequence < oldest {
+		s.forget(s.live[0])
+		s.live[0] = nil
+		s.live = s.live[1:]
+	}
+}
+
+// forget removes the segment from the stream, with its initialization section once no other segment uses it.
+func (s *stream) forget(seg *segment) {
+	delete(s.segments, seg.number)
+	if seg.init == nil {
+		return
+	}
+
+	if seg.init.users--; seg.init.users == 0 {
+		delete(s.inits, seg.init.number)
+	}
+}
+
+// Queue advances the stream to the current time and returns the IDs of the media left to play, starting with the one playing, or server.ErrStreamNotFound.
+func (c *Controller) Queue(id string) ([]string, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	s := c.streams[id]
+	if s == nil {
+		return nil, server.ErrStreamNotFound
+	}
+
+	s.advance(c.now())
+	var queue []string
+	var last *Media
+	for _, seg := range s.upcoming {
+		if seg.media != last {
+			queue = append(queue, seg.media.ID)
+			last = seg.media
+		}
+	}
+
+	return queue, nil
+}
+
+// Remove removes the media with the ID from the queue of the stream, skipping it if it is playing, or returns server.ErrStreamNotFound or ErrMediaNotQueued.
+func (c *Controller) Remove(id string, mediaID string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	s := c.streams[id]
+	if s == nil {
+		return server.ErrStreamNotFound
+	}
+
+	now := c.now()
+	s.advance(now)
+	return s.remove(now, func(media *Media) bool { return media.ID == mediaID })
+}
+
+// Skip stops the media playing on the stream, the next one starting at once, or returns server.ErrStreamNotFound or ErrMediaNotQueued when nothing plays.
+func (c *Controller) Skip(id string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	s := c.streams[id]
+	if s == nil {
+		return server.ErrStreamNotFound
+	}
+
+	now := c.now()
+	s.advance(now)
+	if len(s.upcoming) == 0 {
+		return ErrMediaNotQueued
+	}
+
+	playing := s.upcoming[0].media
+	return s.remove(now, func(media *Media) bool { return media == playing })
+}
+
+// remove removes the upcoming segments of the media matching the function, the next segment starting at the time when the one playing is removed, or returns ErrMediaNotQueued when none matches, called with the mutex held.
+//
+// The numbers of the removed segments are not reused, so the segments cached under their names are never published for another media.
+func (s *stream) remove(now time.Time, matches func(media *Media) bool) error {
+	if len(s.upcoming) == 0 {
+		return ErrMediaNotQueued
+	}
+
+	playing := s.upcoming[0]
+	upcoming := s.upcoming[:0]
+	for _, seg := range s.upcoming {
+		if matches(seg.media) {
+			s.forget(seg)
+		} else {
+			upcoming = append(upcoming, seg)
+		}
+	}
+
+	if len(upcoming) == len(s.upcoming) {
+		return ErrMediaNotQueued
+	}
+
+	clear(s.upcoming[len(upcoming):])
+	s.upcoming = upcoming
+	if len(s.upcoming) == 0 || s.upcoming[0] != playing {
+		// The segment playing is cut short, the timeline resuming from now.
+		s.skew = now.Sub(s.started) - s.published
+		if len(s.upcoming) > 0 {
+			s.upcoming[0].first = true
+		}
+	}
+
+	return nil
+}
+
+// key returns the cache key and the job ID of the segment of the stream.
+func key(id string, name string) string {
+	return id + "/" + name
+}
+
+// job returns the job producing the segment of the stream into the cache, marking it as a gap when it fails.
+func (c *Controller) job(id string, seg *segment) scheduler.Job {
+	return scheduler.Job{ID: key(id, seg.name), Kind: scheduler.KindConversion, Run: func(ctx context.Context) error {
+		data, err := c.config.Produce(ctx, seg.media, seg.source)
+		if err != nil {
+			if ctx.Err() == nil {
+				c.markGap(id, seg)
+			}
+
+			return err
+		}
+
+		return c.config.Cache.Put(ctx, key(id, seg.name), data, c.config.TTL)
+	}}
+}
+
+// markGap marks the segment of the stream as a gap, so the playlists keep being published on schedule while the players skip it instead of stalling on it.
+func (c *Controller) markGap(id string, seg *segment) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	seg.gap = true
+	if s := c.streams[id]; s != nil && seg.published && s.segments[seg.number] == seg {
+		s.window.MarkGap(seg.sequence)
+	}
+}
+
+// Playlist advances the live window of the stream to the current time, submits the jobs producing its upcoming segments, and returns its playlist, or server.ErrStreamNotFound.
+func (c *Controller) Playlist(ctx context.Context, id string) ([]byte, error) {
+	c.mutex.Lock()
+	s := c.streams[id]
+	if s == nil {
+		c.mutex.Unlock()
+		return nil, server.ErrStreamNotFound
+	}
+
+	s.advance(c.now())
+	playlist := s.window.String()
+
+	candidates := make([]scheduler.Candidate, 0, min(len(s.upcoming), maxCandidates))
+	for _, seg := range s.upcoming[:cap(candidates)] {
+		candidates = append(candidates, scheduler.Candidate{Job: c.job(id, seg), Duration: duration(seg.source.Duration), Bitrate: seg.media.Bandwidth})
+	}
+	c.mutex.Unlock()
+
+	if _, err := c.config.Policy.Prefetch(c.config.Scheduler, candidates); err != nil {
+		logging.Or(c.config.Logger).Warn("failed to prefetch upcoming segments", slog.String("stream", id), logging.Err(err))
+	}
+
+	return []byte(playlist), nil
+}
+
+// Segment returns the segment of the stream from the cache, producing it through the scheduler when missing, or returns server.ErrStreamNotFound or server.ErrSegmentNotFound when the segment left the live window or is not part of the stream.
+func (c *Controller) Segment(ctx context.Context, id string, name string) ([]byte, error) {
+	c.mutex.Lock()
+	s := c.streams[id]
+	if s == nil {
+		c.mutex.Unlock()
+		return nil, server.ErrStreamNotFound
+	}
+
+	var seg *segment
+	prefix, _, _ := strings.Cut(name, ".")
+	if number, found := strings.CutPrefix(prefix, initPrefix); found {
+		if number, err := strconv.ParseUint(number, 10, 32); err == nil {
+			seg = s.inits[uint32(number)]
+		}
+	} else if number, err := strconv.ParseUint(prefix, 10, 32); err == nil {
+		seg = s.segments[uint32(number)]
+	}
+	c.mutex.Unlock()
+
+	if seg == nil || seg.name != name {
+		return nil, server.ErrSegmentNotFound
+	}
+
+	if data, err := c.config.Cache.Get(ctx, key(id, name)); err == nil {
+		return data, nil
+	}
+
+	if err := c.config.Scheduler.Do(ctx, c.job(id, seg)); err != nil {
+		return nil, err
+	}
+
+	return c.config.Cache.Get(ctx, key(id, name))
+}
@@ -0,0 +1,345 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"vrmix/cache"
+	"vrmix/hls"
+	"vrmix/scheduler"
+	"vrmix/server"
+)
+
+// base is the first media sequence number of the streams created by the tests, the Unix time of the fixed clock
+const base = 1704067200
+
+// fakeProducer produces each segment as its path, counting the segments produced and failing the ones in failing
+type fakeProducer struct {
+	mutex    sync.Mutex
+	produced map[string]int
+	failing  map[string]bool
+}
+
+func (p *fakeProducer) produce(ctx context.Context, media *Media, segment hls.Segment) ([]byte, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.produced[segment.Path]++
+	if p.failing[segment.Path] {
+		return nil, errors.New("conversion failed")
+	}
+
+	return []byte(media.ID + "/" + segment.Path), nil
+}
+
+// count returns how many times the segment was produced
+func (p *fakeProducer) count(path string) int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	return p.produced[path]
+}
+
+// newController creates a controller with a fixed clock, returned to be advanced, producing the segments into a cache on disk
+func newController(t *testing.T, config Config) (*Controller, *fakeProducer, *time.Time) {
+	t.Helper()
+
+	segments, err := cache.Open(cache.Config{Dir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { segments.Close() })
+
+	s := scheduler.New(scheduler.Config{Workers: 1})
+	t.Cleanup(func() { s.Close(context.Background()) })
+
+	producer := &fakeProducer{produced: map[string]int{}, failing: map[string]bool{}}
+	config.Scheduler, config.Cache, config.Produce = s, segments.Backend(), producer.produce
+	c := New(config)
+
+	now := time.Unix(base, 0)
+	c.now = func() time.Time { return now }
+	return c, producer, &now
+}
+
+// media returns a media of segments of the duration, named from the prefix and extension
+func media(id string, count int, duration float32, extension string) Media {
+	group := hls.SegmentGroup{}
+	for i := range count {
+		group.Segments = append(group.Segments, hls.Segment{Path: id + strconv.Itoa(i) + extension, Duration: duration})
+	}
+
+	return Media{ID: id, Manifest: &hls.Manifest{Version: 3, TargetDuration: uint8(duration), SegmentGroups: []hls.SegmentGroup{group}}}
+}
+
+// playlist returns the parsed playlist of the stream
+func playlist(t *testing.T, c *Controller, id string) hls.Manifest {
+	t.Helper()
+
+	data, err := c.Playlist(context.Background(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	manifest, err := hls.ParseHlsManifest(string(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return manifest
+}
+
+func TestControllerAdvance(t *testing.T) {
+	c, producer, now := newController(t, Config{Window: 3})
+	if err := c.Create("main"); err != nil {
+		t.Fatal(err)
+	}
+
+	if manifest := playlist(t, c, "main"); manifest.SegmentCount() != 0 || manifest.MediaSequence != base {
+		t.Errorf("expected an empty playlist starting at sequence %d, got %+v", base, manifest)
+	}
+
+	c.Enqueue("main", media("a", 3, 4, ".ts"))
+	c.Enqueue("main", media("b", 2, 2, ".m4s"))
+
+	*now = now.Add(5 * time.Second)
+	if manifest := playlist(t, c, "main"); manifest.SegmentCount() != 1 || manifest.SegmentGroups[0].Segments[0].Path != strconv.Itoa(base)+".ts" {
+		t.Errorf("expected the first segment once it ended, got %+v", manifest)
+	}
+
+	*now = now.Add(time.Hour)
+	manifest := playlist(t, c, "main")
+	if manifest.SegmentCount() != 3 || manifest.MediaSequence != base+2 || len(manifest.SegmentGroups) != 2 || manifest.TargetDuration != 4 {
+		t.Errorf("expected the window to slide to the last 3 segments across the discontinuity, got %+v", manifest)
+	}
+
+	name := strconv.Itoa(base+4) + ".m4s"
+	if last := manifest.SegmentGroups[1].Segments[1]; last.Path != name || last.Duration != 2 {
+		t.Errorf("expected the last segment of the second media, got %+v", last)
+	}
+
+	ctx := context.Background()
+	for range 2 {
+		if data, err := c.Segment(ctx, "main", name); err != nil || string(data) != "b/b1.m4s" {
+			t.Errorf("expected the produced segment, got %q and %v", data, err)
+		}
+	}
+
+	if producer.count("b1.m4s") != 1 {
+		t.Errorf("expected the segment to be produced once, got %d", producer.count("b1.m4s"))
+	}
+
+	for _, name := range []string{strconv.Itoa(base) + ".ts", strconv.Itoa(base+4) + ".ts", "index.m4s", "99.m4s"} {
+		if _, err := c.Segment(ctx, "main", name); !errors.Is(err, server.ErrSegmentNotFound) {
+			t.Errorf("expected %s to be missing, got %v", name, err)
+		}
+	}
+
+	if _, err := c.Segment(ctx, "other", name); !errors.Is(err, server.ErrStreamNotFound) {
+		t.Errorf("expected the stream to be missing, got %v", err)
+	}
+}
+
+func TestControllerIdleQueue(t *testing.T) {
+	c, _, now := newController(t, Config{})
+	c.Create("main")
+
+	// Nothing played during the hour the queue was empty, so the media enqueued afterwards starts at once.
+	*now = now.Add(time.Hour)
+	c.Enqueue("main", media("a", 2, 4, ".ts"))
+	if manifest := playlist(t, c, "main"); manifest.SegmentCount() != 0 {
+		t.Errorf("expected no segment before the first one ended, got %+v", manifest)
+	}
+
+	*now = now.Add(4 * time.Second)
+	if manifest := playlist(t, c, "main"); manifest.SegmentCount() != 1 {
+		t.Errorf("expected the first segment once it ended, got %+v", manifest)
+	}
+
+	*now = now.Add(time.Hour)
+	c.Enqueue("main", media("b", 1, 4, ".ts"))
+	*now = now.Add(4 * time.Second)
+	if manifest := playlist(t, c, "main"); manifest.SegmentCount() != 3 || len(manifest.SegmentGroups) != 2 || !strings.HasSuffix(manifest.String(), "#EXTINF:4,\n"+strconv.Itoa(base+2)+".ts\n") {
+		t.Errorf("expected the media enqueued after the queue played to start at once, got %s", manifest.String())
+	}
+}
+
+func TestControllerPrefetch(t *testing.T) {
+	c, producer, _ := newController(t, Config{Policy: scheduler.Policy{Segments: 2}})
+	c.Create("main")
+	c.Enqueue("main", media("a", 4, 4, ".ts"))
+
+	playlist(t, c, "main")
+
+	ctx := context.Background()
+	for i := range 2 {
+		if err := c.config.Scheduler.Await(ctx, "main/"+strconv.Itoa(base+i)+".ts"); err != nil {
+			t.Errorf("expected the upcoming segment %d to be prefetched, got %v", i, err)
+		}
+	}
+
+	if producer.count("a2.ts") != 0 {
+		t.Errorf("expected only the selected segments to be prefetched")
+	}
+
+	if data, err := c.Segment(ctx, "main", strconv.Itoa(base+1)+".ts"); err != nil || string(data) != "a/a1.ts" || producer.count("a1.ts") != 1 {
+		t.Errorf("expected the prefetched segment from the cache, got %q and %v", data, err)
+	}
+}
+
+func TestControllerStreams(t *testing.T) {
+	c, _, _ := newController(t, Config{})
+
+	if err := c.Create("main"); err != nil || !errors.Is(c.Create("main"), ErrStreamExists) {
+		t.Errorf("expected the stream to be created once, got %v", err)
+	}
+
+	if err := c.Enqueue("main", Media{ID: "empty", Manifest: &hls.Manifest{}}); !errors.Is(err, ErrEmptyMedia) {
+		t.Errorf("expected the media without segments to be rejected, got %v", err)
+	}
+
+	if err := c.Enqueue("other", media("a", 1, 4, ".ts")); !errors.Is(err, server.ErrStreamNotFound) {
+		t.Errorf("expected the stream to be missing, got %v", err)
+	}
+
+	c.Enqueue("main", media("a", 3, 4, ".ts"))
+	if remaining, err := c.Remaining("main"); err != nil || remaining != 12*time.Second {
+		t.Errorf("expected the 12 seconds of the queue to remain, got %v and %v", remaining, err)
+	}
+
+	if !c.Delete("main") || c.Delete("main") {
+		t.Errorf("expected the stream to be deleted once")
+	}
+
+	if _, err := c.Playlist(context.Background(), "main"); !errors.Is(err, server.ErrStreamNotFound) {
+		t.Errorf("expected the deleted stream to be missing, got %v", err)
+	}
+
+	if _, err := c.Remaining("main"); !errors.Is(err, server.ErrStreamNotFound) {
+		t.Errorf("expected the deleted stream to be missing, got %v", err)
+	}
+}
+
+func TestControllerGap(t *testing.T) {
+	c, producer, now := newController(t, Config{Policy: scheduler.Policy{Segments: 2}})
+	producer.failing["a1.ts"] = true
+	c.Create("main")
+	c.Enqueue("main", media("a", 3, 4, ".ts"))
+
+	// The failed prefetch is published as a gap once the segment ends, on schedule.
+	playlist(t, c, "main")
+	if err := c.config.Scheduler.Await(context.Background(), "main/"+strconv.Itoa(base+1)+".ts"); err == nil {
+		t.Fatal("expected the prefetch to fail")
+	}
+
+	*now = now.Add(8 * time.Second)
+	if segments := playlist(t, c, "main").SegmentGroups[0].Segments; len(segments) != 2 || segments[0].Gap || !segments[1].Gap {
+		t.Errorf("expected the second segment to be a gap, got %+v", segments)
+	}
+
+	// A published segment failing afterwards, like when requested once evicted, is marked in the live window.
+	*now = now.Add(4 * time.Second)
+	playlist(t, c, "main")
+	c.markGap("main", c.streams["main"].segments[base+2])
+
+	if segments := playlist(t, c, "main").SegmentGroups[0].Segments; len(segments) != 3 || !segments[2].Gap {
+		t.Errorf("expected the published segment to become a gap, got %+v", segments)
+	}
+}
+
+func TestControllerMapsAndKeys(t *testing.T) {
+	c, _, now := newController(t, Config{Window: 2})
+	c.Create("main")
+
+	key := []hls.Key{{Method: hls.MethodAES128, URI: "https://origin/key", IV: "0x00000000000000000000000000000001"}}
+	clear := media("a", 1, 4, ".ts")
+	fmp4 := media("b", 3, 4, ".m4s")
+	fmp4.Manifest.SegmentGroups[0].Map = hls.Map{URI: "https://origin/init.mp4", ByteRange: hls.ByteRange{Length: 10}}
+	fmp4.Manifest.SegmentGroups[0].Segments[0].Keys = key
+	c.Enqueue("main", clear)
+	c.Enqueue("main", fmp4)
+
+	*now = now.Add(8 * time.Second)
+	manifest := playlist(t, c, "main")
+	init := "init" + strconv.Itoa(base+1) + ".mp4"
+	if len(manifest.SegmentGroups) != 2 || manifest.SegmentGroups[0].Map.URI != "" || manifest.SegmentGroups[1].Map.URI != init {
+		t.Fatalf("expected the initialization section of the second media after the discontinuity, got %+v", manifest)
+	}
+
+	if segment := manifest.SegmentGroups[1].Segments[0]; len(segment.Keys) != 1 || segment.Keys[0] != key[0] {
+		t.Errorf("expected the key of the segment, got %+v", segment.Keys)
+	}
+
+	ctx := context.Background()
+	if data, err := c.Segment(ctx, "main", init); err != nil || string(data) != "b/https://origin/init.mp4" {
+		t.Errorf("expected the initialization section to be produced, got %q and %v", data, err)
+	}
+
+	// The initialization section stays published while a segment of the window uses it.
+	*now = now.Add(8 * time.Second)
+	if _, err := c.Segment(ctx, "main", init); err != nil {
+		t.Errorf("expected the initialization section to stay published, got %v", err)
+	}
+
+	c.Enqueue("main", clear)
+	c.Enqueue("main", clear)
+	*now = now.Add(time.Hour)
+	playlist(t, c, "main")
+	if _, err := c.Segment(ctx, "main", init); !errors.Is(err, server.ErrSegmentNotFound) {
+		t.Errorf("expected the initialization section to leave with its segments, got %v", err)
+	}
+}
+
+func TestControllerRemoveAndSkip(t *testing.T) {
+	c, _, now := newController(t, Config{})
+	c.Create("main")
+	c.Enqueue("main", media("a", 3, 4, ".ts"))
+	c.Enqueue("main", media("b", 2, 2, ".ts"))
+	c.Enqueue("main", media("c", 2, 2, ".ts"))
+
+	*now = now.Add(5 * time.Second)
+	if queue, err := c.Queue("main"); err != nil || strings.Join(queue, ",") != "a,b,c" {
+		t.Errorf("expected the queue starting with the media playing, got %v and %v", queue, err)
+	}
+
+	if err := c.Remove("main", "b"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The media playing is cut short, the next one starting at once after a discontinuity.
+	if err := c.Skip("main"); err != nil {
+		t.Fatal(err)
+	}
+
+	if queue, err := c.Queue("main"); err != nil || strings.Join(queue, ",") != "c" {
+		t.Errorf("expected the removed media to leave the queue, got %v and %v", queue, err)
+	}
+
+	*now = now.Add(2 * time.Second)
+	manifest := playlist(t, c, "main")
+	if manifest.SegmentCount() != 2 || len(manifest.SegmentGroups) != 2 || manifest.SegmentGroups[1].Segments[0].Path != strconv.Itoa(base+5)+".ts" {
+		t.Errorf("expected the next media after the skipped segment, keeping its own names, got %+v", manifest)
+	}
+
+	ctx := context.Background()
+	if data, err := c.Segment(ctx, "main", strconv.Itoa(base+5)+".ts"); err != nil || string(data) != "c/c0.ts" {
+		t.Errorf("expected the segment of the next media, got %q and %v", data, err)
+	}
+
+	if _, err := c.Segment(ctx, "main", strconv.Itoa(base+3)+".ts"); !errors.Is(err, server.ErrSegmentNotFound) {
+		t.Errorf("expected the segment of the removed media to be missing, got %v", err)
+	}
+
+	if err := c.Remove("main", "b"); !errors.Is(err, ErrMediaNotQueued) {
+		t.Errorf("expected the removed media to be missing, got %v", err)
+	}
+
+	if err := c.Skip("other"); !errors.Is(err, server.ErrStreamNotFound) {
+		t.Errorf("expected the stream to be missing, got %v", err)
+	}
+}
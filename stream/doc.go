@@ -0,0 +1,2 @@
+// Package stream contains the stream controller, advancing the live window of each stream from the wallclock time when its playlist is requested, without a goroutine per stream.
+package stream
@@ -0,0 +1,97 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"net/url"
+
+	"vrmix/hls"
+	"vrmix/source"
+)
+
+// ErrNoRenditions indicates that the source lists no rendition to play for the media.
+var ErrNoRenditions = errors.New("media without renditions")
+
+// Loader loads the media of the streams from a source, reading the media playlist of one of their renditions, and produces their segments from it, as the Produce of a Config.
+type Loader struct {
+	Source source.Source // Source resolving the references and opening the playlists and segments
+
+	// Select returns the index of the rendition played among the ones of the media, nil to play the first one.
+	Select func(item source.Item, renditions []source.Rendition) int
+}
+
+// Load resolves the reference and reads the media playlist of its selected rendition, its URIs resolved against the URI of the playlist so the segments are opened from the source.
+func (l *Loader) Load(ctx context.Context, ref string) (Media, error) {
+	item, err := l.Source.Resolve(ctx, ref)
+	if err != nil {
+		return Media{}, err
+	}
+
+	renditions, err := l.Source.ListRenditions(ctx, item)
+	if err != nil {
+		return Media{}, err
+	}
+
+	if len(renditions) == 0 {
+		return Media{}, ErrNoRenditions
+	}
+
+	rendition := renditions[0]
+	if l.Select != nil {
+		if i := l.Select(item, renditions); i >= 0 && i < len(renditions) {
+			rendition = renditions[i]
+		}
+	}
+
+	base, err := url.Parse(rendition.URI)
+	if err != nil {
+		return Media{}, err
+	}
+
+	body, err := l.Source.OpenSegment(ctx, rendition.URI)
+	if err != nil {
+		return Media{}, err
+	}
+	defer body.Close()
+
+	manifest, err := hls.ParseHlsManifestReader(body)
+	if err != nil {
+		return Media{}, err
+	}
+
+	// The IVs implied by the media sequence numbers are pinned, as the stream renumbers the segments.
+	manifest.PinIVs()
+	manifest.ResolveAgainst(base)
+	return Media{ID: ref, Manifest: &manifest, Bandwidth: int64(rendition.Bandwidth)}, nil
+}
+
+// Produce reads the segment from the source, keeping only its byte range when it has one.
+func (l *Loader) Produce(ctx context.Context, media *Media, segment hls.Segment) ([]byte, error) {
+	body, err := l.Source.OpenSegment(ctx, segment.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	if segment.ByteRange.Length == 0 {
+		return io.ReadAll(body)
+	}
+
+	if _, err := io.CopyN(io.Discard, body, int64(min(segment.ByteRange.Offset, math.MaxInt64))); err != nil {
+		return nil, err
+	}
+
+	// The length is read up to what the resource holds rather than allocated at once, as it comes from the playlist.
+	data, err := io.ReadAll(io.LimitReader(body, int64(min(segment.ByteRange.Length, math.MaxInt64))))
+	if err != nil {
+		return nil, err
+	}
+
+	if uint64(len(data)) < segment.ByteRange.Length {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	return data, nil
+}
@@ -0,0 +1,79 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"vrmix/hls"
+	"vrmix/source"
+)
+
+// fakeSource resolves every reference to a media with two renditions, serving the resources by URI
+type fakeSource struct {
+	resources map[string]string
+}
+
+func (s *fakeSource) Resolve(ctx context.Context, ref string) (source.Item, error) {
+	if ref == "missing" {
+		return source.Item{}, source.ErrNotFound
+	}
+
+	return source.Item{Ref: ref}, nil
+}
+
+func (s *fakeSource) ListRenditions(ctx context.Context, item source.Item) ([]source.Rendition, error) {
+	return []source.Rendition{{URI: "https://origin/low/index.m3u8", Bandwidth: 1000}, {URI: "https://origin/high/index.m3u8", Bandwidth: 5000}}, nil
+}
+
+func (s *fakeSource) OpenSegment(ctx context.Context, uri string) (io.ReadCloser, error) {
+	data, ok := s.resources[uri]
+	if !ok {
+		return nil, source.ErrNotFound
+	}
+
+	return io.NopCloser(strings.NewReader(data)), nil
+}
+
+func TestLoader(t *testing.T) {
+	src := &fakeSource{resources: map[string]string{
+		"https://origin/high/index.m3u8": "#EXTM3U\n#EXT-X-TARGETDURATION:4\n#EXT-X-MEDIA-SEQUENCE:7\n#EXTINF:4,\na.ts\n#EXTINF:4,\n#EXT-X-BYTERANGE:4@2\n/all.ts\n#EXT-X-ENDLIST\n",
+		"https://origin/high/a.ts":       "segment",
+		"https://origin/all.ts":          "0123456789",
+	}}
+
+	loader := &Loader{Source: src, Select: func(item source.Item, renditions []source.Rendition) int { return 1 }}
+	ctx := context.Background()
+
+	media, err := loader.Load(ctx, "movie")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	segments := media.Manifest.SegmentGroups[0].Segments
+	if media.ID != "movie" || media.Bandwidth != 5000 || segments[0].Path != "https://origin/high/a.ts" || segments[1].Path != "https://origin/all.ts" {
+		t.Errorf("expected the selected rendition with its segments resolved, got %+v", media)
+	}
+
+	if data, err := loader.Produce(ctx, &media, segments[0]); err != nil || string(data) != "segment" {
+		t.Errorf("expected the segment, got %q and %v", data, err)
+	}
+
+	if data, err := loader.Produce(ctx, &media, segments[1]); err != nil || string(data) != "2345" {
+		t.Errorf("expected the byte range of the resource, got %q and %v", data, err)
+	}
+
+	if _, err := loader.Produce(ctx, &media, hls.Segment{Path: "https://origin/all.ts", ByteRange: hls.ByteRange{Length: 8, Offset: 4}}); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("expected the byte range past the end of the resource to fail, got %v", err)
+	}
+
+	if _, err := loader.Load(ctx, "missing"); !errors.Is(err, source.ErrNotFound) {
+		t.Errorf("expected the missing media to fail, got %v", err)
+	}
+
+	if _, err := (&Loader{Source: src}).Load(ctx, "movie"); !errors.Is(err, source.ErrNotFound) {
+		t.Errorf("expected the first rendition, whose playlist is missing, got %v", err)
+	}
+}
@@ -0,0 +1,285 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"sync"
+
+	"vrmix/control"
+	"vrmix/server"
+	"vrmix/session"
+	"vrmix/source"
+)
+
+// ServiceConfig represents the configuration of a Service.
+type ServiceConfig struct {
+	Controller *Controller      // Controller playing the streams of the channels
+	Sessions   *session.Manager // Sessions of the players, nil to list none
+
+	// Load loads the media referenced by an item enqueued on the channel, like Loader.Load with the sources of the channel.
+	Load func(ctx context.Context, channel string, ref string) (Media, error)
+}
+
+// channel is the state of a channel kept by a Service besides its stream.
+type channel struct {
+	name  string
+	items map[string]control.QueueItem // items of the queue by ID, the ID of their media in the stream
+}
+
+// Service implements control.Service over a Controller, a channel being a stream of the controller.
+type Service struct {
+	config   ServiceConfig
+	mutex    sync.Mutex
+	channels map[string]*channel
+	next     uint64 // ID of the next enqueued item
+}
+
+// NewService creates a new Service.
+func NewService(config ServiceConfig) *Service {
+	return &Service{config: config, channels: map[string]*channel{}}
+}
+
+// serviceError converts an error of the controller to a control error.
+func serviceError(err error) error {
+	switch {
+	case errors.Is(err, server.ErrStreamNotFound), errors.Is(err, ErrMediaNotQueued):
+		return fmt.Errorf("%w: %w", control.ErrNotFound, err)
+	case errors.Is(err, ErrStreamExists):
+		return fmt.Errorf("%w: %w", control.ErrAlreadyExists, err)
+	case errors.Is(err, ErrEmptyMedia), errors.Is(err, ErrNoRenditions), errors.Is(err, source.ErrNotFound), errors.Is(err, source.ErrUnsupported):
+		return fmt.Errorf("%w: %w", control.ErrInvalidArgument, err)
+	default:
+		return err
+	}
+}
+
+// queue returns the items left to play on the channel, forgetting the ones which played, called with the mutex held.
+func (s *Service) queue(id string, c *channel) ([]control.QueueItem, error) {
+	ids, err := s.config.Controller.Queue(id)
+	if err != nil {
+		return nil, serviceError(err)
+	}
+
+	queue := make([]control.QueueItem, 0, len(ids))
+	for _, itemID := range ids {
+		if item, ok := c.items[itemID]; ok {
+			queue = append(queue, item)
+		}
+	}
+
+	for itemID := range c.items {
+		if !slices.Contains(ids, itemID) {
+			delete(c.items, itemID)
+		}
+	}
+
+	return queue, nil
+}
+
+// describe returns the channel with its playback state, called with the mutex held.
+func (s *Service) describe(id string, c *channel) (control.Channel, error) {
+	queue, err := s.queue(id, c)
+	if err != nil {
+		return control.Channel{}, err
+	}
+
+	result := control.Channel{ID: id, Name: c.name, State: control.ChannelIdle}
+	if len(queue) > 0 {
+		result.State, result.Current = control.ChannelPlaying, &queue[0]
+	}
+
+	if s.config.Sessions != nil {
+		result.Sessions = len(s.config.Sessions.Sessions(id))
+	}
+
+	return result, nil
+}
+
+// ListChannels returns every channel sorted by ID.
+func (s *Service) ListChannels(ctx context.Context) ([]control.Channel, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	ids := make([]string, 0, len(s.channels))
+	for id := range s.channels {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+
+	channels := make([]control.Channel, 0, len(ids))
+	for _, id := range ids {
+		result, err := s.describe(id, s.channels[id])
+		if err != nil {
+			return nil, err
+		}
+
+		channels = append(channels, result)
+	}
+
+	return channels, nil
+}
+
+// GetChannel returns the channel with the ID, or control.ErrNotFound.
+func (s *Service) GetChannel(ctx context.Context, id string) (control.Channel, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	c := s.channels[id]
+	if c == nil {
+		return control.Channel{}, fmt.Errorf("%w: channel %s", control.ErrNotFound, id)
+	}
+
+	return s.describe(id, c)
+}
+
+// CreateChannel creates the stream of the channel, or returns control.ErrAlreadyExists or control.ErrInvalidArgument without an ID.
+func (s *Service) CreateChannel(ctx context.Context, id string, name string) (control.Channel, error) {
+	if id == "" {
+		return control.Channel{}, fmt.Errorf("%w: empty channel ID", control.ErrInvalidArgument)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.config.Controller.Create(id); err != nil {
+		return control.Channel{}, serviceError(err)
+	}
+
+	c := &channel{name: name, items: map[string]control.QueueItem{}}
+	s.channels[id] = c
+	return s.describe(id, c)
+}
+
+// DeleteChannel removes the stream of the channel, or returns control.ErrNotFound.
+func (s *Service) DeleteChannel(ctx context.Context, id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.channels, id)
+	if !s.config.Controller.Delete(id) {
+		return fmt.Errorf("%w: channel %s", control.ErrNotFound, id)
+	}
+
+	return nil
+}
+
+// ListQueue returns the items left to play on the channel, starting with the one playing, or control.ErrNotFound.
+func (s *Service) ListQueue(ctx context.Context, id string) ([]control.QueueItem, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	c := s.channels[id]
+	if c == nil {
+		return nil, fmt.Errorf("%w: channel %s", control.ErrNotFound, id)
+	}
+
+	return s.queue(id, c)
+}
+
+// Enqueue loads the media of the item, trying its fallbacks in order when its source fails, and appends it to the stream of the channel, returning the item with its ID and the duration of the media.
+func (s *Service) Enqueue(ctx context.Context, id string, item control.QueueItem) (control.QueueItem, error) {
+	if item.Source == "" {
+		return control.QueueItem{}, fmt.Errorf("%w: empty item source", control.ErrInvalidArgument)
+	}
+
+	if _, err := s.GetChannel(ctx, id); err != nil {
+		return control.QueueItem{}, err
+	}
+
+	// The media are loaded without the mutex, as their playlists are read from the sources.
+	var media Media
+	var err error
+	for _, ref := range item.Chain() {
+		if media, err = s.config.Load(ctx, id, ref); err == nil {
+			break
+		}
+	}
+
+	if err != nil {
+		return control.QueueItem{}, serviceError(err)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	c := s.channels[id]
+	if c == nil {
+		return control.QueueItem{}, fmt.Errorf("%w: channel %s", control.ErrNotFound, id)
+	}
+
+	s.next++
+	item.ID = strconv.FormatUint(s.next, 10)
+	media.ID = item.ID
+	if err := s.config.Controller.Enqueue(id, media); err != nil {
+		return control.QueueItem{}, serviceError(err)
+	}
+
+	item.Duration = media.Manifest.Duration()
+
+	c.items[item.ID] = item
+	return item, nil
+}
+
+// RemoveQueueItem removes the item from the stream of the channel, skipping it if it is playing, or returns control.ErrNotFound.
+func (s *Service) RemoveQueueItem(ctx context.Context, id string, itemID string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	c := s.channels[id]
+	if c == nil {
+		return fmt.Errorf("%w: channel %s", control.ErrNotFound, id)
+	}
+
+	if err := s.config.Controller.Remove(id, itemID); err != nil {
+		return serviceError(err)
+	}
+
+	delete(c.items, itemID)
+	return nil
+}
+
+// Skip starts the next item of the channel at once, or returns control.ErrNotFound when nothing plays.
+func (s *Service) Skip(ctx context.Context, id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.channels[id] == nil {
+		return fmt.Errorf("%w: channel %s", control.ErrNotFound, id)
+	}
+
+	return serviceError(s.config.Controller.Skip(id))
+}
+
+// ListSessions returns the sessions watching the channel, or every channel when empty, or control.ErrNotFound.
+func (s *Service) ListSessions(ctx context.Context, id string) ([]control.Session, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var ids []string
+	if id == "" {
+		for id := range s.channels {
+			ids = append(ids, id)
+		}
+		slices.Sort(ids)
+	} else if s.channels[id] == nil {
+		return nil, fmt.Errorf("%w: channel %s", control.ErrNotFound, id)
+	} else {
+		ids = []string{id}
+	}
+
+	sessions := []control.Session{}
+	if s.config.Sessions == nil {
+		return sessions, nil
+	}
+
+	for _, id := range ids {
+		for _, viewer := range s.config.Sessions.Sessions(id) {
+			sessions = append(sessions, control.Session{ID: viewer.ID, Channel: viewer.Stream, Started: viewer.Started, LastSeen: viewer.LastSeen})
+		}
+	}
+
+	return sessions, nil
+}
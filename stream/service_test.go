@@ -0,0 +1,79 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"vrmix/control"
+	"vrmix/source"
+)
+
+func TestService(t *testing.T) {
+	c, _, now := newController(t, Config{})
+	service := NewService(ServiceConfig{Controller: c, Load: func(ctx context.Context, channel string, ref string) (Media, error) {
+		if ref == "missing" {
+			return Media{}, source.ErrNotFound
+		}
+
+		return media(ref, 2, 4, ".ts"), nil
+	}})
+
+	ctx := context.Background()
+	if _, err := service.CreateChannel(ctx, "main", "Main"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := service.CreateChannel(ctx, "main", "Main"); !errors.Is(err, control.ErrAlreadyExists) {
+		t.Errorf("expected the channel to exist, got %v", err)
+	}
+
+	if _, err := service.Enqueue(ctx, "main", control.QueueItem{Source: "missing"}); !errors.Is(err, control.ErrInvalidArgument) {
+		t.Errorf("expected the missing media to be rejected, got %v", err)
+	}
+
+	first, err := service.Enqueue(ctx, "main", control.QueueItem{Source: "missing", Fallbacks: []string{"a"}, Title: "A"})
+	if err != nil || first.ID == "" || first.Duration != 8 {
+		t.Fatalf("expected the fallback to be enqueued with its duration, got %+v and %v", first, err)
+	}
+
+	second, _ := service.Enqueue(ctx, "main", control.QueueItem{Source: "b"})
+	third, _ := service.Enqueue(ctx, "main", control.QueueItem{Source: "c"})
+	if channel, err := service.GetChannel(ctx, "main"); err != nil || channel.State != control.ChannelPlaying || channel.Current.ID != first.ID || channel.Name != "Main" {
+		t.Errorf("expected the channel to play the first item, got %+v and %v", channel, err)
+	}
+
+	if err := service.RemoveQueueItem(ctx, "main", second.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := service.Skip(ctx, "main"); err != nil {
+		t.Fatal(err)
+	}
+
+	if queue, err := service.ListQueue(ctx, "main"); err != nil || len(queue) != 1 || queue[0].ID != third.ID {
+		t.Errorf("expected only the third item left, got %+v and %v", queue, err)
+	}
+
+	*now = now.Add(time.Minute)
+	if channel, _ := service.GetChannel(ctx, "main"); channel.State != control.ChannelIdle || channel.Current != nil {
+		t.Errorf("expected the channel to be idle once the queue played, got %+v", channel)
+	}
+
+	if err := service.RemoveQueueItem(ctx, "main", third.ID); !errors.Is(err, control.ErrNotFound) {
+		t.Errorf("expected the played item to be missing, got %v", err)
+	}
+
+	if err := service.DeleteChannel(ctx, "main"); err != nil {
+		t.Fatal(err)
+	}
+
+	if channels, err := service.ListChannels(ctx); err != nil || len(channels) != 0 {
+		t.Errorf("expected no channel left, got %+v and %v", channels, err)
+	}
+
+	if _, err := service.Enqueue(ctx, "main", control.QueueItem{Source: "a"}); !errors.Is(err, control.ErrNotFound) {
+		t.Errorf("expected the deleted channel to be missing, got %v", err)
+	}
+}
@@ -0,0 +1,2 @@
+// Package tiled packages VR video into tiles at several qualities, experimentally letting clients fetch high quality only for the tiles in their viewport.
+package tiled
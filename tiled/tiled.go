@@ -0,0 +1,310 @@
+package tiled
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"vrmix/hls"
+)
+
+const (
+	// TileAttribute is the vendor variant attribute naming the tile of a variant.
+	TileAttribute = "X-VRMIX-TILE"
+
+	// RegionAttribute is the vendor variant attribute locating the tile in the frame, as the x, y, width and height fractions of the frame.
+	RegionAttribute = "X-VRMIX-TILE-REGION"
+
+	// QualityAttribute is the vendor variant attribute naming the quality of a variant.
+	QualityAttribute = "X-VRMIX-QUALITY"
+
+	// AudioGroup is the group of the audio rendition shared by every tile.
+	AudioGroup = "audio"
+
+	// PlaylistName is the name of the media playlists written in the directory of each tile and quality.
+	PlaylistName = "index.m3u8"
+
+	// MasterName is the name of the master playlist referencing every tile.
+	MasterName = "master.m3u8"
+)
+
+var (
+	// ErrNoTiles indicates that no tile or quality was configured.
+	ErrNoTiles = errors.New("no tiles or qualities")
+
+	// ErrInvalidTile indicates that a tile is outside the frame or has an unsafe name.
+	ErrInvalidTile = errors.New("invalid tile")
+)
+
+// Tile represents a region of the frame packaged as its own playlists, located by fractions of the frame.
+type Tile struct {
+	Name   string  // Name of the tile, used as the directory of its playlists
+	X      float64 // Left edge of the tile, as a fraction of the frame width
+	Y      float64 // Top edge of the tile, as a fraction of the frame height
+	Width  float64 // Width of the tile, as a fraction of the frame width
+	Height float64 // Height of the tile, as a fraction of the frame height
+}
+
+// Quality represents an encoding of every tile.
+type Quality struct {
+	Name    string // Name of the quality, used as the directory of its playlists
+	Bitrate int    // Video bits per second of each tile
+}
+
+// Grid returns the tiles splitting an equirectangular frame into columns of longitude and rows of latitude.
+func Grid(columns int, rows int) []Tile {
+	tiles := make([]Tile, 0, columns*rows)
+	for row := range rows {
+		for column := range columns {
+			tiles = append(tiles, Tile{
+				Name:   "r" + strconv.Itoa(row) + "c" + strconv.Itoa(column),
+				X:      float64(column) / float64(columns),
+				Y:      float64(row) / float64(rows),
+				Width:  1 / float64(columns),
+				Height: 1 / float64(rows),
+			})
+		}
+	}
+
+	return tiles
+}
+
+// CubemapFaces returns the faces of a cubemap frame laid out in 3x2, as written by the c3x2 output of the ffmpeg v360 filter.
+func CubemapFaces() []Tile {
+	names := []string{"right", "left", "up", "down", "front", "back"}
+	tiles := make([]Tile, len(names))
+	for i, name := range names {
+		tiles[i] = Tile{Name: name, X: float64(i%3) / 3, Y: float64(i/3) / 2, Width: 1.0 / 3, Height: 0.5}
+	}
+
+	return tiles
+}
+
+// region returns the value of the region attribute of the tile.
+func (t Tile) region() string {
+	values := []float64{t.X, t.Y, t.Width, t.Height}
+	parts := make([]string, len(values))
+	for i, value := range values {
+		parts[i] = strconv.FormatFloat(value, 'f', 4, 64)
+	}
+
+	return strings.Join(parts, ",")
+}
+
+// valid returns true if the tile is inside the frame and its name is a single path element.
+func (t Tile) valid() bool {
+	return t.Name != "" && !strings.ContainsAny(t.Name, `/\"`) && t.Name != "." && t.Name != ".." &&
+		t.X >= 0 && t.Y >= 0 && t.Width > 0 && t.Height > 0 && t.X+t.Width <= 1.0001 && t.Y+t.Height <= 1.0001
+}
+
+// Visible returns true if the tile of an equirectangular frame intersects the viewport centered on the yaw and pitch, in degrees, with the horizontal and vertical fields of view.
+func (t Tile) Visible(yaw float64, pitch float64, hfov float64, vfov float64) bool {
+	// Longitude spans -180 to 180 degrees from left to right, latitude 90 to -90 degrees from top to bottom.
+	left, right := t.X*360-180, (t.X+t.Width)*360-180
+	top, bottom := 90-t.Y*180, 90-(t.Y+t.Height)*180
+
+	if pitch+vfov/2 < bottom || pitch-vfov/2 > top {
+		return false
+	}
+
+	// Near the poles every longitude is visible.
+	if pitch+vfov/2 >= 90 || pitch-vfov/2 <= -90 {
+		return true
+	}
+
+	center := (left + right) / 2
+	distance := math.Abs(math.Mod(yaw-center+540, 360) - 180)
+	return distance <= (right-left)/2+hfov/2
+}
+
+// Packager packages a VR video into tiles, each one encoded at every quality in its own media playlist, with a master playlist referencing them.
+type Packager struct {
+	Binary          string         // Path to the ffmpeg binary, defaults to "ffmpeg"
+	Tiles           []Tile         // Tiles of the frame, like Grid(4, 2) or CubemapFaces()
+	Qualities       []Quality      // Qualities of each tile, from the highest to the lowest
+	Projection      hls.Projection // Projection of the frame, signaled on the variants
+	SegmentDuration time.Duration  // Target duration of the segments, defaults to 2 seconds for fast viewport switching
+	VideoArgs       []string       // Video codec arguments of each tile, defaults to H.264 with a keyframe on every segment
+
+	prefixArgs []string
+}
+
+// check returns an error when the tiles or the qualities are invalid.
+func (p *Packager) check() error {
+	if len(p.Tiles) == 0 || len(p.Qualities) == 0 {
+		return ErrNoTiles
+	}
+
+	for _, tile := range p.Tiles {
+		if !tile.valid() {
+			return fmt.Errorf("%w: %s", ErrInvalidTile, tile.Name)
+		}
+	}
+
+	for _, quality := range p.Qualities {
+		if !(Tile{Name: quality.Name, Width: 1, Height: 1}).valid() {
+			return fmt.Errorf("%w: quality %s", ErrInvalidTile, quality.Name)
+		}
+	}
+
+	return nil
+}
+
+// hlsArgs returns the arguments of an HLS output written in the directory.
+func (p *Packager) hlsArgs(dir string) []string {
+	return []string{
+		"-f", "hls",
+		"-hls_time", strconv.FormatFloat(hls.SegmentDuration(p.SegmentDuration, 2*time.Second).Seconds(), 'f', -1, 64),
+		"-hls_playlist_type", "vod",
+		"-hls_segment_filename", filepath.Join(dir, "segment%d.ts"),
+		filepath.Join(dir, PlaylistName),
+	}
+}
+
+// args returns the arguments of ffmpeg splitting the input into every tile at every quality, plus the audio.
+func (p *Packager) args(input string, dir string) []string {
+	outputs := len(p.Tiles) * len(p.Qualities)
+
+	var filter strings.Builder
+	filter.WriteString("[0:v]split=" + strconv.Itoa(outputs))
+	for i := range outputs {
+		filter.WriteString("[s" + strconv.Itoa(i) + "]")
+	}
+
+	for i := range outputs {
+		tile := p.Tiles[i/len(p.Qualities)]
+		fmt.Fprintf(&filter, ";[s%d]crop=trunc(iw*%.6g/2)*2:trunc(ih*%.6g/2)*2:iw*%.6g:ih*%.6g[t%d]", i, tile.Width, tile.Height, tile.X, tile.Y, i)
+	}
+
+	videoArgs := p.VideoArgs
+	if videoArgs == nil {
+		keyframes := "expr:gte(t,n_forced*" + strconv.FormatFloat(hls.SegmentDuration(p.SegmentDuration, 2*time.Second).Seconds(), 'f', -1, 64) + ")"
+		videoArgs = []string{"-c:v", "libx264", "-preset", "veryfast", "-force_key_frames", keyframes, "-sc_threshold", "0"}
+	}
+
+	args := append([]string{}, p.prefixArgs...)
+	args = append(args, "-hide_banner", "-loglevel", "error", "-nostdin", "-i", input, "-filter_complex", filter.String())
+
+	for i := range outputs {
+		tile, quality := p.Tiles[i/len(p.Qualities)], p.Qualities[i%len(p.Qualities)]
+		args = append(args, "-map", "[t"+strconv.Itoa(i)+"]", "-an")
+		args = append(args, videoArgs...)
+		args = append(args, "-b:v", strconv.Itoa(quality.Bitrate))
+		args = append(args, p.hlsArgs(filepath.Join(dir, tile.Name, quality.Name))...)
+	}
+
+	args = append(args, "-map", "0:a:0?", "-vn", "-c:a", "aac")
+	return append(args, p.hlsArgs(filepath.Join(dir, "audio"))...)
+}
+
+// Master returns the master playlist referencing every tile at every quality, each variant carrying its tile, region and quality.
+func (p *Packager) Master() hls.MasterManifest {
+	master := hls.MasterManifest{
+		Version: 7,
+		Renditions: []hls.Rendition{{
+			Type: hls.MediaAudio, GroupID: AudioGroup, Name: "Audio", Default: true, AutoSelect: true,
+			URI: path.Join("audio", PlaylistName),
+		}},
+	}
+
+	for _, tile := range p.Tiles {
+		for _, quality := range p.Qualities {
+			master.Variants = append(master.Variants, hls.Variant{
+				URI:        path.Join(tile.Name, quality.Name, PlaylistName),
+				Bandwidth:  quality.Bitrate,
+				Projection: p.Projection,
+				Audio:      AudioGroup,
+				Attributes: []hls.Attribute{
+					{Key: TileAttribute, Value: tile.Name, Quoted: true},
+					{Key: RegionAttribute, Value: tile.region(), Quoted: true},
+					{Key: QualityAttribute, Value: quality.Name, Quoted: true},
+				},
+			})
+		}
+	}
+
+	return master
+}
+
+// Package encodes the input into the directory, writing the playlists of every tile and quality and the master playlist, blocking until the input ends.
+func (p *Packager) Package(ctx context.Context, input string, dir string) error {
+	if err := p.check(); err != nil {
+		return err
+	}
+
+	for _, tile := range p.Tiles {
+		for _, quality := range p.Qualities {
+			if err := os.MkdirAll(filepath.Join(dir, tile.Name, quality.Name), 0o755); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Join(dir, "audio"), 0o755); err != nil {
+		return err
+	}
+
+	binary := p.Binary
+	if binary == "" {
+		binary = "ffmpeg"
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, binary, p.args(input, dir)...)
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return errors.Join(err, errors.New(message))
+		}
+
+		return err
+	}
+
+	master := p.Master()
+	return os.WriteFile(filepath.Join(dir, MasterName), []byte(master.String()), 0o644)
+}
+
+// ParseTile returns the tile and quality carried by a variant of a tiled master playlist, false when the variant is not a tile.
+func ParseTile(variant hls.Variant) (Tile, string, bool) {
+	var tile Tile
+	var quality, region string
+	for _, attribute := range variant.Attributes {
+		switch attribute.Key {
+		case TileAttribute:
+			tile.Name = attribute.Value
+		case RegionAttribute:
+			region = attribute.Value
+		case QualityAttribute:
+			quality = attribute.Value
+		}
+	}
+
+	values := strings.Split(region, ",")
+	if tile.Name == "" || len(values) != 4 {
+		return Tile{}, "", false
+	}
+
+	for i, target := range []*float64{&tile.X, &tile.Y, &tile.Width, &tile.Height} {
+		value, err := strconv.ParseFloat(values[i], 64)
+		if err != nil {
+			return Tile{}, "", false
+		}
+		*target = value
+	}
+
+	return tile, quality, tile.valid()
+}
@@ -0,0 +1,107 @@
+package tiled
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"vrmix/hls"
+)
+
+func TestGrid(t *testing.T) {
+	tiles := Grid(4, 2)
+	if len(tiles) != 8 || tiles[5].Name != "r1c1" || tiles[5].X != 0.25 || tiles[5].Y != 0.5 {
+		t.Fatalf("expected a 4x2 grid, got %+v", tiles)
+	}
+
+	var visible []string
+	for _, tile := range tiles {
+		if tile.Visible(0, 0, 90, 60) {
+			visible = append(visible, tile.Name)
+		}
+	}
+
+	if !slices.Equal(visible, []string{"r0c1", "r0c2", "r1c1", "r1c2"}) {
+		t.Errorf("expected the front tiles to be visible, got %v", visible)
+	}
+
+	if !tiles[0].Visible(180, 0, 90, 60) || !tiles[3].Visible(-180, 0, 90, 60) {
+		t.Error("expected the viewport to wrap around the back of the sphere")
+	}
+
+	if !tiles[2].Visible(-170, 80, 90, 60) {
+		t.Error("expected every top tile to be visible when looking at the pole")
+	}
+}
+
+// TestHelperFFmpeg is not a real test, it acts as ffmpeg when run by TestPackager
+func TestHelperFFmpeg(t *testing.T) {
+	if os.Getenv("VRMIX_HELPER_FFMPEG") == "" {
+		return
+	}
+
+	if !slices.Contains(os.Args, "-filter_complex") {
+		fmt.Fprint(os.Stderr, "missing filter")
+		os.Exit(1)
+	}
+
+	os.Exit(0)
+}
+
+func TestPackager(t *testing.T) {
+	t.Setenv("VRMIX_HELPER_FFMPEG", "1")
+	p := &Packager{
+		Binary:     os.Args[0],
+		Tiles:      CubemapFaces(),
+		Qualities:  []Quality{{Name: "high", Bitrate: 8000000}, {Name: "low", Bitrate: 1000000}},
+		Projection: hls.ProjectionCubemap,
+		prefixArgs: []string{"-test.run=TestHelperFFmpeg", "--"},
+	}
+
+	args := strings.Join(p.args("input.mp4", "/out"), " ")
+	for _, expected := range []string{"split=12", "[s11]crop=trunc(iw*0.333333/2)*2:trunc(ih*0.5/2)*2:iw*0.666667:ih*0.5[t11]", "-map [t1] -an", "/out/front/low/index.m3u8", "-map 0:a:0? -vn"} {
+		if !strings.Contains(args, expected) {
+			t.Errorf("expected %q in %q", expected, args)
+		}
+	}
+
+	dir := t.TempDir()
+	if err := p.Package(context.Background(), "input.mp4", dir); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, MasterName))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	master, err := hls.ParseMasterManifest(string(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(master.Variants) != 12 || len(master.Renditions) != 1 {
+		t.Fatalf("expected 12 tile variants and the audio, got %d and %d", len(master.Variants), len(master.Renditions))
+	}
+
+	tile, quality, ok := ParseTile(master.Variants[9])
+	if !ok || tile.Name != "front" || quality != "low" || tile.X < 0.33 || tile.Y != 0.5 || master.Variants[9].Projection != hls.ProjectionCubemap {
+		t.Errorf("expected the low front face, got %+v %s", tile, quality)
+	}
+}
+
+func TestPackagerInvalid(t *testing.T) {
+	p := &Packager{Tiles: []Tile{{Name: "../escape", Width: 1, Height: 1}}, Qualities: []Quality{{Name: "high"}}}
+	if err := p.Package(context.Background(), "input.mp4", t.TempDir()); !errors.Is(err, ErrInvalidTile) {
+		t.Errorf("expected an invalid tile, got %v", err)
+	}
+
+	if err := (&Packager{}).Package(context.Background(), "input.mp4", t.TempDir()); !errors.Is(err, ErrNoTiles) {
+		t.Errorf("expected no tiles, got %v", err)
+	}
+}
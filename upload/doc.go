@@ -0,0 +1,2 @@
+// Package upload contains the authenticated endpoint receiving media files, in one request or resumable with the tus protocol, and registering them as queueable items.
+package upload
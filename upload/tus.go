@@ -0,0 +1,272 @@
+package upload
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TusVersion is the version of the tus protocol implemented.
+const TusVersion = "1.0.0"
+
+// tusInfo is the description of a tus upload in progress, persisted next to its data so uploads survive restarts.
+type tusInfo struct {
+	Name    string    `json:"name"`    // Name of the uploaded file
+	Length  int64     `json:"length"`  // Announced size of the file in bytes
+	Created time.Time `json:"created"` // Creation time of the upload
+}
+
+// infoPath returns the path of the description of an upload in progress.
+func (h *Handler) infoPath(id string) string {
+	return filepath.Join(h.Dir, id+".json")
+}
+
+// loadInfo loads the description of an upload in progress, returning its current offset.
+func (h *Handler) loadInfo(id string) (tusInfo, int64, error) {
+	if id == "" || strings.ContainsAny(id, `/\.`) {
+		return tusInfo{}, 0, ErrUploadNotFound
+	}
+
+	data, err := os.ReadFile(h.infoPath(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return tusInfo{}, 0, ErrUploadNotFound
+	} else if err != nil {
+		return tusInfo{}, 0, err
+	}
+
+	var info tusInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		return tusInfo{}, 0, err
+	}
+
+	stat, err := os.Stat(h.partPath(id))
+	if err != nil {
+		return tusInfo{}, 0, ErrUploadNotFound
+	}
+
+	return info, stat.Size(), nil
+}
+
+// removeUpload removes the data and description of an upload in progress.
+func (h *Handler) removeUpload(id string) {
+	os.Remove(h.partPath(id))
+	os.Remove(h.infoPath(id))
+}
+
+// removeExpired removes the uploads in progress older than the expiry.
+func (h *Handler) removeExpired() {
+	expiry := h.Expiry
+	if expiry <= 0 {
+		expiry = 24 * time.Hour
+	}
+
+	entries, _ := os.ReadDir(h.Dir)
+	for _, entry := range entries {
+		id, found := strings.CutSuffix(entry.Name(), ".json")
+		if !found {
+			continue
+		}
+
+		if info, _, err := h.loadInfo(id); err == nil && time.Since(info.Created) > expiry {
+			h.removeUpload(id)
+		}
+	}
+}
+
+// tusHeaders sets the headers sent on every tus response.
+func tusHeaders(w http.ResponseWriter) {
+	w.Header().Set("Tus-Resumable", TusVersion)
+	w.Header().Set("Cache-Control", "no-store")
+}
+
+// checkTus rejects requests of other tus versions.
+func checkTus(w http.ResponseWriter, r *http.Request) bool {
+	tusHeaders(w)
+
+	if r.Header.Get("Tus-Resumable") != TusVersion {
+		w.Header().Set("Tus-Version", TusVersion)
+		w.WriteHeader(http.StatusPreconditionFailed)
+		return false
+	}
+
+	return true
+}
+
+// parseMetadata parses the Upload-Metadata header, made of comma separated keys and base64 values.
+func parseMetadata(header string) map[string]string {
+	metadata := map[string]string{}
+
+	for pair := range strings.SplitSeq(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		decoded, err := base64.StdEncoding.DecodeString(value)
+		if key != "" && err == nil {
+			metadata[key] = string(decoded)
+		}
+	}
+
+	return metadata
+}
+
+// tusOptions announces the supported version and extensions.
+func (h *Handler) tusOptions(w http.ResponseWriter, r *http.Request) {
+	tusHeaders(w)
+	w.Header().Set("Tus-Version", TusVersion)
+	w.Header().Set("Tus-Extension", "creation,termination")
+	w.Header().Set("Tus-Max-Size", strconv.FormatInt(h.maxSize(), 10))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// tusCreate creates an upload with the length and file name given in the headers.
+func (h *Handler) tusCreate(w http.ResponseWriter, r *http.Request) {
+	if !checkTus(w, r) {
+		return
+	}
+
+	length, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+	if err != nil || length < 0 {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid Upload-Length"})
+		return
+	}
+
+	if length > h.maxSize() {
+		writeError(w, ErrTooLarge)
+		return
+	}
+
+	name := parseMetadata(r.Header.Get("Upload-Metadata"))["filename"]
+	if err := h.checkName(name); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	h.removeExpired()
+
+	if err := os.MkdirAll(h.Dir, 0o755); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	id := newID()
+	data, _ := json.Marshal(tusInfo{Name: name, Length: length, Created: time.Now()})
+	if err := os.WriteFile(h.partPath(id), nil, 0o644); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := os.WriteFile(h.infoPath(id), data, 0o644); err != nil {
+		h.removeUpload(id)
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Location", strings.TrimSuffix(r.URL.Path, "/")+"/"+id)
+	w.WriteHeader(http.StatusCreated)
+}
+
+// tusHead returns the offset of an upload, so the client can resume it.
+func (h *Handler) tusHead(w http.ResponseWriter, r *http.Request) {
+	if !checkTus(w, r) {
+		return
+	}
+
+	info, offset, err := h.loadInfo(r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Upload-Offset", strconv.FormatInt(offset, 10))
+	w.Header().Set("Upload-Length", strconv.FormatInt(info.Length, 10))
+	w.WriteHeader(http.StatusOK)
+}
+
+// tusPatch appends the body at the offset of an upload, completing it when all the bytes were received.
+func (h *Handler) tusPatch(w http.ResponseWriter, r *http.Request) {
+	if !checkTus(w, r) {
+		return
+	}
+
+	if r.Header.Get("Content-Type") != "application/offset+octet-stream" {
+		writeJSON(w, http.StatusUnsupportedMediaType, errorResponse{Error: "expected application/offset+octet-stream"})
+		return
+	}
+
+	id := r.PathValue("id")
+	lock, ok := h.lock(id)
+	if !ok {
+		writeJSON(w, http.StatusLocked, errorResponse{Error: "upload in use"})
+		return
+	}
+	defer lock.Unlock()
+
+	info, offset, err := h.loadInfo(id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if given, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64); err != nil || given != offset {
+		writeJSON(w, http.StatusConflict, errorResponse{Error: "offset mismatch"})
+		return
+	}
+
+	file, err := os.OpenFile(h.partPath(id), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	written, err := io.Copy(file, io.LimitReader(r.Body, info.Length-offset))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	offset += written
+	w.Header().Set("Upload-Offset", strconv.FormatInt(offset, 10))
+
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+
+	if offset == info.Length {
+		os.Remove(h.infoPath(id))
+
+		if _, err := h.complete(r.Context(), id, info.Name, h.partPath(id)); err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// tusDelete terminates an upload, removing the bytes received.
+func (h *Handler) tusDelete(w http.ResponseWriter, r *http.Request) {
+	if !checkTus(w, r) {
+		return
+	}
+
+	id := r.PathValue("id")
+	lock, ok := h.lock(id)
+	if !ok {
+		writeJSON(w, http.StatusLocked, errorResponse{Error: "upload in use"})
+		return
+	}
+	defer lock.Unlock()
+
+	if _, _, err := h.loadInfo(id); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	h.removeUpload(id)
+	w.WriteHeader(http.StatusNoContent)
+}
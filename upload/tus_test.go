@@ -0,0 +1,141 @@
+package upload
+
+import (
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// tusRequest returns an authorized tus request
+func tusRequest(method string, target string, body io.Reader) *http.Request {
+	r := httptest.NewRequest(method, target, body)
+	r.Header.Set("Authorization", "Bearer secret")
+	r.Header.Set("Tus-Resumable", TusVersion)
+	return r
+}
+
+// tusCreate creates an upload of the length, returning its location
+func tusCreate(t *testing.T, h *Handler, name string, length string) string {
+	r := tusRequest(http.MethodPost, "/tus", nil)
+	r.Header.Set("Upload-Length", length)
+	r.Header.Set("Upload-Metadata", "filename "+base64.StdEncoding.EncodeToString([]byte(name)))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", w.Code, w.Body)
+	}
+
+	return w.Header().Get("Location")
+}
+
+// tusPatch sends a chunk at the offset, returning the response
+func tusPatch(h *Handler, location string, offset string, chunk string) *httptest.ResponseRecorder {
+	r := tusRequest(http.MethodPatch, location, strings.NewReader(chunk))
+	r.Header.Set("Content-Type", "application/offset+octet-stream")
+	r.Header.Set("Upload-Offset", offset)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestTusUpload(t *testing.T) {
+	h, media := newHandler(t)
+
+	location := tusCreate(t, h, "movie.mkv", "10")
+	if !strings.HasPrefix(location, "/tus/") {
+		t.Fatalf("expected a location under /tus, got %s", location)
+	}
+
+	if w := tusPatch(h, location, "0", "hello"); w.Code != http.StatusNoContent || w.Header().Get("Upload-Offset") != "5" {
+		t.Fatalf("expected offset 5, got status %d and offset %s", w.Code, w.Header().Get("Upload-Offset"))
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, tusRequest(http.MethodHead, location, nil))
+
+	if w.Header().Get("Upload-Offset") != "5" || w.Header().Get("Upload-Length") != "10" {
+		t.Errorf("expected offset 5 of 10, got %s of %s", w.Header().Get("Upload-Offset"), w.Header().Get("Upload-Length"))
+	}
+
+	if w := tusPatch(h, location, "3", "lo wo"); w.Code != http.StatusConflict {
+		t.Errorf("expected status 409 for a wrong offset, got %d", w.Code)
+	}
+
+	if w := tusPatch(h, location, "5", " worldextra"); w.Code != http.StatusNoContent || w.Header().Get("Upload-Offset") != "10" {
+		t.Fatalf("expected offset 10, got status %d and offset %s", w.Code, w.Header().Get("Upload-Offset"))
+	}
+
+	if data, err := os.ReadFile(filepath.Join(media, "movie.mkv")); err != nil || string(data) != "hello worl" {
+		t.Errorf("expected the file in the storage, got %q and %v", data, err)
+	}
+
+	id := strings.TrimPrefix(location, "/tus/")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, tusRequest(http.MethodGet, "/uploads/"+id, nil))
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected the completed upload, got status %d", w.Code)
+	}
+}
+
+func TestTusTerminate(t *testing.T) {
+	h, _ := newHandler(t)
+	location := tusCreate(t, h, "movie.mkv", "10")
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, tusRequest(http.MethodDelete, location, nil))
+
+	if w.Code != http.StatusNoContent {
+		t.Errorf("expected status 204, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, tusRequest(http.MethodHead, location, nil))
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 after termination, got %d", w.Code)
+	}
+
+	if entries, _ := os.ReadDir(h.Dir); len(entries) != 0 {
+		t.Errorf("expected no leftover file, got %d", len(entries))
+	}
+}
+
+func TestTusRejected(t *testing.T) {
+	h, _ := newHandler(t)
+	h.MaxSize = 5
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodOptions, "/tus", nil))
+
+	if w.Code != http.StatusNoContent || w.Header().Get("Tus-Max-Size") != "5" {
+		t.Errorf("expected the capabilities without credentials, got status %d", w.Code)
+	}
+
+	r := tusRequest(http.MethodPost, "/tus", nil)
+	r.Header.Set("Upload-Length", "10")
+	r.Header.Set("Upload-Metadata", "filename "+base64.StdEncoding.EncodeToString([]byte("movie.mkv")))
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected status 413, got %d", w.Code)
+	}
+
+	r.Header.Del("Tus-Resumable")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	if w.Code != http.StatusPreconditionFailed {
+		t.Errorf("expected status 412 without the protocol version, got %d", w.Code)
+	}
+}
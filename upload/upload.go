@@ -0,0 +1,391 @@
+package upload
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"vrmix/logging"
+	"vrmix/report"
+	"vrmix/source"
+)
+
+var (
+	// ErrInvalidName indicates that the name of the uploaded file is empty, a path, or has an extension that is not accepted.
+	ErrInvalidName = errors.New("invalid file name")
+
+	// ErrTooLarge indicates that the uploaded file exceeds the maximum size.
+	ErrTooLarge = errors.New("file too large")
+
+	// ErrUploadNotFound indicates that there is no upload with the ID.
+	ErrUploadNotFound = errors.New("upload not found")
+)
+
+// Storage keeps the completed uploads, returning the reference queueable through the matching source.
+type Storage interface {
+	Store(ctx context.Context, name string, localPath string) (string, error)
+}
+
+// DirStorage is a Storage moving the uploads into the root of a FileSource, referenced by "file:///" URIs.
+type DirStorage struct {
+	Root string // Directory the uploads are moved into
+}
+
+// Store moves the file into the root, appending the upload ID to the name when a file with the same name exists.
+func (s *DirStorage) Store(ctx context.Context, name string, localPath string) (string, error) {
+	if err := os.MkdirAll(s.Root, 0o755); err != nil {
+		return "", err
+	}
+
+	target := name
+	if _, err := os.Stat(filepath.Join(s.Root, target)); err == nil {
+		target = strings.TrimSuffix(name, path.Ext(name)) + "-" + strings.TrimSuffix(filepath.Base(localPath), filepath.Ext(localPath)) + path.Ext(name)
+	}
+
+	destination := filepath.Join(s.Root, target)
+	if err := os.Rename(localPath, destination); err != nil {
+		if err := copyFile(localPath, destination); err != nil {
+			return "", err
+		}
+		os.Remove(localPath)
+	}
+
+	return source.FileURI(target), nil
+}
+
+// copyFile copies the file, for when it cannot be renamed across file systems.
+func copyFile(from string, to string) error {
+	input, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+
+	output, err := os.OpenFile(to, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(output, input); err != nil {
+		output.Close()
+		os.Remove(to)
+		return err
+	}
+
+	return output.Close()
+}
+
+// Upload represents a completed upload, registered as a queueable item.
+type Upload struct {
+	ID   string      `json:"id"`   // ID of the upload
+	Name string      `json:"name"` // Name of the uploaded file
+	Size int64       `json:"size"` // Size of the file in bytes
+	Item source.Item `json:"item"` // Queueable item of the file
+}
+
+// Handler receives uploads in a single multipart request or resumable with the tus protocol, with routes relative to the mount point:
+//
+//	POST    /uploads
+//	GET     /uploads/{id}
+//	OPTIONS /tus
+//	POST    /tus
+//	HEAD    /tus/{id}
+//	PATCH   /tus/{id}
+//	DELETE  /tus/{id}
+type Handler struct {
+	Dir        string        // Directory holding the uploads in progress
+	Storage    Storage       // Storage keeping the completed uploads
+	Prober     source.Prober // Prober extracting the metadata of the uploads, nil to register them without metadata
+	MaxSize    int64         // Maximum size of an upload in bytes, defaults to 10 GiB
+	Extensions []string      // File extensions accepted, defaults to source.MediaExtensions
+	Expiry     time.Duration // Time after which incomplete tus uploads are removed, defaults to one day
+
+	// Authorize checks the credentials of a request, nil rejects every request.
+	Authorize func(r *http.Request) bool
+
+	// OnUpload registers a completed upload as a queueable item, like adding it to a catalog or a queue.
+	OnUpload func(ctx context.Context, upload Upload) error
+
+	// Logger receives the completed and failed uploads, defaults to slog.Default.
+	Logger *slog.Logger
+
+	// Reporter receives the uploads the storage failed to store, nil to not report them.
+	Reporter report.Reporter
+
+	once      sync.Once
+	mux       *http.ServeMux
+	mutex     sync.Mutex
+	locks     map[string]*sync.Mutex
+	completed map[string]Upload
+}
+
+// BearerToken returns an Authorize function accepting requests with the token in the Authorization header.
+func BearerToken(token string) func(r *http.Request) bool {
+	return func(r *http.Request) bool {
+		given, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		return found && subtle.ConstantTimeCompare([]byte(given), []byte(token)) == 1
+	}
+}
+
+// errorResponse represents the body of a failed request.
+type errorResponse struct {
+	Error string `json:"error"` // Reason of the failure
+}
+
+// writeJSON writes the value as JSON with the status code.
+func writeJSON(w http.ResponseWriter, code int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(value)
+}
+
+// writeError writes the error with the status code matching it.
+func writeError(w http.ResponseWriter, err error) {
+	code := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, ErrInvalidName):
+		code = http.StatusBadRequest
+	case errors.Is(err, ErrTooLarge):
+		code = http.StatusRequestEntityTooLarge
+	case errors.Is(err, ErrUploadNotFound):
+		code = http.StatusNotFound
+	}
+
+	writeJSON(w, code, errorResponse{Error: err.Error()})
+}
+
+// maxSize returns the maximum size of an upload.
+func (h *Handler) maxSize() int64 {
+	if h.MaxSize <= 0 {
+		return 10 << 30
+	}
+
+	return h.MaxSize
+}
+
+// checkName checks that the name is a plain file name with an accepted extension.
+func (h *Handler) checkName(name string) error {
+	extensions := h.Extensions
+	if extensions == nil {
+		extensions = source.MediaExtensions
+	}
+
+	if name == "" || name != filepath.Base(name) || strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		return ErrInvalidName
+	}
+
+	if !slices.Contains(extensions, strings.ToLower(path.Ext(name))) {
+		return ErrInvalidName
+	}
+
+	return nil
+}
+
+// newID returns a random upload ID.
+func newID() string {
+	random := make([]byte, 16)
+	rand.Read(random)
+	return hex.EncodeToString(random)
+}
+
+// partPath returns the path of the data of an upload in progress.
+func (h *Handler) partPath(id string) string {
+	return filepath.Join(h.Dir, id+".part")
+}
+
+// lock locks the upload, returning false when it is already locked by another request.
+func (h *Handler) lock(id string) (*sync.Mutex, bool) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.locks == nil {
+		h.locks = make(map[string]*sync.Mutex)
+	}
+
+	lock, ok := h.locks[id]
+	if !ok {
+		lock = &sync.Mutex{}
+		h.locks[id] = lock
+	}
+
+	return lock, lock.TryLock()
+}
+
+// complete probes and stores the uploaded file, registering it through OnUpload and logging the outcome.
+func (h *Handler) complete(ctx context.Context, id string, name string, localPath string) (Upload, error) {
+	logger := logging.Or(h.Logger).With(slog.String("upload", id), slog.String("name", name))
+
+	upload, err := h.store(ctx, id, name, localPath)
+	if err != nil {
+		logger.Error("upload failed", logging.Err(err))
+		return Upload{}, err
+	}
+
+	logger.Info("upload completed", slog.String(logging.RefKey, upload.Item.Ref), slog.Int64("size", upload.Size))
+	return upload, nil
+}
+
+// store probes and stores the uploaded file, registering it through OnUpload.
+func (h *Handler) store(ctx context.Context, id string, name string, localPath string) (Upload, error) {
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return Upload{}, err
+	}
+
+	item := source.Item{Title: strings.TrimSuffix(name, path.Ext(name))}
+	if h.Prober != nil {
+		probed, err := h.Prober.Probe(ctx, localPath)
+		if err != nil {
+			os.Remove(localPath)
+			return Upload{}, err
+		}
+
+		if probed.Title != "" {
+			item.Title = probed.Title
+		}
+		item.Duration, item.Live = probed.Duration, probed.Live
+	}
+
+	if item.Ref, err = h.Storage.Store(ctx, name, localPath); err != nil {
+		report.Error(ctx, h.Reporter, "upload", err, map[string]string{"upload": id, "name": name})
+		return Upload{}, err
+	}
+
+	upload := Upload{ID: id, Name: name, Size: info.Size(), Item: item}
+	if h.OnUpload != nil {
+		if err := h.OnUpload(ctx, upload); err != nil {
+			return Upload{}, err
+		}
+	}
+
+	h.mutex.Lock()
+	if h.completed == nil {
+		h.completed = make(map[string]Upload)
+	}
+	h.completed[id] = upload
+	delete(h.locks, id)
+	h.mutex.Unlock()
+
+	return upload, nil
+}
+
+// ServeHTTP checks the credentials, serving the upload routes.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.once.Do(func() {
+		h.mux = http.NewServeMux()
+		h.mux.HandleFunc("POST /uploads", h.multipart)
+		h.mux.HandleFunc("GET /uploads/{id}", h.get)
+		h.mux.HandleFunc("OPTIONS /tus", h.tusOptions)
+		h.mux.HandleFunc("POST /tus", h.tusCreate)
+		h.mux.HandleFunc("HEAD /tus/{id}", h.tusHead)
+		h.mux.HandleFunc("PATCH /tus/{id}", h.tusPatch)
+		h.mux.HandleFunc("DELETE /tus/{id}", h.tusDelete)
+	})
+
+	if r.Method != http.MethodOptions && (h.Authorize == nil || !h.Authorize(r)) {
+		writeJSON(w, http.StatusUnauthorized, errorResponse{Error: "unauthorized"})
+		return
+	}
+
+	h.mux.ServeHTTP(w, r)
+}
+
+// multipart receives a whole file from the "file" field of a multipart form.
+func (h *Handler) multipart(w http.ResponseWriter, r *http.Request) {
+	reader, err := r.MultipartReader()
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: "missing file field"})
+			return
+		} else if err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+			return
+		}
+
+		if part.FormName() != "file" {
+			part.Close()
+			continue
+		}
+
+		name := part.FileName()
+		if err := h.checkName(name); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		id := newID()
+		if err := h.receive(h.partPath(id), part); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		upload, err := h.complete(r.Context(), id, name, h.partPath(id))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusCreated, upload)
+		return
+	}
+}
+
+// receive writes the body into the file, removing it when it exceeds the maximum size or fails.
+func (h *Handler) receive(localPath string, body io.Reader) error {
+	if err := os.MkdirAll(h.Dir, 0o755); err != nil {
+		return err
+	}
+
+	file, err := os.Create(localPath)
+	if err != nil {
+		return err
+	}
+
+	written, err := io.Copy(file, io.LimitReader(body, h.maxSize()+1))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil && written > h.maxSize() {
+		err = ErrTooLarge
+	}
+
+	if err != nil {
+		os.Remove(localPath)
+	}
+
+	return err
+}
+
+// get returns a completed upload.
+func (h *Handler) get(w http.ResponseWriter, r *http.Request) {
+	h.mutex.Lock()
+	upload, ok := h.completed[r.PathValue("id")]
+	h.mutex.Unlock()
+
+	if !ok {
+		writeError(w, ErrUploadNotFound)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, upload)
+}
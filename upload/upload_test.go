@@ -0,0 +1,140 @@
+package upload
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"vrmix/source"
+)
+
+// fakeProber reports a fixed duration for every file
+type fakeProber struct{}
+
+func (fakeProber) Probe(ctx context.Context, path string) (source.Item, error) {
+	return source.Item{Duration: 12.5}, nil
+}
+
+// newHandler returns a handler storing the uploads in a temporary directory, accepting the token "secret"
+func newHandler(t *testing.T) (*Handler, string) {
+	root := t.TempDir()
+
+	return &Handler{
+		Dir:       filepath.Join(root, "incoming"),
+		Storage:   &DirStorage{Root: filepath.Join(root, "media")},
+		Prober:    fakeProber{},
+		Authorize: BearerToken("secret"),
+	}, filepath.Join(root, "media")
+}
+
+// multipartRequest returns a multipart upload request of the file
+func multipartRequest(t *testing.T, name string, data []byte) *http.Request {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+
+	part, err := writer.CreateFormFile("file", name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(data)
+	writer.Close()
+
+	r := httptest.NewRequest(http.MethodPost, "/uploads", &body)
+	r.Header.Set("Content-Type", writer.FormDataContentType())
+	r.Header.Set("Authorization", "Bearer secret")
+	return r
+}
+
+func TestMultipartUpload(t *testing.T) {
+	h, media := newHandler(t)
+
+	var registered []Upload
+	h.OnUpload = func(ctx context.Context, upload Upload) error {
+		registered = append(registered, upload)
+		return nil
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, multipartRequest(t, "clip.mp4", []byte("video")))
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", w.Code, w.Body)
+	}
+
+	var upload Upload
+	if err := json.NewDecoder(w.Body).Decode(&upload); err != nil {
+		t.Fatal(err)
+	}
+
+	if upload.Item.Ref != source.FileURIPrefix+"clip.mp4" || upload.Item.Title != "clip" || upload.Item.Duration != 12.5 || upload.Size != 5 {
+		t.Errorf("expected the probed and stored clip, got %+v", upload)
+	}
+
+	if data, err := os.ReadFile(filepath.Join(media, "clip.mp4")); err != nil || string(data) != "video" {
+		t.Errorf("expected the file in the storage, got %q and %v", data, err)
+	}
+
+	if len(registered) != 1 || registered[0].ID != upload.ID {
+		t.Errorf("expected the upload to be registered, got %v", registered)
+	}
+
+	w = httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/uploads/"+upload.ID, nil)
+	r.Header.Set("Authorization", "Bearer secret")
+	h.ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected the completed upload, got status %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, multipartRequest(t, "clip.mp4", []byte("other")))
+
+	if err := json.NewDecoder(w.Body).Decode(&upload); err != nil {
+		t.Fatal(err)
+	}
+
+	if upload.Item.Ref == source.FileURIPrefix+"clip.mp4" {
+		t.Errorf("expected a new name for the duplicate, got %s", upload.Item.Ref)
+	}
+}
+
+func TestMultipartUploadRejected(t *testing.T) {
+	h, _ := newHandler(t)
+	h.MaxSize = 4
+
+	tests := []struct {
+		name string
+		data string
+		auth string
+		code int
+	}{
+		{"clip.mp4", "vid", "", http.StatusUnauthorized},
+		{"clip.mp4", "vid", "Bearer wrong", http.StatusUnauthorized},
+		{"notes.txt", "vid", "Bearer secret", http.StatusBadRequest},
+		{".clip.mp4", "vid", "Bearer secret", http.StatusBadRequest},
+		{"clip.mp4", "video", "Bearer secret", http.StatusRequestEntityTooLarge},
+	}
+
+	for _, test := range tests {
+		r := multipartRequest(t, test.name, []byte(test.data))
+		r.Header.Set("Authorization", test.auth)
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if w.Code != test.code {
+			t.Errorf("expected status %d for %s, got %d", test.code, test.name, w.Code)
+		}
+	}
+
+	if entries, _ := os.ReadDir(h.Dir); len(entries) != 0 {
+		t.Errorf("expected no leftover file, got %d", len(entries))
+	}
+}
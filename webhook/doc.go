@@ -0,0 +1,2 @@
+// Package webhook contains types and functions to notify external services about VRMix events through signed HTTP callbacks.
+package webhook
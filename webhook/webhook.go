@@ -0,0 +1,284 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"vrmix/events"
+	"vrmix/logging"
+	"vrmix/report"
+)
+
+const (
+	// EventItemStarted is fired when a channel starts playing a queue item.
+	EventItemStarted = "item.started"
+
+	// EventChannelErrored is fired when a channel fails and stops advancing.
+	EventChannelErrored = "channel.errored"
+
+	// EventConversionFailed is fired when a conversion job fails.
+	EventConversionFailed = "conversion.failed"
+
+	// EventSessionThreshold is fired when the session count of a channel crosses a configured threshold.
+	EventSessionThreshold = "sessions.threshold"
+
+	// EventAlertRaised is fired when a condition affecting the playback of a channel is raised or changes severity.
+	EventAlertRaised = "alert.raised"
+
+	// EventAlertResolved is fired when a condition affecting the playback of a channel is back to normal.
+	EventAlertResolved = "alert.resolved"
+)
+
+const (
+	// SignatureHeader is the header holding the HMAC-SHA256 of the timestamp and the body, as "sha256=<hex>".
+	SignatureHeader = "X-VRMix-Signature"
+
+	// TimestampHeader is the header holding the Unix timestamp of the delivery, included in the signature to prevent replays.
+	TimestampHeader = "X-VRMix-Timestamp"
+
+	// EventHeader is the header holding the event type.
+	EventHeader = "X-VRMix-Event"
+)
+
+var (
+	// ErrQueueFull indicates that the event was dropped because the delivery queue is full.
+	ErrQueueFull = errors.New("webhook queue is full")
+
+	// ErrDeliveryFailed indicates that the endpoint did not accept the event after every retry.
+	ErrDeliveryFailed = errors.New("webhook delivery failed")
+
+	// ErrDispatcherClosed indicates that the dispatcher no longer accepts events.
+	ErrDispatcherClosed = errors.New("webhook dispatcher closed")
+)
+
+// Event represents something that happened in VRMix.
+type Event struct {
+	Type    string         `json:"type"`              // Type of the event, like EventItemStarted
+	Time    time.Time      `json:"time"`              // Time the event happened
+	Channel string         `json:"channel,omitempty"` // Channel related to the event, if any
+	Data    map[string]any `json:"data,omitempty"`    // Details of the event
+}
+
+// Webhook represents an endpoint notified about events.
+type Webhook struct {
+	URL    string   // Endpoint receiving the events
+	Secret string   // Secret used to sign the deliveries
+	Events []string // Event types delivered, empty or "*" for every event
+}
+
+// accepts returns true if the webhook is subscribed to the event type.
+func (w *Webhook) accepts(eventType string) bool {
+	return len(w.Events) == 0 || slices.Contains(w.Events, "*") || slices.Contains(w.Events, eventType)
+}
+
+// Sign returns the signature of a delivery with the specified timestamp and body.
+func Sign(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify returns true if the signature matches the timestamp and body, for receivers written in Go.
+func Verify(secret string, timestamp string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
+
+// Config represents the configuration of a Dispatcher.
+type Config struct {
+	Webhooks  []Webhook     // Endpoints notified about the events
+	Client    *http.Client  // Client used for the deliveries, defaults to a client with a ten seconds timeout
+	Retries   int           // Retries after a failed delivery
+	Backoff   time.Duration // Delay before the first retry, doubled on each retry, defaults to one second
+	QueueSize int           // Deliveries waiting to be sent before new events are dropped, defaults to 256
+	Workers   int           // Concurrent deliveries, defaults to 2
+
+	// OnFailure is called when a delivery fails after every retry, nil to only log failures.
+	OnFailure func(hook Webhook, event Event, err error)
+
+	// Logger receives the failed and dropped deliveries, defaults to slog.Default.
+	Logger *slog.Logger
+
+	// Reporter receives the deliveries failed after every retry, nil to not report them.
+	Reporter report.Reporter
+}
+
+// delivery is an event waiting to be sent to a webhook.
+type delivery struct {
+	hook  Webhook
+	event Event
+}
+
+// Dispatcher delivers events asynchronously to the subscribed webhooks, retrying failed deliveries with exponential backoff.
+type Dispatcher struct {
+	config Config
+	queue  chan delivery
+	wg     sync.WaitGroup
+	mutex  sync.RWMutex
+	closed bool
+	now    func() time.Time
+}
+
+// NewDispatcher creates a new Dispatcher and starts its workers.
+func NewDispatcher(config Config) *Dispatcher {
+	if config.Client == nil {
+		config.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	if config.Backoff <= 0 {
+		config.Backoff = time.Second
+	}
+
+	if config.QueueSize <= 0 {
+		config.QueueSize = 256
+	}
+
+	if config.Workers <= 0 {
+		config.Workers = 2
+	}
+
+	d := &Dispatcher{config: config, queue: make(chan delivery, config.QueueSize), now: time.Now}
+	for i := 0; i < config.Workers; i++ {
+		d.wg.Add(1)
+		go d.work()
+	}
+
+	return d
+}
+
+// Notify queues the event for every subscribed webhook, without waiting for the deliveries.
+func (d *Dispatcher) Notify(event Event) error {
+	if event.Time.IsZero() {
+		event.Time = d.now()
+	}
+
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	if d.closed {
+		return ErrDispatcherClosed
+	}
+
+	var err error
+	for _, hook := range d.config.Webhooks {
+		if !hook.accepts(event.Type) {
+			continue
+		}
+
+		select {
+		case d.queue <- delivery{hook: hook, event: event}:
+		default:
+			err = ErrQueueFull
+			logging.Or(d.config.Logger).Warn("webhook delivery dropped", slog.String("url", hook.URL), slog.String("event", event.Type), slog.String(logging.ChannelKey, event.Channel), logging.Err(err))
+		}
+	}
+
+	return err
+}
+
+// Close stops accepting events and waits until the queued deliveries are sent or the context is done.
+func (d *Dispatcher) Close(ctx context.Context) error {
+	d.mutex.Lock()
+	if !d.closed {
+		d.closed = true
+		close(d.queue)
+	}
+	d.mutex.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// work sends the queued deliveries until the queue is closed.
+func (d *Dispatcher) work() {
+	defer d.wg.Done()
+
+	for delivery := range d.queue {
+		err := d.deliver(delivery)
+		if err == nil {
+			continue
+		}
+
+		logging.Or(d.config.Logger).Error("webhook delivery failed", slog.String("url", delivery.hook.URL), slog.String("event", delivery.event.Type), slog.String(logging.ChannelKey, delivery.event.Channel), logging.Err(err))
+		report.Error(context.Background(), d.config.Reporter, "webhook", err, map[string]string{"url": delivery.hook.URL, "event": delivery.event.Type, logging.ChannelKey: delivery.event.Channel})
+		if d.config.OnFailure != nil {
+			d.config.OnFailure(delivery.hook, delivery.event, err)
+		}
+	}
+}
+
+// deliver sends the event to the webhook, retrying with exponential backoff.
+func (d *Dispatcher) deliver(delivery delivery) error {
+	body, err := json.Marshal(delivery.event)
+	if err != nil {
+		return err
+	}
+
+	backoff := d.config.Backoff
+	for attempt := 0; ; attempt++ {
+		err = d.send(delivery.hook, delivery.event.Type, body)
+		if err == nil || attempt >= d.config.Retries {
+			return err
+		}
+
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// send makes a single delivery attempt.
+func (d *Dispatcher) send(hook Webhook, eventType string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	timestamp := strconv.FormatInt(d.now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, eventType)
+	req.Header.Set(TimestampHeader, timestamp)
+	if hook.Secret != "" {
+		req.Header.Set(SignatureHeader, Sign(hook.Secret, timestamp, body))
+	}
+
+	resp, err := d.config.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return ErrDeliveryFailed
+	}
+
+	return nil
+}
+
+// Forward notifies the webhooks about the events of the bus until the context is done, so the bus is the only thing the subsystems publish to.
+func (d *Dispatcher) Forward(ctx context.Context, bus *events.Bus) *events.Subscription {
+	return bus.Handle(ctx, events.Options{Buffer: d.config.QueueSize}, func(event events.Event) {
+		d.Notify(Event{Type: string(event.Type), Time: event.Time, Channel: event.Channel, Data: event.Data})
+	})
+}
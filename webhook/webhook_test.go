@@ -0,0 +1,139 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"vrmix/events"
+	"vrmix/report"
+)
+
+func TestDispatcher(t *testing.T) {
+	var mutex sync.Mutex
+	var received []Event
+	var attempts atomic.Int32
+
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		if !Verify("secret", r.Header.Get(TimestampHeader), body, r.Header.Get(SignatureHeader)) {
+			t.Errorf("expected valid signature")
+		}
+
+		var event Event
+		if err := json.Unmarshal(body, &event); err != nil {
+			t.Error(err)
+		}
+
+		mutex.Lock()
+		received = append(received, event)
+		mutex.Unlock()
+	}))
+	defer endpoint.Close()
+
+	d := NewDispatcher(Config{
+		Webhooks: []Webhook{{URL: endpoint.URL, Secret: "secret", Events: []string{EventConversionFailed}}},
+		Retries:  2,
+		Backoff:  time.Millisecond,
+		Workers:  1,
+	})
+
+	if err := d.Notify(Event{Type: EventItemStarted, Channel: "main"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.Notify(Event{Type: EventConversionFailed, Channel: "main"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(received) != 1 || received[0].Type != EventConversionFailed {
+		t.Fatalf("expected only the conversion failure to be delivered, got %v", received)
+	}
+
+	if attempts.Load() != 2 {
+		t.Errorf("expected 2 attempts, got %d", attempts.Load())
+	}
+
+	if err := d.Notify(Event{Type: EventConversionFailed}); err != ErrDispatcherClosed {
+		t.Errorf("expected closed dispatcher, got %v", err)
+	}
+}
+
+func TestDispatcherFailure(t *testing.T) {
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer endpoint.Close()
+
+	var failures, reports atomic.Int32
+	var logs bytes.Buffer
+	d := NewDispatcher(Config{
+		Webhooks:  []Webhook{{URL: endpoint.URL}},
+		Retries:   1,
+		Backoff:   time.Millisecond,
+		OnFailure: func(hook Webhook, event Event, err error) { failures.Add(1) },
+		Logger:    slog.New(slog.NewTextHandler(&logs, nil)),
+		Reporter:  report.Func(func(ctx context.Context, r report.Report) { reports.Add(1) }),
+	})
+
+	d.Notify(Event{Type: EventChannelErrored, Channel: "main"})
+	d.Close(context.Background())
+
+	if failures.Load() != 1 {
+		t.Errorf("expected 1 failure, got %d", failures.Load())
+	}
+
+	if reports.Load() != 1 {
+		t.Errorf("expected 1 report, got %d", reports.Load())
+	}
+
+	if !strings.Contains(logs.String(), "webhook delivery failed") || !strings.Contains(logs.String(), "channel=main") {
+		t.Errorf("expected the failure to be logged with its channel, got %s", logs.String())
+	}
+}
+
+func TestDispatcherForward(t *testing.T) {
+	delivered := make(chan string, 1)
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		delivered <- r.Header.Get(EventHeader)
+	}))
+	defer endpoint.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	bus := &events.Bus{}
+	d := NewDispatcher(Config{Webhooks: []Webhook{{URL: endpoint.URL, Events: []string{EventItemStarted}}}})
+	d.Forward(ctx, bus)
+
+	bus.Publish(events.Event{Type: events.ItemStarted, Channel: "main"})
+
+	select {
+	case eventType := <-delivered:
+		if eventType != EventItemStarted {
+			t.Errorf("expected %s, got %s", EventItemStarted, eventType)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the bus event to be delivered")
+	}
+
+	d.Close(context.Background())
+}